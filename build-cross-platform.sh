@@ -45,7 +45,7 @@ build_platform() {
     echo "📦 Building ${GOOS}/${GOARCH}..."
     
     GOOS=$GOOS GOARCH=$GOARCH go build \
-        -ldflags="-s -w -X main.AppVersion=${VERSION}" \
+        -ldflags="-s -w -X github.com/mako10k/llmcmd/internal/buildinfo.Version=${VERSION} -X github.com/mako10k/llmcmd/internal/buildinfo.Commit=$(git rev-parse --short HEAD 2>/dev/null || echo unknown)" \
         -o "${output_path}" \
         "${MAIN_FILE}"
     
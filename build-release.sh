@@ -7,8 +7,11 @@ set -e
 
 VERSION="v3.1.1"
 BUILD_DIR="release"
-LDFLAGS_LLMCMD="-s -w -X main.AppVersion=${VERSION}"
-LDFLAGS_LLMSH="-s -w -X github.com/mako10k/llmcmd/internal/llmsh.Version=${VERSION}"
+COMMIT="$(git rev-parse --short HEAD 2>/dev/null || echo unknown)"
+BUILD_TIME="$(date -u '+%Y-%m-%dT%H:%M:%SZ')"
+BUILDINFO="github.com/mako10k/llmcmd/internal/buildinfo"
+LDFLAGS_LLMCMD="-s -w -X ${BUILDINFO}.Version=${VERSION} -X ${BUILDINFO}.Commit=${COMMIT} -X ${BUILDINFO}.Date=${BUILD_TIME}"
+LDFLAGS_LLMSH="${LDFLAGS_LLMCMD}"
 
 echo "Building llmcmd ${VERSION} for multiple platforms..."
 
@@ -0,0 +1,58 @@
+// Command gendocs writes the llmcmd and llmsh man pages and the llmcmd shell
+// completions, for packaging:
+//
+//	go run ./cmd/gendocs -out build/docs
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/mako10k/llmcmd/internal/docgen"
+)
+
+func main() {
+	out := flag.String("out", "build/docs", "Output directory")
+	flag.Parse()
+
+	// Reproducible builds pin the man page date with SOURCE_DATE_EPOCH
+	date := time.Now().UTC()
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		date = time.Unix(epoch, 0).UTC()
+	}
+	day := date.Format("2006-01-02")
+
+	files := map[string]func(io.Writer) error{
+		"man/llmcmd.1":            func(w io.Writer) error { return docgen.WriteLLMCmdManPage(w, day) },
+		"man/llmsh.1":             func(w io.Writer) error { return docgen.WriteLLMShManPage(w, day) },
+		"completions/llmcmd.bash": docgen.WriteBashCompletion,
+		"completions/_llmcmd":     docgen.WriteZshCompletion,
+		"completions/llmcmd.fish": docgen.WriteFishCompletion,
+	}
+	for name, write := range files {
+		if err := writeFile(filepath.Join(*out, name), write); err != nil {
+			log.Fatalf("gendocs: %v", err)
+		}
+	}
+}
+
+// writeFile creates path and its directory and fills it with write
+func writeFile(path string, write func(io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
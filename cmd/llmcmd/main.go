@@ -1,26 +1,32 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"os"
 
 	"github.com/mako10k/llmcmd/internal/app"
+	"github.com/mako10k/llmcmd/internal/buildinfo"
+	_ "github.com/mako10k/llmcmd/internal/llmsh" // Registers the in-process llmsh for spawn_shell=llmsh
+	"github.com/mako10k/llmcmd/internal/openai"
 )
 
-// Application metadata
-var (
-	AppName    = "llmcmd"
-	AppVersion = "3.1.1" // Will be overridden by build-time ldflags
-)
+// AppName is the application name reported by --version
+const AppName = "llmcmd"
 
 func main() {
 	metadata := app.ApplicationMetadata{
 		Name:    AppName,
-		Version: AppVersion,
+		Version: buildinfo.Version,
 	}
 
 	// Execute as external command
 	if err := app.ExecuteExternal(metadata, os.Args[1:]); err != nil {
+		var refusal *openai.RefusalError
+		if errors.As(err, &refusal) {
+			log.Printf("Model refused (%s): %s", refusal.Source, refusal.Reason)
+			os.Exit(app.RefusalExitCode)
+		}
 		log.Fatalf("Application error: %v", err)
 	}
 }
@@ -4,9 +4,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 
+	"github.com/mako10k/llmcmd/internal/buildinfo"
 	"github.com/mako10k/llmcmd/internal/llmsh"
+	"github.com/mako10k/llmcmd/internal/vfsproxy"
 )
 
 func main() {
@@ -14,27 +18,79 @@ func main() {
 	var inputFile, outputFile string
 	var script string
 	var interactive bool
+	var timing bool
+	var checkOnly bool
+	var scriptFile string
+	var allowedCommands []string
+	var transcriptFile, replayFile string
+	// A parent llmcmd announces its VFS connection in the environment
+	vfsFd, _ := strconv.Atoi(os.Getenv(vfsproxy.EnvVar))
+	vfsSocket := os.Getenv(vfsproxy.SocketEnvVar)
 
 	args := os.Args[1:]
-	for i, arg := range args {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
 		switch arg {
 		case "-i":
 			if i+1 < len(args) {
 				inputFile = args[i+1]
+				i++ // The value is not an argument of its own
 			}
 		case "-o":
 			if i+1 < len(args) {
 				outputFile = args[i+1]
+				i++
 			}
 		case "-c":
 			if i+1 < len(args) {
 				script = args[i+1]
+				i++
+			}
+		case "-n":
+			checkOnly = true
+		case "--allow-commands":
+			if i+1 < len(args) {
+				allowedCommands = strings.Split(args[i+1], ",")
+				i++
+			}
+		case "--transcript":
+			if i+1 < len(args) {
+				transcriptFile = args[i+1]
+				i++
+			}
+		case "--replay":
+			if i+1 < len(args) {
+				replayFile = args[i+1]
+				i++
+			}
+		case "--timing":
+			timing = true
+		case "--vfs-fd":
+			if i+1 < len(args) {
+				fd, err := strconv.Atoi(args[i+1])
+				if err != nil || fd <= 0 {
+					fmt.Fprintf(os.Stderr, "Error: invalid --vfs-fd %q\n", args[i+1])
+					os.Exit(1)
+				}
+				vfsFd = fd
+				vfsSocket = ""
+				i++
+			}
+		case "--vfs-socket":
+			if i+1 < len(args) {
+				vfsSocket = args[i+1]
+				i++
 			}
 		case "--help", "-h":
 			printUsage()
 			return
 		case "--version":
-			fmt.Printf("%s version %s\n", llmsh.Name, llmsh.Version)
+			info := buildinfo.Get(llmsh.Name)
+			if slices.Contains(args, "--json") {
+				fmt.Println(info.JSON())
+			} else {
+				fmt.Println(info)
+			}
 			return
 		default:
 			if !strings.HasPrefix(arg, "-") && script == "" {
@@ -45,12 +101,53 @@ func main() {
 					os.Exit(1)
 				}
 				script = string(content)
+				scriptFile = arg
 			}
 		}
 	}
 
+	// With -n the script is only parsed; syntax errors exit with status 2 as
+	// in sh
+	if checkOnly {
+		if script == "" {
+			content, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading from stdin: %v\n", err)
+				os.Exit(1)
+			}
+			script = string(content)
+		}
+		if err := llmsh.Check(script, allowedCommands); err != nil {
+			if scriptFile != "" {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", scriptFile, err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			os.Exit(2)
+		}
+		return
+	}
+
+	// A replay runs the commands of a transcript again with the input they read
+	var stdin io.Reader
+	if replayFile != "" {
+		file, err := os.Open(replayFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading transcript: %v\n", err)
+			os.Exit(1)
+		}
+		var input string
+		script, input, err = llmsh.ReadTranscript(file)
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading transcript: %v\n", err)
+			os.Exit(1)
+		}
+		stdin = strings.NewReader(input)
+	}
+
 	// If no script provided, check if we should read from stdin or be interactive
-	if script == "" {
+	if script == "" && replayFile == "" {
 		stat, err := os.Stdin.Stat()
 		if err == nil && (stat.Mode()&os.ModeCharDevice) == 0 {
 			// Reading from pipe/redirection
@@ -66,11 +163,25 @@ func main() {
 		}
 	}
 
+	// A .llmsh directory in the project keeps its history and scripts run
+	historyDir := ""
+	if cwd, err := os.Getwd(); err == nil {
+		historyDir = llmsh.FindProjectDir(cwd)
+	}
+
 	// Create shell configuration
 	config := &llmsh.Config{
 		InputFile:  inputFile,
 		OutputFile: outputFile,
 		Debug:      false,
+		VFSFd:      vfsFd,
+		VFSSocket:  vfsSocket,
+		Timing:     timing,
+
+		AllowedCommands: allowedCommands,
+		HistoryDir:      historyDir,
+		Transcript:      transcriptFile,
+		Stdin:           stdin,
 	}
 
 	// Create shell instance
@@ -86,6 +197,7 @@ func main() {
 		fmt.Println("Type 'help' for available commands, 'exit' to quit")
 
 		err = shell.Interactive()
+		closeShell(shell)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error in interactive mode: %v\n", err)
 			os.Exit(1)
@@ -93,25 +205,39 @@ func main() {
 	} else {
 		// Execute script
 		err = shell.Execute(script)
+		closeShell(shell)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error executing script: %v\n", err)
-			os.Exit(1)
+			failure := shell.Failure(err)
+			if vfsFd > 0 || vfsSocket != "" {
+				// Run by llmcmd's spawn, which parses the trailer into its result
+				fmt.Fprint(os.Stderr, failure.Trailer())
+			}
+			os.Exit(failure.ExitCode)
 		}
 	}
 }
 
+// closeShell ends the session, reporting a transcript that could not be written
+func closeShell(shell *llmsh.Shell) {
+	if err := shell.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+}
+
 func printUsage() {
 	fmt.Printf("Usage: %s [options] [script]\n\n", os.Args[0])
 	fmt.Println("Options:")
-	fmt.Println("  -i <file>     Input file (accessible as stdin)")
-	fmt.Println("  -o <file>     Output file (accessible as stdout)")
-	fmt.Println("  -c <script>   Execute script string")
-	fmt.Println("  -h, --help    Show this help")
-	fmt.Println("  --version     Show version")
+	for _, option := range llmsh.CommandLineOptions {
+		fmt.Printf("  %-24s %s\n", option.Flag, option.Description)
+	}
 	fmt.Println("")
 	fmt.Println("Examples:")
 	fmt.Printf("  %s -c 'echo hello | grep ello'\n", os.Args[0])
 	fmt.Printf("  echo 'cat file.txt | grep error' | %s\n", os.Args[0])
 	fmt.Printf("  %s script.llmsh\n", os.Args[0])
+	fmt.Printf("  %s -n script.llmsh  # Check syntax only\n", os.Args[0])
+	fmt.Printf("  %s --transcript session.jsonl script.llmsh\n", os.Args[0])
+	fmt.Printf("  %s --replay session.jsonl\n", os.Args[0])
 	fmt.Printf("  %s  # Interactive mode\n", os.Args[0])
 }
@@ -2,31 +2,48 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/mako10k/llmcmd/internal/cli"
+	"github.com/mako10k/llmcmd/internal/debugdump"
 	"github.com/mako10k/llmcmd/internal/openai"
 	"github.com/mako10k/llmcmd/internal/tools"
+	"github.com/mako10k/llmcmd/internal/vfsproxy"
 )
 
+// RefusalExitCode is the exit status when the model declines the request
+const RefusalExitCode = 3
+
+// brokenPipeExitCode is the exit status when stdout is closed early (128 + SIGPIPE),
+// the same status shell tools report when their reader goes away
+const brokenPipeExitCode = 141
+
 // App represents the main application
 type App struct {
 	config         *cli.Config
 	fileConfig     *cli.ConfigFile
 	openaiClient   *openai.Client
 	toolEngine     *tools.Engine
+	virtualFS      *SimpleVirtualFS
 	startTime      time.Time
 	iterationCount int
 	exitRequested  bool
 	exitCode       int
+	// Text of completions cut off by finish_reason=length, written once the reply finishes
+	pendingOutput     strings.Builder
+	continuationCount int
 	// Shared quota support
 	sharedQuota *openai.SharedQuotaManager
 	processID   string
@@ -57,6 +74,10 @@ func NewWithSharedQuota(config *cli.Config, quotaManager *openai.SharedQuotaMana
 
 // Run executes the main application logic
 func (a *App) Run() error {
+	// Turn broken stdout pipes into EPIPE write errors instead of killing the process
+	stopPipeSignals := catchBrokenPipe()
+	defer stopPipeSignals()
+
 	// Load configuration file
 	var err error
 	a.fileConfig, err = cli.LoadAndMergeConfig(a.config)
@@ -91,10 +112,37 @@ func (a *App) Run() error {
 		return err
 	}
 
-	// Execute LLM interaction
-	if err := a.executeWithError(a.executeTask, "execute task"); err != nil {
+	// Spilled files whose names could not be removed while open
+	defer removeNamedSpills()
+
+	// Compress large virtual files while they are not used
+	stopCompression := a.virtualFS.StartCompression()
+	defer stopCompression()
+
+	// Optional debug endpoints and signal-triggered dumps
+	stopDebug, err := a.startDebugFacilities()
+	if err != nil {
 		return err
 	}
+	defer stopDebug()
+
+	// Execute LLM interaction; the snapshot keeps what a failed run produced
+	taskErr := a.executeWithError(a.executeTask, "execute task")
+	if err := a.persistVirtualFiles(); err != nil {
+		if taskErr == nil {
+			return err
+		}
+		if !a.config.Quiet {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	if taskErr != nil {
+		return taskErr
+	}
+
+	if a.config.DryRun {
+		a.showDryRunPlan(os.Stderr)
+	}
 
 	// Show statistics if requested
 	if a.config.ShowStats {
@@ -104,22 +152,145 @@ func (a *App) Run() error {
 	return nil
 }
 
-// initializeOpenAI initializes the OpenAI client
-func (a *App) initializeOpenAI() error {
-	config := openai.ClientConfig{
-		APIKey:     a.fileConfig.OpenAIAPIKey,
-		BaseURL:    a.fileConfig.OpenAIBaseURL,
-		Timeout:    time.Duration(a.fileConfig.TimeoutSeconds) * time.Second,
-		MaxCalls:   a.fileConfig.MaxAPICalls,
-		MaxRetries: a.fileConfig.MaxRetries,
-		RetryDelay: time.Duration(a.fileConfig.RetryDelay) * time.Millisecond,
+// scratchNote tells the LLM about $LLMCMD_TMPDIR, or returns "" with
+// spawn_shell=llmsh, whose scripts only see virtual files
+func scratchNote(spawnShell string) string {
+	if spawnShell == "llmsh" {
+		return ""
+	}
+	return `SCRATCH: $LLMCMD_TMPDIR is a real per-run directory for tools that need real paths (sort -T, patch); open("$LLMCMD_TMPDIR/name") reaches the same files`
+}
+
+// persistVirtualFiles carries out --export and --vfs-save once the task is
+// over. Every export is attempted and all failures are returned. --dry-run
+// skips them; showDryRunPlan lists them instead.
+func (a *App) persistVirtualFiles() error {
+	if a.config.DryRun {
+		return nil
+	}
+	var errs []error
+	for _, export := range a.config.Exports {
+		err := a.virtualFS.ExportFile(export.Name, export.Path)
+		if err == nil && a.config.Verbose {
+			log.Printf("Exported virtual file %s to %s", export.Name, export.Path)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if a.config.VFSSave != "" {
+		err := a.virtualFS.SaveSnapshot(a.config.VFSSave)
+		if err == nil && a.config.Verbose {
+			log.Printf("Saved VFS snapshot %s", a.config.VFSSave)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// showDryRunPlan lists the actions the LLM asked for that --dry-run skipped,
+// then the --export and --vfs-save it skipped after the task
+func (a *App) showDryRunPlan(w io.Writer) {
+	actions := append([]string(nil), a.toolEngine.DryRunActions()...)
+	for _, export := range a.config.Exports {
+		actions = append(actions, fmt.Sprintf("export: write virtual file '%s' to '%s'", export.Name, export.Path))
+	}
+	if a.config.VFSSave != "" {
+		actions = append(actions, fmt.Sprintf("vfs-save: save the virtual files to '%s'", a.config.VFSSave))
+	}
+	fmt.Fprintf(w, "Dry run: %d action(s) not performed\n", len(actions))
+	for i, action := range actions {
+		fmt.Fprintf(w, "  %d. %s\n", i+1, action)
+	}
+}
+
+// startDebugFacilities starts the --debug-addr server and --debug-dump-on handler.
+// The returned function stops whatever was started.
+func (a *App) startDebugFacilities() (func(), error) {
+	var stops []func()
+	stopAll := func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
+
+	if a.config.DebugAddr != "" {
+		addr, stop, err := debugdump.Serve(a.config.DebugAddr, a.toolEngine.WriteDebugState)
+		if err != nil {
+			return nil, err
+		}
+		stops = append(stops, stop)
+		if !a.config.Quiet {
+			log.Printf("Debug endpoints listening on http://%s/debug/", addr)
+		}
+	}
+
+	if a.config.DebugDumpOn != "" {
+		stop, err := debugdump.DumpOnSignal(a.config.DebugDumpOn, os.Stderr, a.toolEngine.WriteDebugState)
+		if err != nil {
+			stopAll()
+			return nil, err
+		}
+		stops = append(stops, stop)
+	}
+
+	return stopAll, nil
+}
+
+// catchBrokenPipe receives SIGPIPE so that writes to a closed stdout return EPIPE.
+// The signal is caught rather than ignored so spawned commands keep the default action.
+func catchBrokenPipe() func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGPIPE)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-signals:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// fallbackProviders builds the secondary providers configured in the profile
+func fallbackProviders(fileConfig *cli.ConfigFile) []openai.Provider {
+	var providers []openai.Provider
+	for _, baseURL := range fileConfig.FallbackBaseURLs {
+		providers = append(providers, openai.Provider{BaseURL: baseURL, APIKey: fileConfig.FallbackAPIKey})
+	}
+	return providers
+}
+
+// newClientConfig builds the OpenAI client configuration of a profile
+func newClientConfig(fileConfig *cli.ConfigFile) openai.ClientConfig {
+	return openai.ClientConfig{
+		APIKey:     fileConfig.OpenAIAPIKey,
+		BaseURL:    fileConfig.OpenAIBaseURL,
+		Timeout:    time.Duration(fileConfig.TimeoutSeconds) * time.Second,
+		MaxCalls:   fileConfig.MaxAPICalls,
+		MaxRetries: fileConfig.MaxRetries,
+		RetryDelay: time.Duration(fileConfig.RetryDelay) * time.Millisecond,
+		Fallbacks:  fallbackProviders(fileConfig),
 		QuotaConfig: &openai.QuotaConfig{
-			MaxTokens:    a.fileConfig.QuotaMaxTokens,
-			InputWeight:  a.fileConfig.GetEffectiveQuotaWeights().InputWeight,
-			CachedWeight: a.fileConfig.GetEffectiveQuotaWeights().InputCachedWeight,
-			OutputWeight: a.fileConfig.GetEffectiveQuotaWeights().OutputWeight,
+			MaxTokens:    fileConfig.QuotaMaxTokens,
+			InputWeight:  fileConfig.GetEffectiveQuotaWeights().InputWeight,
+			CachedWeight: fileConfig.GetEffectiveQuotaWeights().InputCachedWeight,
+			OutputWeight: fileConfig.GetEffectiveQuotaWeights().OutputWeight,
 		},
 	}
+}
+
+// initializeOpenAI initializes the OpenAI client
+func (a *App) initializeOpenAI() error {
+	config := newClientConfig(a.fileConfig)
 
 	// Use shared quota client if available, otherwise regular client
 	if a.sharedQuota != nil {
@@ -143,18 +314,76 @@ func (a *App) initializeOpenAI() error {
 func (a *App) initializeToolEngine() error {
 	shellExecutor := &SimpleShellExecutor{}
 	virtualFS := NewSimpleVirtualFS()
+	virtualFS.SetLimits(VFSLimits{
+		TotalBytes: a.fileConfig.VFSMaxTotalBytes,
+		FileBytes:  a.fileConfig.VFSMaxFileBytes,
+		Files:      a.fileConfig.VFSMaxFiles,
+	})
+	virtualFS.SetSpillThreshold(a.fileConfig.VFSSpillBytes)
+	virtualFS.SetCompression(a.fileConfig.VFSCompressBytes, time.Duration(a.fileConfig.VFSIdleSeconds)*time.Second)
+	if a.config.VFSLoad != "" {
+		if err := virtualFS.LoadSnapshot(a.config.VFSLoad); err != nil {
+			return err
+		}
+		if a.config.Verbose {
+			log.Printf("Loaded VFS snapshot %s (%d files)", a.config.VFSLoad, len(virtualFS.FileNames()))
+		}
+	}
+	for _, mount := range a.config.Mounts {
+		if err := virtualFS.MountReadOnly(mount.Dir, mount.Prefix); err != nil {
+			return err
+		}
+	}
+	if err := a.fetchURLInputs(virtualFS, newURLClient()); err != nil {
+		return err
+	}
+	a.virtualFS = virtualFS
 
 	// Configure shell executor with VFS for redirect support
 	shellExecutor.SetVFS(virtualFS)
 
+	// spawn_shell=llmsh runs scripts in-process; resource limits need a real shell
+	var executor tools.ShellExecutor = shellExecutor
+	limits := tools.SpawnLimits{
+		CPUSeconds:  a.fileConfig.SpawnCPUSeconds,
+		MemoryBytes: int64(a.fileConfig.SpawnMemoryMB) << 20,
+		OpenFiles:   a.fileConfig.SpawnMaxOpenFiles,
+	}
+	if a.fileConfig.SpawnShell == "llmsh" {
+		internalExecutor, ok := NewInternalShellExecutor()
+		if !ok {
+			return fmt.Errorf("spawn_shell=llmsh: llmsh is not available in this build")
+		}
+		internalExecutor.SetVFS(virtualFS)
+		executor = internalExecutor
+		if limits != (tools.SpawnLimits{}) && !a.config.Quiet {
+			log.Printf("Warning: spawn_cpu_seconds, spawn_memory_mb and spawn_max_open_files are ignored with spawn_shell=llmsh")
+		}
+		limits = tools.SpawnLimits{}
+	}
+
+	var hooks []tools.ToolHook
+	if a.config.Verbose {
+		hooks = append(hooks, verboseHook{})
+	}
+	if a.config.Trace {
+		hooks = append(hooks, &traceHook{w: os.Stderr})
+	}
+
 	config := tools.EngineConfig{
-		InputFiles:    a.config.InputFiles,
-		OutputFile:    a.config.OutputFile,
-		MaxFileSize:   a.fileConfig.MaxFileSize,
-		BufferSize:    a.fileConfig.ReadBufferSize,
-		NoStdin:       a.config.NoStdin,
-		ShellExecutor: shellExecutor,
-		VirtualFS:     virtualFS,
+		Hooks:                 hooks,
+		DryRun:                a.config.DryRun,
+		Quiet:                 a.config.Quiet,
+		InputFiles:            a.config.InputFiles,
+		OutputFile:            a.config.OutputFile,
+		MaxFileSize:           a.fileConfig.MaxFileSize,
+		BufferSize:            a.fileConfig.ReadBufferSize,
+		NoStdin:               a.config.NoStdin,
+		SpawnTimeout:          time.Duration(a.fileConfig.SpawnTimeoutSeconds) * time.Second,
+		ResultHandleThreshold: a.fileConfig.ResultHandleThreshold,
+		SpawnLimits:           limits,
+		ShellExecutor:         executor,
+		VirtualFS:             virtualFS,
 	}
 
 	var err error
@@ -198,6 +427,18 @@ func (a *App) executeTask() error {
 		quotaStatus,
 		false, // Initial call is never the last call
 	)
+	notes := []string{
+		scratchNote(a.fileConfig.SpawnShell),
+		mountNote(a.config.Mounts, a.fileConfig.SpawnShell),
+		urlNote(a.config.URLInputs, a.fileConfig.SpawnShell),
+	}
+	for _, note := range notes {
+		if note != "" && !a.fileConfig.DisableTools && len(messages) > 0 {
+			// Announce the scratch directory, mounts and URL inputs before the request itself
+			last := len(messages) - 1
+			messages = append(messages[:last], openai.ChatMessage{Role: "user", Content: note}, messages[last])
+		}
+	}
 
 	if a.config.Verbose {
 		log.Printf("Starting LLM interaction with %d initial messages", len(messages))
@@ -266,6 +507,11 @@ func (a *App) executeTask() error {
 
 		// Process response
 		choice := response.Choices[0]
+
+		// Report refusals distinctly from tool failures and empty results
+		if refusal := openai.DetectRefusal(choice, !a.fileConfig.DisableTools); refusal != nil {
+			return refusal
+		}
 		messages = append(messages, choice.Message)
 
 		// Update quota usage in config file
@@ -323,7 +569,13 @@ func (a *App) executeTask() error {
 					output = os.Stdout
 				}
 
-				if _, err := output.Write([]byte(choice.Message.Content)); err != nil {
+				a.pendingOutput.WriteString(choice.Message.Content)
+				if _, err := output.Write([]byte(a.pendingOutput.String())); err != nil {
+					if output == os.Stdout && errors.Is(err, syscall.EPIPE) {
+						a.exitCode = brokenPipeExitCode
+						a.exitRequested = true
+						return nil
+					}
 					return fmt.Errorf("failed to write output: %w", err)
 				}
 			} else if !a.fileConfig.DisableTools && choice.Message.Content != "" {
@@ -347,6 +599,13 @@ func (a *App) executeTask() error {
 			}
 
 			if err := a.executeToolCalls(choice.Message.ToolCalls, &messages); err != nil {
+				if errors.Is(err, tools.ErrOutputClosed) {
+					// Returning cancels the context and closes spawned commands
+					if a.config.Verbose {
+						log.Printf("Output closed by reader, stopping")
+					}
+					return nil
+				}
 				// Check if this is an exit request
 				if strings.HasPrefix(err.Error(), "EXIT_REQUESTED:") {
 					// Exit was requested, return without error
@@ -356,7 +615,10 @@ func (a *App) executeTask() error {
 			}
 
 		case "length":
-			return fmt.Errorf("response truncated due to length limit")
+			if a.continuationCount >= a.fileConfig.MaxContinuations {
+				return fmt.Errorf("response truncated due to length limit (after %d continuations)", a.continuationCount)
+			}
+			a.continueTruncated(choice.Message, &messages)
 
 		default:
 			return fmt.Errorf("unexpected finish reason: %s", choice.FinishReason)
@@ -364,6 +626,28 @@ func (a *App) executeTask() error {
 	}
 }
 
+// continueTruncated keeps the text of a completion cut off by the length limit
+// and asks the model to carry on where it stopped
+func (a *App) continueTruncated(message openai.ChatMessage, messages *[]openai.ChatMessage) {
+	a.continuationCount++
+	a.pendingOutput.WriteString(message.Content)
+	if a.config.Verbose {
+		log.Printf("Response truncated due to length, requesting continuation %d/%d",
+			a.continuationCount, a.fileConfig.MaxContinuations)
+	}
+
+	// Truncated tool call arguments are unusable, so only the text stays in the history
+	last := &(*messages)[len(*messages)-1]
+	last.ToolCalls = nil
+
+	*messages = append(*messages, openai.ChatMessage{
+		Role: "user",
+		Content: "Your previous response was cut off by the length limit. " +
+			"Continue exactly where it stopped without repeating anything. " +
+			"If you were calling a tool, call it again with complete arguments.",
+	})
+}
+
 // executeToolCalls executes tool calls and updates messages
 func (a *App) executeToolCalls(toolCalls []openai.ToolCall, messages *[]openai.ChatMessage) error {
 	if a.config.Verbose {
@@ -371,11 +655,6 @@ func (a *App) executeToolCalls(toolCalls []openai.ToolCall, messages *[]openai.C
 	}
 
 	for _, toolCall := range toolCalls {
-		if a.config.Verbose {
-			log.Printf("Executing tool: %s (ID: %s) with args: %s",
-				toolCall.Function.Name, toolCall.ID, toolCall.Function.Arguments)
-		}
-
 		// Convert to format expected by tool engine
 		toolCallMap := map[string]interface{}{
 			"name":      toolCall.Function.Name,
@@ -384,6 +663,12 @@ func (a *App) executeToolCalls(toolCalls []openai.ToolCall, messages *[]openai.C
 
 		// Execute the tool call
 		result, err := a.toolEngine.ExecuteToolCall(toolCallMap)
+		if errors.Is(err, tools.ErrOutputClosed) {
+			// Nobody reads our output any more: stop instead of spending tokens
+			a.exitCode = brokenPipeExitCode
+			a.exitRequested = true
+			return err
+		}
 		if err != nil {
 			// Check if this is an exit request
 			if strings.HasPrefix(err.Error(), "EXIT_REQUESTED:") {
@@ -399,16 +684,12 @@ func (a *App) executeToolCalls(toolCalls []openai.ToolCall, messages *[]openai.C
 					return fmt.Errorf("EXIT_REQUESTED:%d", exitCode)
 				}
 			}
-			result = fmt.Sprintf("Error: %v", err)
+			result = tools.ErrorResult(err)
 		}
 
 		// Add tool response to messages
 		toolMessage := openai.CreateToolResponseMessage(toolCall.ID, result)
 		*messages = append(*messages, toolMessage)
-
-		if a.config.Verbose {
-			log.Printf("Tool result: %s", result)
-		}
 	}
 
 	return nil
@@ -485,6 +766,14 @@ func (a *App) validateConfig() error {
 		return err
 	}
 
+	if err := validateRange(a.fileConfig.SpawnTimeoutSeconds, 0, 3600, "spawn_timeout_seconds"); err != nil {
+		return err
+	}
+
+	if err := validateRange(a.fileConfig.MaxContinuations, 0, 20, "max_continuations"); err != nil {
+		return err
+	}
+
 	if err := validateInt64Range(a.fileConfig.MaxFileSize, 1, 100*1024*1024, "max_file_size"); err != nil {
 		return err
 	}
@@ -496,93 +785,111 @@ func (a *App) validateConfig() error {
 	return nil
 }
 
-// showStatistics displays detailed execution statistics
+// showStatistics displays detailed execution statistics in the requested format
 func (a *App) showStatistics() {
+	var err error
+	switch a.config.StatsFormat {
+	case "json":
+		err = writeStatsJSON(os.Stderr, a.collectStatistics())
+	case "csv":
+		err = writeStatsCSV(os.Stderr, a.collectStatistics())
+	default:
+		a.writeStatsTable(os.Stderr, currentLocale())
+	}
+	if err != nil {
+		log.Printf("Warning: failed to write statistics: %v", err)
+	}
+}
+
+// writeStatsTable writes the human-readable statistics block
+func (a *App) writeStatsTable(w io.Writer, loc displayLocale) {
 	duration := time.Since(a.startTime)
 	openaiStats := a.openaiClient.GetStats()
 	toolStats := a.toolEngine.GetStats()
+	num := func(n int) string { return loc.formatInt(int64(n)) }
 
-	fmt.Fprintf(os.Stderr, "\n")
-	fmt.Fprintf(os.Stderr, "=== LLMCMD EXECUTION STATISTICS ===\n")
-	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(w, "\n")
+	fmt.Fprintf(w, "=== LLMCMD EXECUTION STATISTICS ===\n")
+	fmt.Fprintf(w, "\n")
 
 	// Timing Information
-	fmt.Fprintf(os.Stderr, "⏱️  TIMING:\n")
-	fmt.Fprintf(os.Stderr, "   Total Duration:     %v\n", duration.Round(time.Millisecond))
-	fmt.Fprintf(os.Stderr, "   Average per API:    %v\n", (openaiStats.TotalDuration / time.Duration(max(openaiStats.RequestCount, 1))).Round(time.Millisecond))
-	fmt.Fprintf(os.Stderr, "   LLM Iterations:     %d\n", a.iterationCount)
-	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(w, "⏱️  TIMING:\n")
+	fmt.Fprintf(w, "   Started At:         %s\n", a.startTime.Format(loc.dateLayout))
+	fmt.Fprintf(w, "   Total Duration:     %v\n", duration.Round(time.Millisecond))
+	fmt.Fprintf(w, "   Average per API:    %v\n", (openaiStats.TotalDuration / time.Duration(max(openaiStats.RequestCount, 1))).Round(time.Millisecond))
+	fmt.Fprintf(w, "   LLM Iterations:     %s\n", num(a.iterationCount))
+	fmt.Fprintf(w, "\n")
 
 	// OpenAI API Statistics
-	fmt.Fprintf(os.Stderr, "🤖 OPENAI API USAGE:\n")
-	fmt.Fprintf(os.Stderr, "   API Calls:          %d / %d (%.1f%%)\n",
-		openaiStats.RequestCount, a.fileConfig.MaxAPICalls,
-		float64(openaiStats.RequestCount)/float64(a.fileConfig.MaxAPICalls)*100)
-	fmt.Fprintf(os.Stderr, "   Total Retries:      %d\n", openaiStats.RetryCount)
-	fmt.Fprintf(os.Stderr, "   Total Tokens:       %d\n", openaiStats.TotalTokens)
-	fmt.Fprintf(os.Stderr, "   Prompt Tokens:      %d\n", openaiStats.PromptTokens)
-	fmt.Fprintf(os.Stderr, "   Completion Tokens:  %d\n", openaiStats.CompletionTokens)
-	fmt.Fprintf(os.Stderr, "   Error Count:        %d\n", openaiStats.ErrorCount)
+	fmt.Fprintf(w, "🤖 OPENAI API USAGE:\n")
+	fmt.Fprintf(w, "   API Calls:          %s / %s (%s%%)\n",
+		num(openaiStats.RequestCount), num(a.fileConfig.MaxAPICalls),
+		loc.formatFloat(float64(openaiStats.RequestCount)/float64(a.fileConfig.MaxAPICalls)*100, 1))
+	fmt.Fprintf(w, "   Total Retries:      %s\n", num(openaiStats.RetryCount))
+	if openaiStats.FailoverCount > 0 {
+		fmt.Fprintf(w, "   Provider Failovers: %s\n", num(openaiStats.FailoverCount))
+	}
+	fmt.Fprintf(w, "   Total Tokens:       %s\n", num(openaiStats.TotalTokens))
+	fmt.Fprintf(w, "   Prompt Tokens:      %s\n", num(openaiStats.PromptTokens))
+	fmt.Fprintf(w, "   Completion Tokens:  %s\n", num(openaiStats.CompletionTokens))
+	fmt.Fprintf(w, "   Error Count:        %s\n", num(openaiStats.ErrorCount))
 	if openaiStats.RequestCount > 0 {
-		fmt.Fprintf(os.Stderr, "   Avg Tokens/Call:    %.1f\n", float64(openaiStats.TotalTokens)/float64(openaiStats.RequestCount))
+		fmt.Fprintf(w, "   Avg Tokens/Call:    %s\n", loc.formatFloat(float64(openaiStats.TotalTokens)/float64(openaiStats.RequestCount), 1))
 	}
-	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(w, "\n")
 
 	// Tool Usage Statistics
-	fmt.Fprintf(os.Stderr, "🔧 TOOL USAGE:\n")
-	fmt.Fprintf(os.Stderr, "   Read Calls:         %d\n", toolStats.ReadCalls)
-	fmt.Fprintf(os.Stderr, "   Write Calls:        %d\n", toolStats.WriteCalls)
-	fmt.Fprintf(os.Stderr, "   Spawn Calls:        %d\n", toolStats.SpawnCalls)
-	fmt.Fprintf(os.Stderr, "   Exit Calls:         %d\n", toolStats.ExitCalls)
-	fmt.Fprintf(os.Stderr, "   Total Tool Calls:   %d\n", toolStats.ReadCalls+toolStats.WriteCalls+toolStats.SpawnCalls+toolStats.ExitCalls)
-	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(w, "🔧 TOOL USAGE:\n")
+	fmt.Fprintf(w, "   Read Calls:         %s\n", num(toolStats.ReadCalls))
+	fmt.Fprintf(w, "   Write Calls:        %s\n", num(toolStats.WriteCalls))
+	fmt.Fprintf(w, "   Spawn Calls:        %s\n", num(toolStats.SpawnCalls))
+	fmt.Fprintf(w, "   Exit Calls:         %s\n", num(toolStats.ExitCalls))
+	fmt.Fprintf(w, "   Total Tool Calls:   %s\n", num(toolStats.ReadCalls+toolStats.WriteCalls+toolStats.SpawnCalls+toolStats.ExitCalls))
+	fmt.Fprintf(w, "\n")
+
+	// Where the tool time went, slowest tool first
+	if len(toolStats.Tools) > 0 {
+		fmt.Fprintf(w, "⌛ TOOL LATENCY:\n")
+		for _, name := range toolsByLatency(toolStats.Tools) {
+			latency := toolStats.Tools[name]
+			fmt.Fprintf(w, "   %-8s %5s calls, total %v, avg %v, max %v\n", name+":", num(latency.Calls),
+				latency.TotalLatency.Round(time.Millisecond), latency.AverageLatency().Round(time.Millisecond), latency.MaxLatency.Round(time.Millisecond))
+		}
+		fmt.Fprintf(w, "\n")
+	}
 
 	// Data Transfer Statistics
-	fmt.Fprintf(os.Stderr, "📊 DATA TRANSFER:\n")
-	fmt.Fprintf(os.Stderr, "   Bytes Read:         %s\n", formatBytes(toolStats.BytesRead))
-	fmt.Fprintf(os.Stderr, "   Bytes Written:      %s\n", formatBytes(toolStats.BytesWritten))
-	fmt.Fprintf(os.Stderr, "   Error Count:        %d\n", toolStats.ErrorCount)
-	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(w, "📊 DATA TRANSFER:\n")
+	fmt.Fprintf(w, "   Bytes Read:         %s\n", loc.formatBytes(toolStats.BytesRead))
+	fmt.Fprintf(w, "   Bytes Written:      %s\n", loc.formatBytes(toolStats.BytesWritten))
+	fmt.Fprintf(w, "   Error Count:        %s\n", num(toolStats.ErrorCount))
+	fmt.Fprintf(w, "\n")
 
 	// Efficiency Metrics
 	if a.iterationCount > 0 && openaiStats.RequestCount > 0 {
-		fmt.Fprintf(os.Stderr, "⚡ EFFICIENCY METRICS:\n")
-		fmt.Fprintf(os.Stderr, "   API Calls/Iteration: %.2f\n", float64(openaiStats.RequestCount)/float64(a.iterationCount))
-		fmt.Fprintf(os.Stderr, "   Tools/API Call:      %.2f\n", float64(toolStats.ReadCalls+toolStats.WriteCalls+toolStats.SpawnCalls+toolStats.ExitCalls)/float64(openaiStats.RequestCount))
+		fmt.Fprintf(w, "⚡ EFFICIENCY METRICS:\n")
+		fmt.Fprintf(w, "   API Calls/Iteration: %s\n", loc.formatFloat(float64(openaiStats.RequestCount)/float64(a.iterationCount), 2))
+		fmt.Fprintf(w, "   Tools/API Call:      %s\n", loc.formatFloat(float64(toolStats.ReadCalls+toolStats.WriteCalls+toolStats.SpawnCalls+toolStats.ExitCalls)/float64(openaiStats.RequestCount), 2))
 
 		tokensPerSecond := float64(openaiStats.TotalTokens) / duration.Seconds()
-		fmt.Fprintf(os.Stderr, "   Tokens/Second:       %.1f\n", tokensPerSecond)
+		fmt.Fprintf(w, "   Tokens/Second:       %s\n", loc.formatFloat(tokensPerSecond, 1))
 
 		if toolStats.BytesRead > 0 {
-			fmt.Fprintf(os.Stderr, "   Processing Rate:     %s/sec\n", formatBytes(int64(float64(toolStats.BytesRead)/duration.Seconds())))
+			fmt.Fprintf(w, "   Processing Rate:     %s/sec\n", loc.formatBytes(int64(float64(toolStats.BytesRead)/duration.Seconds())))
 		}
-		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(w, "\n")
 	}
 
 	// Model Information
-	fmt.Fprintf(os.Stderr, "🎯 CONFIGURATION:\n")
-	fmt.Fprintf(os.Stderr, "   Model:              %s\n", a.fileConfig.Model)
-	fmt.Fprintf(os.Stderr, "   Max Tokens:         %d\n", a.fileConfig.MaxTokens)
-	fmt.Fprintf(os.Stderr, "   Temperature:        %.1f\n", a.fileConfig.Temperature)
-	fmt.Fprintf(os.Stderr, "   Input Files:        %d\n", len(a.config.InputFiles))
-	fmt.Fprintf(os.Stderr, "   Buffer Size:        %s\n", formatBytes(int64(a.fileConfig.ReadBufferSize)))
-	fmt.Fprintf(os.Stderr, "\n")
-
-	fmt.Fprintf(os.Stderr, "=== END STATISTICS ===\n")
-}
-
-// formatBytes formats byte counts in human-readable format
-func formatBytes(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+	fmt.Fprintf(w, "🎯 CONFIGURATION:\n")
+	fmt.Fprintf(w, "   Model:              %s\n", a.fileConfig.Model)
+	fmt.Fprintf(w, "   Max Tokens:         %s\n", num(a.fileConfig.MaxTokens))
+	fmt.Fprintf(w, "   Temperature:        %s\n", loc.formatFloat(a.fileConfig.Temperature, 1))
+	fmt.Fprintf(w, "   Input Files:        %s\n", num(len(a.config.InputFiles)))
+	fmt.Fprintf(w, "   Buffer Size:        %s\n", loc.formatBytes(int64(a.fileConfig.ReadBufferSize)))
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "=== END STATISTICS ===\n")
 }
 
 // max returns the maximum of two integers
@@ -615,78 +922,144 @@ func (s *SimpleShellExecutor) Execute(command string) error {
 
 // ExecuteWithIO executes a shell command with specified IO
 func (s *SimpleShellExecutor) ExecuteWithIO(command string, stdin io.Reader, stdout, stderr io.Writer) error {
-	cmd := exec.Command("sh", "-c", command)
+	return s.ExecuteContext(context.Background(), command, nil, stdin, stdout, stderr)
+}
+
+// ExecuteWithEnv executes a shell command with specified IO and environment
+func (s *SimpleShellExecutor) ExecuteWithEnv(command string, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return s.ExecuteContext(context.Background(), command, env, stdin, stdout, stderr)
+}
+
+// ExecuteContext executes a shell command, killing it when ctx is done
+func (s *SimpleShellExecutor) ExecuteContext(ctx context.Context, command string, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return s.ExecuteWithVFS(ctx, command, env, stdin, stdout, stderr, nil)
+}
+
+// ExecuteWithVFS executes a shell command with vfsConn inherited as fd 3, so
+// llmsh redirections inside the command resolve against the parent VFS
+func (s *SimpleShellExecutor) ExecuteWithVFS(ctx context.Context, command string, env []string, stdin io.Reader, stdout, stderr io.Writer, vfsConn *os.File) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = env
+	if vfsConn != nil {
+		if env == nil {
+			env = os.Environ()
+		}
+		cmd.Env = append(env, vfsproxy.EnvVar+"=3")
+		cmd.ExtraFiles = []*os.File{vfsConn}
+	}
 	cmd.Stdin = stdin
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
+	// Don't let background children holding the pipes keep a killed command alive
+	cmd.WaitDelay = time.Second
 	return cmd.Run()
 }
 
 // SimpleVirtualFS implements tools.VirtualFileSystem interface
 type SimpleVirtualFS struct {
-	files    map[string]*VirtualFile
-	consumed map[string]bool // Track files that have been fully read (PIPE behavior)
-	mutex    sync.RWMutex
+	files         map[string]*VirtualFile
+	consumed      map[string]bool // Track files that have been fully read (PIPE behavior)
+	limits        VFSLimits
+	used          int64         // Bytes held by all files, checked against limits.TotalBytes
+	spillBytes    int64         // Size past which new files move their data to disk, 0 for never
+	compressBytes int64         // Size from which idle files are compressed, 0 for never
+	compressIdle  time.Duration // Time without use after which a file is idle
+	mounts        []vfsMount    // Read-only real directories, deepest prefix first
+	locks         vfsproxy.LockTable
+	fifos         map[string]*namedPipe // Named pipes made with MakeFifo
+	mutex         sync.RWMutex
+}
+
+// VFSLimits bounds the memory the virtual files of a session can use, so a
+// looping LLM cannot exhaust the host by appending to a file forever. Zero
+// fields are not limited.
+type VFSLimits struct {
+	TotalBytes int64 // Bytes held by all files together
+	FileBytes  int64 // Bytes held by one file
+	Files      int   // Number of files
 }
 
-// VirtualFile represents a virtual file in memory
+// VirtualFile represents a virtual file, held in memory until it grows past
+// spillAt bytes and in a temporary file after that
 type VirtualFile struct {
-	name   string
-	data   []byte
-	offset int64
-	flag   int
-	perm   os.FileMode
-	closed bool
+	name     string
+	content  *fileContent // Shared with clones until one of them writes
+	spillAt  int64
+	offset   int64
+	flag     int
+	perm     os.FileMode
+	created  time.Time
+	modified time.Time // When it was created, last written or truncated
+	regular  bool      // Opened with r+ or w+: reads do not consume it, and each open has its own offset
+	readOnly bool      // Added with AddReadOnlyFile: it cannot be written, replaced or removed
 }
 
 // VirtualFileWrapper wraps VirtualFile to handle consumption tracking
 type VirtualFileWrapper struct {
-	file *VirtualFile
-	vfs  *SimpleVirtualFS
-	name string
+	file   *VirtualFile
+	vfs    *SimpleVirtualFS
+	name   string
+	closed bool // This open is closed; others of the same file are not
 }
 
 // Read implements io.Reader with consumption tracking
 func (w *VirtualFileWrapper) Read(p []byte) (n int, err error) {
+	w.vfs.mutex.Lock()
+	defer w.vfs.mutex.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
 	n, err = w.file.Read(p)
 
 	// Check if file has been fully consumed
-	if w.file.data == nil || w.file.offset >= int64(len(w.file.data)) {
+	if w.file.offset >= w.file.length() {
 		// Mark as consumed in VFS
-		w.vfs.mutex.Lock()
 		w.vfs.consumed[w.name] = true
-		w.vfs.mutex.Unlock()
 	}
 
 	return n, err
 }
 
-// Write implements io.Writer
+// Write implements io.Writer, refusing writes that would exceed the VFS limits
 func (w *VirtualFileWrapper) Write(p []byte) (n int, err error) {
+	w.vfs.mutex.Lock()
+	defer w.vfs.mutex.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	end := w.file.offset + int64(len(p))
+	if w.file.flag&os.O_APPEND != 0 {
+		end = w.file.length() + int64(len(p))
+	}
+	if err := w.vfs.checkGrowthLocked(w.file, end); err != nil {
+		return 0, err
+	}
 	return w.file.Write(p)
 }
 
 // Close implements io.Closer
 func (w *VirtualFileWrapper) Close() error {
-	return w.file.Close()
+	w.vfs.mutex.Lock()
+	defer w.vfs.mutex.Unlock()
+	w.closed = true
+	return nil
 }
 
 // Read implements io.Reader with PIPE-like behavior (consume data)
 func (f *VirtualFile) Read(p []byte) (n int, err error) {
-	if f.closed {
-		return 0, os.ErrClosed
-	}
-	if f.offset >= int64(len(f.data)) {
-		return 0, io.EOF
+	n, err = f.ReadAt(p, f.offset)
+	if err != nil && (err != io.EOF || n == 0) {
+		return n, err
 	}
-	n = copy(p, f.data[f.offset:])
 	f.offset += int64(n)
 
 	// PIPE behavior: once data is read, it's consumed and removed
 	// This simulates pipe consumption where data can only be read once
-	if f.offset >= int64(len(f.data)) {
+	if f.offset >= f.length() {
 		// All data has been read, mark as consumed
-		f.data = nil // Clear data to prevent re-reading
+		f.release() // Clear data to prevent re-reading
 	}
 
 	return n, nil
@@ -694,29 +1067,24 @@ func (f *VirtualFile) Read(p []byte) (n int, err error) {
 
 // Write implements io.Writer
 func (f *VirtualFile) Write(p []byte) (n int, err error) {
-	if f.closed {
-		return 0, os.ErrClosed
-	}
 	if f.flag&os.O_APPEND != 0 {
-		f.data = append(f.data, p...)
+		if err := f.writeAt(p, f.length()); err != nil {
+			return 0, err
+		}
 	} else {
-		// Extend data if necessary
-		needed := f.offset + int64(len(p))
-		if int64(len(f.data)) < needed {
-			newData := make([]byte, needed)
-			copy(newData, f.data)
-			f.data = newData
-		}
-		copy(f.data[f.offset:], p)
+		if err := f.writeAt(p, f.offset); err != nil {
+			return 0, err
+		}
 		f.offset += int64(len(p))
 	}
 	return len(p), nil
 }
 
-// Close implements io.Closer
-func (f *VirtualFile) Close() error {
-	f.closed = true
-	return nil
+// virtualName resolves . and .. in a virtual file name, as llmsh does, so
+// open("./out/a.txt") and a script writing out/a.txt name the same file. A
+// leading slash is the root of the VFS.
+func virtualName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
 }
 
 // NewSimpleVirtualFS creates a new virtual file system
@@ -727,40 +1095,100 @@ func NewSimpleVirtualFS() *SimpleVirtualFS {
 	}
 }
 
-// OpenFile opens or creates a virtual file with PIPE-like behavior
+// SetLimits sets the size and file-count limits checked by later writes
+// and file creations
+func (vfs *SimpleVirtualFS) SetLimits(limits VFSLimits) {
+	vfs.mutex.Lock()
+	defer vfs.mutex.Unlock()
+	vfs.limits = limits
+}
+
+// checkGrowthLocked reports whether a write may make file end bytes long.
+// Writing to a clone copies the content it shares, which counts as growth.
+func (vfs *SimpleVirtualFS) checkGrowthLocked(file *VirtualFile, end int64) error {
+	size := file.length()
+	growth := end - size
+	if file.shared() {
+		growth = end
+		if size > end {
+			growth = size
+		}
+	}
+	if growth <= 0 {
+		return nil
+	}
+	if vfs.limits.FileBytes > 0 && end > size && end > vfs.limits.FileBytes {
+		return fmt.Errorf("virtual file '%s': %w (vfs_max_file_bytes is %d)", file.name, syscall.EFBIG, vfs.limits.FileBytes)
+	}
+	if vfs.limits.TotalBytes > 0 && vfs.used+growth > vfs.limits.TotalBytes {
+		return fmt.Errorf("virtual file '%s': %w (vfs_max_total_bytes is %d)", file.name, syscall.ENOSPC, vfs.limits.TotalBytes)
+	}
+	return nil
+}
+
+// checkNewFileLocked reports whether one more file fits in the file-count limit
+func (vfs *SimpleVirtualFS) checkNewFileLocked(name string) error {
+	if vfs.limits.Files > 0 && len(vfs.files)+len(vfs.fifos) >= vfs.limits.Files {
+		return fmt.Errorf("virtual file '%s': %w (vfs_max_files is %d)", name, syscall.ENOSPC, vfs.limits.Files)
+	}
+	return nil
+}
+
+// OpenFile opens or creates a virtual file with PIPE-like behavior. Opening
+// it read/write without O_APPEND (modes r+ and w+) makes it a regular file
+// instead: its data stays after reads, and each open has its own offset.
 func (vfs *SimpleVirtualFS) OpenFile(name string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
 	vfs.mutex.Lock()
 	defer vfs.mutex.Unlock()
+	name = virtualName(name)
 
-	// Check if file was already consumed (PIPE behavior)
-	if vfs.consumed[name] && (flag&os.O_RDONLY != 0 || flag&os.O_RDWR != 0) {
+	if realPath, mounted, err := vfs.mountedPathLocked(name); mounted {
+		if err != nil {
+			return nil, err
+		}
+		return vfs.openMountedLocked(name, realPath, flag)
+	}
+	if pipe, exists := vfs.fifos[name]; exists {
+		return openFifoLocked(name, pipe, flag)
+	}
+
+	// Check if file was already consumed (PIPE behavior); truncating starts it afresh
+	if vfs.consumed[name] && (flag&os.O_RDONLY != 0 || flag&os.O_RDWR != 0) && flag&os.O_TRUNC == 0 {
 		return nil, fmt.Errorf("virtual file '%s' already consumed (PIPE behavior - cannot read twice)", name)
 	}
 
 	file, exists := vfs.files[name]
+	if exists && file.readOnly && flag&(os.O_WRONLY|os.O_RDWR|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, fmt.Errorf("virtual file '%s': %w", name, syscall.EROFS)
+	}
 	if !exists {
 		if flag&os.O_CREATE == 0 {
 			return nil, os.ErrNotExist
 		}
-		// Create new file
-		file = &VirtualFile{
-			name: name,
-			data: []byte{},
-			flag: flag,
-			perm: perm,
+		if err := vfs.checkNewFileLocked(name); err != nil {
+			return nil, err
 		}
+		// Create new file
+		file = vfs.newVirtualFile(name, flag, perm)
 		vfs.files[name] = file
 		// Clear consumed flag when creating new file
 		delete(vfs.consumed, name)
 	}
 
 	if flag&os.O_TRUNC != 0 {
-		file.data = []byte{}
+		file.truncate()
 		file.offset = 0
 		// Clear consumed flag when truncating
 		delete(vfs.consumed, name)
 	}
 
+	if flag&(os.O_RDWR|os.O_APPEND) == os.O_RDWR {
+		file.regular = true
+	}
+	if file.regular {
+		return &regularFile{file: file, vfs: vfs, name: name, append: flag&os.O_APPEND != 0}, nil
+	}
+
 	// Create a wrapper that will mark file as consumed when fully read
 	wrapper := &VirtualFileWrapper{
 		file: file,
@@ -777,12 +1205,10 @@ func (vfs *SimpleVirtualFS) CreateTemp(pattern string) (io.ReadWriteCloser, stri
 	defer vfs.mutex.Unlock()
 
 	name := fmt.Sprintf("temp_%s_%d", pattern, len(vfs.files))
-	file := &VirtualFile{
-		name: name,
-		data: []byte{},
-		flag: os.O_RDWR | os.O_CREATE,
-		perm: 0644,
+	if err := vfs.checkNewFileLocked(name); err != nil {
+		return nil, "", err
 	}
+	file := vfs.newVirtualFile(name, os.O_RDWR|os.O_CREATE, 0644)
 	vfs.files[name] = file
 	// Clear consumed flag for new temp file
 	delete(vfs.consumed, name)
@@ -800,14 +1226,126 @@ func (vfs *SimpleVirtualFS) CreateTemp(pattern string) (io.ReadWriteCloser, stri
 func (vfs *SimpleVirtualFS) RemoveFile(name string) error {
 	vfs.mutex.Lock()
 	defer vfs.mutex.Unlock()
+	name = virtualName(name)
 
-	if _, exists := vfs.files[name]; !exists {
+	if vfs.mountOfLocked(name) != nil {
+		return fmt.Errorf("virtual file '%s': %w", name, syscall.EROFS)
+	}
+	if _, exists := vfs.fifos[name]; exists {
+		// Ends still open keep working
+		delete(vfs.fifos, name)
+		return nil
+	}
+	file, exists := vfs.files[name]
+	if !exists {
 		return os.ErrNotExist
 	}
+	if file.readOnly {
+		return fmt.Errorf("virtual file '%s': %w", name, syscall.EROFS)
+	}
+	file.release()
 	delete(vfs.files, name)
 	return nil
 }
 
+// Truncate cuts a virtual file to size bytes or extends it with zeros, for
+// TRUNCATE in spawned llmsh scripts. Open offsets are left where they are.
+func (vfs *SimpleVirtualFS) Truncate(name string, size int64) error {
+	vfs.mutex.Lock()
+	defer vfs.mutex.Unlock()
+	name = virtualName(name)
+
+	if vfs.mountOfLocked(name) != nil {
+		return fmt.Errorf("virtual file '%s': %w", name, syscall.EROFS)
+	}
+	if _, exists := vfs.fifos[name]; exists {
+		return fmt.Errorf("virtual file '%s': %w (a named pipe)", name, syscall.EINVAL)
+	}
+	if vfs.consumed[name] {
+		return fmt.Errorf("virtual file '%s' already consumed (PIPE behavior - cannot read twice)", name)
+	}
+	file, exists := vfs.files[name]
+	if !exists {
+		return fmt.Errorf("virtual file '%s': %w", name, os.ErrNotExist)
+	}
+	if file.readOnly {
+		return fmt.Errorf("virtual file '%s': %w", name, syscall.EROFS)
+	}
+	if size < 0 {
+		return fmt.Errorf("virtual file '%s': %w (negative size)", name, syscall.EINVAL)
+	}
+	if err := vfs.checkGrowthLocked(file, size); err != nil {
+		return err
+	}
+	return file.truncateTo(size)
+}
+
+// TryLock takes or converts owner's advisory lock on a virtual name without
+// waiting, so spawned scripts can take turns writing a shared file. The name
+// need not exist, and the lock does not stop anyone reading or writing it.
+func (vfs *SimpleVirtualFS) TryLock(name, owner string, exclusive bool) bool {
+	return vfs.locks.TryLock(virtualName(name), owner, exclusive)
+}
+
+// Unlock releases owner's advisory lock on a virtual name
+func (vfs *SimpleVirtualFS) Unlock(name, owner string) {
+	vfs.locks.Unlock(virtualName(name), owner)
+}
+
+// Clone makes dst a copy of src, replacing any file named dst. The copy
+// shares src's content until either of them is written, so a backup taken
+// before patching a large file costs no memory.
+func (vfs *SimpleVirtualFS) Clone(src, dst string) error {
+	vfs.mutex.Lock()
+	defer vfs.mutex.Unlock()
+	src, dst = virtualName(src), virtualName(dst)
+
+	if vfs.mountOfLocked(dst) != nil {
+		return fmt.Errorf("virtual file '%s': %w", dst, syscall.EROFS)
+	}
+	if vfs.mountOfLocked(src) != nil {
+		return fmt.Errorf("virtual file '%s': mounted files cannot be cloned; read them in place", src)
+	}
+	if vfs.consumed[src] {
+		return fmt.Errorf("virtual file '%s' already consumed (PIPE behavior - cannot read twice)", src)
+	}
+	if _, exists := vfs.fifos[src]; exists {
+		return fmt.Errorf("virtual file '%s': named pipes cannot be cloned", src)
+	}
+	file, exists := vfs.files[src]
+	if !exists {
+		return fmt.Errorf("virtual file '%s': %w", src, os.ErrNotExist)
+	}
+	if src == dst {
+		return fmt.Errorf("virtual file '%s': cannot clone a file onto itself", src)
+	}
+	if old, exists := vfs.files[dst]; exists {
+		if old.readOnly {
+			return fmt.Errorf("virtual file '%s': %w", dst, syscall.EROFS)
+		}
+		old.release()
+	} else if _, exists := vfs.fifos[dst]; exists {
+		delete(vfs.fifos, dst)
+	} else if err := vfs.checkNewFileLocked(dst); err != nil {
+		return err
+	}
+
+	file.content.refs++
+	now := time.Now()
+	vfs.files[dst] = &VirtualFile{
+		name:     dst,
+		content:  file.content,
+		spillAt:  vfs.spillBytes,
+		flag:     os.O_RDWR,
+		perm:     file.perm,
+		created:  now,
+		modified: now,
+		regular:  file.regular,
+	}
+	delete(vfs.consumed, dst)
+	return nil
+}
+
 // ListFiles lists all virtual files with their status
 func (vfs *SimpleVirtualFS) ListFiles() []string {
 	vfs.mutex.RLock()
@@ -818,8 +1356,101 @@ func (vfs *SimpleVirtualFS) ListFiles() []string {
 		status := ""
 		if vfs.consumed[name] {
 			status = " (consumed)"
+		} else if vfs.files[name].readOnly {
+			status = " (read-only)"
 		}
 		files = append(files, name+status)
 	}
+	for name := range vfs.fifos {
+		files = append(files, name+" (named pipe)")
+	}
+	for _, name := range vfs.mountedNamesLocked() {
+		files = append(files, name+" (read-only)")
+	}
 	return files
 }
+
+// FileSize returns the size of a virtual file that can still be read, for
+// vstat in spawned llmsh scripts
+func (vfs *SimpleVirtualFS) FileSize(name string) (int, error) {
+	vfs.mutex.RLock()
+	defer vfs.mutex.RUnlock()
+	name = virtualName(name)
+
+	if realPath, mounted, err := vfs.mountedPathLocked(name); mounted {
+		if err != nil {
+			return 0, err
+		}
+		info, err := os.Stat(realPath)
+		if err != nil {
+			return 0, err
+		}
+		if info.IsDir() {
+			return 0, fmt.Errorf("virtual file '%s': is a directory", name)
+		}
+		return int(info.Size()), nil
+	}
+	if pipe, exists := vfs.fifos[name]; exists {
+		return pipe.fifo.Buffered(), nil
+	}
+	if vfs.consumed[name] {
+		return 0, fmt.Errorf("virtual file '%s' already consumed (PIPE behavior - cannot read twice)", name)
+	}
+	file, exists := vfs.files[name]
+	if !exists {
+		return 0, os.ErrNotExist
+	}
+	return int(file.length()), nil
+}
+
+// FileStat describes a virtual file that can still be read, for vstat and
+// find in spawned llmsh scripts. Mounted files have the times and permissions
+// of the real file, without a creation time.
+func (vfs *SimpleVirtualFS) FileStat(name string) (vfsproxy.FileStat, error) {
+	vfs.mutex.RLock()
+	defer vfs.mutex.RUnlock()
+	name = virtualName(name)
+
+	if realPath, mounted, err := vfs.mountedPathLocked(name); mounted {
+		if err != nil {
+			return vfsproxy.FileStat{}, err
+		}
+		info, err := os.Stat(realPath)
+		if err != nil {
+			return vfsproxy.FileStat{}, err
+		}
+		if info.IsDir() {
+			return vfsproxy.FileStat{}, fmt.Errorf("virtual file '%s': is a directory", name)
+		}
+		return vfsproxy.FileStat{Size: info.Size(), Mode: info.Mode().Perm(), ModTime: info.ModTime()}, nil
+	}
+	if pipe, exists := vfs.fifos[name]; exists {
+		return vfsproxy.FileStat{Size: int64(pipe.fifo.Buffered()), Mode: os.ModeNamedPipe | pipe.perm, Created: pipe.created}, nil
+	}
+	if vfs.consumed[name] {
+		return vfsproxy.FileStat{}, fmt.Errorf("virtual file '%s' already consumed (PIPE behavior - cannot read twice)", name)
+	}
+	file, exists := vfs.files[name]
+	if !exists {
+		return vfsproxy.FileStat{}, os.ErrNotExist
+	}
+	return vfsproxy.FileStat{Size: file.length(), Mode: file.perm.Perm(), ModTime: file.modified, Created: file.created}, nil
+}
+
+// FileNames lists the virtual files that can still be read, for globbing in
+// spawned llmsh scripts
+func (vfs *SimpleVirtualFS) FileNames() []string {
+	vfs.mutex.RLock()
+	defer vfs.mutex.RUnlock()
+
+	names := make([]string, 0, len(vfs.files)+len(vfs.fifos))
+	for name := range vfs.files {
+		if !vfs.consumed[name] {
+			names = append(names, name)
+		}
+	}
+	for name := range vfs.fifos {
+		names = append(names, name)
+	}
+	return append(names, vfs.mountedNamesLocked()...)
+}
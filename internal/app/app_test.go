@@ -0,0 +1,495 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mako10k/llmcmd/internal/tools"
+)
+
+func TestVirtualFSLimits(t *testing.T) {
+	vfs := NewSimpleVirtualFS()
+	vfs.SetLimits(VFSLimits{TotalBytes: 10, FileBytes: 6, Files: 2})
+
+	a, err := vfs.OpenFile("a", os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile(a) error = %v", err)
+	}
+	if _, err := io.WriteString(a, "123456"); err != nil {
+		t.Fatalf("Write within limits: %v", err)
+	}
+	if _, err := io.WriteString(a, "7"); !errors.Is(err, syscall.EFBIG) {
+		t.Errorf("Write past vfs_max_file_bytes: err = %v, want EFBIG", err)
+	}
+
+	b, err := vfs.OpenFile("b", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile(b) error = %v", err)
+	}
+	if _, err := io.WriteString(b, "1234"); err != nil {
+		t.Fatalf("Write within limits: %v", err)
+	}
+	_, err = io.WriteString(b, "5")
+	if !errors.Is(err, syscall.ENOSPC) || !strings.Contains(err.Error(), "vfs_max_total_bytes") {
+		t.Errorf("Write past vfs_max_total_bytes: err = %v, want ENOSPC", err)
+	}
+
+	if _, err := vfs.OpenFile("c", os.O_WRONLY|os.O_CREATE, 0644); !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("OpenFile past vfs_max_files: err = %v, want ENOSPC", err)
+	}
+	if _, _, err := vfs.CreateTemp("x"); !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("CreateTemp past vfs_max_files: err = %v, want ENOSPC", err)
+	}
+
+	// Removing and truncating files frees their space
+	if err := vfs.RemoveFile("a"); err != nil {
+		t.Fatalf("RemoveFile(a) error = %v", err)
+	}
+	c, err := vfs.OpenFile("c", os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile(c) after removing a: %v", err)
+	}
+	if _, err := io.WriteString(c, "1234"); err != nil {
+		t.Errorf("Write within limits after removing a: %v", err)
+	}
+	if _, err := vfs.OpenFile("b", os.O_WRONLY|os.O_TRUNC, 0); err != nil {
+		t.Fatalf("OpenFile(b, O_TRUNC) error = %v", err)
+	}
+	if _, err := io.WriteString(c, "56"); err != nil {
+		t.Errorf("Write after b was truncated: %v", err)
+	}
+}
+
+func TestVirtualFSCloseEndsOneOpen(t *testing.T) {
+	vfs := NewSimpleVirtualFS()
+	engine, err := tools.NewEngine(tools.EngineConfig{
+		BufferSize:    4096,
+		NoStdin:       true,
+		ShellExecutor: &SimpleShellExecutor{},
+		VirtualFS:     vfs,
+	})
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	defer engine.Close()
+
+	// Each write(path) opens and closes the file; closing must not end later opens
+	for _, data := range []string{"one", "two"} {
+		call := map[string]interface{}{
+			"name":      "write",
+			"arguments": `{"path": "notes.txt", "data": "` + data + `", "newline": true}`,
+		}
+		if _, err := engine.ExecuteToolCall(call); err != nil {
+			t.Fatalf("write(%s) error = %v", data, err)
+		}
+	}
+	f, err := vfs.OpenFile("notes.txt", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	if got, err := io.ReadAll(f); err != nil || string(got) != "one\ntwo\n" {
+		t.Errorf("notes.txt = %q, %v; want %q", got, err, "one\ntwo\n")
+	}
+	f.Close()
+	if _, err := f.Read(make([]byte, 1)); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Read() after Close(): err = %v, want ErrClosed", err)
+	}
+}
+
+func TestVirtualFSRegularFiles(t *testing.T) {
+	vfs := NewSimpleVirtualFS()
+
+	w, err := vfs.OpenFile("tmp.txt", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile(w+) error = %v", err)
+	}
+	io.WriteString(w, "hello world")
+	seeker, ok := w.(io.Seeker)
+	if !ok {
+		t.Fatal("a file opened with w+ is not an io.Seeker")
+	}
+	if _, err := seeker.Seek(6, io.SeekStart); err != nil {
+		t.Fatalf("Seek() error = %v", err)
+	}
+	if got, _ := io.ReadAll(w); string(got) != "world" {
+		t.Errorf("read after Seek(6) = %q, want %q", got, "world")
+	}
+	seeker.Seek(0, io.SeekStart)
+	io.WriteString(w, "HELLO")
+	w.Close()
+
+	// Reads of a regular file do not consume it, in any mode, and each open
+	// starts at the beginning
+	for i := 0; i < 2; i++ {
+		r, err := vfs.OpenFile("tmp.txt", os.O_RDONLY, 0)
+		if err != nil {
+			t.Fatalf("OpenFile(r) #%d error = %v", i+1, err)
+		}
+		if got, _ := io.ReadAll(r); string(got) != "HELLO world" {
+			t.Errorf("read #%d = %q, want %q", i+1, got, "HELLO world")
+		}
+		r.Close()
+	}
+
+	r, _ := vfs.OpenFile("tmp.txt", os.O_RDONLY, 0)
+	stat, ok := r.(interface{ Stat() (os.FileInfo, error) })
+	if !ok {
+		t.Fatal("a regular virtual file has no Stat")
+	}
+	if info, _ := stat.Stat(); !info.Mode().IsRegular() || info.Size() != 11 {
+		t.Errorf("Stat() = %v, %d bytes; want a regular file of 11 bytes", info.Mode(), info.Size())
+	}
+	buf := make([]byte, 5)
+	if n, _ := r.(io.ReaderAt).ReadAt(buf, 6); string(buf[:n]) != "world" {
+		t.Errorf("ReadAt(6) = %q, want %q", buf[:n], "world")
+	}
+
+	// Files opened otherwise keep the PIPE behavior
+	p, _ := vfs.OpenFile("pipe.txt", os.O_WRONLY|os.O_CREATE, 0644)
+	io.WriteString(p, "once")
+	p, _ = vfs.OpenFile("pipe.txt", os.O_RDONLY, 0)
+	io.ReadAll(p)
+	if _, err := vfs.OpenFile("pipe.txt", os.O_RDWR, 0); err == nil {
+		t.Error("OpenFile(r+) of a consumed file: expected error")
+	}
+	if _, err := vfs.OpenFile("pipe.txt", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+		t.Errorf("OpenFile(w+) of a consumed file: %v", err)
+	}
+}
+
+func TestVirtualFSSpill(t *testing.T) {
+	vfs := NewSimpleVirtualFS()
+	vfs.SetSpillThreshold(8)
+
+	w, _ := vfs.OpenFile("big.txt", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	io.WriteString(w, "1234")
+	if vfs.files["big.txt"].content.spill != nil {
+		t.Fatal("a file below vfs_spill_bytes was spilled")
+	}
+	io.WriteString(w, "567890abcdef")
+	file := vfs.files["big.txt"]
+	if file.content.spill == nil || file.content.data != nil {
+		t.Fatal("a file past vfs_spill_bytes stayed in memory")
+	}
+	if size, _ := vfs.FileSize("big.txt"); size != 16 || vfs.used != 16 {
+		t.Errorf("FileSize() = %d, used = %d; want 16", size, vfs.used)
+	}
+
+	// Spilled files are exported and read like in-memory ones
+	exported := t.TempDir() + "/big.txt"
+	if err := vfs.ExportFile("big.txt", exported); err != nil {
+		t.Fatalf("ExportFile() error = %v", err)
+	}
+	if got, _ := os.ReadFile(exported); string(got) != "1234567890abcdef" {
+		t.Errorf("exported %q", got)
+	}
+	r, _ := vfs.OpenFile("big.txt", os.O_RDONLY, 0)
+	if got, _ := io.ReadAll(r); string(got) != "1234567890abcdef" {
+		t.Errorf("read %q", got)
+	}
+	if file.content.spill != nil || vfs.used != 0 {
+		t.Errorf("consuming a spilled file kept its temporary file (used = %d)", vfs.used)
+	}
+
+	rw, _ := vfs.OpenFile("rw.txt", os.O_RDWR|os.O_CREATE, 0644)
+	io.WriteString(rw, "hello")
+	rw.(io.Seeker).Seek(12, io.SeekStart)
+	io.WriteString(rw, "world")
+	buf := make([]byte, 17)
+	if n, _ := rw.(io.ReaderAt).ReadAt(buf, 0); string(buf[:n]) != "hello\x00\x00\x00\x00\x00\x00\x00world" {
+		t.Errorf("ReadAt() after writing past the spill threshold = %q", buf[:n])
+	}
+	if vfs.files["rw.txt"].content.spill == nil {
+		t.Error("a regular file past vfs_spill_bytes stayed in memory")
+	}
+	if _, err := vfs.OpenFile("rw.txt", os.O_WRONLY|os.O_TRUNC, 0); err != nil {
+		t.Fatalf("OpenFile(O_TRUNC) error = %v", err)
+	}
+	if vfs.files["rw.txt"].content.spill != nil || vfs.used != 0 {
+		t.Errorf("truncating a spilled file kept its temporary file (used = %d)", vfs.used)
+	}
+}
+
+func TestCloseSpillFileRemovesName(t *testing.T) {
+	// As on Windows, where createUnlinkedTemp cannot remove an open file
+	file, err := os.CreateTemp(t.TempDir(), "spill-*")
+	if err != nil {
+		t.Fatalf("CreateTemp() error = %v", err)
+	}
+	namedSpills.Store(file, file.Name())
+	if err := closeSpillFile(file); err != nil {
+		t.Errorf("closeSpillFile() error = %v", err)
+	}
+	if _, err := os.Stat(file.Name()); !os.IsNotExist(err) {
+		t.Errorf("spill file after closeSpillFile(): stat error = %v, want not exist", err)
+	}
+
+	left, err := os.CreateTemp(t.TempDir(), "spill-*")
+	if err != nil {
+		t.Fatalf("CreateTemp() error = %v", err)
+	}
+	namedSpills.Store(left, left.Name())
+	removeNamedSpills()
+	if _, err := os.Stat(left.Name()); !os.IsNotExist(err) {
+		t.Errorf("spill file after removeNamedSpills(): stat error = %v, want not exist", err)
+	}
+}
+
+func TestVirtualFSClone(t *testing.T) {
+	vfs := NewSimpleVirtualFS()
+	vfs.SetLimits(VFSLimits{TotalBytes: 20})
+
+	w, _ := vfs.OpenFile("app.py", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	io.WriteString(w, "DEBUG = True")
+	if err := vfs.Clone("app.py", "app.py.orig"); err != nil {
+		t.Fatalf("Clone() error = %v", err)
+	}
+	if vfs.used != 12 {
+		t.Errorf("used = %d after Clone, want the 12 bytes to be shared", vfs.used)
+	}
+
+	// Writing either file copies the shared content, within the limits
+	if _, err := io.WriteString(w, "!"); !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("Write that copies past vfs_max_total_bytes: err = %v, want ENOSPC", err)
+	}
+	vfs.SetLimits(VFSLimits{})
+	io.WriteString(w, "!")
+	if vfs.used != 25 {
+		t.Errorf("used = %d after writing the source, want 25", vfs.used)
+	}
+	r, _ := vfs.OpenFile("app.py.orig", os.O_RDONLY, 0)
+	if got, _ := io.ReadAll(r); string(got) != "DEBUG = True" {
+		t.Errorf("clone = %q, want the content before the write", got)
+	}
+
+	// Removing the source keeps a clone's data
+	vfs.Clone("app.py", "copy")
+	vfs.RemoveFile("app.py")
+	if size, err := vfs.FileSize("copy"); err != nil || size != 13 {
+		t.Errorf("FileSize(copy) = %d, %v after removing the source, want 13", size, err)
+	}
+	if vfs.used != 13 {
+		t.Errorf("used = %d, want 13", vfs.used)
+	}
+
+	if err := vfs.Clone("app.py.orig", "x"); err == nil {
+		t.Error("Clone of a consumed file: expected error")
+	}
+	if err := vfs.Clone("missing", "x"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Clone(missing) error = %v, want ErrNotExist", err)
+	}
+}
+
+func TestVirtualFSTruncate(t *testing.T) {
+	vfs := NewSimpleVirtualFS()
+	vfs.SetSpillThreshold(8)
+
+	w, _ := vfs.OpenFile("a.txt", os.O_RDWR|os.O_CREATE, 0644)
+	io.WriteString(w, "hello world")
+	vfs.Clone("a.txt", "orig.txt")
+	if err := vfs.Truncate("./a.txt", 5); err != nil {
+		t.Fatalf("Truncate(5) error = %v", err)
+	}
+	if err := vfs.Truncate("a.txt", 7); err != nil {
+		t.Fatalf("Truncate(7) error = %v", err)
+	}
+	buf := make([]byte, 16)
+	if n, _ := w.(io.ReaderAt).ReadAt(buf, 0); string(buf[:n]) != "hello\x00\x00" {
+		t.Errorf("a.txt = %q, want it cut and extended with zeros", buf[:n])
+	}
+	if size, _ := vfs.FileSize("orig.txt"); size != 11 {
+		t.Errorf("FileSize(orig.txt) = %d, want the clone untouched", size)
+	}
+	if vfs.used != 18 {
+		t.Errorf("used = %d, want 18", vfs.used)
+	}
+
+	// Spilled files are truncated on disk
+	if err := vfs.Truncate("a.txt", 20); err != nil || vfs.files["a.txt"].content.spill == nil {
+		t.Fatalf("Truncate(20) error = %v, want the file spilled", err)
+	}
+	if err := vfs.Truncate("a.txt", 2); err != nil {
+		t.Fatalf("Truncate(2) of a spilled file error = %v", err)
+	}
+	if n, _ := w.(io.ReaderAt).ReadAt(buf, 0); string(buf[:n]) != "he" || vfs.used != 13 {
+		t.Errorf("a.txt = %q, used = %d; want %q and 13", buf[:n], vfs.used, "he")
+	}
+
+	vfs.SetLimits(VFSLimits{FileBytes: 10})
+	if err := vfs.Truncate("a.txt", 11); !errors.Is(err, syscall.EFBIG) {
+		t.Errorf("Truncate past vfs_max_file_bytes: err = %v, want EFBIG", err)
+	}
+	vfs.AddReadOnlyFile("site/index", []byte("x"), time.Now())
+	if err := vfs.Truncate("site/index", 0); !errors.Is(err, syscall.EROFS) {
+		t.Errorf("Truncate of a read-only file: err = %v, want EROFS", err)
+	}
+	if err := vfs.Truncate("missing", 0); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Truncate(missing) error = %v, want ErrNotExist", err)
+	}
+}
+
+func TestVirtualFSCompressIdle(t *testing.T) {
+	vfs := NewSimpleVirtualFS()
+	vfs.SetCompression(1000, time.Minute)
+
+	text := strings.Repeat("the same line again\n", 100)
+	w, _ := vfs.OpenFile("log.txt", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	io.WriteString(w, text)
+	small, _ := vfs.OpenFile("small.txt", os.O_WRONLY|os.O_CREATE, 0644)
+	io.WriteString(small, "short")
+
+	if n := vfs.CompressIdle(time.Now()); n != 0 {
+		t.Errorf("CompressIdle() compressed %d files that were just written", n)
+	}
+	if n := vfs.CompressIdle(time.Now().Add(time.Hour)); n != 1 {
+		t.Errorf("CompressIdle() an hour later compressed %d files, want 1", n)
+	}
+	content := vfs.files["log.txt"].content
+	if content.data != nil || len(content.packed) >= len(text) {
+		t.Fatalf("log.txt is not compressed: %d bytes in memory, %d packed", len(content.data), len(content.packed))
+	}
+	if size, _ := vfs.FileSize("log.txt"); size != len(text) || vfs.used != int64(len(text)+5) {
+		t.Errorf("FileSize() = %d, used = %d; want the uncompressed sizes", size, vfs.used)
+	}
+
+	// Using the file decompresses it
+	io.WriteString(w, "last\n")
+	r, _ := vfs.OpenFile("log.txt", os.O_RDONLY, 0)
+	if got, _ := io.ReadAll(r); string(got) != text+"last\n" {
+		t.Errorf("read %d bytes after compression, want the %d written", len(got), len(text)+5)
+	}
+}
+
+func TestVirtualFSConcurrentReadsOfCompressedFile(t *testing.T) {
+	vfs := NewSimpleVirtualFS()
+	vfs.SetCompression(100, time.Minute)
+	text := strings.Repeat("the same line again\n", 100)
+	f, err := vfs.OpenFile("log.txt", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	io.WriteString(f, text)
+
+	// Readers hold the VFS lock only for reading; run them with go test -race
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				vfs.CompressIdle(time.Now().Add(time.Hour))
+				buf := make([]byte, 20)
+				if n, err := f.(io.ReaderAt).ReadAt(buf, int64(j*20)); err != nil || string(buf[:n]) != "the same line again\n" {
+					t.Errorf("ReadAt(%d) = %q, %v", j*20, buf[:n], err)
+					return
+				}
+				if size, err := vfs.FileSize("log.txt"); err != nil || size != len(text) {
+					t.Errorf("FileSize() = %d, %v; want %d", size, err, len(text))
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestVirtualFSFileStat(t *testing.T) {
+	vfs := NewSimpleVirtualFS()
+	before := time.Now()
+	writeVirtualFile(t, vfs, "report.txt", "report\n", 0600)
+
+	stat, err := vfs.FileStat("report.txt")
+	if err != nil {
+		t.Fatalf("FileStat() error = %v", err)
+	}
+	if stat.Size != 7 || stat.Mode != 0600 || stat.ModTime.Before(before) || stat.Created.Before(before) {
+		t.Errorf("FileStat() = %+v, want 7 bytes, mode 0600 and times after %v", stat, before)
+	}
+
+	// Snapshots keep the modification time
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	vfs.files["report.txt"].modified = modTime
+	path := t.TempDir() + "/vfs.tar"
+	if err := vfs.SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot() error = %v", err)
+	}
+	loaded := NewSimpleVirtualFS()
+	loaded.LoadSnapshot(path)
+	if stat, _ := loaded.FileStat("report.txt"); !stat.ModTime.Equal(modTime) || stat.Mode != 0600 {
+		t.Errorf("FileStat() after LoadSnapshot = %+v, want mode 0600 and modified %v", stat, modTime)
+	}
+
+	if _, err := vfs.FileStat("missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("FileStat(missing) error = %v, want ErrNotExist", err)
+	}
+}
+
+func TestVirtualFSLocks(t *testing.T) {
+	vfs := NewSimpleVirtualFS()
+
+	if !vfs.TryLock("out/report.txt", "a", true) {
+		t.Fatal("TryLock() of a free name failed")
+	}
+	if vfs.TryLock("./out//report.txt", "b", false) {
+		t.Error("TryLock() of the same file under another spelling succeeded, want it held by a")
+	}
+	vfs.Unlock("/out/report.txt", "a")
+	if !vfs.TryLock("out/report.txt", "b", false) || !vfs.TryLock("out/report.txt", "c", false) {
+		t.Error("shared TryLock() after Unlock failed")
+	}
+}
+
+func TestVirtualFSNamedPipes(t *testing.T) {
+	vfs := NewSimpleVirtualFS()
+	if err := vfs.MakeFifo("./jobs/feed", 0600); err != nil {
+		t.Fatalf("MakeFifo() error = %v", err)
+	}
+	if err := vfs.MakeFifo("jobs/feed", 0600); !errors.Is(err, os.ErrExist) {
+		t.Errorf("MakeFifo() of an existing pipe: err = %v, want ErrExist", err)
+	}
+
+	w, err := vfs.OpenFile("jobs/feed", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile(w) error = %v", err)
+	}
+	io.WriteString(w, "line\n")
+	if stat, err := vfs.FileStat("jobs/feed"); err != nil || stat.Mode != os.ModeNamedPipe|0600 || stat.Size != 5 {
+		t.Errorf("FileStat() = %+v, %v, want a 0600 named pipe holding 5 bytes", stat, err)
+	}
+	w.Close()
+	r, _ := vfs.OpenFile("jobs/feed", os.O_RDONLY, 0)
+	if got, _ := io.ReadAll(r); string(got) != "line\n" {
+		t.Errorf("read %q, want %q", got, "line\n")
+	}
+	r.Close()
+
+	if _, err := vfs.OpenFile("jobs/feed", os.O_RDWR, 0); err == nil {
+		t.Error("OpenFile(r+) of a named pipe succeeded, want an error")
+	}
+	if err := vfs.Clone("jobs/feed", "copy"); err == nil {
+		t.Error("Clone() of a named pipe succeeded, want an error")
+	}
+	if err := vfs.RemoveFile("jobs/feed"); err != nil {
+		t.Errorf("RemoveFile() error = %v", err)
+	}
+	if names := vfs.FileNames(); len(names) != 0 {
+		t.Errorf("FileNames() = %v after removing the pipe", names)
+	}
+}
+
+func TestScratchNote(t *testing.T) {
+	for _, shell := range []string{"", "sh"} {
+		if note := scratchNote(shell); !strings.Contains(note, `open("$LLMCMD_TMPDIR/name")`) {
+			t.Errorf("scratchNote(%q) = %q, want the scratch directory", shell, note)
+		}
+	}
+	if note := scratchNote("llmsh"); note != "" {
+		t.Errorf("scratchNote(llmsh) = %q, want empty", note)
+	}
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mako10k/llmcmd/internal/cli"
+	"github.com/mako10k/llmcmd/internal/openai"
+)
+
+// chatSystemPrompt tells the model its reply is used as command output
+const chatSystemPrompt = "You are a text-processing step in a shell pipeline. " +
+	"Do what the instruction asks with the input, if any, and reply with the result only: " +
+	"no explanations, greetings or code fences."
+
+// Chat sends one instruction, and the input it applies to, to the internal
+// model without tools and returns the reply. It is the path of the llmsh llm
+// builtin: the configuration is the user's profile, and usage is charged to
+// processID, which must be registered with sharedQuota.
+func Chat(ctx context.Context, prompt, input string, sharedQuota *openai.SharedQuotaManager, processID string) (string, error) {
+	config, err := cli.ParseArgs([]string{"-p", prompt})
+	if err != nil {
+		return "", err
+	}
+	fileConfig, err := cli.LoadAndMergeConfig(config)
+	if err != nil {
+		return "", fmt.Errorf("configuration error: %w", err)
+	}
+	cli.LoadEnvironmentConfig(fileConfig)
+	if fileConfig.OpenAIAPIKey == "" {
+		return "", fmt.Errorf("OpenAI API key is required. Set it in config file or OPENAI_API_KEY environment variable")
+	}
+	model := fileConfig.Model
+	if fileConfig.InternalModel != "" {
+		model = fileConfig.InternalModel
+	}
+
+	if !sharedQuota.CanMakeCall(processID) {
+		return "", fmt.Errorf("quota exceeded")
+	}
+
+	content := prompt
+	if input != "" {
+		content += "\n\nInput:\n" + input
+	}
+	client := openai.NewClientWithSharedQuota(newClientConfig(fileConfig), sharedQuota, processID)
+	response, err := client.ChatCompletionWithRetry(ctx, openai.ChatCompletionRequest{
+		Model: model,
+		Messages: []openai.ChatMessage{
+			{Role: "system", Content: chatSystemPrompt},
+			{Role: "user", Content: content},
+		},
+		MaxTokens:   fileConfig.MaxTokens,
+		Temperature: fileConfig.Temperature,
+	})
+	if err != nil {
+		return "", fmt.Errorf("OpenAI API error: %w", err)
+	}
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("OpenAI API error: no choices in response")
+	}
+
+	usage := response.Usage
+	cached := 0
+	if usage.PromptTokensDetails != nil {
+		cached = usage.PromptTokensDetails.CachedTokens
+	}
+	if err := sharedQuota.ConsumeTokens(processID, &openai.QuotaUsage{
+		InputTokens:  usage.PromptTokens - cached,
+		CachedTokens: cached,
+		OutputTokens: usage.CompletionTokens,
+	}); err != nil {
+		return "", err
+	}
+
+	choice := response.Choices[0]
+	if refusal := openai.DetectRefusal(choice, false); refusal != nil {
+		return "", refusal
+	}
+	reply := choice.Message.Content
+	if !strings.HasSuffix(reply, "\n") {
+		reply += "\n"
+	}
+	return reply, nil
+}
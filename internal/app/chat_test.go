@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mako10k/llmcmd/internal/openai"
+)
+
+func TestChatSendsPromptAndInputWithoutTools(t *testing.T) {
+	var request openai.ChatCompletionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&request)
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.Choice{{Message: openai.ChatMessage{Role: "assistant", Content: "3 errors"}, FinishReason: "stop"}},
+			Usage:   openai.Usage{PromptTokens: 100, CompletionTokens: 10},
+		})
+	}))
+	defer server.Close()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+
+	quota := openai.NewSharedQuotaManager(&openai.QuotaConfig{MaxTokens: 1000, InputWeight: 1, OutputWeight: 4})
+	quota.RegisterProcess("llm-1", "llmsh")
+	reply, err := Chat(context.Background(), "count the errors", "ERROR a\nERROR b\nERROR c\n", quota, "llm-1")
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	if reply != "3 errors\n" {
+		t.Errorf("reply = %q, want the content with a final newline", reply)
+	}
+	if request.Model != "gpt-4o-mini" || len(request.Tools) != 0 || len(request.Messages) != 2 {
+		t.Errorf("request = %+v, want the internal model, no tools and two messages", request)
+	}
+	if user := request.Messages[1].Content; !strings.HasPrefix(user, "count the errors") || !strings.Contains(user, "ERROR c") {
+		t.Errorf("user message = %q, want the prompt and the input", user)
+	}
+	if usage := quota.GetGlobalUsage(); usage.TotalWeighted != 140 {
+		t.Errorf("quota used = %v, want 140", usage.TotalWeighted)
+	}
+}
+
+func TestChatRequiresQuota(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	quota := openai.NewSharedQuotaManager(&openai.QuotaConfig{MaxTokens: 0})
+	quota.RegisterProcess("llm-1", "llmsh")
+	if _, err := Chat(context.Background(), "hi", "", quota, "llm-1"); err == nil || !strings.Contains(err.Error(), "quota") {
+		t.Errorf("Chat error = %v, want a quota error", err)
+	}
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Large in-memory files that have not been read or written for a while are
+// compressed with DEFLATE at its fastest level, and decompressed again when
+// they are next used. Spilled files are already out of memory and are left
+// alone. Sizes and limits always count the uncompressed bytes.
+
+// SetCompression makes CompressIdle compress files of at least bytes that
+// have been idle for idle; bytes 0 turns compression off
+func (vfs *SimpleVirtualFS) SetCompression(bytes int64, idle time.Duration) {
+	vfs.mutex.Lock()
+	defer vfs.mutex.Unlock()
+	vfs.compressBytes = bytes
+	vfs.compressIdle = idle
+}
+
+// StartCompression runs CompressIdle in the background, every half idle
+// period, until the returned function is called
+func (vfs *SimpleVirtualFS) StartCompression() (stop func()) {
+	vfs.mutex.RLock()
+	enabled, interval := vfs.compressBytes > 0, vfs.compressIdle/2
+	vfs.mutex.RUnlock()
+	if !enabled {
+		return func() {}
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case now := <-ticker.C:
+				vfs.CompressIdle(now)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// CompressIdle compresses the files that are due at now and returns how many
+// it compressed. Each file is compressed under its own hold of the lock, so
+// tools are only held up by the file being compressed.
+func (vfs *SimpleVirtualFS) CompressIdle(now time.Time) int {
+	vfs.mutex.RLock()
+	minBytes, idle := vfs.compressBytes, vfs.compressIdle
+	var due []*fileContent
+	if minBytes > 0 {
+		for _, file := range vfs.files {
+			if file.content.compressible(minBytes, now.Add(-idle)) {
+				due = append(due, file.content)
+			}
+		}
+	}
+	vfs.mutex.RUnlock()
+
+	compressed := 0
+	for _, c := range due {
+		vfs.mutex.Lock()
+		// The file may have been used, or the content dropped, meanwhile
+		if c.compressible(minBytes, now.Add(-idle)) && c.pack(now) {
+			compressed++
+		}
+		vfs.mutex.Unlock()
+	}
+	return compressed
+}
+
+// compressible reports whether the content is in memory, uncompressed, at
+// least minBytes long and unused since before
+func (c *fileContent) compressible(minBytes int64, before time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.spill == nil && c.packed == nil && c.refs > 0 &&
+		int64(len(c.data)) >= minBytes && c.accessed.Load() < before.UnixNano()
+}
+
+// pack replaces the in-memory data with its compressed form. Data that does
+// not shrink stays as it is, and is not tried again until it has been idle
+// for another period. It runs with the VFS mutex held for writing, so no
+// reader can be using the data.
+func (c *fileContent) pack(now time.Time) bool {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	w.Write(c.data)
+	w.Close()
+	if buf.Len() >= len(c.data) {
+		c.accessed.Store(now.UnixNano())
+		return false
+	}
+	c.packed = bytes.Clone(buf.Bytes())
+	c.packedSize = int64(len(c.data))
+	c.data = nil
+	return true
+}
+
+// touch records a use of the content, decompressing it if needed. Readers
+// holding only the VFS read lock may touch the same content at once; the
+// first decompresses it and the others find it in memory.
+func (c *fileContent) touch() error {
+	c.accessed.Store(time.Now().UnixNano())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.packed == nil {
+		return nil
+	}
+	data := make([]byte, c.packedSize)
+	r := flate.NewReader(bytes.NewReader(c.packed))
+	defer r.Close()
+	if _, err := io.ReadFull(r, data); err != nil {
+		return fmt.Errorf("cannot decompress virtual file: %w", err)
+	}
+	c.data = data
+	c.packed = nil
+	c.packedSize = 0
+	return nil
+}
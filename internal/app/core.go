@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 
+	"github.com/mako10k/llmcmd/internal/buildinfo"
 	"github.com/mako10k/llmcmd/internal/cli"
 	"github.com/mako10k/llmcmd/internal/install"
 	"github.com/mako10k/llmcmd/internal/openai"
@@ -40,6 +41,10 @@ func NewLLMCmdCore(metadata ApplicationMetadata, context *ExecutionContext) *LLM
 
 // ExecuteWithArgs executes llmcmd with provided arguments
 func (core *LLMCmdCore) ExecuteWithArgs(args []string) error {
+	if len(args) > 0 && args[0] == "estimate" {
+		return core.handleEstimate(args[1:])
+	}
+
 	// Parse command line arguments
 	config, err := cli.ParseArgs(args)
 	if err != nil {
@@ -48,7 +53,12 @@ func (core *LLMCmdCore) ExecuteWithArgs(args []string) error {
 			cli.ShowHelp()
 			return nil
 		case cli.ErrShowVersion:
-			fmt.Printf("%s version %s\n", core.metadata.Name, core.metadata.Version)
+			info := buildinfo.Get(core.metadata.Name)
+			if config != nil && config.VersionJSON {
+				fmt.Println(info.JSON())
+			} else {
+				fmt.Println(info)
+			}
 			return nil
 		case cli.ErrListPresets:
 			return core.handleListPresets(config)
@@ -159,7 +169,7 @@ func (core *LLMCmdCore) resolvePrompt(config *cli.Config, mergedConfig *cli.Conf
 		defaultPreset := mergedConfig.DefaultPrompt
 		if defaultPreset != "" {
 			presetContent, err := cli.ResolvePreset(mergedConfig, defaultPreset)
-			if err != nil {
+			if err != nil && !config.Quiet {
 				log.Printf("Warning: Could not resolve default preset '%s': %v", defaultPreset, err)
 			} else {
 				finalPrompt = presetContent
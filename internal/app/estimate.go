@@ -0,0 +1,205 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mako10k/llmcmd/internal/cli"
+	"github.com/mako10k/llmcmd/internal/openai"
+)
+
+// Per-call assumptions used when the configuration has no usage history
+const (
+	defaultOutputPerCall = 150 // Output tokens of a typical tool-calling response
+	minToolResultTokens  = 50  // Small tool results (exit codes, fd lists, short reads)
+	maxToolResultTokens  = 500 // Large tool results (chunks of input data)
+	typicalToolCalls     = 10  // Tool calls used for the overall verdict
+)
+
+// estimateToolCalls lists the tool-call counts reported by estimate
+var estimateToolCalls = []int{1, 3, 5, 10, 20}
+
+// EstimateRow is the predicted usage of a run making a given number of tool calls
+type EstimateRow struct {
+	Calls        int
+	InputLow     int
+	InputHigh    int
+	Output       int
+	WeightedLow  float64
+	WeightedHigh float64
+}
+
+// estimateUsage predicts token usage for a run making calls tool calls, one
+// per API call. Every API call resends the conversation so far: base tokens of
+// initial messages and tool definitions, plus each earlier response and tool
+// result. The low end assumes input data is processed by spawned scripts
+// without entering the conversation; the high end assumes it is read into the
+// conversation by the first tool call.
+func estimateUsage(calls, base, data int, usage cli.QuotaUsage, weights cli.QuotaWeights) EstimateRow {
+	outputPerCall := defaultOutputPerCall
+	if usage.APICalls > 0 && usage.OutputTokens > 0 {
+		outputPerCall = usage.OutputTokens / usage.APICalls
+	}
+
+	// Sum of 0..calls-1: how many earlier turns each call resends in total
+	resent := calls * (calls - 1) / 2
+	row := EstimateRow{
+		Calls:     calls,
+		InputLow:  calls*base + resent*(outputPerCall+minToolResultTokens),
+		InputHigh: calls*base + resent*(outputPerCall+maxToolResultTokens) + (calls-1)*data,
+		Output:    calls * outputPerCall,
+	}
+	row.WeightedLow = float64(row.InputLow)*weights.InputWeight + float64(row.Output)*weights.OutputWeight
+	row.WeightedHigh = float64(row.InputHigh)*weights.InputWeight + float64(row.Output)*weights.OutputWeight
+	return row
+}
+
+// inputDataTokens estimates the tokens of the input files and of stdin when
+// it is redirected from a file; piped stdin cannot be sized without reading it
+func inputDataTokens(config *cli.Config) (tokens int, sized bool) {
+	sized = true
+	for _, file := range config.InputFiles {
+		if file == "-" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			sized = false
+			continue
+		}
+		tokens += int(float64(info.Size()) / openai.EstimatedCharsPerToken)
+	}
+	if !config.NoStdin && len(config.InputFiles) == 0 {
+		info, err := os.Stdin.Stat()
+		if err == nil && info.Mode().IsRegular() {
+			tokens += int(float64(info.Size()) / openai.EstimatedCharsPerToken)
+		} else if err == nil && info.Mode()&os.ModeCharDevice == 0 {
+			sized = false
+		}
+	}
+	return tokens, sized
+}
+
+// handleEstimate handles the estimate subcommand: it assembles the initial
+// messages as a run would and predicts token usage without calling the API
+func (core *LLMCmdCore) handleEstimate(args []string) error {
+	config, err := cli.ParseArgs(args)
+	if err == cli.ErrShowHelp {
+		cli.ShowHelp()
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("argument parsing error: %w", err)
+	}
+	mergedConfig, err := cli.LoadAndMergeConfig(config)
+	if err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+	if !core.context.IsTopLevelCmd && mergedConfig.InternalModel != "" {
+		mergedConfig.Model = mergedConfig.InternalModel
+	}
+	config.Prompt, err = core.resolvePrompt(config, mergedConfig)
+	if err != nil {
+		return fmt.Errorf("prompt resolution error: %w", err)
+	}
+
+	messages := openai.CreateInitialMessagesWithQuota(
+		config.Prompt,
+		config.Instructions,
+		config.InputFiles,
+		mergedConfig.GetEffectiveSystemPrompt(),
+		mergedConfig.DisableTools,
+		mergedConfig.GetQuotaStatusString(),
+		false,
+	)
+	var tools []openai.Tool
+	if !mergedConfig.DisableTools {
+		tools = openai.ToolDefinitions()
+	}
+	base := openai.EstimateRequestTokens(messages, tools)
+
+	// With tools disabled the input data is already part of the messages
+	data, sized := 0, true
+	if !mergedConfig.DisableTools {
+		data, sized = inputDataTokens(config)
+	}
+
+	writeEstimate(os.Stdout, mergedConfig, len(messages), base, data, sized)
+	return nil
+}
+
+// writeEstimate prints the estimate report
+func writeEstimate(w io.Writer, config *cli.ConfigFile, messages, base, data int, sized bool) {
+	weights := config.GetEffectiveQuotaWeights()
+
+	fmt.Fprintf(w, "Estimate for %s (rough: about %.1f characters per token)\n", config.Model, openai.EstimatedCharsPerToken)
+	fmt.Fprintf(w, "  Initial request: %d tokens (%d messages", base, messages)
+	if !config.DisableTools {
+		fmt.Fprintf(w, " and tool definitions")
+	}
+	fmt.Fprintf(w, ")\n")
+	if !config.DisableTools {
+		fmt.Fprintf(w, "  Input data:      %d tokens", data)
+		if !sized {
+			fmt.Fprintf(w, " (piped or missing input not counted)")
+		}
+		fmt.Fprintf(w, "\n")
+	}
+	if config.QuotaUsage.APICalls > 0 {
+		fmt.Fprintf(w, "  History:         %d API calls, %d output tokens per call on average\n",
+			config.QuotaUsage.APICalls, config.QuotaUsage.OutputTokens/config.QuotaUsage.APICalls)
+	} else {
+		fmt.Fprintf(w, "  History:         none, assuming %d output tokens per call\n", defaultOutputPerCall)
+	}
+
+	calls := append([]int{}, estimateToolCalls...)
+	if config.DisableTools {
+		// Without tools a run is a single API call
+		calls = []int{1}
+	}
+	fmt.Fprintf(w, "\n  %-10s  %-19s  %-8s  %s\n", "Tool calls", "Input tokens", "Output", "Weighted tokens")
+	for _, n := range calls {
+		if n > config.MaxAPICalls {
+			break
+		}
+		row := estimateUsage(n, base, data, config.QuotaUsage, weights)
+		fmt.Fprintf(w, "  %-10d  %-19s  %-8d  %s\n", n,
+			fmt.Sprintf("%d-%d", row.InputLow, row.InputHigh), row.Output,
+			fmt.Sprintf("%.0f-%.0f", row.WeightedLow, row.WeightedHigh))
+	}
+	fmt.Fprintf(w, "\n")
+
+	if config.QuotaMaxTokens <= 0 {
+		fmt.Fprintf(w, "Quota: no limit set (quota_max_tokens)\n")
+		return
+	}
+	remaining := float64(config.QuotaMaxTokens) - config.QuotaUsage.TotalWeightedTokens
+	worst, best := 0, 0
+	for n := 1; n <= config.MaxAPICalls; n++ {
+		row := estimateUsage(n, base, data, config.QuotaUsage, weights)
+		if row.WeightedHigh <= remaining {
+			worst = n
+		}
+		if row.WeightedLow <= remaining {
+			best = n
+		}
+	}
+	fmt.Fprintf(w, "Quota: %.0f of %d weighted tokens remaining; enough for %d-%d tool calls\n",
+		remaining, config.QuotaMaxTokens, worst, best)
+
+	typical := typicalToolCalls
+	if config.DisableTools {
+		typical = 1
+	} else if typical > config.MaxAPICalls {
+		typical = config.MaxAPICalls
+	}
+	switch {
+	case worst >= typical:
+		fmt.Fprintf(w, "Verdict: plausibly sufficient\n")
+	case best >= typical:
+		fmt.Fprintf(w, "Verdict: tight, depends on how much input data the run reads\n")
+	default:
+		fmt.Fprintf(w, "Verdict: likely insufficient, raise quota_max_tokens or reduce the input\n")
+	}
+}
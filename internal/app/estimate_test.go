@@ -0,0 +1,52 @@
+package app
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mako10k/llmcmd/internal/cli"
+)
+
+func TestEstimateUsageGrowsWithCalls(t *testing.T) {
+	weights := cli.QuotaWeights{InputWeight: 1, OutputWeight: 4}
+	usage := cli.QuotaUsage{APICalls: 10, OutputTokens: 1000}
+
+	one := estimateUsage(1, 1000, 5000, usage, weights)
+	if one.InputLow != 1000 || one.InputHigh != 1000 || one.Output != 100 {
+		t.Fatalf("single call: %+v", one)
+	}
+
+	three := estimateUsage(3, 1000, 5000, usage, weights)
+	// 3 requests of the base, 3 resent turns, input data resent by calls 2 and 3
+	if three.InputLow != 3000+3*(100+minToolResultTokens) {
+		t.Errorf("InputLow = %d", three.InputLow)
+	}
+	if three.InputHigh != 3000+3*(100+maxToolResultTokens)+2*5000 {
+		t.Errorf("InputHigh = %d", three.InputHigh)
+	}
+	if three.WeightedHigh != float64(three.InputHigh)+4*300 {
+		t.Errorf("WeightedHigh = %v", three.WeightedHigh)
+	}
+}
+
+func TestWriteEstimateVerdict(t *testing.T) {
+	tests := []struct {
+		quota int
+		want  string
+	}{
+		{0, "no limit set"},
+		{10000000, "plausibly sufficient"},
+		{60000, "tight"},
+		{5000, "likely insufficient"},
+	}
+	for _, tt := range tests {
+		config := cli.DefaultConfig()
+		config.QuotaMaxTokens = tt.quota
+		var out bytes.Buffer
+		writeEstimate(&out, config, 2, 3000, 5000, true)
+		if !strings.Contains(out.String(), tt.want) {
+			t.Errorf("quota %d: want %q in\n%s", tt.quota, tt.want, out.String())
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/mako10k/llmcmd/internal/vfsproxy"
+)
+
+// namedPipe is a FIFO made with MakeFifo. Scripts spawned separately, and the
+// open tool, find it by name, so they can stream data to each other without
+// passing file descriptors around.
+type namedPipe struct {
+	fifo    *vfsproxy.Fifo
+	perm    os.FileMode
+	created time.Time
+}
+
+// MakeFifo makes a named pipe, like mkfifo(1). Opening it for reading or for
+// writing never waits; see vfsproxy.Fifo for how the ends meet.
+func (vfs *SimpleVirtualFS) MakeFifo(name string, perm os.FileMode) error {
+	vfs.mutex.Lock()
+	defer vfs.mutex.Unlock()
+	name = virtualName(name)
+
+	if name == "" {
+		return fmt.Errorf("virtual file '/': %w", os.ErrExist)
+	}
+	if vfs.mountOfLocked(name) != nil {
+		return fmt.Errorf("virtual file '%s': %w", name, syscall.EROFS)
+	}
+	if _, exists := vfs.files[name]; exists {
+		return fmt.Errorf("virtual file '%s': %w", name, os.ErrExist)
+	}
+	if _, exists := vfs.fifos[name]; exists {
+		return fmt.Errorf("virtual file '%s': %w", name, os.ErrExist)
+	}
+	if err := vfs.checkNewFileLocked(name); err != nil {
+		return err
+	}
+	if vfs.fifos == nil {
+		vfs.fifos = make(map[string]*namedPipe)
+	}
+	vfs.fifos[name] = &namedPipe{fifo: vfsproxy.NewFifo(), perm: perm.Perm(), created: time.Now()}
+	delete(vfs.consumed, name)
+	return nil
+}
+
+// openFifoLocked opens the end of a named pipe flag asks for. Pipes are
+// read or written, not both.
+func openFifoLocked(name string, pipe *namedPipe, flag int) (io.ReadWriteCloser, error) {
+	switch flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR) {
+	case os.O_RDONLY:
+		return pipe.fifo.OpenReader(), nil
+	case os.O_WRONLY:
+		return pipe.fifo.OpenWriter(), nil
+	}
+	return nil, fmt.Errorf("virtual file '%s': a named pipe is opened for reading or for writing, not both", name)
+}
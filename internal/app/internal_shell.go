@@ -0,0 +1,67 @@
+package app
+
+import (
+	"context"
+	"io"
+
+	"github.com/mako10k/llmcmd/internal/tools"
+)
+
+// InternalShellRunner runs llmsh scripts in-process. The llmsh package imports
+// app, so it registers its runner on import instead of app importing it.
+type InternalShellRunner interface {
+	RunScript(ctx context.Context, script string, stdin io.Reader, stdout, stderr io.Writer, vfs tools.VirtualFileSystem) error
+	CheckScript(script string) error
+}
+
+// internalShellRunner is the registered llmsh runner, nil when llmsh is not linked in
+var internalShellRunner InternalShellRunner
+
+// RegisterInternalShellRunner makes runner available for spawn_shell=llmsh
+func RegisterInternalShellRunner(runner InternalShellRunner) {
+	internalShellRunner = runner
+}
+
+// InternalShellExecutor implements tools.ShellExecutor by running spawn
+// scripts with the in-process llmsh, so no shell binary has to be found on
+// PATH. Environment variables do not apply to llmsh builtins and are ignored.
+type InternalShellExecutor struct {
+	runner InternalShellRunner
+	vfs    tools.VirtualFileSystem
+}
+
+// NewInternalShellExecutor creates an executor for the registered llmsh
+// runner; ok is false when none is registered
+func NewInternalShellExecutor() (executor *InternalShellExecutor, ok bool) {
+	if internalShellRunner == nil {
+		return nil, false
+	}
+	return &InternalShellExecutor{runner: internalShellRunner}, true
+}
+
+// SetVFS sets the virtual file system that llmsh redirections resolve against
+func (s *InternalShellExecutor) SetVFS(vfs tools.VirtualFileSystem) {
+	s.vfs = vfs
+}
+
+// Execute runs a script without input, discarding its output
+func (s *InternalShellExecutor) Execute(command string) error {
+	return s.ExecuteContext(context.Background(), command, nil, nil, io.Discard, io.Discard)
+}
+
+// ExecuteWithIO runs a script with the specified IO
+func (s *InternalShellExecutor) ExecuteWithIO(command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return s.ExecuteContext(context.Background(), command, nil, stdin, stdout, stderr)
+}
+
+// CheckSyntax parses a script without running it, so spawn can return
+// llmsh's syntax errors before starting anything
+func (s *InternalShellExecutor) CheckSyntax(script string) error {
+	return s.runner.CheckScript(script)
+}
+
+// ExecuteContext runs a script. When ctx is done the script is stopped, and
+// a builtin blocked on its input or output released, before it returns.
+func (s *InternalShellExecutor) ExecuteContext(ctx context.Context, command string, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return s.runner.RunScript(ctx, command, stdin, stdout, stderr, s.vfs)
+}
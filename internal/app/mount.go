@@ -0,0 +1,148 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/mako10k/llmcmd/internal/cli"
+)
+
+// vfsMount is a real directory exposed read-only under a virtual prefix
+type vfsMount struct {
+	prefix string // Virtual name of the directory, without slashes at either end
+	dir    string // Real directory, absolute with symlinks resolved
+}
+
+// MountReadOnly exposes the tree under realDir as read-only virtual files
+// named virtualPrefix/..., so a whole directory can be analyzed without
+// listing every file with -i. Files are read from disk when they are opened;
+// writing or removing them fails with EROFS.
+func (vfs *SimpleVirtualFS) MountReadOnly(realDir, virtualPrefix string) error {
+	prefix := virtualName(virtualPrefix)
+	if prefix == "" {
+		return fmt.Errorf("mount %s: the virtual prefix cannot be the root", realDir)
+	}
+	dir, err := filepath.Abs(realDir)
+	if err == nil {
+		dir, err = filepath.EvalSymlinks(dir)
+	}
+	if err != nil {
+		return fmt.Errorf("mount %s: %w", realDir, err)
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("mount %s: %w", realDir, err)
+	} else if !info.IsDir() {
+		return fmt.Errorf("mount %s: not a directory", realDir)
+	}
+
+	vfs.mutex.Lock()
+	defer vfs.mutex.Unlock()
+	for _, m := range vfs.mounts {
+		if m.prefix == prefix {
+			return fmt.Errorf("mount %s: %s is already mounted from %s", realDir, prefix, m.dir)
+		}
+	}
+	vfs.mounts = append(vfs.mounts, vfsMount{prefix: prefix, dir: dir})
+	// The deepest mount wins for nested prefixes
+	sort.Slice(vfs.mounts, func(i, j int) bool { return len(vfs.mounts[i].prefix) > len(vfs.mounts[j].prefix) })
+	return nil
+}
+
+// mountedPathLocked returns the real path of a cleaned virtual name under a
+// mount. Paths that leave the mounted directory through symlinks are refused.
+func (vfs *SimpleVirtualFS) mountedPathLocked(name string) (string, bool, error) {
+	m := vfs.mountOfLocked(name)
+	if m == nil {
+		return "", false, nil
+	}
+	realPath := filepath.Join(m.dir, filepath.FromSlash(strings.TrimPrefix(name, m.prefix)))
+	resolved, err := filepath.EvalSymlinks(realPath)
+	if err != nil {
+		// Missing files fail when they are opened
+		return realPath, true, nil
+	}
+	if resolved != m.dir && !strings.HasPrefix(resolved, m.dir+string(filepath.Separator)) {
+		return "", true, fmt.Errorf("virtual file '%s': %w (outside the mounted directory)", name, os.ErrPermission)
+	}
+	return resolved, true, nil
+}
+
+// openMountedLocked opens a file under a mount for reading
+func (vfs *SimpleVirtualFS) openMountedLocked(name, realPath string, flag int) (io.ReadWriteCloser, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, fmt.Errorf("virtual file '%s': %w", name, syscall.EROFS)
+	}
+	file, err := os.Open(realPath)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := file.Stat(); err != nil || info.IsDir() {
+		file.Close()
+		if err == nil {
+			err = fmt.Errorf("virtual file '%s': is a directory", name)
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+// mountedNamesLocked lists the regular files under the mounts by virtual name
+func (vfs *SimpleVirtualFS) mountedNamesLocked() []string {
+	var names []string
+	for i := range vfs.mounts {
+		m := &vfs.mounts[i]
+		filepath.WalkDir(m.dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(m.dir, p)
+			if err != nil {
+				return nil
+			}
+			name := path.Join(m.prefix, filepath.ToSlash(rel))
+			// Files under a nested mount are listed by that mount
+			if vfs.mountOfLocked(name) == m {
+				names = append(names, name)
+			}
+			return nil
+		})
+	}
+	return names
+}
+
+// mountOfLocked returns the mount a cleaned name falls under, or nil
+func (vfs *SimpleVirtualFS) mountOfLocked(name string) *vfsMount {
+	for i, m := range vfs.mounts {
+		if name == m.prefix || strings.HasPrefix(name, m.prefix+"/") {
+			return &vfs.mounts[i]
+		}
+	}
+	return nil
+}
+
+// mountNote tells the LLM where the --mount directories are, or returns ""
+// without mounts. Only llmsh sees virtual files, so with spawn_shell=sh the
+// commands go through llmsh -c.
+func mountNote(mounts []cli.Mount, spawnShell string) string {
+	if len(mounts) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("MOUNTED DIRECTORIES (read-only virtual files, read from disk when opened):")
+	for _, mount := range mounts {
+		fmt.Fprintf(&b, "\n- %s/ = %s", virtualName(mount.Prefix), mount.Dir)
+	}
+	find, cat := "find PREFIX -type f", "grep -n PATTERN PREFIX/path"
+	if spawnShell != "llmsh" {
+		find, cat = "llmsh -c 'find PREFIX -type f'", "llmsh -c 'grep -n PATTERN PREFIX/path'"
+	}
+	fmt.Fprintf(&b, "\nList them with spawn(%q), read them with open(\"PREFIX/path\") or spawn(%q); they cannot be written or removed.", find, cat)
+	return b.String()
+}
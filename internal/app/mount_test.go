@@ -0,0 +1,85 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/mako10k/llmcmd/internal/cli"
+)
+
+func TestMountReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("alpha"), 0644)
+	os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("beta"), 0644)
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	os.WriteFile(outside, []byte("secret"), 0644)
+	os.Symlink(outside, filepath.Join(dir, "link.txt"))
+
+	vfs := NewSimpleVirtualFS()
+	if err := vfs.MountReadOnly(dir, "/repo/"); err != nil {
+		t.Fatalf("MountReadOnly() error = %v", err)
+	}
+	if err := vfs.MountReadOnly(dir, "repo"); err == nil {
+		t.Error("MountReadOnly() on a mounted prefix: expected error")
+	}
+	if err := vfs.MountReadOnly(filepath.Join(dir, "a.txt"), "file"); err == nil {
+		t.Error("MountReadOnly() of a file: expected error")
+	}
+
+	// Mounted files can be read any number of times
+	for i := 0; i < 2; i++ {
+		f, err := vfs.OpenFile("./repo/sub/../sub/b.txt", os.O_RDONLY, 0)
+		if err != nil {
+			t.Fatalf("OpenFile() error = %v", err)
+		}
+		if got, _ := io.ReadAll(f); string(got) != "beta" {
+			t.Errorf("read #%d = %q, want %q", i+1, got, "beta")
+		}
+		f.Close()
+	}
+	if size, err := vfs.FileSize("repo/a.txt"); err != nil || size != 5 {
+		t.Errorf("FileSize() = %d, %v; want 5", size, err)
+	}
+
+	names := vfs.FileNames()
+	sort.Strings(names)
+	if want := []string{"repo/a.txt", "repo/sub/b.txt"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("FileNames() = %v, want %v", names, want)
+	}
+
+	for _, flag := range []int{os.O_WRONLY | os.O_CREATE | os.O_TRUNC, os.O_RDWR, os.O_WRONLY | os.O_CREATE | os.O_APPEND} {
+		if _, err := vfs.OpenFile("repo/new.txt", flag, 0644); !errors.Is(err, syscall.EROFS) {
+			t.Errorf("OpenFile(flag %#x) under the mount: err = %v, want EROFS", flag, err)
+		}
+	}
+	if err := vfs.RemoveFile("repo/a.txt"); !errors.Is(err, syscall.EROFS) {
+		t.Errorf("RemoveFile() under the mount: err = %v, want EROFS", err)
+	}
+	if _, err := vfs.OpenFile("repo/link.txt", os.O_RDONLY, 0); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("OpenFile() of a symlink out of the mount: err = %v, want a permission error", err)
+	}
+	if _, err := vfs.OpenFile("repo/sub", os.O_RDONLY, 0); err == nil {
+		t.Error("OpenFile() of a mounted directory: expected error")
+	}
+}
+
+func TestMountNote(t *testing.T) {
+	if note := mountNote(nil, "sh"); note != "" {
+		t.Errorf("mountNote(nil) = %q, want empty", note)
+	}
+	mounts := []cli.Mount{{Prefix: "repo", Dir: "./src"}}
+	if note := mountNote(mounts, "sh"); !strings.Contains(note, "- repo/ = ./src") || !strings.Contains(note, "llmsh -c 'find PREFIX -type f'") {
+		t.Errorf("mountNote(sh) = %q", note)
+	}
+	if note := mountNote(mounts, "llmsh"); strings.Contains(note, "llmsh -c") {
+		t.Errorf("mountNote(llmsh) = %q, want commands without llmsh -c", note)
+	}
+}
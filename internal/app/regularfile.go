@@ -0,0 +1,130 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"time"
+)
+
+// regularFile is an open virtual file with regular file semantics, for files
+// opened with r+ or w+. Reads leave the data in place, Seek and ReadAt work,
+// and Stat reports a regular file so the read tool can page through it.
+type regularFile struct {
+	file   *VirtualFile
+	vfs    *SimpleVirtualFS
+	name   string
+	offset int64
+	append bool
+	closed bool
+}
+
+// Read implements io.Reader without consuming the file
+func (f *regularFile) Read(p []byte) (int, error) {
+	f.vfs.mutex.Lock()
+	defer f.vfs.mutex.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	n, err := f.file.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadAt implements io.ReaderAt
+func (f *regularFile) ReadAt(p []byte, off int64) (int, error) {
+	f.vfs.mutex.RLock()
+	defer f.vfs.mutex.RUnlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	return f.file.ReadAt(p, off)
+}
+
+// Write implements io.Writer at the file's offset, or at its end when opened
+// for appending, within the VFS limits
+func (f *regularFile) Write(p []byte) (int, error) {
+	f.vfs.mutex.Lock()
+	defer f.vfs.mutex.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if f.file.readOnly {
+		return 0, fmt.Errorf("virtual file '%s': %w", f.name, syscall.EROFS)
+	}
+	size := f.file.length()
+	if f.append {
+		f.offset = size
+	}
+	end := f.offset + int64(len(p))
+	if err := f.vfs.checkGrowthLocked(f.file, end); err != nil {
+		return 0, err
+	}
+	if err := f.file.writeAt(p, f.offset); err != nil {
+		return 0, err
+	}
+	f.offset = end
+	return len(p), nil
+}
+
+// Seek implements io.Seeker; seeking past the end is allowed, and a later
+// write fills the gap with zeros
+func (f *regularFile) Seek(offset int64, whence int) (int64, error) {
+	f.vfs.mutex.Lock()
+	defer f.vfs.mutex.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.file.length()
+	default:
+		return 0, fmt.Errorf("virtual file '%s': invalid whence %d", f.name, whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("virtual file '%s': negative offset", f.name)
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Stat describes the file as a regular file of its current size
+func (f *regularFile) Stat() (os.FileInfo, error) {
+	f.vfs.mutex.RLock()
+	defer f.vfs.mutex.RUnlock()
+	return virtualFileInfo{name: f.name, size: f.file.length(), mode: f.file.perm.Perm(), modTime: f.file.modified}, nil
+}
+
+// Close implements io.Closer; the data stays for later opens
+func (f *regularFile) Close() error {
+	f.vfs.mutex.Lock()
+	defer f.vfs.mutex.Unlock()
+	f.closed = true
+	return nil
+}
+
+// virtualFileInfo is the os.FileInfo of a regular virtual file
+type virtualFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (i virtualFileInfo) Name() string       { return i.name }
+func (i virtualFileInfo) Size() int64        { return i.size }
+func (i virtualFileInfo) Mode() os.FileMode  { return i.mode }
+func (i virtualFileInfo) ModTime() time.Time { return i.modTime }
+func (i virtualFileInfo) IsDir() bool        { return false }
+func (i virtualFileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,203 @@
+package app
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SaveSnapshot writes the virtual files that can still be read to a tar
+// archive at path, gzip-compressed when path ends in .gz or .tgz. Each entry
+// keeps the file's name, permissions and modification time, so a later run can pick up the
+// intermediate artifacts with LoadSnapshot. Read-only files fetched from URLs
+// are left out; the next run fetches them again.
+func (vfs *SimpleVirtualFS) SaveSnapshot(path string) (err error) {
+	// Hold the lock while writing, as spilled files are read from disk
+	vfs.mutex.RLock()
+	defer vfs.mutex.RUnlock()
+	names := make([]string, 0, len(vfs.files))
+	for name := range vfs.files {
+		if !vfs.consumed[name] && !vfs.files[name].readOnly {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to save VFS snapshot: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("failed to save VFS snapshot: %w", closeErr)
+		}
+	}()
+
+	var w io.Writer = file
+	var zw *gzip.Writer
+	if strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".tgz") {
+		zw = gzip.NewWriter(file)
+		w = zw
+	}
+
+	tw := tar.NewWriter(w)
+	for _, name := range names {
+		entry := vfs.files[name]
+		perm := entry.perm.Perm()
+		if perm == 0 {
+			perm = 0644
+		}
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     int64(perm),
+			Size:     entry.length(),
+			ModTime:  entry.modified,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to save VFS snapshot %s: %w", path, err)
+		}
+		if _, err := io.Copy(tw, io.NewSectionReader(entry, 0, entry.length())); err != nil {
+			return fmt.Errorf("failed to save VFS snapshot %s: %w", path, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to save VFS snapshot %s: %w", path, err)
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("failed to save VFS snapshot %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// LoadSnapshot adds the files of a tar archive written by SaveSnapshot to
+// the VFS, replacing files of the same name. Gzip-compressed archives are
+// recognized by their content rather than the file name.
+func (vfs *SimpleVirtualFS) LoadSnapshot(path string) (err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to load VFS snapshot: %w", err)
+	}
+	defer file.Close()
+
+	br := bufio.NewReader(file)
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("failed to load VFS snapshot %s: %w", path, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	// Read the whole archive before touching the VFS, so a corrupt
+	// snapshot leaves it unchanged; the files count their bytes in loadedBytes
+	// until then
+	loaded := make(map[string]*VirtualFile)
+	var loadedBytes int64
+	defer func() {
+		if err != nil {
+			for _, entry := range loaded {
+				entry.release()
+			}
+		}
+	}()
+	vfs.mutex.RLock()
+	spillAt := vfs.spillBytes
+	vfs.mutex.RUnlock()
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load VFS snapshot %s: %w", path, err)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeReg:
+		default:
+			return fmt.Errorf("failed to load VFS snapshot %s: %s: unsupported entry type", path, header.Name)
+		}
+		name := virtualName(header.Name)
+		if name == "" {
+			return fmt.Errorf("failed to load VFS snapshot %s: entry without a file name", path)
+		}
+		entry := &VirtualFile{
+			name:    name,
+			content: newFileContent(&loadedBytes, time.Now()),
+			flag:    os.O_RDWR,
+			perm:    os.FileMode(header.Mode).Perm(),
+			spillAt: spillAt,
+			created: time.Now(),
+		}
+		if old, exists := loaded[name]; exists {
+			old.release()
+		}
+		loaded[name] = entry
+		// Large entries spill to disk while they are read
+		if _, err := io.Copy(entry, tr); err != nil {
+			return fmt.Errorf("failed to load VFS snapshot %s: %w", path, err)
+		}
+		entry.offset = 0
+		entry.modified = header.ModTime
+	}
+
+	vfs.mutex.Lock()
+	defer vfs.mutex.Unlock()
+	for name, entry := range loaded {
+		if old, exists := vfs.files[name]; exists {
+			old.release()
+		}
+		delete(vfs.fifos, name)
+		entry.content.used = &vfs.used
+		vfs.files[name] = entry
+		vfs.used += entry.length()
+		delete(vfs.consumed, name)
+	}
+	return nil
+}
+
+// ExportFile copies a virtual file to the real file realPath without
+// consuming it, so outputs that were not declared with -o can still be kept
+func (vfs *SimpleVirtualFS) ExportFile(name, realPath string) error {
+	vfs.mutex.RLock()
+	defer vfs.mutex.RUnlock()
+	name = virtualName(name)
+	if vfs.consumed[name] {
+		return fmt.Errorf("failed to export virtual file '%s': already consumed (PIPE behavior - cannot read twice)", name)
+	}
+	file, exists := vfs.files[name]
+	if !exists {
+		return fmt.Errorf("failed to export virtual file '%s': %w", name, os.ErrNotExist)
+	}
+	perm := file.perm.Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+
+	out, err := os.OpenFile(realPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("failed to export virtual file '%s': %w", name, err)
+	}
+	_, err = io.Copy(out, io.NewSectionReader(file, 0, file.length()))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to export virtual file '%s': %w", name, err)
+	}
+	return nil
+}
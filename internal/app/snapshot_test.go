@@ -0,0 +1,162 @@
+package app
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/mako10k/llmcmd/internal/cli"
+	"github.com/mako10k/llmcmd/internal/tools"
+)
+
+func writeVirtualFile(t *testing.T, vfs *SimpleVirtualFS, name, content string, perm os.FileMode) {
+	t.Helper()
+	f, err := vfs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		t.Fatalf("OpenFile(%s) error = %v", name, err)
+	}
+	if _, err := io.WriteString(f, content); err != nil {
+		t.Fatalf("Write(%s) error = %v", name, err)
+	}
+	f.Close()
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	for _, name := range []string{"vfs.tar", "vfs.tgz"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+
+			src := NewSimpleVirtualFS()
+			writeVirtualFile(t, src, "out/report.txt", "report\n", 0600)
+			writeVirtualFile(t, src, "empty.txt", "", 0644)
+			writeVirtualFile(t, src, "read.txt", "gone", 0644)
+			f, _ := src.OpenFile("read.txt", os.O_RDONLY, 0)
+			io.ReadAll(f)
+
+			if err := src.SaveSnapshot(path); err != nil {
+				t.Fatalf("SaveSnapshot() error = %v", err)
+			}
+
+			dst := NewSimpleVirtualFS()
+			writeVirtualFile(t, dst, "out/report.txt", "old", 0644)
+			if err := dst.LoadSnapshot(path); err != nil {
+				t.Fatalf("LoadSnapshot() error = %v", err)
+			}
+
+			names := dst.FileNames()
+			sort.Strings(names)
+			if want := []string{"empty.txt", "out/report.txt"}; !reflect.DeepEqual(names, want) {
+				t.Errorf("FileNames() = %v, want %v (consumed files are not saved)", names, want)
+			}
+			f, err := dst.OpenFile("./out/report.txt", os.O_RDONLY, 0)
+			if err != nil {
+				t.Fatalf("OpenFile() error = %v", err)
+			}
+			if got, _ := io.ReadAll(f); string(got) != "report\n" {
+				t.Errorf("content = %q, want %q", got, "report\n")
+			}
+			if perm := dst.files["out/report.txt"].perm; perm != 0600 {
+				t.Errorf("perm = %v, want 0600", perm)
+			}
+		})
+	}
+}
+
+func TestLoadSnapshotErrors(t *testing.T) {
+	dir := t.TempDir()
+	vfs := NewSimpleVirtualFS()
+	writeVirtualFile(t, vfs, "keep.txt", "keep", 0644)
+
+	if err := vfs.LoadSnapshot(filepath.Join(dir, "missing.tar")); err == nil {
+		t.Error("LoadSnapshot() of a missing file: expected error")
+	}
+
+	corrupt := filepath.Join(dir, "corrupt.tgz")
+	os.WriteFile(corrupt, []byte{0x1f, 0x8b, 0, 1, 2, 3}, 0644)
+	if err := vfs.LoadSnapshot(corrupt); err == nil {
+		t.Error("LoadSnapshot() of a corrupt archive: expected error")
+	}
+
+	if names := vfs.FileNames(); !reflect.DeepEqual(names, []string{"keep.txt"}) {
+		t.Errorf("FileNames() after failed loads = %v, want [keep.txt]", names)
+	}
+}
+
+func TestExportFile(t *testing.T) {
+	dir := t.TempDir()
+	vfs := NewSimpleVirtualFS()
+	writeVirtualFile(t, vfs, "out/result.txt", "result\n", 0600)
+
+	target := filepath.Join(dir, "result.txt")
+	if err := vfs.ExportFile("/out/result.txt", target); err != nil {
+		t.Fatalf("ExportFile() error = %v", err)
+	}
+	got, err := os.ReadFile(target)
+	if err != nil || string(got) != "result\n" {
+		t.Errorf("exported content = %q, %v; want %q", got, err, "result\n")
+	}
+	if info, err := os.Stat(target); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("exported file mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+	// Exporting does not consume the file
+	if names := vfs.FileNames(); !reflect.DeepEqual(names, []string{"out/result.txt"}) {
+		t.Errorf("FileNames() after export = %v", names)
+	}
+
+	if err := vfs.ExportFile("missing.txt", filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("ExportFile() of a missing file: expected error")
+	}
+	f, _ := vfs.OpenFile("out/result.txt", os.O_RDONLY, 0)
+	io.ReadAll(f)
+	if err := vfs.ExportFile("out/result.txt", target); err == nil {
+		t.Error("ExportFile() of a consumed file: expected error")
+	}
+}
+
+func TestDryRunSkipsExports(t *testing.T) {
+	dir := t.TempDir()
+	vfs := NewSimpleVirtualFS()
+	writeVirtualFile(t, vfs, "result.txt", "result\n", 0644)
+	engine, err := tools.NewEngine(tools.EngineConfig{
+		DryRun:        true,
+		NoStdin:       true,
+		ShellExecutor: &SimpleShellExecutor{},
+		VirtualFS:     vfs,
+	})
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	defer engine.Close()
+
+	target, snapshot := filepath.Join(dir, "result.txt"), filepath.Join(dir, "vfs.tar")
+	a := &App{
+		config: &cli.Config{
+			DryRun:  true,
+			Exports: []cli.Export{{Name: "result.txt", Path: target}},
+			VFSSave: snapshot,
+		},
+		toolEngine: engine,
+		virtualFS:  vfs,
+	}
+	if err := a.persistVirtualFiles(); err != nil {
+		t.Fatalf("persistVirtualFiles() error = %v", err)
+	}
+	for _, path := range []string{target, snapshot} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Stat(%s) error = %v, want the file not to exist", path, err)
+		}
+	}
+
+	var plan bytes.Buffer
+	a.showDryRunPlan(&plan)
+	want := "Dry run: 2 action(s) not performed\n" +
+		"  1. export: write virtual file 'result.txt' to '" + target + "'\n" +
+		"  2. vfs-save: save the virtual files to '" + snapshot + "'\n"
+	if plan.String() != want {
+		t.Errorf("dry-run plan = %q, want %q", plan.String(), want)
+	}
+}
@@ -0,0 +1,290 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A virtual file keeps its data in memory until it grows past the spill
+// threshold (vfs_spill_bytes); the data then moves to an anonymous temporary
+// file, so multi-GB intermediates cost disk space instead of memory. Clones
+// share the data until one of them writes. The methods below are the only
+// ones that touch the storage, and like the rest of VirtualFile they run with
+// the VFS mutex held. Readers may hold it only for reading, so decompressing
+// on a read, the one change a read makes, also takes the content's own lock.
+
+// fileContent is the data of one virtual file, or of several after Clone
+type fileContent struct {
+	mu         sync.Mutex // Guards data, packed and packedSize against touch
+	data       []byte     // Contents while in memory, nil once spilled or compressed
+	spill      *os.File
+	spillSize  int64        // Size of the contents in spill
+	packed     []byte       // Compressed contents of an idle file
+	packedSize int64        // Size of the contents in packed
+	accessed   atomic.Int64 // When the content was last used, in Unix nanoseconds
+	refs       int          // Number of files sharing the content
+	used       *int64       // The VFS's count of bytes held, kept up to date here
+}
+
+// newFileContent returns an empty content counted in used
+func newFileContent(used *int64, accessed time.Time) *fileContent {
+	c := &fileContent{data: []byte{}, refs: 1, used: used}
+	c.accessed.Store(accessed.UnixNano())
+	return c
+}
+
+// size returns the number of bytes in the content
+func (c *fileContent) size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case c.spill != nil:
+		return c.spillSize
+	case c.packed != nil:
+		return c.packedSize
+	}
+	return int64(len(c.data))
+}
+
+// SetSpillThreshold makes files that grow past bytes move their data to a
+// temporary file; 0 keeps all data in memory
+func (vfs *SimpleVirtualFS) SetSpillThreshold(bytes int64) {
+	vfs.mutex.Lock()
+	defer vfs.mutex.Unlock()
+	vfs.spillBytes = bytes
+}
+
+// newVirtualFile returns an empty file that spills at the VFS's threshold
+func (vfs *SimpleVirtualFS) newVirtualFile(name string, flag int, perm os.FileMode) *VirtualFile {
+	now := time.Now()
+	return &VirtualFile{
+		name:     name,
+		content:  newFileContent(&vfs.used, now),
+		flag:     flag,
+		perm:     perm,
+		spillAt:  vfs.spillBytes,
+		created:  now,
+		modified: now,
+	}
+}
+
+// length returns the number of bytes in the file
+func (f *VirtualFile) length() int64 {
+	return f.content.size()
+}
+
+// shared reports whether the file's content is shared with a clone
+func (f *VirtualFile) shared() bool {
+	return f.content.refs > 1
+}
+
+// ReadAt implements io.ReaderAt over the file's data wherever it is stored
+func (f *VirtualFile) ReadAt(p []byte, off int64) (int, error) {
+	size := f.length()
+	if off < 0 {
+		return 0, fmt.Errorf("virtual file '%s': negative offset", f.name)
+	}
+	if off >= size {
+		return 0, io.EOF
+	}
+	if err := f.content.touch(); err != nil {
+		return 0, fmt.Errorf("virtual file '%s': %w", f.name, err)
+	}
+	short := int64(len(p)) > size-off
+	if short {
+		p = p[:size-off]
+	}
+	var n int
+	if f.content.spill != nil {
+		var err error
+		if n, err = f.content.spill.ReadAt(p, off); err != nil {
+			return n, fmt.Errorf("virtual file '%s': %w", f.name, err)
+		}
+	} else {
+		n = copy(p, f.content.data[off:])
+	}
+	if short {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// writeAt stores p at off, filling any gap with zeros. A shared content is
+// copied first, and the data moves to a temporary file when the file
+// outgrows spillAt.
+func (f *VirtualFile) writeAt(p []byte, off int64) error {
+	if err := f.content.touch(); err != nil {
+		return fmt.Errorf("virtual file '%s': %w", f.name, err)
+	}
+	if f.shared() {
+		if err := f.unshare(); err != nil {
+			return err
+		}
+	}
+	c := f.content
+	end := off + int64(len(p))
+	if c.spill == nil && f.spillAt > 0 && end > f.spillAt {
+		if err := f.spillToDisk(); err != nil {
+			return err
+		}
+	}
+	size := c.size()
+	if c.spill != nil {
+		if _, err := c.spill.WriteAt(p, off); err != nil {
+			return fmt.Errorf("virtual file '%s': %w", f.name, err)
+		}
+		if end > c.spillSize {
+			c.spillSize = end
+		}
+	} else {
+		if end > size {
+			c.data = append(c.data, make([]byte, end-size)...)
+		}
+		copy(c.data[off:], p)
+	}
+	*c.used += c.size() - size
+	f.modified = time.Now()
+	return nil
+}
+
+// unshare gives the file its own copy of a content shared with clones
+func (f *VirtualFile) unshare() error {
+	old := f.content
+	c := &fileContent{refs: 1, used: old.used}
+	c.accessed.Store(old.accessed.Load())
+	if old.spill != nil {
+		file, err := createSpillFile()
+		if err != nil {
+			return fmt.Errorf("virtual file '%s': cannot copy to disk: %w", f.name, err)
+		}
+		if _, err := io.Copy(file, io.NewSectionReader(old.spill, 0, old.spillSize)); err != nil {
+			closeSpillFile(file)
+			return fmt.Errorf("virtual file '%s': cannot copy to disk: %w", f.name, err)
+		}
+		c.spill, c.spillSize = file, old.spillSize
+	} else {
+		c.data = append([]byte{}, old.data...)
+	}
+	old.refs--
+	f.content = c
+	*c.used += c.size()
+	return nil
+}
+
+// spillToDisk moves the file's in-memory data to a new temporary file
+func (f *VirtualFile) spillToDisk() error {
+	c := f.content
+	file, err := createSpillFile()
+	if err != nil {
+		return fmt.Errorf("virtual file '%s': cannot spill to disk: %w", f.name, err)
+	}
+	if _, err := file.Write(c.data); err != nil {
+		closeSpillFile(file)
+		return fmt.Errorf("virtual file '%s': cannot spill to disk: %w", f.name, err)
+	}
+	c.spill = file
+	c.spillSize = int64(len(c.data))
+	c.data = nil
+	return nil
+}
+
+// truncate empties the file, giving back its content
+func (f *VirtualFile) truncate() {
+	f.release()
+	f.content.data = []byte{}
+	f.modified = time.Now()
+}
+
+// truncateTo cuts the file to size bytes or extends it with zeros, copying a
+// shared content first
+func (f *VirtualFile) truncateTo(size int64) error {
+	if size == 0 {
+		f.truncate()
+		return nil
+	}
+	if err := f.content.touch(); err != nil {
+		return fmt.Errorf("virtual file '%s': %w", f.name, err)
+	}
+	if f.shared() {
+		if err := f.unshare(); err != nil {
+			return err
+		}
+	}
+	c := f.content
+	old := c.size()
+	if c.spill == nil && f.spillAt > 0 && size > f.spillAt {
+		if err := f.spillToDisk(); err != nil {
+			return err
+		}
+	}
+	switch {
+	case c.spill != nil:
+		if err := c.spill.Truncate(size); err != nil {
+			return fmt.Errorf("virtual file '%s': %w", f.name, err)
+		}
+		c.spillSize = size
+	case size < old:
+		c.data = append([]byte{}, c.data[:size]...)
+	default:
+		c.data = append(c.data, make([]byte, size-old)...)
+	}
+	*c.used += size - old
+	f.modified = time.Now()
+	return nil
+}
+
+// release drops the file's data. The content is freed, and its temporary
+// file closed, when no clone shares it any more.
+func (f *VirtualFile) release() {
+	old := f.content
+	f.content = newFileContent(old.used, time.Now())
+	if old.refs--; old.refs > 0 {
+		return
+	}
+	*old.used -= old.size()
+	if old.spill != nil {
+		closeSpillFile(old.spill)
+	}
+}
+
+// namedSpills are the spill files whose names could not be removed while
+// they were open, as on Windows, by file; closeSpillFile removes them
+var namedSpills sync.Map
+
+// createUnlinkedTemp creates a temporary file and removes its name, so
+// nothing is left behind once it is closed. Where an open file cannot be
+// removed, the name stays until closeSpillFile or removeNamedSpills.
+func createUnlinkedTemp() (*os.File, error) {
+	file, err := os.CreateTemp("", "llmcmd-vfs-*")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(file.Name()); err != nil {
+		namedSpills.Store(file, file.Name())
+	}
+	return file, nil
+}
+
+// closeSpillFile closes a spill file and removes its name if it still has one
+func closeSpillFile(file *os.File) error {
+	err := file.Close()
+	if name, ok := namedSpills.LoadAndDelete(file); ok {
+		if removeErr := os.Remove(name.(string)); err == nil {
+			err = removeErr
+		}
+	}
+	return err
+}
+
+// removeNamedSpills closes and removes the spill files that still have
+// names, for the end of the run; their virtual files are unusable after it
+func removeNamedSpills() {
+	namedSpills.Range(func(file, _ any) bool {
+		closeSpillFile(file.(*os.File))
+		return true
+	})
+}
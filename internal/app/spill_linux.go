@@ -0,0 +1,21 @@
+//go:build linux
+
+package app
+
+import (
+	"os"
+	"syscall"
+)
+
+// oTmpfile is O_TMPFILE, which the syscall package does not define
+const oTmpfile = 0x400000 | syscall.O_DIRECTORY
+
+// createSpillFile returns an anonymous file in the temporary directory that
+// the kernel frees when it is closed, even if the process is killed. File
+// systems without O_TMPFILE get a temporary file that is unlinked at once.
+func createSpillFile() (*os.File, error) {
+	if file, err := os.OpenFile(os.TempDir(), oTmpfile|os.O_RDWR, 0600); err == nil {
+		return file, nil
+	}
+	return createUnlinkedTemp()
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package app
+
+import "os"
+
+// createSpillFile returns a temporary file that is unlinked at once where
+// the platform allows it
+func createSpillFile() (*os.File, error) {
+	return createUnlinkedTemp()
+}
@@ -0,0 +1,226 @@
+package app
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mako10k/llmcmd/internal/buildinfo"
+	"github.com/mako10k/llmcmd/internal/tools"
+)
+
+// statsField is a single named value in the statistics report
+type statsField struct {
+	Section string
+	Name    string
+	Value   interface{}
+}
+
+// collectStatistics gathers the end-of-run statistics as machine-readable fields
+func (a *App) collectStatistics() []statsField {
+	duration := time.Since(a.startTime)
+	openaiStats := a.openaiClient.GetStats()
+	toolStats := a.toolEngine.GetStats()
+	totalToolCalls := toolStats.ReadCalls + toolStats.WriteCalls + toolStats.SpawnCalls + toolStats.ExitCalls
+	build := buildinfo.Get("llmcmd")
+
+	fields := []statsField{
+		{"timing", "started_at", a.startTime.Format(time.RFC3339)},
+		{"timing", "duration_ms", duration.Milliseconds()},
+		{"timing", "avg_api_duration_ms", (openaiStats.TotalDuration / time.Duration(max(openaiStats.RequestCount, 1))).Milliseconds()},
+		{"timing", "iterations", a.iterationCount},
+		{"api", "calls", openaiStats.RequestCount},
+		{"api", "max_calls", a.fileConfig.MaxAPICalls},
+		{"api", "retries", openaiStats.RetryCount},
+		{"api", "failovers", openaiStats.FailoverCount},
+		{"api", "continuations", a.continuationCount},
+		{"api", "total_tokens", openaiStats.TotalTokens},
+		{"api", "prompt_tokens", openaiStats.PromptTokens},
+		{"api", "completion_tokens", openaiStats.CompletionTokens},
+		{"api", "errors", openaiStats.ErrorCount},
+		{"tools", "read_calls", toolStats.ReadCalls},
+		{"tools", "write_calls", toolStats.WriteCalls},
+		{"tools", "spawn_calls", toolStats.SpawnCalls},
+		{"tools", "wait_calls", toolStats.WaitCalls},
+		{"tools", "close_calls", toolStats.CloseCalls},
+		{"tools", "exit_calls", toolStats.ExitCalls},
+		{"tools", "total_calls", totalToolCalls},
+		{"tools", "bytes_read", toolStats.BytesRead},
+		{"tools", "bytes_written", toolStats.BytesWritten},
+		{"tools", "errors", toolStats.ErrorCount},
+		{"config", "model", a.fileConfig.Model},
+		{"config", "max_tokens", a.fileConfig.MaxTokens},
+		{"config", "temperature", a.fileConfig.Temperature},
+		{"config", "input_files", len(a.config.InputFiles)},
+		{"config", "buffer_size", a.fileConfig.ReadBufferSize},
+		{"build", "version", build.Version},
+		{"build", "commit", build.Commit},
+		{"build", "date", build.Date},
+		{"build", "go_version", build.GoVersion},
+	}
+	for _, name := range toolsByLatency(toolStats.Tools) {
+		latency := toolStats.Tools[name]
+		fields = append(fields,
+			statsField{"tool_latency", name + "_calls", latency.Calls},
+			statsField{"tool_latency", name + "_total_ms", latency.TotalLatency.Milliseconds()},
+			statsField{"tool_latency", name + "_max_ms", latency.MaxLatency.Milliseconds()},
+		)
+	}
+	return fields
+}
+
+// toolsByLatency returns the tool names ordered by total latency, slowest first
+func toolsByLatency(latencies map[string]tools.ToolLatency) []string {
+	names := make([]string, 0, len(latencies))
+	for name := range latencies {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := latencies[names[i]], latencies[names[j]]
+		if a.TotalLatency != b.TotalLatency {
+			return a.TotalLatency > b.TotalLatency
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// writeStatsJSON writes the statistics as a JSON object grouped by section
+func writeStatsJSON(w io.Writer, fields []statsField) error {
+	report := make(map[string]map[string]interface{})
+	for _, field := range fields {
+		if report[field.Section] == nil {
+			report[field.Section] = make(map[string]interface{})
+		}
+		report[field.Section][field.Name] = field.Value
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// writeStatsCSV writes the statistics as section,metric,value rows
+func writeStatsCSV(w io.Writer, fields []statsField) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"section", "metric", "value"}); err != nil {
+		return err
+	}
+	for _, field := range fields {
+		if err := writer.Write([]string{field.Section, field.Name, fmt.Sprint(field.Value)}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// displayLocale holds the number and date conventions for the table statistics
+type displayLocale struct {
+	groupSeparator string // Thousands separator, empty for none
+	decimalMark    string
+	dateLayout     string
+}
+
+// currentLocale derives display conventions from LC_ALL, LC_NUMERIC or LANG
+func currentLocale() displayLocale {
+	name := ""
+	for _, key := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		if value := os.Getenv(key); value != "" {
+			name = value
+			break
+		}
+	}
+	return localeFor(name)
+}
+
+// localeFor maps a POSIX locale name such as "de_DE.UTF-8" to display conventions
+func localeFor(name string) displayLocale {
+	// Strip encoding and modifier: "de_DE.UTF-8@euro" -> "de_DE"
+	if i := strings.IndexAny(name, ".@"); i >= 0 {
+		name = name[:i]
+	}
+	lang, region, _ := strings.Cut(name, "_")
+
+	switch lang {
+	case "en":
+		if region == "US" {
+			return displayLocale{",", ".", "01/02/2006 15:04:05"}
+		}
+		return displayLocale{",", ".", "02/01/2006 15:04:05"}
+	case "ja", "zh", "ko":
+		return displayLocale{",", ".", "2006/01/02 15:04:05"}
+	case "de", "nl", "da", "id", "tr":
+		return displayLocale{".", ",", "02.01.2006 15:04:05"}
+	case "es", "it", "pt":
+		return displayLocale{".", ",", "02/01/2006 15:04:05"}
+	case "fr", "ru", "pl", "cs", "sv", "fi", "nb", "uk":
+		return displayLocale{" ", ",", "02.01.2006 15:04:05"}
+	default:
+		// C/POSIX and unknown locales keep plain, unambiguous formatting
+		return displayLocale{"", ".", "2006-01-02 15:04:05"}
+	}
+}
+
+// formatInt formats an integer with the locale's thousands separator
+func (l displayLocale) formatInt(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if l.groupSeparator == "" || len(digits) <= 3 {
+		return sign + digits
+	}
+
+	var b strings.Builder
+	head := len(digits) % 3
+	if head > 0 {
+		b.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(l.groupSeparator)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return sign + b.String()
+}
+
+// formatFloat formats a float with the given precision and the locale's decimal mark
+func (l displayLocale) formatFloat(f float64, precision int) string {
+	s := strconv.FormatFloat(f, 'f', precision, 64)
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	n, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return s
+	}
+	whole = l.formatInt(n)
+	if n == 0 && f < 0 {
+		whole = "-" + whole
+	}
+	if !hasFrac {
+		return whole
+	}
+	return whole + l.decimalMark + frac
+}
+
+// formatBytes formats byte counts in human-readable format
+func (l displayLocale) formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%s B", l.formatInt(bytes))
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%s %cB", l.formatFloat(float64(bytes)/float64(div), 1), "KMGTPE"[exp])
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mako10k/llmcmd/internal/tools"
+)
+
+// traceHook writes tool events as JSON lines for --trace
+type traceHook struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// traceRecord is one line of --trace output
+type traceRecord struct {
+	Event      string `json:"event"` // tool_start or tool_end
+	Time       string `json:"time"`
+	Tool       string `json:"tool"`
+	ArgsDigest string `json:"args_digest"`
+	DurationMs *int64 `json:"duration_ms,omitempty"`
+	Bytes      *int64 `json:"bytes,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func (h *traceHook) OnToolStart(event tools.ToolEvent) {
+	h.write(traceRecord{
+		Event:      "tool_start",
+		Time:       event.Start.Format(time.RFC3339Nano),
+		Tool:       event.Tool,
+		ArgsDigest: event.ArgsDigest,
+	})
+}
+
+func (h *traceHook) OnToolEnd(event tools.ToolEvent) {
+	duration := event.Duration.Milliseconds()
+	record := traceRecord{
+		Event:      "tool_end",
+		Time:       event.Start.Add(event.Duration).Format(time.RFC3339Nano),
+		Tool:       event.Tool,
+		ArgsDigest: event.ArgsDigest,
+		DurationMs: &duration,
+		Bytes:      &event.Bytes,
+	}
+	if event.Err != nil {
+		record.Error = event.Err.Error()
+	}
+	h.write(record)
+}
+
+// write encodes one record per line; trace output is best effort
+func (h *traceHook) write(record traceRecord) {
+	line, _ := json.Marshal(record)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.w.Write(append(line, '\n'))
+}
+
+// verboseHook logs tool calls and results for -v
+type verboseHook struct{}
+
+func (verboseHook) OnToolStart(event tools.ToolEvent) {
+	log.Printf("Executing tool: %s with args: %s", event.Tool, event.Args)
+}
+
+func (verboseHook) OnToolEnd(event tools.ToolEvent) {
+	if event.Err != nil {
+		log.Printf("Tool %s failed after %v: %v", event.Tool, event.Duration.Round(time.Millisecond), event.Err)
+		return
+	}
+	log.Printf("Tool result (%v): %s", event.Duration.Round(time.Millisecond), event.Result)
+}
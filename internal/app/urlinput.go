@@ -0,0 +1,189 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// urlFetchTimeout bounds the download of one --allow-url input
+const urlFetchTimeout = 60 * time.Second
+
+// newURLClient returns the HTTP client --allow-url inputs are fetched with.
+// Redirects are followed only to other https:// URLs.
+func newURLClient() *http.Client {
+	return &http.Client{
+		Timeout: urlFetchTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to %s: only https:// URLs can be fetched", req.URL.Redacted())
+			}
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// urlInputName returns the virtual file a URL is fetched into: its host and
+// path, so https://example.com/logs/app.log becomes example.com/logs/app.log.
+// A URL without a path becomes HOST/index. The query is not part of the name.
+func urlInputName(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("%s: URL without a host", rawURL)
+	}
+	host := virtualName(u.Host)
+	name := virtualName(host + "/" + u.Path)
+	if name == host {
+		name += "/index"
+	}
+	return name, nil
+}
+
+// redactURL hides the password of a URL meant for messages and logs
+func redactURL(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Redacted()
+	}
+	return rawURL
+}
+
+// fetchURL downloads a text file, refusing responses other than 200 OK and
+// bodies larger than maxBytes (0 for no limit). It returns the body and the
+// time the server says it was last modified, or now when it does not say.
+func fetchURL(client *http.Client, rawURL string, maxBytes int64) ([]byte, time.Time, error) {
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to fetch input: %w", err)
+	}
+	defer resp.Body.Close()
+
+	redacted := resp.Request.URL.Redacted()
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, fmt.Errorf("failed to fetch input %s: %s", redacted, resp.Status)
+	}
+	tooLarge := fmt.Errorf("failed to fetch input %s: %w (url_max_bytes is %d)", redacted, syscall.EFBIG, maxBytes)
+	var body io.Reader = resp.Body
+	if maxBytes > 0 {
+		if resp.ContentLength > maxBytes {
+			return nil, time.Time{}, tooLarge
+		}
+		body = io.LimitReader(resp.Body, maxBytes+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to fetch input %s: %w", redacted, err)
+	}
+	if maxBytes > 0 && int64(len(data)) > maxBytes {
+		return nil, time.Time{}, tooLarge
+	}
+	// Same check as for -i files: a NUL byte near the start means binary data
+	if bytes.IndexByte(data[:min(len(data), 512)], 0) >= 0 {
+		return nil, time.Time{}, fmt.Errorf("binary file detected: %s - llmcmd only supports text files for security and cost reasons", redacted)
+	}
+
+	modTime, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil {
+		modTime = time.Now()
+	}
+	return data, modTime, nil
+}
+
+// AddReadOnlyFile adds a file holding data, for inputs fetched with
+// --allow-url. It can be read any number of times, like a file opened with
+// r+, but writing, replacing or removing it fails with EROFS. The file
+// counts against the VFS limits, and name must not exist yet.
+func (vfs *SimpleVirtualFS) AddReadOnlyFile(name string, data []byte, modTime time.Time) error {
+	vfs.mutex.Lock()
+	defer vfs.mutex.Unlock()
+	name = virtualName(name)
+
+	if name == "" {
+		return fmt.Errorf("virtual file '/': %w", os.ErrExist)
+	}
+	if vfs.mountOfLocked(name) != nil {
+		return fmt.Errorf("virtual file '%s': %w", name, syscall.EROFS)
+	}
+	if _, exists := vfs.files[name]; exists {
+		return fmt.Errorf("virtual file '%s': %w", name, os.ErrExist)
+	}
+	if _, exists := vfs.fifos[name]; exists {
+		return fmt.Errorf("virtual file '%s': %w", name, os.ErrExist)
+	}
+	if err := vfs.checkNewFileLocked(name); err != nil {
+		return err
+	}
+	file := vfs.newVirtualFile(name, os.O_RDONLY, 0444)
+	if err := vfs.checkGrowthLocked(file, int64(len(data))); err != nil {
+		return err
+	}
+	if err := file.writeAt(data, 0); err != nil {
+		file.release()
+		return err
+	}
+	file.modified = modTime
+	file.regular = true
+	file.readOnly = true
+	vfs.files[name] = file
+	delete(vfs.consumed, name)
+	return nil
+}
+
+// fetchURLInputs fetches the --allow-url inputs into read-only virtual files
+// named by urlInputName
+func (a *App) fetchURLInputs(vfs *SimpleVirtualFS, client *http.Client) error {
+	for _, rawURL := range a.config.URLInputs {
+		name, err := urlInputName(rawURL)
+		if err != nil {
+			return fmt.Errorf("invalid input URL: %w", err)
+		}
+		data, modTime, err := fetchURL(client, rawURL, a.fileConfig.URLMaxBytes)
+		if err != nil {
+			return err
+		}
+		if err := vfs.AddReadOnlyFile(name, data, modTime); err != nil {
+			return fmt.Errorf("input %s: %w", redactURL(rawURL), err)
+		}
+		if a.config.Verbose {
+			log.Printf("Fetched %s into virtual file %s (%d bytes)", redactURL(rawURL), name, len(data))
+		}
+	}
+	return nil
+}
+
+// urlNote tells the LLM which virtual files hold the --allow-url inputs, or
+// returns "" without them. As with mountNote, scripts run by sh reach virtual
+// files through llmsh -c.
+func urlNote(urls []string, spawnShell string) string {
+	if len(urls) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("URL INPUTS (read-only virtual files fetched before the run):")
+	for _, rawURL := range urls {
+		name, err := urlInputName(rawURL)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&b, "\n- %s = %s", name, redactURL(rawURL))
+	}
+	cat := "grep -n PATTERN NAME"
+	if spawnShell != "llmsh" {
+		cat = "llmsh -c 'grep -n PATTERN NAME'"
+	}
+	fmt.Fprintf(&b, "\nRead them with open(\"NAME\") or spawn(%q) any number of times; they cannot be written or removed.", cat)
+	return b.String()
+}
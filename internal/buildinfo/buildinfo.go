@@ -0,0 +1,71 @@
+// Package buildinfo holds the version information shared by llmcmd and llmsh.
+//
+// Release builds set the variables with ldflags, e.g.
+//
+//	-X github.com/mako10k/llmcmd/internal/buildinfo.Version=v3.2.0
+//	-X github.com/mako10k/llmcmd/internal/buildinfo.Commit=abc1234
+//	-X github.com/mako10k/llmcmd/internal/buildinfo.Date=2026-01-02T15:04:05Z
+package buildinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build variables, overridden by build-time ldflags
+var (
+	Version = "3.1.1"
+	Commit  = "unknown"
+	Date    = "unknown"
+)
+
+// Info describes the running binary
+type Info struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Get returns the build information for the named binary. Builds without
+// ldflags (go install, go run) fall back to the VCS stamp recorded by Go.
+func Get(name string) Info {
+	info := Info{
+		Name:      name,
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "unknown":
+				info.Commit = setting.Value
+				if len(info.Commit) > 12 {
+					info.Commit = info.Commit[:12]
+				}
+			case setting.Key == "vcs.time" && info.Date == "unknown":
+				info.Date = setting.Value
+			}
+		}
+	}
+	return info
+}
+
+// String returns the one-line form printed by --version
+func (i Info) String() string {
+	return fmt.Sprintf("%s version %s (commit %s, built %s, %s, %s)", i.Name, i.Version, i.Commit, i.Date, i.GoVersion, i.Platform)
+}
+
+// JSON returns the indented JSON form printed by --version --json
+func (i Info) JSON() string {
+	data, _ := json.MarshalIndent(i, "", "  ")
+	return string(data)
+}
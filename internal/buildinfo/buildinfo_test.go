@@ -0,0 +1,30 @@
+package buildinfo
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestInfoFormats(t *testing.T) {
+	info := Info{Name: "llmcmd", Version: "v1.2.3", Commit: "abc1234", Date: "2026-01-02", GoVersion: "go1.22.0", Platform: "linux/amd64"}
+
+	if got := info.String(); !strings.HasPrefix(got, "llmcmd version v1.2.3 (commit abc1234") {
+		t.Errorf("String() = %q", got)
+	}
+
+	var decoded Info
+	if err := json.Unmarshal([]byte(info.JSON()), &decoded); err != nil {
+		t.Fatalf("JSON() is not valid JSON: %v", err)
+	}
+	if decoded != info {
+		t.Errorf("JSON() round trip = %+v, want %+v", decoded, info)
+	}
+}
+
+func TestGetUsesBuildVariables(t *testing.T) {
+	info := Get("llmsh")
+	if info.Name != "llmsh" || info.Version != Version || info.GoVersion == "" || info.Platform == "" {
+		t.Errorf("Get() = %+v", info)
+	}
+}
@@ -49,22 +49,38 @@ type QuotaUsage struct {
 
 // ConfigFile represents configuration loaded from file
 type ConfigFile struct {
-	OpenAIAPIKey   string                  `json:"openai_api_key"`
-	OpenAIBaseURL  string                  `json:"openai_base_url"`
-	Model          string                  `json:"model"`          // Primary model for external llmcmd calls
-	InternalModel  string                  `json:"internal_model"` // Model for internal llmcmd calls from llmsh
-	MaxTokens      int                     `json:"max_tokens"`
-	Temperature    float64                 `json:"temperature"`
-	MaxAPICalls    int                     `json:"max_api_calls"`
-	TimeoutSeconds int                     `json:"timeout_seconds"`
-	MaxFileSize    int64                   `json:"max_file_size"`
-	ReadBufferSize int                     `json:"read_buffer_size"`
-	MaxRetries     int                     `json:"max_retries"`
-	RetryDelay     int                     `json:"retry_delay_ms"`
-	SystemPrompt   string                  `json:"system_prompt"`
-	DefaultPrompt  string                  `json:"default_prompt"`
-	DisableTools   bool                    `json:"disable_tools"`
-	PromptPresets  map[string]PromptPreset `json:"prompt_presets"`
+	OpenAIAPIKey          string                  `json:"openai_api_key"`
+	OpenAIBaseURL         string                  `json:"openai_base_url"`
+	FallbackBaseURLs      []string                `json:"fallback_base_urls"` // Secondary providers used while the primary is unhealthy
+	FallbackAPIKey        string                  `json:"fallback_api_key"`   // API key for the fallback providers, defaults to openai_api_key
+	Model                 string                  `json:"model"`              // Primary model for external llmcmd calls
+	InternalModel         string                  `json:"internal_model"`     // Model for internal llmcmd calls from llmsh
+	MaxTokens             int                     `json:"max_tokens"`
+	Temperature           float64                 `json:"temperature"`
+	MaxAPICalls           int                     `json:"max_api_calls"`
+	TimeoutSeconds        int                     `json:"timeout_seconds"`
+	SpawnTimeoutSeconds   int                     `json:"spawn_timeout_seconds"` // Per-spawn wall-clock limit, 0 for none
+	SpawnCPUSeconds       int                     `json:"spawn_cpu_seconds"`     // RLIMIT_CPU for spawned scripts, 0 for none
+	SpawnMemoryMB         int                     `json:"spawn_memory_mb"`       // RLIMIT_AS for spawned scripts, 0 for none
+	SpawnMaxOpenFiles     int                     `json:"spawn_max_open_files"`  // RLIMIT_NOFILE for spawned scripts, 0 for none
+	SpawnShell            string                  `json:"spawn_shell"`           // Shell for spawned scripts: "sh" or the in-process "llmsh"
+	MaxFileSize           int64                   `json:"max_file_size"`
+	ReadBufferSize        int                     `json:"read_buffer_size"`
+	ResultHandleThreshold int                     `json:"result_handle_threshold"` // Tool results above this many bytes become fetch handles, 0 to disable
+	VFSMaxTotalBytes      int64                   `json:"vfs_max_total_bytes"`     // Bytes all virtual files may hold together, 0 for no limit
+	VFSMaxFileBytes       int64                   `json:"vfs_max_file_bytes"`      // Bytes one virtual file may hold, 0 for no limit
+	VFSMaxFiles           int                     `json:"vfs_max_files"`           // Number of virtual files, 0 for no limit
+	VFSSpillBytes         int64                   `json:"vfs_spill_bytes"`         // Size past which a virtual file moves to a temporary file, 0 to keep all in memory
+	VFSCompressBytes      int64                   `json:"vfs_compress_bytes"`      // Size from which idle virtual files are compressed, 0 to disable
+	VFSIdleSeconds        int                     `json:"vfs_idle_seconds"`        // Seconds without use after which a virtual file is idle
+	URLMaxBytes           int64                   `json:"url_max_bytes"`           // Bytes one --allow-url input may hold, 0 for no limit
+	MaxRetries            int                     `json:"max_retries"`
+	MaxContinuations      int                     `json:"max_continuations"` // Continuation turns requested after finish_reason=length
+	RetryDelay            int                     `json:"retry_delay_ms"`
+	SystemPrompt          string                  `json:"system_prompt"`
+	DefaultPrompt         string                  `json:"default_prompt"`
+	DisableTools          bool                    `json:"disable_tools"`
+	PromptPresets         map[string]PromptPreset `json:"prompt_presets"`
 	// Quota system configuration
 	QuotaMaxTokens     int                     `json:"quota_max_tokens"`     // Maximum weighted tokens allowed
 	QuotaWeights       QuotaWeights            `json:"quota_weights"`        // Token type weights
@@ -76,21 +92,30 @@ type ConfigFile struct {
 // DefaultConfig returns default configuration values
 func DefaultConfig() *ConfigFile {
 	return &ConfigFile{
-		OpenAIBaseURL:  "https://api.openai.com/v1",
-		Model:          "gpt-4o-mini",
-		InternalModel:  "gpt-4o-mini", // Default to same model for internal calls
-		MaxTokens:      4096,
-		Temperature:    0.1,
-		MaxAPICalls:    50,
-		TimeoutSeconds: 300,
-		MaxFileSize:    10 * 1024 * 1024, // 10MB
-		ReadBufferSize: 4096,             // 4KB
-		MaxRetries:     3,
-		RetryDelay:     1000,      // 1 second
-		SystemPrompt:   "",        // Empty means use default built-in prompt
-		DefaultPrompt:  "general", // Default preset key
-		DisableTools:   false,     // Tools enabled by default
-		PromptPresets:  getDefaultPromptPresets(),
+		OpenAIBaseURL:         "https://api.openai.com/v1",
+		Model:                 "gpt-4o-mini",
+		InternalModel:         "gpt-4o-mini", // Default to same model for internal calls
+		MaxTokens:             4096,
+		Temperature:           0.1,
+		MaxAPICalls:           50,
+		TimeoutSeconds:        300,
+		SpawnShell:            "sh",
+		MaxFileSize:           10 * 1024 * 1024,  // 10MB
+		ReadBufferSize:        4096,              // 4KB
+		ResultHandleThreshold: 8192,              // 8KB
+		VFSMaxTotalBytes:      256 * 1024 * 1024, // 256MB
+		VFSMaxFiles:           10000,
+		VFSSpillBytes:         16 * 1024 * 1024, // 16MB
+		VFSCompressBytes:      1024 * 1024,      // 1MB
+		VFSIdleSeconds:        60,
+		URLMaxBytes:           10 * 1024 * 1024, // 10MB
+		MaxRetries:            3,
+		MaxContinuations:      3,
+		RetryDelay:            1000,      // 1 second
+		SystemPrompt:          "",        // Empty means use default built-in prompt
+		DefaultPrompt:         "general", // Default preset key
+		DisableTools:          false,     // Tools enabled by default
+		PromptPresets:         getDefaultPromptPresets(),
 		// Default quota configuration (0 means no limit)
 		QuotaMaxTokens: 0, // No limit by default
 		QuotaWeights: QuotaWeights{
@@ -243,6 +268,18 @@ func validateConfigValues(config *ConfigFile) error {
 		return fmt.Errorf("timeout_seconds must be between 1 and 3600, got %d", config.TimeoutSeconds)
 	}
 
+	if config.SpawnTimeoutSeconds < 0 || config.SpawnTimeoutSeconds > 3600 {
+		return fmt.Errorf("spawn_timeout_seconds must be between 0 and 3600, got %d", config.SpawnTimeoutSeconds)
+	}
+
+	if config.SpawnCPUSeconds < 0 || config.SpawnMemoryMB < 0 || config.SpawnMaxOpenFiles < 0 {
+		return fmt.Errorf("spawn_cpu_seconds, spawn_memory_mb and spawn_max_open_files cannot be negative")
+	}
+
+	if config.SpawnShell != "" && config.SpawnShell != "sh" && config.SpawnShell != "llmsh" {
+		return fmt.Errorf("spawn_shell must be sh or llmsh, got %q", config.SpawnShell)
+	}
+
 	if config.MaxFileSize < 1 || config.MaxFileSize > 100*1024*1024 {
 		return fmt.Errorf("max_file_size must be between 1 and 100MB, got %d", config.MaxFileSize)
 	}
@@ -251,10 +288,34 @@ func validateConfigValues(config *ConfigFile) error {
 		return fmt.Errorf("read_buffer_size must be between 1 and 64KB, got %d", config.ReadBufferSize)
 	}
 
+	if config.ResultHandleThreshold < 0 || config.ResultHandleThreshold > 1024*1024 {
+		return fmt.Errorf("result_handle_threshold must be between 0 and 1MB, got %d", config.ResultHandleThreshold)
+	}
+
+	if config.VFSMaxTotalBytes < 0 || config.VFSMaxFileBytes < 0 || config.VFSMaxFiles < 0 {
+		return fmt.Errorf("vfs_max_total_bytes, vfs_max_file_bytes and vfs_max_files cannot be negative")
+	}
+	if config.VFSSpillBytes < 0 {
+		return fmt.Errorf("vfs_spill_bytes cannot be negative")
+	}
+	if config.VFSCompressBytes < 0 {
+		return fmt.Errorf("vfs_compress_bytes cannot be negative")
+	}
+	if config.VFSIdleSeconds < 1 {
+		return fmt.Errorf("vfs_idle_seconds must be at least 1, got %d", config.VFSIdleSeconds)
+	}
+	if config.URLMaxBytes < 0 {
+		return fmt.Errorf("url_max_bytes cannot be negative")
+	}
+
 	if config.MaxRetries < 0 || config.MaxRetries > 10 {
 		return fmt.Errorf("max_retries must be between 0 and 10, got %d", config.MaxRetries)
 	}
 
+	if config.MaxContinuations < 0 || config.MaxContinuations > 20 {
+		return fmt.Errorf("max_continuations must be between 0 and 20, got %d", config.MaxContinuations)
+	}
+
 	if config.RetryDelay < 0 || config.RetryDelay > 60000 {
 		return fmt.Errorf("retry_delay_ms must be between 0 and 60000, got %d", config.RetryDelay)
 	}
@@ -321,6 +382,12 @@ func LoadAndMergeConfig(cliConfig *Config) (*ConfigFile, error) {
 			if fileConfig.OpenAIBaseURL != "" {
 				config.OpenAIBaseURL = fileConfig.OpenAIBaseURL
 			}
+			if len(fileConfig.FallbackBaseURLs) > 0 {
+				config.FallbackBaseURLs = fileConfig.FallbackBaseURLs
+			}
+			if fileConfig.FallbackAPIKey != "" {
+				config.FallbackAPIKey = fileConfig.FallbackAPIKey
+			}
 			if fileConfig.Model != "" {
 				config.Model = fileConfig.Model
 			}
@@ -336,15 +403,57 @@ func LoadAndMergeConfig(cliConfig *Config) (*ConfigFile, error) {
 			if fileConfig.TimeoutSeconds > 0 {
 				config.TimeoutSeconds = fileConfig.TimeoutSeconds
 			}
+			if fileConfig.SpawnTimeoutSeconds > 0 {
+				config.SpawnTimeoutSeconds = fileConfig.SpawnTimeoutSeconds
+			}
+			if fileConfig.SpawnCPUSeconds > 0 {
+				config.SpawnCPUSeconds = fileConfig.SpawnCPUSeconds
+			}
+			if fileConfig.SpawnMemoryMB > 0 {
+				config.SpawnMemoryMB = fileConfig.SpawnMemoryMB
+			}
+			if fileConfig.SpawnMaxOpenFiles > 0 {
+				config.SpawnMaxOpenFiles = fileConfig.SpawnMaxOpenFiles
+			}
+			if fileConfig.SpawnShell != "" {
+				config.SpawnShell = fileConfig.SpawnShell
+			}
 			if fileConfig.MaxFileSize > 0 {
 				config.MaxFileSize = fileConfig.MaxFileSize
 			}
 			if fileConfig.ReadBufferSize > 0 {
 				config.ReadBufferSize = fileConfig.ReadBufferSize
 			}
+			if fileConfig.ResultHandleThreshold != DefaultConfig().ResultHandleThreshold {
+				config.ResultHandleThreshold = fileConfig.ResultHandleThreshold
+			}
+			if fileConfig.VFSMaxTotalBytes != DefaultConfig().VFSMaxTotalBytes {
+				config.VFSMaxTotalBytes = fileConfig.VFSMaxTotalBytes
+			}
+			if fileConfig.VFSMaxFileBytes > 0 {
+				config.VFSMaxFileBytes = fileConfig.VFSMaxFileBytes
+			}
+			if fileConfig.VFSMaxFiles != DefaultConfig().VFSMaxFiles {
+				config.VFSMaxFiles = fileConfig.VFSMaxFiles
+			}
+			if fileConfig.VFSSpillBytes != DefaultConfig().VFSSpillBytes {
+				config.VFSSpillBytes = fileConfig.VFSSpillBytes
+			}
+			if fileConfig.VFSCompressBytes != DefaultConfig().VFSCompressBytes {
+				config.VFSCompressBytes = fileConfig.VFSCompressBytes
+			}
+			if fileConfig.VFSIdleSeconds > 0 {
+				config.VFSIdleSeconds = fileConfig.VFSIdleSeconds
+			}
+			if fileConfig.URLMaxBytes != DefaultConfig().URLMaxBytes {
+				config.URLMaxBytes = fileConfig.URLMaxBytes
+			}
 			if fileConfig.MaxRetries > 0 {
 				config.MaxRetries = fileConfig.MaxRetries
 			}
+			if fileConfig.MaxContinuations != DefaultConfig().MaxContinuations {
+				config.MaxContinuations = fileConfig.MaxContinuations
+			}
 			if fileConfig.RetryDelay > 0 {
 				config.RetryDelay = fileConfig.RetryDelay
 			}
@@ -439,6 +548,15 @@ func setConfigValue(config *ConfigFile, key, value string) error {
 		config.OpenAIAPIKey = value
 	case "openai_base_url":
 		config.OpenAIBaseURL = value
+	case "fallback_base_urls":
+		config.FallbackBaseURLs = nil
+		for _, url := range strings.Split(value, ",") {
+			if url = strings.TrimSpace(url); url != "" {
+				config.FallbackBaseURLs = append(config.FallbackBaseURLs, url)
+			}
+		}
+	case "fallback_api_key":
+		config.FallbackAPIKey = value
 	case "model":
 		config.Model = value
 	case "max_tokens":
@@ -449,12 +567,40 @@ func setConfigValue(config *ConfigFile, key, value string) error {
 		return parseAndAssignInt(value, "max_api_calls", func(val int) { config.MaxAPICalls = val })
 	case "timeout_seconds":
 		return parseAndAssignInt(value, "timeout_seconds", func(val int) { config.TimeoutSeconds = val })
+	case "spawn_timeout_seconds":
+		return parseAndAssignInt(value, "spawn_timeout_seconds", func(val int) { config.SpawnTimeoutSeconds = val })
+	case "spawn_cpu_seconds":
+		return parseAndAssignInt(value, "spawn_cpu_seconds", func(val int) { config.SpawnCPUSeconds = val })
+	case "spawn_memory_mb":
+		return parseAndAssignInt(value, "spawn_memory_mb", func(val int) { config.SpawnMemoryMB = val })
+	case "spawn_max_open_files":
+		return parseAndAssignInt(value, "spawn_max_open_files", func(val int) { config.SpawnMaxOpenFiles = val })
+	case "spawn_shell":
+		config.SpawnShell = value
 	case "max_file_size":
 		return parseAndAssignInt64(value, "max_file_size", func(val int64) { config.MaxFileSize = val })
 	case "read_buffer_size":
 		return parseAndAssignInt(value, "read_buffer_size", func(val int) { config.ReadBufferSize = val })
+	case "result_handle_threshold":
+		return parseAndAssignInt(value, "result_handle_threshold", func(val int) { config.ResultHandleThreshold = val })
+	case "vfs_max_total_bytes":
+		return parseAndAssignInt64(value, "vfs_max_total_bytes", func(val int64) { config.VFSMaxTotalBytes = val })
+	case "vfs_max_file_bytes":
+		return parseAndAssignInt64(value, "vfs_max_file_bytes", func(val int64) { config.VFSMaxFileBytes = val })
+	case "vfs_max_files":
+		return parseAndAssignInt(value, "vfs_max_files", func(val int) { config.VFSMaxFiles = val })
+	case "vfs_spill_bytes":
+		return parseAndAssignInt64(value, "vfs_spill_bytes", func(val int64) { config.VFSSpillBytes = val })
+	case "vfs_compress_bytes":
+		return parseAndAssignInt64(value, "vfs_compress_bytes", func(val int64) { config.VFSCompressBytes = val })
+	case "vfs_idle_seconds":
+		return parseAndAssignInt(value, "vfs_idle_seconds", func(val int) { config.VFSIdleSeconds = val })
+	case "url_max_bytes":
+		return parseAndAssignInt64(value, "url_max_bytes", func(val int64) { config.URLMaxBytes = val })
 	case "max_retries":
 		return parseAndAssignInt(value, "max_retries", func(val int) { config.MaxRetries = val })
+	case "max_continuations":
+		return parseAndAssignInt(value, "max_continuations", func(val int) { config.MaxContinuations = val })
 	case "retry_delay_ms":
 		return parseAndAssignInt(value, "retry_delay_ms", func(val int) { config.RetryDelay = val })
 	case "system_prompt":
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -26,9 +27,22 @@ type Config struct {
 	InputFiles  []string // -i: Input file paths (can be specified multiple times)
 	OutputFile  string   // -o: Output file path
 	Verbose     bool     // -v: Verbose logging
+	Quiet       bool     // -q: No decorative output: result on stdout, errors on stderr
+	Trace       bool     // --trace: Write tool call events to stderr as JSON lines
+	DryRun      bool     // --dry-run: Report spawn/write/open instead of performing them
 	ShowStats   bool     // --stats: Show detailed statistics
+	StatsFormat string   // --stats-format: Statistics format (table, json, csv)
+	VersionJSON bool     // --json: With --version, print build information as JSON
 	ConfigFile  string   // -c: Configuration file path
 	NoStdin     bool     // --no-stdin: Skip reading from stdin
+	DebugAddr   string   // --debug-addr: Address for pprof/expvar debug endpoints
+	DebugDumpOn string   // --debug-dump-on: Signal that dumps goroutines and fd tables to stderr
+	VFSLoad     string   // --vfs-load: Snapshot whose virtual files are loaded before the run
+	VFSSave     string   // --vfs-save: Snapshot the virtual files are saved to after the run
+	Exports     []Export // --export: Virtual files copied to real files after the run
+	Mounts      []Mount  // --mount: Real directories exposed as read-only virtual files
+	AllowURL    bool     // --allow-url: Let -i take https:// URLs
+	URLInputs   []string // -i with --allow-url: URLs fetched into read-only virtual files
 
 	// Positional arguments
 	Instructions string // Remaining arguments as instructions
@@ -38,48 +52,139 @@ type Config struct {
 	ConfigExplicit bool   // Whether config file was explicitly specified
 }
 
-// ParseArgs parses command line arguments and returns configuration
-func ParseArgs(args []string) (*Config, error) {
-	var config Config
-	var inputFiles arrayFlags
+// Export names a virtual file to copy to the real filesystem after the run
+type Export struct {
+	Name string // Virtual file name
+	Path string // Real file path
+}
+
+// Mount names a real directory exposed read-only under a virtual prefix
+type Mount struct {
+	Prefix string // Virtual name of the directory
+	Dir    string // Real directory
+}
+
+// flagTargets are the values command line flags are parsed into
+type flagTargets struct {
+	config        Config
+	inputFiles    arrayFlags
+	exports       arrayFlags
+	mounts        arrayFlags
+	showHelp      bool
+	showVersion   bool
+	installSystem bool
+}
+
+// newFlagSet defines the llmcmd command line flags. Backquoted words in the
+// usage strings name the flag's argument (see flag.UnquoteUsage).
+func newFlagSet(t *flagTargets) *flag.FlagSet {
+	config := &t.config
 
 	// Create a custom FlagSet to handle our specific requirements
 	fs := flag.NewFlagSet("llmcmd", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
 
 	// Define flags with both short and long options where appropriate
-	fs.StringVar(&config.Prompt, "p", "", "LLM prompt/instructions (free text)")
-	fs.StringVar(&config.Prompt, "prompt", "", "LLM prompt/instructions (free text)")
+	fs.StringVar(&config.Prompt, "p", "", "LLM prompt/instructions (free `text`)")
+	fs.StringVar(&config.Prompt, "prompt", "", "LLM prompt/instructions (free `text`)")
 
-	fs.StringVar(&config.Preset, "r", "", "Use predefined prompt preset (see --list-presets)")
-	fs.StringVar(&config.Preset, "preset", "", "Use predefined prompt preset (see --list-presets)")
+	fs.StringVar(&config.Preset, "r", "", "Use predefined prompt preset `key` (see --list-presets)")
+	fs.StringVar(&config.Preset, "preset", "", "Use predefined prompt preset `key` (see --list-presets)")
 	fs.BoolVar(&config.ListPresets, "list-presets", false, "List available prompt presets and exit")
 
-	fs.Var(&inputFiles, "i", "Input file path (can be specified multiple times)")
-	fs.Var(&inputFiles, "input", "Input file path (can be specified multiple times)")
+	fs.Var(&t.inputFiles, "i", "Input `file` path (can be specified multiple times)")
+	fs.Var(&t.inputFiles, "input", "Input `file` path (can be specified multiple times)")
 
-	fs.StringVar(&config.OutputFile, "o", "", "Output file path")
-	fs.StringVar(&config.OutputFile, "output", "", "Output file path")
+	fs.StringVar(&config.OutputFile, "o", "", "Output `file` path")
+	fs.StringVar(&config.OutputFile, "output", "", "Output `file` path")
 
-	fs.StringVar(&config.ConfigFile, "c", "", "Configuration file path")
-	fs.StringVar(&config.ConfigFile, "config", "", "Configuration file path")
+	fs.StringVar(&config.ConfigFile, "c", "", "Configuration `file` path")
+	fs.StringVar(&config.ConfigFile, "config", "", "Configuration `file` path")
 
 	fs.BoolVar(&config.Verbose, "v", false, "Enable verbose logging")
 	fs.BoolVar(&config.Verbose, "verbose", false, "Enable verbose logging")
+	fs.BoolVar(&config.Quiet, "q", false, "Quiet: only the result on stdout and errors on stderr")
+	fs.BoolVar(&config.Quiet, "quiet", false, "Quiet: only the result on stdout and errors on stderr")
+	fs.BoolVar(&config.Trace, "trace", false, "Write tool call events to stderr as JSON lines")
+	fs.BoolVar(&config.DryRun, "dry-run", false, "Report what spawn, write and open would do without doing it")
 
 	fs.BoolVar(&config.ShowStats, "s", false, "Show detailed statistics after execution")
 	fs.BoolVar(&config.ShowStats, "stats", false, "Show detailed statistics after execution")
+	fs.StringVar(&config.StatsFormat, "stats-format", "", "Statistics `format`: table, json or csv (implies --stats)")
 
 	fs.BoolVar(&config.NoStdin, "n", false, "Skip reading from stdin")
 	fs.BoolVar(&config.NoStdin, "no-stdin", false, "Skip reading from stdin")
 
+	fs.StringVar(&config.DebugAddr, "debug-addr", "", "Serve pprof/expvar debug endpoints on this `address` (e.g. localhost:6060)")
+	fs.StringVar(&config.DebugDumpOn, "debug-dump-on", "", "Dump goroutines, fd and process tables to stderr on this `signal` (e.g. SIGUSR1)")
+
+	fs.StringVar(&config.VFSLoad, "vfs-load", "", "Load virtual files from a snapshot `file` saved by --vfs-save")
+	fs.StringVar(&config.VFSSave, "vfs-save", "", "Save the virtual files to a snapshot `file` (tar, gzip if it ends in .gz or .tgz) after the run")
+
+	fs.Var(&t.exports, "export", "Copy virtual file `name=path` to a real file after the run (can be specified multiple times)")
+
+	fs.BoolVar(&config.AllowURL, "allow-url", false, "Let -i take https:// URLs, fetched into read-only virtual files before the run")
+
+	fs.Var(&t.mounts, "mount", "Expose real directory `[prefix=]dir` read-only as virtual files prefix/... (default prefix: the directory name; can be specified multiple times)")
+
 	// Handle help and version flags
-	var showHelp, showVersion, installSystem bool
-	fs.BoolVar(&showHelp, "h", false, "Show help")
-	fs.BoolVar(&showHelp, "help", false, "Show help")
-	fs.BoolVar(&showVersion, "V", false, "Show version")
-	fs.BoolVar(&showVersion, "version", false, "Show version")
-	fs.BoolVar(&installSystem, "install", false, "Install llmcmd system-wide")
+	fs.BoolVar(&t.showHelp, "h", false, "Show help")
+	fs.BoolVar(&t.showHelp, "help", false, "Show help")
+	fs.BoolVar(&t.showVersion, "V", false, "Show version")
+	fs.BoolVar(&t.showVersion, "version", false, "Show version")
+	fs.BoolVar(&config.VersionJSON, "json", false, "With --version, print build information as JSON")
+	fs.BoolVar(&t.installSystem, "install", false, "Install llmcmd system-wide")
+
+	return fs
+}
+
+// Option describes one command line option and its aliases, for generated
+// documentation and shell completions
+type Option struct {
+	Names []string // Flag names without dashes, short names first
+	Arg   string   // Argument name, empty for boolean flags
+	Usage string
+}
+
+// Options returns the llmcmd command line options in definition order
+func Options() []Option {
+	fs := newFlagSet(&flagTargets{})
+
+	var order []string
+	byUsage := make(map[string]*Option)
+	fs.VisitAll(func(f *flag.Flag) {
+		arg, usage := flag.UnquoteUsage(f)
+		if _, isBool := f.Value.(interface{ IsBoolFlag() bool }); isBool {
+			arg = ""
+		}
+		// Aliases share the same usage text
+		option, exists := byUsage[usage]
+		if !exists {
+			option = &Option{Arg: arg, Usage: usage}
+			byUsage[usage] = option
+			order = append(order, usage)
+		}
+		option.Names = append(option.Names, f.Name)
+	})
+
+	options := make([]Option, 0, len(order))
+	for _, usage := range order {
+		option := byUsage[usage]
+		sort.SliceStable(option.Names, func(i, j int) bool { return len(option.Names[i]) < len(option.Names[j]) })
+		options = append(options, *option)
+	}
+	// Sort by long name, as man pages list options
+	sort.SliceStable(options, func(i, j int) bool {
+		return options[i].Names[len(options[i].Names)-1] < options[j].Names[len(options[j].Names)-1]
+	})
+	return options
+}
+
+// ParseArgs parses command line arguments and returns configuration
+func ParseArgs(args []string) (*Config, error) {
+	var targets flagTargets
+	fs := newFlagSet(&targets)
+	config := &targets.config
 
 	// Parse arguments
 	err := fs.Parse(args)
@@ -88,28 +193,72 @@ func ParseArgs(args []string) (*Config, error) {
 	}
 
 	// Handle help/version first
-	if showHelp {
+	if targets.showHelp {
 		return nil, ErrShowHelp
 	}
-	if showVersion {
-		return nil, ErrShowVersion
+	if targets.showVersion {
+		return &Config{VersionJSON: config.VersionJSON}, ErrShowVersion
 	}
 	if config.ListPresets {
 		// Return minimal config with ConfigFile path for preset loading
 		return &Config{ConfigFile: config.ConfigFile}, ErrListPresets
 	}
-	if installSystem {
+	if targets.installSystem {
 		return nil, ErrInstall
 	}
 
-	// Copy input files from the custom type
-	config.InputFiles = []string(inputFiles)
+	// Choosing a statistics format implies showing statistics
+	if config.StatsFormat != "" {
+		config.ShowStats = true
+	}
+
+	// Copy input files from the custom type, setting URLs aside
+	for _, input := range targets.inputFiles {
+		lower := strings.ToLower(input)
+		switch {
+		case strings.HasPrefix(lower, "https://"):
+			if !config.AllowURL {
+				return nil, fmt.Errorf("input %s is a URL: pass --allow-url to fetch it", input)
+			}
+			config.URLInputs = append(config.URLInputs, input)
+		case strings.HasPrefix(lower, "http://"):
+			return nil, fmt.Errorf("input %s: only https:// URLs can be fetched", input)
+		default:
+			config.InputFiles = append(config.InputFiles, input)
+		}
+	}
 
 	// If no input files specified, default to stdin
-	if len(config.InputFiles) == 0 {
+	if len(config.InputFiles) == 0 && len(config.URLInputs) == 0 {
 		config.InputFiles = []string{"-"}
 	}
 
+	for _, export := range targets.exports {
+		name, realPath, ok := strings.Cut(export, "=")
+		if !ok || name == "" || realPath == "" {
+			return nil, fmt.Errorf("invalid --export %q: must be name=path", export)
+		}
+		config.Exports = append(config.Exports, Export{Name: name, Path: realPath})
+	}
+
+	for _, mount := range targets.mounts {
+		prefix, dir, ok := strings.Cut(mount, "=")
+		if !ok {
+			prefix, dir = "", mount
+		}
+		if dir == "" {
+			return nil, fmt.Errorf("invalid --mount %q: must be [prefix=]dir", mount)
+		}
+		if prefix == "" {
+			abs, err := filepath.Abs(dir)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --mount %q: %w", mount, err)
+			}
+			prefix = filepath.Base(abs)
+		}
+		config.Mounts = append(config.Mounts, Mount{Prefix: prefix, Dir: dir})
+	}
+
 	// Remaining arguments become instructions
 	remaining := fs.Args()
 	if len(remaining) > 0 {
@@ -117,7 +266,7 @@ func ParseArgs(args []string) (*Config, error) {
 	}
 
 	// Validate configuration
-	if err := validateConfig(&config); err != nil {
+	if err := validateConfig(config); err != nil {
 		return nil, err
 	}
 
@@ -139,7 +288,7 @@ func ParseArgs(args []string) (*Config, error) {
 		config.ConfigDir = filepath.Dir(config.ConfigFile)
 	}
 
-	return &config, nil
+	return config, nil
 }
 
 // validateConfig validates the parsed configuration
@@ -151,6 +300,16 @@ func validateConfig(config *Config) error {
 
 	// If both are provided, that's also fine - they will be combined
 
+	if config.Quiet && (config.Verbose || config.Trace || config.ShowStats) {
+		return fmt.Errorf("--quiet cannot be combined with --verbose, --trace, --stats or --stats-format")
+	}
+
+	switch config.StatsFormat {
+	case "", "table", "json", "csv":
+	default:
+		return fmt.Errorf("invalid --stats-format %q: must be table, json or csv", config.StatsFormat)
+	}
+
 	// Validate input files exist (skip stdin)
 	for _, inputFile := range config.InputFiles {
 		// Skip validation for stdin
@@ -162,6 +321,26 @@ func validateConfig(config *Config) error {
 		}
 	}
 
+	if config.VFSLoad != "" {
+		if _, err := os.Stat(config.VFSLoad); os.IsNotExist(err) {
+			return fmt.Errorf("VFS snapshot does not exist: %s", config.VFSLoad)
+		}
+	}
+
+	for _, export := range config.Exports {
+		if dir := filepath.Dir(export.Path); dir != "." {
+			if _, err := os.Stat(dir); os.IsNotExist(err) {
+				return fmt.Errorf("export directory does not exist: %s", dir)
+			}
+		}
+	}
+
+	for _, mount := range config.Mounts {
+		if info, err := os.Stat(mount.Dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("mount directory does not exist: %s", mount.Dir)
+		}
+	}
+
 	// Validate output file directory exists if specified (skip stdout)
 	if config.OutputFile != "" && config.OutputFile != "-" {
 		dir := filepath.Dir(config.OutputFile)
@@ -197,6 +376,10 @@ DESCRIPTION:
 
 USAGE:
     llmcmd [OPTIONS] [INSTRUCTIONS]
+    llmcmd estimate [OPTIONS] [INSTRUCTIONS]
+
+COMMANDS:
+    estimate                Predict token usage and check the quota without calling the API
 
 OPTIONS:
     -p, --prompt <text>     LLM prompt/instructions (free text)
@@ -206,10 +389,22 @@ OPTIONS:
     -o, --output <file>     Output file path  
     -c, --config <file>     Configuration file path (default: ~/.llmcmdrc)
     -v, --verbose           Enable verbose logging
+    -q, --quiet             Only the result on stdout and errors on stderr (no stats or warnings)
+    --trace                 Write tool call events to stderr as JSON lines
+    --dry-run               Report what spawn, write and open would do without doing it
     -s, --stats             Show detailed statistics after execution
+    --stats-format <fmt>    Statistics format: table (default), json, csv
     -n, --no-stdin          Skip reading from stdin
+    --debug-addr <addr>     Serve pprof/expvar debug endpoints (e.g. localhost:6060)
+    --debug-dump-on <sig>   Dump goroutines, fd and process tables on signal (e.g. SIGUSR1)
+    --vfs-load <file>       Load virtual files from a snapshot saved by --vfs-save
+    --vfs-save <file>       Save the virtual files to a tar snapshot after the run
+    --export <name=path>    Copy a virtual file to a real file after the run (repeatable)
+    --mount <[prefix=]dir>  Expose a real directory read-only as virtual files (repeatable)
+    --allow-url             Let -i take https:// URLs, fetched into read-only virtual files
     -h, --help              Show this help message
     -V, --version           Show version information
+    --json                  With --version, print build information as JSON
 
 ARGUMENTS:
     INSTRUCTIONS            Command instructions for the LLM
@@ -231,6 +426,9 @@ EXAMPLES:
     # List available presets
     llmcmd --list-presets
 
+    # Check whether the quota covers a run before spending it
+    llmcmd estimate -i big.log -p "Summarize the errors"
+
 CONFIGURATION:
     Configuration priority (highest to lowest):
     1. Command line options
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -106,3 +107,150 @@ func TestDefaultConfig(t *testing.T) {
 		t.Errorf("DefaultConfig() MaxAPICalls = %v, want 50", config.MaxAPICalls)
 	}
 }
+
+func TestParseVersionJSON(t *testing.T) {
+	got, err := ParseArgs([]string{"--version", "--json"})
+	if err != ErrShowVersion {
+		t.Fatalf("ParseArgs() error = %v, want ErrShowVersion", err)
+	}
+	if got == nil || !got.VersionJSON {
+		t.Errorf("ParseArgs() = %+v, want VersionJSON set", got)
+	}
+}
+
+func TestParseStatsFormat(t *testing.T) {
+	got, err := ParseArgs([]string{"--stats-format", "json", "test instruction"})
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	if got.StatsFormat != "json" || !got.ShowStats {
+		t.Errorf("ParseArgs() StatsFormat = %q, ShowStats = %v, want json and true", got.StatsFormat, got.ShowStats)
+	}
+
+	if _, err := ParseArgs([]string{"--stats-format", "xml", "test instruction"}); err == nil {
+		t.Errorf("ParseArgs() expected error for unknown stats format")
+	}
+}
+
+func TestOptionsGroupAliases(t *testing.T) {
+	var output, version *Option
+	options := Options()
+	for i := range options {
+		switch options[i].Names[len(options[i].Names)-1] {
+		case "output":
+			output = &options[i]
+		case "version":
+			version = &options[i]
+		}
+	}
+
+	if output == nil || !reflect.DeepEqual(output.Names, []string{"o", "output"}) || output.Arg != "file" {
+		t.Errorf("output option = %+v, want names [o output] with a file argument", output)
+	}
+	if version == nil || !reflect.DeepEqual(version.Names, []string{"V", "version"}) || version.Arg != "" {
+		t.Errorf("version option = %+v, want names [V version] without an argument", version)
+	}
+}
+
+func TestParseQuiet(t *testing.T) {
+	got, err := ParseArgs([]string{"-q", "test instruction"})
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	if !got.Quiet {
+		t.Errorf("ParseArgs() Quiet = false, want true")
+	}
+
+	for _, flag := range []string{"--verbose", "--trace", "--stats", "--stats-format=json"} {
+		if _, err := ParseArgs([]string{"--quiet", flag, "test instruction"}); err == nil {
+			t.Errorf("ParseArgs() expected error for --quiet with %s", flag)
+		}
+	}
+}
+
+func TestParseVFSSnapshot(t *testing.T) {
+	snapshot := filepath.Join(t.TempDir(), "vfs.tar")
+	if _, err := ParseArgs([]string{"--vfs-load", snapshot, "test instruction"}); err == nil {
+		t.Errorf("ParseArgs() expected error for a missing --vfs-load snapshot")
+	}
+
+	if err := os.WriteFile(snapshot, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseArgs([]string{"--vfs-load", snapshot, "--vfs-save", "next.tgz", "test instruction"})
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	if got.VFSLoad != snapshot || got.VFSSave != "next.tgz" {
+		t.Errorf("ParseArgs() VFSLoad = %q, VFSSave = %q", got.VFSLoad, got.VFSSave)
+	}
+}
+
+func TestParseExport(t *testing.T) {
+	got, err := ParseArgs([]string{"--export", "out/a.txt=a.txt", "--export", "b=c=d", "test instruction"})
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	want := []Export{{Name: "out/a.txt", Path: "a.txt"}, {Name: "b", Path: "c=d"}}
+	if !reflect.DeepEqual(got.Exports, want) {
+		t.Errorf("ParseArgs() Exports = %+v, want %+v", got.Exports, want)
+	}
+
+	for _, export := range []string{"a.txt", "=a.txt", "a.txt=", "a=" + filepath.Join(t.TempDir(), "missing", "a.txt")} {
+		if _, err := ParseArgs([]string{"--export", export, "test instruction"}); err == nil {
+			t.Errorf("ParseArgs() expected error for --export %q", export)
+		}
+	}
+}
+
+func TestParseMount(t *testing.T) {
+	dir := t.TempDir()
+	got, err := ParseArgs([]string{"--mount", dir, "--mount", "repo=" + dir, "test instruction"})
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	want := []Mount{{Prefix: filepath.Base(dir), Dir: dir}, {Prefix: "repo", Dir: dir}}
+	if !reflect.DeepEqual(got.Mounts, want) {
+		t.Errorf("ParseArgs() Mounts = %+v, want %+v", got.Mounts, want)
+	}
+
+	for _, mount := range []string{"repo=", filepath.Join(dir, "missing")} {
+		if _, err := ParseArgs([]string{"--mount", mount, "test instruction"}); err == nil {
+			t.Errorf("ParseArgs() expected error for --mount %q", mount)
+		}
+	}
+}
+
+func TestParseURLInputs(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "local.txt")
+	os.WriteFile(file, []byte("local"), 0644)
+
+	got, err := ParseArgs([]string{"--allow-url", "-i", "https://example.com/app.log", "-i", file, "test instruction"})
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	if want := []string{"https://example.com/app.log"}; !reflect.DeepEqual(got.URLInputs, want) {
+		t.Errorf("ParseArgs() URLInputs = %v, want %v", got.URLInputs, want)
+	}
+	if want := []string{file}; !reflect.DeepEqual(got.InputFiles, want) {
+		t.Errorf("ParseArgs() InputFiles = %v, want %v", got.InputFiles, want)
+	}
+
+	// URLs alone do not read stdin as an input file
+	got, err = ParseArgs([]string{"--allow-url", "-i", "https://example.com/app.log", "test instruction"})
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	if len(got.InputFiles) != 0 {
+		t.Errorf("ParseArgs() InputFiles = %v, want none", got.InputFiles)
+	}
+
+	for _, args := range [][]string{
+		{"-i", "https://example.com/app.log", "test instruction"},
+		{"--allow-url", "-i", "http://example.com/app.log", "test instruction"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Errorf("ParseArgs(%q) expected error", args)
+		}
+	}
+}
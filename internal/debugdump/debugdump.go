@@ -0,0 +1,119 @@
+package debugdump
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StateFunc writes application state (fd tables, process tables) to w
+type StateFunc func(w io.Writer)
+
+var (
+	publishOnce  sync.Once
+	stateMu      sync.Mutex
+	currentState StateFunc
+)
+
+// WriteDump writes heap statistics, application state and all goroutine stacks to w
+func WriteDump(w io.Writer, state StateFunc) {
+	fmt.Fprintf(w, "=== LLMCMD DEBUG DUMP (%s, pid %d) ===\n", time.Now().Format(time.RFC3339), os.Getpid())
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	fmt.Fprintf(w, "\n--- heap ---\n")
+	fmt.Fprintf(w, "HeapAlloc: %d\nHeapInuse: %d\nHeapObjects: %d\nSys: %d\nNumGC: %d\nGoroutines: %d\n",
+		mem.HeapAlloc, mem.HeapInuse, mem.HeapObjects, mem.Sys, mem.NumGC, runtime.NumGoroutine())
+
+	if state != nil {
+		fmt.Fprintf(w, "\n--- state ---\n")
+		state(w)
+	}
+
+	fmt.Fprintf(w, "\n--- goroutines ---\n")
+	runtimepprof.Lookup("goroutine").WriteTo(w, 2)
+	fmt.Fprintf(w, "=== END DEBUG DUMP ===\n")
+}
+
+// DumpOnSignal writes a dump to w each time the named signal (e.g. SIGUSR1) arrives.
+// The returned function stops listening.
+func DumpOnSignal(name string, w io.Writer, state StateFunc) (func(), error) {
+	sig, err := parseSignal(strings.ToUpper(strings.TrimSpace(name)))
+	if err != nil {
+		return nil, err
+	}
+
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, sig)
+	go func() {
+		for {
+			select {
+			case <-signals:
+				WriteDump(w, state)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}, nil
+}
+
+// Serve starts an HTTP server on addr exposing /debug/pprof/, /debug/vars and /debug/dump.
+// It returns the listening address and a function that shuts the server down.
+func Serve(addr string, state StateFunc) (string, func(), error) {
+	stateMu.Lock()
+	currentState = state
+	stateMu.Unlock()
+	publishOnce.Do(func() {
+		expvar.Publish("llmcmd_state", expvar.Func(func() interface{} {
+			var b strings.Builder
+			if s := loadState(); s != nil {
+				s(&b)
+			}
+			return b.String()
+		}))
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/dump", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		WriteDump(w, loadState())
+	})
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", nil, fmt.Errorf("debug server: %w", err)
+	}
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+
+	return listener.Addr().String(), func() { server.Close() }, nil
+}
+
+// loadState returns the state function registered by Serve
+func loadState() StateFunc {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	return currentState
+}
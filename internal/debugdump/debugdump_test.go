@@ -0,0 +1,51 @@
+package debugdump
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWriteDump(t *testing.T) {
+	var buf bytes.Buffer
+	WriteDump(&buf, func(w io.Writer) { fmt.Fprintln(w, "open fds: 1=stdout") })
+
+	dump := buf.String()
+	for _, want := range []string{"--- heap ---", "HeapAlloc:", "open fds: 1=stdout", "--- goroutines ---", "TestWriteDump"} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("dump missing %q", want)
+		}
+	}
+}
+
+func TestServeDumpEndpoint(t *testing.T) {
+	addr, stop, err := Serve("127.0.0.1:0", func(w io.Writer) { fmt.Fprintln(w, "processes: 0") })
+	if err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+	defer stop()
+
+	for path, want := range map[string]string{
+		"/debug/dump": "processes: 0",
+		"/debug/vars": "llmcmd_state",
+	} {
+		resp, err := http.Get("http://" + addr + path)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !strings.Contains(string(body), want) {
+			t.Errorf("GET %s body missing %q", path, want)
+		}
+	}
+}
+
+func TestDumpOnSignalRejectsUnknownSignal(t *testing.T) {
+	if _, err := DumpOnSignal("SIGKILL", io.Discard, nil); err == nil {
+		t.Errorf("DumpOnSignal() expected error for unsupported signal")
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package debugdump
+
+import (
+	"fmt"
+	"os"
+)
+
+// parseSignal reports that signal-triggered dumps need a unix platform
+func parseSignal(name string) (os.Signal, error) {
+	return nil, fmt.Errorf("debug dump on %s is not supported on this platform", name)
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package debugdump
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// parseSignal maps a signal name to a signal usable for debug dumps
+func parseSignal(name string) (os.Signal, error) {
+	switch name {
+	case "SIGUSR1", "USR1":
+		return syscall.SIGUSR1, nil
+	case "SIGUSR2", "USR2":
+		return syscall.SIGUSR2, nil
+	default:
+		return nil, fmt.Errorf("unsupported debug dump signal %q (use SIGUSR1 or SIGUSR2)", name)
+	}
+}
@@ -0,0 +1,122 @@
+package docgen
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mako10k/llmcmd/internal/cli"
+)
+
+// optionValues lists the fixed values of options that take one
+var optionValues = map[string][]string{
+	"stats-format": {"table", "json", "csv"},
+}
+
+// longName returns the last (longest) name of an option
+func longName(option cli.Option) string {
+	return option.Names[len(option.Names)-1]
+}
+
+// dashed returns the names of an option with their dashes
+func dashed(option cli.Option) []string {
+	names := make([]string, len(option.Names))
+	for i, name := range option.Names {
+		if len(name) == 1 {
+			names[i] = "-" + name
+		} else {
+			names[i] = "--" + name
+		}
+	}
+	return names
+}
+
+// WriteBashCompletion writes the bash completion script for llmcmd
+func WriteBashCompletion(w io.Writer) error {
+	var all, files, free []string
+	var cases strings.Builder
+	for _, option := range cli.Options() {
+		names := dashed(option)
+		all = append(all, names...)
+		if values, ok := optionValues[longName(option)]; ok {
+			fmt.Fprintf(&cases, "        %s)\n            COMPREPLY=($(compgen -W %q -- \"$cur\"))\n            return ;;\n",
+				strings.Join(names, "|"), strings.Join(values, " "))
+		} else if option.Arg == "file" {
+			files = append(files, names...)
+		} else if option.Arg != "" {
+			free = append(free, names...)
+		}
+	}
+
+	fmt.Fprintf(w, "# bash completion for llmcmd (generated, do not edit)\n")
+	fmt.Fprintf(w, "_llmcmd() {\n")
+	fmt.Fprintf(w, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(w, "    case \"$prev\" in\n")
+	fmt.Fprintf(w, "        %s)\n            COMPREPLY=($(compgen -f -- \"$cur\"))\n            return ;;\n", strings.Join(files, "|"))
+	fmt.Fprintf(w, "%s", cases.String())
+	// Free-form arguments: nothing to complete
+	fmt.Fprintf(w, "        %s)\n            return ;;\n    esac\n", strings.Join(free, "|"))
+	fmt.Fprintf(w, "    if [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(all, " "))
+	fmt.Fprintf(w, "    fi\n}\n")
+	fmt.Fprintf(w, "complete -F _llmcmd llmcmd\n")
+	return nil
+}
+
+// zshEscape escapes text for a zsh _arguments description
+func zshEscape(text string) string {
+	return strings.NewReplacer(`'`, `'\''`, "[", `\[`, "]", `\]`).Replace(text)
+}
+
+// WriteZshCompletion writes the zsh completion script for llmcmd
+func WriteZshCompletion(w io.Writer) error {
+	fmt.Fprintf(w, "#compdef llmcmd\n# zsh completion for llmcmd (generated, do not edit)\n\n_arguments \\\n")
+	for _, option := range cli.Options() {
+		names := dashed(option)
+		spec := names[0]
+		if len(names) > 1 {
+			spec = fmt.Sprintf("(%s)'{%s}'", strings.Join(names, " "), strings.Join(names, ","))
+		}
+		// Input files may be given more than once
+		if longName(option) == "input" {
+			spec = "*'{" + strings.Join(names, ",") + "}'"
+		}
+
+		action := ""
+		if values, ok := optionValues[longName(option)]; ok {
+			action = fmt.Sprintf(":%s:(%s)", option.Arg, strings.Join(values, " "))
+		} else if option.Arg == "file" {
+			action = ":file:_files"
+		} else if option.Arg != "" {
+			action = fmt.Sprintf(":%s: ", option.Arg)
+		}
+		fmt.Fprintf(w, "  '%s[%s]%s' \\\n", spec, zshEscape(option.Usage), action)
+	}
+	fmt.Fprintf(w, "  '*::instructions: '\n")
+	return nil
+}
+
+// WriteFishCompletion writes the fish completion script for llmcmd
+func WriteFishCompletion(w io.Writer) error {
+	fmt.Fprintf(w, "# fish completion for llmcmd (generated, do not edit)\n")
+	for _, option := range cli.Options() {
+		line := "complete -c llmcmd"
+		for _, name := range option.Names {
+			if len(name) == 1 {
+				line += " -s " + name
+			} else {
+				line += " -l " + name
+			}
+		}
+		if values, ok := optionValues[longName(option)]; ok {
+			line += fmt.Sprintf(" -x -a '%s'", strings.Join(values, " "))
+		} else if option.Arg == "file" {
+			line += " -r -F"
+		} else if option.Arg != "" {
+			line += " -x"
+		}
+		line += " -d '" + strings.ReplaceAll(option.Usage, "'", `\'`) + "'"
+		fmt.Fprintln(w, line)
+	}
+	return nil
+}
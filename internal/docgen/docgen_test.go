@@ -0,0 +1,48 @@
+package docgen
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mako10k/llmcmd/internal/cli"
+	"github.com/mako10k/llmcmd/internal/openai"
+)
+
+func TestManPageCoversOptionsAndTools(t *testing.T) {
+	var page bytes.Buffer
+	if err := WriteLLMCmdManPage(&page, "2026-01-02"); err != nil {
+		t.Fatalf("WriteLLMCmdManPage failed: %v", err)
+	}
+	text := page.String()
+
+	for _, option := range cli.Options() {
+		if name := roffEscape(longName(option)); !strings.Contains(text, `\-\-`+name) && !strings.Contains(text, `\fB\-`+name) {
+			t.Errorf("man page does not document option %q", longName(option))
+		}
+	}
+	for _, tool := range openai.ToolDefinitions() {
+		if !strings.Contains(text, `\fB`+tool.Function.Name+`\fR(`) {
+			t.Errorf("man page does not document tool %q", tool.Function.Name)
+		}
+	}
+}
+
+func TestCompletionsListEveryOption(t *testing.T) {
+	writers := map[string]func(*bytes.Buffer) error{
+		"bash": func(b *bytes.Buffer) error { return WriteBashCompletion(b) },
+		"zsh":  func(b *bytes.Buffer) error { return WriteZshCompletion(b) },
+		"fish": func(b *bytes.Buffer) error { return WriteFishCompletion(b) },
+	}
+	for shell, write := range writers {
+		var script bytes.Buffer
+		if err := write(&script); err != nil {
+			t.Fatalf("%s completion failed: %v", shell, err)
+		}
+		for _, option := range cli.Options() {
+			if !strings.Contains(script.String(), longName(option)) {
+				t.Errorf("%s completion is missing option %q", shell, longName(option))
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Package docgen generates man pages and shell completions from the command
+// line, tool and llmsh help metadata, so packaged documentation always
+// matches the binary it ships with.
+package docgen
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mako10k/llmcmd/internal/buildinfo"
+	"github.com/mako10k/llmcmd/internal/cli"
+	"github.com/mako10k/llmcmd/internal/llmsh"
+	"github.com/mako10k/llmcmd/internal/openai"
+	"github.com/mako10k/llmcmd/internal/tools"
+	"github.com/mako10k/llmcmd/internal/vfsproxy"
+)
+
+// roffEscape escapes text for use in a roff paragraph
+func roffEscape(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\e`)
+	text = strings.ReplaceAll(text, "-", `\-`)
+	// A leading dot or quote would be read as a request
+	if strings.HasPrefix(text, ".") || strings.HasPrefix(text, "'") {
+		text = `\&` + text
+	}
+	return text
+}
+
+// manHeader writes the title and NAME sections shared by both pages
+func manHeader(w io.Writer, name, summary, date string) {
+	fmt.Fprintf(w, ".TH %s 1 %q %q \"User Commands\"\n", strings.ToUpper(name), date, name+" "+buildinfo.Version)
+	fmt.Fprintf(w, ".SH NAME\n%s \\- %s\n", name, roffEscape(summary))
+}
+
+// WriteLLMCmdManPage writes llmcmd(1)
+func WriteLLMCmdManPage(w io.Writer, date string) error {
+	manHeader(w, "llmcmd", "LLM command line tool for text processing", date)
+	fmt.Fprintf(w, ".SH SYNOPSIS\n.B llmcmd\n[\\fIOPTIONS\\fR] [\\fIINSTRUCTIONS\\fR]\n.br\n.B llmcmd estimate\n[\\fIOPTIONS\\fR] [\\fIINSTRUCTIONS\\fR]\n")
+	fmt.Fprintf(w, ".SH DESCRIPTION\n%s\n", roffEscape("llmcmd lets a large language model process text with a small set of built-in tools. "+
+		"The model reads the input files and standard input through file descriptors, runs llmsh scripts with spawn, "+
+		"and writes its result to standard output or the output file. "+
+		"llmcmd estimate predicts the token usage of a run and checks it against the quota without calling the API."))
+
+	fmt.Fprintf(w, ".SH OPTIONS\n")
+	for _, option := range cli.Options() {
+		var names []string
+		for _, name := range option.Names {
+			dashes := "\\-\\-"
+			if len(name) == 1 {
+				dashes = "\\-"
+			}
+			names = append(names, "\\fB"+dashes+roffEscape(name)+"\\fR")
+		}
+		fmt.Fprintf(w, ".TP\n%s", strings.Join(names, ", "))
+		if option.Arg != "" {
+			fmt.Fprintf(w, " \\fI%s\\fR", option.Arg)
+		}
+		fmt.Fprintf(w, "\n%s\n", roffEscape(option.Usage))
+	}
+
+	fmt.Fprintf(w, ".SH TOOLS\nThe model works through these tools:\n")
+	for _, tool := range openai.ToolDefinitions() {
+		writeToolEntry(w, tool.Function)
+	}
+
+	fmt.Fprintf(w, ".SH FILES\n.TP\n.I ~/.llmcmdrc\nDefault configuration file (see \\fB\\-\\-config\\fR).\n")
+	fmt.Fprintf(w, ".SH ENVIRONMENT\n")
+	for _, env := range [][2]string{
+		{"OPENAI_API_KEY", "API key used when the configuration file does not set one."},
+		{tools.ScratchDirEnv, "Set for spawned scripts: a scratch directory removed when the run ends."},
+		{vfsproxy.EnvVar, "Set for spawned scripts: the fd llmsh uses to reach llmcmd's virtual files."},
+	} {
+		fmt.Fprintf(w, ".TP\n.B %s\n%s\n", env[0], roffEscape(env[1]))
+	}
+	fmt.Fprintf(w, ".SH SEE ALSO\n.BR llmsh (1)\n")
+	return nil
+}
+
+// writeToolEntry writes one tool with its parameters, required ones first
+func writeToolEntry(w io.Writer, function openai.ToolFunction) {
+	properties, _ := function.Parameters["properties"].(map[string]interface{})
+	required := make(map[string]bool)
+	if names, ok := function.Parameters["required"].([]string); ok {
+		for _, name := range names {
+			required[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if required[names[i]] != required[names[j]] {
+			return required[names[i]]
+		}
+		return names[i] < names[j]
+	})
+
+	var signature []string
+	for _, name := range names {
+		if required[name] {
+			signature = append(signature, "\\fI"+name+"\\fR")
+		} else {
+			signature = append(signature, "[\\fI"+name+"\\fR]")
+		}
+	}
+	fmt.Fprintf(w, ".TP\n\\fB%s\\fR(%s)\n%s\n", function.Name, strings.Join(signature, ", "), roffEscape(function.Description))
+	for _, name := range names {
+		property, _ := properties[name].(map[string]interface{})
+		description, _ := property["description"].(string)
+		fmt.Fprintf(w, ".br\n\\fI%s\\fR: %s\n", name, roffEscape(description))
+	}
+}
+
+// placeholder matches argument names such as <file> in llmsh option flags
+var placeholder = regexp.MustCompile(`<([^>]+)>`)
+
+// WriteLLMShManPage writes llmsh(1)
+func WriteLLMShManPage(w io.Writer, date string) error {
+	manHeader(w, "llmsh", llmsh.Description, date)
+	fmt.Fprintf(w, ".SH SYNOPSIS\n.B llmsh\n[\\fIOPTIONS\\fR] [\\fISCRIPT\\fR]\n")
+	fmt.Fprintf(w, ".SH DESCRIPTION\n%s\n", roffEscape("llmsh runs pipelines of built-in text processing commands. "+
+		"It has no access to external programs; files are limited to the input and output files and virtual files."))
+
+	fmt.Fprintf(w, ".SH OPTIONS\n")
+	for _, option := range llmsh.CommandLineOptions {
+		flag := placeholder.ReplaceAllString(roffEscape(option.Flag), `\fI$1\fB`)
+		fmt.Fprintf(w, ".TP\n\\fB%s\\fR\n%s\n", flag, roffEscape(option.Description))
+	}
+
+	help := llmsh.NewHelpSystem()
+	fmt.Fprintf(w, ".SH COMMANDS\n")
+	for _, name := range help.ListCommands() {
+		command, err := help.GetHelp(name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, ".TP\n.B %s\n%s\n", roffEscape(command.Usage), roffEscape(command.Description))
+	}
+
+	fmt.Fprintf(w, ".SH ENVIRONMENT\n.TP\n.B %s\n%s\n", vfsproxy.EnvVar, roffEscape("Default for --vfs-fd."))
+	fmt.Fprintf(w, ".TP\n.B %s\n%s\n", vfsproxy.SocketEnvVar, roffEscape("Default for --vfs-socket."))
+	fmt.Fprintf(w, ".SH SEE ALSO\n.BR llmcmd (1)\n")
+	return nil
+}
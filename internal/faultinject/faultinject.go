@@ -0,0 +1,174 @@
+package faultinject
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EnvVar is the hidden environment variable that enables fault injection.
+//
+// Its value is a comma-separated list of faults, for example
+// "api_429=2,api_500=1,tool_delay=200ms,truncate_output=64,pipe_eof=16":
+//
+//	api_429=N          fail the next N API requests with HTTP 429
+//	api_500=N          fail the next N API requests with HTTP 500 (after any 429s)
+//	tool_delay=D       sleep for duration D before every tool call
+//	truncate_output=N  drop spawned command output after N bytes
+//	pipe_eof=N         give spawned commands EOF on stdin after N bytes
+const EnvVar = "LLMCMD_FAULT_INJECT"
+
+// Config describes the faults to inject
+type Config struct {
+	API429         int           // Remaining API requests to fail with 429
+	API500         int           // Remaining API requests to fail with 500
+	ToolDelay      time.Duration // Delay before each tool call
+	TruncateOutput int64         // Bytes of child output kept, -1 for no limit
+	PipeEOF        int64         // Bytes of child input delivered, -1 for no limit
+}
+
+var (
+	mu     sync.Mutex
+	active *Config
+)
+
+func init() {
+	spec := os.Getenv(EnvVar)
+	if spec == "" {
+		return
+	}
+	if err := Enable(spec); err != nil {
+		log.Printf("Warning: ignoring %s: %v", EnvVar, err)
+	}
+}
+
+// Parse parses a fault specification in the EnvVar format
+func Parse(spec string) (*Config, error) {
+	config := &Config{TruncateOutput: -1, PipeEOF: -1}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("fault %q: expected key=value", entry)
+		}
+
+		var err error
+		switch key {
+		case "api_429":
+			config.API429, err = strconv.Atoi(value)
+		case "api_500":
+			config.API500, err = strconv.Atoi(value)
+		case "tool_delay":
+			config.ToolDelay, err = time.ParseDuration(value)
+		case "truncate_output":
+			config.TruncateOutput, err = strconv.ParseInt(value, 10, 64)
+		case "pipe_eof":
+			config.PipeEOF, err = strconv.ParseInt(value, 10, 64)
+		default:
+			return nil, fmt.Errorf("unknown fault %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("fault %q: %w", key, err)
+		}
+	}
+
+	return config, nil
+}
+
+// Enable activates the faults described by spec, replacing any active ones
+func Enable(spec string) error {
+	config, err := Parse(spec)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	active = config
+	mu.Unlock()
+	return nil
+}
+
+// Disable turns fault injection off
+func Disable() {
+	mu.Lock()
+	active = nil
+	mu.Unlock()
+}
+
+// APIFailure consumes one injected API failure and returns its HTTP status
+func APIFailure() (int, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	if active == nil {
+		return 0, false
+	}
+	switch {
+	case active.API429 > 0:
+		active.API429--
+		return 429, true
+	case active.API500 > 0:
+		active.API500--
+		return 500, true
+	}
+	return 0, false
+}
+
+// ToolDelay returns the injected delay before a tool call
+func ToolDelay() time.Duration {
+	mu.Lock()
+	defer mu.Unlock()
+	if active == nil {
+		return 0
+	}
+	return active.ToolDelay
+}
+
+// WrapChildOutput truncates output written by a spawned command if requested
+func WrapChildOutput(w io.Writer) io.Writer {
+	mu.Lock()
+	defer mu.Unlock()
+	if active == nil || active.TruncateOutput < 0 {
+		return w
+	}
+	return &truncatingWriter{w: w, remaining: active.TruncateOutput}
+}
+
+// WrapChildInput ends input read by a spawned command early if requested
+func WrapChildInput(r io.Reader) io.Reader {
+	mu.Lock()
+	defer mu.Unlock()
+	if active == nil || active.PipeEOF < 0 || r == nil {
+		return r
+	}
+	return io.LimitReader(r, active.PipeEOF)
+}
+
+// truncatingWriter forwards up to remaining bytes and silently drops the rest
+type truncatingWriter struct {
+	w         io.Writer
+	remaining int64
+}
+
+func (t *truncatingWriter) Write(p []byte) (int, error) {
+	if t.remaining <= 0 {
+		return len(p), nil
+	}
+	keep := p
+	if int64(len(keep)) > t.remaining {
+		keep = keep[:t.remaining]
+	}
+	n, err := t.w.Write(keep)
+	t.remaining -= int64(n)
+	if err != nil {
+		return n, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,71 @@
+package faultinject
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	config, err := Parse("api_429=2, api_500=1,tool_delay=150ms,truncate_output=4,pipe_eof=0")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if config.API429 != 2 || config.API500 != 1 {
+		t.Errorf("API faults = %d/%d, want 2/1", config.API429, config.API500)
+	}
+	if config.ToolDelay != 150*time.Millisecond {
+		t.Errorf("ToolDelay = %v, want 150ms", config.ToolDelay)
+	}
+	if config.TruncateOutput != 4 || config.PipeEOF != 0 {
+		t.Errorf("TruncateOutput/PipeEOF = %d/%d, want 4/0", config.TruncateOutput, config.PipeEOF)
+	}
+
+	for _, spec := range []string{"api_429", "api_429=x", "disk_full=1"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) expected error", spec)
+		}
+	}
+}
+
+func TestAPIFailureOrder(t *testing.T) {
+	if err := Enable("api_429=1,api_500=1"); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+	defer Disable()
+
+	for _, want := range []int{429, 500} {
+		status, ok := APIFailure()
+		if !ok || status != want {
+			t.Errorf("APIFailure() = %d, %v, want %d, true", status, ok, want)
+		}
+	}
+	if _, ok := APIFailure(); ok {
+		t.Errorf("APIFailure() expected no more injected failures")
+	}
+}
+
+func TestChildStreams(t *testing.T) {
+	if err := Enable("truncate_output=5,pipe_eof=3"); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+	defer Disable()
+
+	var out bytes.Buffer
+	w := WrapChildOutput(&out)
+	for _, chunk := range []string{"abc", "def", "ghi"} {
+		if n, err := w.Write([]byte(chunk)); err != nil || n != len(chunk) {
+			t.Fatalf("Write(%q) = %d, %v", chunk, n, err)
+		}
+	}
+	if out.String() != "abcde" {
+		t.Errorf("truncated output = %q, want %q", out.String(), "abcde")
+	}
+
+	data, err := io.ReadAll(WrapChildInput(strings.NewReader("abcdef")))
+	if err != nil || string(data) != "abc" {
+		t.Errorf("limited input = %q, %v, want %q", data, err, "abc")
+	}
+}
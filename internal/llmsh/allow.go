@@ -0,0 +1,140 @@
+package llmsh
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mako10k/llmcmd/internal/llmsh/parser"
+)
+
+// notAllowedError is returned for a command outside the allowed commands
+type notAllowedError struct {
+	cmd  *parser.CommandNode
+	name string
+}
+
+func (e *notAllowedError) Error() string {
+	return fmt.Sprintf("line %d: %s: command not allowed", e.cmd.Line, e.name)
+}
+
+// ExitCode returns 126, the status of a command that cannot be run in sh
+func (e *notAllowedError) ExitCode() int {
+	return 126
+}
+
+// allowedSet converts a list of allowed commands to a set; nil allows all
+func allowedSet(names []string) map[string]bool {
+	if names == nil {
+		return nil
+	}
+	allowed := make(map[string]bool, len(names))
+	for _, name := range names {
+		allowed[name] = true
+	}
+	return allowed
+}
+
+// checkAllowed runs before a command, which must be allowed or a function
+func (e *Executor) checkAllowed(cmd *parser.CommandNode, name string) error {
+	if e.allowed == nil || e.allowed[name] {
+		return nil
+	}
+	if _, exists := e.functions[name]; exists {
+		return nil
+	}
+	return &notAllowedError{cmd: cmd, name: name}
+}
+
+// checkScript rejects a script running a command outside allowed before any
+// of it runs, including the commands in its command substitutions. Functions
+// and aliases the script defines may be called, as their own commands are
+// checked. Names that need expanding are only checked when they run.
+func checkScript(node parser.Node, allowed map[string]bool) error {
+	if allowed == nil {
+		return nil
+	}
+	defined := make(map[string]bool)
+	var commands []*parser.CommandNode
+	var walk func(node parser.Node)
+	walk = func(node parser.Node) {
+		switch n := node.(type) {
+		case *parser.ScriptNode:
+			for _, stmt := range n.Statements {
+				walk(stmt)
+			}
+		case *parser.SequenceNode:
+			for _, cmd := range n.Commands {
+				walk(cmd)
+			}
+		case *parser.ConditionalNode:
+			walk(n.Left)
+			walk(n.Right)
+		case *parser.BackgroundNode:
+			walk(n.Command)
+		case *parser.PipelineNode:
+			for _, cmd := range n.Commands {
+				walk(cmd)
+			}
+		case *parser.FunctionNode:
+			defined[n.Name] = true
+			for _, stmt := range n.Body {
+				walk(stmt)
+			}
+		case *parser.CommandNode:
+			commands = append(commands, n)
+			words := append([]string{n.Name}, n.Args...)
+			for _, redir := range n.Redirections {
+				words = append(words, redir.Target)
+			}
+			for _, word := range words {
+				for _, script := range substitutions(word) {
+					// A script that does not parse fails when it runs
+					if ast, err := parser.NewParser().Parse(script); err == nil {
+						walk(ast)
+					}
+				}
+			}
+			if n.Name == "alias" {
+				for _, arg := range n.Args {
+					if match := assignmentPattern.FindStringSubmatch(arg); match != nil {
+						defined[match[1]] = true
+					}
+				}
+			}
+		}
+	}
+	walk(node)
+
+	for _, cmd := range commands {
+		assignment := assignmentPattern.MatchString(cmd.Name) && len(cmd.Args) == 0 && len(cmd.Redirections) == 0
+		if assignment || strings.ContainsAny(cmd.Name, `$'"\`) || allowed[cmd.Name] || defined[cmd.Name] {
+			continue
+		}
+		return &notAllowedError{cmd: cmd, name: cmd.Name}
+	}
+	return nil
+}
+
+// substitutions returns the scripts of the command substitutions in word,
+// including those within arithmetic expansions, as expand finds them
+func substitutions(word string) []string {
+	var scripts []string
+	for i := 0; i < len(word)-1; i++ {
+		switch {
+		case word[i] == '\\' && word[i+1] == '$':
+			i++
+		case word[i] == '$' && word[i+1] == '(':
+			end := parser.MatchParen(word, i+1)
+			if end < 0 {
+				return scripts
+			}
+			if word[i+2] == '(' && parser.MatchParen(word, i+2) == end-1 {
+				scripts = append(scripts, substitutions(word[i+3:end-1])...)
+			} else {
+				scripts = append(scripts, word[i+2:end])
+			}
+			i = end
+		}
+	}
+	return scripts
+}
@@ -0,0 +1,366 @@
+package llmsh
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// arithOperators are the operators of arithmetic expressions, longest first
+// so that the lexer prefers "<<=" over "<<" and "<"
+var arithOperators = []string{
+	"<<=", ">>=",
+	"<=", ">=", "==", "!=", "&&", "||", "<<", ">>",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=",
+	"+", "-", "*", "/", "%", "(", ")", "<", ">", "!", "~", "&", "|", "^", "?", ":", "=",
+}
+
+// arithBinary lists the binary operators by precedence, lowest first, below
+// || and &&, which short-circuit and are handled apart
+var arithBinary = [][]string{
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// arith evaluates an arithmetic expression as in sh's $(( )): 64-bit integers,
+// C operators including assignments, and variables named without $
+type arith struct {
+	expr  string
+	pos   int
+	token string // Current token, "" at the end
+	vars  map[string]string
+	skip  int // Greater than 0 in a branch not taken, which has no effects
+}
+
+// evalArith evaluates expr, assigning to vars as the expression says
+func evalArith(expr string, vars map[string]string) (int64, error) {
+	a := &arith{expr: expr, vars: vars}
+	if err := a.next(); err != nil {
+		return 0, err
+	}
+	if a.token == "" {
+		return 0, nil
+	}
+	value, err := a.parseAssignment()
+	if err != nil {
+		return 0, err
+	}
+	if a.token != "" {
+		return 0, fmt.Errorf("arithmetic: unexpected %q in %q", a.token, expr)
+	}
+	return value, nil
+}
+
+// next reads the next token
+func (a *arith) next() error {
+	for a.pos < len(a.expr) && strings.IndexByte(" \t\n", a.expr[a.pos]) >= 0 {
+		a.pos++
+	}
+	if a.pos == len(a.expr) {
+		a.token = ""
+		return nil
+	}
+
+	start := a.pos
+	switch c := a.expr[a.pos]; {
+	case isNameChar(c, false):
+		for a.pos < len(a.expr) && isNameChar(a.expr[a.pos], false) {
+			a.pos++
+		}
+	default:
+		for _, op := range arithOperators {
+			if strings.HasPrefix(a.expr[a.pos:], op) {
+				a.pos += len(op)
+				break
+			}
+		}
+		if a.pos == start {
+			return fmt.Errorf("arithmetic: unexpected character %q in %q", c, a.expr)
+		}
+	}
+	a.token = a.expr[start:a.pos]
+	return nil
+}
+
+// accept consumes the current token if it is op
+func (a *arith) accept(op string) (bool, error) {
+	if a.token != op {
+		return false, nil
+	}
+	return true, a.next()
+}
+
+// parseAssignment parses name = expr, name op= expr, or a conditional
+func (a *arith) parseAssignment() (int64, error) {
+	name := a.token
+	if isArithName(name) {
+		// Look ahead for an assignment operator
+		pos, token := a.pos, a.token
+		if err := a.next(); err != nil {
+			return 0, err
+		}
+		if op := a.token; strings.HasSuffix(op, "=") && op != "==" && op != "!=" && op != "<=" && op != ">=" {
+			if err := a.next(); err != nil {
+				return 0, err
+			}
+			value, err := a.parseAssignment()
+			if err != nil {
+				return 0, err
+			}
+			if op != "=" {
+				current, err := a.variable(name)
+				if err != nil {
+					return 0, err
+				}
+				if value, err = a.apply(strings.TrimSuffix(op, "="), current, value); err != nil {
+					return 0, err
+				}
+			}
+			if a.skip == 0 {
+				a.vars[name] = strconv.FormatInt(value, 10)
+			}
+			return value, nil
+		}
+		a.pos, a.token = pos, token
+	}
+	return a.parseConditional()
+}
+
+// parseConditional parses cond ? a : b, evaluating only the branch taken
+func (a *arith) parseConditional() (int64, error) {
+	cond, err := a.parseLogical("||")
+	if err != nil {
+		return 0, err
+	}
+	if ok, err := a.accept("?"); !ok || err != nil {
+		return cond, err
+	}
+
+	ifTrue, err := a.parseBranch(cond == 0, a.parseAssignment)
+	if err != nil {
+		return 0, err
+	}
+	if ok, err := a.accept(":"); err != nil {
+		return 0, err
+	} else if !ok {
+		return 0, fmt.Errorf("arithmetic: expected ':' in %q", a.expr)
+	}
+	ifFalse, err := a.parseBranch(cond != 0, a.parseAssignment)
+	if err != nil {
+		return 0, err
+	}
+	if cond != 0 {
+		return ifTrue, nil
+	}
+	return ifFalse, nil
+}
+
+// parseLogical parses operands joined by || or &&, which short-circuit
+func (a *arith) parseLogical(op string) (int64, error) {
+	operand := func() (int64, error) {
+		if op == "||" {
+			return a.parseLogical("&&")
+		}
+		return a.parseBinary(0)
+	}
+
+	left, err := operand()
+	if err != nil {
+		return 0, err
+	}
+	for a.token == op {
+		if err := a.next(); err != nil {
+			return 0, err
+		}
+		decided := (op == "||") == (left != 0)
+		right, err := a.parseBranch(decided, operand)
+		if err != nil {
+			return 0, err
+		}
+		if !decided {
+			left = right
+		}
+		left = boolInt(left != 0)
+	}
+	return left, nil
+}
+
+// parseBranch parses an operand, without effects when skipped
+func (a *arith) parseBranch(skipped bool, parse func() (int64, error)) (int64, error) {
+	if skipped {
+		a.skip++
+		defer func() { a.skip-- }()
+	}
+	return parse()
+}
+
+// parseBinary parses the binary operators of precedence level and above
+func (a *arith) parseBinary(level int) (int64, error) {
+	if level == len(arithBinary) {
+		return a.parseUnary()
+	}
+	left, err := a.parseBinary(level + 1)
+	if err != nil {
+		return 0, err
+	}
+	for containsString(arithBinary[level], a.token) {
+		op := a.token
+		if err := a.next(); err != nil {
+			return 0, err
+		}
+		right, err := a.parseBinary(level + 1)
+		if err != nil {
+			return 0, err
+		}
+		if left, err = a.apply(op, left, right); err != nil {
+			return 0, err
+		}
+	}
+	return left, nil
+}
+
+// parseUnary parses unary operators, numbers, variables and parentheses
+func (a *arith) parseUnary() (int64, error) {
+	token := a.token
+	switch {
+	case token == "":
+		return 0, fmt.Errorf("arithmetic: missing operand in %q", a.expr)
+	case token == "-" || token == "+" || token == "!" || token == "~":
+		if err := a.next(); err != nil {
+			return 0, err
+		}
+		value, err := a.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		switch token {
+		case "-":
+			return -value, nil
+		case "!":
+			return boolInt(value == 0), nil
+		case "~":
+			return ^value, nil
+		}
+		return value, nil
+	case token == "(":
+		if err := a.next(); err != nil {
+			return 0, err
+		}
+		value, err := a.parseAssignment()
+		if err != nil {
+			return 0, err
+		}
+		if ok, err := a.accept(")"); err != nil {
+			return 0, err
+		} else if !ok {
+			return 0, fmt.Errorf("arithmetic: missing ')' in %q", a.expr)
+		}
+		return value, nil
+	case token[0] >= '0' && token[0] <= '9':
+		value, err := strconv.ParseInt(token, 0, 64)
+		if err != nil {
+			return 0, fmt.Errorf("arithmetic: invalid number %q", token)
+		}
+		return value, a.next()
+	case isArithName(token):
+		value, err := a.variable(token)
+		if err != nil {
+			return 0, err
+		}
+		return value, a.next()
+	default:
+		return 0, fmt.Errorf("arithmetic: unexpected %q in %q", token, a.expr)
+	}
+}
+
+// variable returns the value of a variable; unset and empty ones are 0
+func (a *arith) variable(name string) (int64, error) {
+	text := strings.TrimSpace(a.vars[name])
+	if text == "" {
+		return 0, nil
+	}
+	value, err := strconv.ParseInt(text, 0, 64)
+	if err != nil {
+		if a.skip > 0 {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("arithmetic: %s: not a number: %q", name, text)
+	}
+	return value, nil
+}
+
+// apply applies a binary operator
+func (a *arith) apply(op string, left, right int64) (int64, error) {
+	switch op {
+	case "|":
+		return left | right, nil
+	case "^":
+		return left ^ right, nil
+	case "&":
+		return left & right, nil
+	case "==":
+		return boolInt(left == right), nil
+	case "!=":
+		return boolInt(left != right), nil
+	case "<":
+		return boolInt(left < right), nil
+	case "<=":
+		return boolInt(left <= right), nil
+	case ">":
+		return boolInt(left > right), nil
+	case ">=":
+		return boolInt(left >= right), nil
+	case "<<":
+		return left << uint64(right&63), nil
+	case ">>":
+		return left >> uint64(right&63), nil
+	case "+":
+		return left + right, nil
+	case "-":
+		return left - right, nil
+	case "*":
+		return left * right, nil
+	case "/", "%":
+		if right == 0 {
+			if a.skip > 0 {
+				return 0, nil
+			}
+			return 0, fmt.Errorf("arithmetic: division by zero in %q", a.expr)
+		}
+		if op == "/" {
+			return left / right, nil
+		}
+		return left % right, nil
+	default:
+		return 0, fmt.Errorf("arithmetic: unknown operator %q", op)
+	}
+}
+
+// isArithName reports whether token is a variable name
+func isArithName(token string) bool {
+	return token != "" && isNameChar(token[0], true)
+}
+
+// boolInt converts a truth value to 1 or 0
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
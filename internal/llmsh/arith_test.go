@@ -0,0 +1,78 @@
+package llmsh
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEvalArith(t *testing.T) {
+	tests := []struct {
+		expr  string
+		value int64
+	}{
+		{"", 0},
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"7 / 2", 3},
+		{"-7 % 3", -1},
+		{"2 - 3 - 4", -5},
+		{"0x10 + 010 + 0b11", 27},
+		{"1 << 4 | 1", 17},
+		{"!0 + !5 + ~0", 0},
+		{"3 > 2 && 2 >= 2 && 1 != 2 && 1 == 1", 1},
+		{"0 || 0", 0},
+		{"n * 2", 20},
+		{"missing + 1", 1},
+		{"n > 5 ? n : -n", 10},
+		{"0 ? 1 : 0 ? 2 : 3", 3},
+		{"0 && 1 / 0", 0},
+		{"1 || (x = 5)", 1},
+	}
+
+	for _, test := range tests {
+		vars := map[string]string{"n": "10"}
+		value, err := evalArith(test.expr, vars)
+		if err != nil || value != test.value {
+			t.Errorf("evalArith(%q) = %d, %v, want %d", test.expr, value, err, test.value)
+		}
+		if _, assigned := vars["x"]; assigned {
+			t.Errorf("evalArith(%q) assigned x in a branch not taken", test.expr)
+		}
+	}
+}
+
+func TestEvalArithAssignments(t *testing.T) {
+	vars := map[string]string{"i": "4"}
+	for _, expr := range []string{"i += 2", "i *= 3", "j = i - 8", "k = j <<= 1"} {
+		if _, err := evalArith(expr, vars); err != nil {
+			t.Fatalf("evalArith(%q) failed: %v", expr, err)
+		}
+	}
+	if vars["i"] != "18" || vars["j"] != "20" || vars["k"] != "20" {
+		t.Errorf("vars = %v, want i=18 j=20 k=20", vars)
+	}
+}
+
+func TestEvalArithErrors(t *testing.T) {
+	tests := []struct {
+		expr  string
+		error string
+	}{
+		{"1 / 0", "division by zero"},
+		{"5 % (2 - 2)", "division by zero"},
+		{"1 +", "missing operand"},
+		{"(1 + 2", "missing ')'"},
+		{"1 ? 2", "expected ':'"},
+		{"2 3", "unexpected"},
+		{"1 $ 2", "unexpected character"},
+		{"s + 1", "not a number"},
+		{"09", "invalid number"},
+	}
+
+	for _, test := range tests {
+		_, err := evalArith(test.expr, map[string]string{"s": "text"})
+		if err == nil || !strings.Contains(err.Error(), test.error) {
+			t.Errorf("evalArith(%q) error = %v, want one containing %q", test.expr, err, test.error)
+		}
+	}
+}
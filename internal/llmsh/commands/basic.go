@@ -3,7 +3,6 @@ package commands
 import (
 	"fmt"
 	"io"
-	"strconv"
 	"strings"
 )
 
@@ -26,23 +25,6 @@ func (b *BasicCommands) ExecuteEcho(args []string, stdin io.ReadWriteCloser, std
 	return err
 }
 
-// ExecutePrintf implements printf command
-func (b *BasicCommands) ExecutePrintf(args []string, stdin io.ReadWriteCloser, stdout io.ReadWriteCloser) error {
-	if len(args) == 0 {
-		return nil
-	}
-
-	format := args[0]
-	values := make([]interface{}, len(args)-1)
-	for i, arg := range args[1:] {
-		values[i] = arg
-	}
-
-	output := fmt.Sprintf(format, values...)
-	_, err := stdout.Write([]byte(output))
-	return err
-}
-
 // ExecuteTrue implements true command
 func (b *BasicCommands) ExecuteTrue(args []string, stdin io.ReadWriteCloser, stdout io.ReadWriteCloser) error {
 	return nil
@@ -116,67 +98,3 @@ func (b *BasicCommands) ExecuteDirname(args []string, stdin io.ReadWriteCloser,
 	_, err := stdout.Write([]byte(path + "\n"))
 	return err
 }
-
-// ExecuteSeq implements seq command
-func (b *BasicCommands) ExecuteSeq(args []string, stdin io.ReadWriteCloser, stdout io.ReadWriteCloser) error {
-	if len(args) == 0 {
-		return fmt.Errorf("seq: missing operand")
-	}
-
-	var start, end, step int = 1, 1, 1
-	var err error
-
-	switch len(args) {
-	case 1:
-		end, err = strconv.Atoi(args[0])
-		if err != nil {
-			return fmt.Errorf("seq: invalid number: %s", args[0])
-		}
-	case 2:
-		start, err = strconv.Atoi(args[0])
-		if err != nil {
-			return fmt.Errorf("seq: invalid number: %s", args[0])
-		}
-		end, err = strconv.Atoi(args[1])
-		if err != nil {
-			return fmt.Errorf("seq: invalid number: %s", args[1])
-		}
-	case 3:
-		start, err = strconv.Atoi(args[0])
-		if err != nil {
-			return fmt.Errorf("seq: invalid number: %s", args[0])
-		}
-		step, err = strconv.Atoi(args[1])
-		if err != nil {
-			return fmt.Errorf("seq: invalid number: %s", args[1])
-		}
-		end, err = strconv.Atoi(args[2])
-		if err != nil {
-			return fmt.Errorf("seq: invalid number: %s", args[2])
-		}
-	default:
-		return fmt.Errorf("seq: too many arguments")
-	}
-
-	if step == 0 {
-		return fmt.Errorf("seq: step cannot be zero")
-	}
-
-	if step > 0 {
-		for i := start; i <= end; i += step {
-			_, err := stdout.Write([]byte(fmt.Sprintf("%d\n", i)))
-			if err != nil {
-				return err
-			}
-		}
-	} else {
-		for i := start; i >= end; i += step {
-			_, err := stdout.Write([]byte(fmt.Sprintf("%d\n", i)))
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
-}
@@ -1,10 +1,11 @@
 package commands
 
 import (
-	"encoding/base64"
 	"fmt"
 	"io"
 	"strings"
+
+	"github.com/mako10k/llmcmd/internal/tools/builtin"
 )
 
 // EncodingCommands contains encoding and decoding commands
@@ -210,121 +211,32 @@ func (e *EncodingCommands) uudecodeChunk(line string) ([]byte, error) {
 	return result, nil
 }
 
-// ExecuteGzip implements gzip compression (simplified)
+// ExecuteGzip implements gzip compression and decompression
 func (e *EncodingCommands) ExecuteGzip(args []string, stdin io.ReadWriteCloser, stdout io.ReadWriteCloser) error {
-	decompress := false
-
-	// Parse arguments
-	for _, arg := range args {
-		if arg == "-d" || arg == "--decompress" {
-			decompress = true
-		}
-	}
-
-	input, err := io.ReadAll(stdin)
-	if err != nil {
-		return fmt.Errorf("gzip: error reading input: %w", err)
-	}
-
-	if decompress {
-		// For simplicity, we'll use base64 as a compression placeholder
-		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(input)))
-		if err != nil {
-			return fmt.Errorf("gzip: decompression error: %w", err)
-		}
-		_, err = stdout.Write(decoded)
-		return err
-	} else {
-		// For simplicity, we'll use base64 as a compression placeholder
-		encoded := base64.StdEncoding.EncodeToString(input)
-		_, err := stdout.Write([]byte(encoded + "\n"))
-		return err
-	}
+	return builtin.Gzip(args, stdin, stdout)
 }
 
 // ExecuteGunzip implements gunzip decompression (alias for gzip -d)
 func (e *EncodingCommands) ExecuteGunzip(args []string, stdin io.ReadWriteCloser, stdout io.ReadWriteCloser) error {
-	return e.ExecuteGzip(append([]string{"-d"}, args...), stdin, stdout)
+	return builtin.Gunzip(args, stdin, stdout)
 }
 
-// ExecuteBzip2 implements bzip2 compression (simplified)
+// ExecuteBzip2 implements bzip2 decompression; compressing is not supported
 func (e *EncodingCommands) ExecuteBzip2(args []string, stdin io.ReadWriteCloser, stdout io.ReadWriteCloser) error {
-	decompress := false
-
-	// Parse arguments
-	for _, arg := range args {
-		if arg == "-d" || arg == "--decompress" {
-			decompress = true
-		}
-	}
-
-	input, err := io.ReadAll(stdin)
-	if err != nil {
-		return fmt.Errorf("bzip2: error reading input: %w", err)
-	}
-
-	if decompress {
-		// For simplicity, we'll use base64 as a compression placeholder with prefix
-		text := strings.TrimSpace(string(input))
-		if !strings.HasPrefix(text, "BZ2:") {
-			return fmt.Errorf("bzip2: not a bzip2 file")
-		}
-		decoded, err := base64.StdEncoding.DecodeString(text[4:])
-		if err != nil {
-			return fmt.Errorf("bzip2: decompression error: %w", err)
-		}
-		_, err = stdout.Write(decoded)
-		return err
-	} else {
-		// For simplicity, we'll use base64 as a compression placeholder with prefix
-		encoded := "BZ2:" + base64.StdEncoding.EncodeToString(input)
-		_, err := stdout.Write([]byte(encoded + "\n"))
-		return err
-	}
+	return builtin.Bzip2(args, stdin, stdout)
 }
 
 // ExecuteBunzip2 implements bunzip2 decompression (alias for bzip2 -d)
 func (e *EncodingCommands) ExecuteBunzip2(args []string, stdin io.ReadWriteCloser, stdout io.ReadWriteCloser) error {
-	return e.ExecuteBzip2(append([]string{"-d"}, args...), stdin, stdout)
+	return builtin.Bunzip2(args, stdin, stdout)
 }
 
-// ExecuteXz implements xz compression (simplified)
+// ExecuteXz implements xz decompression; compressing is not supported
 func (e *EncodingCommands) ExecuteXz(args []string, stdin io.ReadWriteCloser, stdout io.ReadWriteCloser) error {
-	decompress := false
-
-	// Parse arguments
-	for _, arg := range args {
-		if arg == "-d" || arg == "--decompress" {
-			decompress = true
-		}
-	}
-
-	input, err := io.ReadAll(stdin)
-	if err != nil {
-		return fmt.Errorf("xz: error reading input: %w", err)
-	}
-
-	if decompress {
-		// For simplicity, we'll use base64 as a compression placeholder with prefix
-		text := strings.TrimSpace(string(input))
-		if !strings.HasPrefix(text, "XZ:") {
-			return fmt.Errorf("xz: not an xz file")
-		}
-		decoded, err := base64.StdEncoding.DecodeString(text[3:])
-		if err != nil {
-			return fmt.Errorf("xz: decompression error: %w", err)
-		}
-		_, err = stdout.Write(decoded)
-		return err
-	} else {
-		// For simplicity, we'll use base64 as a compression placeholder with prefix
-		encoded := "XZ:" + base64.StdEncoding.EncodeToString(input)
-		_, err := stdout.Write([]byte(encoded + "\n"))
-		return err
-	}
+	return builtin.Xz(args, stdin, stdout)
 }
 
 // ExecuteUnxz implements unxz decompression (alias for xz -d)
 func (e *EncodingCommands) ExecuteUnxz(args []string, stdin io.ReadWriteCloser, stdout io.ReadWriteCloser) error {
-	return e.ExecuteXz(append([]string{"-d"}, args...), stdin, stdout)
+	return builtin.Unxz(args, stdin, stdout)
 }
@@ -32,8 +32,6 @@ func (m *Manager) Execute(name string, args []string, stdin io.ReadWriteCloser,
 	// Basic commands
 	case "echo":
 		return m.Basic.ExecuteEcho(args, stdin, stdout)
-	case "printf":
-		return m.Basic.ExecutePrintf(args, stdin, stdout)
 	case "true":
 		return m.Basic.ExecuteTrue(args, stdin, stdout)
 	case "false":
@@ -44,8 +42,6 @@ func (m *Manager) Execute(name string, args []string, stdin io.ReadWriteCloser,
 		return m.Basic.ExecuteBasename(args, stdin, stdout)
 	case "dirname":
 		return m.Basic.ExecuteDirname(args, stdin, stdout)
-	case "seq":
-		return m.Basic.ExecuteSeq(args, stdin, stdout)
 
 	// Conversion commands
 	case "base64":
@@ -54,14 +50,6 @@ func (m *Manager) Execute(name string, args []string, stdin io.ReadWriteCloser,
 		return m.Conversion.ExecuteOd(args, stdin, stdout)
 	case "hexdump":
 		return m.Conversion.ExecuteHexdump(args, stdin, stdout)
-	case "fmt":
-		return m.Conversion.ExecuteFmt(args, stdin, stdout)
-	case "fold":
-		return m.Conversion.ExecuteFold(args, stdin, stdout)
-	case "expand":
-		return m.Conversion.ExecuteExpand(args, stdin, stdout)
-	case "unexpand":
-		return m.Conversion.ExecuteUnexpand(args, stdin, stdout)
 
 	// Calculation commands
 	case "bc":
@@ -73,13 +61,7 @@ func (m *Manager) Execute(name string, args []string, stdin io.ReadWriteCloser,
 	case "test", "[":
 		return m.Calculation.ExecuteTest(args, stdin, stdout)
 
-	// Split commands
-	case "split":
-		return m.Split.ExecuteSplit(args, stdin, stdout)
-	case "join":
-		return m.Split.ExecuteJoin(args, stdin, stdout)
-	case "comm":
-		return m.Split.ExecuteComm(args, stdin, stdout)
+	// Split commands; split, join and comm work with virtual files, so llmsh runs them
 	case "csplit":
 		return m.Split.ExecuteCsplit(args, stdin, stdout)
 
@@ -107,27 +89,36 @@ func (m *Manager) Execute(name string, args []string, stdin io.ReadWriteCloser,
 	}
 }
 
-// IsInternalCommand checks if a command is implemented internally
-func (m *Manager) IsInternalCommand(name string) bool {
-	internalCommands := map[string]bool{
-		// Basic commands
-		"echo": true, "printf": true, "true": true, "false": true,
-		"yes": true, "basename": true, "dirname": true, "seq": true,
+// internalCommands are the commands implemented by the Manager
+var internalCommands = map[string]bool{
+	// Basic commands
+	"echo": true, "true": true, "false": true,
+	"yes": true, "basename": true, "dirname": true,
 
-		// Conversion commands
-		"base64": true, "od": true, "hexdump": true, "fmt": true,
-		"fold": true, "expand": true, "unexpand": true,
+	// Conversion commands
+	"base64": true, "od": true, "hexdump": true,
 
-		// Calculation commands
-		"bc": true, "dc": true, "expr": true, "test": true, "[": true,
+	// Calculation commands
+	"bc": true, "dc": true, "expr": true, "test": true, "[": true,
 
-		// Split commands
-		"split": true, "join": true, "comm": true, "csplit": true,
+	// Split commands
+	"csplit": true,
 
-		// Encoding commands
-		"uuencode": true, "uudecode": true, "gzip": true, "gunzip": true,
-		"bzip2": true, "bunzip2": true, "xz": true, "unxz": true,
-	}
+	// Encoding commands
+	"uuencode": true, "uudecode": true, "gzip": true, "gunzip": true,
+	"bzip2": true, "bunzip2": true, "xz": true, "unxz": true,
+}
 
+// IsInternalCommand checks if a command is implemented internally
+func (m *Manager) IsInternalCommand(name string) bool {
 	return internalCommands[name]
 }
+
+// CommandNames returns the names of the internal commands
+func (m *Manager) CommandNames() []string {
+	names := make([]string, 0, len(internalCommands))
+	for name := range internalCommands {
+		names = append(names, name)
+	}
+	return names
+}
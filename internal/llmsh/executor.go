@@ -1,14 +1,20 @@
 package llmsh
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/mako10k/llmcmd/internal/app"
+	"github.com/mako10k/llmcmd/internal/buildinfo"
 	"github.com/mako10k/llmcmd/internal/llmsh/commands"
 	"github.com/mako10k/llmcmd/internal/llmsh/parser"
+	"github.com/mako10k/llmcmd/internal/llmsh/readline"
 	"github.com/mako10k/llmcmd/internal/openai"
 	"github.com/mako10k/llmcmd/internal/tools/builtin"
 )
@@ -19,6 +25,39 @@ type Executor struct {
 	help         *HelpSystem
 	quotaManager interface{} // Will be properly typed later
 	commands     *Commands
+	vars         map[string]string  // Shell variables set by NAME=value
+	status       int                // Exit status of the last command ($?)
+	stdin        io.ReadWriteCloser // Default stdin in place of the VFS's, for background jobs
+	stdout       io.ReadWriteCloser // Default stdout in place of the VFS's, for command substitution
+	stderr       io.ReadWriteCloser // Default stderr in place of the VFS's, for function calls
+	jobs         *jobTable          // Background jobs started with &
+	options      options            // Options changed with set
+	timings      *timingStats       // Run time of each command, with set -o timing
+
+	functions map[string]*parser.FunctionNode // Functions defined with name() { ...; }
+	aliases   map[string]string               // Aliases defined with alias name=value
+	expanding map[string]bool                 // Aliases being expanded, not to be expanded again
+	args      []string                        // Arguments of the function being called ($1, $2, ...)
+	calls     int                             // Function calls in progress
+	depth     int                             // Function calls and alias expansions in progress
+	allowed   map[string]bool                 // Commands scripts may run, nil for all
+	history   *readline.History               // Interactive history, for the history builtin
+	scriptLog string                          // Log of the scripts run in the project, "" for none
+
+	transcript *transcript // Records the statements run and their status, not in subshells
+
+	failed *failure // Last command that failed, reported if the script fails
+
+	lockOwner string          // Owner of the locks taken with vlock, "" until the first
+	locked    map[string]bool // Names locked with vlock and not yet unlocked
+
+	ctx context.Context // Stops the script between commands once done, nil for never
+}
+
+// failure is a command that failed and its error
+type failure struct {
+	cmd *parser.CommandNode
+	err error
 }
 
 // NewExecutor creates a new executor
@@ -28,7 +67,73 @@ func NewExecutor(vfs *VirtualFileSystem, help *HelpSystem, quotaManager interfac
 		help:         help,
 		quotaManager: quotaManager,
 		commands:     NewCommands(vfs, help, quotaManager),
+		vars:         make(map[string]string),
+		jobs:         &jobTable{jobs: make(map[int]*job)},
+		timings:      &timingStats{commands: make(map[string]*commandTiming)},
+		functions:    make(map[string]*parser.FunctionNode),
+		aliases:      make(map[string]string),
+		expanding:    make(map[string]bool),
+	}
+}
+
+// subshell returns an executor sharing the VFS and commands, with a copy of
+// the variables, functions and aliases and no jobs, for command substitutions
+// and background jobs
+func (e *Executor) subshell() *Executor {
+	sub := &Executor{
+		vfs:          e.vfs,
+		help:         e.help,
+		quotaManager: e.quotaManager,
+		commands:     e.commands,
+		vars:         make(map[string]string, len(e.vars)),
+		status:       e.status,
+		stdin:        e.stdin,
+		stdout:       e.stdout,
+		stderr:       e.stderr,
+		jobs:         &jobTable{jobs: make(map[int]*job)},
+		options:      e.options,
+		timings:      e.timings,
+		functions:    make(map[string]*parser.FunctionNode, len(e.functions)),
+		aliases:      make(map[string]string, len(e.aliases)),
+		expanding:    make(map[string]bool, len(e.expanding)),
+		args:         e.args,
+		calls:        e.calls,
+		depth:        e.depth,
+		allowed:      e.allowed,
+		history:      e.history,
+		scriptLog:    e.scriptLog,
+		ctx:          e.ctx,
+	}
+	for name, value := range e.vars {
+		sub.vars[name] = value
+	}
+	for name, fn := range e.functions {
+		sub.functions[name] = fn
+	}
+	for name, value := range e.aliases {
+		sub.aliases[name] = value
+	}
+	for name := range e.expanding {
+		sub.expanding[name] = true
+	}
+	return sub
+}
+
+// stopped returns the context's error once the script has been stopped
+func (e *Executor) stopped() error {
+	if e.ctx == nil {
+		return nil
+	}
+	return e.ctx.Err()
+}
+
+// context returns the context commands run in, which is never done when the
+// executor has none
+func (e *Executor) context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
 	}
+	return e.ctx
 }
 
 // Execute executes a parsed AST node
@@ -44,10 +149,12 @@ func (e *Executor) Execute(node parser.Node) error {
 		return e.executeSequence(n)
 	case *parser.ConditionalNode:
 		return e.executeConditional(n)
-	case *parser.ComplexCommandNode:
-		return e.executeComplexCommand(n)
+	case *parser.BackgroundNode:
+		return e.executeBackground(n)
+	case *parser.FunctionNode:
+		return e.defineFunction(n)
 	case *parser.PipelineNode:
-		return e.executePipeline(n, nil)
+		return e.executePipeline(n)
 	case *parser.CommandNode:
 		return e.executeCommand(n, nil, nil, nil)
 	default:
@@ -55,29 +162,84 @@ func (e *Executor) Execute(node parser.Node) error {
 	}
 }
 
-// executeScript executes a script (multiple statements)
+// executeScript executes a script (multiple statements). As in sh, a failing
+// statement does not stop the script unless set -e is in effect; later
+// statements can check $?.
 func (e *Executor) executeScript(script *parser.ScriptNode) error {
-	for _, stmt := range script.Statements {
-		if err := e.Execute(stmt); err != nil {
-			return err
+	var err error
+	// Statements of the session itself go in the transcript, not function bodies
+	recording := e.transcript != nil && e.depth == 0
+	for i, stmt := range script.Statements {
+		e.reportError(err)
+		if recording {
+			source := stmt.String()
+			if i < len(script.Sources) {
+				source = script.Sources[i]
+			}
+			e.transcript.record(transcriptEvent{Stream: "command", Data: source})
+		}
+		err = e.Execute(stmt)
+		if recording {
+			e.transcript.recordStatus(exitStatus(err))
+		}
+		if e.stopsScript(err) {
+			break
 		}
 	}
-	return nil
+	return err
 }
 
-// executeSequence executes sequential commands
+// executeSequence executes sequential commands, returning the last one's error
 func (e *Executor) executeSequence(seq *parser.SequenceNode) error {
+	var err error
 	for _, cmd := range seq.Commands {
-		if err := e.Execute(cmd); err != nil {
-			return err
+		e.reportError(err)
+		err = e.Execute(cmd)
+		if e.stopsScript(err) {
+			break
 		}
 	}
-	return nil
+	return err
+}
+
+// reportError writes the error of a statement that did not end the script to stderr
+func (e *Executor) reportError(err error) {
+	if err == nil || isBareStatus(err) {
+		// A bare exit status is only reported through $?
+		return
+	}
+	fmt.Fprintf(e.errorStream(), "llmsh: %v\n", err)
+}
+
+// isBareStatus reports whether err only carries an exit status, with no
+// message of its own, like grep selecting no line or diff finding differences
+func isBareStatus(err error) bool {
+	var status exitError
+	var ret returnStatus
+	var grep *builtin.GrepExitError
+	var diff *builtin.DiffExitError
+	return errors.As(err, &status) || errors.As(err, &ret) ||
+		errors.As(err, &grep) && grep.Err == nil || errors.As(err, &diff) && diff.Err == nil
+}
+
+// errorStream returns the shell's stderr, for its own messages
+func (e *Executor) errorStream() io.Writer {
+	if e.stderr != nil {
+		return e.stderr
+	}
+	if stderr, err := e.vfs.OpenForWrite("stderr", false); err == nil {
+		return stderr
+	}
+	return io.Discard
 }
 
 // executeConditional executes conditional commands (&& or ||)
 func (e *Executor) executeConditional(cond *parser.ConditionalNode) error {
 	leftErr := e.Execute(cond.Left)
+	var ret returnStatus
+	if errors.As(leftErr, &ret) {
+		return leftErr
+	}
 
 	switch cond.Operator {
 	case "&&":
@@ -85,7 +247,7 @@ func (e *Executor) executeConditional(cond *parser.ConditionalNode) error {
 		if leftErr == nil {
 			return e.Execute(cond.Right)
 		}
-		return leftErr
+		return testedError{leftErr}
 
 	case "||":
 		// Execute right only if left failed
@@ -99,225 +261,366 @@ func (e *Executor) executeConditional(cond *parser.ConditionalNode) error {
 	}
 }
 
-// executeComplexCommand executes a command with redirections
-func (e *Executor) executeComplexCommand(complex *parser.ComplexCommandNode) error {
-	redirections := make(map[string]io.ReadWriteCloser)
+// openRedirection opens the target of a file redirection in the VFS
+func (e *Executor) openRedirection(redirType parser.RedirectionType, target string) (io.ReadWriteCloser, error) {
+	var file interface{}
+	var err error
+	switch redirType {
+	case parser.RedirOut, parser.RedirErr, parser.RedirAll:
+		file, err = e.vfs.OpenForWrite(target, false)
+	case parser.RedirAppend:
+		file, err = e.vfs.OpenForWrite(target, true)
+	case parser.RedirIn:
+		file, err = e.vfs.OpenForRead(target)
+	default:
+		return nil, fmt.Errorf("unknown redirection type")
+	}
+	if err != nil {
+		return nil, err
+	}
+	rwc, ok := file.(io.ReadWriteCloser)
+	if !ok {
+		return nil, fmt.Errorf("%s does not support read/write", target)
+	}
+	return rwc, nil
+}
 
-	// Set up redirections
-	for _, redir := range complex.Redirections {
-		if err := e.setupRedirection(redir, redirections); err != nil {
-			e.cleanupRedirections(redirections)
-			return err
-		}
+// executePipeline executes a pipeline of commands
+func (e *Executor) executePipeline(pipeline *parser.PipelineNode) error {
+	if pipeline.Timed {
+		return e.executeTimedPipeline(pipeline)
 	}
+	return e.runPipeline(pipeline, nil)
+}
 
-	// Execute the pipeline with redirections
-	err := e.executePipeline(complex.Pipeline, redirections)
+// runPipeline runs the commands of a pipeline, storing the run time of each
+// in elapsed unless it is nil
+func (e *Executor) runPipeline(pipeline *parser.PipelineNode, elapsed []time.Duration) error {
+	if len(pipeline.Commands) == 0 {
+		return nil
+	}
 
-	// Clean up redirections
-	e.cleanupRedirections(redirections)
+	if len(pipeline.Commands) == 1 {
+		start := now()
+		err := e.executeCommand(pipeline.Commands[0], nil, nil, nil)
+		if elapsed != nil {
+			elapsed[0] = now().Sub(start)
+		}
+		return err
+	}
 
-	return err
-}
+	// Multiple commands run concurrently, connected by bounded pipes: a writer
+	// waits while its reader is behind, so memory stays bounded as in sh. All
+	// but the last command run in subshells, whose assignments are lost.
+	type stage struct {
+		first int  // Index of the stage's command
+		fused bool // "sort | uniq" run as one step, its status recorded as uniq's
+	}
+	var stages []stage
+	for i := 0; i < len(pipeline.Commands); i++ {
+		if elapsed == nil && i+1 < len(pipeline.Commands) && e.canFuseSortUniq(pipeline.Commands[i], pipeline.Commands[i+1]) {
+			stages = append(stages, stage{first: i, fused: true})
+			i++
+			continue
+		}
+		stages = append(stages, stage{first: i})
+	}
 
-// setupRedirection sets up a single redirection
-func (e *Executor) setupRedirection(redir *parser.RedirectionNode, redirections map[string]io.ReadWriteCloser) error {
-	switch redir.Type {
-	case parser.RedirOut:
-		writer, err := e.vfs.OpenForWrite(redir.Target, false)
+	stdins := make([]io.ReadWriteCloser, len(stages))
+	stdouts := make([]io.ReadWriteCloser, len(stages))
+	for i := 0; i+1 < len(stages); i++ {
+		reader, writer, err := e.vfs.CreatePipe()
 		if err != nil {
 			return err
 		}
-		redirections["stdout"] = writer.(io.ReadWriteCloser)
+		stdouts[i], stdins[i+1] = writer, reader
+	}
+
+	// Each command's redirections override the pipe ends. As in sh, a failing
+	// command does not stop the pipeline, whose status is that of its last
+	// command, or with pipefail its rightmost failing one.
+	errs := make([]error, len(pipeline.Commands))
+	shells := make([]*Executor, len(pipeline.Commands))
+	// The commands start together, so each one's time runs from here to its exit
+	start := now()
+	run := func(x *Executor, i int) {
+		st := stages[i]
+		last := st.first
+		if st.fused {
+			last++
+			errs[last] = x.runSortUniq(pipeline.Commands[st.first], pipeline.Commands[last], stdins[i], stdouts[i])
+		} else {
+			errs[last] = x.executeCommand(pipeline.Commands[last], stdins[i], stdouts[i], nil)
+		}
+		if elapsed != nil {
+			elapsed[last] = now().Sub(start)
+		}
+		// The reader sees EOF, and the writer of the input stops with EPIPE
+		if stdouts[i] != nil {
+			stdouts[i].Close()
+		}
+		if stdins[i] != nil {
+			stdins[i].Close()
+		}
+		if i+1 < len(stages) && errors.Is(errs[last], syscall.EPIPE) {
+			// As if killed by SIGPIPE: the reader was done, nothing to report
+			errs[last] = exitError(brokenPipeStatus)
+		}
+		shells[last] = x
+	}
+	var wg sync.WaitGroup
+	for i := 0; i+1 < len(stages); i++ {
+		sub := e.subshell()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			run(sub, i)
+			sub.releaseLocks()
+		}(i)
+	}
+	run(e, len(stages)-1)
+	wg.Wait()
+
+	result := len(errs) - 1
+	if e.options.pipefail {
+		for i := len(errs) - 1; i >= 0; i-- {
+			if errs[i] != nil {
+				result = i
+				break
+			}
+		}
+	}
+	// The other failures are only reported
+	for i, err := range errs {
+		if i != result {
+			e.reportError(err)
+		}
+	}
+	if shells[result] != nil && shells[result] != e && shells[result].failed != nil {
+		e.failed = shells[result].failed
+	}
+	e.status = exitStatus(errs[result])
+	return errs[result]
+}
 
-	case parser.RedirAppend:
-		writer, err := e.vfs.OpenForWrite(redir.Target, true)
-		if err != nil {
-			return err
+// canFuseSortUniq reports whether "sort | uniq" can run as a single
+// builtin.SortUniq, which only holds the distinct lines instead of piping
+// every sorted line to uniq. Both must be the builtins, without redirections.
+func (e *Executor) canFuseSortUniq(first, second *parser.CommandNode) bool {
+	if first.Name != "sort" || second.Name != "uniq" || len(first.Redirections) > 0 || len(second.Redirections) > 0 {
+		return false
+	}
+	for _, name := range []string{"sort", "uniq"} {
+		if _, exists := e.aliases[name]; exists {
+			return false
+		}
+		if _, exists := e.functions[name]; exists {
+			return false
 		}
-		redirections["stdout"] = writer.(io.ReadWriteCloser)
+	}
+	return true
+}
 
-	case parser.RedirIn:
-		reader, err := e.vfs.OpenForRead(redir.Target)
+// runSortUniq runs a fused "sort | uniq", recording its status as uniq's
+func (e *Executor) runSortUniq(sortCmd, uniqCmd *parser.CommandNode, stdin, stdout io.ReadWriteCloser) error {
+	failed := e.failed
+	start := now()
+	err := func() error {
+		sortArgs, err := e.expandArgs(sortCmd.Args)
 		if err != nil {
 			return err
 		}
-		redirections["stdin"] = reader.(io.ReadWriteCloser)
-
-	case parser.RedirErr:
-		writer, err := e.vfs.OpenForWrite(redir.Target, false)
+		uniqArgs, err := e.expandArgs(uniqCmd.Args)
 		if err != nil {
 			return err
 		}
-		redirections["stderr"] = writer.(io.ReadWriteCloser)
-
-	case parser.RedirAll:
-		writer, err := e.vfs.OpenForWrite(redir.Target, false)
+		if err := e.checkAllowed(sortCmd, "sort"); err != nil {
+			return err
+		}
+		if err := e.checkAllowed(uniqCmd, "uniq"); err != nil {
+			return err
+		}
+		stdin, stdout, _, err := e.defaultStreams(stdin, stdout, nil)
 		if err != nil {
 			return err
 		}
-		redirections["stdout"] = writer.(io.ReadWriteCloser)
-		redirections["stderr"] = writer.(io.ReadWriteCloser)
-
-	default:
-		return fmt.Errorf("unknown redirection type")
+		return builtin.SortUniq(sortArgs, uniqArgs, stdin, stdout)
+	}()
+	if e.options.timing {
+		e.timings.record("sort|uniq", now().Sub(start))
 	}
-
-	return nil
+	e.status = exitStatus(err)
+	if e.status != 0 && e.failed == failed {
+		e.failed = &failure{cmd: uniqCmd, err: err}
+	}
+	return err
 }
 
-// cleanupRedirections closes all redirection files
-func (e *Executor) cleanupRedirections(redirections map[string]io.ReadWriteCloser) {
-	for _, file := range redirections {
-		if file != nil {
-			file.Close()
+// executeCommand executes a single command, recording its exit status for $?
+func (e *Executor) executeCommand(cmd *parser.CommandNode, stdin, stdout, stderr io.ReadWriteCloser) error {
+	failed := e.failed
+	assigned, err := e.assign(cmd)
+	if !assigned {
+		start := now()
+		err = e.runCommand(cmd, stdin, stdout, stderr)
+		if e.options.timing {
+			e.timings.record(cmd.Name, now().Sub(start))
 		}
+		e.status = exitStatus(err)
 	}
+	// A failure inside a function or alias is more precise than the call's
+	if exitStatus(err) != 0 && e.failed == failed {
+		e.failed = &failure{cmd: cmd, err: err}
+	}
+	return err
 }
 
-// executePipeline executes a pipeline of commands
-func (e *Executor) executePipeline(pipeline *parser.PipelineNode, redirections map[string]io.ReadWriteCloser) error {
-	if len(pipeline.Commands) == 0 {
-		return nil
+// runCommand expands and runs a single command with its redirections. Closing
+// a redirection can fail, e.g. when a file of the parent VFS is read-only; a
+// command that succeeded then fails with that error.
+func (e *Executor) runCommand(cmd *parser.CommandNode, stdin, stdout, stderr io.ReadWriteCloser) (err error) {
+	if err := e.stopped(); err != nil {
+		return err
 	}
-
-	if len(pipeline.Commands) == 1 {
-		// Single command
-		var stdin, stdout, stderr io.ReadWriteCloser
-
-		// Use redirections or defaults
-		if redirections != nil {
-			stdin = redirections["stdin"]
-			stdout = redirections["stdout"]
-			stderr = redirections["stderr"]
-		}
-
-		return e.executeCommand(pipeline.Commands[0], stdin, stdout, stderr)
+	// An alias's arguments are expanded when its expansion runs
+	aliased, err := e.expandAlias(cmd)
+	if err != nil {
+		return err
 	}
-
-	// Multiple commands - create pipes
-	var readers []io.ReadCloser
-	var writers []io.WriteCloser
-
-	for i := 0; i < len(pipeline.Commands)-1; i++ {
-		reader, writer, err := e.vfs.CreatePipe()
-		if err != nil {
-			// Clean up already created pipes
-			for _, r := range readers {
-				r.Close()
-			}
-			for _, w := range writers {
-				w.Close()
-			}
+	var name string
+	var args []string
+	if aliased == nil {
+		if name, err = e.expandWord(cmd.Name); err != nil {
+			return err
+		}
+		if args, err = e.expandArgs(cmd.Args); err != nil {
+			return err
+		}
+		if err := e.checkAllowed(cmd, name); err != nil {
 			return err
 		}
-		readers = append(readers, reader)
-		writers = append(writers, writer)
 	}
 
-	// Execute commands in pipeline
-	for i, cmd := range pipeline.Commands {
-		var stdin, stdout, stderr io.ReadWriteCloser
+	stdin, stdout, stderr, err = e.defaultStreams(stdin, stdout, stderr)
+	if err != nil {
+		return err
+	}
 
-		// Set up stdin
-		if i == 0 {
-			// First command uses input redirection or default stdin
-			if redirections != nil {
-				stdin = redirections["stdin"]
-			}
-		} else {
-			// Use pipe from previous command
-			if rwc, ok := readers[i-1].(io.ReadWriteCloser); ok {
-				stdin = rwc
-			} else {
-				return fmt.Errorf("pipe reader does not support read/write")
+	// Apply redirections in order, so "2>&1 > out" and "> out 2>&1" differ as in sh
+	var opened []io.ReadWriteCloser
+	defer func() {
+		for _, file := range opened {
+			if closeErr := file.Close(); err == nil && closeErr != nil {
+				err = closeErr
 			}
 		}
-
-		// Set up stdout
-		if i == len(pipeline.Commands)-1 {
-			// Last command uses output redirection or default stdout
-			if redirections != nil {
-				stdout = redirections["stdout"]
-			}
-		} else {
-			// Use pipe to next command
-			if rwc, ok := writers[i].(io.ReadWriteCloser); ok {
-				stdout = rwc
-			} else {
-				return fmt.Errorf("pipe writer does not support read/write")
-			}
+	}()
+	for _, redir := range cmd.Redirections {
+		if redir.Type == parser.RedirErrToOut {
+			stderr = stdout
+			continue
 		}
-
-		// stderr always uses redirection or default
-		if redirections != nil {
-			stderr = redirections["stderr"]
+		target, err := e.expandTarget(redir.Target)
+		if err != nil {
+			return err
 		}
-
-		if err := e.executeCommand(cmd, stdin, stdout, stderr); err != nil {
-			// Clean up pipes
-			for _, r := range readers {
-				r.Close()
-			}
-			for _, w := range writers {
-				w.Close()
-			}
+		file, err := e.openRedirection(redir.Type, target)
+		if err != nil {
 			return err
 		}
+		opened = append(opened, file)
+		switch redir.Type {
+		case parser.RedirIn:
+			stdin = file
+		case parser.RedirErr:
+			stderr = file
+		case parser.RedirAll:
+			stdout, stderr = file, file
+		default:
+			stdout = file
+		}
 	}
 
-	// Clean up pipes
-	for _, r := range readers {
-		r.Close()
+	if aliased != nil {
+		return e.runAlias(cmd.Name, aliased, stdin, stdout, stderr)
 	}
-	for _, w := range writers {
-		w.Close()
+	if fn, exists := e.functions[name]; exists {
+		return e.callFunction(fn, args, stdin, stdout, stderr)
 	}
-
-	return nil
+	if isJobBuiltin(name) {
+		return e.executeJobBuiltin(name, args, stdout)
+	}
+	if isLockBuiltin(name) {
+		return e.executeLockBuiltin(name, args)
+	}
+	switch name {
+	case "set":
+		return e.executeSet(args, stdout)
+	case "alias":
+		return e.executeAlias(args, stdout)
+	case "unalias":
+		return e.executeUnalias(args)
+	case "return":
+		return e.executeReturn(args)
+	case "times":
+		return e.executeTimes(stdout)
+	case "history":
+		return e.executeHistory(args, stdout)
+	}
+	return e.commands.Execute(e.context(), name, args, stdin, stdout, stderr)
 }
 
-// executeCommand executes a single command
-func (e *Executor) executeCommand(cmd *parser.CommandNode, stdin, stdout, stderr io.ReadWriteCloser) error {
+// defaultStreams fills in the streams a command was not given: the
+// executor's own, or else the stdin, stdout and stderr virtual files
+func (e *Executor) defaultStreams(stdin, stdout, stderr io.ReadWriteCloser) (io.ReadWriteCloser, io.ReadWriteCloser, io.ReadWriteCloser, error) {
+	if stdin == nil && e.stdin != nil {
+		stdin = e.stdin
+	}
+	if stdout == nil && e.stdout != nil {
+		stdout = e.stdout
+	}
+	if stderr == nil && e.stderr != nil {
+		stderr = e.stderr
+	}
+
 	// Use default streams if not provided
 	if stdin == nil {
 		reader, err := e.vfs.OpenForRead("stdin")
 		if err != nil {
-			return err
+			return nil, nil, nil, err
 		}
 		// For now, we'll use a type assertion - this needs better design
 		if rwc, ok := reader.(io.ReadWriteCloser); ok {
 			stdin = rwc
 		} else {
-			return fmt.Errorf("stdin does not support read/write")
+			return nil, nil, nil, fmt.Errorf("stdin does not support read/write")
 		}
 	}
 	if stdout == nil {
 		writer, err := e.vfs.OpenForWrite("stdout", false)
 		if err != nil {
-			return err
+			return nil, nil, nil, err
 		}
 		// For now, we'll use a type assertion - this needs better design
 		if rwc, ok := writer.(io.ReadWriteCloser); ok {
 			stdout = rwc
 		} else {
-			return fmt.Errorf("stdout does not support read/write")
+			return nil, nil, nil, fmt.Errorf("stdout does not support read/write")
 		}
 	}
 	if stderr == nil {
 		writer, err := e.vfs.OpenForWrite("stderr", false)
 		if err != nil {
-			return err
+			return nil, nil, nil, err
 		}
 		// For now, we'll use a type assertion - this needs better design
 		if rwc, ok := writer.(io.ReadWriteCloser); ok {
 			stderr = rwc
 		} else {
-			return fmt.Errorf("stderr does not support read/write")
+			return nil, nil, nil, fmt.Errorf("stderr does not support read/write")
 		}
 	}
-
-	return e.commands.Execute(cmd.Name, cmd.Args, stdin, stdout, stderr)
+	return stdin, stdout, stderr, nil
 }
 
 // Commands manages command execution
@@ -350,8 +653,8 @@ func NewCommands(vfs *VirtualFileSystem, help *HelpSystem, quotaManager interfac
 	}
 }
 
-// Execute executes a command by name
-func (c *Commands) Execute(name string, args []string, stdin io.ReadWriteCloser, stdout, stderr io.ReadWriteCloser) error {
+// Execute executes a command by name; ctx stops the llm builtin's request
+func (c *Commands) Execute(ctx context.Context, name string, args []string, stdin io.ReadWriteCloser, stdout, stderr io.ReadWriteCloser) error {
 	// Handle special commands first
 	switch name {
 	case "help", "man":
@@ -360,6 +663,52 @@ func (c *Commands) Execute(name string, args []string, stdin io.ReadWriteCloser,
 		return c.executeLLMCmd(args, stdin, stdout, stderr)
 	case "llmsh":
 		return c.executeLLMSh(args, stdin, stdout, stderr)
+	case "llm":
+		return c.executeLLM(ctx, args, stdin, stdout)
+	case "vls":
+		return c.executeVLs(args, stdout)
+	case "vcat":
+		return c.executeVCat(args, stdout)
+	case "vcp":
+		return c.executeVCp(args)
+	case "vrm":
+		return c.executeVRm(args)
+	case "vstat":
+		return c.executeVStat(args, stdout)
+	case "find":
+		return c.executeFind(args, stdout)
+	case "mkdir":
+		return c.executeMkdir(args)
+	case "rmdir":
+		return c.executeRmdir(args)
+	case "mkfifo":
+		return c.executeMkfifo(args)
+	case "cat":
+		if len(args) > 0 {
+			return c.executeCat(args, stdin, stdout)
+		}
+	case "tar":
+		return builtin.RunTar(args, stdin, stdout, vfsFiles{c.vfs})
+	case "sha256sum":
+		return builtin.RunSha256sum(args, stdin, stdout, vfsFiles{c.vfs}.Open)
+	case "md5sum":
+		return builtin.RunMd5sum(args, stdin, stdout, vfsFiles{c.vfs}.Open)
+	case "split":
+		return builtin.RunSplit(args, stdin, stdout, vfsFiles{c.vfs})
+	case "join":
+		return builtin.RunJoin(args, stdin, stdout, vfsFiles{c.vfs}.Open)
+	case "paste":
+		return builtin.RunPaste(args, stdin, stdout, vfsFiles{c.vfs}.Open)
+	case "comm":
+		return builtin.RunComm(args, stdin, stdout, vfsFiles{c.vfs}.Open)
+	case "diff":
+		return builtin.RunDiff(args, stdin, stdout, vfsFiles{c.vfs}.Open, vfsFiles{c.vfs}.List)
+	case "patch":
+		return builtin.RunPatch(args, stdin, stdout, vfsFiles{c.vfs})
+	case "diff3":
+		return builtin.RunDiff3(args, stdin, stdout, vfsFiles{c.vfs}.Open)
+	case "merge":
+		return builtin.RunMerge(args, stdin, stdout, vfsFiles{c.vfs})
 	}
 
 	// Check new internal command implementations first
@@ -376,6 +725,17 @@ func (c *Commands) Execute(name string, args []string, stdin io.ReadWriteCloser,
 	return c.executeLLMCommand(name, args, stdin, stdout, stderr)
 }
 
+// CommandNames returns the names of all commands, for completion
+func (c *Commands) CommandNames() []string {
+	names := []string{"help", "man", "llmcmd", "llmsh", "llm"}
+	names = append(names, vfsBuiltins...)
+	names = append(names, c.manager.CommandNames()...)
+	for name := range builtin.Commands {
+		names = append(names, name)
+	}
+	return names
+}
+
 // executeHelp executes help command
 func (c *Commands) executeHelp(args []string, stdout io.ReadWriteCloser) error {
 	if len(args) == 0 {
@@ -395,6 +755,29 @@ func (c *Commands) executeHelp(args []string, stdout io.ReadWriteCloser) error {
 	return err
 }
 
+// executeCat concatenates the named files, "-" being stdin, so globs such as
+// cat *.log work. It goes on after a file that cannot be read, as cat does.
+func (c *Commands) executeCat(args []string, stdin io.ReadWriteCloser, stdout io.ReadWriteCloser) error {
+	var firstErr error
+	for _, name := range args {
+		var reader io.Reader = stdin
+		if name != "-" {
+			file, err := c.vfs.OpenForRead(name)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("cat: %s: %w", name, err)
+				}
+				continue
+			}
+			reader = file
+		}
+		if _, err := io.Copy(stdout, reader); err != nil {
+			return fmt.Errorf("cat: %s: %w", name, err)
+		}
+	}
+	return firstErr
+}
+
 // executeLLMCmd executes llmcmd (recursive LLM execution)
 func (c *Commands) executeLLMCmd(args []string, stdin io.ReadWriteCloser, stdout, stderr io.ReadWriteCloser) error {
 	if len(args) == 0 {
@@ -434,7 +817,7 @@ func (c *Commands) executeLLMCmd(args []string, stdin io.ReadWriteCloser, stdout
 	// Execute llmcmd internally with shared quota
 	metadata := app.ApplicationMetadata{
 		Name:    "llmcmd",
-		Version: "3.0.3",
+		Version: buildinfo.Version,
 	}
 
 	// Execute with internal context
@@ -448,6 +831,45 @@ func (c *Commands) executeLLMCmd(args []string, stdin io.ReadWriteCloser, stdout
 	return nil
 }
 
+// chat sends an llm builtin prompt to the model; tests replace it
+var chat = app.Chat
+
+// executeLLM executes the llm builtin: the prompt is sent with stdin as its
+// input (unless -n is given) and the reply is written to stdout, charging the
+// shell's shared quota. The request is abandoned once ctx is done.
+func (c *Commands) executeLLM(ctx context.Context, args []string, stdin io.ReadWriteCloser, stdout io.ReadWriteCloser) error {
+	readInput := true
+	if len(args) > 0 && args[0] == "-n" {
+		readInput = false
+		args = args[1:]
+	}
+	prompt := strings.Join(args, " ")
+	if strings.TrimSpace(prompt) == "" {
+		return fmt.Errorf("llm: missing prompt")
+	}
+
+	var input []byte
+	if readInput {
+		var err error
+		if input, err = io.ReadAll(stdin); err != nil {
+			return fmt.Errorf("llm: error reading input: %w", err)
+		}
+	}
+
+	processID := fmt.Sprintf("llm-%d", time.Now().UnixNano())
+	if err := c.sharedQuota.RegisterProcess(processID, "llmsh"); err != nil {
+		return fmt.Errorf("llm: failed to register process: %w", err)
+	}
+	defer c.sharedQuota.UnregisterProcess(processID)
+
+	reply, err := chat(ctx, prompt, string(input), c.sharedQuota, processID)
+	if err != nil {
+		return fmt.Errorf("llm: %w", err)
+	}
+	_, err = io.WriteString(stdout, reply)
+	return err
+}
+
 // executeLLMSh executes llmsh subshell
 func (c *Commands) executeLLMSh(args []string, stdin io.ReadWriteCloser, stdout, stderr io.ReadWriteCloser) error {
 	// Generate process ID for this llmsh call
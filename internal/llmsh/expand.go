@@ -0,0 +1,264 @@
+package llmsh
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mako10k/llmcmd/internal/llmsh/parser"
+)
+
+// assignmentPattern matches a NAME=value word
+var assignmentPattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)=`)
+
+// exitStatus converts a command error into its $? value
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// expand replaces $?, $NAME, ${NAME}, the function parameters $1..$9, ${10},
+// $# and $@, $(command) and $((expression)) in word. \$ stands for a
+// literal dollar sign. Substituted output loses its trailing newlines and is
+// not split into several words. Escaped glob characters are left escaped, so
+// the result is a glob pattern.
+func (e *Executor) expand(word string) (string, error) {
+	if !strings.Contains(word, "$") {
+		return word, nil
+	}
+
+	var result strings.Builder
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		switch {
+		case c == '\\' && i+1 < len(word) && word[i+1] == '$':
+			result.WriteByte('$')
+			i++
+		case c != '$' || i+1 == len(word):
+			result.WriteByte(c)
+		case word[i+1] == '?':
+			result.WriteString(strconv.Itoa(e.status))
+			i++
+		case word[i+1] == '(':
+			end := parser.MatchParen(word, i+1)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated command substitution in %q", word)
+			}
+			if word[i+2] == '(' && parser.MatchParen(word, i+2) == end-1 {
+				value, err := e.arithmetic(word[i+3 : end-1])
+				if err != nil {
+					return "", err
+				}
+				result.WriteString(strconv.FormatInt(value, 10))
+				i = end
+				continue
+			}
+			output, err := e.substitute(word[i+2 : end])
+			if err != nil {
+				return "", err
+			}
+			result.WriteString(output)
+			i = end
+		case word[i+1] == '{':
+			end := strings.IndexByte(word[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference in %q", word)
+			}
+			result.WriteString(e.lookup(word[i+2 : i+end]))
+			i += end
+		case word[i+1] == '#' || word[i+1] == '@' || word[i+1] >= '0' && word[i+1] <= '9':
+			result.WriteString(e.lookup(word[i+1 : i+2]))
+			i++
+		default:
+			end := i + 1
+			for end < len(word) && isNameChar(word[end], end == i+1) {
+				end++
+			}
+			if end == i+1 {
+				// A lone $ is literal
+				result.WriteByte(c)
+				continue
+			}
+			result.WriteString(e.lookup(word[i+1 : end]))
+			i = end - 1
+		}
+	}
+	return result.String(), nil
+}
+
+// expandWord expands word without globbing, as for command names and
+// assignments
+func (e *Executor) expandWord(word string) (string, error) {
+	pattern, err := e.expand(word)
+	if err != nil {
+		return "", err
+	}
+	return unescapeGlob(pattern), nil
+}
+
+// expandArgs expands words into arguments. A word containing unquoted glob
+// characters becomes the sorted names of the files it matches, or stays as
+// it is when nothing matches, as in sh.
+func (e *Executor) expandArgs(words []string) ([]string, error) {
+	args := make([]string, 0, len(words))
+	for _, word := range words {
+		pattern, err := e.expand(word)
+		if err != nil {
+			return nil, err
+		}
+		matches, err := e.glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			matches = []string{unescapeGlob(pattern)}
+		}
+		args = append(args, matches...)
+	}
+	return args, nil
+}
+
+// expandTarget expands a redirection target, which may be a glob matching
+// exactly one file
+func (e *Executor) expandTarget(word string) (string, error) {
+	pattern, err := e.expand(word)
+	if err != nil {
+		return "", err
+	}
+	matches, err := e.glob(pattern)
+	switch {
+	case err != nil:
+		return "", err
+	case len(matches) > 1:
+		return "", fmt.Errorf("%s: ambiguous redirect", word)
+	case len(matches) == 1:
+		return matches[0], nil
+	default:
+		return unescapeGlob(pattern), nil
+	}
+}
+
+// glob returns the files matching pattern, or nil when it has no unescaped
+// glob characters
+func (e *Executor) glob(pattern string) ([]string, error) {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return e.vfs.Glob(pattern)
+		}
+	}
+	return nil, nil
+}
+
+// unescapeGlob removes the backslashes that keep glob characters literal
+func unescapeGlob(pattern string) string {
+	if !strings.Contains(pattern, "\\") {
+		return pattern
+	}
+	var result strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) && strings.IndexByte("*?[", pattern[i+1]) >= 0 {
+			i++
+		}
+		result.WriteByte(pattern[i])
+	}
+	return result.String()
+}
+
+// lookup returns the value of a variable or of a parameter: $0 is llmsh, $1...
+// the arguments of the function being called, $# their number and $@ all of
+// them separated by spaces
+func (e *Executor) lookup(name string) string {
+	switch {
+	case name == "#":
+		return strconv.Itoa(len(e.args))
+	case name == "@":
+		return strings.Join(e.args, " ")
+	case name != "" && name[0] >= '0' && name[0] <= '9':
+		n, err := strconv.Atoi(name)
+		switch {
+		case err != nil || n > len(e.args):
+			return ""
+		case n == 0:
+			return "llmsh"
+		default:
+			return e.args[n-1]
+		}
+	default:
+		return e.vars[name]
+	}
+}
+
+// isNameChar reports whether c may appear in a variable name
+func isNameChar(c byte, first bool) bool {
+	switch {
+	case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+		return true
+	case c >= '0' && c <= '9':
+		return !first
+	default:
+		return false
+	}
+}
+
+// substitute runs script as a command substitution and returns its output.
+// It runs like a subshell: assignments inside it do not leak out, but $?
+// afterwards is its exit status.
+func (e *Executor) substitute(script string) (string, error) {
+	ast, err := parser.NewParser().Parse(script)
+	if err != nil {
+		return "", fmt.Errorf("command substitution: %w", err)
+	}
+
+	output := NewVirtualFile("$(" + script + ")")
+	sub := e.subshell()
+	sub.stdout = output
+	// A failing command still yields its output, as in sh, and its error
+	// goes to stderr
+	err = sub.Execute(ast)
+	sub.waitJobs()
+	sub.releaseLocks()
+	sub.reportError(err)
+	e.status = sub.status
+	return strings.TrimRight(output.buffer.String(), "\n"), nil
+}
+
+// arithmetic evaluates the expression of an arithmetic expansion, after
+// expanding the variables and command substitutions in it
+func (e *Executor) arithmetic(expr string) (int64, error) {
+	expanded, err := e.expand(expr)
+	if err != nil {
+		return 0, err
+	}
+	return evalArith(unescapeGlob(expanded), e.vars)
+}
+
+// assign handles a NAME=value command, reporting whether cmd was one
+func (e *Executor) assign(cmd *parser.CommandNode) (bool, error) {
+	match := assignmentPattern.FindStringSubmatch(cmd.Name)
+	if match == nil || len(cmd.Args) > 0 || len(cmd.Redirections) > 0 {
+		return false, nil
+	}
+
+	// $? after an assignment is the status of its last substitution, or 0
+	e.status = 0
+	value, err := e.expandWord(cmd.Name[len(match[0]):])
+	if err != nil {
+		return true, err
+	}
+	e.vars[match[1]] = value
+	if e.status != 0 {
+		return true, exitError(e.status)
+	}
+	return true, nil
+}
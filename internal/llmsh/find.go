@@ -0,0 +1,434 @@
+package llmsh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// findEntry is a file, or a directory implied by the names of files
+type findEntry struct {
+	name    string
+	dir     bool
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+	depth   int
+	empty   bool // A directory with nothing in it
+}
+
+// findPredicate is a compiled find expression
+type findPredicate func(entry *findEntry) bool
+
+// executeFind searches the files llmsh can see, like find. Directories are
+// those made with mkdir and the prefixes of names with slashes. Starting points
+// default to all files; names are printed as the VFS names them, without a
+// leading ./. Supported are -name, -iname, -path, -type f|d, -size, -newer,
+// -mmin, -mtime, -perm, -empty, -maxdepth, -mindepth, -print, !, -a, -o and
+// parentheses.
+func (c *Commands) executeFind(args []string, stdout io.ReadWriteCloser) error {
+	var starts []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") && args[0] != "!" && args[0] != "(" {
+		starts = append(starts, args[0])
+		args = args[1:]
+	}
+	if len(starts) == 0 {
+		starts = []string{"."}
+	}
+
+	p := &findParser{args: args, c: c, out: stdout, maxDepth: -1}
+	predicate, err := p.parse()
+	if err != nil {
+		return err
+	}
+	if !p.hasAction {
+		expr := predicate
+		predicate = func(entry *findEntry) bool {
+			if expr(entry) {
+				fmt.Fprintln(stdout, entry.name)
+			}
+			return true
+		}
+	}
+
+	entries, err := c.findEntries()
+	if err != nil {
+		return fmt.Errorf("find: %w", err)
+	}
+	var firstErr error
+	for _, start := range starts {
+		root := path.Clean(start)
+		if !path.IsAbs(root) || root == "/" {
+			// Virtual names are relative to the root of the VFS
+			root = strings.TrimPrefix(path.Clean("/"+root), "/")
+		}
+		found := false
+		for i := range entries {
+			entry := entries[i]
+			switch {
+			case root == "" && path.IsAbs(entry.name):
+				entry.depth = 1 // The input or output file, given by its real path
+			case root == "":
+				entry.depth = strings.Count(entry.name, "/") + 1
+			case entry.name == root:
+				entry.depth = 0
+			case strings.HasPrefix(entry.name, root+"/"):
+				entry.depth = strings.Count(entry.name[len(root):], "/")
+			default:
+				continue
+			}
+			found = true
+			if entry.depth < p.minDepth || (p.maxDepth >= 0 && entry.depth > p.maxDepth) {
+				continue
+			}
+			predicate(&entry)
+		}
+		if !found && root != "" && firstErr == nil {
+			firstErr = fmt.Errorf("find: '%s': no such file or directory", start)
+		}
+	}
+	return firstErr
+}
+
+// findEntries returns the files and the directories their names imply, in
+// the order find visits them: each directory before its contents
+func (c *Commands) findEntries() ([]findEntry, error) {
+	names, err := c.vfs.Names()
+	if err != nil {
+		return nil, err
+	}
+	dirs := make(map[string]*findEntry)
+	var entries []findEntry
+	for _, name := range names {
+		info, err := c.vfs.Stat(name)
+		if err != nil {
+			continue // Consumed or removed since it was listed
+		}
+		entries = append(entries, findEntry{name: name, size: info.Size, mode: info.Mode, modTime: info.ModTime})
+		if path.IsAbs(name) {
+			continue // A real path implies no virtual directories
+		}
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			entry, ok := dirs[dir]
+			if !ok {
+				entry = &findEntry{name: dir, dir: true, mode: dirInfo(dir).Mode}
+				dirs[dir] = entry
+			}
+			// A directory was modified when its newest file was
+			if info.ModTime.After(entry.modTime) {
+				entry.modTime = info.ModTime
+			}
+		}
+	}
+	for _, dir := range c.vfs.Dirs() {
+		if _, ok := dirs[dir]; !ok {
+			dirs[dir] = &findEntry{name: dir, dir: true, mode: dirInfo(dir).Mode, empty: true}
+		}
+	}
+	for _, entry := range dirs {
+		if parent := path.Dir(entry.name); parent != "." {
+			if entry, ok := dirs[parent]; ok {
+				entry.empty = false
+			}
+		}
+	}
+	for _, entry := range dirs {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := strings.Split(entries[i].name, "/"), strings.Split(entries[j].name, "/")
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return entries, nil
+}
+
+// findParser compiles a find expression: alternatives of conjunctions of
+// possibly negated primaries, -a being implied between primaries
+type findParser struct {
+	args      []string
+	pos       int
+	c         *Commands
+	out       io.Writer
+	hasAction bool
+	maxDepth  int
+	minDepth  int
+}
+
+func (p *findParser) parse() (findPredicate, error) {
+	if len(p.args) == 0 {
+		return func(*findEntry) bool { return true }, nil
+	}
+	predicate, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.args) {
+		return nil, fmt.Errorf("find: unexpected '%s'", p.args[p.pos])
+	}
+	return predicate, nil
+}
+
+func (p *findParser) peek() string {
+	if p.pos < len(p.args) {
+		return p.args[p.pos]
+	}
+	return ""
+}
+
+func (p *findParser) parseOr() (findPredicate, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "-o" || p.peek() == "-or" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(entry *findEntry) bool { return l(entry) || right(entry) }
+	}
+	return left, nil
+}
+
+func (p *findParser) parseAnd() (findPredicate, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek() {
+		case "", "-o", "-or", ")":
+			return left, nil
+		case "-a", "-and":
+			p.pos++
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(entry *findEntry) bool { return l(entry) && right(entry) }
+	}
+}
+
+func (p *findParser) parseNot() (findPredicate, error) {
+	if p.peek() == "!" || p.peek() == "-not" {
+		p.pos++
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(entry *findEntry) bool { return !operand(entry) }, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *findParser) parsePrimary() (findPredicate, error) {
+	token := p.peek()
+	if token == "" {
+		return nil, fmt.Errorf("find: expected an expression")
+	}
+	p.pos++
+	if token == "(" {
+		predicate, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("find: missing ')'")
+		}
+		p.pos++
+		return predicate, nil
+	}
+
+	switch token {
+	case "-print":
+		p.hasAction = true
+		return func(entry *findEntry) bool {
+			fmt.Fprintln(p.out, entry.name)
+			return true
+		}, nil
+	case "-empty":
+		return func(entry *findEntry) bool { return entry.empty || (!entry.dir && entry.size == 0) }, nil
+	case "-true":
+		return func(*findEntry) bool { return true }, nil
+	case "-false":
+		return func(*findEntry) bool { return false }, nil
+	}
+
+	if p.pos == len(p.args) {
+		return nil, fmt.Errorf("find: missing argument to '%s'", token)
+	}
+	arg := p.args[p.pos]
+	p.pos++
+	switch token {
+	case "-name", "-iname":
+		fold := token == "-iname"
+		if fold {
+			arg = strings.ToLower(arg)
+		}
+		if _, err := path.Match(arg, ""); err != nil {
+			return nil, fmt.Errorf("find: invalid pattern '%s'", arg)
+		}
+		return func(entry *findEntry) bool {
+			base := path.Base(entry.name)
+			if fold {
+				base = strings.ToLower(base)
+			}
+			matched, _ := path.Match(arg, base)
+			return matched
+		}, nil
+	case "-path", "-wholename":
+		if _, err := path.Match(arg, ""); err != nil {
+			return nil, fmt.Errorf("find: invalid pattern '%s'", arg)
+		}
+		// As in find, wildcards match slashes too
+		pattern := strings.ReplaceAll(strings.TrimPrefix(arg, "./"), "/", "\x00")
+		return func(entry *findEntry) bool {
+			matched, _ := path.Match(pattern, strings.ReplaceAll(entry.name, "/", "\x00"))
+			return matched
+		}, nil
+	case "-type":
+		switch arg {
+		case "f":
+			return func(entry *findEntry) bool { return !entry.dir && entry.mode&os.ModeNamedPipe == 0 }, nil
+		case "d":
+			return func(entry *findEntry) bool { return entry.dir }, nil
+		case "p":
+			return func(entry *findEntry) bool { return entry.mode&os.ModeNamedPipe != 0 }, nil
+		}
+		return nil, fmt.Errorf("find: invalid argument '%s' to -type; only f, d and p are supported", arg)
+	case "-size":
+		return parseFindSize(arg)
+	case "-newer":
+		info, err := p.c.vfs.Stat(arg)
+		if err != nil {
+			return nil, fmt.Errorf("find: '%s': %w", arg, err)
+		}
+		if info.ModTime.IsZero() {
+			return nil, fmt.Errorf("find: '%s': modification time is not known", arg)
+		}
+		return func(entry *findEntry) bool { return entry.modTime.After(info.ModTime) }, nil
+	case "-mmin", "-mtime":
+		unit := time.Minute
+		if token == "-mtime" {
+			unit = 24 * time.Hour
+		}
+		return parseFindAge(token, arg, unit, time.Now())
+	case "-perm":
+		return parseFindPerm(arg)
+	case "-maxdepth", "-mindepth":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("find: invalid argument '%s' to %s", arg, token)
+		}
+		if token == "-maxdepth" {
+			p.maxDepth = n
+		} else {
+			p.minDepth = n
+		}
+		return func(*findEntry) bool { return true }, nil
+	}
+	return nil, fmt.Errorf("find: unknown predicate '%s'", token)
+}
+
+// parseFindAge compiles -mmin and -mtime [+-]N: the time since the file was
+// modified, in whole units with any fraction dropped, is more than, less than
+// or exactly N. Files whose modification time is not known never match.
+func parseFindAge(token, arg string, unit time.Duration, now time.Time) (findPredicate, error) {
+	text := arg
+	sign := byte(0)
+	if text != "" && (text[0] == '+' || text[0] == '-') {
+		sign, text = text[0], text[1:]
+	}
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("find: invalid argument '%s' to %s", arg, token)
+	}
+	return func(entry *findEntry) bool {
+		if entry.modTime.IsZero() {
+			return false
+		}
+		age := int64(now.Sub(entry.modTime) / unit)
+		switch sign {
+		case '+':
+			return age > n
+		case '-':
+			return age < n
+		}
+		return age == n
+	}, nil
+}
+
+// parseFindPerm compiles -perm with an octal mode: MODE matches exactly these
+// permissions, -MODE files with all of these bits set and /MODE files with
+// any of them set
+func parseFindPerm(arg string) (findPredicate, error) {
+	text := arg
+	kind := byte(0)
+	if text != "" && (text[0] == '-' || text[0] == '/') {
+		kind, text = text[0], text[1:]
+	}
+	bits, err := strconv.ParseUint(text, 8, 32)
+	if err != nil || bits > 0777 {
+		return nil, fmt.Errorf("find: invalid mode '%s' to -perm; only octal permissions are supported", arg)
+	}
+	mode := os.FileMode(bits)
+	return func(entry *findEntry) bool {
+		perm := entry.mode.Perm()
+		switch kind {
+		case '-':
+			return perm&mode == mode
+		case '/':
+			return perm&mode != 0 || mode == 0
+		}
+		return perm == mode
+	}, nil
+}
+
+// parseFindSize compiles -size [+-]N[cwbkMG]. As in find, sizes are rounded
+// up to the unit, which is 512-byte blocks unless given, so -size -1M
+// matches only empty files.
+func parseFindSize(arg string) (findPredicate, error) {
+	text := arg
+	sign := byte(0)
+	if text != "" && (text[0] == '+' || text[0] == '-') {
+		sign, text = text[0], text[1:]
+	}
+	unit := int64(512)
+	if text != "" {
+		units := map[byte]int64{'c': 1, 'w': 2, 'b': 512, 'k': 1 << 10, 'M': 1 << 20, 'G': 1 << 30}
+		if u, ok := units[text[len(text)-1]]; ok {
+			unit, text = u, text[:len(text)-1]
+		}
+	}
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("find: invalid argument '%s' to -size", arg)
+	}
+	return func(entry *findEntry) bool {
+		if entry.dir {
+			return false
+		}
+		size := (entry.size + unit - 1) / unit
+		switch sign {
+		case '+':
+			return size > n
+		case '-':
+			return size < n
+		}
+		return size == n
+	}, nil
+}
@@ -0,0 +1,207 @@
+package llmsh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mako10k/llmcmd/internal/llmsh/parser"
+)
+
+// maxCallDepth bounds nested function calls and alias expansions, so a
+// function calling itself fails instead of exhausting the stack
+const maxCallDepth = 100
+
+// returnStatus is returned by the return builtin; it ends the function
+// being called with the given status
+type returnStatus int
+
+func (r returnStatus) Error() string {
+	return fmt.Sprintf("return %d", int(r))
+}
+
+// ExitCode returns the status given to return
+func (r returnStatus) ExitCode() int {
+	return int(r)
+}
+
+// defineFunction records a function definition; a later one replaces it
+func (e *Executor) defineFunction(fn *parser.FunctionNode) error {
+	e.functions[fn.Name] = fn
+	e.status = 0
+	return nil
+}
+
+// callFunction runs a function's body with args as $1, $2, ... Its status is
+// that of the last statement run, or the one given to return.
+func (e *Executor) callFunction(fn *parser.FunctionNode, args []string, stdin, stdout, stderr io.ReadWriteCloser) error {
+	saved := e.args
+	e.args = args
+	e.calls++
+	defer func() {
+		e.args = saved
+		e.calls--
+	}()
+
+	err := e.runWithStreams(&parser.ScriptNode{Statements: fn.Body}, stdin, stdout, stderr)
+	var ret returnStatus
+	if errors.As(err, &ret) {
+		if ret == 0 {
+			return nil
+		}
+		return exitError(ret)
+	}
+	// A failure tested inside the function is a plain failure to the caller
+	var tested testedError
+	if errors.As(err, &tested) {
+		return tested.err
+	}
+	return err
+}
+
+// runWithStreams executes node with stdin, stdout and stderr as the default
+// streams of its commands, as for a function call or an alias
+func (e *Executor) runWithStreams(node parser.Node, stdin, stdout, stderr io.ReadWriteCloser) error {
+	if e.depth >= maxCallDepth {
+		return fmt.Errorf("maximum nesting depth %d exceeded", maxCallDepth)
+	}
+	savedStdin, savedStdout, savedStderr := e.stdin, e.stdout, e.stderr
+	e.stdin, e.stdout, e.stderr = stdin, stdout, stderr
+	e.depth++
+	defer func() {
+		e.stdin, e.stdout, e.stderr = savedStdin, savedStdout, savedStderr
+		e.depth--
+	}()
+	return e.Execute(node)
+}
+
+// expandAlias returns the command an alias stands for, with cmd's arguments
+// appended to its last command, or nil when cmd does not start with an alias.
+// An alias is not expanded again within its own expansion.
+func (e *Executor) expandAlias(cmd *parser.CommandNode) (parser.Node, error) {
+	value, exists := e.aliases[cmd.Name]
+	if !exists || e.expanding[cmd.Name] {
+		return nil, nil
+	}
+	node, err := parser.NewParser().Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("alias %s: %w", cmd.Name, err)
+	}
+	if node == nil {
+		return nil, fmt.Errorf("alias %s: empty command", cmd.Name)
+	}
+	if len(cmd.Args) > 0 {
+		last := lastCommand(node)
+		if last == nil {
+			return nil, fmt.Errorf("alias %s: cannot take arguments", cmd.Name)
+		}
+		last.Args = append(last.Args, cmd.Args...)
+	}
+	return node, nil
+}
+
+// runAlias executes the expansion of alias name with the command's streams
+func (e *Executor) runAlias(name string, node parser.Node, stdin, stdout, stderr io.ReadWriteCloser) error {
+	e.expanding[name] = true
+	defer delete(e.expanding, name)
+	return e.runWithStreams(node, stdin, stdout, stderr)
+}
+
+// lastCommand returns the command that arguments after an alias attach to,
+// the last one of its expansion, or nil when that is not a simple command
+func lastCommand(node parser.Node) *parser.CommandNode {
+	switch n := node.(type) {
+	case *parser.ScriptNode:
+		return lastCommand(n.Statements[len(n.Statements)-1])
+	case *parser.SequenceNode:
+		return lastCommand(n.Commands[len(n.Commands)-1])
+	case *parser.ConditionalNode:
+		return lastCommand(n.Right)
+	case *parser.PipelineNode:
+		return n.Commands[len(n.Commands)-1]
+	default:
+		return nil
+	}
+}
+
+// executeAlias defines aliases (alias name=value) or prints them (alias
+// [name...])
+func (e *Executor) executeAlias(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		names := make([]string, 0, len(e.aliases))
+		for name := range e.aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		args = names
+	}
+
+	var firstErr error
+	for _, arg := range args {
+		if match := assignmentPattern.FindStringSubmatch(arg); match != nil {
+			e.aliases[match[1]] = arg[len(match[0]):]
+			continue
+		}
+		value, exists := e.aliases[arg]
+		if !exists {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("alias: %s: not found", arg)
+			}
+			continue
+		}
+		if _, err := fmt.Fprintf(stdout, "alias %s=%s\n", arg, quote(value)); err != nil {
+			return err
+		}
+	}
+	return firstErr
+}
+
+// executeUnalias removes aliases; unalias -a removes them all
+func (e *Executor) executeUnalias(args []string) error {
+	if len(args) == 1 && args[0] == "-a" {
+		e.aliases = make(map[string]string)
+		return nil
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("unalias: missing alias name")
+	}
+	var firstErr error
+	for _, name := range args {
+		if _, exists := e.aliases[name]; !exists {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("unalias: %s: not found", name)
+			}
+			continue
+		}
+		delete(e.aliases, name)
+	}
+	return firstErr
+}
+
+// executeReturn ends the current function with a status, $? by default
+func (e *Executor) executeReturn(args []string) error {
+	if e.calls == 0 {
+		return fmt.Errorf("return: can only be used in a function")
+	}
+	status := e.status
+	switch len(args) {
+	case 0:
+	case 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 || n > 255 {
+			return fmt.Errorf("return: %s: numeric argument 0-255 required", args[0])
+		}
+		status = n
+	default:
+		return fmt.Errorf("return: too many arguments")
+	}
+	return returnStatus(status)
+}
+
+// quote single-quotes s for output that can be read back by the shell
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+
+	"github.com/mako10k/llmcmd/internal/vfsproxy"
 )
 
 // HelpSystem provides integrated help for all commands
@@ -33,6 +35,23 @@ type Example struct {
 	Description string
 }
 
+// CommandLineOptions are the llmsh command line options, shared by --help and
+// the generated man page
+var CommandLineOptions = []Option{
+	{Flag: "-i <file>", Description: "Input file (accessible as stdin)"},
+	{Flag: "-o <file>", Description: "Output file (accessible as stdout)"},
+	{Flag: "-c <script>", Description: "Execute script string"},
+	{Flag: "-n", Description: "Check the script's syntax without running it; errors give the line and column (exit status 2)"},
+	{Flag: "--allow-commands <list>", Description: "Only allow the comma-separated commands; scripts running others are rejected before they start"},
+	{Flag: "--transcript <file>", Description: "Record the commands, input and output of the session with timestamps in file, as JSON lines"},
+	{Flag: "--replay <file>", Description: "Run the commands of a transcript again, with the input they read"},
+	{Flag: "--timing", Description: "Print the time spent in each command when the script ends (as set -o timing)"},
+	{Flag: "--vfs-fd <n>", Description: "Resolve redirections against the parent llmcmd VFS on fd n (default: $" + vfsproxy.EnvVar + ", set for scripts run by spawn)"},
+	{Flag: "--vfs-socket <path>", Description: "Resolve redirections against the parent llmcmd VFS served on the Unix socket path, over a connection of this shell's own (default: $" + vfsproxy.SocketEnvVar + ", preferred to fd 3 unless --vfs-fd is given)"},
+	{Flag: "-h, --help", Description: "Show this help"},
+	{Flag: "--version", Description: "Show version (add --json for build information as JSON)"},
+}
+
 // NewHelpSystem creates a new help system
 func NewHelpSystem() *HelpSystem {
 	h := &HelpSystem{
@@ -114,13 +133,14 @@ func (h *HelpSystem) FormatCommandList() string {
 		"Calculation":              {},
 		"Compression":              {},
 		"Special Commands":         {},
+		"Virtual Files":            {},
 	}
 
-	builtins := []string{"cat", "grep", "sed", "head", "tail", "sort", "wc", "tr", "cut", "uniq", "nl", "tee", "rev", "diff", "patch"}
-	utilities := []string{"echo", "printf", "true", "false", "test", "[", "yes", "basename", "dirname", "seq"}
-	conversion := []string{"od", "hexdump", "base64", "uuencode", "uudecode", "fmt", "fold", "expand", "unexpand", "join", "comm", "csplit", "split"}
+	builtins := []string{"cat", "grep", "sed", "head", "tail", "sort", "wc", "tr", "cut", "uniq", "nl", "tee", "rev", "diff", "patch", "diff3", "merge", "awk", "jq", "csvcut", "csvgrep", "csvjoin", "rextract", "yaml2json", "json2yaml", "toml2json", "json2toml", "xxd", "sha256sum", "md5sum", "split", "join", "paste", "comm", "tac", "shuf", "strings", "column", "expand", "unexpand", "fold", "fmt", "stats"}
+	utilities := []string{"echo", "printf", "true", "false", "test", "[", "yes", "basename", "dirname", "seq", "date"}
+	conversion := []string{"od", "hexdump", "base64", "uuencode", "uudecode", "csplit"}
 	calculation := []string{"bc", "dc", "expr"}
-	compression := []string{"gzip", "gunzip", "bzip2", "bunzip2", "xz", "unxz"}
+	compression := []string{"gzip", "gunzip", "zcat", "bzip2", "bunzip2", "bzcat", "xz", "unxz", "xzcat", "tar"}
 	special := []string{"llmcmd", "llmsh", "help", "man"}
 
 	categories["Built-in Text Processing"] = builtins
@@ -129,6 +149,7 @@ func (h *HelpSystem) FormatCommandList() string {
 	categories["Calculation"] = calculation
 	categories["Compression"] = compression
 	categories["Special Commands"] = special
+	categories["Virtual Files"] = vfsBuiltins
 
 	for category, commands := range categories {
 		result.WriteString(fmt.Sprintf("%s:\n", category))
@@ -164,16 +185,27 @@ func (h *HelpSystem) initializeBuiltinHelp() {
 
 	h.commands["grep"] = &CommandHelp{
 		Name:        "grep",
-		Usage:       "grep [options] pattern [file...]",
-		Description: "search text using patterns",
+		Usage:       "grep [options] pattern",
+		Description: "search text using patterns (basic regex unless -E or -F)",
 		Options: []Option{
+			{"-E", "extended regex"},
+			{"-F", "fixed strings"},
+			{"-e pattern", "add a pattern; may be repeated"},
 			{"-i", "ignore case"},
 			{"-v", "invert match"},
+			{"-w", "match whole words"},
+			{"-x", "match whole lines"},
 			{"-n", "show line numbers"},
-			{"-c", "count matches"},
+			{"-c", "count matching lines"},
+			{"-o", "print only the matching parts"},
+			{"-m num", "stop after num matching lines"},
+			{"-A num", "print num lines of context after each match"},
+			{"-B num", "print num lines of context before each match"},
+			{"-C num", "print num lines of context around each match"},
 		},
 		Examples: []Example{
-			{"grep \"error\" log.txt", "Find lines containing 'error'"},
+			{"grep \"error\" < log.txt", "Find lines containing 'error'"},
+			{"grep -E -C2 'panic|fatal'", "Show matches with two lines of context"},
 			{"cat file.txt | grep -i \"warning\"", "Case-insensitive search"},
 		},
 		Related: []string{"sed", "awk"},
@@ -181,13 +213,242 @@ func (h *HelpSystem) initializeBuiltinHelp() {
 
 	h.commands["sed"] = &CommandHelp{
 		Name:        "sed",
-		Usage:       "sed 's/pattern/replacement/flags' [file...]",
-		Description: "stream editor for filtering and transforming text",
+		Usage:       "sed [-n] [-E] [-e script]... [script]",
+		Description: "stream editor for filtering and transforming text (basic regex unless -E)",
+		Options: []Option{
+			{"-n", "print only what p and s///p print"},
+			{"-E, -r", "extended regex"},
+			{"-e script", "add a script; may be repeated"},
+		},
 		Examples: []Example{
-			{"sed 's/old/new/g' file.txt", "Replace all occurrences of 'old' with 'new'"},
+			{"sed 's/old/new/g' < file.txt", "Replace all occurrences of 'old' with 'new'"},
 			{"echo \"hello\" | sed 's/h/H/'", "Replace first 'h' with 'H'"},
+			{"sed -E 's/([0-9]+)-([0-9]+)/\\2-\\1/'", "Swap two numbers with groups"},
+			{"sed -n '10,20p'", "Print lines 10 to 20"},
+			{"sed '/^#/d'", "Delete comment lines"},
+		},
+		Related: []string{"grep", "tr", "awk"},
+	}
+
+	h.commands["tr"] = &CommandHelp{
+		Name:        "tr",
+		Usage:       "tr [-cdst] SET1 [SET2]",
+		Description: "translate, delete or squeeze characters (GNU semantics; newlines are characters too)",
+		Options: []Option{
+			{"-d", "delete the characters in SET1"},
+			{"-s", "squeeze runs of a character in the last set into one"},
+			{"-c", "use the complement of SET1"},
+			{"-t", "truncate SET1 to the length of SET2"},
+			{"SET", "characters, escapes such as \\n, ranges such as a-z, classes such as [:upper:], [:digit:], [:space:]; [c*] in SET2 repeats c"},
+		},
+		Examples: []Example{
+			{"tr '[:upper:]' '[:lower:]'", "Lower-case the input"},
+			{"tr -cs '[:alpha:]' '\\n'", "One word per line"},
+			{"tr -d '\\r'", "Strip carriage returns"},
+			{"tr -s ' '", "Collapse runs of spaces"},
+		},
+		Related: []string{"sed", "cut"},
+	}
+
+	h.commands["sort"] = &CommandHelp{
+		Name:        "sort",
+		Usage:       "sort [-bfnrsu] [-t sep] [-k pos1[,pos2]]...",
+		Description: "sort lines of text (coreutils semantics, C locale)",
+		Options: []Option{
+			{"-n", "compare leading numbers; lines without one count as 0"},
+			{"-r", "reverse the order"},
+			{"-u", "keep only the first of lines with equal keys"},
+			{"-t sep", "field separator (default: runs of blanks)"},
+			{"-k pos1[,pos2]", "sort on fields pos1 to pos2; a position is F[.C] with optional b, f, n or r"},
+			{"-f", "fold lower case to upper case"},
+			{"-b", "ignore leading blanks in keys"},
+			{"-s", "stable: no whole-line comparison between equal keys"},
+		},
+		Examples: []Example{
+			{"sort -t, -k2,2n data.csv", "Sort by the numeric second column"},
+			{"sort | uniq -c | sort -rn", "Sort by frequency"},
+		},
+		Related: []string{"uniq", "cut"},
+	}
+
+	h.commands["uniq"] = &CommandHelp{
+		Name:        "uniq",
+		Usage:       "uniq [-cdiu] [-f N] [-s N] [-w N]",
+		Description: "collapse runs of adjacent equal lines; sort | uniq runs as one step that only keeps the distinct lines",
+		Options: []Option{
+			{"-c", "prefix lines with the number of occurrences"},
+			{"-d", "only print lines that are repeated"},
+			{"-u", "only print lines that are not repeated"},
+			{"-i", "ignore case when comparing"},
+			{"-f N", "skip the first N fields before comparing"},
+			{"-s N", "skip the first N characters (after -f) before comparing"},
+			{"-w N", "compare at most N characters"},
+		},
+		Examples: []Example{
+			{"sort | uniq -c | sort -rn", "Count and rank the lines of a large input"},
+			{"sort -f | uniq -di", "Lines that appear more than once, ignoring case"},
+			{"sort -k2 | uniq -c -f1", "Count lines by everything after the first field"},
+		},
+		Related: []string{"sort", "stats"},
+	}
+
+	h.commands["awk"] = &CommandHelp{
+		Name:        "awk",
+		Usage:       "awk [-F fs] [-v var=value] 'program' [var=value...]",
+		Description: "pattern scanning and processing (subset: no getline, user functions or output redirection)",
+		Options: []Option{
+			{"-F fs", "field separator: a character, a regex, or t for a tab"},
+			{"-v var=value", "assign a variable before BEGIN"},
+		},
+		Examples: []Example{
+			{"awk '{print $2}'", "Print the second field of each line"},
+			{"awk -F, '$3 > 100 {n++} END {print n}'", "Count CSV rows whose third column exceeds 100"},
+			{"awk '{sum[$1] += $2} END {for (k in sum) printf \"%s %d\\n\", k, sum[k]}'", "Sum the second column by the first"},
+		},
+		Related: []string{"cut", "sed", "grep"},
+	}
+
+	h.commands["jq"] = &CommandHelp{
+		Name:        "jq",
+		Usage:       "jq [-r] [-c] [-n] [-s] [--arg name value] [filter]",
+		Description: "process a stream of JSON values (subset: no def, label or file operands)",
+		Options: []Option{
+			{"-r", "print strings without quotes"},
+			{"-j", "like -r, without newlines"},
+			{"-c", "compact output, one value per line"},
+			{"-n", "run the filter once on null; read the input with input and inputs"},
+			{"-s", "read all input values into one array"},
+			{"-S", "sort object keys"},
+			{"--arg name value", "bind $name to a string"},
+			{"--argjson name json", "bind $name to a JSON value"},
+		},
+		Examples: []Example{
+			{"jq '.items[] | select(.ok) | .name'", "Names of the items that are ok"},
+			{"jq -r '.[] | [.id, .name] | @csv'", "Convert an array of objects to CSV"},
+			{"jq -c 'group_by(.level) | map({level: .[0].level, count: length})'", "Count log entries by level"},
+		},
+		Related: []string{"awk", "grep"},
+	}
+
+	h.commands["rextract"] = &CommandHelp{
+		Name:        "rextract",
+		Usage:       "rextract [-1inz] [-g GROUPS | -f FORMAT | --json] PATTERN",
+		Description: "print what a Go (RE2) regular expression captures from each line, one result per match",
+		Options: []Option{
+			{"-g GROUPS", "print these groups, by number or name, separated by tabs (default: all groups, or the whole match)"},
+			{"-f FORMAT", "fill a template: $1, ${1} or ${name} for groups, $0 for the match, \\t and \\n for tab and newline"},
+			{"--json", "print an object per match: the match and each group by name or number"},
+			{"-1", "only the first match of each line"},
+			{"-i", "ignore case"},
+			{"-n", "prefix results with their line number"},
+			{"-z", "match across the whole input, so patterns may span lines"},
+		},
+		Examples: []Example{
+			{"rextract 'user=(\\w+) ip=(\\S+)'", "User and address of each login, tab-separated"},
+			{"rextract '(?P<ts>\\S+) ERROR (?P<msg>.*)' -f '${ts}: ${msg}'", "Reformat error lines"},
+			{"rextract --json 'id=(?P<id>\\d+)' | jq -s 'map(.id)'", "Collect ids with jq"},
+		},
+		Related: []string{"grep", "sed", "awk"},
+	}
+
+	h.commands["yaml2json"] = &CommandHelp{
+		Name:        "yaml2json",
+		Usage:       "yaml2json [-c] [-S]",
+		Description: "convert YAML documents to JSON, one value per document (no complex ? keys)",
+		Options: []Option{
+			{"-c", "compact output, one value per line"},
+			{"-S", "sort object keys"},
+		},
+		Examples: []Example{
+			{"yaml2json < deploy.yaml | jq '.spec.containers[].image'", "Images used by a Kubernetes manifest"},
+			{"yaml2json -c < compose.yaml | jq -r '.services | keys[]'", "Service names in a Compose file"},
+		},
+		Related: []string{"json2yaml", "toml2json", "jq"},
+	}
+
+	h.commands["json2yaml"] = &CommandHelp{
+		Name:        "json2yaml",
+		Usage:       "json2yaml",
+		Description: "convert JSON values to block-style YAML, one document per value",
+		Examples: []Example{
+			{"yaml2json < values.yaml | jq '.replicas = 3' | json2yaml", "Edit a YAML file with jq"},
+		},
+		Related: []string{"yaml2json", "jq"},
+	}
+
+	h.commands["toml2json"] = &CommandHelp{
+		Name:        "toml2json",
+		Usage:       "toml2json [-c] [-S]",
+		Description: "convert a TOML document to a JSON object; dates and times become strings",
+		Options: []Option{
+			{"-c", "compact output"},
+			{"-S", "sort object keys"},
+		},
+		Examples: []Example{
+			{"toml2json < Cargo.toml | jq -r '.dependencies | keys[]'", "List the dependencies of a Rust crate"},
 		},
-		Related: []string{"grep", "tr"},
+		Related: []string{"json2toml", "yaml2json", "jq"},
+	}
+
+	h.commands["json2toml"] = &CommandHelp{
+		Name:        "json2toml",
+		Usage:       "json2toml",
+		Description: "convert a JSON object to TOML tables; null values are an error",
+		Examples: []Example{
+			{"toml2json < config.toml | jq '.server.port = 9090' | json2toml", "Edit a TOML file with jq"},
+		},
+		Related: []string{"toml2json", "jq"},
+	}
+
+	h.commands["csvcut"] = &CommandHelp{
+		Name:        "csvcut",
+		Usage:       "csvcut [-c columns] [-C columns] [-n] [-d delim] [-t] [-H]",
+		Description: "select CSV columns by name or number, respecting quoted fields",
+		Options: []Option{
+			{"-c columns", "columns to keep: names, numbers or ranges such as 2-4"},
+			{"-C columns", "columns to drop"},
+			{"-n", "list the column names"},
+			{"-d delim", "field delimiter (default ,)"},
+			{"-t", "tab-delimited input and output"},
+			{"-H", "the input has no header row"},
+		},
+		Examples: []Example{
+			{"csvcut -c name,email users.csv", "Keep the name and email columns"},
+		},
+		Related: []string{"csvgrep", "csvjoin", "cut"},
+	}
+
+	h.commands["csvgrep"] = &CommandHelp{
+		Name:        "csvgrep",
+		Usage:       "csvgrep [-c columns] (-m string | -r regex) [-i] [-a] [-d delim] [-t] [-H]",
+		Description: "keep CSV rows whose columns match, keeping the header",
+		Options: []Option{
+			{"-c columns", "columns to search (default: any column)"},
+			{"-m string", "match a substring"},
+			{"-r regex", "match a regular expression"},
+			{"-i", "keep the rows that do not match"},
+			{"-a", "a match in any of the columns is enough (default: all must match)"},
+		},
+		Examples: []Example{
+			{"csvgrep -c status -m failed", "Rows whose status contains failed"},
+		},
+		Related: []string{"csvcut", "grep"},
+	}
+
+	h.commands["csvjoin"] = &CommandHelp{
+		Name:        "csvjoin",
+		Usage:       "csvjoin -c column[,right_column] [--left|--right|--outer] [-d delim] [-t] [-H]",
+		Description: "join two CSV tables on a column; input is the left table, a ---LLMCMD_CSVJOIN_SEPARATOR--- line and the right table",
+		Options: []Option{
+			{"-c column", "join column, or left,right when the names differ"},
+			{"--left", "keep unmatched rows of the left table"},
+			{"--right", "keep unmatched rows of the right table"},
+			{"--outer", "keep unmatched rows of both tables"},
+		},
+		Examples: []Example{
+			{"{ cat users.csv; echo ---LLMCMD_CSVJOIN_SEPARATOR---; cat orders.csv; } | csvjoin -c id", "Join users and orders on id"},
+		},
+		Related: []string{"csvcut", "csvgrep"},
 	}
 
 	// Add more built-in commands...
@@ -241,7 +502,22 @@ func (h *HelpSystem) initializeSpecialHelp() {
 			{"cat doc.txt | llmcmd \"summarize this\"", "Summarize document content"},
 			{"echo \"data\" | llmcmd \"analyze this data\"", "Analyze input data"},
 		},
-		Related: []string{"llmsh"},
+		Related: []string{"llm", "llmsh"},
+	}
+
+	h.commands["llm"] = &CommandHelp{
+		Name:        "llm",
+		Usage:       "llm [-n] \"prompt\"",
+		Description: "send a prompt and stdin to the model (no tools) and print the reply; uses the internal model and the shell's quota",
+		Options: []Option{
+			{"-n", "do not read stdin; send the prompt alone"},
+		},
+		Examples: []Example{
+			{"head -50 log.txt | llm \"summarize this chunk in one line\"", "Summarize part of a file"},
+			{"grep ERROR log.txt | llm \"group these errors by cause\" > causes.txt", "Classify lines mid-pipeline"},
+			{"llm -n \"suggest a regex matching ISO dates\"", "Ask without input"},
+		},
+		Related: []string{"llmcmd"},
 	}
 
 	h.commands["llmsh"] = &CommandHelp{
@@ -254,6 +530,253 @@ func (h *HelpSystem) initializeSpecialHelp() {
 		Related: []string{"llmcmd"},
 	}
 
+	h.commands["jobs"] = &CommandHelp{
+		Name:        "jobs",
+		Usage:       "jobs",
+		Description: "list background jobs started with &; finished jobs are listed once",
+		Examples: []Example{
+			{"sort big.txt > sorted.txt &\njobs", "Show whether the sort is still running"},
+		},
+		Related: []string{"wait", "fg"},
+	}
+
+	h.commands["wait"] = &CommandHelp{
+		Name:        "wait",
+		Usage:       "wait [%job...]",
+		Description: "wait for background jobs; the exit status is that of the last job named",
+		Examples: []Example{
+			{"sort a > a.out & sort b > b.out & wait", "Sort two files concurrently"},
+			{"grep -q x big.txt & wait %1 && echo found", "Branch on a job's status"},
+		},
+		Related: []string{"jobs", "fg"},
+	}
+
+	h.commands["fg"] = &CommandHelp{
+		Name:        "fg",
+		Usage:       "fg [%job]",
+		Description: "print a background job's command and wait for it (default: the most recent job)",
+		Examples: []Example{
+			{"fg %1", "Wait for job 1"},
+		},
+		Related: []string{"jobs", "wait"},
+	}
+
+	h.commands["vls"] = &CommandHelp{
+		Name:        "vls",
+		Usage:       "vls [-l] [pattern | directory...]",
+		Description: "list virtual files and directories, the input and output files and the parent llmcmd's files (default: the top directory); a directory lists what is in it",
+		Options: []Option{
+			{"-l", "show each file's kind (virtual, input, output, parent or directory) and size"},
+		},
+		Examples: []Example{
+			{"vls", "List the top directory"},
+			{"vls out/reports", "List a directory"},
+			{"vls -l '*.txt'", "List text files with their sizes"},
+		},
+		Related: []string{"vcat", "vrm", "vstat"},
+	}
+
+	h.commands["vcat"] = &CommandHelp{
+		Name:        "vcat",
+		Usage:       "vcat file...",
+		Description: "print files like cat, but leave virtual files unread so they can be used again; parent files are consumed as with cat",
+		Examples: []Example{
+			{"grep ERROR log.txt > errors\nvcat errors\nwc -l < errors", "Inspect an intermediate file, then use it"},
+		},
+		Related: []string{"cat", "vls"},
+	}
+
+	h.commands["vcp"] = &CommandHelp{
+		Name:        "vcp",
+		Usage:       "vcp source destination",
+		Description: "copy a file without consuming it, replacing destination; a parent llmcmd file copied to a new name shares its data until either is written, so backups of large files are cheap",
+		Examples: []Example{
+			{"vcp app.py app.py.orig", "Keep the original before patching app.py"},
+		},
+		Related: []string{"vcat", "vrm"},
+	}
+
+	h.commands["vrm"] = &CommandHelp{
+		Name:        "vrm",
+		Usage:       "vrm file...",
+		Description: "remove virtual files or parent llmcmd files; the input and output files cannot be removed",
+		Examples: []Example{
+			{"vrm tmp1 tmp2", "Remove intermediate files"},
+		},
+		Related: []string{"vls"},
+	}
+
+	h.commands["vstat"] = &CommandHelp{
+		Name:        "vstat",
+		Usage:       "vstat [-c format] file...",
+		Description: "print the kind and size of files without reading them, or that they are directories; with -c, the permissions and times too",
+		Options: []Option{
+			{"-c FORMAT, --format=FORMAT", "print FORMAT for each file: %n name, %s size, %F kind, %a/%A permissions in octal/symbolic form, %y/%Y modification time, %w/%W creation time (- and 0 when not known)"},
+		},
+		Examples: []Example{
+			{"vstat out.txt", "Check that a file was written"},
+			{"vstat -c '%Y %n' *.csv | sort -n | tail -1", "Find the most recently written CSV file"},
+		},
+		Related: []string{"vls"},
+	}
+
+	h.commands["find"] = &CommandHelp{
+		Name:        "find",
+		Usage:       "find [start...] [expression]",
+		Description: "search the files llmsh can see; directories are those made with mkdir and the prefixes of names with slashes, and names are printed as vls prints them",
+		Options: []Option{
+			{"-name PATTERN", "base name matches a glob (-iname ignores case)"},
+			{"-path PATTERN", "whole name matches a glob; * matches / too"},
+			{"-type f|d|p", "files, directories or named pipes"},
+			{"-size [+-]N[cwbkMG]", "size in units rounded up, 512-byte blocks by default"},
+			{"-newer FILE", "modified after FILE"},
+			{"-mmin [+-]N, -mtime [+-]N", "modified more than (+), less than (-) or exactly N minutes or days ago"},
+			{"-perm MODE, -perm -MODE, -perm /MODE", "octal permissions exactly MODE, with all of its bits or with any of them"},
+			{"-empty", "empty files and directories"},
+			{"-maxdepth N, -mindepth N", "limit how deep below the starting points to look"},
+			{"! EXPR, EXPR -o EXPR, ( EXPR )", "negation, alternatives and grouping; -a is implied"},
+		},
+		Examples: []Example{
+			{"find -name '*.log' -size +10k", "Find large logs"},
+			{"find logs -type f -newer start.txt", "Files written under logs/ since start.txt"},
+			{"find -type f -mmin -10 -perm -600", "Files written in the last ten minutes that the owner can read and write"},
+		},
+		Related: []string{"vls", "vstat"},
+	}
+
+	h.commands["mkdir"] = &CommandHelp{
+		Name:        "mkdir",
+		Usage:       "mkdir [-p] directory...",
+		Description: "make virtual directories; writing a file also makes the directories it is in, and paths may use ./, .. and a leading / for the top",
+		Options: []Option{
+			{"-p", "make parent directories too; an existing directory is not an error"},
+		},
+		Examples: []Example{
+			{"mkdir -p out/reports; grep ERROR < app.log > out/reports/errors.txt", "Organize results in directories"},
+		},
+		Related: []string{"rmdir", "vls", "find"},
+	}
+
+	h.commands["rmdir"] = &CommandHelp{
+		Name:        "rmdir",
+		Usage:       "rmdir directory...",
+		Description: "remove empty virtual directories",
+		Examples: []Example{
+			{"vrm out/tmp/*; rmdir out/tmp", "Remove a scratch directory"},
+		},
+		Related: []string{"mkdir", "vrm"},
+	}
+
+	h.commands["mkfifo"] = &CommandHelp{
+		Name:        "mkfifo",
+		Usage:       "mkfifo name...",
+		Description: "make named pipes: what commands write to one waits there (up to 64KiB) until a command reads it, and readers see EOF once every writer has closed it. Under llmcmd the pipe is made in the parent, so separately spawned scripts, and the LLM with open, can stream data through it by name; a script reading a parent pipe gets the data once the writers are done",
+		Examples: []Example{
+			{"mkfifo feed; grep ERROR big.log > feed &\nsort < feed | uniq -c", "Stream between a background job and a pipeline"},
+			{"mkfifo counts; grep -c ERROR app.log > counts", "Hand a result to another spawned script, which reads it with cat counts"},
+		},
+		Related: []string{"mkdir", "vstat", "find"},
+	}
+
+	h.commands["set"] = &CommandHelp{
+		Name:        "set",
+		Usage:       "set [-e|+e] [-o option|+o option]",
+		Description: "change shell options (- enables, + disables); set -o lists them, set alone lists the variables",
+		Options: []Option{
+			{"-e", "errexit: stop the script at the first failing statement, except the left side of && or ||"},
+			{"-o pipefail", "a pipeline fails with its rightmost failing command instead of its last one"},
+			{"-o timing", "record the time spent in each command; the summary is printed at the end and by times"},
+		},
+		Examples: []Example{
+			{"set -e -o pipefail", "Strict mode: stop on any failure, including inside pipelines"},
+			{"set +e", "Go on after failures again"},
+		},
+		Related: []string{"jobs"},
+	}
+
+	h.commands["alias"] = &CommandHelp{
+		Name:        "alias",
+		Usage:       "alias [name[=value]...]",
+		Description: "define aliases, or print them (all by default); arguments after an alias are appended to its last command",
+		Examples: []Example{
+			{"alias errors='grep ERROR | sort'\nerrors -r < log.txt", "Name a pipeline; -r is passed to sort"},
+			{"alias", "List all aliases"},
+		},
+		Related: []string{"unalias"},
+	}
+
+	h.commands["unalias"] = &CommandHelp{
+		Name:        "unalias",
+		Usage:       "unalias name... | unalias -a",
+		Description: "remove aliases (-a: all of them)",
+		Examples: []Example{
+			{"unalias errors", "Remove the errors alias"},
+		},
+		Related: []string{"alias"},
+	}
+
+	h.commands["return"] = &CommandHelp{
+		Name:        "return",
+		Usage:       "return [n]",
+		Description: "end the function being called with status n (default: that of the last command)",
+		Examples: []Example{
+			{"check() { test -n \"$1\" || return 2; grep -q \"$1\" log.txt; }", "Validate an argument"},
+		},
+		Related: []string{"set"},
+	}
+
+	h.commands["time"] = &CommandHelp{
+		Name:        "time",
+		Usage:       "time pipeline",
+		Description: "run a pipeline and print its run time on stderr; its commands run concurrently, so each one's time and share run from the start of the pipeline to its exit",
+		Examples: []Example{
+			{"time grep ERROR big.log | sort | uniq -c > counts.txt", "See which stage of a pipeline is slow"},
+		},
+		Related: []string{"times", "set"},
+	}
+
+	h.commands["times"] = &CommandHelp{
+		Name:        "times",
+		Usage:       "times",
+		Description: "print the calls and total run time of each command so far, slowest first (requires set -o timing)",
+		Examples: []Example{
+			{"set -o timing\n...\ntimes", "Profile a whole script"},
+		},
+		Related: []string{"time", "set"},
+	}
+
+	h.commands["history"] = &CommandHelp{
+		Name:        "history",
+		Usage:       "history [-s] [-n count] [pattern]",
+		Description: "print the interactive history, or with -s the scripts run in the project, keeping the entries containing pattern (history is kept in a .llmsh directory of the project when there is one)",
+		Examples: []Example{
+			{"history sort", "Find earlier pipelines using sort"},
+			{"history -s -n 3", "Show the last three scripts run in the project"},
+		},
+		Related: []string{"times"},
+	}
+
+	h.commands["vlock"] = &CommandHelp{
+		Name:        "vlock",
+		Usage:       "vlock [-s] [-n | -w seconds] file",
+		Description: "take an advisory lock on a file name, exclusive unless -s is given, waiting while another shell holds a conflicting lock (-n: fail at once, -w: fail after seconds); locks only hold back other vlock calls, and a background job, pipeline stage or command substitution releases its locks when it ends. Under llmcmd the parent keeps the locks, so separately spawned scripts share them",
+		Examples: []Example{
+			{"add() { vlock report; cat \"$1\" >> report; vunlock report; }\nadd a.txt &\nadd b.txt &\nwait", "Append from background jobs without interleaving"},
+			{"vlock -n report && cat part.txt >> report", "Append only if no other script holds the lock"},
+		},
+		Related: []string{"vunlock", "wait"},
+	}
+
+	h.commands["vunlock"] = &CommandHelp{
+		Name:        "vunlock",
+		Usage:       "vunlock file...",
+		Description: "release this shell's advisory locks on files; names it has not locked are ignored",
+		Examples: []Example{
+			{"vunlock report", "Let the next job write report"},
+		},
+		Related: []string{"vlock"},
+	}
+
 	h.commands["help"] = &CommandHelp{
 		Name:        "help",
 		Usage:       "help [command]",
@@ -294,44 +817,519 @@ func (h *HelpSystem) addMoreBuiltinHelp() {
 
 	h.commands["tail"] = &CommandHelp{
 		Name:        "tail",
-		Usage:       "tail [-n lines] [file...]",
-		Description: "output the last part of files",
+		Usage:       "tail [-n N | -n +N] [-f [--timeout SECONDS]]",
+		Description: "output the last part of the input, or follow what is appended to it",
 		Options: []Option{
-			{"-n N", "output last N lines"},
+			{"-n N", "output last N lines (default 10; -N also works)"},
+			{"-n +N", "output from line N on"},
+			{"-f", "once the input pauses, output its last lines, then each line appended until it ends"},
+			{"--timeout SECONDS", "with -f, stop after no input for SECONDS"},
 		},
 		Examples: []Example{
-			{"tail -20 log.txt", "Show last 20 lines"},
+			{"tail -20 < log.txt", "Show last 20 lines"},
+			{"long_job | tail -f --timeout 30", "Watch a long-running command until it is quiet for 30 seconds"},
 		},
 		Related: []string{"head", "cat"},
 	}
 
-	// Add more as needed...
-}
+	h.commands["sha256sum"] = &CommandHelp{
+		Name:        "sha256sum",
+		Usage:       "sha256sum [-b] [--tag] [file...] | sha256sum -c [--quiet] [--status] [list...]",
+		Description: "print SHA-256 checksums of stdin or files, or check them against a list; md5sum does the same with MD5",
+		Options: []Option{
+			{"-c, --check", "read HASH  NAME lines and check each file, printing OK or FAILED"},
+			{"-b", "mark names with * in the output, as binary mode does"},
+			{"--tag", "print BSD-style lines: SHA256 (NAME) = HASH"},
+			{"--quiet", "with -c, print only failures"},
+			{"--status", "with -c, print nothing; the exit status tells"},
+			{"--ignore-missing", "with -c, skip files that cannot be read"},
+			{"--strict", "with -c, fail on improperly formatted lines"},
+		},
+		Examples: []Example{
+			{"sha256sum release.tar.gz > release.sha256", "Record a checksum"},
+			{"sha256sum -c release.sha256", "Verify files against recorded checksums"},
+		},
+		Related: []string{"md5sum"},
+	}
+
+	h.commands["md5sum"] = &CommandHelp{
+		Name:        "md5sum",
+		Usage:       "md5sum [-b] [--tag] [file...] | md5sum -c [--quiet] [--status] [list...]",
+		Description: "print MD5 checksums of stdin or files, or check them against a list; the options are those of sha256sum",
+		Related:     []string{"sha256sum"},
+	}
+
+	h.commands["split"] = &CommandHelp{
+		Name:        "split",
+		Usage:       "split [-l N | -b SIZE | -C SIZE | -n [l/]N] [-d] [-a N] [file [prefix]]",
+		Description: "split stdin or a file into virtual files named PREFIXaa, PREFIXab, ... (prefix x), printing each name as it is written",
+		Options: []Option{
+			{"-l N", "N lines per file (default 1000)"},
+			{"-b SIZE", "SIZE bytes per file; K, M, G are powers of 1024, KB, MB, GB of 1000"},
+			{"-C SIZE", "at most SIZE bytes of whole lines per file"},
+			{"-n N, -n l/N", "N files of equal size; with l/ lines are not broken"},
+			{"-d, -x", "numeric or hexadecimal suffixes"},
+			{"-a N", "suffix length (default 2)"},
+			{"--additional-suffix=SUF", "append SUF to the names"},
+			{"-e", "with -n, do not write empty files"},
+		},
+		Examples: []Example{
+			{"split -n l/4 big.log chunk.", "Split a log into four pieces of whole lines"},
+			{"split -l 500 data.csv part.; llm 'summarize' < part.aa", "Process a large input piece by piece"},
+		},
+		Related: []string{"vls", "cat"},
+	}
+
+	h.commands["join"] = &CommandHelp{
+		Name:        "join",
+		Usage:       "join [-1 FIELD] [-2 FIELD] [-t CHAR] [-a FILENUM] [-v FILENUM] [-o FORMAT] [-e EMPTY] [-i] file1 file2",
+		Description: "join the lines of two files sorted on a key field, printing the key and the other fields of each matching pair; either file may be - for stdin",
+		Options: []Option{
+			{"-1 FIELD, -2 FIELD", "join on FIELD of file 1 or 2 (default 1)"},
+			{"-j FIELD", "join on FIELD of both files"},
+			{"-t CHAR", "fields are separated by CHAR (default runs of blanks)"},
+			{"-a FILENUM", "also print unpairable lines of file FILENUM"},
+			{"-v FILENUM", "print only unpairable lines of file FILENUM"},
+			{"-o FORMAT", "print fields FORMAT, such as 0,1.2,2.3 or auto"},
+			{"-e EMPTY", "print EMPTY for missing fields in -o output"},
+			{"-i", "ignore case when comparing keys"},
+			{"--header", "join the first lines as headers"},
+		},
+		Examples: []Example{
+			{"sort users > u; sort orders > o; join u o", "Join two tables on their first column"},
+			{"join -t, -a1 -e NA -o 0,1.2,2.2 a.csv b.csv", "Left join of comma-separated files"},
+		},
+		Related: []string{"comm", "paste", "csvjoin", "sort"},
+	}
+
+	h.commands["paste"] = &CommandHelp{
+		Name:        "paste",
+		Usage:       "paste [-s] [-d LIST] [file...]",
+		Description: "merge the lines of files side by side, separated by tabs; each - reads the next line of stdin",
+		Options: []Option{
+			{"-d LIST", "use the delimiters in LIST in turn; \\n, \\t, \\\\ and \\0 (none) are understood"},
+			{"-s", "paste the lines of each file into one line"},
+		},
+		Examples: []Example{
+			{"paste names ages", "Put two columns side by side"},
+			{"paste -sd, -", "Join the lines of stdin with commas"},
+			{"paste - - -", "Combine every three lines into one"},
+		},
+		Related: []string{"join", "cut"},
+	}
+
+	h.commands["comm"] = &CommandHelp{
+		Name:        "comm",
+		Usage:       "comm [-123] [--output-delimiter=STR] [--total] file1 file2",
+		Description: "compare two sorted files: column 1 lists lines only in file1, column 2 lines only in file2, column 3 lines in both",
+		Options: []Option{
+			{"-1, -2, -3", "suppress column 1, 2 or 3"},
+			{"--output-delimiter=STR", "separate columns with STR instead of a tab"},
+			{"--total", "print the number of lines in each column"},
+			{"--nocheck-order", "do not report unsorted input"},
+		},
+		Examples: []Example{
+			{"comm -12 old new", "Lines in both files"},
+			{"comm -23 old new", "Lines only in old"},
+		},
+		Related: []string{"join", "diff", "sort"},
+	}
 
-// addMoreLLMHelp adds help for remaining LLM commands
-func (h *HelpSystem) addMoreLLMHelp() {
 	h.commands["printf"] = &CommandHelp{
 		Name:        "printf",
 		Usage:       "printf format [arguments...]",
-		Description: "format and print data",
+		Description: "format and print arguments like the shell's printf; the format is reused while arguments remain",
+		Options: []Option{
+			{"%d %i %o %u %x %X", "integers; arguments may be 0x hex, 0 octal or 'c for a character code"},
+			{"%f %e %g", "floating point numbers"},
+			{"%s %c", "strings and characters"},
+			{"%b", "string with backslash escapes expanded"},
+			{"%q", "string quoted for the shell"},
+			{"\\n \\t \\NNN \\xHH", "escapes in the format; \\c stops output"},
+		},
 		Examples: []Example{
 			{"printf \"Hello %s\\n\" \"World\"", "Formatted output"},
+			{"printf '%-10s %5.1f\\n' apple 1.5 pear 22", "Aligned columns, one line per pair of arguments"},
+		},
+		Related: []string{"echo", "seq"},
+	}
+
+	h.commands["seq"] = &CommandHelp{
+		Name:        "seq",
+		Usage:       "seq [-s SEP] [-w] [-f FORMAT] [first [increment]] last",
+		Description: "print numbers from first (default 1) to last in steps of increment (default 1); decimals are kept",
+		Options: []Option{
+			{"-s SEP", "separate numbers with SEP instead of a newline"},
+			{"-w", "pad numbers with zeros to equal width"},
+			{"-f FORMAT", "format numbers with a printf format such as %.2f"},
+		},
+		Examples: []Example{
+			{"seq 5", "1 to 5"},
+			{"seq -w 1 10", "01 to 10"},
+			{"seq 0 0.25 1", "Fractional steps"},
+			{"seq -s, 3", "1,2,3"},
+		},
+		Related: []string{"printf"},
+	}
+
+	h.commands["date"] = &CommandHelp{
+		Name:        "date",
+		Usage:       "date [-u] [-d STRING] [+FORMAT | -I[PRECISION] | -R | --rfc-3339=PRECISION]",
+		Description: "print the current time, or the time a date string describes, in the given format",
+		Options: []Option{
+			{"-u", "use UTC instead of the local time zone"},
+			{"-d STRING", "describe the time: 2024-01-31, 2024-01-31T10:00:00Z, @SECONDS, yesterday, 3 days ago, next monday, 2024-01-31 +1 month"},
+			{"+FORMAT", "strftime format: %Y %m %d %H %M %S %s %F %T %a %b %j %z %:z ..., with flags - _ 0 ^"},
+			{"-I[PRECISION]", "ISO 8601: date, hours, minutes, seconds or ns"},
+			{"-R", "RFC 5322 format, as in email headers"},
+			{"--rfc-3339=PRECISION", "RFC 3339: date, seconds or ns"},
+		},
+		Examples: []Example{
+			{"date +%Y-%m-%d", "Today's date"},
+			{"date -u -Iseconds", "UTC timestamp"},
+			{"date -d '2024-03-01 -1 day' +%F", "Date arithmetic"},
+			{"date -d @1700000000", "Convert seconds since the epoch"},
+		},
+		Related: []string{"printf"},
+	}
+
+	h.commands["nl"] = &CommandHelp{
+		Name:        "nl",
+		Usage:       "nl [-b STYLE] [-w N] [-s SEP] [-v N] [-i N] [-n FORMAT]",
+		Description: "number the lines of stdin; by default non-empty lines are numbered",
+		Options: []Option{
+			{"-b STYLE", "lines to number: a all, t non-empty, n none, pREGEX matching"},
+			{"-w N", "width of numbers (default 6)"},
+			{"-s SEP", "separator after numbers (default tab)"},
+			{"-v N", "first number (default 1)"},
+			{"-i N", "increment (default 1)"},
+			{"-n FORMAT", "ln left justified, rn right justified, rz zero padded"},
+		},
+		Examples: []Example{
+			{"nl -ba -w3 -s': ' < app.log", "Number every line, compactly"},
+			{"nl -b'p^ERROR'", "Number only the error lines"},
+		},
+		Related: []string{"cat", "grep"},
+	}
+
+	h.commands["tac"] = &CommandHelp{
+		Name:        "tac",
+		Usage:       "tac [-s SEP] [-b]",
+		Description: "print the lines of stdin in reverse order",
+		Options: []Option{
+			{"-s SEP", "records end with SEP instead of a newline"},
+			{"-b", "the separator starts records instead of ending them"},
+		},
+		Examples: []Example{
+			{"tac < app.log | head -20", "The last 20 lines, newest first"},
+		},
+		Related: []string{"tail", "rev", "sort"},
+	}
+
+	h.commands["shuf"] = &CommandHelp{
+		Name:        "shuf",
+		Usage:       "shuf [-n COUNT] [-r] [-e ARG... | -i LO-HI]",
+		Description: "print the lines of stdin in random order; -n samples lines without holding the whole input",
+		Options: []Option{
+			{"-n COUNT", "print at most COUNT lines"},
+			{"-e", "shuffle the arguments instead of stdin"},
+			{"-i LO-HI", "shuffle the numbers LO to HI"},
+			{"-r", "choose with replacement; needs -n"},
+		},
+		Examples: []Example{
+			{"shuf -n 50 < big.log | llm 'what kinds of entries are there?'", "Show the model a random sample of a large log"},
+			{"shuf -i 1-100 -n 3", "Three distinct random numbers"},
+		},
+		Related: []string{"sort", "head"},
+	}
+
+	h.commands["strings"] = &CommandHelp{
+		Name:        "strings",
+		Usage:       "strings [-a] [-n MIN] [-t d|o|x] [-e s|S|l|b] [-w] [-s SEP]",
+		Description: "print the runs of printable characters in binary stdin; a safe way to triage a binary file",
+		Options: []Option{
+			{"-n MIN", "print runs at least MIN characters long (default 4)"},
+			{"-t d|o|x", "prefix each string with its byte offset in decimal, octal or hexadecimal"},
+			{"-o", "same as -t o"},
+			{"-e s|S|l|b", "7-bit, 8-bit, 16-bit little endian or 16-bit big endian characters"},
+			{"-w", "count all white space, not only spaces and tabs, as printable"},
+			{"-s SEP", "write SEP after each string instead of a newline"},
+		},
+		Examples: []Example{
+			{"strings -n 8 < app.bin | head -50", "Look at the text in a binary without reading its bytes"},
+			{"strings -t x < firmware.img | grep -i version", "Find version strings and where they are"},
+		},
+		Related: []string{"xxd", "head", "grep"},
+	}
+
+	h.commands["diff"] = &CommandHelp{
+		Name:        "diff",
+		Usage:       "diff [-u] [-U N] [-q] [-r] [-N] [-i] [-w] [-b] FILE1 FILE2",
+		Description: "compare two virtual files, or two directories of them, as a unified diff; the exit status is 0 when they are the same, 1 when they differ and 2 on errors",
+		Options: []Option{
+			{"-U N", "N lines of context (default 3)"},
+			{"-q", "only report whether the files differ"},
+			{"-r", "compare subdirectories too; a directory is a prefix of file names, as in logs/app.log"},
+			{"-N", "compare a file missing from one directory as an empty file"},
+			{"-i", "ignore case"},
+			{"-w", "ignore all white space"},
+			{"-b", "ignore changes in the amount of white space"},
+		},
+		Examples: []Example{
+			{"diff config.old config.new", "Show what changed"},
+			{"diff -r before after", "Compare two sets of files, such as two tar extractions"},
+			{"diff -q expected.txt - < actual.txt", "Compare a file with stdin"},
+		},
+		Related: []string{"patch", "comm", "sha256sum"},
+	}
+
+	h.commands["patch"] = &CommandHelp{
+		Name:        "patch",
+		Usage:       "patch [-pN] [-F N] [--dry-run] [-i PATCHFILE] [-o OUTFILE] [FILE]",
+		Description: "apply a unified diff on stdin to the virtual files it names; failed hunks go to FILE.rej",
+		Options: []Option{
+			{"-pN", "strip N leading directories from file names (without -p only the base name is kept)"},
+			{"-F N", "ignore up to N lines of context of hunks that do not match (default 2)"},
+			{"--dry-run", "only check that the patch applies"},
+			{"-i PATCHFILE", "read the patch from a virtual file"},
+			{"-o OUTFILE", "write the patched file to OUTFILE"},
+			{"-s", "only report failures"},
+		},
+		Examples: []Example{
+			{"patch -p1 < fix.diff", "Apply a git-style patch (a/ and b/ prefixes)"},
+			{"patch --dry-run -p1 < fix.diff", "Check a patch before applying it"},
+			{"diff -u old.txt new.txt | patch old.txt", "Make old.txt the same as new.txt"},
+		},
+		Related: []string{"diff", "diff3", "sed"},
+	}
+
+	h.commands["diff3"] = &CommandHelp{
+		Name:        "diff3",
+		Usage:       "diff3 [-m] [-A|-E] [-T] [-L LABEL]... MINE OLDER YOURS",
+		Description: "compare three virtual files, or merge the changes from OLDER to YOURS into MINE (-m)",
+		Options: []Option{
+			{"-m", "output the merged file; conflicts are bracketed with <<<<<<< and >>>>>>> and make diff3 fail"},
+			{"-A", "show the OLDER lines of conflicts after ||||||| (the default)"},
+			{"-E", "leave the OLDER lines out of conflicts"},
+			{"-T", "start the lines of each block with a tab"},
+			{"-L LABEL", "label for the conflict markers, once for each file (default the file names)"},
+		},
+		Examples: []Example{
+			{"diff3 ours.go base.go theirs.go", "See which side changed what (====1 mine, ====3 yours, ==== conflict)"},
+			{"diff3 -m -L ours -L base -L theirs ours.go base.go theirs.go > merged.go", "Three-way merge into a new file"},
+		},
+		Related: []string{"merge", "diff", "patch"},
+	}
+
+	h.commands["merge"] = &CommandHelp{
+		Name:        "merge",
+		Usage:       "merge [-p] [-A|-E] [-L LABEL]... FILE1 FILE2 FILE3",
+		Description: "merge the changes from FILE2 to FILE3 into FILE1, overwriting it; conflicts make merge fail",
+		Options: []Option{
+			{"-p", "write the result to stdout instead of FILE1"},
+			{"-A", "show the FILE2 lines of conflicts after |||||||"},
+			{"-E", "leave the FILE2 lines out of conflicts (the default)"},
+			{"-L LABEL", "label for the conflict markers, once for each file (default the file names)"},
+		},
+		Examples: []Example{
+			{"merge ours.go base.go theirs.go; grep -n '^<<<<<<<' < ours.go", "Merge theirs into ours, then find the conflicts"},
+			{"merge -p ours.go base.go theirs.go | grep -c '^======='", "Count conflicts without changing anything"},
+		},
+		Related: []string{"diff3", "diff", "patch"},
+	}
+
+	h.commands["column"] = &CommandHelp{
+		Name:        "column",
+		Usage:       "column [-t] [-s SEP] [-o SEP] [-R COLUMNS] [-N NAMES] [-x] [-c WIDTH]",
+		Description: "align the fields of each line as a table (-t), or fill lines into columns",
+		Options: []Option{
+			{"-t", "align fields as a table"},
+			{"-s SEP", "characters separating input fields (default: white space); empty fields are kept"},
+			{"-o SEP", "text between output columns (default: two spaces)"},
+			{"-R COLUMNS", "right-align these columns, by number or name"},
+			{"-N NAMES", "comma-separated column names, printed as a header"},
+			{"-x", "fill rows before columns"},
+			{"-c WIDTH", "output width for filling columns (default 80)"},
+		},
+		Examples: []Example{
+			{"column -t -s, -R 2 < totals.csv", "Align a CSV report, numbers to the right"},
+			{"cut -d ' ' -f 1 < users | sort | column -c 60", "List names in columns"},
+		},
+		Related: []string{"fmt", "paste", "csvcut"},
+	}
+
+	h.commands["expand"] = &CommandHelp{
+		Name:        "expand",
+		Usage:       "expand [-i] [-t N | -t LIST]",
+		Description: "convert tabs to spaces",
+		Options: []Option{
+			{"-t N", "tab stops every N columns (default 8)"},
+			{"-t LIST", "tab stops at these columns; a last /N or +N repeats them"},
+			{"-i", "only convert tabs at the start of lines"},
+		},
+		Examples: []Example{
+			{"expand -t 4 < code.go", "Show code with four-column tabs"},
+		},
+		Related: []string{"unexpand", "column"},
+	}
+
+	h.commands["unexpand"] = &CommandHelp{
+		Name:        "unexpand",
+		Usage:       "unexpand [-a] [--first-only] [-t N | -t LIST]",
+		Description: "convert leading spaces to tabs",
+		Options: []Option{
+			{"-a", "convert all runs of blanks that reach a tab stop, not only leading ones"},
+			{"-t N", "tab stops every N columns (default 8); implies -a"},
+			{"--first-only", "only convert leading blanks, even with -t"},
+		},
+		Examples: []Example{
+			{"unexpand --first-only -t 4 < code.py", "Indent with tabs instead of four spaces"},
+		},
+		Related: []string{"expand"},
+	}
+
+	h.commands["fold"] = &CommandHelp{
+		Name:        "fold",
+		Usage:       "fold [-s] [-b] [-w WIDTH]",
+		Description: "wrap lines to a width",
+		Options: []Option{
+			{"-w WIDTH", "wrap at WIDTH columns (default 80)"},
+			{"-s", "break after the last blank that fits, not in a word"},
+			{"-b", "count bytes rather than columns"},
+		},
+		Examples: []Example{
+			{"fold -s -w 72 < summary.txt", "Wrap a summary at word boundaries"},
+		},
+		Related: []string{"fmt", "cut"},
+	}
+
+	h.commands["fmt"] = &CommandHelp{
+		Name:        "fmt",
+		Usage:       "fmt [-w WIDTH] [-s] [-u] [-c] [-p PREFIX]",
+		Description: "refill paragraphs, keeping blank lines and indentation",
+		Options: []Option{
+			{"-w WIDTH", "maximum line width (default 75)"},
+			{"-s", "only split long lines; do not join short ones"},
+			{"-u", "one space between words, two after sentences"},
+			{"-c", "indent the rest of a paragraph like its second line"},
+			{"-p PREFIX", "only refill lines starting with PREFIX, such as '#'"},
+		},
+		Examples: []Example{
+			{"llm 'summarize' < notes | fmt -w 72", "Reflow an answer into tidy paragraphs"},
+			{"fmt -p '#' -w 60 < script.sh", "Rewrap comments in a script"},
+		},
+		Related: []string{"fold", "column"},
+	}
+
+	h.commands["stats"] = &CommandHelp{
+		Name:        "stats",
+		Usage:       "stats [-d DELIM | -t | --csv] [--header | -H] [-c COLUMNS] [-n N]",
+		Description: "summarize input in one pass: line/word/char counts, min/max/mean/sum of numeric columns, most frequent values",
+		Options: []Option{
+			{"-d DELIM", "split fields on DELIM instead of white space"},
+			{"-t", "split fields on tabs"},
+			{"--csv", "read quoted CSV; the first row is a header unless -H"},
+			{"--header", "the first line names the columns"},
+			{"-H", "the first line is data"},
+			{"-c COLUMNS", "only summarize these columns: numbers, ranges such as 2-4, or header names"},
+			{"-n N", "show the N most frequent values of each column (default 5, 0 for none)"},
 		},
-		Related: []string{"echo"},
+		Examples: []Example{
+			{"stats --csv < sales.csv", "Get a feel for a CSV file before analysing it"},
+			{"cut -d' ' -f9 < access.log | stats", "Distribution of HTTP status codes"},
+		},
+		Related: []string{"wc", "sort", "uniq", "awk"},
 	}
 
+	// Add more as needed...
+}
+
+// addMoreLLMHelp adds help for remaining LLM commands
+func (h *HelpSystem) addMoreLLMHelp() {
 	h.commands["base64"] = &CommandHelp{
 		Name:        "base64",
-		Usage:       "base64 [-d] [file]",
+		Usage:       "base64 [-d] [-i] [-w cols]",
 		Description: "base64 encode/decode data",
 		Options: []Option{
-			{"-d", "decode data"},
+			{"-d", "decode data; line breaks and missing padding are accepted"},
+			{"-i", "when decoding, ignore characters outside the alphabet"},
+			{"-w cols", "wrap encoded lines at cols characters (default 76, 0 disables)"},
 		},
 		Examples: []Example{
 			{"echo \"hello\" | base64", "Encode text"},
 			{"echo \"aGVsbG8K\" | base64 -d", "Decode text"},
 		},
-		Related: []string{"od", "hexdump"},
+		Related: []string{"od", "hexdump", "xxd"},
+	}
+
+	h.commands["xxd"] = &CommandHelp{
+		Name:        "xxd",
+		Usage:       "xxd [-p] [-r] [-u] [-c cols] [-g bytes] [-l len] [-s offset]",
+		Description: "make a hex dump, or convert one back to binary with -r",
+		Options: []Option{
+			{"-p", "plain dump: hex digits only, 30 bytes per line"},
+			{"-r", "convert a dump (or with -p, plain hex digits) back to binary"},
+			{"-c cols", "bytes per line (default 16)"},
+			{"-g bytes", "bytes per group (default 2, 0 for none)"},
+			{"-l len", "stop after len bytes"},
+			{"-s offset", "start at offset"},
+			{"-u", "upper case hex digits"},
+		},
+		Examples: []Example{
+			{"xxd -l 16 < image.png", "Inspect the magic number of a file"},
+			{"echo 48656c6c6f | xxd -r -p", "Decode hex digits"},
+		},
+		Related: []string{"hexdump", "od", "base64"},
+	}
+
+	h.commands["gzip"] = &CommandHelp{
+		Name:        "gzip",
+		Usage:       "gzip [-d] [-t] [-1..-9]",
+		Description: "compress or decompress gzip data from stdin to stdout; gunzip and zcat are gzip -d",
+		Options: []Option{
+			{"-d", "decompress; concatenated members are decompressed in turn"},
+			{"-t", "check the compressed data without printing it"},
+			{"-1..-9", "compression level, fastest to best"},
+		},
+		Examples: []Example{
+			{"zcat < access.log.gz | grep ' 500 '", "Search a compressed log"},
+		},
+		Related: []string{"bzip2", "xz"},
+	}
+
+	h.commands["bzip2"] = &CommandHelp{
+		Name:        "bzip2",
+		Usage:       "bzip2 -d [-t]",
+		Description: "decompress bzip2 data from stdin to stdout; bunzip2 and bzcat are bzip2 -d (compressing is not supported)",
+		Related:     []string{"gzip", "xz"},
+	}
+
+	h.commands["xz"] = &CommandHelp{
+		Name:        "xz",
+		Usage:       "xz -d [-t]",
+		Description: "decompress xz data (LZMA2) from stdin to stdout; unxz and xzcat are xz -d (compressing is not supported)",
+		Related:     []string{"gzip", "bzip2", "tar"},
+	}
+
+	h.commands["tar"] = &CommandHelp{
+		Name:        "tar",
+		Usage:       "tar -t|-x [-vzjJO] [-f archive] [member...]",
+		Description: "list or extract the members of a tar archive, optionally compressed with gzip, bzip2 or xz; members are extracted into virtual files",
+		Options: []Option{
+			{"-t", "list the members"},
+			{"-x", "extract the members into virtual files"},
+			{"-f ARCHIVE", "read the archive from a file instead of stdin"},
+			{"-v", "list in the long format, or name the members extracted"},
+			{"-O", "write extracted members to stdout"},
+			{"-z, -j, -J", "force gzip, bzip2 or xz; otherwise it is detected"},
+			{"--strip-components=N", "drop N leading directories from extracted names"},
+		},
+		Examples: []Example{
+			{"tar -tzf release.tar.gz", "See what an archive contains"},
+			{"tar -xzf release.tar.gz '*/README*'; vls", "Extract the READMEs and list the files"},
+			{"tar -xOf release.tar.gz pkg/config.json | jq .", "Read one member without extracting it"},
+		},
+		Related: []string{"gzip", "vls", "vcat"},
 	}
 
 	// Add more as needed...
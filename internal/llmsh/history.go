@@ -0,0 +1,172 @@
+package llmsh
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProjectDir is the project-local directory keeping the interactive history
+// and the scripts run, used when it exists in the current directory or a
+// parent
+const ProjectDir = ".llmsh"
+
+// Files in the project directory
+const (
+	projectHistoryFile = "history"
+	scriptLogFile      = "scripts.jsonl"
+)
+
+// maxScriptRuns is the number of script runs kept in the log
+const maxScriptRuns = 100
+
+// FindProjectDir returns the .llmsh directory of dir or of its nearest parent
+// that has one, or "" when there is none
+func FindProjectDir(dir string) string {
+	for {
+		path := filepath.Join(dir, ProjectDir)
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// scriptRun is a script run recorded in the project's log
+type scriptRun struct {
+	Time   time.Time `json:"time"`
+	Status int       `json:"status"`
+	Script string    `json:"script"`
+}
+
+// loadScriptRuns reads the runs in the log at path, oldest first; a missing
+// log has none
+func loadScriptRuns(path string) ([]scriptRun, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var runs []scriptRun
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var run scriptRun
+		if err := json.Unmarshal(scanner.Bytes(), &run); err == nil {
+			runs = append(runs, run)
+		}
+	}
+	return runs, scanner.Err()
+}
+
+// recordScriptRun appends a run to the log at path, which is rewritten with
+// the last maxScriptRuns once it holds twice as many
+func recordScriptRun(path string, run scriptRun) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	runs, err := loadScriptRuns(path)
+	if err != nil {
+		return err
+	}
+	if len(runs) >= 2*maxScriptRuns {
+		var b strings.Builder
+		for _, old := range runs[len(runs)-maxScriptRuns+1:] {
+			line, _ := json.Marshal(old)
+			b.Write(line)
+			b.WriteByte('\n')
+		}
+		b.Write(data)
+		b.WriteByte('\n')
+		return os.WriteFile(path, []byte(b.String()), 0600)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// executeHistory prints the interactive history, numbered oldest first, or
+// with -s the scripts run in the project. A pattern keeps the entries
+// containing it and -n count the last count of them.
+func (e *Executor) executeHistory(args []string, stdout io.Writer) error {
+	scripts := false
+	count := -1
+	var pattern string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-s":
+			scripts = true
+		case arg == "-n":
+			if i+1 == len(args) {
+				return fmt.Errorf("history: -n: missing count")
+			}
+			i++
+			n, err := strconv.Atoi(args[i])
+			if err != nil || n < 0 {
+				return fmt.Errorf("history: -n: invalid count %q", args[i])
+			}
+			count = n
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			return fmt.Errorf("history: %s: invalid option", arg)
+		case pattern != "":
+			return fmt.Errorf("history: too many arguments")
+		default:
+			pattern = arg
+		}
+	}
+
+	var entries []string
+	if scripts {
+		if e.scriptLog == "" {
+			return fmt.Errorf("history: -s: no %s directory; create one to record scripts", ProjectDir)
+		}
+		runs, err := loadScriptRuns(e.scriptLog)
+		if err != nil {
+			return fmt.Errorf("history: %w", err)
+		}
+		for i, run := range runs {
+			if !strings.Contains(run.Script, pattern) {
+				continue
+			}
+			script := strings.TrimRight(run.Script, "\n")
+			entries = append(entries, fmt.Sprintf("#%d %s status %d\n  %s\n",
+				i+1, run.Time.Format("2006-01-02 15:04:05"), run.Status, strings.ReplaceAll(script, "\n", "\n  ")))
+		}
+	} else if e.history != nil {
+		for i := 0; i < e.history.Len(); i++ {
+			if line := e.history.At(i); strings.Contains(line, pattern) {
+				entries = append(entries, fmt.Sprintf("%5d  %s\n", i+1, line))
+			}
+		}
+	}
+
+	if count >= 0 && count < len(entries) {
+		entries = entries[len(entries)-count:]
+	}
+	_, err := io.WriteString(stdout, strings.Join(entries, ""))
+	return err
+}
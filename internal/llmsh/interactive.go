@@ -0,0 +1,135 @@
+package llmsh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mako10k/llmcmd/internal/llmsh/readline"
+)
+
+// historyFile is the interactive history file in the home directory
+const historyFile = ".llmsh_history"
+
+// maxHistory is the number of history entries kept
+const maxHistory = 1000
+
+// prompt is the interactive prompt
+const prompt = "llmsh$ "
+
+// Interactive reads and runs commands from the terminal until exit or EOF,
+// with line editing, tab completion of command and file names, and history
+// kept in the project directory, or in ~/.llmsh_history without one
+func (s *Shell) Interactive() error {
+	historyPath := ""
+	if s.config.HistoryDir != "" {
+		historyPath = filepath.Join(s.config.HistoryDir, projectHistoryFile)
+	} else if home, err := os.UserHomeDir(); err == nil {
+		historyPath = filepath.Join(home, historyFile)
+	}
+	return s.interact(os.Stdin, os.Stdout, historyPath)
+}
+
+// interact runs the interactive loop; an empty historyPath keeps the history
+// in memory only
+func (s *Shell) interact(in io.Reader, out io.Writer, historyPath string) error {
+	history := readline.NewHistory(maxHistory)
+	if historyPath != "" {
+		loaded, err := readline.LoadHistory(historyPath, maxHistory)
+		if err != nil {
+			s.executor.reportError(fmt.Errorf("history: %w", err))
+		}
+		history = loaded
+	}
+
+	s.executor.history = history
+	editor := readline.New(in, out)
+	editor.History = history
+	editor.Complete = s.complete
+
+	// Background jobs run in-process, so leaving waits for them
+	defer s.reportTimings()
+	defer s.executor.waitJobs()
+	historyFailed := false
+	for {
+		line, err := editor.ReadLine(prompt)
+		switch {
+		case errors.Is(err, readline.ErrInterrupted):
+			continue
+		case err == io.EOF:
+			return nil
+		case err != nil:
+			return err
+		}
+
+		if err := history.Add(line); err != nil && !historyFailed {
+			// Report a history file problem once, then keep going without it
+			historyFailed = true
+			s.executor.reportError(fmt.Errorf("history: %w", err))
+		}
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == "exit" {
+			return nil
+		}
+
+		// Unlike a script, the prompt comes back while background jobs run
+		ast, err := s.parse(line)
+		if err == nil {
+			err = s.executor.Execute(ast)
+		}
+		s.executor.reportError(err)
+	}
+}
+
+// complete completes the word before the cursor: command names in command
+// position, file names elsewhere
+func (s *Shell) complete(head string) (int, []string) {
+	start := strings.LastIndexAny(head, " \t|;&<>") + 1
+	word := head[start:]
+	before := strings.TrimRight(head[:start], " \t")
+
+	var names []string
+	if before == "" || strings.HasSuffix(before, "|") || strings.HasSuffix(before, ";") || strings.HasSuffix(before, "&") {
+		names = append(s.executor.commands.CommandNames(), jobBuiltins...)
+		names = append(names, "set", "alias", "unalias", "return", "time", "times", "history", "vlock", "vunlock", "exit")
+		for name := range s.executor.functions {
+			names = append(names, name)
+		}
+		for name := range s.executor.aliases {
+			names = append(names, name)
+		}
+	} else {
+		// Glob characters in what was typed so far match themselves
+		matches, err := s.vfs.Glob(escapeGlob(word) + "*")
+		if err != nil {
+			return 0, nil
+		}
+		names = matches
+	}
+
+	seen := make(map[string]bool)
+	var candidates []string
+	for _, name := range names {
+		if strings.HasPrefix(name, word) && !seen[name] {
+			seen[name] = true
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+	return start, candidates
+}
+
+// escapeGlob escapes the glob characters in s
+func escapeGlob(s string) string {
+	var result strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[\`, c) {
+			result.WriteByte('\\')
+		}
+		result.WriteRune(c)
+	}
+	return result.String()
+}
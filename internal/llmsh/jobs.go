@@ -0,0 +1,192 @@
+package llmsh
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mako10k/llmcmd/internal/llmsh/parser"
+)
+
+// job is a command list started with &. Jobs run in-process as subshells, so
+// their assignments do not reach the shell that started them.
+type job struct {
+	id      int
+	command string
+	done    chan struct{} // Closed when the job has finished
+	status  int           // Exit status, valid once done is closed
+}
+
+// jobTable tracks the background jobs of a shell
+type jobTable struct {
+	mu     sync.Mutex
+	jobs   map[int]*job
+	nextID int
+}
+
+// exitError is a bare exit status, as returned by wait and fg
+type exitError int
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// ExitCode returns the exit status
+func (e exitError) ExitCode() int {
+	return int(e)
+}
+
+// executeBackground starts a job without waiting for it. Its stdin is empty,
+// as for background commands of a non-interactive sh.
+func (e *Executor) executeBackground(bg *parser.BackgroundNode) error {
+	sub := e.subshell()
+	sub.stdin = NewVirtualFile("/dev/null")
+
+	e.jobs.mu.Lock()
+	e.jobs.nextID++
+	j := &job{id: e.jobs.nextID, command: bg.Command.String(), done: make(chan struct{})}
+	e.jobs.jobs[j.id] = j
+	e.jobs.mu.Unlock()
+
+	go func() {
+		defer close(j.done)
+		err := sub.Execute(bg.Command)
+		sub.waitJobs()
+		sub.releaseLocks()
+		sub.reportError(err)
+		j.status = exitStatus(err)
+	}()
+	return nil
+}
+
+// waitJobs waits for all background jobs and forgets them
+func (e *Executor) waitJobs() {
+	for _, j := range e.listJobs() {
+		<-j.done
+		e.removeJob(j)
+	}
+}
+
+// listJobs returns the jobs in the order they were started
+func (e *Executor) listJobs() []*job {
+	e.jobs.mu.Lock()
+	defer e.jobs.mu.Unlock()
+	jobs := make([]*job, 0, len(e.jobs.jobs))
+	for _, j := range e.jobs.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].id < jobs[b].id })
+	return jobs
+}
+
+// findJob resolves a job spec: %N, N, or "" for the most recent job
+func (e *Executor) findJob(spec string) (*job, error) {
+	if spec == "" {
+		jobs := e.listJobs()
+		if len(jobs) == 0 {
+			return nil, fmt.Errorf("no current job")
+		}
+		return jobs[len(jobs)-1], nil
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(spec, "%"))
+	if err == nil {
+		e.jobs.mu.Lock()
+		j, exists := e.jobs.jobs[id]
+		e.jobs.mu.Unlock()
+		if exists {
+			return j, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: no such job", spec)
+}
+
+// removeJob forgets a finished job
+func (e *Executor) removeJob(j *job) {
+	e.jobs.mu.Lock()
+	defer e.jobs.mu.Unlock()
+	delete(e.jobs.jobs, j.id)
+}
+
+// jobBuiltins are the job control builtins, run by the executor itself
+var jobBuiltins = []string{"jobs", "wait", "fg"}
+
+// isJobBuiltin reports whether name is a job control builtin
+func isJobBuiltin(name string) bool {
+	for _, builtin := range jobBuiltins {
+		if name == builtin {
+			return true
+		}
+	}
+	return false
+}
+
+// executeJobBuiltin runs jobs, wait or fg against the shell's job table
+func (e *Executor) executeJobBuiltin(name string, args []string, stdout io.Writer) error {
+	switch name {
+	case "jobs":
+		// Finished jobs are listed once, then forgotten
+		for _, j := range e.listJobs() {
+			state := "Running"
+			select {
+			case <-j.done:
+				state = "Done"
+				if j.status != 0 {
+					state = fmt.Sprintf("Exit %d", j.status)
+				}
+				e.removeJob(j)
+			default:
+			}
+			if _, err := fmt.Fprintf(stdout, "[%d] %-8s %s &\n", j.id, state, j.command); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "wait":
+		if len(args) == 0 {
+			e.waitJobs()
+			return nil
+		}
+		// Like sh, the status is that of the last job waited for
+		var status int
+		for _, spec := range args {
+			j, err := e.findJob(spec)
+			if err != nil {
+				return fmt.Errorf("wait: %w", err)
+			}
+			<-j.done
+			e.removeJob(j)
+			status = j.status
+		}
+		if status != 0 {
+			return exitError(status)
+		}
+		return nil
+
+	default: // fg
+		if len(args) > 1 {
+			return fmt.Errorf("fg: too many arguments")
+		}
+		spec := ""
+		if len(args) == 1 {
+			spec = args[0]
+		}
+		j, err := e.findJob(spec)
+		if err != nil {
+			return fmt.Errorf("fg: %w", err)
+		}
+		if _, err := fmt.Fprintln(stdout, j.command); err != nil {
+			return err
+		}
+		<-j.done
+		e.removeJob(j)
+		if j.status != 0 {
+			return exitError(j.status)
+		}
+		return nil
+	}
+}
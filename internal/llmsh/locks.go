@@ -0,0 +1,131 @@
+package llmsh
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Waiting for a lock polls, backing off between these delays, so a shell
+// waiting on its parent's lock does not hold the connection to it
+const (
+	lockPollMin = 5 * time.Millisecond
+	lockPollMax = 100 * time.Millisecond
+)
+
+// lockOwners numbers the shells of this process that have taken locks
+var lockOwners atomic.Int64
+
+// isLockBuiltin reports whether name is vlock or vunlock, which run in the
+// executor because locks belong to the shell that took them
+func isLockBuiltin(name string) bool {
+	return name == "vlock" || name == "vunlock"
+}
+
+// executeLockBuiltin runs vlock or vunlock
+func (e *Executor) executeLockBuiltin(name string, args []string) error {
+	if name == "vunlock" {
+		return e.executeVunlock(args)
+	}
+	return e.executeVlock(args)
+}
+
+// executeVlock runs vlock [-s] [-n | -w SECONDS] FILE, taking an advisory
+// lock on FILE like flock(1): exclusive by default, shared with -s. Without
+// -n or -w it waits for as long as other shells hold a conflicting lock.
+func (e *Executor) executeVlock(args []string) error {
+	exclusive := true
+	wait := time.Duration(-1)
+options:
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "-" {
+		opt := args[0]
+		args = args[1:]
+		switch opt {
+		case "-x":
+			exclusive = true
+		case "-s":
+			exclusive = false
+		case "-n":
+			wait = 0
+		case "-w":
+			if len(args) == 0 {
+				return fmt.Errorf("vlock: -w: missing number of seconds")
+			}
+			seconds, err := strconv.ParseFloat(args[0], 64)
+			if err != nil || seconds < 0 {
+				return fmt.Errorf("vlock: -w: invalid number of seconds %q", args[0])
+			}
+			wait = time.Duration(seconds * float64(time.Second))
+			args = args[1:]
+		case "--":
+			break options
+		default:
+			return fmt.Errorf("vlock: unknown option %s", opt)
+		}
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: vlock [-s] [-n | -w SECONDS] FILE")
+	}
+	name := args[0]
+
+	if e.lockOwner == "" {
+		e.lockOwner = fmt.Sprintf("%d.%d", os.Getpid(), lockOwners.Add(1))
+	}
+	deadline := now().Add(wait)
+	delay := lockPollMin
+	for {
+		ok, err := e.vfs.TryLock(name, e.lockOwner, exclusive)
+		if err != nil {
+			return fmt.Errorf("vlock: %s: %w", name, err)
+		}
+		if ok {
+			if e.locked == nil {
+				e.locked = make(map[string]bool)
+			}
+			e.locked[name] = true
+			return nil
+		}
+		left := deadline.Sub(now())
+		if wait >= 0 && left <= 0 {
+			return fmt.Errorf("vlock: %s: locked by another shell", name)
+		}
+		if wait >= 0 && left < delay {
+			delay = left
+		}
+		if err := e.stopped(); err != nil {
+			return fmt.Errorf("vlock: %s: %w", name, err)
+		}
+		time.Sleep(delay)
+		delay = min(delay*2, lockPollMax)
+	}
+}
+
+// executeVunlock runs vunlock FILE..., releasing this shell's locks on the
+// files; files it has not locked are ignored
+func (e *Executor) executeVunlock(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: vunlock FILE...")
+	}
+	for _, name := range args {
+		if e.lockOwner == "" {
+			break
+		}
+		if err := e.vfs.Unlock(name, e.lockOwner); err != nil {
+			return fmt.Errorf("vunlock: %s: %w", name, err)
+		}
+		delete(e.locked, name)
+	}
+	return nil
+}
+
+// releaseLocks releases the locks a subshell still holds when it ends, so a
+// background job that forgets vunlock does not block the others forever
+func (e *Executor) releaseLocks() {
+	for name := range e.locked {
+		e.vfs.Unlock(name, e.lockOwner)
+	}
+	e.locked = nil
+}
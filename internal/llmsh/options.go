@@ -0,0 +1,120 @@
+package llmsh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// options are the shell options changed with set
+type options struct {
+	errexit  bool // -e: stop the script at the first failing statement
+	pipefail bool // A pipeline fails with its rightmost failing command
+	timing   bool // Record the run time of each command for the session summary
+}
+
+// testedError is the failure of a command whose status was tested: the left
+// side of a && that skipped its right side. Like sh, errexit ignores it.
+type testedError struct {
+	err error
+}
+
+func (e testedError) Error() string {
+	return e.err.Error()
+}
+
+func (e testedError) Unwrap() error {
+	return e.err
+}
+
+// stopsScript reports whether a statement failing with err ends the script,
+// or the function that return was called in
+func (e *Executor) stopsScript(err error) bool {
+	var ret returnStatus
+	if errors.As(err, &ret) {
+		return true
+	}
+	var tested testedError
+	return err != nil && e.options.errexit && !errors.As(err, &tested)
+}
+
+// executeSet changes options (set -e, set -o pipefail and their + forms),
+// prints them (set -o) or prints the variables (set)
+func (e *Executor) executeSet(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		names := make([]string, 0, len(e.vars))
+		for name := range e.vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, err := fmt.Fprintf(stdout, "%s=%s\n", name, e.vars[name]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if len(arg) < 2 || (arg[0] != '-' && arg[0] != '+') {
+			return fmt.Errorf("set: %s: positional parameters are not supported", arg)
+		}
+		enable := arg[0] == '-'
+		for _, flag := range arg[1:] {
+			switch flag {
+			case 'e':
+				e.options.errexit = enable
+			case 'o':
+				if i+1 == len(args) {
+					return e.printOptions(stdout)
+				}
+				i++
+				if err := e.setOption(args[i], enable); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("set: %c%c: invalid option", arg[0], flag)
+			}
+		}
+	}
+	return nil
+}
+
+// setOption changes an option given by its long name
+func (e *Executor) setOption(name string, enable bool) error {
+	switch name {
+	case "errexit":
+		e.options.errexit = enable
+	case "pipefail":
+		e.options.pipefail = enable
+	case "timing":
+		e.options.timing = enable
+	default:
+		return fmt.Errorf("set: %s: invalid option name", name)
+	}
+	return nil
+}
+
+// printOptions prints the options and their state, as set -o does
+func (e *Executor) printOptions(stdout io.Writer) error {
+	var b strings.Builder
+	for _, option := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"errexit", e.options.errexit},
+		{"pipefail", e.options.pipefail},
+		{"timing", e.options.timing},
+	} {
+		state := "off"
+		if option.enabled {
+			state = "on"
+		}
+		fmt.Fprintf(&b, "%-15s %s\n", option.name, state)
+	}
+	_, err := io.WriteString(stdout, b.String())
+	return err
+}
@@ -9,26 +9,29 @@ type Node interface {
 	String() string
 }
 
-// CommandNode represents a single command with arguments
+// CommandNode represents a single command with arguments and redirections
 type CommandNode struct {
-	Name string   // Command name
-	Args []string // Arguments
+	Name         string             // Command name
+	Args         []string           // Arguments
+	Redirections []*RedirectionNode // Applied in order over the command's streams
+	Line         int                // Line of the command in the script, from 1
 }
 
 func (c *CommandNode) String() string {
-	if len(c.Args) == 0 {
-		return c.Name
-	}
 	result := c.Name
 	for _, arg := range c.Args {
 		result += " " + arg
 	}
+	for _, redir := range c.Redirections {
+		result += " " + redir.String()
+	}
 	return result
 }
 
 // PipelineNode represents a series of commands connected by pipes
 type PipelineNode struct {
 	Commands []*CommandNode
+	Timed    bool // Prefixed with time
 }
 
 func (p *PipelineNode) String() string {
@@ -36,6 +39,9 @@ func (p *PipelineNode) String() string {
 		return ""
 	}
 	result := p.Commands[0].String()
+	if p.Timed {
+		result = "time " + result
+	}
 	for i := 1; i < len(p.Commands); i++ {
 		result += " | " + p.Commands[i].String()
 	}
@@ -46,17 +52,18 @@ func (p *PipelineNode) String() string {
 type RedirectionType int
 
 const (
-	RedirOut    RedirectionType = iota // >
-	RedirAppend                        // >>
-	RedirIn                            // <
-	RedirErr                           // 2>
-	RedirAll                           // &>
+	RedirOut      RedirectionType = iota // >
+	RedirAppend                          // >>
+	RedirIn                              // <
+	RedirErr                             // 2>
+	RedirAll                             // &>
+	RedirErrToOut                        // 2>&1
 )
 
 // RedirectionNode represents input/output redirection
 type RedirectionNode struct {
 	Type   RedirectionType
-	Target string // File name or fd
+	Target string // File name, empty for 2>&1
 }
 
 func (r *RedirectionNode) String() string {
@@ -71,25 +78,13 @@ func (r *RedirectionNode) String() string {
 		return "2> " + r.Target
 	case RedirAll:
 		return "&> " + r.Target
+	case RedirErrToOut:
+		return "2>&1"
 	default:
 		return "unknown redirection"
 	}
 }
 
-// ComplexCommandNode represents a command with redirections
-type ComplexCommandNode struct {
-	Pipeline     *PipelineNode
-	Redirections []*RedirectionNode
-}
-
-func (c *ComplexCommandNode) String() string {
-	result := c.Pipeline.String()
-	for _, redir := range c.Redirections {
-		result += " " + redir.String()
-	}
-	return result
-}
-
 // ConditionalNode represents conditional execution (&& or ||)
 type ConditionalNode struct {
 	Left     Node
@@ -101,6 +96,29 @@ func (c *ConditionalNode) String() string {
 	return c.Left.String() + " " + c.Operator + " " + c.Right.String()
 }
 
+// BackgroundNode represents a command list run as a background job (&)
+type BackgroundNode struct {
+	Command Node
+}
+
+func (b *BackgroundNode) String() string {
+	return b.Command.String() + " &"
+}
+
+// FunctionNode represents a function definition: name() { body; }
+type FunctionNode struct {
+	Name string
+	Body []Node // Statements of the body
+}
+
+func (f *FunctionNode) String() string {
+	result := f.Name + "() {"
+	for _, stmt := range f.Body {
+		result += " " + stmt.String() + ";"
+	}
+	return result + " }"
+}
+
 // SequenceNode represents sequential execution (;)
 type SequenceNode struct {
 	Commands []Node
@@ -120,6 +138,7 @@ func (s *SequenceNode) String() string {
 // ScriptNode represents the top-level script (multiple statements)
 type ScriptNode struct {
 	Statements []Node
+	Sources    []string // Source text of each statement, when parsed with ParseScript
 }
 
 func (s *ScriptNode) String() string {
@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// SyntaxError is a parse error, located by line and column, both counted
+// from 1; columns count characters, not bytes
+type SyntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// ExitCode returns 2, the status of a script with a syntax error as in sh
+func (e *SyntaxError) ExitCode() int {
+	return 2
+}
+
+// newSyntaxError creates a syntax error at position, a byte offset in input
+func newSyntaxError(input string, position int, format string, args ...interface{}) *SyntaxError {
+	if position > len(input) {
+		position = len(input)
+	}
+	before := input[:position]
+	lineStart := strings.LastIndexByte(before, '\n') + 1
+	return &SyntaxError{
+		Line:    strings.Count(before, "\n") + 1,
+		Column:  utf8.RuneCountInString(before[lineStart:]) + 1,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// tokenNames describe token types in syntax errors
+var tokenNames = map[TokenType]string{
+	WORD:             "word",
+	PIPE:             "'|'",
+	REDIRECT_OUT:     "'>'",
+	REDIRECT_APPEND:  "'>>'",
+	REDIRECT_IN:      "'<'",
+	REDIRECT_ERR:     "'2>'",
+	REDIRECT_ALL:     "'&>'",
+	REDIRECT_ERR_OUT: "'2>&1'",
+	AND:              "'&&'",
+	OR:               "'||'",
+	SEMICOLON:        "';'",
+	NEWLINE:          "newline",
+	EOF:              "end of input",
+	QUOTED_STRING:    "quoted string",
+	BACKGROUND:       "'&'",
+	LPAREN:           "'('",
+	RPAREN:           "')'",
+}
+
+func (t TokenType) String() string {
+	if name, exists := tokenNames[t]; exists {
+		return name
+	}
+	return fmt.Sprintf("token %d", int(t))
+}
+
+// describe names a token in syntax errors: words are quoted, operators and
+// the end of input are named
+func describe(token Token) string {
+	if token.Type == WORD || token.Type == QUOTED_STRING {
+		return "'" + token.Value + "'"
+	}
+	return token.Type.String()
+}
@@ -1,14 +1,19 @@
 package parser
 
 import (
-	"fmt"
+	"regexp"
+	"strings"
 )
 
+// functionNamePattern matches the names functions can be defined with
+var functionNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 // Parser parses shell syntax into an AST
 type Parser struct {
 	tokenizer *Tokenizer
 	current   Token
 	position  int
+	end       int // Byte offset just after the last token consumed
 }
 
 // NewParser creates a new parser
@@ -18,22 +23,56 @@ func NewParser() *Parser {
 
 // Parse parses the input string and returns an AST
 func (p *Parser) Parse(input string) (Node, error) {
-	p.tokenizer = NewTokenizer(input)
+	if err := p.start(input); err != nil {
+		return nil, err
+	}
 
-	// Get first token
-	token, err := p.tokenizer.NextToken()
+	// Parse the script
+	return p.parseScript()
+}
+
+// ParseScript parses input like Parse, but always returns a script, which
+// also has the source text of each statement
+func (p *Parser) ParseScript(input string) (*ScriptNode, error) {
+	if err := p.start(input); err != nil {
+		return nil, err
+	}
+	statements, sources, err := p.parseStatements(false)
 	if err != nil {
 		return nil, err
 	}
+	if p.current.Type != EOF {
+		return nil, p.errorAt(p.current.Position, "unexpected %s", describe(p.current))
+	}
+	return &ScriptNode{Statements: statements, Sources: sources}, nil
+}
+
+// start begins parsing input at its first token
+func (p *Parser) start(input string) error {
+	p.tokenizer = NewTokenizer(input)
+	token, err := p.tokenizer.NextToken()
+	if err != nil {
+		return err
+	}
 	p.current = token
 	p.position = 0
+	p.end = 0
+	return nil
+}
 
-	// Parse the script
-	return p.parseScript()
+// lineOf returns the line of a byte offset of the input, counted from 1
+func (p *Parser) lineOf(position int) int {
+	return strings.Count(p.tokenizer.input[:position], "\n") + 1
+}
+
+// errorAt creates a syntax error at a byte offset of the input
+func (p *Parser) errorAt(position int, format string, args ...interface{}) error {
+	return newSyntaxError(p.tokenizer.input, position, format, args...)
 }
 
 // advance moves to the next token
 func (p *Parser) advance() error {
+	p.end = p.tokenizer.position
 	token, err := p.tokenizer.NextToken()
 	if err != nil {
 		return err
@@ -46,55 +85,138 @@ func (p *Parser) advance() error {
 // expect checks if current token is of expected type and advances
 func (p *Parser) expect(tokenType TokenType) error {
 	if p.current.Type != tokenType {
-		return fmt.Errorf("expected %v, got %v at position %d", tokenType, p.current.Type, p.current.Position)
+		return p.errorAt(p.current.Position, "expected %v, got %s", tokenType, describe(p.current))
 	}
 	return p.advance()
 }
 
+// peek returns the token after the current one without consuming it
+func (p *Parser) peek() (Token, error) {
+	saved := *p.tokenizer
+	defer func() { *p.tokenizer = saved }()
+	return p.tokenizer.NextToken()
+}
+
+// isClosingBrace reports whether the current token is the } ending a
+// function body. Like sh, } is only special where a command would start.
+func (p *Parser) isClosingBrace() bool {
+	return p.current.Type == WORD && p.current.Value == "}"
+}
+
 // parseScript parses the top-level script
 func (p *Parser) parseScript() (Node, error) {
+	statements, _, err := p.parseStatements(false)
+	if err != nil {
+		return nil, err
+	}
+	if p.current.Type != EOF {
+		return nil, p.errorAt(p.current.Position, "unexpected %s", describe(p.current))
+	}
+
+	if len(statements) == 0 {
+		return nil, nil
+	}
+
+	if len(statements) == 1 {
+		return statements[0], nil
+	}
+
+	return &ScriptNode{Statements: statements}, nil
+}
+
+// parseStatements parses statements separated by newlines and semicolons,
+// up to the end of input or, in a function body, the closing brace. It also
+// returns the source text of each statement.
+func (p *Parser) parseStatements(inBody bool) ([]Node, []string, error) {
 	var statements []Node
+	var sources []string
 
 	// Skip leading newlines
 	for p.current.Type == NEWLINE {
 		if err := p.advance(); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
-	for p.current.Type != EOF {
+	for p.current.Type != EOF && !(inBody && p.isClosingBrace()) {
+		start := p.current.Position
 		stmt, err := p.parseStatement()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		if stmt != nil {
 			statements = append(statements, stmt)
+			sources = append(sources, strings.TrimRight(p.tokenizer.input[start:p.end], " \t\n"))
+		}
+
+		switch {
+		case p.current.Type == NEWLINE, p.current.Type == SEMICOLON, p.current.Type == EOF:
+		case inBody && p.isClosingBrace():
+		default:
+			return nil, nil, p.errorAt(p.current.Position, "unexpected %s", describe(p.current))
 		}
 
 		// Skip statement separators
 		for p.current.Type == NEWLINE || p.current.Type == SEMICOLON {
+			if err := p.advance(); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return statements, sources, nil
+}
+
+// parseStatement parses a line: conditionals separated by ; or &, where &
+// runs the conditional before it in the background
+func (p *Parser) parseStatement() (Node, error) {
+	var commands []Node
+
+	for {
+		cmd, err := p.parseConditional()
+		if err != nil {
+			return nil, err
+		}
+		if cmd == nil {
+			break
+		}
+
+		if p.current.Type == BACKGROUND {
+			cmd = &BackgroundNode{Command: cmd}
+		}
+		commands = append(commands, cmd)
+
+		if p.current.Type != SEMICOLON && p.current.Type != BACKGROUND {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		// Skip newlines after semicolon
+		for p.current.Type == NEWLINE {
 			if err := p.advance(); err != nil {
 				return nil, err
 			}
 		}
 	}
 
-	if len(statements) == 0 {
+	if len(commands) == 0 {
 		return nil, nil
 	}
 
-	if len(statements) == 1 {
-		return statements[0], nil
+	if len(commands) == 1 {
+		return commands[0], nil
 	}
 
-	return &ScriptNode{Statements: statements}, nil
+	return &SequenceNode{Commands: commands}, nil
 }
 
-// parseStatement parses a single statement (with conditionals)
-func (p *Parser) parseStatement() (Node, error) {
-	left, err := p.parseSequence()
-	if err != nil {
+// parseConditional parses pipelines joined by && and ||
+func (p *Parser) parseConditional() (Node, error) {
+	left, err := p.parsePipelineNode()
+	if err != nil || left == nil {
 		return nil, err
 	}
 
@@ -105,10 +227,13 @@ func (p *Parser) parseStatement() (Node, error) {
 			return nil, err
 		}
 
-		right, err := p.parseSequence()
+		right, err := p.parsePipelineNode()
 		if err != nil {
 			return nil, err
 		}
+		if right == nil {
+			return nil, p.errorAt(p.current.Position, "expected command after %s", operator)
+		}
 
 		left = &ConditionalNode{
 			Left:     left,
@@ -120,94 +245,91 @@ func (p *Parser) parseStatement() (Node, error) {
 	return left, nil
 }
 
-// parseSequence parses a sequence of commands separated by semicolons
-func (p *Parser) parseSequence() (Node, error) {
-	var commands []Node
+// parsePipelineNode parses a pipeline or a function definition, returning a
+// nil Node when there is none
+func (p *Parser) parsePipelineNode() (Node, error) {
+	if p.current.Type == WORD {
+		next, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if next.Type == LPAREN {
+			return p.parseFunction()
+		}
+	}
 
-	cmd, err := p.parseComplexCommand()
-	if err != nil {
+	pipeline, err := p.parsePipeline()
+	if err != nil || pipeline == nil {
 		return nil, err
 	}
+	return pipeline, nil
+}
 
-	if cmd != nil {
-		commands = append(commands, cmd)
+// parseFunction parses a function definition, name() { body; }. The body
+// must be a brace group, whose closing brace follows a ; or a newline.
+func (p *Parser) parseFunction() (Node, error) {
+	name := p.current.Value
+	position := p.current.Position
+	if !functionNamePattern.MatchString(name) {
+		return nil, p.errorAt(position, "invalid function name '%s'", name)
 	}
-
-	for p.current.Type == SEMICOLON {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(LPAREN); err != nil {
+		return nil, err
+	}
+	if err := p.expect(RPAREN); err != nil {
+		return nil, err
+	}
+	for p.current.Type == NEWLINE {
 		if err := p.advance(); err != nil {
 			return nil, err
 		}
-
-		// Skip newlines after semicolon
-		for p.current.Type == NEWLINE {
-			if err := p.advance(); err != nil {
-				return nil, err
-			}
-		}
-
-		cmd, err := p.parseComplexCommand()
-		if err != nil {
-			return nil, err
-		}
-
-		if cmd != nil {
-			commands = append(commands, cmd)
-		}
 	}
-
-	if len(commands) == 0 {
-		return nil, nil
+	if p.current.Type != WORD || p.current.Value != "{" {
+		return nil, p.errorAt(p.current.Position, "expected '{' after %s()", name)
 	}
-
-	if len(commands) == 1 {
-		return commands[0], nil
+	if err := p.advance(); err != nil {
+		return nil, err
 	}
 
-	return &SequenceNode{Commands: commands}, nil
-}
-
-// parseComplexCommand parses a pipeline with redirections
-func (p *Parser) parseComplexCommand() (Node, error) {
-	pipeline, err := p.parsePipeline()
+	body, _, err := p.parseStatements(true)
 	if err != nil {
 		return nil, err
 	}
-
-	if pipeline == nil {
-		return nil, nil
+	if !p.isClosingBrace() {
+		return nil, p.errorAt(position, "missing '}' for function %s", name)
 	}
-
-	var redirections []*RedirectionNode
-
-	// Parse redirections
-	for p.isRedirection() {
-		redir, err := p.parseRedirection()
-		if err != nil {
-			return nil, err
-		}
-		redirections = append(redirections, redir)
+	if len(body) == 0 {
+		return nil, p.errorAt(position, "empty body for function %s", name)
 	}
-
-	if len(redirections) == 0 {
-		return pipeline, nil
+	if err := p.advance(); err != nil {
+		return nil, err
 	}
-
-	return &ComplexCommandNode{
-		Pipeline:     pipeline,
-		Redirections: redirections,
-	}, nil
+	return &FunctionNode{Name: name, Body: body}, nil
 }
 
-// parsePipeline parses a pipeline of commands
+// parsePipeline parses a pipeline of commands, which time may prefix
 func (p *Parser) parsePipeline() (*PipelineNode, error) {
 	var commands []*CommandNode
 
+	timed := p.current.Type == WORD && p.current.Value == "time"
+	if timed {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
 	cmd, err := p.parseCommand()
 	if err != nil {
 		return nil, err
 	}
 
 	if cmd == nil {
+		if timed {
+			return nil, p.errorAt(p.current.Position, "expected command after time")
+		}
 		return nil, nil
 	}
 
@@ -224,45 +346,50 @@ func (p *Parser) parsePipeline() (*PipelineNode, error) {
 		}
 
 		if cmd == nil {
-			return nil, fmt.Errorf("expected command after pipe at position %d", p.current.Position)
+			return nil, p.errorAt(p.current.Position, "expected command after pipe")
 		}
 
 		commands = append(commands, cmd)
 	}
 
-	return &PipelineNode{Commands: commands}, nil
+	return &PipelineNode{Commands: commands, Timed: timed}, nil
 }
 
-// parseCommand parses a single command with arguments
+// parseCommand parses a single command with arguments and redirections,
+// which may appear between the arguments as in "grep err < log"
 func (p *Parser) parseCommand() (*CommandNode, error) {
-	if p.current.Type != WORD && p.current.Type != QUOTED_STRING {
+	if p.current.Type != WORD && p.current.Type != QUOTED_STRING || p.isClosingBrace() {
 		return nil, nil
 	}
 
-	name := p.current.Value
+	cmd := &CommandNode{Name: p.current.Value, Line: p.lineOf(p.current.Position)}
 	if err := p.advance(); err != nil {
 		return nil, err
 	}
 
-	var args []string
-
-	for p.current.Type == WORD || p.current.Type == QUOTED_STRING {
-		args = append(args, p.current.Value)
-		if err := p.advance(); err != nil {
-			return nil, err
+	for {
+		switch {
+		case p.current.Type == WORD || p.current.Type == QUOTED_STRING:
+			cmd.Args = append(cmd.Args, p.current.Value)
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		case p.isRedirection():
+			redir, err := p.parseRedirection()
+			if err != nil {
+				return nil, err
+			}
+			cmd.Redirections = append(cmd.Redirections, redir)
+		default:
+			return cmd, nil
 		}
 	}
-
-	return &CommandNode{
-		Name: name,
-		Args: args,
-	}, nil
 }
 
 // isRedirection checks if current token is a redirection operator
 func (p *Parser) isRedirection() bool {
 	switch p.current.Type {
-	case REDIRECT_OUT, REDIRECT_APPEND, REDIRECT_IN, REDIRECT_ERR, REDIRECT_ALL:
+	case REDIRECT_OUT, REDIRECT_APPEND, REDIRECT_IN, REDIRECT_ERR, REDIRECT_ALL, REDIRECT_ERR_OUT:
 		return true
 	default:
 		return false
@@ -284,8 +411,14 @@ func (p *Parser) parseRedirection() (*RedirectionNode, error) {
 		redirType = RedirErr
 	case REDIRECT_ALL:
 		redirType = RedirAll
+	case REDIRECT_ERR_OUT:
+		// 2>&1 takes no target
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		return &RedirectionNode{Type: RedirErrToOut}, nil
 	default:
-		return nil, fmt.Errorf("expected redirection operator at position %d", p.current.Position)
+		return nil, p.errorAt(p.current.Position, "expected redirection operator")
 	}
 
 	if err := p.advance(); err != nil {
@@ -293,7 +426,7 @@ func (p *Parser) parseRedirection() (*RedirectionNode, error) {
 	}
 
 	if p.current.Type != WORD && p.current.Type != QUOTED_STRING {
-		return nil, fmt.Errorf("expected filename after redirection at position %d", p.current.Position)
+		return nil, p.errorAt(p.current.Position, "expected filename after redirection")
 	}
 
 	target := p.current.Value
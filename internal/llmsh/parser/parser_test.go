@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -29,6 +30,14 @@ func TestTokenizer(t *testing.T) {
 			input:    "cat file1; cat file2",
 			expected: []TokenType{WORD, WORD, SEMICOLON, WORD, WORD, EOF},
 		},
+		{
+			input:    "COUNT=$(wc -l < file | tr -d ' ') && echo $?",
+			expected: []TokenType{WORD, AND, WORD, WORD, EOF},
+		},
+		{
+			input:    "X=\"a b\"c; f() { echo; }",
+			expected: []TokenType{WORD, SEMICOLON, WORD, LPAREN, RPAREN, WORD, WORD, SEMICOLON, WORD, EOF},
+		},
 	}
 
 	for _, test := range tests {
@@ -81,6 +90,26 @@ func TestParser(t *testing.T) {
 			input:       "echo >", // Invalid: redirection without target
 			expectError: true,
 		},
+		{
+			input:       "grep err < log | sort | uniq -c > out.txt",
+			expectError: false,
+		},
+		{
+			input:       "| cat", // Invalid: pipe without left side
+			expectError: true,
+		},
+		{
+			input:       "sort big.txt > sorted.txt & wc -l < big.txt; wait",
+			expectError: false,
+		},
+		{
+			input:       "cat & && echo", // Invalid: & ends the command list
+			expectError: true,
+		},
+		{
+			input:       "true &&", // Invalid: && without right side
+			expectError: true,
+		},
 	}
 
 	parser := NewParser()
@@ -98,6 +127,113 @@ func TestParser(t *testing.T) {
 	}
 }
 
+func TestRedirectionsAttachToCommands(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string // Redirections of each pipeline command
+	}{
+		{input: "grep err < log | sort | uniq -c > out.txt", expected: []string{"< log", "", "> out.txt"}},
+		{input: "cat 2>&1 > out.txt | wc -l", expected: []string{"2>&1 > out.txt", ""}},
+		{input: "sort < in.txt 2> err.txt >> all.txt", expected: []string{"< in.txt 2> err.txt >> all.txt"}},
+	}
+
+	for _, test := range tests {
+		node, err := NewParser().Parse(test.input)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", test.input, err)
+		}
+		pipeline, ok := node.(*PipelineNode)
+		if !ok || len(pipeline.Commands) != len(test.expected) {
+			t.Fatalf("Parse(%q) = %#v, want a pipeline of %d commands", test.input, node, len(test.expected))
+		}
+		for i, cmd := range pipeline.Commands {
+			var redirs []string
+			for _, redir := range cmd.Redirections {
+				redirs = append(redirs, redir.String())
+			}
+			if got := strings.Join(redirs, " "); got != test.expected[i] {
+				t.Errorf("Parse(%q) command %d redirections = %q, want %q", test.input, i, got, test.expected[i])
+			}
+		}
+	}
+}
+
+func TestBackgroundBindsLooserThanConditionals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "a; b && c & d", expected: "a; b && c &; d"},
+		{input: "a | b &", expected: "a | b &"},
+		{input: "a &\nb", expected: "a &; b"},
+	}
+
+	for _, test := range tests {
+		node, err := NewParser().Parse(test.input)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", test.input, err)
+		}
+		if got := node.String(); got != test.expected {
+			t.Errorf("Parse(%q) = %q, want %q", test.input, got, test.expected)
+		}
+	}
+	node, _ := NewParser().Parse("a && b &")
+	if bg, ok := node.(*BackgroundNode); !ok || bg.Command.String() != "a && b" {
+		t.Errorf("Parse(\"a && b &\") = %#v, want the whole conditional in the background", node)
+	}
+}
+
+func TestFunctionDefinitions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "f() { echo a; }", expected: "f() { echo a; }"},
+		{input: "f () {\n  grep x | sort\n  echo done\n}\nf", expected: "f() { grep x | sort; echo done; }\nf"},
+		{input: "f(){ sleep 1 & }; echo } {", expected: "f() { sleep 1 &; }; echo } {"},
+		{input: "outer() { inner() { true; }; inner; }", expected: "outer() { inner() { true; }; inner; }"},
+	}
+
+	for _, test := range tests {
+		node, err := NewParser().Parse(test.input)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", test.input, err)
+			continue
+		}
+		if got := node.String(); got != test.expected {
+			t.Errorf("Parse(%q) = %q, want %q", test.input, got, test.expected)
+		}
+	}
+
+	for _, input := range []string{"f() { echo a }", "f() { }", "f() echo", "1f() { true; }", "echo (a)", "}"} {
+		if _, err := NewParser().Parse(input); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", input)
+		}
+	}
+}
+
+func TestTimedPipelines(t *testing.T) {
+	node, err := NewParser().Parse("time grep x | sort && time echo time")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	cond, ok := node.(*ConditionalNode)
+	if !ok {
+		t.Fatalf("Parse = %#v, want a conditional", node)
+	}
+	left, right := cond.Left.(*PipelineNode), cond.Right.(*PipelineNode)
+	if !left.Timed || len(left.Commands) != 2 || left.Commands[0].Name != "grep" {
+		t.Errorf("left = %q, want the whole timed pipeline", left)
+	}
+	if !right.Timed || right.String() != "time echo time" {
+		t.Errorf("right = %q, want time echo time", right)
+	}
+
+	if _, err := NewParser().Parse("time"); err == nil {
+		t.Error("Parse(\"time\") succeeded, want an error")
+	}
+}
+
 func TestQuotedStrings(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -108,6 +244,9 @@ func TestQuotedStrings(t *testing.T) {
 		{`"escaped \"quote\""`, `escaped "quote"`},
 		{`"newline\nhere"`, "newline\nhere"},
 		{`"tab\there"`, "tab\there"},
+		{`"*.log [a] $? x?"`, `\*.log \[a] $? x\?`},
+		{`'$? *'`, `\$\? \*`},
+		{`"a b"'c d'e`, "a bc de"},
 	}
 
 	for _, test := range tests {
@@ -170,3 +309,47 @@ func TestComments(t *testing.T) {
 		}
 	}
 }
+
+func TestSyntaxErrorPositions(t *testing.T) {
+	tests := []struct {
+		input        string
+		line, column int
+		message      string
+	}{
+		{"echo a\necho 'x", 2, 6, "unterminated quoted string"},
+		{"echo a |", 1, 9, "expected command after pipe"},
+		{"echo a\n  | b", 2, 3, "unexpected '|'"},
+		{"é x | | y", 1, 7, "expected command after pipe"},
+		{"f() { echo", 1, 1, "missing '}' for function f"},
+	}
+
+	for _, test := range tests {
+		_, err := NewParser().Parse(test.input)
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("Parse(%q) error = %v, want a *SyntaxError", test.input, err)
+			continue
+		}
+		if syntaxErr.Line != test.line || syntaxErr.Column != test.column || syntaxErr.Message != test.message {
+			t.Errorf("Parse(%q) error = %d:%d %q, want %d:%d %q", test.input,
+				syntaxErr.Line, syntaxErr.Column, syntaxErr.Message, test.line, test.column, test.message)
+		}
+	}
+}
+
+func TestParseScriptKeepsSources(t *testing.T) {
+	input := "# setup\nf() { echo \"a  b\"; }   # define\ngrep 'x y' < in | sort; echo $(date) &\n"
+	script, err := NewParser().ParseScript(input)
+	if err != nil {
+		t.Fatalf("ParseScript failed: %v", err)
+	}
+	want := []string{`f() { echo "a  b"; }`, `grep 'x y' < in | sort; echo $(date) &`}
+	if strings.Join(script.Sources, "|") != strings.Join(want, "|") || len(script.Statements) != len(want) {
+		t.Errorf("sources = %q, want %q", script.Sources, want)
+	}
+
+	script, err = NewParser().ParseScript("  # nothing\n")
+	if err != nil || len(script.Statements) != 0 {
+		t.Errorf("ParseScript of a comment = %v, %v", script, err)
+	}
+}
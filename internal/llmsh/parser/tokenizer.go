@@ -1,7 +1,6 @@
 package parser
 
 import (
-	"fmt"
 	"strings"
 	"unicode"
 )
@@ -11,22 +10,25 @@ type TokenType int
 
 const (
 	// Basic tokens
-	WORD            TokenType = iota
-	PIPE                      // |
-	REDIRECT_OUT              // >
-	REDIRECT_APPEND           // >>
-	REDIRECT_IN               // <
-	REDIRECT_ERR              // 2>
-	REDIRECT_ALL              // &>
-	AND                       // &&
-	OR                        // ||
-	SEMICOLON                 // ;
-	NEWLINE                   // \n
+	WORD             TokenType = iota
+	PIPE                       // |
+	REDIRECT_OUT               // >
+	REDIRECT_APPEND            // >>
+	REDIRECT_IN                // <
+	REDIRECT_ERR               // 2>
+	REDIRECT_ALL               // &>
+	REDIRECT_ERR_OUT           // 2>&1
+	AND                        // &&
+	OR                         // ||
+	SEMICOLON                  // ;
+	NEWLINE                    // \n
 	EOF
 
 	// Special tokens
 	QUOTED_STRING // "string" or 'string'
-	BACKGROUND    // & (not implemented for security)
+	BACKGROUND    // &
+	LPAREN        // (
+	RPAREN        // )
 )
 
 // Token represents a single token
@@ -68,6 +70,11 @@ func NewTokenizer(input string) *Tokenizer {
 	return t
 }
 
+// errorAt creates a syntax error at a byte offset of the input
+func (t *Tokenizer) errorAt(position int, format string, args ...interface{}) error {
+	return newSyntaxError(t.input, position, format, args...)
+}
+
 // advance moves to the next character
 func (t *Tokenizer) advance() {
 	t.position++
@@ -100,26 +107,78 @@ func (t *Tokenizer) skipComment() {
 	}
 }
 
-// readWord reads a word token
-func (t *Tokenizer) readWord() string {
-	start := t.position
+// readWord reads a word token. Quoted parts are joined with the rest of the
+// word, as in X="a b". A command substitution $(...) is kept whole, including
+// any spaces and operators inside it; the executor expands it.
+func (t *Tokenizer) readWord() (string, error) {
+	var result strings.Builder
 	for t.current != 0 && !t.isSpecialChar() && !unicode.IsSpace(t.current) {
-		t.advance()
+		switch {
+		case t.current == '"' || t.current == '\'':
+			part, err := t.readQuotedString(t.current)
+			if err != nil {
+				return "", err
+			}
+			result.WriteString(part)
+		case t.current == '$' && t.peek() == '(':
+			end := MatchParen(t.input, t.position+1)
+			if end < 0 {
+				return "", t.errorAt(t.position, "unterminated command substitution")
+			}
+			result.WriteString(t.input[t.position : end+1])
+			for t.position <= end {
+				t.advance()
+			}
+		default:
+			result.WriteRune(t.current)
+			t.advance()
+		}
 	}
-	return t.input[start:t.position]
+	return result.String(), nil
 }
 
-// readQuotedString reads a quoted string
+// MatchParen returns the index of the parenthesis closing the one at open,
+// skipping quoted text, or -1 when it is not closed
+func MatchParen(s string, open int) int {
+	depth := 0
+	var quote byte
+	for i := open; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// readQuotedString reads a quoted string. Within single quotes every character
+// is literal, backslashes included; within double quotes a backslash escapes
+// the next character. Dollar signs that must stay literal, escaped or within
+// single quotes, are kept as \$ for the executor's expansion, and glob
+// characters as \*, \? and \[ so they are not matched against files.
 func (t *Tokenizer) readQuotedString(quote rune) (string, error) {
 	start := t.position
 	t.advance() // skip opening quote
 
 	var result strings.Builder
 	for t.current != 0 && t.current != quote {
-		if t.current == '\\' {
+		if t.current == '\\' && quote == '"' {
 			t.advance()
 			if t.current == 0 {
-				return "", fmt.Errorf("unterminated quoted string at position %d", start)
+				return "", t.errorAt(start, "unterminated quoted string")
 			}
 			// Handle escape sequences
 			switch t.current {
@@ -133,9 +192,13 @@ func (t *Tokenizer) readQuotedString(quote rune) (string, error) {
 				result.WriteRune('\\')
 			case '"', '\'':
 				result.WriteRune(t.current)
+			case '$', '*', '?', '[':
+				result.WriteString(`\` + string(t.current))
 			default:
 				result.WriteRune(t.current)
 			}
+		} else if t.current == '$' && quote == '\'' || isGlobChar(t.current) && !(t.current == '?' && isStatusParam(result.String())) {
+			result.WriteString(`\` + string(t.current))
 		} else {
 			result.WriteRune(t.current)
 		}
@@ -143,17 +206,27 @@ func (t *Tokenizer) readQuotedString(quote rune) (string, error) {
 	}
 
 	if t.current != quote {
-		return "", fmt.Errorf("unterminated quoted string at position %d", start)
+		return "", t.errorAt(start, "unterminated quoted string")
 	}
 	t.advance() // skip closing quote
 
 	return result.String(), nil
 }
 
+// isGlobChar reports whether c has a meaning in glob patterns
+func isGlobChar(c rune) bool {
+	return c == '*' || c == '?' || c == '['
+}
+
+// isStatusParam reports whether a ? following quoted text is part of $?
+func isStatusParam(quoted string) bool {
+	return strings.HasSuffix(quoted, "$") && !strings.HasSuffix(quoted, `\$`)
+}
+
 // isSpecialChar checks if current character is a special shell character
 func (t *Tokenizer) isSpecialChar() bool {
 	switch t.current {
-	case '|', '>', '<', '&', ';', '\n':
+	case '|', '>', '<', '&', ';', '(', ')', '\n':
 		return true
 	default:
 		return false
@@ -206,8 +279,8 @@ func (t *Tokenizer) NextToken() (Token, error) {
 				t.advance()
 				return Token{Type: REDIRECT_ALL, Value: "&>", Position: position}, nil
 			}
-			// & alone is background execution (not supported for security)
-			return Token{}, fmt.Errorf("background execution (&) not supported for security reasons at position %d", position)
+			t.advance()
+			return Token{Type: BACKGROUND, Value: "&", Position: position}, nil
 
 		case '>':
 			if t.peek() == '>' {
@@ -222,28 +295,47 @@ func (t *Tokenizer) NextToken() (Token, error) {
 			t.advance()
 			return Token{Type: REDIRECT_IN, Value: "<", Position: position}, nil
 
+		case '(':
+			t.advance()
+			return Token{Type: LPAREN, Value: "(", Position: position}, nil
+
+		case ')':
+			t.advance()
+			return Token{Type: RPAREN, Value: ")", Position: position}, nil
+
 		case '2':
+			if strings.HasPrefix(t.input[t.position:], "2>&1") {
+				for range "2>&1" {
+					t.advance()
+				}
+				return Token{Type: REDIRECT_ERR_OUT, Value: "2>&1", Position: position}, nil
+			}
 			if t.peek() == '>' {
 				t.advance()
 				t.advance()
 				return Token{Type: REDIRECT_ERR, Value: "2>", Position: position}, nil
 			}
 			// Fall through to word parsing
-			word := t.readWord()
+			word, err := t.readWord()
+			if err != nil {
+				return Token{}, err
+			}
 			return Token{Type: WORD, Value: word, Position: position}, nil
 
 		case '"', '\'':
-			quote := t.current
-			value, err := t.readQuotedString(quote)
+			value, err := t.readWord()
 			if err != nil {
 				return Token{}, err
 			}
 			return Token{Type: QUOTED_STRING, Value: value, Position: position}, nil
 
 		default:
-			word := t.readWord()
+			word, err := t.readWord()
+			if err != nil {
+				return Token{}, err
+			}
 			if word == "" {
-				return Token{}, fmt.Errorf("unexpected character '%c' at position %d", t.current, position)
+				return Token{}, t.errorAt(position, "unexpected character '%c'", t.current)
 			}
 			return Token{Type: WORD, Value: word, Position: position}, nil
 		}
@@ -0,0 +1,154 @@
+package llmsh
+
+import (
+	"io"
+	"sync"
+	"syscall"
+)
+
+// pipeCapacity is the number of bytes a pipe holds before writers block, the
+// default pipe size of Linux
+const pipeCapacity = 64 * 1024
+
+// brokenPipeStatus is the exit status of a command whose output pipe was
+// closed by its reader (128 + SIGPIPE)
+const brokenPipeStatus = 141
+
+// pipe is a bounded in-memory pipe between two commands of a pipeline. The
+// data lives in a ring buffer of pipeCapacity bytes: writes block while it is
+// full, reads block while it is empty, the reader sees EOF once the writer
+// has closed its end, and writes fail with EPIPE once the reader has closed
+// its end.
+type pipe struct {
+	mu       sync.Mutex
+	changed  *sync.Cond // Signalled when data, space or a closed end appears
+	buf      []byte
+	start    int // Index of the first unread byte
+	size     int // Number of unread bytes
+	rclosed  bool
+	wclosed  bool
+	capacity int
+}
+
+// newPipe returns the read and write ends of a new pipe
+func newPipe() (*pipeReader, *pipeWriter) {
+	p := &pipe{buf: make([]byte, pipeCapacity), capacity: pipeCapacity}
+	p.changed = sync.NewCond(&p.mu)
+	return &pipeReader{p}, &pipeWriter{p}
+}
+
+// read copies buffered data to b, waiting for some to arrive
+func (p *pipe) read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for p.size == 0 {
+		if p.rclosed {
+			return 0, syscall.EBADF
+		}
+		if p.wclosed {
+			return 0, io.EOF
+		}
+		if len(b) == 0 {
+			return 0, nil
+		}
+		p.changed.Wait()
+	}
+
+	n := 0
+	for n < len(b) && p.size > 0 {
+		end := p.start + p.size
+		if end > p.capacity {
+			end = p.capacity
+		}
+		copied := copy(b[n:], p.buf[p.start:end])
+		n += copied
+		p.start = (p.start + copied) % p.capacity
+		p.size -= copied
+	}
+	p.changed.Broadcast()
+	return n, nil
+}
+
+// write copies b into the buffer, waiting for the reader to make room
+func (p *pipe) write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := 0
+	for n < len(b) {
+		if p.wclosed {
+			return n, syscall.EBADF
+		}
+		if p.rclosed {
+			return n, syscall.EPIPE
+		}
+		if p.size == p.capacity {
+			p.changed.Wait()
+			continue
+		}
+		// Fill the free space up to the end of the buffer or the unread data
+		end := (p.start + p.size) % p.capacity
+		limit := p.capacity
+		if end < p.start {
+			limit = p.start
+		}
+		copied := copy(p.buf[end:limit], b[n:])
+		n += copied
+		p.size += copied
+		p.changed.Broadcast()
+	}
+	return n, nil
+}
+
+// closeRead closes the read end, failing pending and later writes
+func (p *pipe) closeRead() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rclosed = true
+	p.changed.Broadcast()
+}
+
+// closeWrite closes the write end; the reader gets EOF after the buffered data
+func (p *pipe) closeWrite() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.wclosed = true
+	p.changed.Broadcast()
+}
+
+// pipeReader is the read end of a pipe
+type pipeReader struct {
+	p *pipe
+}
+
+func (r *pipeReader) Read(b []byte) (int, error) {
+	return r.p.read(b)
+}
+
+func (r *pipeReader) Write(b []byte) (int, error) {
+	return 0, syscall.EBADF
+}
+
+func (r *pipeReader) Close() error {
+	r.p.closeRead()
+	return nil
+}
+
+// pipeWriter is the write end of a pipe
+type pipeWriter struct {
+	p *pipe
+}
+
+func (w *pipeWriter) Read(b []byte) (int, error) {
+	return 0, syscall.EBADF
+}
+
+func (w *pipeWriter) Write(b []byte) (int, error) {
+	return w.p.write(b)
+}
+
+func (w *pipeWriter) Close() error {
+	w.p.closeWrite()
+	return nil
+}
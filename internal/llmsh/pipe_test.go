@@ -0,0 +1,76 @@
+package llmsh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPipeBackpressure(t *testing.T) {
+	reader, writer := newPipe()
+	data := bytes.Repeat([]byte("0123456789"), pipeCapacity/5)
+
+	written := make(chan error, 1)
+	go func() {
+		_, err := writer.Write(data)
+		writer.Close()
+		written <- err
+	}()
+
+	// The writer blocks once the pipe holds pipeCapacity bytes
+	select {
+	case err := <-written:
+		t.Fatalf("Write of %d bytes returned %v without a reader", len(data), err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("read %d bytes, want the %d written", len(got), len(data))
+	}
+	if err := <-written; err != nil {
+		t.Errorf("Write() error = %v", err)
+	}
+}
+
+func TestPipeClosedReader(t *testing.T) {
+	reader, writer := newPipe()
+	if _, err := writer.Write([]byte("buffered")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	reader.Close()
+	if _, err := writer.Write([]byte("more")); !errors.Is(err, syscall.EPIPE) {
+		t.Errorf("Write() after the reader closed: err = %v, want EPIPE", err)
+	}
+}
+
+func TestRunnerPipelineStreams(t *testing.T) {
+	tests := []struct {
+		script string
+		stdout string
+	}{
+		// seq stops with EPIPE once head is done; the status is head's
+		{"seq 1 10000000 | head -2; echo $?", "1\n2\n0\n"},
+		{"set -o pipefail; seq 1 10000000 | head -1; echo $?", "1\n141\n"},
+		// More than a pipe holds passes through every stage
+		{"seq 1 200000 | cat | tail -1", "200000\n"},
+		// All but the last command run in subshells
+		{"x=1 | true; echo x=$x; true | y=2; echo y=$y", "x=\ny=2\n"},
+	}
+
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		runner{}.RunScript(context.Background(), test.script, nil, &stdout, &stderr, nil)
+		if stdout.String() != test.stdout || strings.Contains(stderr.String(), "pipe") {
+			t.Errorf("RunScript(%q) stdout = %q, stderr = %q, want %q", test.script, stdout.String(), stderr.String(), test.stdout)
+		}
+	}
+}
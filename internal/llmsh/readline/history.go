@@ -0,0 +1,102 @@
+package readline
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// History is a list of entered lines, optionally kept in a file
+type History struct {
+	entries []string
+	path    string // File the history is kept in, empty for none
+	max     int    // Maximum number of entries kept
+}
+
+// NewHistory creates an empty history kept in memory only
+func NewHistory(max int) *History {
+	return &History{max: max}
+}
+
+// LoadHistory reads the last max lines of the history file at path. A missing
+// file is an empty history; lines added later are appended to the file.
+func LoadHistory(path string, max int) (*History, error) {
+	h := &History{path: path, max: max}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return h, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	if len(h.entries) > max {
+		h.entries = h.entries[len(h.entries)-max:]
+	}
+	return h, scanner.Err()
+}
+
+// Len returns the number of entries
+func (h *History) Len() int {
+	return len(h.entries)
+}
+
+// At returns the entry at i, oldest first
+func (h *History) At(i int) string {
+	return h.entries[i]
+}
+
+// Add records a line, skipping blank lines and repeats of the last entry.
+// The file is appended to, and rewritten once it holds twice the maximum.
+func (h *History) Add(line string) error {
+	if strings.TrimSpace(line) == "" || strings.ContainsAny(line, "\r\n") {
+		return nil
+	}
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == line {
+		return nil
+	}
+	h.entries = append(h.entries, line)
+	if len(h.entries) > h.max {
+		h.entries = h.entries[len(h.entries)-h.max:]
+	}
+	if h.path == "" {
+		return nil
+	}
+
+	if h.fileTooLong() {
+		return os.WriteFile(h.path, []byte(strings.Join(h.entries, "\n")+"\n"), 0600)
+	}
+	file, err := os.OpenFile(h.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(line + "\n"); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// fileTooLong reports whether the history file has grown past twice the
+// maximum number of entries
+func (h *History) fileTooLong() bool {
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return false
+	}
+	// Lines are rarely shorter than a few bytes; only count when it may matter
+	if info.Size() < int64(2*h.max) {
+		return false
+	}
+	data, err := os.ReadFile(h.path)
+	return err == nil && strings.Count(string(data), "\n") >= 2*h.max
+}
@@ -0,0 +1,318 @@
+// Package readline is the line editor of the interactive llmsh: cursor
+// movement, history browsing and tab completion on a raw-mode terminal,
+// using only the standard library.
+package readline
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInterrupted is returned by ReadLine when the line is abandoned with Ctrl-C
+var ErrInterrupted = errors.New("interrupted")
+
+// Completer returns the candidates for the word ending at the end of head,
+// the text before the cursor, and the byte offset in head where that word starts
+type Completer func(head string) (start int, candidates []string)
+
+// Editor reads lines from a terminal
+type Editor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	fd       int  // Terminal file descriptor, if in is one
+	terminal bool // Whether in is a terminal that can be put in raw mode
+
+	// History is browsed with the up and down keys; nil disables it
+	History *History
+
+	// Complete is called on Tab; nil disables completion
+	Complete Completer
+}
+
+// New creates an editor reading keys from in and drawing on out. Line editing
+// is only enabled when in is a terminal; otherwise lines are read as they are.
+func New(in io.Reader, out io.Writer) *Editor {
+	e := &Editor{in: bufio.NewReader(in), out: out}
+	if file, ok := in.(*os.File); ok && isTerminal(int(file.Fd())) {
+		e.fd = int(file.Fd())
+		e.terminal = true
+	}
+	return e
+}
+
+// ReadLine shows prompt and returns the line entered, without its newline.
+// It returns io.EOF on Ctrl-D at an empty line or the end of input.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if !e.terminal {
+		return e.readPlain(prompt)
+	}
+
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return e.readPlain(prompt)
+	}
+	defer restore()
+	return e.edit(prompt)
+}
+
+// readPlain reads a line without editing, for input that is not a terminal
+func (e *Editor) readPlain(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+	line, err := e.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// ctrl returns the key code of Ctrl and c
+func ctrl(c rune) rune {
+	return c & 0x1f
+}
+
+// lineState is the line being edited
+type lineState struct {
+	line []rune
+	pos  int // Cursor position in line
+
+	// History browsing: index of the entry shown, and the line being
+	// typed before browsing started
+	historyIndex int
+	draft        []rune
+}
+
+// edit reads keys until the line is entered, redrawing it after each key
+func (e *Editor) edit(prompt string) (string, error) {
+	s := &lineState{historyIndex: e.historyLen()}
+	e.redraw(prompt, s)
+
+	for {
+		key, _, err := e.in.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(s.line) > 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return string(s.line), nil
+			}
+			return "", err
+		}
+
+		switch key {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(s.line), nil
+		case ctrl('C'):
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", ErrInterrupted
+		case ctrl('D'):
+			if len(s.line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			s.deleteAt(s.pos)
+		case ctrl('A'):
+			s.pos = 0
+		case ctrl('E'):
+			s.pos = len(s.line)
+		case ctrl('B'):
+			s.moveBy(-1)
+		case ctrl('F'):
+			s.moveBy(1)
+		case ctrl('K'):
+			s.line = s.line[:s.pos]
+		case ctrl('U'):
+			s.line = append([]rune(nil), s.line[s.pos:]...)
+			s.pos = 0
+		case ctrl('W'):
+			s.deleteWord()
+		case ctrl('P'):
+			e.browseHistory(s, -1)
+		case ctrl('N'):
+			e.browseHistory(s, 1)
+		case ctrl('H'), 127:
+			if s.pos > 0 {
+				s.deleteAt(s.pos - 1)
+				s.pos--
+			}
+		case '\t':
+			e.complete(s)
+		case 27:
+			if err := e.escape(s); err != nil {
+				return "", err
+			}
+		default:
+			if key >= ' ' {
+				s.insert(key)
+			}
+		}
+		e.redraw(prompt, s)
+	}
+}
+
+// escape handles an escape sequence: arrows, Home, End and Delete
+func (e *Editor) escape(s *lineState) error {
+	intro, _, err := e.in.ReadRune()
+	if err != nil || (intro != '[' && intro != 'O') {
+		return err
+	}
+
+	// Parameters, then a final byte in @..~
+	var param strings.Builder
+	for {
+		c, _, err := e.in.ReadRune()
+		if err != nil {
+			return err
+		}
+		if c >= '@' && c <= '~' {
+			switch {
+			case c == 'A':
+				e.browseHistory(s, -1)
+			case c == 'B':
+				e.browseHistory(s, 1)
+			case c == 'C':
+				s.moveBy(1)
+			case c == 'D':
+				s.moveBy(-1)
+			case c == 'H', c == '~' && (param.String() == "1" || param.String() == "7"):
+				s.pos = 0
+			case c == 'F', c == '~' && (param.String() == "4" || param.String() == "8"):
+				s.pos = len(s.line)
+			case c == '~' && param.String() == "3":
+				s.deleteAt(s.pos)
+			}
+			return nil
+		}
+		param.WriteRune(c)
+	}
+}
+
+// redraw rewrites the whole line and puts the cursor back in place
+func (e *Editor) redraw(prompt string, s *lineState) {
+	var b strings.Builder
+	b.WriteString("\r")
+	b.WriteString(prompt)
+	b.WriteString(string(s.line))
+	b.WriteString("\x1b[K")
+	if back := len(s.line) - s.pos; back > 0 {
+		fmt.Fprintf(&b, "\x1b[%dD", back)
+	}
+	io.WriteString(e.out, b.String())
+}
+
+// complete completes the word before the cursor: a single candidate is
+// inserted, several are narrowed to their common prefix or listed
+func (e *Editor) complete(s *lineState) {
+	if e.Complete == nil {
+		return
+	}
+	head := string(s.line[:s.pos])
+	start, candidates := e.Complete(head)
+	if start < 0 || start > len(head) {
+		return
+	}
+	word := head[start:]
+
+	var replacement string
+	switch len(candidates) {
+	case 0:
+		fmt.Fprint(e.out, "\a")
+		return
+	case 1:
+		replacement = candidates[0] + " "
+	default:
+		replacement = commonPrefix(candidates)
+		if len(replacement) <= len(word) {
+			fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+			return
+		}
+	}
+
+	newHead := []rune(head[:start] + replacement)
+	s.line = append(newHead, s.line[s.pos:]...)
+	s.pos = len(newHead)
+}
+
+// commonPrefix returns the longest prefix shared by all words
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	// Don't split a multi-byte character
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix
+}
+
+// browseHistory shows the previous (-1) or next (+1) history entry, coming
+// back to the line being typed after the last one
+func (e *Editor) browseHistory(s *lineState, delta int) {
+	index := s.historyIndex + delta
+	if index < 0 || index > e.historyLen() {
+		return
+	}
+	if s.historyIndex == e.historyLen() {
+		s.draft = append([]rune(nil), s.line...)
+	}
+	s.historyIndex = index
+	if index == e.historyLen() {
+		s.line = append([]rune(nil), s.draft...)
+	} else {
+		s.line = []rune(e.History.At(index))
+	}
+	s.pos = len(s.line)
+}
+
+// historyLen returns the number of history entries
+func (e *Editor) historyLen() int {
+	if e.History == nil {
+		return 0
+	}
+	return e.History.Len()
+}
+
+// insert inserts r at the cursor
+func (s *lineState) insert(r rune) {
+	s.line = append(s.line, 0)
+	copy(s.line[s.pos+1:], s.line[s.pos:])
+	s.line[s.pos] = r
+	s.pos++
+}
+
+// deleteAt deletes the rune at i, if any
+func (s *lineState) deleteAt(i int) {
+	if i < len(s.line) {
+		s.line = append(s.line[:i], s.line[i+1:]...)
+	}
+}
+
+// deleteWord deletes the word before the cursor and the spaces after it
+func (s *lineState) deleteWord() {
+	start := s.pos
+	for start > 0 && s.line[start-1] == ' ' {
+		start--
+	}
+	for start > 0 && s.line[start-1] != ' ' {
+		start--
+	}
+	s.line = append(s.line[:start], s.line[s.pos:]...)
+	s.pos = start
+}
+
+// moveBy moves the cursor, staying within the line
+func (s *lineState) moveBy(delta int) {
+	s.pos += delta
+	if s.pos < 0 {
+		s.pos = 0
+	}
+	if s.pos > len(s.line) {
+		s.pos = len(s.line)
+	}
+}
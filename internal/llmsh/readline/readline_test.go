@@ -0,0 +1,147 @@
+package readline
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEditKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		keys  string
+		want  string
+		err   error
+		setup func(e *Editor)
+	}{
+		{name: "plain", keys: "echo hi\r", want: "echo hi"},
+		{name: "backspace", keys: "echo hix\x7f\r", want: "echo hi"},
+		{name: "insert after moving left", keys: "ech hi\x1b[D\x1b[D\x1b[Do\r", want: "echo hi"},
+		{name: "home and end", keys: "cho\x01e\x05 hi\r", want: "echo hi"},
+		{name: "home and end sequences", keys: "cho\x1b[He\x1b[4~ hi\r", want: "echo hi"},
+		{name: "delete key", keys: "echoo hi\x01\x1b[C\x1b[C\x1b[C\x1b[3~\r", want: "echo hi"},
+		{name: "kill to end and start", keys: "xx echo hi there\x1b[D\x1b[D\x1b[D\x1b[D\x1b[D\x1b[D\x0b\x01\x06\x06\x06\x15\r", want: "echo hi"},
+		{name: "delete word", keys: "echo hello  \x17hi\r", want: "echo hi"},
+		{name: "interrupt", keys: "echo\x03", err: ErrInterrupted},
+		{name: "eof on empty line", keys: "\x04", err: io.EOF},
+		{name: "ctrl-d deletes", keys: "echoo\x02\x04\r", want: "echo"},
+		{name: "end of input", keys: "echo hi", want: "echo hi"},
+		{
+			name:  "history",
+			keys:  "draft\x1b[A\x1b[A\x1b[A\x1b[B\r",
+			want:  "second",
+			setup: func(e *Editor) { e.History = historyOf("first", "second") },
+		},
+		{
+			name:  "history back to draft",
+			keys:  "draft\x10\x0e\r",
+			want:  "draft",
+			setup: func(e *Editor) { e.History = historyOf("first") },
+		},
+		{
+			name: "complete single candidate",
+			keys: "ca\t< fi\t\r",
+			want: "cat < file.txt ",
+			setup: func(e *Editor) {
+				e.Complete = func(head string) (int, []string) {
+					start := strings.LastIndex(head, " ") + 1
+					if strings.HasPrefix(head, "ca") && start == 0 {
+						return 0, []string{"cat"}
+					}
+					return start, []string{"file.txt"}
+				}
+			},
+		},
+		{
+			name: "complete common prefix",
+			keys: "g\t\r",
+			want: "gun",
+			setup: func(e *Editor) {
+				e.Complete = func(head string) (int, []string) { return 0, []string{"gunzip", "gunxz"} }
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out strings.Builder
+			e := New(strings.NewReader(test.keys), &out)
+			if test.setup != nil {
+				test.setup(e)
+			}
+			got, err := e.edit("$ ")
+			if err != test.err || got != test.want {
+				t.Errorf("edit(%q) = %q, %v, want %q, %v", test.keys, got, err, test.want, test.err)
+			}
+		})
+	}
+}
+
+func TestCompletionListsAmbiguousCandidates(t *testing.T) {
+	var out strings.Builder
+	e := New(strings.NewReader("s\t\r"), &out)
+	e.Complete = func(head string) (int, []string) { return 0, []string{"sed", "sort"} }
+	if got, err := e.edit("$ "); err != nil || got != "s" {
+		t.Fatalf("edit = %q, %v, want the line unchanged", got, err)
+	}
+	if !strings.Contains(out.String(), "\r\nsed  sort\r\n") {
+		t.Errorf("output %q does not list the candidates", out.String())
+	}
+}
+
+func TestReadLineWithoutTerminal(t *testing.T) {
+	var out strings.Builder
+	e := New(strings.NewReader("echo hi\r\nlast"), &out)
+	for _, want := range []string{"echo hi", "last"} {
+		if got, err := e.ReadLine("$ "); err != nil || got != want {
+			t.Errorf("ReadLine = %q, %v, want %q", got, err, want)
+		}
+	}
+	if _, err := e.ReadLine("$ "); err != io.EOF {
+		t.Errorf("ReadLine at end = %v, want io.EOF", err)
+	}
+	if out.String() != "$ $ $ " {
+		t.Errorf("output = %q, want only the prompts", out.String())
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	h, err := LoadHistory(path, 3)
+	if err != nil || h.Len() != 0 {
+		t.Fatalf("LoadHistory(missing) = %d entries, %v", h.Len(), err)
+	}
+	for _, line := range []string{"a", "b", "b", "  ", "c", "d"} {
+		if err := h.Add(line); err != nil {
+			t.Fatalf("Add(%q) failed: %v", line, err)
+		}
+	}
+	if h.Len() != 3 || h.At(0) != "b" || h.At(2) != "d" {
+		t.Errorf("entries = %v, want the last 3 distinct lines", h.entries)
+	}
+
+	reloaded, err := LoadHistory(path, 3)
+	if err != nil || strings.Join(reloaded.entries, ",") != "b,c,d" {
+		t.Errorf("reloaded entries = %v, %v, want [b c d]", reloaded.entries, err)
+	}
+
+	// The file is rewritten to the kept entries once it grows too long
+	for _, line := range []string{"e", "f", "g", "h"} {
+		h.Add(line)
+	}
+	data, _ := os.ReadFile(path)
+	if lines := strings.Count(string(data), "\n"); lines >= 6 {
+		t.Errorf("history file has %d lines, want it trimmed", lines)
+	}
+}
+
+// historyOf returns an in-memory history of lines
+func historyOf(lines ...string) *History {
+	h := NewHistory(10)
+	for _, line := range lines {
+		h.Add(line)
+	}
+	return h
+}
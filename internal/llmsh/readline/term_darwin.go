@@ -0,0 +1,9 @@
+package readline
+
+import "syscall"
+
+// ioctl requests for the terminal attributes
+const (
+	ioctlGetTermios = syscall.TIOCGETA
+	ioctlSetTermios = syscall.TIOCSETA
+)
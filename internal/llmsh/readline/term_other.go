@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+
+package readline
+
+import "errors"
+
+// isTerminal reports false: raw mode is not supported on this platform, so
+// lines are read without editing
+func isTerminal(fd int) bool {
+	return false
+}
+
+// makeRaw is not supported on this platform
+func makeRaw(fd int) (restore func(), err error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
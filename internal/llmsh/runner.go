@@ -0,0 +1,127 @@
+package llmsh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/mako10k/llmcmd/internal/app"
+	"github.com/mako10k/llmcmd/internal/tools"
+	"github.com/mako10k/llmcmd/internal/vfsproxy"
+)
+
+func init() {
+	app.RegisterInternalShellRunner(runner{})
+}
+
+// stopGracePeriod is how long RunScript waits for a stopped script to finish
+const stopGracePeriod = time.Second
+
+// runner runs spawn scripts in-process for llmcmd (spawn_shell=llmsh)
+type runner struct{}
+
+// RunScript parses and executes script with the given streams. Redirections
+// resolve against vfs through the same proxy protocol a separate llmsh process
+// would use, served over an in-memory connection. A failure ends stderr with a
+// tools.ScriptFailure trailer. When ctx is done the script stops, and
+// RunScript waits for it, up to stopGracePeriod, before returning.
+func (runner) RunScript(ctx context.Context, script string, stdin io.Reader, stdout, stderr io.Writer, vfs tools.VirtualFileSystem) error {
+	shell, err := NewShell(&Config{})
+	if err != nil {
+		return err
+	}
+	streams := []interface{}{stdin, stdout, stderr}
+	if stdin != nil {
+		stdin = stoppableReader{ctx, stdin}
+	}
+	shell.vfs.SetStreams(stdin, stoppableWriter{ctx, stdout}, stoppableWriter{ctx, stderr})
+	shell.executor.ctx = ctx
+	if vfs != nil {
+		parentEnd, childEnd := net.Pipe()
+		defer parentEnd.Close()
+		defer childEnd.Close()
+		go vfsproxy.Serve(parentEnd, vfs)
+		shell.vfs.SetRemote(vfsproxy.NewClient(childEnd))
+		streams = append(streams, childEnd)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- shell.Execute(script)
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// The executor stops before its next command; release the one that
+		// is blocked on a stream, and wait so nothing writes after we return
+		interrupt(streams)
+		select {
+		case <-done:
+		case <-time.After(stopGracePeriod):
+			// Blocked where no stream reaches, e.g. on a local named pipe
+		}
+		resume(streams)
+		return ctx.Err()
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "llmsh: %v\n", err)
+		io.WriteString(stderr, shell.Failure(err).Trailer())
+	}
+	return err
+}
+
+// stoppableReader fails reads once ctx is done
+type stoppableReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (s stoppableReader) Read(p []byte) (int, error) {
+	if err := s.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.r.Read(p)
+}
+
+// stoppableWriter fails writes once ctx is done
+type stoppableWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (s stoppableWriter) Write(p []byte) (int, error) {
+	if err := s.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.w.Write(p)
+}
+
+// interrupt fails the reads and writes in progress on streams: those that
+// take deadlines get one in the past, and others that can be closed are
+func interrupt(streams []interface{}) {
+	for _, stream := range streams {
+		switch stream := stream.(type) {
+		case interface{ SetDeadline(time.Time) error }:
+			stream.SetDeadline(time.Unix(1, 0))
+		case io.Closer:
+			stream.Close()
+		}
+	}
+}
+
+// resume clears the deadlines interrupt set, as the caller may keep using
+// the streams
+func resume(streams []interface{}) {
+	for _, stream := range streams {
+		if stream, ok := stream.(interface{ SetDeadline(time.Time) error }); ok {
+			stream.SetDeadline(time.Time{})
+		}
+	}
+}
+
+// CheckScript reports the first syntax error in script without running it
+func (runner) CheckScript(script string) error {
+	return Check(script, nil)
+}
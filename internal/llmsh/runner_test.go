@@ -0,0 +1,666 @@
+package llmsh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mako10k/llmcmd/internal/app"
+	"github.com/mako10k/llmcmd/internal/llmsh/parser"
+	"github.com/mako10k/llmcmd/internal/openai"
+	"github.com/mako10k/llmcmd/internal/tools"
+	"github.com/mako10k/llmcmd/internal/tools/builtin"
+)
+
+func TestRunnerPipelineWithStreams(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	err := runner{}.RunScript(context.Background(), "cat | tr hel HEL", strings.NewReader("hello\n"), &stdout, &stderr, nil)
+	if err != nil {
+		t.Fatalf("RunScript failed: %v (stderr %q)", err, stderr.String())
+	}
+	if stdout.String() != "HELLo\n" {
+		t.Errorf("stdout = %q, want HELLo", stdout.String())
+	}
+}
+
+// memFS is a parent VFS keeping whole files in memory
+type memFS struct {
+	files map[string]*bytes.Buffer
+}
+
+// memFile is an open memFS file
+type memFile struct {
+	*bytes.Buffer
+}
+
+func (memFile) Close() error { return nil }
+
+func (m *memFS) OpenFile(name string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
+	buf, exists := m.files[name]
+	if !exists {
+		if flag&os.O_CREATE == 0 {
+			return nil, os.ErrNotExist
+		}
+		buf = &bytes.Buffer{}
+		m.files[name] = buf
+	}
+	if flag&os.O_TRUNC != 0 {
+		buf.Reset()
+	}
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return memFile{bytes.NewBuffer(buf.Bytes())}, nil
+	}
+	return memFile{buf}, nil
+}
+
+func (m *memFS) CreateTemp(pattern string) (io.ReadWriteCloser, string, error) {
+	return nil, "", errors.New("not supported")
+}
+
+func (m *memFS) RemoveFile(name string) error {
+	delete(m.files, name)
+	return nil
+}
+
+func (m *memFS) ListFiles() []string {
+	return nil
+}
+
+func (m *memFS) FileNames() []string {
+	var names []string
+	for name := range m.files {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (m *memFS) FileSize(name string) (int, error) {
+	buf, exists := m.files[name]
+	if !exists {
+		return 0, os.ErrNotExist
+	}
+	return buf.Len(), nil
+}
+
+func TestRunnerRedirectsToParentVFS(t *testing.T) {
+	vfs := &memFS{files: map[string]*bytes.Buffer{"in.txt": bytes.NewBufferString("b\na\n")}}
+	var stderr bytes.Buffer
+	if err := (runner{}).RunScript(context.Background(), "sort < in.txt > out.txt", nil, io.Discard, &stderr, vfs); err != nil {
+		t.Fatalf("RunScript failed: %v (stderr %q)", err, stderr.String())
+	}
+	if out := vfs.files["out.txt"]; out == nil || out.String() != "a\nb\n" {
+		t.Errorf("out.txt = %v, want the sorted input", out)
+	}
+}
+
+func TestRunnerReturnsWhenContextIsDone(t *testing.T) {
+	stdin, _ := io.Pipe()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := runner{}.RunScript(ctx, "cat", stdin, io.Discard, io.Discard, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RunScript = %v, want the context error", err)
+	}
+	stdin.Close()
+}
+
+// countingWriter counts the writes made to it
+type countingWriter struct {
+	mu     sync.Mutex
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writes++
+	return len(p), nil
+}
+
+func (w *countingWriter) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.writes
+}
+
+func TestRunnerStopsScriptWhenContextIsDone(t *testing.T) {
+	// A script that never blocks stops writing
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var stdout countingWriter
+	if err := (runner{}).RunScript(ctx, "seq 1000000000; echo after", nil, &stdout, io.Discard, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RunScript = %v, want the context error", err)
+	}
+	written := stdout.count()
+	time.Sleep(50 * time.Millisecond)
+	if stdout.count() != written {
+		t.Errorf("the script wrote %d more times after RunScript returned", stdout.count()-written)
+	}
+
+	// One blocked reading a pipe is released, and the pipe is usable again
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe() error = %v", err)
+	}
+	defer reader.Close()
+	defer writer.Close()
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var after bytes.Buffer
+	if err := (runner{}).RunScript(ctx, "cat; echo after", reader, &after, io.Discard, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RunScript = %v, want the context error", err)
+	}
+	if after.Len() != 0 {
+		t.Errorf("stdout = %q, want nothing run after the stop", after.String())
+	}
+	go writer.Write([]byte("x"))
+	if _, err := reader.Read(make([]byte, 1)); err != nil {
+		t.Errorf("Read() from the pipe after RunScript = %v", err)
+	}
+}
+
+func TestRunnerIsRegistered(t *testing.T) {
+	if _, ok := app.NewInternalShellExecutor(); !ok {
+		t.Error("importing llmsh did not register the in-process runner")
+	}
+}
+
+func TestRunnerChecksSyntax(t *testing.T) {
+	executor, _ := app.NewInternalShellExecutor()
+	if err := executor.CheckSyntax("f() { echo $1; }\nf a | sort"); err != nil {
+		t.Errorf("CheckSyntax of a valid script = %v", err)
+	}
+	err := executor.CheckSyntax("echo a\necho b &&")
+	var syntaxErr *parser.SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Line != 2 {
+		t.Errorf("CheckSyntax = %v, want a syntax error on line 2", err)
+	}
+}
+
+func TestRunnerReportsFailure(t *testing.T) {
+	tests := []struct {
+		script  string
+		failure tools.ScriptFailure
+	}{
+		{
+			script:  "set -e\necho a\ncheck() {\n  vcat missing.txt\n}\ncheck",
+			failure: tools.ScriptFailure{Command: "vcat missing.txt", Line: 4, ExitCode: 1, Message: "vcat: missing.txt: file not found: missing.txt"},
+		},
+		{
+			script:  "echo a\nf() { return 3; }\nf",
+			failure: tools.ScriptFailure{Command: "return 3", Line: 2, ExitCode: 3},
+		},
+		{
+			script:  "echo a\necho b |",
+			failure: tools.ScriptFailure{Line: 2, ExitCode: 2, Message: "syntax error at line 2, column 9: expected command after pipe"},
+		},
+	}
+
+	for _, test := range tests {
+		var stderr bytes.Buffer
+		err := runner{}.RunScript(context.Background(), test.script, nil, io.Discard, &stderr, nil)
+		if code := exitStatus(err); code != test.failure.ExitCode {
+			t.Errorf("RunScript(%q) status = %d, want %d", test.script, code, test.failure.ExitCode)
+		}
+		if want := test.failure.Trailer(); !strings.HasSuffix(stderr.String(), want) {
+			t.Errorf("RunScript(%q) stderr = %q, want it to end with %q", test.script, stderr.String(), want)
+		}
+	}
+
+	var stderr bytes.Buffer
+	runner{}.RunScript(context.Background(), "false || echo ok", nil, io.Discard, &stderr, nil)
+	if strings.Contains(stderr.String(), tools.ScriptFailurePrefix) {
+		t.Errorf("successful script reported a failure: %q", stderr.String())
+	}
+}
+
+func TestRunnerPipelineWithRedirections(t *testing.T) {
+	tests := []struct {
+		script   string
+		stdout   string
+		expected map[string]string
+	}{
+		{
+			script:   "grep err < log | sort | uniq -c > out.txt",
+			expected: map[string]string{"out.txt": "   1 err a\n   2 err b\n"},
+		},
+		{
+			script:   "grep ok < log >> log",
+			expected: map[string]string{"log": "err b\nok\nerr a\nerr b\nok\n"},
+		},
+		{
+			script: "grep err < log | sort | uniq -c",
+			stdout: "   1 err a\n   2 err b\n",
+		},
+	}
+
+	for _, test := range tests {
+		vfs := &memFS{files: map[string]*bytes.Buffer{"log": bytes.NewBufferString("err b\nok\nerr a\nerr b\n")}}
+		var stdout, stderr bytes.Buffer
+		if err := (runner{}).RunScript(context.Background(), test.script, nil, &stdout, &stderr, vfs); err != nil {
+			t.Fatalf("RunScript(%q) failed: %v (stderr %q)", test.script, err, stderr.String())
+		}
+		if stdout.String() != test.stdout {
+			t.Errorf("RunScript(%q) stdout = %q, want %q", test.script, stdout.String(), test.stdout)
+		}
+		for name, want := range test.expected {
+			if got := vfs.files[name]; got == nil || got.String() != want {
+				t.Errorf("RunScript(%q) %s = %v, want %q", test.script, name, got, want)
+			}
+		}
+	}
+}
+
+func TestRunnerSubstitutionAndStatus(t *testing.T) {
+	tests := []struct {
+		script string
+		stdout string
+		stderr string
+	}{
+		{script: "COUNT=$(wc -l < log)\necho lines: $COUNT", stdout: "lines: 4\n"},
+		{script: "false; echo $?", stdout: "1\n"},
+		{script: "true && echo $?", stdout: "0\n"},
+		{script: "echo a | grep b; echo $?; echo a | grep -E '('; echo $?", stdout: "1\n2\n"},
+		{script: "X=1; echo \"x=$X\" 'y=$X' \\$X ${X}0", stdout: "x=1 y=$X $X 10\n"},
+		{script: "echo \"$(echo a | tr a b)\" $(echo $(echo nested))", stdout: "b nested\n"},
+		{script: "N=$(echo 6); echo $((N * 7)) \"$(($N / 4))\" $(( $(echo 5) % 3 )); echo $((i = 2 + 1)) $i", stdout: "42 1 2\n3 3\n"},
+		{script: "echo $((1 / 0)); echo $?", stdout: "1\n"},
+		{script: "X=$(echo inner; Y=leak); echo $X \"[$Y]\"", stdout: "inner []\n"},
+		{script: "echo \"x$(echo in; nosuch)y\" $?", stdout: "xiny 1\n", stderr: "llmsh: command not found: nosuch\n"},
+	}
+
+	for _, test := range tests {
+		vfs := &memFS{files: map[string]*bytes.Buffer{"log": bytes.NewBufferString("err b\nok\nerr a\nerr b\n")}}
+		var stdout, stderr bytes.Buffer
+		runner{}.RunScript(context.Background(), test.script, nil, &stdout, &stderr, vfs)
+		if stdout.String() != test.stdout {
+			t.Errorf("RunScript(%q) stdout = %q, want %q", test.script, stdout.String(), test.stdout)
+		}
+		if test.stderr != "" && stderr.String() != test.stderr {
+			t.Errorf("RunScript(%q) stderr = %q, want %q", test.script, stderr.String(), test.stderr)
+		}
+	}
+}
+
+func TestRunnerGlobsParentFiles(t *testing.T) {
+	tests := []struct {
+		script string
+		stdout string
+	}{
+		{script: "cat *.log", stdout: "a\nb\n"},
+		{script: "echo *.log b.t?? x*", stdout: "a.log b.log b.txt x*\n"},
+		{script: "echo '*.log' \"b.*\" \\*.log", stdout: "*.log b.* *.log\n"},
+		{script: "F=*.txt; echo $F; cat < *.txt", stdout: "b.txt\ntext\n"},
+		{script: "cat *.log > all.out; cat *.out", stdout: "a\nb\n"},
+	}
+
+	for _, test := range tests {
+		vfs := &memFS{files: map[string]*bytes.Buffer{
+			"b.log": bytes.NewBufferString("b\n"),
+			"a.log": bytes.NewBufferString("a\n"),
+			"b.txt": bytes.NewBufferString("text\n"),
+		}}
+		var stdout, stderr bytes.Buffer
+		runner{}.RunScript(context.Background(), test.script, nil, &stdout, &stderr, vfs)
+		if stdout.String() != test.stdout {
+			t.Errorf("RunScript(%q) stdout = %q, want %q (stderr %q)", test.script, stdout.String(), test.stdout, stderr.String())
+		}
+	}
+}
+
+func TestRunnerMountedParentDirectory(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("alpha\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("beta\n"), 0644)
+	vfs := app.NewSimpleVirtualFS()
+	if err := vfs.MountReadOnly(dir, "repo"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		script string
+		stdout string
+		stderr string
+	}{
+		{script: "find repo -type f", stdout: "repo/a.txt\nrepo/sub/b.txt\n"},
+		{script: "cat repo/sub/b.txt repo/a.txt", stdout: "beta\nalpha\n"},
+		{script: "vls repo", stdout: "repo/a.txt\n"},
+		{script: "echo x > repo/c.txt", stderr: "read-only file system"},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		runner{}.RunScript(context.Background(), test.script, nil, &stdout, &stderr, vfs)
+		if stdout.String() != test.stdout || !strings.Contains(stderr.String(), test.stderr) {
+			t.Errorf("RunScript(%q) stdout = %q, stderr = %q, want %q and %q", test.script, stdout.String(), stderr.String(), test.stdout, test.stderr)
+		}
+	}
+}
+
+func TestRunnerBackgroundJobs(t *testing.T) {
+	// block runs until release is called, so the job is seen running
+	released := make(chan struct{})
+	builtin.Commands["block"] = func(args []string, stdin io.Reader, stdout io.Writer) error {
+		<-released
+		_, err := io.WriteString(stdout, "unblocked\n")
+		return err
+	}
+	builtin.Commands["release"] = func(args []string, stdin io.Reader, stdout io.Writer) error {
+		close(released)
+		return nil
+	}
+	defer delete(builtin.Commands, "block")
+	defer delete(builtin.Commands, "release")
+
+	script := "X=1; block && false &\necho started\njobs\nrelease\nwait %1; echo $?\nX=2 & wait; jobs; echo $X\nblock & fg\nwait %9"
+	var stdout, stderr bytes.Buffer
+	runner{}.RunScript(context.Background(), script, nil, &stdout, &stderr, nil)
+
+	want := "started\n[1] Running  block && false &\nunblocked\n1\n1\nblock\nunblocked\n"
+	if stdout.String() != want {
+		t.Errorf("stdout = %q, want %q", stdout.String(), want)
+	}
+	if !strings.Contains(stderr.String(), "wait: %9: no such job") {
+		t.Errorf("stderr = %q, want an unknown job error", stderr.String())
+	}
+}
+
+func TestRunnerLLMBuiltin(t *testing.T) {
+	type runKey struct{}
+	ctx := context.WithValue(context.Background(), runKey{}, "run")
+	var prompts, inputs []string
+	defer func(original func(context.Context, string, string, *openai.SharedQuotaManager, string) (string, error)) {
+		chat = original
+	}(chat)
+	chat = func(chatCtx context.Context, prompt, input string, quota *openai.SharedQuotaManager, processID string) (string, error) {
+		if quota == nil || processID == "" {
+			t.Errorf("chat called without a quota process")
+		}
+		if chatCtx.Value(runKey{}) != "run" {
+			t.Errorf("chat called without the script's context")
+		}
+		prompts = append(prompts, prompt)
+		inputs = append(inputs, input)
+		return "ERROR a\n", nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	script := "grep ERROR | llm \"pick\" the worst | tr a A\nllm -n 'no input'\nllm"
+	runner{}.RunScript(ctx, script, strings.NewReader("ok\nERROR a\nERROR b\n"), &stdout, &stderr, nil)
+
+	if strings.Join(prompts, "|") != "pick the worst|no input" || strings.Join(inputs, "|") != "ERROR a\nERROR b\n|" {
+		t.Errorf("prompts = %q, inputs = %q", prompts, inputs)
+	}
+	if !strings.HasPrefix(stdout.String(), "ERROR A\n") {
+		t.Errorf("stdout = %q, want the reply to flow down the pipeline", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "llm: missing prompt") {
+		t.Errorf("stderr = %q, want a missing prompt error", stderr.String())
+	}
+}
+
+func TestRunnerManagesParentFiles(t *testing.T) {
+	vfs := &memFS{files: map[string]*bytes.Buffer{
+		"a.log": bytes.NewBufferString("a\n"),
+		"b.log": bytes.NewBufferString("bb\n"),
+	}}
+	var stdout, stderr bytes.Buffer
+	script := "vls -l; vstat b.log; vcat a.log; vrm a.log; vls *; vstat a.log"
+	runner{}.RunScript(context.Background(), script, nil, &stdout, &stderr, vfs)
+
+	want := "parent         2 a.log\nparent         3 b.log\nb.log: parent file, 3 bytes\na\nb.log\n"
+	if stdout.String() != want {
+		t.Errorf("stdout = %q, want %q (stderr %q)", stdout.String(), want, stderr.String())
+	}
+	if _, exists := vfs.files["a.log"]; exists {
+		t.Error("a.log still exists in the parent after vrm")
+	}
+	if !strings.Contains(stderr.String(), "vstat: a.log:") {
+		t.Errorf("stderr = %q, want a vstat error for a.log", stderr.String())
+	}
+}
+
+func TestRunnerClonesParentFiles(t *testing.T) {
+	vfs := app.NewSimpleVirtualFS()
+	w, _ := vfs.OpenFile("app.py", os.O_WRONLY|os.O_CREATE, 0644)
+	io.WriteString(w, "DEBUG = True\n")
+
+	var stdout, stderr bytes.Buffer
+	script := "vcp app.py app.py.orig; echo 'DEBUG = False' > app.py; cat app.py.orig; vcp missing x"
+	runner{}.RunScript(context.Background(), script, nil, &stdout, &stderr, vfs)
+
+	if want := "DEBUG = True\n"; stdout.String() != want {
+		t.Errorf("stdout = %q, want %q (stderr %q)", stdout.String(), want, stderr.String())
+	}
+	if size, err := vfs.FileSize("app.py"); err != nil || size != 14 {
+		t.Errorf("FileSize(app.py) = %d, %v, want the 14 bytes written after vcp", size, err)
+	}
+	if !strings.Contains(stderr.String(), "vcp: missing:") {
+		t.Errorf("stderr = %q, want a vcp error for missing", stderr.String())
+	}
+}
+
+func TestRunnerParentFileMetadata(t *testing.T) {
+	vfs := app.NewSimpleVirtualFS()
+	for name, perm := range map[string]os.FileMode{"old.txt": 0644, "report.txt": 0600} {
+		w, _ := vfs.OpenFile(name, os.O_WRONLY|os.O_CREATE, perm)
+		io.WriteString(w, "report\n")
+	}
+
+	tests := []struct {
+		script string
+		stdout string
+	}{
+		{script: "vstat -c '%a %A %s %F %n' report.txt", stdout: "600 -rw------- 7 parent file report.txt\n"},
+		{script: "find -perm 600", stdout: "report.txt\n"},
+		{script: "find -mmin -1", stdout: "old.txt\nreport.txt\n"},
+		{script: "vstat -c '%Y' report.txt | grep -c '^[1-9][0-9]*$'", stdout: "1\n"},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		runner{}.RunScript(context.Background(), test.script, nil, &stdout, &stderr, vfs)
+		if stdout.String() != test.stdout {
+			t.Errorf("RunScript(%q) stdout = %q, want %q (stderr %q)", test.script, stdout.String(), test.stdout, stderr.String())
+		}
+	}
+}
+
+func TestRunnerParentLocks(t *testing.T) {
+	vfs := app.NewSimpleVirtualFS()
+	vfs.TryLock("report", "other", true)
+
+	var stdout, stderr bytes.Buffer
+	script := "vlock -n ./report; echo $?; vlock -n summary; echo $?"
+	runner{}.RunScript(context.Background(), script, nil, &stdout, &stderr, vfs)
+	if want := "1\n0\n"; stdout.String() != want {
+		t.Errorf("stdout = %q, want %q (stderr %q)", stdout.String(), want, stderr.String())
+	}
+
+	// The script's locks go when its connection closes
+	deadline := time.Now().Add(time.Second)
+	for !vfs.TryLock("summary", "other", true) {
+		if time.Now().After(deadline) {
+			t.Fatal("summary is still locked after the script ended")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRunnerParentNamedPipes(t *testing.T) {
+	vfs := app.NewSimpleVirtualFS()
+
+	var stdout, stderr bytes.Buffer
+	runner{}.RunScript(context.Background(), "mkfifo feed; vstat -c '%F %a' feed; mkfifo feed", nil, &stdout, &stderr, vfs)
+	if want := "parent fifo 644\n"; stdout.String() != want {
+		t.Errorf("stdout = %q, want %q (stderr %q)", stdout.String(), want, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "mkfifo: vfs:") {
+		t.Errorf("stderr = %q, want an error for the existing pipe", stderr.String())
+	}
+
+	// The reader may start before the writer's script, and then waits for it
+	var readerOut bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		runner{}.RunScript(context.Background(), "sort -r < feed", nil, &readerOut, io.Discard, vfs)
+		close(done)
+	}()
+	runner{}.RunScript(context.Background(), "seq 3 > feed", nil, io.Discard, io.Discard, vfs)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the reader did not finish")
+	}
+	if want := "3\n2\n1\n"; readerOut.String() != want {
+		t.Errorf("reader stdout = %q, want %q", readerOut.String(), want)
+	}
+}
+
+func TestRunnerStrictMode(t *testing.T) {
+	tests := []struct {
+		script string
+		stdout string
+	}{
+		{script: "false; echo after", stdout: "after\n"},
+		{script: "set -e; false; echo after", stdout: ""},
+		{script: "set -e; false && echo skipped; false || echo handled; echo after", stdout: "handled\nafter\n"},
+		{script: "set -e; true && false; echo after", stdout: ""},
+		{script: "set -e; set +e; false; echo after", stdout: "after\n"},
+		{script: "set -e; X=$(false; echo in); echo $X", stdout: ""},
+		{script: "X=$(false) || echo failed; Y=$(true) && echo ok", stdout: "failed\nok\n"},
+		{script: "false | echo piped; echo $?", stdout: "piped\n0\n"},
+		{script: "echo a | false | true; echo $?", stdout: "0\n"},
+		{script: "set -o pipefail; echo a | false | true; echo $?", stdout: "1\n"},
+		{script: "set -eo pipefail; false | true; echo after", stdout: ""},
+		{script: "set -o pipefail -e; set -o", stdout: "errexit         on\npipefail        on\ntiming          off\n"},
+		{script: "A=1; B=x; set", stdout: "A=1\nB=x\n"},
+	}
+
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		runner{}.RunScript(context.Background(), test.script, nil, &stdout, &stderr, nil)
+		if stdout.String() != test.stdout {
+			t.Errorf("RunScript(%q) stdout = %q, want %q (stderr %q)", test.script, stdout.String(), test.stdout, stderr.String())
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	err := runner{}.RunScript(context.Background(), "set -x", nil, &stdout, &stderr, nil)
+	if err == nil || !strings.Contains(stderr.String(), "set: -x: invalid option") {
+		t.Errorf("set -x: err = %v, stderr = %q, want an invalid option error", err, stderr.String())
+	}
+}
+
+func TestRunnerFunctionsAndAliases(t *testing.T) {
+	tests := []struct {
+		script string
+		stdout string
+	}{
+		{script: "greet() { echo \"hello $1 ($#: $@)\"; }; greet world two", stdout: "hello world (2: world two)\n"},
+		{script: "upper() {\n  tr abc ABC\n}\necho abc | upper | rev", stdout: "CBA\n"},
+		{script: "f() { cat missing; echo out; }; f > o.txt 2> e.txt; vcat o.txt e.txt", stdout: "out\nllmsh: cat: missing: file not found: missing\n"},
+		{script: "check() { test -n \"$1\" || return 3; echo ok; }; check; echo $?; check x", stdout: "3\nok\n"},
+		{script: "f() { X=inner; false; }; f; echo $? $X", stdout: "1 inner\n"},
+		{script: "set -e; f() { false && true; }; f; echo after", stdout: ""},
+		{script: "alias hi='echo hello | tr h H'; hi; alias up=\"tr x X\"; echo x | up", stdout: "Hello\nX\n"},
+		{script: "alias say='echo said'; say a b; unalias say; say 2> /dev/null || echo gone", stdout: "said a b\ngone\n"},
+		{script: "alias echo='echo [x]'; echo a; alias", stdout: "[x] a\nalias echo='echo [x]'\n"},
+		{script: "loop() { loop; }; loop; echo $?", stdout: "1\n"},
+	}
+
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		runner{}.RunScript(context.Background(), test.script, nil, &stdout, &stderr, nil)
+		if stdout.String() != test.stdout {
+			t.Errorf("RunScript(%q) stdout = %q, want %q (stderr %q)", test.script, stdout.String(), test.stdout, stderr.String())
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	runner{}.RunScript(context.Background(), "return 1", nil, &stdout, &stderr, nil)
+	if !strings.Contains(stderr.String(), "return: can only be used in a function") {
+		t.Errorf("return outside a function: stderr = %q", stderr.String())
+	}
+}
+
+func TestRunnerTiming(t *testing.T) {
+	// The clock only moves when sleep runs, by its argument in seconds, once
+	// its input is done. Pipelines run their commands concurrently.
+	var mu sync.Mutex
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return clock
+	}
+	defer func() { now = time.Now }()
+	builtin.Commands["sleep"] = func(args []string, stdin io.Reader, stdout io.Writer) error {
+		seconds, _ := strconv.Atoi(args[0])
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		clock = clock.Add(time.Duration(seconds) * time.Second)
+		mu.Unlock()
+		_, err = stdout.Write(data)
+		return err
+	}
+	defer delete(builtin.Commands, "sleep")
+
+	var stdout, stderr bytes.Buffer
+	runner{}.RunScript(context.Background(), "echo a | sleep 3 | sleep 1 > out; time echo a | sleep 3 | sleep 1; time sleep 2", nil, &stdout, &stderr, nil)
+	// Each command's time runs from the start of the pipeline to its exit
+	want := "time: 4.000s  echo a | sleep 3 | sleep 1\n" +
+		"      0.000s   0%  echo a\n" +
+		"      3.000s  75%  sleep 3\n" +
+		"      4.000s 100%  sleep 1\n" +
+		"time: 2.000s  sleep 2\n"
+	if stdout.String() != "a\n" || stderr.String() != want {
+		t.Errorf("stdout = %q, stderr = %q, want %q", stdout.String(), stderr.String(), want)
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	runner{}.RunScript(context.Background(), "times; set -o timing; sleep 2; echo a | sleep 1 > a.txt; sleep 3; times > t.txt; vcat t.txt", nil, &stdout, &stderr, nil)
+	summary := " calls      total  command\n" +
+		"     3     6.000s  sleep\n" +
+		"     1     0.000s  echo\n" +
+		"     1     0.000s  set\n"
+	if stdout.String() != summary {
+		t.Errorf("times output = %q, want %q", stdout.String(), summary)
+	}
+	if want := "llmsh: times: no timings recorded; enable them with set -o timing\nllmsh: timing summary\n calls"; !strings.HasPrefix(stderr.String(), want) {
+		t.Errorf("stderr = %q, want it to start with %q", stderr.String(), want)
+	}
+}
+
+func TestRunnerSingleQuotedBackslashes(t *testing.T) {
+	tests := []struct {
+		script string
+		stdout string
+	}{
+		{script: `echo abc | sed 's/\(b\)/[\1]/'`, stdout: "a[b]c\n"},
+		{script: `echo abc | sed -E 's/(b)/[\1]/'`, stdout: "a[b]c\n"},
+		{script: `printf 'a.b\naxb\n' | grep -c 'a\.b'`, stdout: "1\n"},
+		{script: `echo 'axb a.b' | rextract 'a\.b'`, stdout: "a.b\n"},
+		{script: `echo '\$X\*' "a\\b"`, stdout: "\\$X\\* a\\b\n"},
+	}
+
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		err := runner{}.RunScript(context.Background(), test.script, nil, &stdout, &stderr, nil)
+		if err != nil || stdout.String() != test.stdout {
+			t.Errorf("RunScript(%q) = %q, %v, want %q (stderr %q)", test.script, stdout.String(), err, test.stdout, stderr.String())
+		}
+	}
+}
@@ -1,14 +1,22 @@
 package llmsh
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/mako10k/llmcmd/internal/buildinfo"
 	"github.com/mako10k/llmcmd/internal/llmsh/parser"
+	"github.com/mako10k/llmcmd/internal/llmsh/readline"
+	"github.com/mako10k/llmcmd/internal/tools"
+	"github.com/mako10k/llmcmd/internal/vfsproxy"
 )
 
 // Version information
 var (
-	Version     = "3.1.1" // Will be overridden by build-time ldflags
-	BuildCommit = "unknown" // Will be overridden by build-time ldflags
-	BuildTime   = "unknown" // Will be overridden by build-time ldflags
+	Version     = buildinfo.Version // Set by build-time ldflags on the buildinfo package
 	Name        = "llmsh"
 	Description = "Minimal shell for LLM text processing"
 )
@@ -42,6 +50,32 @@ type Config struct {
 
 	// Debug mode
 	Debug bool
+
+	// Inherited connection to the parent llmcmd VFS (0 = none)
+	VFSFd int
+
+	// Unix socket on which the parent llmcmd serves its VFS; preferred to
+	// VFSFd, as the connection is this shell's own
+	VFSSocket string
+
+	// Print a summary of the time spent in each command at the end, as with
+	// set -o timing
+	Timing bool
+
+	// Commands scripts may run, nil for all. Functions and aliases defined
+	// by the script may always be called.
+	AllowedCommands []string
+
+	// Project directory (see FindProjectDir) keeping the interactive history
+	// and a log of the scripts run, "" for none
+	HistoryDir string
+
+	// File recording the session's commands, input and output with
+	// timestamps, as JSON lines (see ReadTranscript), "" for none
+	Transcript string
+
+	// Input in place of the process's stdin, nil for the process's
+	Stdin io.Reader
 }
 
 // NewShell creates a new shell instance
@@ -52,9 +86,43 @@ func NewShell(config *Config) (*Shell, error) {
 
 	// Initialize components
 	vfs := NewVirtualFileSystem(config.InputFile, config.OutputFile)
+	if config.VFSSocket != "" {
+		client, err := vfsproxy.DialSocket(config.VFSSocket)
+		if err != nil {
+			return nil, err
+		}
+		vfs.SetRemote(client)
+	} else if config.VFSFd > 0 {
+		client, err := vfsproxy.Dial(config.VFSFd)
+		if err != nil {
+			return nil, err
+		}
+		vfs.SetRemote(client)
+	}
 	help := NewHelpSystem()
 	parser := parser.NewParser()
 	executor := NewExecutor(vfs, help, config.QuotaManager)
+	executor.options.timing = config.Timing
+	executor.allowed = allowedSet(config.AllowedCommands)
+	if config.HistoryDir != "" {
+		// Scripts can search the history too; interactive mode adds to it
+		executor.history, _ = readline.LoadHistory(filepath.Join(config.HistoryDir, projectHistoryFile), maxHistory)
+		executor.scriptLog = filepath.Join(config.HistoryDir, scriptLogFile)
+	}
+	var stdin io.Reader = os.Stdin
+	if config.Stdin != nil {
+		stdin = config.Stdin
+	}
+	if config.Transcript != "" {
+		t, err := createTranscript(config.Transcript)
+		if err != nil {
+			return nil, err
+		}
+		executor.transcript = t
+		vfs.SetStreams(transcriptReader{t, stdin}, transcriptWriter{t, "stdout", os.Stdout}, transcriptWriter{t, "stderr", os.Stderr})
+	} else if config.Stdin != nil {
+		vfs.SetStreams(stdin, os.Stdout, os.Stderr)
+	}
 
 	return &Shell{
 		config:   config,
@@ -65,20 +133,100 @@ func NewShell(config *Config) (*Shell, error) {
 	}, nil
 }
 
+// Check parses a script without running it. A syntax error is a
+// *parser.SyntaxError giving the line and column of the problem. Unless
+// allowedCommands is nil, the script may only run those commands.
+func Check(input string, allowedCommands []string) error {
+	ast, err := parser.NewParser().Parse(input)
+	if err != nil {
+		return err
+	}
+	return checkScript(ast, allowedSet(allowedCommands))
+}
+
 // Execute runs a shell command or script
 func (s *Shell) Execute(input string) error {
 	// Parse the input
-	ast, err := s.parser.Parse(input)
-	if err != nil {
-		return err
+	ast, err := s.parse(input)
+	if err == nil {
+		err = checkScript(ast, s.executor.allowed)
+	}
+	if err == nil {
+		// Execute the parsed commands. Background jobs run in-process, so
+		// they must finish before the shell does.
+		err = s.executor.Execute(ast)
+		s.executor.waitJobs()
+		s.reportTimings()
 	}
+	s.recordRun(input, err)
+	return err
+}
 
-	// Execute the parsed commands
-	return s.executor.Execute(ast)
+// parse parses a script or an interactive line. With a transcript, it is
+// parsed as a script keeping the source of each statement for the transcript.
+func (s *Shell) parse(input string) (parser.Node, error) {
+	if s.executor.transcript == nil {
+		return s.parser.Parse(input)
+	}
+	return s.parser.ParseScript(input)
 }
 
-// Interactive starts an interactive shell session
-func (s *Shell) Interactive() error {
-	// TODO: Implement interactive mode
-	return nil
+// Close ends the session, closing the transcript
+func (s *Shell) Close() error {
+	if s.executor.transcript == nil {
+		return nil
+	}
+	return s.executor.transcript.Close()
+}
+
+// recordRun adds a script run to the project's log, if there is one
+func (s *Shell) recordRun(script string, err error) {
+	if s.executor.scriptLog == "" {
+		return
+	}
+	run := scriptRun{Time: now(), Status: exitStatus(err), Script: script}
+	if err := recordScriptRun(s.executor.scriptLog, run); err != nil {
+		s.executor.reportError(fmt.Errorf("history: %w", err))
+	}
+}
+
+// Failure describes why Execute failed with err, for a caller that runs the
+// script on behalf of llmcmd: the last command that failed and its line, or
+// the location of a syntax error. It returns nil when err is nil.
+func (s *Shell) Failure(err error) *tools.ScriptFailure {
+	if err == nil {
+		return nil
+	}
+	failure := &tools.ScriptFailure{ExitCode: exitStatus(err)}
+	var syntaxErr *parser.SyntaxError
+	var notAllowed *notAllowedError
+	switch {
+	case errors.As(err, &syntaxErr):
+		failure.Line = syntaxErr.Line
+		failure.Message = syntaxErr.Error()
+	case errors.As(err, &notAllowed) && s.executor.failed == nil:
+		failure.Command = notAllowed.cmd.String()
+		failure.Line = notAllowed.cmd.Line
+		failure.Message = notAllowed.Error()
+	case s.executor.failed != nil:
+		failure.Command = s.executor.failed.cmd.String()
+		failure.Line = s.executor.failed.cmd.Line
+		if !isBareStatus(s.executor.failed.err) {
+			failure.Message = s.executor.failed.err.Error()
+		}
+	case !isBareStatus(err):
+		failure.Message = err.Error()
+	}
+	return failure
+}
+
+// reportTimings prints the session's timing summary on stderr if set -o
+// timing was in effect
+func (s *Shell) reportTimings() {
+	if !s.executor.options.timing {
+		return
+	}
+	if summary := s.executor.timings.summary(); summary != "" {
+		fmt.Fprintf(s.executor.errorStream(), "llmsh: timing summary\n%s", summary)
+	}
 }
@@ -1,8 +1,16 @@
 package llmsh
 
 import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestShellBasicCommands(t *testing.T) {
@@ -130,3 +138,531 @@ func TestHelpSystem(t *testing.T) {
 		}
 	}
 }
+
+func TestShellInteractive(t *testing.T) {
+	shell, err := NewShell(nil)
+	if err != nil {
+		t.Fatalf("Failed to create shell: %v", err)
+	}
+	var stdout bytes.Buffer
+	shell.vfs.SetStreams(nil, &stdout, &stdout)
+	historyPath := filepath.Join(t.TempDir(), ".llmsh_history")
+
+	input := "X=hi\n\necho $X | tr h H\nnosuchcmd\nexit\necho never\n"
+	var prompts bytes.Buffer
+	if err := shell.interact(strings.NewReader(input), &prompts, historyPath); err != nil {
+		t.Fatalf("interact failed: %v", err)
+	}
+
+	if !strings.Contains(stdout.String(), "Hi\n") || strings.Contains(stdout.String(), "never") {
+		t.Errorf("output = %q, want the commands before exit to run", stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "llmsh: command not found: nosuchcmd") {
+		t.Errorf("output = %q, want the failing command reported", stdout.String())
+	}
+	if strings.Count(prompts.String(), prompt) != 5 {
+		t.Errorf("prompts = %q, want one per line read", prompts.String())
+	}
+	history, _ := os.ReadFile(historyPath)
+	if string(history) != "X=hi\necho $X | tr h H\nnosuchcmd\nexit\n" {
+		t.Errorf("history = %q", history)
+	}
+}
+
+func TestShellCompletion(t *testing.T) {
+	shell, err := NewShell(&Config{InputFile: "input.txt"})
+	if err != nil {
+		t.Fatalf("Failed to create shell: %v", err)
+	}
+	out, _ := shell.vfs.OpenForWrite("report.txt", false)
+	out.Close()
+
+	tests := []struct {
+		head       string
+		start      int
+		candidates string
+	}{
+		{head: "gun", start: 0, candidates: "gunzip"},
+		{head: "cat x | wa", start: 8, candidates: "wait"},
+		{head: "cat ", start: 4, candidates: "input.txt report.txt"},
+		{head: "sort < re", start: 7, candidates: "report.txt"},
+		{head: "echo hi && ex", start: 11, candidates: "exit expand expr"},
+		{head: "cat *", start: 4, candidates: ""},
+	}
+
+	for _, test := range tests {
+		start, candidates := shell.complete(test.head)
+		if start != test.start || strings.Join(candidates, " ") != test.candidates {
+			t.Errorf("complete(%q) = %d, %q, want %d, %q", test.head, start, candidates, test.start, test.candidates)
+		}
+	}
+}
+
+func TestShellVirtualFileBuiltins(t *testing.T) {
+	shell, err := NewShell(nil)
+	if err != nil {
+		t.Fatalf("Failed to create shell: %v", err)
+	}
+	var stdout bytes.Buffer
+	shell.vfs.SetStreams(nil, &stdout, &stdout)
+
+	script := "echo hello > a.txt; echo hi > b.txt\n" +
+		"vls; vls -l '*.txt'; vcat a.txt; vcat a.txt; vstat b.txt\n" +
+		"vrm a.txt; vls; vrm a.txt || echo gone"
+	if err := shell.Execute(script); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	want := "a.txt\nb.txt\n" +
+		"virtual        6 a.txt\nvirtual        3 b.txt\n" +
+		"hello\nhello\nb.txt: virtual file, 3 bytes\n" +
+		"b.txt\ngone\n"
+	if stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestShellLocks(t *testing.T) {
+	shell, err := NewShell(nil)
+	if err != nil {
+		t.Fatalf("Failed to create shell: %v", err)
+	}
+	var stdout, stderr bytes.Buffer
+	shell.vfs.SetStreams(nil, &stdout, &stderr)
+
+	script := "add() { vlock report; echo job >> report; vunlock report; }\n" +
+		"vlock report; vlock -n report && echo relocked\n" +
+		"add &\necho main >> report\n" +
+		"vlock -n report & wait %2; echo $?\n" +
+		"vlock -s -w 0.02 report & wait %3; echo $?\n" +
+		"vunlock report; wait %1; vcat report\n" +
+		"vlock -s report & wait %4; vlock -n report; echo $?"
+	if err := shell.Execute(script); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	want := "relocked\n1\n1\nmain\njob\n0\n"
+	if stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+	if !strings.Contains(stderr.String(), "vlock: report: locked by another shell") {
+		t.Errorf("stderr = %q, want a busy lock error", stderr.String())
+	}
+}
+
+func TestShellFind(t *testing.T) {
+	shell, err := NewShell(nil)
+	if err != nil {
+		t.Fatalf("Failed to create shell: %v", err)
+	}
+	setup := "echo hello > notes.txt; printf '' > empty.log; echo one > logs/app.log; echo two > logs/old/APP.LOG"
+	if err := shell.Execute(setup); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	base := time.Now()
+	for i, name := range []string{"logs/old/APP.LOG", "notes.txt", "empty.log", "logs/app.log"} {
+		shell.vfs.files[name].modTime = base.Add(time.Duration(i) * time.Second)
+	}
+	shell.vfs.files["logs/old/APP.LOG"].modTime = base.Add(-2 * time.Hour)
+
+	tests := []struct {
+		args string
+		want string
+	}{
+		{"", "empty.log\nlogs\nlogs/app.log\nlogs/old\nlogs/old/APP.LOG\nnotes.txt\n"},
+		{". -name '*.log'", "empty.log\nlogs/app.log\n"},
+		{"-iname '*.log' -type f", "empty.log\nlogs/app.log\nlogs/old/APP.LOG\n"},
+		{"logs -type d", "logs\nlogs/old\n"},
+		{"logs -maxdepth 1", "logs\nlogs/app.log\nlogs/old\n"},
+		{"-mindepth 2 -path 'logs/*'", "logs/app.log\nlogs/old\nlogs/old/APP.LOG\n"},
+		{"-empty -o -size +0c -name '*.txt'", "empty.log\nnotes.txt\n"},
+		{"-type f -size -1", "empty.log\n"},
+		{"-newer notes.txt", "empty.log\nlogs\nlogs/app.log\n"},
+		{"! '(' -type d -o -name '*.log' ')'", "logs/old/APP.LOG\nnotes.txt\n"},
+		{"-name notes.txt -print -print", "notes.txt\nnotes.txt\n"},
+		{"-mmin +60", "logs/old\nlogs/old/APP.LOG\n"},
+		{"-type f -mmin -60 -name '*.log'", "empty.log\nlogs/app.log\n"},
+		{"-mtime 0 -name '*.txt'", "notes.txt\n"},
+		{"-perm 644", "empty.log\nlogs/app.log\nlogs/old/APP.LOG\nnotes.txt\n"},
+		{"-perm /111", "logs\nlogs/old\n"},
+		{"-perm -640 -name '*.txt'", "notes.txt\n"},
+	}
+	for _, test := range tests {
+		var stdout bytes.Buffer
+		shell.vfs.SetStreams(nil, &stdout, &stdout)
+		if err := shell.Execute("find " + test.args); err != nil {
+			t.Errorf("find %s failed: %v", test.args, err)
+			continue
+		}
+		if stdout.String() != test.want {
+			t.Errorf("find %s = %q, want %q", test.args, stdout.String(), test.want)
+		}
+	}
+
+	for _, args := range []string{"missing", "-type x", "-size 1q", "-name", "-bogus", "'(' -name x", "-mmin x", "-perm u+x"} {
+		if err := shell.Execute("find " + args); err == nil {
+			t.Errorf("find %s succeeded, want an error", args)
+		}
+	}
+}
+
+func TestShellSplitWritesVirtualFiles(t *testing.T) {
+	shell, err := NewShell(nil)
+	if err != nil {
+		t.Fatalf("Failed to create shell: %v", err)
+	}
+	var stdout bytes.Buffer
+	shell.vfs.SetStreams(nil, &stdout, &stdout)
+
+	// Files can be read and appended to after the redirection that wrote
+	// them is closed
+	script := "printf '1\\n2\\n3\\n' > nums; echo 4 >> nums\n" +
+		"split -l 3 nums part.; wc -l < part.aa; cat part.ab"
+	if err := shell.Execute(script); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if want := "part.aa\npart.ab\n3\n4\n"; stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestShellFusesSortUniq(t *testing.T) {
+	tests := []struct {
+		script string
+		stdout string
+	}{
+		{"printf 'b\\na\\nB\\nb\\n' | sort -f | uniq -ci | sort -r", "   3 B\n   1 a\n"},
+		{"printf 'x\\ny\\nx\\n' | sort | uniq -d", "x\n"},
+		{"uniq() { echo shadowed; }; printf 'a\\na\\n' | sort | uniq", "shadowed\n"},
+		{"printf 'a\\na\\n' | sort | uniq > out; vcat out", "a\n"},
+		{"set -o timing; printf 'a\\n' | sort | uniq -c; times | grep -c 'sort|uniq'", "   1 a\n1\n"},
+	}
+
+	for _, test := range tests {
+		shell, err := NewShell(nil)
+		if err != nil {
+			t.Fatalf("Failed to create shell: %v", err)
+		}
+		var stdout bytes.Buffer
+		shell.vfs.SetStreams(nil, &stdout, io.Discard)
+		if err := shell.Execute(test.script); err != nil {
+			t.Fatalf("Execute(%q) failed: %v", test.script, err)
+		}
+		if stdout.String() != test.stdout {
+			t.Errorf("Execute(%q) output = %q, want %q", test.script, stdout.String(), test.stdout)
+		}
+	}
+}
+
+func TestShellJoinsVirtualFiles(t *testing.T) {
+	shell, err := NewShell(nil)
+	if err != nil {
+		t.Fatalf("Failed to create shell: %v", err)
+	}
+	var stdout bytes.Buffer
+	shell.vfs.SetStreams(nil, &stdout, &stdout)
+
+	script := "printf '1 alice\\n2 bob\\n' > names; printf '1 30\\n3 40\\n' > ages\n" +
+		"join -a1 names ages; printf '1\\n3\\n' > keys\n" +
+		"printf '1\\n2\\n' | comm -3 - keys; paste -d: names ages"
+	if err := shell.Execute(script); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	want := "1 alice 30\n2 bob\n2\n\t3\n1 alice:1 30\n2 bob:3 40\n"
+	if stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestShellDiffsVirtualFiles(t *testing.T) {
+	shell, err := NewShell(nil)
+	if err != nil {
+		t.Fatalf("Failed to create shell: %v", err)
+	}
+	var stdout bytes.Buffer
+	shell.vfs.SetStreams(nil, &stdout, &stdout)
+
+	script := "printf 'a\\nb\\n' > old/f; printf 'a\\nc\\n' > new/f; printf 'x\\n' > new/g\n" +
+		"diff old/f new/f; diff -rq old new; echo $?; diff old/f old/f && echo same"
+	if err := shell.Execute(script); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	want := "--- old/f\n+++ new/f\n@@ -1,2 +1,2 @@\n a\n-b\n+c\nFiles old/f and new/f differ\nOnly in new: g\n1\nsame\n"
+	if stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestShellPatchesVirtualFiles(t *testing.T) {
+	shell, err := NewShell(nil)
+	if err != nil {
+		t.Fatalf("Failed to create shell: %v", err)
+	}
+	var stdout bytes.Buffer
+	shell.vfs.SetStreams(nil, &stdout, &stdout)
+
+	script := "printf 'a\\nb\\n' > old/f; printf 'a\\nc\\n' > new/f\n" +
+		"diff old/f new/f > f.diff; printf 'z\\na\\nb\\n' > old/f\n" +
+		"patch -p0 < f.diff; vcat old/f"
+	if err := shell.Execute(script); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	want := "patching file old/f\nHunk #1 succeeded at 2 (offset 1 line).\nz\na\nc\n"
+	if stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestShellMergesVirtualFiles(t *testing.T) {
+	shell, err := NewShell(nil)
+	if err != nil {
+		t.Fatalf("Failed to create shell: %v", err)
+	}
+	var stdout bytes.Buffer
+	shell.vfs.SetStreams(nil, &stdout, &stdout)
+
+	script := "printf 'a\\nb\\nc\\n' > base; printf 'A\\nb\\nc\\n' > ours; printf 'a\\nb\\nC\\n' > theirs\n" +
+		"merge ours base theirs; vcat ours; printf 'X\\nb\\nc\\n' > other\n" +
+		"merge -p ours base other || echo conflict $?"
+	if err := shell.Execute(script); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	want := "A\nb\nC\n<<<<<<< ours\nA\n=======\nX\n>>>>>>> other\nb\nC\nconflict 1\n"
+	if stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestShellTarExtractsIntoVirtualFiles(t *testing.T) {
+	shell, err := NewShell(nil)
+	if err != nil {
+		t.Fatalf("Failed to create shell: %v", err)
+	}
+	var stdout bytes.Buffer
+	shell.vfs.SetStreams(nil, &stdout, &stdout)
+
+	var archive bytes.Buffer
+	writer := tar.NewWriter(&archive)
+	writer.WriteHeader(&tar.Header{Name: "logs/app.log", Mode: 0644, Size: 6})
+	writer.Write([]byte("ERROR\n"))
+	writer.Close()
+	file, err := shell.vfs.OpenForWrite("logs.tar", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write(archive.Bytes())
+	file.Close()
+
+	script := "tar -tf logs.tar; tar -xf logs.tar; vcat logs/app.log"
+	if err := shell.Execute(script); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if want := "logs/app.log\nERROR\n"; stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestShellAllowedCommands(t *testing.T) {
+	allowed := []string{"echo", "tr", "alias"}
+	tests := []struct {
+		script string
+		stdout string
+		err    string
+	}{
+		{script: "echo a | tr a A", stdout: "A\n"},
+		{script: "up() { tr a A; }; alias hi='echo hi'; hi; echo a | up", stdout: "hi\nA\n"},
+		{script: "echo before\necho a | sort", err: "line 2: sort: command not allowed"},
+		{script: "f() { vrm x; }\necho a", err: "line 1: vrm: command not allowed"},
+		{script: "C=sort; echo a; $C", stdout: "a\n", err: "line 1: sort: command not allowed"},
+		{script: "alias s=sort; echo b | s", err: "line 1: sort: command not allowed"},
+		{script: `echo "x$(echo y)"`, stdout: "xy\n"},
+		{script: `echo hi > a; echo "got:$(cat a)"`, err: "line 1: cat: command not allowed"},
+		{script: "echo a > $(sort)", err: "line 1: sort: command not allowed"},
+		{script: "echo $(( $(sort) + 1 ))", err: "line 1: sort: command not allowed"},
+		{script: "f() { echo $(echo $(vrm x)); }", err: "line 1: vrm: command not allowed"},
+	}
+
+	for _, test := range tests {
+		shell, err := NewShell(&Config{AllowedCommands: allowed})
+		if err != nil {
+			t.Fatalf("Failed to create shell: %v", err)
+		}
+		var stdout bytes.Buffer
+		shell.vfs.SetStreams(nil, &stdout, io.Discard)
+		err = shell.Execute(test.script)
+		if stdout.String() != test.stdout {
+			t.Errorf("Execute(%q) output = %q, want %q", test.script, stdout.String(), test.stdout)
+		}
+		if test.err == "" && err != nil {
+			t.Errorf("Execute(%q) failed: %v", test.script, err)
+		} else if test.err != "" && (err == nil || err.Error() != test.err) {
+			t.Errorf("Execute(%q) error = %v, want %q", test.script, err, test.err)
+		}
+	}
+
+	if err := Check("echo a | sort", allowed); err == nil {
+		t.Error("Check accepted a command that is not allowed")
+	}
+	if err := Check("echo a | sort", nil); err != nil {
+		t.Errorf("Check without a list = %v", err)
+	}
+}
+
+func TestShellProjectHistory(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, ProjectDir)
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(root, "src", "pkg")
+	if err := os.MkdirAll(sub, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if got := FindProjectDir(sub); got != dir {
+		t.Errorf("FindProjectDir = %q, want %q", got, dir)
+	}
+
+	now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local) }
+	defer func() { now = time.Now }()
+
+	var stdout bytes.Buffer
+	for _, script := range []string{"echo a | sort\necho b", "false"} {
+		shell, err := NewShell(&Config{HistoryDir: dir})
+		if err != nil {
+			t.Fatalf("Failed to create shell: %v", err)
+		}
+		shell.vfs.SetStreams(nil, &stdout, &stdout)
+		shell.Execute(script)
+	}
+
+	shell, err := NewShell(&Config{HistoryDir: dir})
+	if err != nil {
+		t.Fatalf("Failed to create shell: %v", err)
+	}
+	stdout.Reset()
+	shell.vfs.SetStreams(nil, &stdout, &stdout)
+	input := "echo x | tr x y\nhistory tr\nhistory -s sort\nhistory -s -n 1\nexit\n"
+	if err := shell.interact(strings.NewReader(input), io.Discard, filepath.Join(dir, projectHistoryFile)); err != nil {
+		t.Fatalf("interact failed: %v", err)
+	}
+	want := "y\n" +
+		"    1  echo x | tr x y\n    2  history tr\n" +
+		"#1 2026-01-02 03:04:05 status 0\n  echo a | sort\n  echo b\n" +
+		"#2 2026-01-02 03:04:05 status 1\n  false\n"
+	if stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+
+	// Interactive lines are not logged as scripts, but scripts see their history
+	shell, _ = NewShell(&Config{HistoryDir: dir})
+	stdout.Reset()
+	shell.vfs.SetStreams(nil, &stdout, &stdout)
+	shell.Execute("history -n 1 echo")
+	if want := "    1  echo x | tr x y\n"; stdout.String() != want {
+		t.Errorf("history in a script = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestShellTranscript(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "session.jsonl")
+	out, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	saved := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = saved }()
+
+	shell, err := NewShell(&Config{Transcript: path, Stdin: strings.NewReader("b\na\n")})
+	if err != nil {
+		t.Fatalf("Failed to create shell: %v", err)
+	}
+	script := "up() { tr ab AB; }\nsort | up\necho 'x y' > f.txt && false"
+	shell.Execute(script)
+	if err := shell.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var event transcriptEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("Bad transcript line %q: %v", line, err)
+		}
+		if event.Status != nil {
+			events = append(events, fmt.Sprintf("%s %d", event.Stream, *event.Status))
+		} else {
+			events = append(events, event.Stream+" "+event.Data)
+		}
+	}
+	want := []string{
+		"command up() { tr ab AB; }", "status 0",
+		"command sort | up", "stdin b\na\n", "stdout A\n", "stdout B\n", "status 0",
+		"command echo 'x y' > f.txt && false", "status 1",
+	}
+	if strings.Join(events, "|") != strings.Join(want, "|") {
+		t.Errorf("transcript events = %q, want %q", events, want)
+	}
+
+	replayed, stdin, err := ReadTranscript(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("ReadTranscript failed: %v", err)
+	}
+	if replayed != "up() { tr ab AB; }\nsort | up\necho 'x y' > f.txt && false" || stdin != "b\na\n" {
+		t.Errorf("ReadTranscript = %q, %q", replayed, stdin)
+	}
+	if err := Check(replayed, nil); err != nil {
+		t.Errorf("replayed script does not parse: %v", err)
+	}
+}
+
+func TestShellDirectories(t *testing.T) {
+	shell, err := NewShell(nil)
+	if err != nil {
+		t.Fatalf("Failed to create shell: %v", err)
+	}
+	var stdout bytes.Buffer
+	shell.vfs.SetStreams(nil, &stdout, &stdout)
+
+	script := "mkdir -p out/reports empty; echo sum > ./out/reports/../reports/summary.txt\n" +
+		"vls; vls out; vls -l out/reports; vcat /out/reports/summary.txt; vstat out\n" +
+		"mkdir out/reports || echo exists; mkdir a/b || echo no parent; echo x > out || echo is dir\n" +
+		"rmdir out/reports || echo not empty; find -type d -empty; rmdir empty; vls"
+	if err := shell.Execute(script); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	want := "empty\nout\n" +
+		"out/reports\n" +
+		"virtual        4 out/reports/summary.txt\n" +
+		"sum\nout: directory\n" +
+		"exists\nno parent\nis dir\n" +
+		"not empty\nempty\nout\n"
+	if stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestShellNamedPipes(t *testing.T) {
+	shell, err := NewShell(nil)
+	if err != nil {
+		t.Fatalf("Failed to create shell: %v", err)
+	}
+	var stdout, stderr bytes.Buffer
+	shell.vfs.SetStreams(nil, &stdout, &stderr)
+
+	script := "mkfifo feed; vstat -c '%F %A' feed; find -type p\n" +
+		"seq 3 > feed &\nsort -r < feed\n" +
+		"echo late > feed & wait; cat feed\n" +
+		"mkfifo feed || echo exists; vrm feed; vls"
+	if err := shell.Execute(script); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	want := "virtual fifo prw-r--r--\nfeed\n3\n2\n1\nlate\nexists\n"
+	if stdout.String() != want {
+		t.Errorf("output = %q, want %q (stderr %q)", stdout.String(), want, stderr.String())
+	}
+}
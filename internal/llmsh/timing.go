@@ -0,0 +1,113 @@
+package llmsh
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mako10k/llmcmd/internal/llmsh/parser"
+)
+
+// now returns the current time; tests replace it with a fake clock
+var now = time.Now
+
+// commandTiming is the time spent in one command over the session
+type commandTiming struct {
+	name  string
+	calls int
+	total time.Duration
+}
+
+// timingStats collects the run time of each command while set -o timing is
+// in effect. Subshells and background jobs share it with their shell.
+type timingStats struct {
+	mu       sync.Mutex
+	commands map[string]*commandTiming
+}
+
+// record adds a run of a command
+func (t *timingStats) record(name string, elapsed time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timing, exists := t.commands[name]
+	if !exists {
+		timing = &commandTiming{name: name}
+		t.commands[name] = timing
+	}
+	timing.calls++
+	timing.total += elapsed
+}
+
+// summary describes the time spent in each command, slowest first, or
+// returns "" when nothing was recorded
+func (t *timingStats) summary() string {
+	t.mu.Lock()
+	timings := make([]commandTiming, 0, len(t.commands))
+	for _, timing := range t.commands {
+		timings = append(timings, *timing)
+	}
+	t.mu.Unlock()
+	if len(timings) == 0 {
+		return ""
+	}
+	sort.Slice(timings, func(i, j int) bool {
+		if timings[i].total != timings[j].total {
+			return timings[i].total > timings[j].total
+		}
+		return timings[i].name < timings[j].name
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%6s %10s  %s\n", "calls", "total", "command")
+	for _, timing := range timings {
+		fmt.Fprintf(&b, "%6d %10s  %s\n", timing.calls, formatDuration(timing.total), timing.name)
+	}
+	return b.String()
+}
+
+// executeTimedPipeline runs a pipeline prefixed with time and reports its
+// run time on the shell's stderr, with the share of each command in it
+func (e *Executor) executeTimedPipeline(pipeline *parser.PipelineNode) error {
+	elapsed := make([]time.Duration, len(pipeline.Commands))
+	start := now()
+	err := e.runPipeline(pipeline, elapsed)
+	total := now().Sub(start)
+
+	untimed := *pipeline
+	untimed.Timed = false
+	var b strings.Builder
+	fmt.Fprintf(&b, "time: %s  %s\n", formatDuration(total), untimed.String())
+	if len(pipeline.Commands) > 1 {
+		for i, cmd := range pipeline.Commands {
+			share := 0.0
+			if total > 0 {
+				share = float64(elapsed[i]) * 100 / float64(total)
+			}
+			fmt.Fprintf(&b, "  %10s %3.0f%%  %s\n", formatDuration(elapsed[i]), share, cmd)
+		}
+	}
+	io.WriteString(e.errorStream(), b.String())
+	return err
+}
+
+// executeTimes prints the session's timing summary, as collected with
+// set -o timing
+func (e *Executor) executeTimes(stdout io.Writer) error {
+	summary := e.timings.summary()
+	if summary == "" {
+		if !e.options.timing {
+			return fmt.Errorf("times: no timings recorded; enable them with set -o timing")
+		}
+		return nil
+	}
+	_, err := io.WriteString(stdout, summary)
+	return err
+}
+
+// formatDuration formats a duration in seconds with millisecond precision
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
@@ -0,0 +1,120 @@
+package llmsh
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// transcriptEvent is one line of a session transcript, in JSON. Commands are
+// the top-level statements run, in a form llmsh parses back, so a session can
+// be replayed from its commands and the input it read.
+type transcriptEvent struct {
+	Time   time.Time `json:"time"`
+	Stream string    `json:"stream"` // "command", "stdin", "stdout", "stderr" or "status"
+	Data   string    `json:"data,omitempty"`
+	Status *int      `json:"status,omitempty"` // Exit status of the last command, for "status"
+}
+
+// transcript records a session in a file given with --transcript
+type transcript struct {
+	mu   sync.Mutex
+	file io.WriteCloser
+	enc  *json.Encoder
+	err  error // First write error; recording stops after it
+}
+
+// createTranscript creates or truncates the transcript file at path
+func createTranscript(path string) (*transcript, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("transcript: %w", err)
+	}
+	return &transcript{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// record adds an event
+func (t *transcript) record(event transcriptEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return
+	}
+	event.Time = now()
+	t.err = t.enc.Encode(event)
+}
+
+// recordStatus adds the exit status of a command
+func (t *transcript) recordStatus(status int) {
+	t.record(transcriptEvent{Stream: "status", Status: &status})
+}
+
+// Close closes the file, returning the first error met while recording
+func (t *transcript) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	err := t.file.Close()
+	if t.err != nil {
+		return fmt.Errorf("transcript: %w", t.err)
+	}
+	return err
+}
+
+// transcriptWriter copies what is written to a stream into the transcript
+type transcriptWriter struct {
+	t      *transcript
+	stream string
+	w      io.Writer
+}
+
+func (w transcriptWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if n > 0 {
+		w.t.record(transcriptEvent{Stream: w.stream, Data: string(p[:n])})
+	}
+	return n, err
+}
+
+// transcriptReader copies what is read from stdin into the transcript
+type transcriptReader struct {
+	t *transcript
+	r io.Reader
+}
+
+func (r transcriptReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.t.record(transcriptEvent{Stream: "stdin", Data: string(p[:n])})
+	}
+	return n, err
+}
+
+// ReadTranscript reads a session transcript back as the script it ran and
+// the input it read, to replay the session
+func ReadTranscript(r io.Reader) (script, stdin string, err error) {
+	var commands []string
+	var input strings.Builder
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var event transcriptEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return "", "", fmt.Errorf("transcript: line %d: %w", line, err)
+		}
+		switch event.Stream {
+		case "command":
+			commands = append(commands, event.Data)
+		case "stdin":
+			input.WriteString(event.Data)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", fmt.Errorf("transcript: %w", err)
+	}
+	return strings.Join(commands, "\n"), input.String(), nil
+}
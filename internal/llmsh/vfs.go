@@ -5,38 +5,62 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
+	"sort"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/mako10k/llmcmd/internal/vfsproxy"
 )
 
 // VirtualFileSystem manages virtual files and pipes for llmsh
 type VirtualFileSystem struct {
 	mu sync.RWMutex
 
-	// Virtual files (temporary named pipes)
+	// Virtual files written by redirections
 	files map[string]*VirtualFile
 
+	// Directories, made by mkdir or by writing a file in them. Names are
+	// relative to the root of the VFS, without a leading slash.
+	dirs map[string]bool
+
+	// Named pipes made by mkfifo; with a parent llmcmd they are made there
+	fifos map[string]*vfsproxy.Fifo
+
 	// Real files (stdin, stdout, stderr, input/output files)
 	realFiles map[string]io.ReadWriteCloser
 
 	// Allowed file access
 	inputFile  string
 	outputFile string
+
+	// Parent llmcmd VFS; when set, named files are read from and written to it
+	remote *vfsproxy.Client
+
+	// Advisory locks taken with vlock, kept by the parent when there is one
+	locks vfsproxy.LockTable
 }
 
 // VirtualFile represents a virtual file in memory
 type VirtualFile struct {
-	name   string
-	buffer *bytes.Buffer
-	closed bool
-	mu     sync.RWMutex
+	name    string
+	buffer  *bytes.Buffer
+	closed  bool
+	created time.Time
+	modTime time.Time // When it was created, truncated or last written
+	mu      sync.RWMutex
 }
 
 // NewVirtualFile creates a new virtual file
 func NewVirtualFile(name string) *VirtualFile {
+	now := time.Now()
 	return &VirtualFile{
-		name:   name,
-		buffer: &bytes.Buffer{},
-		closed: false,
+		name:    name,
+		buffer:  &bytes.Buffer{},
+		closed:  false,
+		created: now,
+		modTime: now,
 	}
 }
 
@@ -66,6 +90,7 @@ func (vf *VirtualFile) Write(p []byte) (n int, err error) {
 		return 0, fmt.Errorf("file %s is closed", vf.name)
 	}
 
+	vf.modTime = time.Now()
 	return vf.buffer.Write(p)
 }
 
@@ -78,10 +103,114 @@ func (vf *VirtualFile) Close() error {
 	return nil
 }
 
+// reopen makes a closed file usable again; a file is closed after each
+// redirection, and opening it again must still work
+func (vf *VirtualFile) reopen() {
+	vf.mu.Lock()
+	defer vf.mu.Unlock()
+	vf.closed = false
+}
+
+// remoteFile is a file of the parent VFS. Reads are served from a copy taken
+// at open; writes are buffered and sent to the parent on the first Close.
+type remoteFile struct {
+	name   string
+	client *vfsproxy.Client
+	buffer bytes.Buffer
+	append bool
+	dirty  bool
+	closed bool
+	mu     sync.Mutex
+}
+
+// Read reads from the copy of the remote file
+func (rf *remoteFile) Read(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.buffer.Read(p)
+}
+
+// Write buffers data for the remote file
+func (rf *remoteFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.closed {
+		return 0, fmt.Errorf("file %s is closed", rf.name)
+	}
+	rf.dirty = true
+	return rf.buffer.Write(p)
+}
+
+// Close sends buffered writes to the parent (once; 2>&1 closes it twice)
+func (rf *remoteFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.closed {
+		return nil
+	}
+	rf.closed = true
+	if !rf.dirty {
+		return nil
+	}
+	return rf.client.WriteFile(rf.name, rf.buffer.Bytes(), rf.append)
+}
+
+// streamFile adapts a caller's stream to the VFS's real files. Close leaves
+// the stream open; it belongs to the caller. Writes are serialized, since
+// background jobs share the streams.
+type streamFile struct {
+	reader io.Reader
+	writer io.Writer
+	mu     *sync.Mutex
+}
+
+// Read reads from the stream, or reports EOF when there is no input
+func (sf streamFile) Read(p []byte) (int, error) {
+	if sf.reader == nil {
+		return 0, io.EOF
+	}
+	return sf.reader.Read(p)
+}
+
+// Write writes to the stream
+func (sf streamFile) Write(p []byte) (int, error) {
+	if sf.writer == nil {
+		return 0, fmt.Errorf("stream is not writable")
+	}
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	return sf.writer.Write(p)
+}
+
+// Close does nothing
+func (sf streamFile) Close() error {
+	return nil
+}
+
+// SetStreams replaces the process's stdin, stdout and stderr, for scripts run in-process
+func (vfs *VirtualFileSystem) SetStreams(stdin io.Reader, stdout, stderr io.Writer) {
+	vfs.mu.Lock()
+	defer vfs.mu.Unlock()
+	vfs.realFiles["stdin"] = streamFile{reader: stdin}
+	// One lock for both, as stdout and stderr are often the same writer
+	mu := &sync.Mutex{}
+	vfs.realFiles["stdout"] = streamFile{writer: stdout, mu: mu}
+	vfs.realFiles["stderr"] = streamFile{writer: stderr, mu: mu}
+}
+
+// SetRemote makes named files resolve against the parent llmcmd VFS
+func (vfs *VirtualFileSystem) SetRemote(client *vfsproxy.Client) {
+	vfs.mu.Lock()
+	defer vfs.mu.Unlock()
+	vfs.remote = client
+}
+
 // NewVirtualFileSystem creates a new VFS
 func NewVirtualFileSystem(inputFile, outputFile string) *VirtualFileSystem {
 	vfs := &VirtualFileSystem{
 		files:      make(map[string]*VirtualFile),
+		dirs:       make(map[string]bool),
+		fifos:      make(map[string]*vfsproxy.Fifo),
 		realFiles:  make(map[string]io.ReadWriteCloser),
 		inputFile:  inputFile,
 		outputFile: outputFile,
@@ -99,6 +228,7 @@ func NewVirtualFileSystem(inputFile, outputFile string) *VirtualFileSystem {
 func (vfs *VirtualFileSystem) OpenForRead(filename string) (io.ReadCloser, error) {
 	vfs.mu.RLock()
 	defer vfs.mu.RUnlock()
+	filename = vfs.cleanName(filename)
 
 	// Check for real files first
 	if filename == "stdin" || filename == vfs.inputFile {
@@ -119,8 +249,29 @@ func (vfs *VirtualFileSystem) OpenForRead(filename string) (io.ReadCloser, error
 
 	// Check for virtual files
 	if vfile, exists := vfs.files[filename]; exists {
+		vfile.reopen()
 		return vfile, nil
 	}
+	if fifo, exists := vfs.fifos[filename]; exists {
+		return fifo.OpenReader(), nil
+	}
+	if vfs.isDirLocked(filename) {
+		return nil, fmt.Errorf("%s: is a directory", vfs.displayName(filename))
+	}
+
+	if remote := vfs.remote; remote != nil {
+		// A parent named pipe is read once its writers are done; other
+		// commands may use the VFS meanwhile
+		vfs.mu.RUnlock()
+		defer vfs.mu.RLock()
+		data, err := remote.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		file := &remoteFile{name: filename, client: remote}
+		file.buffer.Write(data)
+		return file, nil
+	}
 
 	return nil, fmt.Errorf("file not found: %s", filename)
 }
@@ -129,6 +280,7 @@ func (vfs *VirtualFileSystem) OpenForRead(filename string) (io.ReadCloser, error
 func (vfs *VirtualFileSystem) OpenForWrite(filename string, append bool) (io.WriteCloser, error) {
 	vfs.mu.Lock()
 	defer vfs.mu.Unlock()
+	filename = vfs.cleanName(filename)
 
 	// Check for real files first
 	if filename == "stdout" || filename == "stderr" || filename == vfs.outputFile {
@@ -156,31 +308,48 @@ func (vfs *VirtualFileSystem) OpenForWrite(filename string, append bool) (io.Wri
 		}
 	}
 
+	if err := vfs.makeParentsLocked(filename); err != nil {
+		return nil, err
+	}
+	if fifo, exists := vfs.fifos[filename]; exists {
+		return fifo.OpenWriter(), nil
+	}
+
+	if vfs.remote != nil {
+		if _, exists := vfs.files[filename]; !exists {
+			file := &remoteFile{name: filename, client: vfs.remote, append: append}
+			if !append {
+				// Create the file even if nothing is written, like > does
+				file.dirty = true
+			}
+			return file, nil
+		}
+	}
+
 	// Create or get virtual file
 	vfile, exists := vfs.files[filename]
 	if !exists {
 		vfile = NewVirtualFile(filename)
 		vfs.files[filename] = vfile
-	} else if !append {
-		// Truncate if not appending
-		vfile.buffer.Reset()
+	} else {
+		vfile.reopen()
+		if !append {
+			// Truncate if not appending
+			vfile.mu.Lock()
+			vfile.buffer.Reset()
+			vfile.modTime = time.Now()
+			vfile.mu.Unlock()
+		}
 	}
 
 	return vfile, nil
 }
 
-// CreatePipe creates a virtual pipe between two commands
-func (vfs *VirtualFileSystem) CreatePipe() (io.ReadCloser, io.WriteCloser, error) {
-	pipeName := fmt.Sprintf("pipe_%d", len(vfs.files))
-	vfile := NewVirtualFile(pipeName)
-
-	vfs.mu.Lock()
-	vfs.files[pipeName] = vfile
-	vfs.mu.Unlock()
-
-	// Return the same file for both read and write
-	// VirtualFile implements both ReadCloser and WriteCloser
-	return vfile, vfile, nil
+// CreatePipe creates a bounded pipe between two commands. Pipes are
+// anonymous: they are closed by the pipeline and never globbed.
+func (vfs *VirtualFileSystem) CreatePipe() (io.ReadWriteCloser, io.ReadWriteCloser, error) {
+	reader, writer := newPipe()
+	return reader, writer, nil
 }
 
 // ListFiles returns a list of all virtual files
@@ -196,6 +365,316 @@ func (vfs *VirtualFileSystem) ListFiles() []string {
 	return files
 }
 
+// Glob returns the names of the files matching a path.Match pattern, sorted:
+// virtual files, the allowed input and output files, and the files of the
+// parent llmcmd VFS. It returns no names for a malformed pattern.
+func (vfs *VirtualFileSystem) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, nil
+	}
+	return vfs.names(pattern, true)
+}
+
+// Names returns the names of all files, sorted, including names with
+// slashes that no single pattern matches
+func (vfs *VirtualFileSystem) Names() ([]string, error) {
+	return vfs.names("", false)
+}
+
+// names lists the files matching a pattern, or all files for "", and with
+// withDirs the directories too. Virtual names are matched against the
+// pattern with . and .. resolved; the real input and output files against
+// the pattern as given.
+func (vfs *VirtualFileSystem) names(pattern string, withDirs bool) ([]string, error) {
+	vfs.mu.RLock()
+	var candidates []string
+	for name := range vfs.files {
+		candidates = append(candidates, name)
+	}
+	for name := range vfs.fifos {
+		candidates = append(candidates, name)
+	}
+	if withDirs {
+		for dir := range vfs.dirs {
+			candidates = append(candidates, dir)
+		}
+	}
+	real := []string{vfs.inputFile, vfs.outputFile}
+	remote := vfs.remote
+	virtualPattern := pattern
+	if pattern != "" {
+		virtualPattern = vfs.cleanName(pattern)
+	}
+	vfs.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var matches []string
+	add := func(pattern, name string) {
+		if ok, _ := path.Match(pattern, name); (ok || pattern == "") && name != "" && !seen[name] {
+			seen[name] = true
+			matches = append(matches, name)
+		}
+	}
+	for _, name := range real {
+		add(pattern, name)
+	}
+	for _, name := range candidates {
+		add(virtualPattern, name)
+	}
+	if remote != nil {
+		names, err := remote.ListFiles(virtualPattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				matches = append(matches, name)
+			}
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// virtualFileMode is the permissions of llmsh's own virtual files
+const virtualFileMode os.FileMode = 0644
+
+// FileInfo describes a file visible to llmsh
+type FileInfo struct {
+	Name    string
+	Kind    string // "virtual", "input", "output", "parent" or "directory"
+	Size    int64
+	Mode    os.FileMode // Permissions, with os.ModeDir for directories and os.ModeNamedPipe for named pipes
+	ModTime time.Time   // Zero when not known: for directories, and parents that do not track times
+	Created time.Time   // Zero when not known, as for the real input and output files
+}
+
+// Stat describes a file without reading it. Files are looked up in the same
+// order as OpenForRead.
+func (vfs *VirtualFileSystem) Stat(name string) (FileInfo, error) {
+	vfs.mu.RLock()
+	name = vfs.cleanName(name)
+	vfile, isVirtual := vfs.files[name]
+	fifo, isFifo := vfs.fifos[name]
+	isDir := !isVirtual && vfs.isDirLocked(name)
+	remote := vfs.remote
+	vfs.mu.RUnlock()
+
+	switch {
+	case name != "" && name == vfs.inputFile:
+		return vfs.statReal(name, "input")
+	case isFifo:
+		return FileInfo{Name: name, Kind: "virtual", Size: int64(fifo.Buffered()), Mode: os.ModeNamedPipe | virtualFileMode}, nil
+	case isVirtual:
+		vfile.mu.RLock()
+		defer vfile.mu.RUnlock()
+		return FileInfo{Name: name, Kind: "virtual", Size: int64(vfile.buffer.Len()), Mode: virtualFileMode, ModTime: vfile.modTime, Created: vfile.created}, nil
+	case name != "" && name == vfs.outputFile:
+		return vfs.statReal(name, "output")
+	case isDir:
+		return dirInfo(vfs.displayName(name)), nil
+	case remote != nil:
+		stat, err := remote.Stat(name)
+		if err != nil {
+			// The parent has no directories of its own, only files named dir/...
+			if names, listErr := remote.ListFiles(""); listErr == nil {
+				for _, file := range names {
+					if strings.HasPrefix(file, name+"/") {
+						return dirInfo(vfs.displayName(name)), nil
+					}
+				}
+			}
+			return FileInfo{}, err
+		}
+		return FileInfo{Name: name, Kind: "parent", Size: stat.Size, Mode: stat.Mode, ModTime: stat.ModTime, Created: stat.Created}, nil
+	default:
+		return FileInfo{}, fmt.Errorf("file not found: %s", name)
+	}
+}
+
+// dirInfo describes a directory, which has no size or times of its own
+func dirInfo(name string) FileInfo {
+	return FileInfo{Name: name, Kind: "directory", Mode: os.ModeDir | 0755}
+}
+
+// statReal describes the input or output file. The output file may not have
+// been written yet, in which case it is empty.
+func (vfs *VirtualFileSystem) statReal(name, kind string) (FileInfo, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		if kind == "output" && os.IsNotExist(err) {
+			return FileInfo{Name: name, Kind: kind, Mode: 0644}, nil
+		}
+		return FileInfo{}, err
+	}
+	return FileInfo{Name: name, Kind: kind, Size: info.Size(), Mode: info.Mode().Perm(), ModTime: info.ModTime()}, nil
+}
+
+// Contents returns the contents of a file. Unlike reading it through
+// OpenForRead, this leaves a virtual file's contents in place; files of the
+// parent VFS are read with GET and so are consumed there as usual.
+func (vfs *VirtualFileSystem) Contents(name string) ([]byte, error) {
+	vfs.mu.RLock()
+	name = vfs.cleanName(name)
+	vfile, isVirtual := vfs.files[name]
+	fifo, isFifo := vfs.fifos[name]
+	isDir := !isVirtual && vfs.isDirLocked(name)
+	remote := vfs.remote
+	vfs.mu.RUnlock()
+
+	switch {
+	case name != "" && name == vfs.inputFile:
+		return os.ReadFile(name)
+	case isFifo:
+		// What is read from a pipe is gone, so it cannot be left in place
+		reader := fifo.OpenReader()
+		defer reader.Close()
+		return io.ReadAll(reader)
+	case isVirtual:
+		vfile.mu.RLock()
+		defer vfile.mu.RUnlock()
+		return bytes.Clone(vfile.buffer.Bytes()), nil
+	case name != "" && name == vfs.outputFile:
+		return os.ReadFile(name)
+	case isDir:
+		return nil, fmt.Errorf("%s: is a directory", vfs.displayName(name))
+	case remote != nil:
+		return remote.ReadFile(name)
+	default:
+		return nil, fmt.Errorf("file not found: %s", name)
+	}
+}
+
+// Remove deletes a virtual file or a file of the parent VFS. The input and
+// output files are real files and cannot be removed.
+func (vfs *VirtualFileSystem) Remove(name string) error {
+	vfs.mu.Lock()
+	name = vfs.cleanName(name)
+	if name != "" && (name == vfs.inputFile || name == vfs.outputFile) {
+		vfs.mu.Unlock()
+		return fmt.Errorf("cannot remove real file %s", name)
+	}
+	if vfile, exists := vfs.files[name]; exists {
+		delete(vfs.files, name)
+		vfs.mu.Unlock()
+		return vfile.Close()
+	}
+	if _, exists := vfs.fifos[name]; exists {
+		// Ends still open keep working
+		delete(vfs.fifos, name)
+		vfs.mu.Unlock()
+		return nil
+	}
+	if vfs.isDirLocked(name) {
+		vfs.mu.Unlock()
+		return fmt.Errorf("%s: is a directory; remove it with rmdir", vfs.displayName(name))
+	}
+	remote := vfs.remote
+	vfs.mu.Unlock()
+
+	if remote != nil {
+		return remote.RemoveFile(name)
+	}
+	return fmt.Errorf("file not found: %s", name)
+}
+
+// Clone makes dst a copy of src without consuming src. When both are files
+// of the parent VFS the parent copies it, sharing the data until one of them
+// is written; otherwise src is read as vcat reads it and written to dst.
+func (vfs *VirtualFileSystem) Clone(src, dst string) error {
+	vfs.mu.RLock()
+	src, dst = vfs.cleanName(src), vfs.cleanName(dst)
+	_, srcLocal := vfs.files[src]
+	_, dstLocal := vfs.files[dst]
+	local := func(name string) bool {
+		return name == "" || name == "stdin" || name == "stdout" || name == "stderr" ||
+			name == vfs.inputFile || name == vfs.outputFile || vfs.isDirLocked(name)
+	}
+	parentOnly := !srcLocal && !dstLocal && !local(src) && !local(dst)
+	remote := vfs.remote
+	vfs.mu.RUnlock()
+
+	if remote != nil && parentOnly {
+		return remote.CloneFile(src, dst)
+	}
+	if src == dst {
+		return fmt.Errorf("%s: cannot copy a file onto itself", src)
+	}
+	data, err := vfs.Contents(src)
+	if err != nil {
+		return err
+	}
+	file, err := vfs.OpenForWrite(dst, false)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Mkfifo makes a named pipe, which commands open by name: writers' output
+// waits in it until a reader takes it, and readers see EOF once every writer
+// has closed it. With a parent llmcmd the pipe is made there, so scripts
+// spawned separately can stream data to each other through it.
+func (vfs *VirtualFileSystem) Mkfifo(name string) error {
+	vfs.mu.Lock()
+	name = vfs.cleanName(name)
+	_, isVirtual := vfs.files[name]
+	_, isFifo := vfs.fifos[name]
+	if name == "" || name == "stdin" || name == "stdout" || name == "stderr" ||
+		name == vfs.inputFile || name == vfs.outputFile || isVirtual || isFifo || vfs.isDirLocked(name) {
+		vfs.mu.Unlock()
+		return fmt.Errorf("%s: %w", vfs.displayName(name), os.ErrExist)
+	}
+	if err := vfs.makeParentsLocked(name); err != nil {
+		vfs.mu.Unlock()
+		return err
+	}
+	remote := vfs.remote
+	if remote == nil {
+		vfs.fifos[name] = vfsproxy.NewFifo()
+	}
+	vfs.mu.Unlock()
+
+	if remote != nil {
+		return remote.MakeFifo(name, virtualFileMode)
+	}
+	return nil
+}
+
+// TryLock takes or converts owner's advisory lock on a name without waiting,
+// and reports whether it was granted. With a parent llmcmd the lock is taken
+// there, so scripts spawned separately see each other's locks.
+func (vfs *VirtualFileSystem) TryLock(name, owner string, exclusive bool) (bool, error) {
+	vfs.mu.RLock()
+	name = vfs.cleanName(name)
+	remote := vfs.remote
+	vfs.mu.RUnlock()
+
+	if remote != nil {
+		return remote.TryLock(name, owner, exclusive)
+	}
+	return vfs.locks.TryLock(name, owner, exclusive), nil
+}
+
+// Unlock releases owner's advisory lock on a name
+func (vfs *VirtualFileSystem) Unlock(name, owner string) error {
+	vfs.mu.RLock()
+	name = vfs.cleanName(name)
+	remote := vfs.remote
+	vfs.mu.RUnlock()
+
+	if remote != nil {
+		return remote.Unlock(name, owner)
+	}
+	vfs.locks.Unlock(name, owner)
+	return nil
+}
+
 // CleanUp closes and removes all virtual files
 func (vfs *VirtualFileSystem) CleanUp() error {
 	vfs.mu.Lock()
@@ -206,6 +685,7 @@ func (vfs *VirtualFileSystem) CleanUp() error {
 	}
 
 	vfs.files = make(map[string]*VirtualFile)
+	vfs.dirs = make(map[string]bool)
 
 	// Close real files (except std streams)
 	for name, file := range vfs.realFiles {
@@ -216,3 +696,119 @@ func (vfs *VirtualFileSystem) CleanUp() error {
 
 	return nil
 }
+
+// cleanName resolves . and .. in a virtual file name, so ./out/a.txt and
+// out/tmp/../a.txt name out/a.txt. A leading slash is the root of the VFS,
+// and .. stops there. The streams and the real input and output files keep
+// their names. vfs.mu must be held.
+func (vfs *VirtualFileSystem) cleanName(name string) string {
+	switch name {
+	case "", "stdin", "stdout", "stderr", vfs.inputFile, vfs.outputFile:
+		return name
+	}
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// displayName is how errors name a virtual file: the root is /
+func (vfs *VirtualFileSystem) displayName(name string) string {
+	if name == "" {
+		return "/"
+	}
+	return name
+}
+
+// isDirLocked reports whether name is a directory; vfs.mu must be held
+func (vfs *VirtualFileSystem) isDirLocked(name string) bool {
+	return name == "" || vfs.dirs[name]
+}
+
+// makeParentsLocked makes the directories a file is written in, as if by
+// mkdir -p; vfs.mu must be held for writing
+func (vfs *VirtualFileSystem) makeParentsLocked(name string) error {
+	if name == "" || vfs.isDirLocked(name) {
+		return fmt.Errorf("%s: is a directory", vfs.displayName(name))
+	}
+	if path.IsAbs(name) {
+		return nil // The real output file
+	}
+	var parents []string
+	for dir := path.Dir(name); dir != "." && !vfs.dirs[dir]; dir = path.Dir(dir) {
+		if _, exists := vfs.files[dir]; exists {
+			return fmt.Errorf("%s: not a directory", dir)
+		}
+		parents = append(parents, dir)
+	}
+	for _, dir := range parents {
+		vfs.dirs[dir] = true
+	}
+	return nil
+}
+
+// Mkdir makes a directory. Its parent must exist unless parents is set, in
+// which case they are made too and an existing directory is not an error.
+func (vfs *VirtualFileSystem) Mkdir(name string, parents bool) error {
+	vfs.mu.Lock()
+	defer vfs.mu.Unlock()
+	name = vfs.cleanName(name)
+	if vfs.isDirLocked(name) {
+		if parents {
+			return nil
+		}
+		return fmt.Errorf("%s: file exists", vfs.displayName(name))
+	}
+	if _, exists := vfs.files[name]; exists || name == vfs.inputFile || name == vfs.outputFile {
+		return fmt.Errorf("%s: file exists", name)
+	}
+	if parent := path.Dir(name); !parents && parent != "." && !vfs.dirs[parent] {
+		if _, exists := vfs.files[parent]; exists {
+			return fmt.Errorf("%s: not a directory", parent)
+		}
+		return fmt.Errorf("%s: no such directory", parent)
+	}
+	if err := vfs.makeParentsLocked(name); err != nil {
+		return err
+	}
+	vfs.dirs[name] = true
+	return nil
+}
+
+// Rmdir removes an empty directory
+func (vfs *VirtualFileSystem) Rmdir(name string) error {
+	vfs.mu.Lock()
+	defer vfs.mu.Unlock()
+	name = vfs.cleanName(name)
+	switch {
+	case name == "":
+		return fmt.Errorf("/: cannot remove the root directory")
+	case !vfs.dirs[name]:
+		if _, exists := vfs.files[name]; exists {
+			return fmt.Errorf("%s: not a directory", name)
+		}
+		return fmt.Errorf("%s: no such directory", name)
+	}
+	prefix := name + "/"
+	for file := range vfs.files {
+		if strings.HasPrefix(file, prefix) {
+			return fmt.Errorf("%s: directory not empty", name)
+		}
+	}
+	for dir := range vfs.dirs {
+		if strings.HasPrefix(dir, prefix) {
+			return fmt.Errorf("%s: directory not empty", name)
+		}
+	}
+	delete(vfs.dirs, name)
+	return nil
+}
+
+// Dirs returns the directories, sorted
+func (vfs *VirtualFileSystem) Dirs() []string {
+	vfs.mu.RLock()
+	defer vfs.mu.RUnlock()
+	dirs := make([]string, 0, len(vfs.dirs))
+	for dir := range vfs.dirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
@@ -0,0 +1,292 @@
+package llmsh
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// vfsBuiltins are the builtins that manage the virtual file system
+var vfsBuiltins = []string{"vls", "vcat", "vcp", "vrm", "vstat", "find", "mkdir", "rmdir", "mkfifo"}
+
+// executeVLs lists the files matching the patterns, the top directory by
+// default; a directory lists what is in it. With -l each name is preceded by
+// its kind and size.
+func (c *Commands) executeVLs(args []string, stdout io.ReadWriteCloser) error {
+	long := false
+	if len(args) > 0 && args[0] == "-l" {
+		long = true
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		args = []string{"*"}
+	}
+
+	var firstErr error
+	for _, pattern := range args {
+		listing := pattern == "*"
+		if info, err := c.vfs.Stat(pattern); err == nil && info.Kind == "directory" {
+			// info.Name is the directory with . and .. resolved, / for the root
+			listing, pattern = true, "*"
+			if info.Name != "/" {
+				pattern = escapeGlob(info.Name) + "/*"
+			}
+		}
+		names, err := c.vfs.Glob(pattern)
+		if err == nil && len(names) == 0 && !listing {
+			err = fmt.Errorf("no such file")
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("vls: %s: %w", pattern, err)
+			}
+			continue
+		}
+		for _, name := range names {
+			line := name
+			if long {
+				info, err := c.vfs.Stat(name)
+				if err != nil {
+					if firstErr == nil {
+						firstErr = fmt.Errorf("vls: %s: %w", name, err)
+					}
+					continue
+				}
+				line = fmt.Sprintf("%-7s %8d %s", info.Kind, info.Size, name)
+			}
+			if _, err := fmt.Fprintln(stdout, line); err != nil {
+				return err
+			}
+		}
+	}
+	return firstErr
+}
+
+// executeVCat prints files like cat, but leaves virtual files unread so they
+// can still be used afterwards
+func (c *Commands) executeVCat(args []string, stdout io.ReadWriteCloser) error {
+	if len(args) == 0 {
+		return fmt.Errorf("vcat: missing file name")
+	}
+	var firstErr error
+	for _, name := range args {
+		data, err := c.vfs.Contents(name)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("vcat: %s: %w", name, err)
+			}
+			continue
+		}
+		if _, err := stdout.Write(data); err != nil {
+			return fmt.Errorf("vcat: %s: %w", name, err)
+		}
+	}
+	return firstErr
+}
+
+// executeVCp copies a file without consuming it
+func (c *Commands) executeVCp(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("vcp: usage: vcp source destination")
+	}
+	if err := c.vfs.Clone(args[0], args[1]); err != nil {
+		return fmt.Errorf("vcp: %s: %w", args[0], err)
+	}
+	return nil
+}
+
+// executeVRm removes files, going on after one that cannot be removed
+func (c *Commands) executeVRm(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("vrm: missing file name")
+	}
+	var firstErr error
+	for _, name := range args {
+		if err := c.vfs.Remove(name); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("vrm: %s: %w", name, err)
+		}
+	}
+	return firstErr
+}
+
+// executeVStat prints the kind and size of files, or that they are
+// directories; with -c FORMAT it prints the fields FORMAT asks for instead
+func (c *Commands) executeVStat(args []string, stdout io.ReadWriteCloser) error {
+	format := ""
+	if len(args) > 0 && (args[0] == "-c" || strings.HasPrefix(args[0], "--format=")) {
+		if args[0] == "-c" {
+			if len(args) < 2 {
+				return fmt.Errorf("vstat: option requires an argument -- 'c'")
+			}
+			format, args = args[1], args[2:]
+		} else {
+			format, args = strings.TrimPrefix(args[0], "--format="), args[1:]
+		}
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("vstat: missing file name")
+	}
+	var firstErr error
+	for _, name := range args {
+		info, err := c.vfs.Stat(name)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("vstat: %s: %w", name, err)
+			}
+			continue
+		}
+		if format != "" {
+			_, err = fmt.Fprintln(stdout, formatStat(format, name, info))
+		} else if info.Kind == "directory" {
+			_, err = fmt.Fprintf(stdout, "%s: directory\n", name)
+		} else {
+			_, err = fmt.Fprintf(stdout, "%s: %s, %d bytes\n", name, fileType(info), info.Size)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return firstErr
+}
+
+// formatStat expands the directives of a vstat -c format, as stat -c does:
+// %n name, %s size, %F kind, %a octal and %A symbolic permissions, %y and %Y
+// modification time, %w and %W creation time, %% a percent sign. Times that
+// are not known print as - and 0.
+func formatStat(format, name string, info FileInfo) string {
+	human := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format("2006-01-02 15:04:05.000000000 -0700")
+	}
+	epoch := func(t time.Time) string {
+		if t.IsZero() {
+			return "0"
+		}
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'n':
+			b.WriteString(name)
+		case 's':
+			b.WriteString(strconv.FormatInt(info.Size, 10))
+		case 'F':
+			if info.Kind == "directory" {
+				b.WriteString("directory")
+			} else {
+				b.WriteString(fileType(info))
+			}
+		case 'a':
+			b.WriteString(strconv.FormatUint(uint64(info.Mode.Perm()), 8))
+		case 'A':
+			b.WriteString(info.Mode.String())
+		case 'y':
+			b.WriteString(human(info.ModTime))
+		case 'Y':
+			b.WriteString(epoch(info.ModTime))
+		case 'w':
+			b.WriteString(human(info.Created))
+		case 'W':
+			b.WriteString(epoch(info.Created))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(format[i])
+		}
+	}
+	return b.String()
+}
+
+// fileType describes a file that is not a directory by its kind and type, as
+// in "virtual file" or "parent fifo"
+func fileType(info FileInfo) string {
+	if info.Mode&os.ModeNamedPipe != 0 {
+		return info.Kind + " fifo"
+	}
+	return info.Kind + " file"
+}
+
+// executeMkdir makes directories; with -p their parents too, and existing
+// directories are not an error
+func (c *Commands) executeMkdir(args []string) error {
+	parents := false
+	if len(args) > 0 && (args[0] == "-p" || args[0] == "--parents") {
+		parents = true
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("mkdir: missing operand")
+	}
+	var firstErr error
+	for _, name := range args {
+		if err := c.vfs.Mkdir(name, parents); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("mkdir: %w", err)
+		}
+	}
+	return firstErr
+}
+
+// executeRmdir removes empty directories
+func (c *Commands) executeRmdir(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("rmdir: missing operand")
+	}
+	var firstErr error
+	for _, name := range args {
+		if err := c.vfs.Rmdir(name); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("rmdir: %w", err)
+		}
+	}
+	return firstErr
+}
+
+// executeMkfifo makes named pipes
+func (c *Commands) executeMkfifo(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("mkfifo: missing operand")
+	}
+	var firstErr error
+	for _, name := range args {
+		if err := c.vfs.Mkfifo(name); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("mkfifo: %w", err)
+		}
+	}
+	return firstErr
+}
+
+// vfsFiles gives builtins that take file operands, such as tar, split,
+// sha256sum and diff, access to the VFS. Files are read like vcat reads, so
+// an archive can be listed and then extracted.
+type vfsFiles struct {
+	vfs *VirtualFileSystem
+}
+
+func (f vfsFiles) Open(name string) (io.ReadCloser, error) {
+	data, err := f.vfs.Contents(name)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (f vfsFiles) Create(name string) (io.WriteCloser, error) {
+	return f.vfs.OpenForWrite(name, false)
+}
+
+func (f vfsFiles) List() ([]string, error) {
+	return f.vfs.Names()
+}
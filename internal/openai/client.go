@@ -12,6 +12,8 @@ import (
 	"strings"
 	"time"
 	"unicode/utf8"
+
+	"github.com/mako10k/llmcmd/internal/faultinject"
 )
 
 // Token estimation constants
@@ -85,6 +87,21 @@ func estimateTokens(text string) int {
 	return int(float64(charCount) / EstimatedCharsPerToken)
 }
 
+// EstimateRequestTokens provides a rough estimate of the input tokens of a
+// request carrying the given messages and tool definitions
+func EstimateRequestTokens(messages []ChatMessage, tools []Tool) int {
+	total := 0
+	for _, message := range messages {
+		total += estimateTokens(message.Content)
+	}
+	if len(tools) > 0 {
+		// Tool definitions are sent as JSON schemas
+		data, _ := json.Marshal(tools)
+		total += estimateTokens(string(data))
+	}
+	return total
+}
+
 // readFileWithTokenLimit reads a file with token limit consideration
 func readFileWithTokenLimit(filePath string, maxTokens int) (string, bool, error) {
 	file, err := os.Open(filePath)
@@ -146,6 +163,9 @@ type Client struct {
 	quotaConfig *QuotaConfig        // Optional quota configuration
 	sharedQuota *SharedQuotaManager // Optional shared quota manager
 	processID   string              // Process ID for shared quota
+	fallbacks   []Provider          // Secondary providers, in priority order
+	health      *HealthRegistry     // Provider health used to pick the endpoint
+	lastBaseURL string              // Provider used by the previous request
 }
 
 // ClientConfig holds configuration for the OpenAI client
@@ -157,6 +177,10 @@ type ClientConfig struct {
 	MaxRetries  int
 	RetryDelay  time.Duration
 	QuotaConfig *QuotaConfig // Optional quota configuration
+	// Fallbacks are used, in order, while the primary provider is unhealthy.
+	// A fallback without an API key reuses APIKey.
+	Fallbacks []Provider
+	Health    *HealthRegistry // Defaults to DefaultHealthRegistry
 }
 
 // NewClient creates a new OpenAI API client
@@ -176,6 +200,16 @@ func NewClient(config ClientConfig) *Client {
 	if config.RetryDelay == 0 {
 		config.RetryDelay = 1 * time.Second
 	}
+	if config.Health == nil {
+		config.Health = DefaultHealthRegistry
+	}
+	var fallbacks []Provider
+	for _, fallback := range config.Fallbacks {
+		if fallback.APIKey == "" {
+			fallback.APIKey = config.APIKey
+		}
+		fallbacks = append(fallbacks, fallback)
+	}
 
 	return &Client{
 		httpClient: &http.Client{
@@ -185,6 +219,8 @@ func NewClient(config ClientConfig) *Client {
 		baseURL:     config.BaseURL,
 		maxCalls:    config.MaxCalls,
 		quotaConfig: config.QuotaConfig,
+		fallbacks:   fallbacks,
+		health:      config.Health,
 		retryConfig: RetryConfig{
 			MaxRetries:    config.MaxRetries,
 			BaseDelay:     config.RetryDelay,
@@ -221,6 +257,11 @@ func (c *Client) ChatCompletion(ctx context.Context, req ChatCompletionRequest)
 			c.stats.QuotaUsage.TotalWeighted, float64(c.quotaConfig.MaxTokens))
 	}
 
+	// Simulated API failures for LLMCMD_FAULT_INJECT
+	if status, injected := faultinject.APIFailure(); injected {
+		return c.errorf("API request failed with status %d: injected fault", status)
+	}
+
 	// Prepare request
 	reqBody, err := json.Marshal(req)
 	if err != nil {
@@ -228,8 +269,11 @@ func (c *Client) ChatCompletion(ctx context.Context, req ChatCompletionRequest)
 		return c.errorf("failed to marshal request: %w", err)
 	}
 
+	// Route to the first healthy provider
+	provider := c.chooseProvider()
+
 	// Create HTTP request
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewBuffer(reqBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", provider.BaseURL+"/chat/completions", bytes.NewBuffer(reqBody))
 	if err != nil {
 		c.stats.AddError()
 		return c.errorf("failed to create request: %w", err)
@@ -237,7 +281,7 @@ func (c *Client) ChatCompletion(ctx context.Context, req ChatCompletionRequest)
 
 	// Set headers
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	httpReq.Header.Set("Authorization", "Bearer "+provider.APIKey)
 	httpReq.Header.Set("User-Agent", "llmcmd/1.0.0")
 
 	// Send request and measure duration
@@ -246,11 +290,15 @@ func (c *Client) ChatCompletion(ctx context.Context, req ChatCompletionRequest)
 	duration := time.Since(start)
 
 	if err != nil {
+		c.health.Record(provider.BaseURL, duration, true)
 		c.stats.AddError()
 		return c.errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
+	// Rate limits and server errors count against the provider; client errors don't
+	c.health.Record(provider.BaseURL, duration, resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500)
+
 	// Read response body
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -283,6 +331,28 @@ func (c *Client) ChatCompletion(ctx context.Context, req ChatCompletionRequest)
 	return &chatResp, nil
 }
 
+// chooseProvider picks the provider for the next request and counts failovers
+func (c *Client) chooseProvider() Provider {
+	provider := Provider{BaseURL: c.baseURL, APIKey: c.apiKey}
+	if len(c.fallbacks) > 0 {
+		provider = c.health.Choose(append([]Provider{provider}, c.fallbacks...))
+	}
+
+	if c.lastBaseURL != "" && provider.BaseURL != c.lastBaseURL {
+		c.stats.FailoverCount++
+		if c.stats.Verbose {
+			fmt.Printf("[FAILOVER] Switching provider from %s to %s\n", c.lastBaseURL, provider.BaseURL)
+		}
+	}
+	c.lastBaseURL = provider.BaseURL
+	return provider
+}
+
+// ProviderHealth returns the health of the configured providers in priority order
+func (c *Client) ProviderHealth() []ProviderHealth {
+	return c.health.Snapshot(append([]Provider{{BaseURL: c.baseURL}}, c.fallbacks...))
+}
+
 // GetStats returns current client statistics
 func (c *Client) GetStats() ClientStats {
 	return c.stats
@@ -402,33 +472,31 @@ func getStdFileInfo(fd int) map[string]interface{} {
 		}
 	}()
 
-	// Try to get file info for the standard FD
-	var stat os.FileInfo
-	var err error
-
 	// Use different approaches based on the FD
+	var file *os.File
 	switch fd {
 	case 0: // stdin
-		stat, err = os.Stdin.Stat()
+		file = os.Stdin
 	case 1: // stdout
-		stat, err = os.Stdout.Stat()
+		file = os.Stdout
 	case 2: // stderr
-		stat, err = os.Stderr.Stat()
+		file = os.Stderr
 	default:
 		// For other FDs, try os.NewFile approach
-		file := os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd))
+		file = os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd))
 		if file == nil {
 			return map[string]interface{}{
-				"type": "terminal",
+				"type": "unknown",
 			}
 		}
 		defer file.Close()
-		stat, err = file.Stat()
 	}
 
+	// A closed or unusable fd is not a terminal; don't describe it as one
+	stat, err := file.Stat()
 	if err != nil {
 		return map[string]interface{}{
-			"type": "terminal",
+			"type": "unknown",
 		}
 	}
 
@@ -443,9 +511,8 @@ func getStdFileInfo(fd int) map[string]interface{} {
 		info["modtime"] = stat.ModTime().Format("2006-01-02 15:04:05")
 		info["type"] = "file"
 
-		// Try to get the actual file path if possible (Linux/Unix only)
-		fdPath := fmt.Sprintf("/proc/self/fd/%d", fd)
-		if realPath, err := os.Readlink(fdPath); err == nil {
+		// Try to get the actual file path (/proc on Linux, fcntl on macOS)
+		if realPath, ok := filePathOf(file); ok {
 			info["file_path"] = realPath
 
 			// Get file type from extension
@@ -500,7 +567,15 @@ func getStdFileInfo(fd int) map[string]interface{} {
 			}
 		}
 	} else if stat.Mode()&os.ModeCharDevice != 0 {
-		info["type"] = "terminal"
+		// Character devices such as /dev/null are only terminals if they say so
+		if isTerminal(file) {
+			info["type"] = "terminal"
+		} else {
+			info["type"] = "device"
+			if realPath, ok := filePathOf(file); ok {
+				info["file_path"] = realPath
+			}
+		}
 	} else if stat.Mode()&os.ModeNamedPipe != 0 {
 		info["type"] = "pipe"
 	} else {
@@ -530,16 +605,18 @@ func CreateInitialMessagesWithQuota(prompt, instructions string, inputFiles []st
 		// Optimized system prompt - detailed guidance available via help()
 		systemContent = `You are llmcmd, a text processing assistant with secure tool access.
 
-CORE TOOLS: read(fd), write(fd,data), spawn(script), open(path), close(fd), exit(code), help(keys)
+CORE TOOLS: read(fd), write(fd,data), copy(src_fd,dst_fd), hash(fd|path), batch(calls), spawn(script), wait(fd), poll(fds), fds(), open(path), close(fd), fetch(handle), note_write(key,value), note_read(key), exit(code), help(keys)
+LARGE RESULTS: results over the size limit come back as {handle, preview}; use fetch(handle, offset, length) to page through them
+ERRORS: failed calls return {"error":{"code","message"}}; codes: EBADFD (bad fd), EEOF (stream closed), ETIMEOUT, EACCESS (wrong direction/read-only), ESPAWN, ENOENT, EINVAL (bad arguments), EIO
 
 WORKFLOW: read() → process → write(1,result) → exit(0)
 COMMANDS: Built-in only (cat,grep,sed,head,tail,sort,wc,tr,cut,uniq) - no external tools
 PIPES: spawn("cmd1 | cmd2") for multi-stage processing
-FILES: Virtual filesystem - files consumed after read (PIPE behavior)
+FILES: Virtual filesystem - files consumed after read (PIPE behavior); open(path,"w+") or "r+" makes a regular file that stays readable and supports read offsets
 
-⚠️ BINARY FILE LIMITS: For binary analysis, read ONLY small chunks (4-16 bytes max) to identify file type/magic numbers. DO NOT read entire binary files or perform extensive binary data processing.
+⚠️ BINARY FILE LIMITS: For binary analysis, read ONLY small chunks (4-16 bytes max) to identify file type/magic numbers. DO NOT read entire binary files or perform extensive binary data processing. To see the text inside a binary, spawn("strings -n 8 | head -50") and copy() the binary into it instead of reading it yourself.
 
-USAGE HELP: help(["basic_operations"]) for fundamentals, help(["debugging"]) for troubleshooting
+USAGE HELP: help(["basic_operations"]) for fundamentals, help(["debugging"]) for troubleshooting, help(["jq"]) for a command's options and common errors
 
 📋 STANDARD WORKFLOWS:
 
@@ -631,23 +708,21 @@ C) Virtual File Operations:
 			} else {
 				// Try to read from stdin if no files specified
 				stdinInfo := getStdFileInfo(0)
-				if stdinInfo["type"] == "file" {
+				if filePath, ok := stdinInfo["file_path"].(string); ok && stdinInfo["type"] == "file" {
 					// Stdin is redirected from a file
-					if filePath, ok := stdinInfo["file_path"].(string); ok {
-						content, truncated, err := readFileWithTokenLimit(filePath, remainingTokens)
-						if err != nil {
-							inputData.WriteString(fmt.Sprintf("STDIN INPUT:\n[Error reading: %v]\n\n", err))
-						} else {
-							inputData.WriteString("STDIN INPUT:\n")
-							inputData.WriteString(content)
-							if truncated {
-								inputData.WriteString(fmt.Sprintf("\n[Input truncated - showing first %d tokens estimated]", remainingTokens))
-							}
-							inputData.WriteString("\n\n")
+					content, truncated, err := readFileWithTokenLimit(filePath, remainingTokens)
+					if err != nil {
+						inputData.WriteString(fmt.Sprintf("STDIN INPUT:\n[Error reading: %v]\n\n", err))
+					} else {
+						inputData.WriteString("STDIN INPUT:\n")
+						inputData.WriteString(content)
+						if truncated {
+							inputData.WriteString(fmt.Sprintf("\n[Input truncated - showing first %d tokens estimated]", remainingTokens))
 						}
+						inputData.WriteString("\n\n")
 					}
 				} else {
-					// Stdin is a pipe or terminal - try to read directly
+					// Stdin is a pipe, a terminal or a file whose path is unknown - read directly
 					content, err := io.ReadAll(os.Stdin)
 					if err != nil {
 						inputData.WriteString(fmt.Sprintf("STDIN INPUT:\n[Error reading: %v]\n\n", err))
@@ -721,30 +796,37 @@ C) Virtual File Operations:
 	stdinInfo := getStdFileInfo(0)
 	stdinDisplay := "stdin (standard input)"
 	if stdinInfo["type"] == "file" {
-		if filePath, ok := stdinInfo["file_path"].(string); ok {
-			size := stdinInfo["size_bytes"].(int64)
-			sizeStr := ""
-			if size < 1024 {
-				sizeStr = fmt.Sprintf("%d bytes", size)
-			} else if size < 1024*1024 {
-				sizeStr = fmt.Sprintf("%.1f KB", float64(size)/1024)
-			} else if size < 1024*1024*1024 {
-				sizeStr = fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
-			} else {
-				sizeStr = fmt.Sprintf("%.1f GB", float64(size)/(1024*1024*1024))
-			}
+		// Without /proc or fcntl support the path is unknown, but the file is still described
+		filePath, ok := stdinInfo["file_path"].(string)
+		if !ok {
+			filePath = "redirected file (path unknown)"
+		}
+		size := stdinInfo["size_bytes"].(int64)
+		sizeStr := ""
+		if size < 1024 {
+			sizeStr = fmt.Sprintf("%d bytes", size)
+		} else if size < 1024*1024 {
+			sizeStr = fmt.Sprintf("%.1f KB", float64(size)/1024)
+		} else if size < 1024*1024*1024 {
+			sizeStr = fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+		} else {
+			sizeStr = fmt.Sprintf("%.1f GB", float64(size)/(1024*1024*1024))
+		}
 
-			fileType := "unknown"
-			if ftype, ok := stdinInfo["file_type"].(string); ok {
-				fileType = ftype
-			}
+		fileType := "unknown"
+		if ftype, ok := stdinInfo["file_type"].(string); ok {
+			fileType = ftype
+		}
 
-			sizeCategory := "unknown"
-			if category, ok := stdinInfo["size_category"].(string); ok {
-				sizeCategory = category
-			}
+		sizeCategory := "unknown"
+		if category, ok := stdinInfo["size_category"].(string); ok {
+			sizeCategory = category
+		}
 
-			stdinDisplay = fmt.Sprintf("stdin <- %s [%s, %s, %s]", filePath, sizeStr, fileType, sizeCategory)
+		stdinDisplay = fmt.Sprintf("stdin <- %s [%s, %s, %s]", filePath, sizeStr, fileType, sizeCategory)
+	} else if stdinInfo["type"] == "device" {
+		if filePath, ok := stdinInfo["file_path"].(string); ok {
+			stdinDisplay = fmt.Sprintf("stdin <- %s (device)", filePath)
 		}
 	}
 
@@ -821,7 +903,7 @@ C) Virtual File Operations:
 			fdMappingContent += "\n✗ stdin (fd=0) - ignore, no input data here"
 		}
 		fdMappingContent += "\nWORKFLOW: read(fd=3+) → spawn(commands) → write(fd=1) → exit(0)"
-		fdMappingContent += "\n\nFILE REFERENCES: Use $1 for first file, $2 for second file, etc."
+		fdMappingContent += "\n\nFILE REFERENCES: Use $1 for first file, $2 for second file, etc. (accepted by read(fd) and open(path))"
 	} else {
 		fdMappingContent += "\n\nAVAILABLE INPUT SOURCES:"
 		if stdinInfo["type"] == "file" {
@@ -832,6 +914,7 @@ C) Virtual File Operations:
 		fdMappingContent += "\n✗ input files - none specified (do NOT read fd=3+)"
 		fdMappingContent += "\nWORKFLOW: read(fd=0) → spawn(commands) → write(fd=1) → exit(0)"
 	}
+	fdMappingContent += fmt.Sprintf("\n\nNEW FDS: open() and spawn() allocate fds starting at fd=%d (after the last input file); always use the fd numbers they return", len(actualFiles)+3)
 
 	messages = append(messages, ChatMessage{
 		Role:    "user",
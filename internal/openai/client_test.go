@@ -26,8 +26,8 @@ func TestNewClient(t *testing.T) {
 
 func TestToolDefinitions(t *testing.T) {
 	tools := ToolDefinitions()
-	if len(tools) != 7 {
-		t.Errorf("Expected 7 tools, got %d", len(tools))
+	if len(tools) != 16 {
+		t.Errorf("Expected 16 tools, got %d", len(tools))
 	}
 
 	expected := map[string]bool{
@@ -35,6 +35,15 @@ func TestToolDefinitions(t *testing.T) {
 		"write": false,
 		"open":  false,
 		"spawn": false,
+		"wait":  false,
+		"fds":   false,
+		"poll":  false,
+		"fetch": false,
+		"copy":  false,
+		"hash":  false,
+		"batch": false,
+		"note_write": false,
+		"note_read":  false,
 		"close": false,
 		"help":  false,
 		"exit":  false,
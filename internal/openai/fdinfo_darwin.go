@@ -0,0 +1,33 @@
+package openai
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// maxPathLen is PATH_MAX on macOS, the buffer size F_GETPATH requires
+const maxPathLen = 1024
+
+// filePathOf returns the path of an open file; macOS has no /proc, but
+// fcntl(F_GETPATH) reports the path directly
+func filePathOf(file *os.File) (string, bool) {
+	buf := make([]byte, maxPathLen)
+	_, _, errno := syscall.Syscall(syscall.SYS_FCNTL, file.Fd(), syscall.F_GETPATH, uintptr(unsafe.Pointer(&buf[0])))
+	if errno != 0 {
+		return "", false
+	}
+	if n := bytes.IndexByte(buf, 0); n > 0 {
+		return string(buf[:n]), true
+	}
+	return "", false
+}
+
+// isTerminal reports whether file is a terminal rather than another
+// character device such as /dev/null
+func isTerminal(file *os.File) bool {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), syscall.TIOCGETA, uintptr(unsafe.Pointer(&termios)))
+	return errno == 0
+}
@@ -0,0 +1,26 @@
+package openai
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// filePathOf returns the path of an open file from /proc, which minimal
+// containers may not mount
+func filePathOf(file *os.File) (string, bool) {
+	path, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", file.Fd()))
+	if err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// isTerminal reports whether file is a terminal rather than another
+// character device such as /dev/null
+func isTerminal(file *os.File) bool {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&termios)))
+	return errno == 0
+}
@@ -0,0 +1,60 @@
+package openai
+
+import (
+	"os"
+	"syscall"
+	"testing"
+)
+
+// stdFileInfoOf describes a duplicate of f's fd, which getStdFileInfo closes
+func stdFileInfoOf(t *testing.T, f *os.File) map[string]interface{} {
+	t.Helper()
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatalf("dup failed: %v", err)
+	}
+	return getStdFileInfo(fd)
+}
+
+func TestStdFileInfoDevNullIsNotTerminal(t *testing.T) {
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("open %s: %v", os.DevNull, err)
+	}
+	defer devNull.Close()
+
+	info := stdFileInfoOf(t, devNull)
+	if info["type"] != "device" || info["file_path"] != os.DevNull {
+		t.Errorf("info = %v, want a device at %s", info, os.DevNull)
+	}
+}
+
+func TestStdFileInfoRegularFile(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "stdin*.log")
+	if err != nil {
+		t.Fatalf("create temp file: %v", err)
+	}
+	defer file.Close()
+
+	info := stdFileInfoOf(t, file)
+	if info["type"] != "file" || info["file_path"] != file.Name() || info["file_type"] != "text" {
+		t.Errorf("info = %v, want the text file %s", info, file.Name())
+	}
+}
+
+func TestStdFileInfoClosedFdIsUnknown(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "closed")
+	if err != nil {
+		t.Fatalf("create temp file: %v", err)
+	}
+	fd, err := syscall.Dup(int(file.Fd()))
+	if err != nil {
+		t.Fatalf("dup failed: %v", err)
+	}
+	file.Close()
+	syscall.Close(fd)
+
+	if info := getStdFileInfo(fd); info["type"] != "unknown" {
+		t.Errorf("info = %v, want unknown for a closed fd", info)
+	}
+}
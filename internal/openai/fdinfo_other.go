@@ -0,0 +1,25 @@
+//go:build !linux && !darwin
+
+package openai
+
+import (
+	"fmt"
+	"os"
+)
+
+// filePathOf returns the path of an open file where a Linux-compatible /proc
+// is mounted, and reports false elsewhere
+func filePathOf(file *os.File) (string, bool) {
+	path, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", file.Fd()))
+	if err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// isTerminal reports whether file is a character device; without a portable
+// terminal check every character device is assumed to be one
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,133 @@
+package openai
+
+import (
+	"sync"
+	"time"
+)
+
+// Provider is an OpenAI-compatible endpoint requests can be sent to
+type Provider struct {
+	BaseURL string
+	APIKey  string
+}
+
+// ProviderHealth summarizes the observed behaviour of one provider
+type ProviderHealth struct {
+	BaseURL             string        `json:"base_url"`
+	Requests            int           `json:"requests"`
+	Errors              int           `json:"errors"`
+	ConsecutiveFailures int           `json:"consecutive_failures"`
+	TotalLatency        time.Duration `json:"total_latency"`
+	LastFailure         time.Time     `json:"last_failure"`
+}
+
+// ErrorRate returns the fraction of requests that failed
+func (p ProviderHealth) ErrorRate() float64 {
+	if p.Requests == 0 {
+		return 0
+	}
+	return float64(p.Errors) / float64(p.Requests)
+}
+
+// AverageLatency returns the mean latency of successful and failed requests
+func (p ProviderHealth) AverageLatency() time.Duration {
+	if p.Requests == 0 {
+		return 0
+	}
+	return p.TotalLatency / time.Duration(p.Requests)
+}
+
+// Health thresholds: a provider with this many consecutive failures is skipped
+// until the cooldown has passed since its last failure
+const (
+	unhealthyAfterFailures = 2
+	unhealthyCooldown      = 30 * time.Second
+)
+
+// HealthRegistry tracks provider health across clients in the same process,
+// so later runs (e.g. internal llmcmd calls from llmsh) avoid a failing provider
+type HealthRegistry struct {
+	mu        sync.Mutex
+	providers map[string]*ProviderHealth
+	now       func() time.Time
+}
+
+// NewHealthRegistry creates an empty health registry
+func NewHealthRegistry() *HealthRegistry {
+	return &HealthRegistry{
+		providers: make(map[string]*ProviderHealth),
+		now:       time.Now,
+	}
+}
+
+// DefaultHealthRegistry is shared by clients that don't configure their own
+var DefaultHealthRegistry = NewHealthRegistry()
+
+// entry returns the health record for baseURL, creating it if needed (mu must be held)
+func (h *HealthRegistry) entry(baseURL string) *ProviderHealth {
+	p, ok := h.providers[baseURL]
+	if !ok {
+		p = &ProviderHealth{BaseURL: baseURL}
+		h.providers[baseURL] = p
+	}
+	return p
+}
+
+// Record stores the outcome of one request to baseURL
+func (h *HealthRegistry) Record(baseURL string, latency time.Duration, failed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	p := h.entry(baseURL)
+	p.Requests++
+	p.TotalLatency += latency
+	if failed {
+		p.Errors++
+		p.ConsecutiveFailures++
+		p.LastFailure = h.now()
+	} else {
+		p.ConsecutiveFailures = 0
+	}
+}
+
+// Healthy reports whether baseURL should receive new requests
+func (h *HealthRegistry) Healthy(baseURL string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.healthy(h.entry(baseURL))
+}
+
+// healthy implements Healthy (mu must be held)
+func (h *HealthRegistry) healthy(p *ProviderHealth) bool {
+	return p.ConsecutiveFailures < unhealthyAfterFailures || h.now().Sub(p.LastFailure) >= unhealthyCooldown
+}
+
+// Choose returns the first healthy provider in priority order. If none is
+// healthy, the provider whose last failure is oldest is tried.
+func (h *HealthRegistry) Choose(providers []Provider) Provider {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	best := 0
+	for i, provider := range providers {
+		p := h.entry(provider.BaseURL)
+		if h.healthy(p) {
+			return provider
+		}
+		if p.LastFailure.Before(h.entry(providers[best].BaseURL).LastFailure) {
+			best = i
+		}
+	}
+	return providers[best]
+}
+
+// Snapshot returns the health of the given providers in priority order
+func (h *HealthRegistry) Snapshot(providers []Provider) []ProviderHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshot := make([]ProviderHealth, 0, len(providers))
+	for _, provider := range providers {
+		snapshot = append(snapshot, *h.entry(provider.BaseURL))
+	}
+	return snapshot
+}
@@ -0,0 +1,46 @@
+package openai
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHealthRegistryChoose(t *testing.T) {
+	now := time.Now()
+	health := NewHealthRegistry()
+	health.now = func() time.Time { return now }
+	providers := []Provider{{BaseURL: "primary"}, {BaseURL: "secondary"}}
+
+	if got := health.Choose(providers); got.BaseURL != "primary" {
+		t.Errorf("Choose() with no history = %q, want primary", got.BaseURL)
+	}
+
+	// One failure is tolerated, a second one routes away from the primary
+	health.Record("primary", 10*time.Millisecond, true)
+	if got := health.Choose(providers); got.BaseURL != "primary" {
+		t.Errorf("Choose() after one failure = %q, want primary", got.BaseURL)
+	}
+	health.Record("primary", 30*time.Millisecond, true)
+	if got := health.Choose(providers); got.BaseURL != "secondary" {
+		t.Errorf("Choose() after two failures = %q, want secondary", got.BaseURL)
+	}
+
+	// When every provider is unhealthy, the one that failed longest ago is tried
+	now = now.Add(time.Second)
+	health.Record("secondary", 0, true)
+	health.Record("secondary", 0, true)
+	if got := health.Choose(providers); got.BaseURL != "primary" {
+		t.Errorf("Choose() with all providers failing = %q, want primary", got.BaseURL)
+	}
+
+	// The primary is retried once the cooldown has passed
+	now = now.Add(unhealthyCooldown)
+	if !health.Healthy("primary") {
+		t.Errorf("Healthy(primary) after cooldown = false, want true")
+	}
+
+	snapshot := health.Snapshot(providers[:1])
+	if snapshot[0].ErrorRate() != 1 || snapshot[0].AverageLatency() != 20*time.Millisecond {
+		t.Errorf("Snapshot() = %+v, want error rate 1 and average latency 20ms", snapshot[0])
+	}
+}
@@ -0,0 +1,59 @@
+package openai
+
+import (
+	"strings"
+)
+
+// RefusalError reports that the model declined the request, as opposed to a
+// tool failure or an empty result
+type RefusalError struct {
+	Reason string // Refusal text from the model
+	Source string // "refusal" (dedicated field), "content_filter" or "content"
+}
+
+func (e *RefusalError) Error() string {
+	return "model refused the request: " + e.Reason
+}
+
+// refusalPhrases are openings that mark a plain-text reply as a refusal
+var refusalPhrases = []string{
+	"i'm sorry, but i can't",
+	"i’m sorry, but i can’t",
+	"i'm sorry, but i cannot",
+	"i am sorry, but i cannot",
+	"i can't assist with",
+	"i cannot assist with",
+	"i can't help with",
+	"i cannot help with",
+	"i'm unable to help with",
+	"i won't be able to help",
+}
+
+// DetectRefusal returns a RefusalError when choice is a model refusal.
+// Plain-text replies are only checked when textIsUnexpected is set, i.e. when
+// the model should have used tools instead of answering directly.
+func DetectRefusal(choice Choice, textIsUnexpected bool) *RefusalError {
+	if refusal := strings.TrimSpace(choice.Message.Refusal); refusal != "" {
+		return &RefusalError{Reason: refusal, Source: "refusal"}
+	}
+
+	if choice.FinishReason == "content_filter" {
+		reason := strings.TrimSpace(choice.Message.Content)
+		if reason == "" {
+			reason = "response blocked by content filter"
+		}
+		return &RefusalError{Reason: reason, Source: "content_filter"}
+	}
+
+	if textIsUnexpected && len(choice.Message.ToolCalls) == 0 {
+		content := strings.TrimSpace(choice.Message.Content)
+		lower := strings.ToLower(content)
+		for _, phrase := range refusalPhrases {
+			if strings.HasPrefix(lower, phrase) {
+				return &RefusalError{Reason: content, Source: "content"}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package openai
+
+import "testing"
+
+func TestDetectRefusal(t *testing.T) {
+	tests := []struct {
+		name             string
+		choice           Choice
+		textIsUnexpected bool
+		wantSource       string
+	}{
+		{
+			name:       "refusal field",
+			choice:     Choice{Message: ChatMessage{Refusal: "I can't do that."}, FinishReason: "stop"},
+			wantSource: "refusal",
+		},
+		{
+			name:       "content filter",
+			choice:     Choice{FinishReason: "content_filter"},
+			wantSource: "content_filter",
+		},
+		{
+			name:             "refusal text instead of tool calls",
+			choice:           Choice{Message: ChatMessage{Content: "I'm sorry, but I can't help with that."}, FinishReason: "stop"},
+			textIsUnexpected: true,
+			wantSource:       "content",
+		},
+		{
+			name:   "refusal-like text as the answer",
+			choice: Choice{Message: ChatMessage{Content: "I'm sorry, but I can't"}, FinishReason: "stop"},
+		},
+		{
+			name:             "ordinary text",
+			choice:           Choice{Message: ChatMessage{Content: "Done."}, FinishReason: "stop"},
+			textIsUnexpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectRefusal(tt.choice, tt.textIsUnexpected)
+			if tt.wantSource == "" {
+				if got != nil {
+					t.Errorf("DetectRefusal() = %v, want nil", got)
+				}
+				return
+			}
+			if got == nil || got.Source != tt.wantSource {
+				t.Errorf("DetectRefusal() = %+v, want source %q", got, tt.wantSource)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mako10k/llmcmd/internal/faultinject"
+)
+
+func TestChatCompletionWithRetryRecoversFromInjectedFault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ChatCompletionResponse{ID: "ok"})
+	}))
+	defer server.Close()
+
+	if err := faultinject.Enable("api_500=1"); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+	defer faultinject.Disable()
+
+	client := NewClient(ClientConfig{APIKey: "test", BaseURL: server.URL})
+	resp, err := client.ChatCompletionWithRetry(context.Background(), ChatCompletionRequest{Model: "test"})
+	if err != nil {
+		t.Fatalf("ChatCompletionWithRetry() error = %v", err)
+	}
+	if resp.ID != "ok" {
+		t.Errorf("response ID = %q, want %q", resp.ID, "ok")
+	}
+	if stats := client.GetStats(); stats.RetryCount != 1 {
+		t.Errorf("RetryCount = %d, want 1", stats.RetryCount)
+	}
+}
+
+func TestChatCompletionWithRetryFailsOverToHealthyProvider(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("service_unavailable"))
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test" {
+			t.Errorf("Authorization = %q, want the primary key", got)
+		}
+		json.NewEncoder(w).Encode(ChatCompletionResponse{ID: "secondary"})
+	}))
+	defer secondary.Close()
+
+	health := NewHealthRegistry()
+	client := NewClient(ClientConfig{
+		APIKey:     "test",
+		BaseURL:    primary.URL,
+		RetryDelay: time.Millisecond,
+		Fallbacks:  []Provider{{BaseURL: secondary.URL}},
+		Health:     health,
+	})
+	// Mark the primary unhealthy up front so the test does not wait for backoff
+	health.Record(primary.URL, 0, true)
+	health.Record(primary.URL, 0, true)
+
+	resp, err := client.ChatCompletionWithRetry(context.Background(), ChatCompletionRequest{Model: "test"})
+	if err != nil {
+		t.Fatalf("ChatCompletionWithRetry() error = %v", err)
+	}
+	if resp.ID != "secondary" {
+		t.Errorf("response ID = %q, want %q", resp.ID, "secondary")
+	}
+	if snapshot := client.ProviderHealth(); len(snapshot) != 2 || snapshot[1].Requests != 1 || snapshot[1].Errors != 0 {
+		t.Errorf("ProviderHealth() = %+v, want one successful request to the secondary", snapshot)
+	}
+}
@@ -29,6 +29,7 @@ type ChatCompletionResponse struct {
 type ChatMessage struct {
 	Role       string     `json:"role"`
 	Content    string     `json:"content,omitempty"`
+	Refusal    string     `json:"refusal,omitempty"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
 }
@@ -118,6 +119,7 @@ type ClientStats struct {
 	LastRequestTime  time.Time     `json:"last_request_time"`
 	ErrorCount       int           `json:"error_count"`
 	RetryCount       int           `json:"retry_count"`
+	FailoverCount    int           `json:"failover_count"` // Requests routed to a different provider than the previous one
 	QuotaUsage       QuotaUsage    `json:"quota_usage"`    // Quota tracking
 	QuotaExceeded    bool          `json:"quota_exceeded"` // Whether quota was exceeded
 	Verbose          bool          `json:"-"`              // Not serialized
@@ -133,6 +135,7 @@ func (s *ClientStats) Reset() {
 	s.LastRequestTime = time.Time{}
 	s.ErrorCount = 0
 	s.RetryCount = 0
+	s.FailoverCount = 0
 	s.QuotaUsage = QuotaUsage{}
 	s.QuotaExceeded = false
 }
@@ -203,9 +206,8 @@ func ToolDefinitions() []Tool {
 					"type": "object",
 					"properties": map[string]interface{}{
 						"fd": map[string]interface{}{
-							"type":        "integer",
-							"description": "File descriptor number (0=stdin, 3+=input files)",
-							"minimum":     0,
+							"type":        []string{"integer", "string"},
+							"description": "File descriptor number (0=stdin, 3+=input files) or a file reference from the FD mapping ($1, $2, input file name)",
 						},
 						"count": map[string]interface{}{
 							"type":        "integer",
@@ -219,6 +221,11 @@ func ToolDefinitions() []Tool {
 							"minimum":     1,
 							"maximum":     1000,
 						},
+						"offset": map[string]interface{}{
+							"type":        "integer",
+							"description": "Byte offset to read a file from; pass the next_offset reported by a previous read to continue",
+							"minimum":     0,
+						},
 					},
 					"required": []string{"fd"},
 				},
@@ -228,7 +235,7 @@ func ToolDefinitions() []Tool {
 			Type: "function",
 			Function: ToolFunction{
 				Name:        "write",
-				Description: "Write data to a file descriptor or stream",
+				Description: "Write data to a file descriptor or stream. Use path instead of fd to append to a virtual file in one call (open/write/close)",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
@@ -238,6 +245,10 @@ func ToolDefinitions() []Tool {
 							"minimum":     1,
 							"maximum":     2,
 						},
+						"path": map[string]interface{}{
+							"type":        "string",
+							"description": "Virtual file path to append to (alternative to fd; the file is created if missing and closed after writing)",
+						},
 						"data": map[string]interface{}{
 							"type":        "string",
 							"description": "Data to write",
@@ -251,7 +262,7 @@ func ToolDefinitions() []Tool {
 							"description": "Signal end of file and trigger chain cleanup (default: false)",
 						},
 					},
-					"required": []string{"fd", "data"},
+					"required": []string{"data"},
 				},
 			},
 		},
@@ -259,7 +270,7 @@ func ToolDefinitions() []Tool {
 			Type: "function",
 			Function: ToolFunction{
 				Name:        "spawn",
-				Description: "Execute shell scripts using the full shell execution environment. Supports complete shell syntax including pipes, redirects, and complex commands. Pattern 1: spawn({script}) returns new file descriptors. Pattern 2: spawn({script,in_fd}) reads from existing fd. Pattern 3: spawn({script,out_fd}) writes to existing fd. Pattern 4: spawn({script,in_fd,out_fd}) for pipeline middle.",
+				Description: "Execute shell scripts using the full shell execution environment. Supports complete shell syntax including pipes, redirects, and complex commands. Pattern 1: spawn({script}) returns new file descriptors. Pattern 2: spawn({script,in_fd}) reads from existing fd. Pattern 3: spawn({script,out_fd}) writes to existing fd. Pattern 4: spawn({script,in_fd,out_fd}) for pipeline middle. Use wait(fd) to get the exit status.",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
@@ -268,14 +279,25 @@ func ToolDefinitions() []Tool {
 							"description": "Shell script/command to execute. Supports full shell syntax: pipes (|), redirects (>, >>), command substitution, etc. Examples: 'grep ERROR | sort', 'ls -la *.log | wc -l', 'cat file1 file2 | sort > output'",
 						},
 						"in_fd": map[string]interface{}{
-							"type":        "integer",
-							"description": "Input file descriptor for script (optional). When provided with out_fd, runs synchronously.",
-							"minimum":     0,
+							"type":        []string{"integer", "string"},
+							"description": "Existing readable fd connected directly to the script's stdin, e.g. 3 or \"$1\" for an input file (optional). When provided with out_fd, runs synchronously.",
 						},
 						"out_fd": map[string]interface{}{
-							"type":        "integer",
-							"description": "Output file descriptor for script (optional). When provided with in_fd, runs synchronously.",
-							"minimum":     1,
+							"type":        []string{"integer", "string"},
+							"description": "Existing writable fd connected directly to the script's stdout, e.g. 1 (optional). When provided with in_fd, runs synchronously.",
+						},
+						"env": map[string]interface{}{
+							"type":                 "object",
+							"description":          "Extra environment variables for the script (optional), e.g. {\"LC_ALL\": \"C\"}. Prefer this over interpolating values into the script.",
+							"additionalProperties": map[string]interface{}{"type": "string"},
+						},
+						"clear_env": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Start from an empty environment (PATH is kept) before applying env (optional)",
+						},
+						"capture_stderr": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Also return err_fd, a readable fd for the script's stderr (optional)",
 						},
 					},
 					"required": []string{"script"},
@@ -292,14 +314,18 @@ func ToolDefinitions() []Tool {
 					"properties": map[string]interface{}{
 						"path": map[string]interface{}{
 							"type":        "string",
-							"description": "Virtual file path to open",
+							"description": "Virtual file path to open. Input file references ($1, $2, input file name) open a fresh read-only fd on that input file",
 						},
 						"mode": map[string]interface{}{
 							"type":        "string",
-							"description": "File mode: 'r' (read), 'w' (write), 'a' (append), 'r+' (read/write), 'w+' (write/read), 'a+' (append/read)",
+							"description": "File mode: 'r' (read), 'w' (write), 'a' (append), 'r+' (read/write), 'w+' (write/read), 'a+' (append/read). Virtual files are consumed when read, like pipes; opening one with 'r+' or 'w+' makes it a regular file that keeps its data and supports read offsets",
 							"enum":        []string{"r", "w", "a", "r+", "w+", "a+"},
 							"default":     "r",
 						},
+						"fifo": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Make path a named pipe first, unless it already exists (optional). Spawned llmsh scripts open it by name (cat path, cmd > path) to stream data to each other or to you. Open it with mode 'r' or 'w'; reads wait for data and end when every writer has closed it, writes wait while 64KiB is unread. poll reports a pipe opened with fifo like a spawned script's out_fd",
+						},
 					},
 					"required": []string{"path"},
 				},
@@ -323,35 +349,230 @@ func ToolDefinitions() []Tool {
 				},
 			},
 		},
+		{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        "wait",
+				Description: "Wait until a spawned script exits. Returns finished, exit_code, stderr_tail and duration_ms; a failed llmsh script also returns failure with the failing command, its line and message. If the timeout expires first, returns finished=false and the script keeps running.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"fd": map[string]interface{}{
+							"type":        "integer",
+							"description": "in_fd or out_fd returned by spawn",
+							"minimum":     0,
+						},
+						"timeout": map[string]interface{}{
+							"type":        "number",
+							"description": "Maximum seconds to wait (default 30, max 300)",
+							"minimum":     0,
+						},
+					},
+					"required": []string{"fd"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        "fds",
+				Description: "List open file descriptors with their origin (stdin, input file, spawn pipe, virtual file), direction (r/w/rw) and bytes transferred. Use it when unsure which fd to use.",
+				Parameters: map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        "poll",
+				Description: "Wait until at least one of several readable fds has data or reached EOF, e.g. out_fd and err_fd of spawned scripts. Reports each fd as data, eof, pending or ready (regular files). Avoids guess-read loops.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"fds": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]interface{}{"type": "integer"},
+							"description": "File descriptors to watch",
+							"minItems":    1,
+						},
+						"timeout": map[string]interface{}{
+							"type":        "number",
+							"description": "Maximum seconds to wait (default 10, max 300, 0 = check without waiting)",
+							"minimum":     0,
+						},
+					},
+					"required": []string{"fds"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        "copy",
+				Description: "Stream data from one fd to another inside llmcmd, e.g. an input file to stdout or to a spawned script's in_fd, without reading it into the conversation. Copies until EOF unless count is given.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"src_fd": map[string]interface{}{
+							"type":        []string{"integer", "string"},
+							"description": "Readable fd to copy from (number or reference like \"$1\")",
+						},
+						"dst_fd": map[string]interface{}{
+							"type":        []string{"integer", "string"},
+							"description": "Writable fd to copy to",
+						},
+						"count": map[string]interface{}{
+							"type":        "integer",
+							"description": "Maximum bytes to copy (default: until EOF)",
+							"minimum":     1,
+						},
+					},
+					"required": []string{"src_fd", "dst_fd"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        "hash",
+				Description: "Compute a checksum of an fd or a file inside llmcmd, to verify transformations or detect duplicates without reading the contents. Hashing an fd reads it to EOF; hashing a virtual file consumes it like read.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"fd": map[string]interface{}{
+							"type":        []string{"integer", "string"},
+							"description": "Readable fd to hash (number or reference like \"$1\")",
+						},
+						"path": map[string]interface{}{
+							"type":        "string",
+							"description": "File to hash instead of an fd: an input file name, a $LLMCMD_TMPDIR path or a virtual file",
+						},
+						"algorithm": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{"sha256", "md5", "crc32"},
+							"description": "Checksum algorithm (default: sha256)",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        "batch",
+				Description: "Run several tool calls in order in one round trip, e.g. open, write and close. Stops at the first failing call and reports which one failed; batches cannot be nested.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"calls": map[string]interface{}{
+							"type":        "array",
+							"description": "Tool calls to run in order (at most 32)",
+							"maxItems":    32,
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"name": map[string]interface{}{
+										"type":        "string",
+										"description": "Tool name, e.g. \"write\"",
+									},
+									"arguments": map[string]interface{}{
+										"type":        "object",
+										"description": "The tool's arguments",
+									},
+								},
+								"required": []string{"name"},
+							},
+						},
+					},
+					"required": []string{"calls"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        "note_write",
+				Description: "Keep a short note (counts, offsets, plans) in an in-memory scratchpad for later turns, without creating a virtual file. An empty value removes the note.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"key": map[string]interface{}{
+							"type":        "string",
+							"description": "Note name",
+						},
+						"value": map[string]interface{}{
+							"type":        "string",
+							"description": "Note text; an empty value removes the note",
+						},
+						"append": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Append to the existing note instead of replacing it (default false)",
+						},
+					},
+					"required": []string{"key", "value"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        "note_read",
+				Description: "Read a scratchpad note kept by note_write. Without a key, lists the names and sizes of all notes.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"key": map[string]interface{}{
+							"type":        "string",
+							"description": "Note name (omit to list all notes)",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        "fetch",
+				Description: "Page through a large tool result that was replaced by a handle and preview. Returns data plus the number of bytes remaining.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"handle": map[string]interface{}{
+							"type":        "string",
+							"description": "Handle from a previous tool result (e.g. \"r1\")",
+						},
+						"offset": map[string]interface{}{
+							"type":        "integer",
+							"description": "Byte offset to start from (default 0)",
+							"minimum":     0,
+						},
+						"length": map[string]interface{}{
+							"type":        "integer",
+							"description": "Maximum bytes to return (default and maximum: the handle threshold)",
+							"minimum":     1,
+						},
+					},
+					"required": []string{"handle"},
+				},
+			},
+		},
 		{
 			Type: "function",
 			Function: ToolFunction{
 				Name:        "help",
-				Description: "Get comprehensive usage information for specific tool categories. Provides detailed guidance, examples, and best practices organized by subsections.",
+				Description: "Get comprehensive usage information for specific tool categories, or the manual of a spawn builtin (synopsis, options, examples and common errors). Provides detailed guidance, examples, and best practices organized by subsections.",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
 						"keys": map[string]interface{}{
 							"type":        "array",
-							"description": "Usage categories to retrieve: data_analysis, text_processing, file_operations, content_search, format_conversion, log_analysis, batch_processing, interactive_workflow, debugging, basic_operations, command_usage",
+							"description": "Usage categories to retrieve: data_analysis, text_processing, file_operations, content_search, format_conversion, log_analysis, batch_processing, interactive_workflow, debugging, basic_operations, command_usage; or builtin names such as jq or sort for their manuals; or --list for all keys",
 							"items": map[string]interface{}{
 								"type": "string",
-								"enum": []string{
-									"data_analysis",
-									"text_processing",
-									"file_operations",
-									"content_search",
-									"format_conversion",
-									"log_analysis",
-									"batch_processing",
-									"interactive_workflow",
-									"debugging",
-									"basic_operations",
-									"command_usage",
-								},
 							},
 							"minItems": 1,
-							"maxItems": 11,
 						},
 					},
 					"required": []string{"keys"},
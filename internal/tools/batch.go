@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxBatchCalls bounds the number of calls in one batch
+const maxBatchCalls = 32
+
+// batchResult is the result of one call in a batch
+type batchResult struct {
+	Name   string `json:"name"`
+	Result string `json:"result"`
+}
+
+// executeBatch implements the batch tool - runs primitive tool calls in order
+// in one round trip, stopping at the first error
+func (e *Engine) executeBatch(args map[string]interface{}) (string, error) {
+	calls, ok := args["calls"].([]interface{})
+	if !ok || len(calls) == 0 {
+		e.countError()
+		return "", fmt.Errorf("batch: calls must be a non-empty array")
+	}
+	if len(calls) > maxBatchCalls {
+		e.countError()
+		return "", fmt.Errorf("batch: at most %d calls are allowed, got %d", maxBatchCalls, len(calls))
+	}
+
+	// Validate the whole batch before running any of it
+	toolCalls := make([]map[string]interface{}, len(calls))
+	for i, value := range calls {
+		call, ok := value.(map[string]interface{})
+		name, _ := call["name"].(string)
+		if !ok || name == "" {
+			e.countError()
+			return "", fmt.Errorf("batch: call %d: name is required", i)
+		}
+		if name == "batch" {
+			e.countError()
+			return "", fmt.Errorf("batch: call %d: batches cannot be nested", i)
+		}
+		arguments, ok := call["arguments"]
+		if !ok {
+			arguments = map[string]interface{}{}
+		}
+		argumentBytes, err := json.Marshal(arguments)
+		if err != nil {
+			e.countError()
+			return "", fmt.Errorf("batch: call %d: %w", i, err)
+		}
+		toolCalls[i] = map[string]interface{}{"name": name, "arguments": string(argumentBytes)}
+	}
+
+	results := make([]batchResult, 0, len(toolCalls))
+	report := map[string]interface{}{}
+	for i, toolCall := range toolCalls {
+		result, err := e.ExecuteToolCall(toolCall)
+		if err != nil {
+			// Exit requests and a closed output end the run; pass them on
+			if errors.Is(err, ErrOutputClosed) || strings.HasPrefix(err.Error(), "EXIT_REQUESTED:") {
+				results = append(results, batchResult{Name: toolCall["name"].(string), Result: result})
+				return e.batchReport(report, results), err
+			}
+			report["failed_call"] = i
+			report["error"] = err.Error()
+			report["error_code"] = ErrorCode(err)
+			break
+		}
+		results = append(results, batchResult{Name: toolCall["name"].(string), Result: result})
+	}
+	return e.batchReport(report, results), nil
+}
+
+// batchReport encodes the results of a batch
+func (e *Engine) batchReport(report map[string]interface{}, results []batchResult) string {
+	report["completed"] = len(results)
+	report["results"] = results
+	resultBytes, _ := json.Marshal(report)
+	return string(resultBytes)
+}
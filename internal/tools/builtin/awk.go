@@ -0,0 +1,1206 @@
+package builtin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Awk runs a subset of awk: patterns and ranges, BEGIN and END, field
+// splitting with -F or FS, print, printf, the control statements, arrays and
+// the string and math functions. getline, user-defined functions, output
+// redirection and file operands are not supported; input comes from stdin.
+func Awk(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := " "
+	var assignments []string
+	var source string
+	haveSource := false
+
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			i++
+		case arg == "-F" || arg == "-v":
+			if i+1 == len(args) {
+				return fmt.Errorf("awk: %s: missing argument", arg)
+			}
+			i++
+			if arg == "-F" {
+				fs = awkFieldSeparator(args[i])
+			} else {
+				assignments = append(assignments, args[i])
+			}
+			continue
+		case strings.HasPrefix(arg, "-F"):
+			fs = awkFieldSeparator(arg[2:])
+			continue
+		case strings.HasPrefix(arg, "-v"):
+			assignments = append(assignments, arg[2:])
+			continue
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			return fmt.Errorf("awk: %s: invalid option", arg)
+		}
+		break
+	}
+	if i < len(args) {
+		source, haveSource = args[i], true
+		i++
+	}
+	if !haveSource {
+		return fmt.Errorf("awk: missing program")
+	}
+
+	prog, err := parseAwk(source)
+	if err != nil {
+		return fmt.Errorf("awk: %w", err)
+	}
+
+	in := newAwkInterp(prog, stdout)
+	in.vars["FS"] = awkStrValue(fs)
+	for _, assignment := range assignments {
+		if err := in.assign(assignment); err != nil {
+			return err
+		}
+	}
+	// Operands may only assign variables, applied before the input is read
+	var operands []string
+	for _, operand := range args[i:] {
+		if !awkAssignmentPattern.MatchString(operand) {
+			return fmt.Errorf("awk: %s: file operands are not supported; pipe the input instead", operand)
+		}
+		operands = append(operands, operand)
+	}
+
+	err = in.run(stdin, operands)
+	if flushErr := in.out.Flush(); err == nil {
+		err = flushErr
+	}
+	if err == nil && in.exitCode != 0 {
+		return &AwkExitError{Code: in.exitCode}
+	}
+	return err
+}
+
+// AwkExitError is returned when an awk program exits with a non-zero status
+type AwkExitError struct {
+	Code int
+}
+
+func (e *AwkExitError) Error() string {
+	return fmt.Sprintf("awk: exit %d", e.Code)
+}
+
+// ExitCode returns the status given to exit
+func (e *AwkExitError) ExitCode() int {
+	return e.Code
+}
+
+// awkAssignmentPattern matches a name=value operand or -v argument
+var awkAssignmentPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// awkFieldSeparator converts the argument of -F to FS; "t" is a tab
+func awkFieldSeparator(arg string) string {
+	if arg == "t" {
+		return "\t"
+	}
+	return awkUnescape(arg)
+}
+
+// awkUnescape processes the escape sequences of a -v or operand value
+func awkUnescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			b.WriteString(awkEscape(s[i]))
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// awkKind is the type of an awk value
+type awkKind int
+
+const (
+	awkUninit awkKind = iota
+	awkNum
+	awkStrKind
+	awkStrNum // Input that looks numeric: compared as a number
+)
+
+// awkValue is a value of an awk program
+type awkValue struct {
+	kind awkKind
+	n    float64
+	s    string
+}
+
+func awkNumValue(n float64) awkValue {
+	return awkValue{kind: awkNum, n: n}
+}
+
+func awkStrValue(s string) awkValue {
+	return awkValue{kind: awkStrKind, s: s}
+}
+
+func awkBoolValue(b bool) awkValue {
+	if b {
+		return awkNumValue(1)
+	}
+	return awkNumValue(0)
+}
+
+// awkInput makes a value from input, such as a field: a strnum if it looks
+// numeric, otherwise a string
+func awkInput(s string) awkValue {
+	if n, ok := awkLooksNumeric(s); ok {
+		return awkValue{kind: awkStrNum, n: n, s: s}
+	}
+	return awkStrValue(s)
+}
+
+// awkLeadingNumber matches the numeric prefix of a string
+var awkLeadingNumber = regexp.MustCompile(`^[-+]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][-+]?[0-9]+)?`)
+
+// awkLooksNumeric reports whether s, blanks aside, is entirely a number
+func awkLooksNumeric(s string) (float64, bool) {
+	s = strings.Trim(s, " \t\n")
+	match := awkLeadingNumber.FindString(s)
+	if match == "" || len(match) != len(s) {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(match, 64)
+	return n, err == nil
+}
+
+func (v awkValue) num() float64 {
+	switch v.kind {
+	case awkNum, awkStrNum:
+		return v.n
+	case awkStrKind:
+		n, _ := strconv.ParseFloat(awkLeadingNumber.FindString(strings.TrimLeft(v.s, " \t\n")), 64)
+		return n
+	}
+	return 0
+}
+
+func (v awkValue) str() string {
+	switch v.kind {
+	case awkNum:
+		return awkFormatNumber(v.n)
+	case awkStrKind, awkStrNum:
+		return v.s
+	}
+	return ""
+}
+
+func (v awkValue) truth() bool {
+	switch v.kind {
+	case awkNum, awkStrNum:
+		return v.n != 0
+	case awkStrKind:
+		return v.s != ""
+	}
+	return false
+}
+
+// awkFormatNumber converts a number to a string: integers in full, others
+// with %.6g
+func awkFormatNumber(n float64) string {
+	if n == math.Trunc(n) && math.Abs(n) < 1e16 {
+		return strconv.FormatInt(int64(n), 10)
+	}
+	return fmt.Sprintf("%.6g", n)
+}
+
+// awkCompare compares two values, numerically unless either is a string
+func awkCompare(a, b awkValue) int {
+	if a.kind != awkStrKind && b.kind != awkStrKind {
+		switch x, y := a.num(), b.num(); {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a.str(), b.str())
+}
+
+// awkControl unwinds the statements being run for next, exit, break and
+// continue
+type awkControl int
+
+const (
+	awkCtlNext awkControl = iota
+	awkCtlExit
+	awkCtlBreak
+	awkCtlContinue
+)
+
+func (c awkControl) Error() string {
+	return [...]string{"next", "exit", "break", "continue"}[c] + " used outside its context"
+}
+
+// awkInterp runs a parsed program
+type awkInterp struct {
+	prog     *awkProgram
+	out      *bufio.Writer
+	vars     map[string]awkValue
+	arrays   map[string]map[string]awkValue
+	fields   []string // fields[0] is the record
+	regexps  map[string]*regexp.Regexp
+	exitCode int
+}
+
+func newAwkInterp(prog *awkProgram, stdout io.Writer) *awkInterp {
+	return &awkInterp{
+		prog: prog,
+		out:  bufio.NewWriter(stdout),
+		vars: map[string]awkValue{
+			"NR":      awkNumValue(0),
+			"FNR":     awkNumValue(0),
+			"OFS":     awkStrValue(" "),
+			"ORS":     awkStrValue("\n"),
+			"RS":      awkStrValue("\n"),
+			"SUBSEP":  awkStrValue("\x1c"),
+			"RSTART":  awkNumValue(0),
+			"RLENGTH": awkNumValue(-1),
+		},
+		arrays:  make(map[string]map[string]awkValue),
+		fields:  []string{""},
+		regexps: make(map[string]*regexp.Regexp),
+	}
+}
+
+// assign applies a name=value assignment from -v or an operand
+func (in *awkInterp) assign(assignment string) error {
+	name, value, ok := strings.Cut(assignment, "=")
+	if !ok || !awkAssignmentPattern.MatchString(assignment) {
+		return fmt.Errorf("awk: %q: expected name=value", assignment)
+	}
+	return in.setVar(name, awkInput(awkUnescape(value)))
+}
+
+// run runs BEGIN, the main items for each input line, and END
+func (in *awkInterp) run(stdin io.Reader, operands []string) error {
+	exited := false
+	for _, block := range in.prog.begin {
+		if err := in.execTop(block); err != nil {
+			if err == awkCtlExit {
+				exited = true
+				break
+			}
+			return err
+		}
+	}
+
+	if !exited {
+		for _, operand := range operands {
+			if err := in.assign(operand); err != nil {
+				return err
+			}
+		}
+	}
+	if !exited && (len(in.prog.items) > 0 || len(in.prog.end) > 0) {
+		reader := bufio.NewReader(stdin)
+		ranges := make(map[*awkItem]bool)
+	records:
+		for {
+			line, readErr := reader.ReadString('\n')
+			if line == "" && readErr != nil {
+				if readErr != io.EOF {
+					return fmt.Errorf("awk: %w", readErr)
+				}
+				break
+			}
+			in.vars["NR"] = awkNumValue(in.vars["NR"].num() + 1)
+			in.vars["FNR"] = awkNumValue(in.vars["FNR"].num() + 1)
+			in.setRecord(strings.TrimSuffix(line, "\n"))
+
+			for _, item := range in.prog.items {
+				matched, err := in.matches(item, ranges)
+				if err != nil {
+					return err
+				}
+				if !matched {
+					continue
+				}
+				if item.action == nil {
+					err = in.print(nil)
+				} else {
+					err = in.execTop(item.action)
+				}
+				if err == awkCtlNext {
+					break
+				}
+				if err == awkCtlExit {
+					break records
+				}
+				if err != nil {
+					return err
+				}
+			}
+			if readErr != nil {
+				break
+			}
+		}
+	}
+
+	for _, block := range in.prog.end {
+		if err := in.execTop(block); err != nil {
+			if err == awkCtlExit {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// matches reports whether an item's pattern matches the current record,
+// tracking the ranges that are open
+func (in *awkInterp) matches(item *awkItem, ranges map[*awkItem]bool) (bool, error) {
+	if item.pattern == nil {
+		return true, nil
+	}
+	if item.end == nil {
+		v, err := in.eval(item.pattern)
+		return v.truth(), err
+	}
+	if !ranges[item] {
+		v, err := in.eval(item.pattern)
+		if err != nil || !v.truth() {
+			return false, err
+		}
+	}
+	v, err := in.eval(item.end)
+	if err != nil {
+		return false, err
+	}
+	ranges[item] = !v.truth()
+	return true, nil
+}
+
+// execTop runs a BEGIN, END or main action, where break and continue are
+// errors
+func (in *awkInterp) execTop(block *awkBlock) error {
+	err := in.exec(block)
+	if err == awkCtlBreak || err == awkCtlContinue {
+		return fmt.Errorf("awk: %v", err)
+	}
+	return err
+}
+
+func (in *awkInterp) exec(stmt awkStmt) error {
+	switch s := stmt.(type) {
+	case *awkBlock:
+		for _, stmt := range s.stmts {
+			if err := in.exec(stmt); err != nil {
+				return err
+			}
+		}
+	case *awkExprStmt:
+		_, err := in.eval(s.expr)
+		return err
+	case *awkPrint:
+		if s.printf {
+			return in.printf(s.args)
+		}
+		return in.print(s.args)
+	case *awkIf:
+		cond, err := in.eval(s.cond)
+		if err != nil {
+			return err
+		}
+		if cond.truth() {
+			return in.exec(s.then)
+		}
+		if s.otherwise != nil {
+			return in.exec(s.otherwise)
+		}
+	case *awkWhile:
+		for first := true; ; first = false {
+			if !first || !s.doWhile {
+				cond, err := in.eval(s.cond)
+				if err != nil {
+					return err
+				}
+				if !cond.truth() {
+					return nil
+				}
+			}
+			if err := in.exec(s.body); err == awkCtlBreak {
+				return nil
+			} else if err != nil && err != awkCtlContinue {
+				return err
+			}
+		}
+	case *awkFor:
+		if s.init != nil {
+			if err := in.exec(s.init); err != nil {
+				return err
+			}
+		}
+		for {
+			if s.cond != nil {
+				cond, err := in.eval(s.cond)
+				if err != nil {
+					return err
+				}
+				if !cond.truth() {
+					return nil
+				}
+			}
+			if err := in.exec(s.body); err == awkCtlBreak {
+				return nil
+			} else if err != nil && err != awkCtlContinue {
+				return err
+			}
+			if s.post != nil {
+				if err := in.exec(s.post); err != nil {
+					return err
+				}
+			}
+		}
+	case *awkForIn:
+		array := in.array(s.array)
+		keys := make([]string, 0, len(array))
+		for key := range array {
+			keys = append(keys, key)
+		}
+		awkSortKeys(keys)
+		for _, key := range keys {
+			if _, exists := array[key]; !exists {
+				continue
+			}
+			if err := in.setVar(s.key, awkInput(key)); err != nil {
+				return err
+			}
+			if err := in.exec(s.body); err == awkCtlBreak {
+				return nil
+			} else if err != nil && err != awkCtlContinue {
+				return err
+			}
+		}
+	case *awkNext:
+		return awkCtlNext
+	case *awkExitStmt:
+		if s.status != nil {
+			status, err := in.eval(s.status)
+			if err != nil {
+				return err
+			}
+			in.exitCode = int(status.num())
+		}
+		return awkCtlExit
+	case *awkBreak:
+		return awkCtlBreak
+	case *awkContinue:
+		return awkCtlContinue
+	case *awkDelete:
+		if s.subs == nil {
+			in.arrays[s.array] = make(map[string]awkValue)
+			return nil
+		}
+		key, err := in.key(s.subs)
+		if err != nil {
+			return err
+		}
+		delete(in.array(s.array), key)
+	default:
+		return fmt.Errorf("awk: unknown statement %T", stmt)
+	}
+	return nil
+}
+
+// awkSortKeys orders array keys for for-in: numbers numerically, before
+// other strings, which sort as strings. awk leaves the order unspecified;
+// a stable one keeps output reproducible.
+func awkSortKeys(keys []string) {
+	less := func(a, b string) bool {
+		x, aNum := awkLooksNumeric(a)
+		y, bNum := awkLooksNumeric(b)
+		switch {
+		case aNum && bNum:
+			return x < y
+		case aNum != bNum:
+			return aNum
+		}
+		return a < b
+	}
+	for i := 1; i < len(keys); i++ {
+		for j := i; j > 0 && less(keys[j], keys[j-1]); j-- {
+			keys[j], keys[j-1] = keys[j-1], keys[j]
+		}
+	}
+}
+
+// print writes its arguments separated by OFS, or the record, then ORS
+func (in *awkInterp) print(args []awkExpr) error {
+	if len(args) == 0 {
+		in.out.WriteString(in.fields[0])
+	}
+	for i, arg := range args {
+		v, err := in.eval(arg)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			in.out.WriteString(in.vars["OFS"].str())
+		}
+		in.out.WriteString(v.str())
+	}
+	_, err := in.out.WriteString(in.vars["ORS"].str())
+	return err
+}
+
+func (in *awkInterp) printf(args []awkExpr) error {
+	values, err := in.evalAll(args)
+	if err != nil {
+		return err
+	}
+	s, err := awkSprintf(values[0].str(), values[1:])
+	if err != nil {
+		return err
+	}
+	_, err = in.out.WriteString(s)
+	return err
+}
+
+func (in *awkInterp) evalAll(exprs []awkExpr) ([]awkValue, error) {
+	values := make([]awkValue, len(exprs))
+	for i, expr := range exprs {
+		v, err := in.eval(expr)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// setRecord sets $0 and splits it into fields
+func (in *awkInterp) setRecord(record string) {
+	in.fields = append([]string{record}, in.split(record, in.vars["FS"].str())...)
+}
+
+// split splits s by a field separator: blanks for " ", a single character
+// literally, otherwise a regex
+func (in *awkInterp) split(s, fs string) []string {
+	switch {
+	case s == "":
+		return nil
+	case fs == " ":
+		return strings.Fields(s)
+	case utf8.RuneCountInString(fs) == 1 && fs != `\`:
+		return strings.Split(s, fs)
+	case fs == "":
+		return strings.Split(s, "")
+	}
+	re, err := in.regexp(fs)
+	if err != nil {
+		return []string{s}
+	}
+	return re.Split(s, -1)
+}
+
+// rebuild joins the fields with OFS into $0
+func (in *awkInterp) rebuild() {
+	in.fields[0] = strings.Join(in.fields[1:], in.vars["OFS"].str())
+}
+
+func (in *awkInterp) field(i int) (awkValue, error) {
+	switch {
+	case i < 0:
+		return awkValue{}, fmt.Errorf("awk: $%d: negative field index", i)
+	case i >= len(in.fields):
+		return awkValue{}, nil
+	}
+	return awkInput(in.fields[i]), nil
+}
+
+func (in *awkInterp) setField(i int, s string) error {
+	switch {
+	case i < 0:
+		return fmt.Errorf("awk: $%d: negative field index", i)
+	case i == 0:
+		in.setRecord(s)
+		return nil
+	}
+	for len(in.fields) <= i {
+		in.fields = append(in.fields, "")
+	}
+	in.fields[i] = s
+	in.rebuild()
+	return nil
+}
+
+func (in *awkInterp) getVar(name string) awkValue {
+	if name == "NF" {
+		return awkNumValue(float64(len(in.fields) - 1))
+	}
+	return in.vars[name]
+}
+
+func (in *awkInterp) setVar(name string, v awkValue) error {
+	if _, isArray := in.arrays[name]; isArray {
+		return fmt.Errorf("awk: %s: cannot assign to an array", name)
+	}
+	if name == "NF" {
+		nf := int(v.num())
+		if nf < 0 {
+			return fmt.Errorf("awk: NF: negative value %d", nf)
+		}
+		for len(in.fields) <= nf {
+			in.fields = append(in.fields, "")
+		}
+		in.fields = in.fields[:nf+1]
+		in.rebuild()
+		return nil
+	}
+	in.vars[name] = v
+	return nil
+}
+
+// array returns an array, creating it on first use
+func (in *awkInterp) array(name string) map[string]awkValue {
+	array, exists := in.arrays[name]
+	if !exists {
+		array = make(map[string]awkValue)
+		in.arrays[name] = array
+	}
+	return array
+}
+
+// key joins subscripts with SUBSEP
+func (in *awkInterp) key(subs []awkExpr) (string, error) {
+	values, err := in.evalAll(subs)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = v.str()
+	}
+	return strings.Join(parts, in.vars["SUBSEP"].str()), nil
+}
+
+// regexp compiles a dynamic regex, caching it
+func (in *awkInterp) regexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := in.regexps[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("awk: invalid regex %q: %w", pattern, err)
+	}
+	in.regexps[pattern] = re
+	return re, nil
+}
+
+// regexArg evaluates an expression used as a regex: a regex literal as is,
+// anything else as a dynamic regex
+func (in *awkInterp) regexArg(expr awkExpr) (*regexp.Regexp, error) {
+	if lit, ok := expr.(*awkRegexLit); ok {
+		return lit.re, nil
+	}
+	v, err := in.eval(expr)
+	if err != nil {
+		return nil, err
+	}
+	return in.regexp(v.str())
+}
+
+// store assigns to a variable, field or array element
+func (in *awkInterp) store(target awkExpr, v awkValue) error {
+	switch t := target.(type) {
+	case *awkVar:
+		return in.setVar(t.name, v)
+	case *awkField:
+		i, err := in.eval(t.index)
+		if err != nil {
+			return err
+		}
+		return in.setField(int(i.num()), v.str())
+	case *awkIndex:
+		key, err := in.key(t.subs)
+		if err != nil {
+			return err
+		}
+		in.array(t.name)[key] = v
+		return nil
+	}
+	return fmt.Errorf("awk: cannot assign to %T", target)
+}
+
+func (in *awkInterp) eval(expr awkExpr) (awkValue, error) {
+	switch e := expr.(type) {
+	case *awkNumLit:
+		return awkNumValue(e.value), nil
+	case *awkStrLit:
+		return awkStrValue(e.value), nil
+	case *awkRegexLit:
+		return awkBoolValue(e.re.MatchString(in.fields[0])), nil
+	case *awkVar:
+		if _, isArray := in.arrays[e.name]; isArray {
+			return awkValue{}, fmt.Errorf("awk: %s: array used as a scalar", e.name)
+		}
+		return in.getVar(e.name), nil
+	case *awkField:
+		i, err := in.eval(e.index)
+		if err != nil {
+			return awkValue{}, err
+		}
+		return in.field(int(i.num()))
+	case *awkIndex:
+		key, err := in.key(e.subs)
+		if err != nil {
+			return awkValue{}, err
+		}
+		array := in.array(e.name)
+		v, exists := array[key]
+		if !exists {
+			// Referencing an element creates it, as in awk
+			array[key] = v
+		}
+		return v, nil
+	case *awkAssign:
+		v, err := in.eval(e.value)
+		if err != nil {
+			return awkValue{}, err
+		}
+		if e.op != "=" {
+			old, err := in.eval(e.target)
+			if err != nil {
+				return awkValue{}, err
+			}
+			n, err := awkArith(strings.TrimSuffix(e.op, "="), old.num(), v.num())
+			if err != nil {
+				return awkValue{}, err
+			}
+			v = awkNumValue(n)
+		}
+		return v, in.store(e.target, v)
+	case *awkIncDec:
+		old, err := in.eval(e.target)
+		if err != nil {
+			return awkValue{}, err
+		}
+		v := awkNumValue(old.num() + e.delta)
+		if err := in.store(e.target, v); err != nil {
+			return awkValue{}, err
+		}
+		if e.prefix {
+			return v, nil
+		}
+		return awkNumValue(old.num()), nil
+	case *awkBinary:
+		return in.evalBinary(e)
+	case *awkUnary:
+		v, err := in.eval(e.operand)
+		if err != nil {
+			return awkValue{}, err
+		}
+		switch e.op {
+		case "!":
+			return awkBoolValue(!v.truth()), nil
+		case "-":
+			return awkNumValue(-v.num()), nil
+		}
+		return awkNumValue(v.num()), nil
+	case *awkCond:
+		cond, err := in.eval(e.cond)
+		if err != nil {
+			return awkValue{}, err
+		}
+		if cond.truth() {
+			return in.eval(e.ifTrue)
+		}
+		return in.eval(e.ifFalse)
+	case *awkIn:
+		key, err := in.key(e.subs)
+		if err != nil {
+			return awkValue{}, err
+		}
+		_, exists := in.array(e.array)[key]
+		return awkBoolValue(exists), nil
+	case *awkCall:
+		return in.call(e)
+	case *awkGroup:
+		return awkValue{}, fmt.Errorf("awk: a list in parentheses is only valid in print or before in")
+	}
+	return awkValue{}, fmt.Errorf("awk: unknown expression %T", expr)
+}
+
+func (in *awkInterp) evalBinary(e *awkBinary) (awkValue, error) {
+	switch e.op {
+	case "&&", "||":
+		left, err := in.eval(e.left)
+		if err != nil || left.truth() == (e.op == "||") {
+			return awkBoolValue(left.truth()), err
+		}
+		right, err := in.eval(e.right)
+		return awkBoolValue(right.truth()), err
+	case "~", "!~":
+		left, err := in.eval(e.left)
+		if err != nil {
+			return awkValue{}, err
+		}
+		re, err := in.regexArg(e.right)
+		if err != nil {
+			return awkValue{}, err
+		}
+		return awkBoolValue(re.MatchString(left.str()) == (e.op == "~")), nil
+	}
+
+	left, err := in.eval(e.left)
+	if err != nil {
+		return awkValue{}, err
+	}
+	right, err := in.eval(e.right)
+	if err != nil {
+		return awkValue{}, err
+	}
+	switch e.op {
+	case "":
+		return awkStrValue(left.str() + right.str()), nil
+	case "<":
+		return awkBoolValue(awkCompare(left, right) < 0), nil
+	case "<=":
+		return awkBoolValue(awkCompare(left, right) <= 0), nil
+	case ">":
+		return awkBoolValue(awkCompare(left, right) > 0), nil
+	case ">=":
+		return awkBoolValue(awkCompare(left, right) >= 0), nil
+	case "==":
+		return awkBoolValue(awkCompare(left, right) == 0), nil
+	case "!=":
+		return awkBoolValue(awkCompare(left, right) != 0), nil
+	}
+	n, err := awkArith(e.op, left.num(), right.num())
+	return awkNumValue(n), err
+}
+
+// awkArith applies an arithmetic operator
+func awkArith(op string, x, y float64) (float64, error) {
+	switch op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "/", "%":
+		if y == 0 {
+			return 0, fmt.Errorf("awk: division by zero")
+		}
+		if op == "/" {
+			return x / y, nil
+		}
+		return math.Mod(x, y), nil
+	case "^", "**":
+		return math.Pow(x, y), nil
+	}
+	return 0, fmt.Errorf("awk: unknown operator %q", op)
+}
+
+// call runs a builtin function
+func (in *awkInterp) call(c *awkCall) (awkValue, error) {
+	argc := len(c.args)
+	minArgs, maxArgs := 1, 1
+	switch c.name {
+	case "length":
+		minArgs = 0
+	case "substr":
+		minArgs, maxArgs = 2, 3
+	case "index", "atan2", "match":
+		minArgs, maxArgs = 2, 2
+	case "split", "sub", "gsub":
+		minArgs, maxArgs = 2, 3
+	case "sprintf":
+		maxArgs = math.MaxInt
+	}
+	if argc < minArgs || argc > maxArgs {
+		return awkValue{}, fmt.Errorf("awk: %s: wrong number of arguments", c.name)
+	}
+
+	switch c.name {
+	case "length":
+		if argc == 0 {
+			return awkNumValue(float64(utf8.RuneCountInString(in.fields[0]))), nil
+		}
+		if v, ok := c.args[0].(*awkVar); ok {
+			if array, isArray := in.arrays[v.name]; isArray {
+				return awkNumValue(float64(len(array))), nil
+			}
+		}
+	case "split":
+		return in.callSplit(c.args)
+	case "sub", "gsub":
+		return in.callSub(c.name == "gsub", c.args)
+	case "match":
+		s, err := in.eval(c.args[0])
+		if err != nil {
+			return awkValue{}, err
+		}
+		re, err := in.regexArg(c.args[1])
+		if err != nil {
+			return awkValue{}, err
+		}
+		start, length := 0, -1
+		if loc := re.FindStringIndex(s.str()); loc != nil {
+			start = utf8.RuneCountInString(s.str()[:loc[0]]) + 1
+			length = utf8.RuneCountInString(s.str()[loc[0]:loc[1]])
+		}
+		in.vars["RSTART"] = awkNumValue(float64(start))
+		in.vars["RLENGTH"] = awkNumValue(float64(length))
+		return awkNumValue(float64(start)), nil
+	}
+
+	args, err := in.evalAll(c.args)
+	if err != nil {
+		return awkValue{}, err
+	}
+	switch c.name {
+	case "length":
+		return awkNumValue(float64(utf8.RuneCountInString(args[0].str()))), nil
+	case "substr":
+		return awkStrValue(awkSubstr(args)), nil
+	case "index":
+		i := strings.Index(args[0].str(), args[1].str())
+		if i < 0 {
+			return awkNumValue(0), nil
+		}
+		return awkNumValue(float64(utf8.RuneCountInString(args[0].str()[:i]) + 1)), nil
+	case "sprintf":
+		s, err := awkSprintf(args[0].str(), args[1:])
+		return awkStrValue(s), err
+	case "tolower":
+		return awkStrValue(strings.ToLower(args[0].str())), nil
+	case "toupper":
+		return awkStrValue(strings.ToUpper(args[0].str())), nil
+	case "int":
+		return awkNumValue(math.Trunc(args[0].num())), nil
+	case "sqrt":
+		return awkNumValue(math.Sqrt(args[0].num())), nil
+	case "exp":
+		return awkNumValue(math.Exp(args[0].num())), nil
+	case "log":
+		return awkNumValue(math.Log(args[0].num())), nil
+	case "sin":
+		return awkNumValue(math.Sin(args[0].num())), nil
+	case "cos":
+		return awkNumValue(math.Cos(args[0].num())), nil
+	case "atan2":
+		return awkNumValue(math.Atan2(args[0].num(), args[1].num())), nil
+	}
+	return awkValue{}, fmt.Errorf("awk: %s: unknown function", c.name)
+}
+
+// awkSubstr returns the characters of s from position m, n of them if given,
+// positions counting from 1
+func awkSubstr(args []awkValue) string {
+	runes := []rune(args[0].str())
+	start := math.Round(args[1].num())
+	end := float64(len(runes) + 1)
+	if len(args) == 3 {
+		end = math.Min(end, start+math.Round(args[2].num()))
+	}
+	start = math.Max(start, 1)
+	if end <= start {
+		return ""
+	}
+	return string(runes[int(start)-1 : int(end)-1])
+}
+
+// callSplit runs split(s, array [, fs])
+func (in *awkInterp) callSplit(args []awkExpr) (awkValue, error) {
+	target, ok := args[1].(*awkVar)
+	if !ok {
+		return awkValue{}, fmt.Errorf("awk: split: second argument must be an array name")
+	}
+	s, err := in.eval(args[0])
+	if err != nil {
+		return awkValue{}, err
+	}
+	var parts []string
+	switch {
+	case len(args) == 2:
+		parts = in.split(s.str(), in.vars["FS"].str())
+	default:
+		if lit, ok := args[2].(*awkRegexLit); ok {
+			if s.str() != "" {
+				parts = lit.re.Split(s.str(), -1)
+			}
+		} else {
+			fs, err := in.eval(args[2])
+			if err != nil {
+				return awkValue{}, err
+			}
+			parts = in.split(s.str(), fs.str())
+		}
+	}
+	if _, isScalar := in.vars[target.name]; isScalar {
+		return awkValue{}, fmt.Errorf("awk: split: %s is not an array", target.name)
+	}
+	array := make(map[string]awkValue, len(parts))
+	for i, part := range parts {
+		array[strconv.Itoa(i+1)] = awkInput(part)
+	}
+	in.arrays[target.name] = array
+	return awkNumValue(float64(len(parts))), nil
+}
+
+// callSub runs sub or gsub(regex, replacement [, target]); & in the
+// replacement stands for the match and \& for a literal &
+func (in *awkInterp) callSub(global bool, args []awkExpr) (awkValue, error) {
+	re, err := in.regexArg(args[0])
+	if err != nil {
+		return awkValue{}, err
+	}
+	repl, err := in.eval(args[1])
+	if err != nil {
+		return awkValue{}, err
+	}
+	var target awkExpr = &awkField{index: &awkNumLit{value: 0}}
+	if len(args) == 3 {
+		target = args[2]
+		if !isAwkLvalue(target) {
+			return awkValue{}, fmt.Errorf("awk: sub: third argument must be a variable, field or array element")
+		}
+	}
+	old, err := in.eval(target)
+	if err != nil {
+		return awkValue{}, err
+	}
+
+	s := old.str()
+	count := 0
+	var b strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringIndex(s, -1) {
+		if !global && count == 1 {
+			break
+		}
+		b.WriteString(s[last:loc[0]])
+		b.WriteString(awkReplacement(repl.str(), s[loc[0]:loc[1]]))
+		last = loc[1]
+		count++
+	}
+	if count == 0 {
+		return awkNumValue(0), nil
+	}
+	b.WriteString(s[last:])
+	return awkNumValue(float64(count)), in.store(target, awkStrValue(b.String()))
+}
+
+// awkReplacement expands & and \& in a sub or gsub replacement
+func awkReplacement(repl, match string) string {
+	if !strings.ContainsAny(repl, `&\`) {
+		return repl
+	}
+	var b strings.Builder
+	for i := 0; i < len(repl); i++ {
+		switch {
+		case repl[i] == '\\' && i+1 < len(repl) && (repl[i+1] == '&' || repl[i+1] == '\\'):
+			i++
+			b.WriteByte(repl[i])
+		case repl[i] == '&':
+			b.WriteString(match)
+		default:
+			b.WriteByte(repl[i])
+		}
+	}
+	return b.String()
+}
+
+// awkSprintf formats values as printf does
+func awkSprintf(format string, args []awkValue) (string, error) {
+	var b strings.Builder
+	next := func() awkValue {
+		if len(args) == 0 {
+			return awkValue{}
+		}
+		v := args[0]
+		args = args[1:]
+		return v
+	}
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+		start := i
+		i++
+		if i < len(format) && format[i] == '%' {
+			b.WriteByte('%')
+			continue
+		}
+		spec := []byte{'%'}
+		for i < len(format) && strings.IndexByte("-+ #0", format[i]) >= 0 {
+			spec = append(spec, format[i])
+			i++
+		}
+		// Width and precision, either of which may be * to take an argument
+		for part := 0; part < 2; part++ {
+			if part == 1 {
+				if i == len(format) || format[i] != '.' {
+					break
+				}
+				spec = append(spec, '.')
+				i++
+			}
+			if i < len(format) && format[i] == '*' {
+				spec = strconv.AppendInt(spec, int64(next().num()), 10)
+				i++
+				continue
+			}
+			for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+				spec = append(spec, format[i])
+				i++
+			}
+		}
+		if i == len(format) {
+			return "", fmt.Errorf("awk: printf: incomplete format %q", format[start:])
+		}
+
+		switch verb := format[i]; verb {
+		case 'd', 'i', 'u':
+			fmt.Fprintf(&b, string(append(spec, 'd')), int64(next().num()))
+		case 'o', 'x', 'X':
+			fmt.Fprintf(&b, string(append(spec, verb)), int64(next().num()))
+		case 'e', 'E', 'f', 'g', 'G':
+			fmt.Fprintf(&b, string(append(spec, verb)), next().num())
+		case 'F':
+			fmt.Fprintf(&b, string(append(spec, 'f')), next().num())
+		case 'c':
+			v := next()
+			s := v.str()
+			if v.kind == awkNum {
+				s = string(rune(int(v.n)))
+			} else if r, size := utf8.DecodeRuneInString(s); size > 0 {
+				s = string(r)
+			}
+			fmt.Fprintf(&b, string(append(spec, 's')), s)
+		case 's':
+			fmt.Fprintf(&b, string(append(spec, 's')), next().str())
+		default:
+			return "", fmt.Errorf("awk: printf: invalid conversion %q", format[start:i+1])
+		}
+	}
+	return b.String(), nil
+}
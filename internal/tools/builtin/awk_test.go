@@ -0,0 +1,163 @@
+package builtin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAwk(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		input          string
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name:           "print field",
+			args:           []string{"{print $2}"},
+			input:          "a b c\nd  e\tf\n",
+			expectedOutput: "b\ne\n",
+		},
+		{
+			name:           "field separator option",
+			args:           []string{"-F:", "{print $1, NF}"},
+			input:          "root:x:0\n",
+			expectedOutput: "root 3\n",
+		},
+		{
+			name:           "regex field separator",
+			args:           []string{"-F", "[,;]", "{print $3}"},
+			input:          "a,b;c\n",
+			expectedOutput: "c\n",
+		},
+		{
+			name:           "pattern without action prints the record",
+			args:           []string{"$2 > 5"},
+			input:          "a 10\nb 2\nc 6\n",
+			expectedOutput: "a 10\nc 6\n",
+		},
+		{
+			name:           "regex pattern and match operators",
+			args:           []string{`/^a/ && $0 !~ /z/`},
+			input:          "ab\naz\nb\n",
+			expectedOutput: "ab\n",
+		},
+		{
+			name:           "range pattern",
+			args:           []string{"/start/,/end/"},
+			input:          "x\nstart\ny\nend\nz\n",
+			expectedOutput: "start\ny\nend\n",
+		},
+		{
+			name:           "begin and end",
+			args:           []string{`BEGIN {print "head"} {n += $1} END {print "sum", n, NR}`},
+			input:          "1\n2\n3\n",
+			expectedOutput: "head\nsum 6 3\n",
+		},
+		{
+			name:           "printf",
+			args:           []string{`{printf "%-4s|%3d|%.2f|%x|%c%%\n", $1, $2, $2 / 3, $2, 65}`},
+			input:          "ab 10\n",
+			expectedOutput: "ab  | 10|3.33|a|A%\n",
+		},
+		{
+			name:           "arrays and for in",
+			args:           []string{`{sum[$1] += $2} END {for (k in sum) print k, sum[k]}`},
+			input:          "y 1\nx 2\ny 3\n",
+			expectedOutput: "x 2\ny 4\n",
+		},
+		{
+			name:           "string functions",
+			args:           []string{`{print length(), substr($0, 2, 3), index($0, "ll"), toupper($1)}`},
+			input:          "hello\n",
+			expectedOutput: "5 ell 3 HELLO\n",
+		},
+		{
+			name:           "gsub with ampersand",
+			args:           []string{`{n = gsub(/o/, "[&]"); print n, $0}`},
+			input:          "foo bar\n",
+			expectedOutput: "2 f[o][o] bar\n",
+		},
+		{
+			name:           "split and loops",
+			args:           []string{`{n = split($0, p, ","); for (i = n; i > 0; i--) printf "%s%s", p[i], (i > 1 ? "," : "\n")}`},
+			input:          "a,b,c\n",
+			expectedOutput: "c,b,a\n",
+		},
+		{
+			name:           "assigning fields rebuilds the record",
+			args:           []string{"-v", "OFS=-", `{$2 = "X"; print; NF = 2; print}`},
+			input:          "a b c\n",
+			expectedOutput: "a-X-c\na-X\n",
+		},
+		{
+			name:           "numeric and string comparison",
+			args:           []string{`{print ($1 < $2), ("10" < "9"), 1 / 4, 2 ^ 10, -2 ^ 2}`},
+			input:          "9 10\n",
+			expectedOutput: "1 1 0.25 1024 -4\n",
+		},
+		{
+			name:           "next and exit",
+			args:           []string{`/skip/ {next} /stop/ {exit} {print} END {print "done"}`},
+			input:          "a\nskip\nb\nstop\nc\n",
+			expectedOutput: "a\nb\ndone\n",
+		},
+		{
+			name:           "variable operand",
+			args:           []string{"{print x, $1}", "x=1"},
+			input:          "a\n",
+			expectedOutput: "1 a\n",
+		},
+		{
+			name:          "exit status",
+			args:          []string{"BEGIN {exit 3}"},
+			expectedError: "awk: exit 3",
+		},
+		{
+			name:          "missing program",
+			args:          []string{},
+			expectedError: "awk: missing program",
+		},
+		{
+			name:          "syntax error",
+			args:          []string{"{print $1"},
+			expectedError: "awk: line 1: missing '}'",
+		},
+		{
+			name:          "output redirection",
+			args:          []string{`{print > "out"}`},
+			expectedError: "output redirection is not supported",
+		},
+		{
+			name:          "file operand",
+			args:          []string{"{print}", "data.txt"},
+			expectedError: "file operands are not supported",
+		},
+		{
+			name:          "division by zero",
+			args:          []string{"BEGIN {print 1 / 0}"},
+			expectedError: "division by zero",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output strings.Builder
+			err := Awk(tt.args, strings.NewReader(tt.input), &output)
+
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != tt.expectedOutput {
+				t.Errorf("expected output %q, got %q", tt.expectedOutput, output.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,1036 @@
+package builtin
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// awkTokenKind classifies awk tokens
+type awkTokenKind int
+
+const (
+	awkEOF awkTokenKind = iota
+	awkNewline
+	awkNumber
+	awkString
+	awkRegex
+	awkName
+	awkFunc    // Builtin function name
+	awkKeyword // if, while, print, ...
+	awkPunct   // Operators and punctuation
+)
+
+// awkToken is a token of an awk program
+type awkToken struct {
+	kind awkTokenKind
+	text string // Name, keyword, operator, string value or regex source
+	num  float64
+	line int
+}
+
+var awkKeywords = map[string]bool{
+	"BEGIN": true, "END": true, "if": true, "else": true, "while": true, "for": true,
+	"do": true, "in": true, "print": true, "printf": true, "next": true, "exit": true,
+	"delete": true, "break": true, "continue": true, "getline": true, "function": true,
+	"return": true,
+}
+
+var awkFuncs = map[string]bool{
+	"length": true, "substr": true, "index": true, "split": true, "sub": true,
+	"gsub": true, "match": true, "sprintf": true, "tolower": true, "toupper": true,
+	"int": true, "sqrt": true, "exp": true, "log": true, "sin": true, "cos": true,
+	"atan2": true,
+}
+
+// awkOperators are the operators, longest first
+var awkOperators = []string{
+	"**=", "+=", "-=", "*=", "/=", "%=", "^=", "**", "==", "<=", ">=", "!=", "++", "--",
+	"&&", "||", ">>", "!~",
+	"+", "-", "*", "/", "%", "^", "!", "<", ">", "|", "?", ":", "~", "$", "=",
+	"(", ")", "{", "}", "[", "]", ";", ",",
+}
+
+// awkNumberPattern matches a number at the start of a string
+var awkNumberPattern = regexp.MustCompile(`^([0-9]+\.?[0-9]*|\.[0-9]+)([eE][-+]?[0-9]+)?`)
+
+// lexAwk splits an awk program into tokens
+func lexAwk(src string) ([]awkToken, error) {
+	var tokens []awkToken
+	line := 1
+	// A slash starts a regex unless it follows an operand, where it divides
+	regexAllowed := func() bool {
+		if len(tokens) == 0 {
+			return true
+		}
+		last := tokens[len(tokens)-1]
+		switch last.kind {
+		case awkNumber, awkString, awkRegex, awkName, awkFunc:
+			return false
+		case awkPunct:
+			return last.text != ")" && last.text != "]" && last.text != "$" && last.text != "++" && last.text != "--"
+		}
+		return true
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '\\' && i+1 < len(src) && src[i+1] == '\n':
+			i += 2
+			line++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '\n':
+			tokens = append(tokens, awkToken{kind: awkNewline, line: line})
+			i++
+			line++
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			text := awkNumberPattern.FindString(src[i:])
+			n, _ := strconv.ParseFloat(text, 64)
+			tokens = append(tokens, awkToken{kind: awkNumber, num: n, text: text, line: line})
+			i += len(text)
+		case c == '"':
+			s, n, err := lexAwkString(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			tokens = append(tokens, awkToken{kind: awkString, text: s, line: line})
+			i += n
+		case c == '/' && regexAllowed():
+			re, n, err := lexAwkRegex(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			tokens = append(tokens, awkToken{kind: awkRegex, text: re, line: line})
+			i += n
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			start := i
+			for i < len(src) && (src[i] == '_' || src[i] >= 'a' && src[i] <= 'z' || src[i] >= 'A' && src[i] <= 'Z' || src[i] >= '0' && src[i] <= '9') {
+				i++
+			}
+			word := src[start:i]
+			kind := awkName
+			if awkKeywords[word] {
+				kind = awkKeyword
+			} else if awkFuncs[word] {
+				kind = awkFunc
+			}
+			tokens = append(tokens, awkToken{kind: kind, text: word, line: line})
+		default:
+			op := ""
+			for _, candidate := range awkOperators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("line %d: unexpected character %q", line, c)
+			}
+			tokens = append(tokens, awkToken{kind: awkPunct, text: op, line: line})
+			i += len(op)
+		}
+	}
+	return append(tokens, awkToken{kind: awkEOF, line: line}), nil
+}
+
+// lexAwkString reads a double-quoted string at the start of src, returning
+// its value and length in src
+func lexAwkString(src string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		switch c := src[i]; c {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\n':
+			return "", 0, fmt.Errorf("newline in string")
+		case '\\':
+			if i+1 == len(src) {
+				return "", 0, fmt.Errorf("unterminated string")
+			}
+			i++
+			b.WriteString(awkEscape(src[i]))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// awkEscape returns the character an escape sequence \c stands for
+func awkEscape(c byte) string {
+	switch c {
+	case 'n':
+		return "\n"
+	case 't':
+		return "\t"
+	case 'r':
+		return "\r"
+	case 'a':
+		return "\a"
+	case 'b':
+		return "\b"
+	case 'f':
+		return "\f"
+	case 'v':
+		return "\v"
+	case '"', '/', '\\':
+		return string(c)
+	default:
+		return `\` + string(c)
+	}
+}
+
+// lexAwkRegex reads a /regex/ at the start of src, returning its source and
+// length in src
+func lexAwkRegex(src string) (string, int, error) {
+	var b strings.Builder
+	inBracket := false
+	for i := 1; i < len(src); i++ {
+		switch c := src[i]; {
+		case c == '\n':
+			return "", 0, fmt.Errorf("newline in regex")
+		case c == '\\' && i+1 < len(src):
+			i++
+			if src[i] == '/' {
+				b.WriteByte('/')
+			} else {
+				b.WriteByte('\\')
+				b.WriteByte(src[i])
+			}
+		case c == '[':
+			inBracket = true
+			b.WriteByte(c)
+		case c == ']':
+			inBracket = false
+			b.WriteByte(c)
+		case c == '/' && !inBracket:
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated regex")
+}
+
+// awkExpr is an expression of an awk program
+type awkExpr interface{}
+
+type (
+	awkNumLit   struct{ value float64 }
+	awkStrLit   struct{ value string }
+	awkRegexLit struct{ re *regexp.Regexp } // Matches $0 when used as a value
+	awkVar      struct{ name string }
+	awkField    struct{ index awkExpr }
+	awkIndex    struct {
+		name string
+		subs []awkExpr
+	}
+	awkAssign struct {
+		target awkExpr // *awkVar, *awkField or *awkIndex
+		op     string  // "=" or an arithmetic operator followed by "="
+		value  awkExpr
+	}
+	awkIncDec struct {
+		target awkExpr
+		delta  float64
+		prefix bool
+	}
+	awkBinary struct {
+		op          string // Arithmetic, comparison, "~", "!~", "&&", "||" or "" for concatenation
+		left, right awkExpr
+	}
+	awkUnary struct {
+		op      string // "-", "+" or "!"
+		operand awkExpr
+	}
+	awkCond struct{ cond, ifTrue, ifFalse awkExpr }
+	awkIn   struct {
+		subs  []awkExpr
+		array string
+	}
+	awkCall struct {
+		name string
+		args []awkExpr
+	}
+	awkGroup struct{ exprs []awkExpr } // (a, b): print's argument list or the subscripts of in
+)
+
+// awkStmt is a statement of an awk program
+type awkStmt interface{}
+
+type (
+	awkBlock struct{ stmts []awkStmt }
+	awkPrint struct {
+		printf bool
+		args   []awkExpr
+	}
+	awkExprStmt struct{ expr awkExpr }
+	awkIf       struct {
+		cond            awkExpr
+		then, otherwise awkStmt
+	}
+	awkWhile struct {
+		cond    awkExpr
+		body    awkStmt
+		doWhile bool // The body runs once before the condition is tested
+	}
+	awkFor struct {
+		init, post awkStmt
+		cond       awkExpr
+		body       awkStmt
+	}
+	awkForIn struct {
+		key, array string
+		body       awkStmt
+	}
+	awkNext     struct{}
+	awkExitStmt struct{ status awkExpr }
+	awkBreak    struct{}
+	awkContinue struct{}
+	awkDelete   struct {
+		array string
+		subs  []awkExpr // nil deletes the whole array
+	}
+)
+
+// awkItem is a pattern and its action
+type awkItem struct {
+	pattern, end awkExpr   // end is the second pattern of a range
+	action       *awkBlock // nil prints the record
+}
+
+// awkProgram is a parsed awk program
+type awkProgram struct {
+	begin, end []*awkBlock
+	items      []*awkItem
+}
+
+// awkParser parses a token list
+type awkParser struct {
+	tokens []awkToken
+	pos    int
+	// In print's arguments, > is a redirection unless parenthesized
+	noGreater bool
+}
+
+// parseAwk parses an awk program
+func parseAwk(src string) (*awkProgram, error) {
+	tokens, err := lexAwk(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &awkParser{tokens: tokens}
+	return p.program()
+}
+
+func (p *awkParser) tok() awkToken {
+	return p.tokens[p.pos]
+}
+
+func (p *awkParser) is(text string) bool {
+	t := p.tok()
+	return (t.kind == awkPunct || t.kind == awkKeyword) && t.text == text
+}
+
+func (p *awkParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.tok().line, fmt.Sprintf(format, args...))
+}
+
+// describe names the current token in errors
+func (p *awkParser) describe() string {
+	switch t := p.tok(); t.kind {
+	case awkEOF:
+		return "end of program"
+	case awkNewline:
+		return "newline"
+	case awkString:
+		return strconv.Quote(t.text)
+	case awkRegex:
+		return "/" + t.text + "/"
+	default:
+		return "'" + t.text + "'"
+	}
+}
+
+func (p *awkParser) expect(text string) error {
+	if !p.is(text) {
+		return p.errorf("expected '%s', got %s", text, p.describe())
+	}
+	p.pos++
+	return nil
+}
+
+func (p *awkParser) skipNewlines() {
+	for p.tok().kind == awkNewline {
+		p.pos++
+	}
+}
+
+// skipTerminators skips newlines and semicolons
+func (p *awkParser) skipTerminators() {
+	for p.tok().kind == awkNewline || p.is(";") {
+		p.pos++
+	}
+}
+
+func (p *awkParser) program() (*awkProgram, error) {
+	prog := &awkProgram{}
+	p.skipTerminators()
+	for p.tok().kind != awkEOF {
+		switch {
+		case p.is("BEGIN"), p.is("END"):
+			begin := p.is("BEGIN")
+			p.pos++
+			p.skipNewlines()
+			block, err := p.block()
+			if err != nil {
+				return nil, err
+			}
+			if begin {
+				prog.begin = append(prog.begin, block)
+			} else {
+				prog.end = append(prog.end, block)
+			}
+		case p.is("function"):
+			return nil, p.errorf("user-defined functions are not supported")
+		default:
+			item := &awkItem{}
+			if !p.is("{") {
+				pattern, err := p.expr()
+				if err != nil {
+					return nil, err
+				}
+				item.pattern = pattern
+				if p.is(",") {
+					p.pos++
+					p.skipNewlines()
+					if item.end, err = p.expr(); err != nil {
+						return nil, err
+					}
+				}
+			}
+			if p.is("{") {
+				block, err := p.block()
+				if err != nil {
+					return nil, err
+				}
+				item.action = block
+			}
+			prog.items = append(prog.items, item)
+		}
+		if t := p.tok(); t.kind != awkEOF && t.kind != awkNewline && !p.is(";") && !p.is("BEGIN") && !p.is("END") && !p.is("{") {
+			if p.tokens[p.pos-1].kind != awkPunct || p.tokens[p.pos-1].text != "}" {
+				return nil, p.errorf("unexpected %s", p.describe())
+			}
+		}
+		p.skipTerminators()
+	}
+	return prog, nil
+}
+
+// block parses { statements }
+func (p *awkParser) block() (*awkBlock, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	block := &awkBlock{}
+	p.skipTerminators()
+	for !p.is("}") {
+		if p.tok().kind == awkEOF {
+			return nil, p.errorf("missing '}'")
+		}
+		stmt, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		block.stmts = append(block.stmts, stmt)
+		p.skipTerminators()
+	}
+	p.pos++
+	return block, nil
+}
+
+// endSimple ends a simple statement: ; or newline, or before } or the end
+func (p *awkParser) endSimple() error {
+	switch {
+	case p.is(";"), p.tok().kind == awkNewline:
+		p.pos++
+	case p.is("}"), p.tok().kind == awkEOF:
+	default:
+		return p.errorf("unexpected %s", p.describe())
+	}
+	return nil
+}
+
+// body parses the statement controlled by if, while or for
+func (p *awkParser) body() (awkStmt, error) {
+	p.skipNewlines()
+	if p.is(";") {
+		p.pos++
+		return &awkBlock{}, nil
+	}
+	return p.statement()
+}
+
+func (p *awkParser) statement() (awkStmt, error) {
+	switch {
+	case p.is("{"):
+		return p.block()
+	case p.is("if"):
+		p.pos++
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		cond, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		then, err := p.body()
+		if err != nil {
+			return nil, err
+		}
+		stmt := &awkIf{cond: cond, then: then}
+		// else may follow a ; and newlines
+		save := p.pos
+		if p.is(";") {
+			p.pos++
+		}
+		p.skipNewlines()
+		if !p.is("else") {
+			p.pos = save
+			return stmt, nil
+		}
+		p.pos++
+		if stmt.otherwise, err = p.body(); err != nil {
+			return nil, err
+		}
+		return stmt, nil
+	case p.is("while"):
+		p.pos++
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		cond, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		if p.is(";") {
+			p.pos++
+			return &awkWhile{cond: cond, body: &awkBlock{}}, nil
+		}
+		body, err := p.body()
+		if err != nil {
+			return nil, err
+		}
+		return &awkWhile{cond: cond, body: body}, nil
+	case p.is("do"):
+		p.pos++
+		body, err := p.body()
+		if err != nil {
+			return nil, err
+		}
+		p.skipTerminators()
+		if err := p.expect("while"); err != nil {
+			return nil, err
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		cond, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return &awkWhile{cond: cond, body: body, doWhile: true}, p.endSimple()
+	case p.is("for"):
+		return p.forStatement()
+	case p.is(";"):
+		p.pos++
+		return &awkBlock{}, nil
+	}
+
+	stmt, err := p.simpleStatement()
+	if err != nil {
+		return nil, err
+	}
+	return stmt, p.endSimple()
+}
+
+func (p *awkParser) forStatement() (awkStmt, error) {
+	p.pos++
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	// for (key in array)
+	if t := p.tokens[p.pos:]; len(t) >= 4 && t[0].kind == awkName && t[1].kind == awkKeyword && t[1].text == "in" &&
+		t[2].kind == awkName && t[3].kind == awkPunct && t[3].text == ")" {
+		p.pos += 4
+		body, err := p.body()
+		if err != nil {
+			return nil, err
+		}
+		return &awkForIn{key: t[0].text, array: t[2].text, body: body}, nil
+	}
+
+	stmt := &awkFor{}
+	var err error
+	if !p.is(";") {
+		if stmt.init, err = p.simpleStatement(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect(";"); err != nil {
+		return nil, err
+	}
+	p.skipNewlines()
+	if !p.is(";") {
+		if stmt.cond, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect(";"); err != nil {
+		return nil, err
+	}
+	p.skipNewlines()
+	if !p.is(")") {
+		if stmt.post, err = p.simpleStatement(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if p.is(";") {
+		p.pos++
+		stmt.body = &awkBlock{}
+		return stmt, nil
+	}
+	if stmt.body, err = p.body(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+func (p *awkParser) simpleStatement() (awkStmt, error) {
+	switch {
+	case p.is("print"), p.is("printf"):
+		printf := p.is("printf")
+		p.pos++
+		stmt := &awkPrint{printf: printf}
+		if !p.is(";") && !p.is("}") && !p.is(">") && !p.is(">>") && !p.is("|") && p.tok().kind != awkNewline && p.tok().kind != awkEOF {
+			p.noGreater = true
+			args, err := p.exprList()
+			p.noGreater = false
+			if err != nil {
+				return nil, err
+			}
+			if len(args) == 1 {
+				if group, ok := args[0].(*awkGroup); ok {
+					args = group.exprs
+				}
+			}
+			stmt.args = args
+		}
+		if p.is(">") || p.is(">>") || p.is("|") {
+			return nil, p.errorf("output redirection is not supported")
+		}
+		if printf && len(stmt.args) == 0 {
+			return nil, p.errorf("printf: missing format")
+		}
+		return stmt, nil
+	case p.is("next"):
+		p.pos++
+		return &awkNext{}, nil
+	case p.is("exit"):
+		p.pos++
+		stmt := &awkExitStmt{}
+		if !p.is(";") && !p.is("}") && p.tok().kind != awkNewline && p.tok().kind != awkEOF {
+			status, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			stmt.status = status
+		}
+		return stmt, nil
+	case p.is("break"):
+		p.pos++
+		return &awkBreak{}, nil
+	case p.is("continue"):
+		p.pos++
+		return &awkContinue{}, nil
+	case p.is("delete"):
+		p.pos++
+		if p.tok().kind != awkName {
+			return nil, p.errorf("delete: expected array name, got %s", p.describe())
+		}
+		stmt := &awkDelete{array: p.tok().text}
+		p.pos++
+		if p.is("[") {
+			p.pos++
+			subs, err := p.exprList()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			stmt.subs = subs
+		}
+		return stmt, nil
+	case p.is("getline"):
+		return nil, p.errorf("getline is not supported")
+	case p.is("return"):
+		return nil, p.errorf("return outside a function")
+	}
+	expr, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &awkExprStmt{expr: expr}, nil
+}
+
+// exprList parses expressions separated by commas
+func (p *awkParser) exprList() ([]awkExpr, error) {
+	var exprs []awkExpr
+	for {
+		expr, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+		if !p.is(",") {
+			return exprs, nil
+		}
+		p.pos++
+		p.skipNewlines()
+	}
+}
+
+// expr parses an expression, assignments included
+func (p *awkParser) expr() (awkExpr, error) {
+	left, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.tok(); t.kind == awkPunct && (t.text == "=" || len(t.text) >= 2 && strings.HasSuffix(t.text, "=") && !strings.Contains("=<>!", t.text[:1])) {
+		if !isAwkLvalue(left) {
+			return nil, p.errorf("cannot assign to this expression")
+		}
+		p.pos++
+		p.skipNewlines()
+		value, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		op := t.text
+		if op == "**=" {
+			op = "^="
+		}
+		return &awkAssign{target: left, op: op, value: value}, nil
+	}
+	return left, nil
+}
+
+func isAwkLvalue(expr awkExpr) bool {
+	switch expr.(type) {
+	case *awkVar, *awkField, *awkIndex:
+		return true
+	}
+	return false
+}
+
+func (p *awkParser) ternary() (awkExpr, error) {
+	cond, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if !p.is("?") {
+		return cond, nil
+	}
+	p.pos++
+	p.skipNewlines()
+	ifTrue, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipNewlines()
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	p.skipNewlines()
+	ifFalse, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &awkCond{cond: cond, ifTrue: ifTrue, ifFalse: ifFalse}, nil
+}
+
+// awkLevels are the binary operators by precedence, lowest first; "in" and
+// concatenation ("") have levels of their own
+var awkLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"in"},
+	{"~", "!~"},
+	{"<", "<=", "!=", "==", ">", ">="},
+	{""},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// binary parses the binary operators of precedence level and above
+func (p *awkParser) binary(level int) (awkExpr, error) {
+	if level == len(awkLevels) {
+		return p.unary()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	ops := awkLevels[level]
+	for {
+		switch {
+		case ops[0] == "in":
+			if !p.is("in") {
+				return left, nil
+			}
+			p.pos++
+			if p.tok().kind != awkName {
+				return nil, p.errorf("in: expected array name, got %s", p.describe())
+			}
+			subs := []awkExpr{left}
+			if group, ok := left.(*awkGroup); ok {
+				subs = group.exprs
+			}
+			left = &awkIn{subs: subs, array: p.tok().text}
+			p.pos++
+			continue
+		case ops[0] == "":
+			if !p.startsConcat() {
+				return left, nil
+			}
+			right, err := p.binary(level + 1)
+			if err != nil {
+				return nil, err
+			}
+			left = &awkBinary{op: "", left: left, right: right}
+			continue
+		}
+
+		op := ""
+		for _, candidate := range ops {
+			if p.tok().kind == awkPunct && p.tok().text == candidate {
+				op = candidate
+			}
+		}
+		if op == "" || op == ">" && p.noGreater {
+			return left, nil
+		}
+		p.pos++
+		if op == "&&" || op == "||" {
+			p.skipNewlines()
+		}
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &awkBinary{op: op, left: left, right: right}
+		// Comparisons do not chain
+		if level == 4 {
+			return left, nil
+		}
+	}
+}
+
+// startsConcat reports whether the current token starts an operand that is
+// concatenated to the expression before it
+func (p *awkParser) startsConcat() bool {
+	switch t := p.tok(); t.kind {
+	case awkNumber, awkString, awkRegex, awkName, awkFunc:
+		return true
+	case awkPunct:
+		return t.text == "$" || t.text == "(" || t.text == "++" || t.text == "--"
+	}
+	return false
+}
+
+func (p *awkParser) unary() (awkExpr, error) {
+	if p.is("!") || p.is("-") || p.is("+") {
+		op := p.tok().text
+		p.pos++
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &awkUnary{op: op, operand: operand}, nil
+	}
+	return p.power()
+}
+
+// power parses ^, which is right associative and binds tighter than unary
+// minus: -2^2 is -4
+func (p *awkParser) power() (awkExpr, error) {
+	base, err := p.postfix()
+	if err != nil {
+		return nil, err
+	}
+	if !p.is("^") && !p.is("**") {
+		return base, nil
+	}
+	p.pos++
+	exponent, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return &awkBinary{op: "^", left: base, right: exponent}, nil
+}
+
+func (p *awkParser) postfix() (awkExpr, error) {
+	if p.is("++") || p.is("--") {
+		delta := 1.0
+		if p.is("--") {
+			delta = -1
+		}
+		p.pos++
+		target, err := p.postfix()
+		if err != nil {
+			return nil, err
+		}
+		if !isAwkLvalue(target) {
+			return nil, p.errorf("++ or -- needs a variable, field or array element")
+		}
+		return &awkIncDec{target: target, delta: delta, prefix: true}, nil
+	}
+	expr, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if (p.is("++") || p.is("--")) && isAwkLvalue(expr) {
+		delta := 1.0
+		if p.is("--") {
+			delta = -1
+		}
+		p.pos++
+		return &awkIncDec{target: expr, delta: delta}, nil
+	}
+	return expr, nil
+}
+
+func (p *awkParser) primary() (awkExpr, error) {
+	t := p.tok()
+	switch t.kind {
+	case awkNumber:
+		p.pos++
+		return &awkNumLit{value: t.num}, nil
+	case awkString:
+		p.pos++
+		return &awkStrLit{value: t.text}, nil
+	case awkRegex:
+		p.pos++
+		re, err := regexp.Compile(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid regex /%s/: %w", t.line, t.text, err)
+		}
+		return &awkRegexLit{re: re}, nil
+	case awkName:
+		p.pos++
+		if !p.is("[") {
+			return &awkVar{name: t.text}, nil
+		}
+		p.pos++
+		subs, err := p.exprList()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return &awkIndex{name: t.text, subs: subs}, nil
+	case awkFunc:
+		p.pos++
+		call := &awkCall{name: t.text}
+		if !p.is("(") {
+			if t.text == "length" {
+				return call, nil
+			}
+			return nil, p.errorf("%s: expected '('", t.text)
+		}
+		p.pos++
+		if !p.is(")") {
+			saveGreater := p.noGreater
+			p.noGreater = false
+			args, err := p.exprList()
+			p.noGreater = saveGreater
+			if err != nil {
+				return nil, err
+			}
+			call.args = args
+		}
+		return call, p.expect(")")
+	case awkPunct:
+		switch t.text {
+		case "$":
+			p.pos++
+			index, err := p.fieldIndex()
+			if err != nil {
+				return nil, err
+			}
+			return &awkField{index: index}, nil
+		case "(":
+			p.pos++
+			saveGreater := p.noGreater
+			p.noGreater = false
+			exprs, err := p.exprList()
+			p.noGreater = saveGreater
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			if len(exprs) > 1 {
+				return &awkGroup{exprs: exprs}, nil
+			}
+			return exprs[0], nil
+		case "-", "+", "!":
+			return p.unary()
+		}
+	case awkKeyword:
+		if t.text == "getline" {
+			return nil, p.errorf("getline is not supported")
+		}
+	}
+	return nil, p.errorf("unexpected %s", p.describe())
+}
+
+// fieldIndex parses the operand of $, which binds tighter than ++ and --
+func (p *awkParser) fieldIndex() (awkExpr, error) {
+	if p.is("-") || p.is("+") || p.is("!") {
+		op := p.tok().text
+		p.pos++
+		operand, err := p.fieldIndex()
+		if err != nil {
+			return nil, err
+		}
+		return &awkUnary{op: op, operand: operand}, nil
+	}
+	if p.is("++") || p.is("--") {
+		return p.postfix()
+	}
+	return p.primary()
+}
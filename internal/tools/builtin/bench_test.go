@@ -0,0 +1,88 @@
+package builtin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+)
+
+// benchSizes are the input sizes used by the builtin benchmarks.
+// The 100MB size only runs with LLMCMD_BENCH_LARGE=1.
+var benchSizes = []struct {
+	name  string
+	bytes int
+	large bool
+}{
+	{name: "1MB", bytes: 1 << 20},
+	{name: "100MB", bytes: 100 << 20, large: true},
+}
+
+// benchInput builds deterministic, unsorted text of about size bytes
+func benchInput(size int) []byte {
+	var buf bytes.Buffer
+	for i := 0; buf.Len() < size; i++ {
+		level := "INFO"
+		if i%7 == 0 {
+			level = "ERROR"
+		}
+		fmt.Fprintf(&buf, "%08d %s request handled in %dms by worker-%d\n", (i*7919)%1000003, level, i%997, i%16)
+	}
+	return buf.Bytes()
+}
+
+// runBuiltinBenchmark runs cmd over input for each benchmark size
+func runBuiltinBenchmark(b *testing.B, cmd func([]string, io.Reader, io.Writer) error, args []string, makeInput func(int) []byte) {
+	for _, size := range benchSizes {
+		b.Run(size.name, func(b *testing.B) {
+			if size.large && os.Getenv("LLMCMD_BENCH_LARGE") != "1" {
+				b.Skip("set LLMCMD_BENCH_LARGE=1 to run large inputs")
+			}
+			input := makeInput(size.bytes)
+			b.SetBytes(int64(len(input)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := cmd(args, bytes.NewReader(input), io.Discard); err != nil {
+					b.Fatalf("command failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGrep(b *testing.B) {
+	runBuiltinBenchmark(b, Grep, []string{"ERROR"}, benchInput)
+}
+
+func BenchmarkSort(b *testing.B) {
+	runBuiltinBenchmark(b, Sort, nil, benchInput)
+}
+
+func BenchmarkSortUniq(b *testing.B) {
+	fused := func(args []string, stdin io.Reader, stdout io.Writer) error {
+		return SortUniq([]string{"-k2,2"}, args, stdin, stdout)
+	}
+	runBuiltinBenchmark(b, fused, []string{"-c", "-f1", "-w5"}, benchInput)
+}
+
+func BenchmarkDiff(b *testing.B) {
+	runBuiltinBenchmark(b, Diff, nil, func(size int) []byte {
+		// Two halves that differ in every 50th line
+		original := benchInput(size / 2)
+		modified := bytes.Clone(original)
+		for i, line := 0, 0; i < len(modified); i++ {
+			if modified[i] == '\n' {
+				line++
+				if line%50 == 0 && i+1 < len(modified) {
+					modified[i+1] = 'X'
+				}
+			}
+		}
+		var buf bytes.Buffer
+		buf.Write(original)
+		buf.WriteString("---LLMCMD_DIFF_SEPARATOR---\n")
+		buf.Write(modified)
+		return buf.Bytes()
+	})
+}
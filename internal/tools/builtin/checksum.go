@@ -0,0 +1,301 @@
+package builtin
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// checksumAlgorithm is a hash a checksum command computes
+type checksumAlgorithm struct {
+	command string
+	tag     string // The name in --tag lines, as in SHA256 (file) = ...
+	new     func() hash.Hash
+}
+
+var (
+	sha256Algorithm = checksumAlgorithm{"sha256sum", "SHA256", sha256.New}
+	md5Algorithm    = checksumAlgorithm{"md5sum", "MD5", md5.New}
+)
+
+// checksumOptions are the parsed options of a checksum command
+type checksumOptions struct {
+	check         bool
+	tag           bool
+	binary        bool
+	quiet         bool // -c: do not print OK lines
+	status        bool // -c: print nothing; only the result tells
+	ignoreMissing bool // -c: skip files that do not exist
+	warn          bool // -c: report improperly formatted lines
+	strict        bool // -c: fail on improperly formatted lines
+	operands      []string
+}
+
+// Sha256sum prints the SHA-256 checksum of stdin, or with -c verifies the
+// checksums listed on stdin
+func Sha256sum(args []string, stdin io.Reader, stdout io.Writer) error {
+	return runChecksum(sha256Algorithm, args, stdin, stdout, nil)
+}
+
+// Md5sum prints the MD5 checksum of stdin, or with -c verifies the
+// checksums listed on stdin
+func Md5sum(args []string, stdin io.Reader, stdout io.Writer) error {
+	return runChecksum(md5Algorithm, args, stdin, stdout, nil)
+}
+
+// RunSha256sum is Sha256sum with access to files, so operands and the
+// files listed for -c can be read
+func RunSha256sum(args []string, stdin io.Reader, stdout io.Writer, open OpenFunc) error {
+	return runChecksum(sha256Algorithm, args, stdin, stdout, open)
+}
+
+// RunMd5sum is Md5sum with access to files, so operands and the files
+// listed for -c can be read
+func RunMd5sum(args []string, stdin io.Reader, stdout io.Writer, open OpenFunc) error {
+	return runChecksum(md5Algorithm, args, stdin, stdout, open)
+}
+
+// runChecksum prints a line per operand in the format of GNU coreutils,
+// "-" or no operands meaning stdin
+func runChecksum(algo checksumAlgorithm, args []string, stdin io.Reader, stdout io.Writer, open OpenFunc) error {
+	opts, err := parseChecksumArgs(algo.command, args)
+	if err != nil {
+		return err
+	}
+	operands := opts.operands
+	if len(operands) == 0 {
+		operands = []string{"-"}
+	}
+	for _, name := range operands {
+		if name != "-" && open == nil {
+			return fmt.Errorf("%s: %s: file operands are not supported; pipe the input instead", algo.command, name)
+		}
+	}
+
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+	if opts.check {
+		return checkChecksums(algo, opts, operands, stdin, out, open)
+	}
+
+	var firstErr error
+	for _, name := range operands {
+		sum, err := checksumOf(algo, name, stdin, open)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %s: %w", algo.command, name, err)
+			}
+			continue
+		}
+		switch {
+		case opts.tag:
+			fmt.Fprintf(out, "%s (%s) = %s\n", algo.tag, name, sum)
+		case opts.binary:
+			fmt.Fprintf(out, "%s *%s\n", sum, name)
+		default:
+			fmt.Fprintf(out, "%s  %s\n", sum, name)
+		}
+	}
+	return firstErr
+}
+
+// parseChecksumArgs parses the options GNU sha256sum and md5sum share
+func parseChecksumArgs(command string, args []string) (checksumOptions, error) {
+	var opts checksumOptions
+	for i, arg := range args {
+		switch arg {
+		case "--":
+			opts.operands = append(opts.operands, args[i+1:]...)
+			return opts, nil
+		case "--check":
+			opts.check = true
+			continue
+		case "--tag":
+			opts.tag = true
+			continue
+		case "--binary":
+			opts.binary = true
+			continue
+		case "--text":
+			opts.binary = false
+			continue
+		case "--quiet":
+			opts.quiet = true
+			continue
+		case "--status":
+			opts.status = true
+			continue
+		case "--ignore-missing":
+			opts.ignoreMissing = true
+			continue
+		case "--warn":
+			opts.warn = true
+			continue
+		case "--strict":
+			opts.strict = true
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			opts.operands = append(opts.operands, arg)
+			continue
+		}
+		if strings.HasPrefix(arg, "--") {
+			return opts, fmt.Errorf("%s: %s: invalid option", command, arg)
+		}
+		for _, c := range arg[1:] {
+			switch c {
+			case 'c':
+				opts.check = true
+			case 'b':
+				opts.binary = true
+			case 't':
+				opts.binary = false
+			case 'w':
+				opts.warn = true
+			default:
+				return opts, fmt.Errorf("%s: -%c: invalid option", command, c)
+			}
+		}
+	}
+	if opts.tag && opts.check {
+		return opts, fmt.Errorf("%s: the --tag option is meaningless when verifying checksums", command)
+	}
+	return opts, nil
+}
+
+// checksumOf returns the hex checksum of stdin ("-") or a file
+func checksumOf(algo checksumAlgorithm, name string, stdin io.Reader, open OpenFunc) (string, error) {
+	reader := stdin
+	if name != "-" {
+		file, err := open(name)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		reader = file
+	}
+	h := algo.new()
+	if _, err := io.Copy(h, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checkChecksums verifies the checksum lists named by the operands,
+// printing a line per file unless --quiet or --status says otherwise. The
+// result is an error when a checksum did not match, a file could not be
+// read, or no line was properly formatted.
+func checkChecksums(algo checksumAlgorithm, opts checksumOptions, lists []string, stdin io.Reader, out *bufio.Writer, open OpenFunc) error {
+	var mismatched, unreadable, improper int
+	for _, list := range lists {
+		reader := stdin
+		if list != "-" {
+			file, err := open(list)
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", algo.command, list, err)
+			}
+			defer file.Close()
+			reader = file
+		}
+
+		scanner := bufio.NewScanner(reader)
+		lineNumber, proper := 0, 0
+		for scanner.Scan() {
+			lineNumber++
+			want, name, ok := parseChecksumLine(algo, scanner.Text())
+			if !ok {
+				improper++
+				if opts.warn && !opts.status {
+					fmt.Fprintf(out, "%s: %s: %d: improperly formatted %s checksum line\n", algo.command, list, lineNumber, algo.tag)
+				}
+				continue
+			}
+			proper++
+
+			var sum string
+			var err error
+			if name == "-" && list == "-" {
+				err = fmt.Errorf("stdin holds the checksum list")
+			} else if name != "-" && open == nil {
+				err = fmt.Errorf("file operands are not supported")
+			} else {
+				sum, err = checksumOf(algo, name, stdin, open)
+			}
+			result := "OK"
+			switch {
+			case err != nil && opts.ignoreMissing:
+				continue
+			case err != nil:
+				unreadable++
+				result = "FAILED open or read"
+			case !strings.EqualFold(sum, want):
+				mismatched++
+				result = "FAILED"
+			}
+			if !opts.status && !(opts.quiet && result == "OK") {
+				fmt.Fprintf(out, "%s: %s\n", name, result)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("%s: %s: %w", algo.command, list, err)
+		}
+		if proper == 0 {
+			return fmt.Errorf("%s: %s: no properly formatted %s checksum lines found", algo.command, list, algo.tag)
+		}
+	}
+
+	var problems []string
+	if unreadable > 0 {
+		problems = append(problems, checksumCount(unreadable, "listed file could not be read", "listed files could not be read"))
+	}
+	if mismatched > 0 {
+		problems = append(problems, checksumCount(mismatched, "computed checksum did NOT match", "computed checksums did NOT match"))
+	}
+	if improper > 0 && (opts.strict || len(problems) > 0) {
+		problems = append(problems, checksumCount(improper, "line is improperly formatted", "lines are improperly formatted"))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s: WARNING: %s", algo.command, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func checksumCount(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
+
+// parseChecksumLine parses a line of a checksum list: "HASH  NAME",
+// "HASH *NAME", or the --tag format "TAG (NAME) = HASH"
+func parseChecksumLine(algo checksumAlgorithm, line string) (string, string, bool) {
+	size := 2 * algo.new().Size()
+	validHash := func(sum string) bool {
+		_, err := hex.DecodeString(sum)
+		return len(sum) == size && err == nil
+	}
+
+	if rest, ok := strings.CutPrefix(line, algo.tag+" ("); ok {
+		end := strings.LastIndex(rest, ") = ")
+		if end < 0 {
+			return "", "", false
+		}
+		sum := rest[end+len(") = "):]
+		return sum, rest[:end], validHash(sum)
+	}
+
+	if len(line) < size+2 || !validHash(line[:size]) || line[size] != ' ' {
+		return "", "", false
+	}
+	if line[size+1] != ' ' && line[size+1] != '*' {
+		return "", "", false
+	}
+	name := line[size+2:]
+	return line[:size], name, name != ""
+}
@@ -0,0 +1,171 @@
+package builtin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestChecksumCommands(t *testing.T) {
+	const (
+		helloSHA256 = "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+		helloMD5    = "b1946ac92492d2347c6235b4d2611184"
+		emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	)
+	files := map[string]string{
+		"hello.txt": "hello\n",
+		"empty.txt": "",
+		"sums.md5":  helloMD5 + "  hello.txt\n",
+	}
+	open := func(name string) (io.ReadCloser, error) {
+		data, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("file not found")
+		}
+		return io.NopCloser(strings.NewReader(data)), nil
+	}
+
+	tests := []struct {
+		name           string
+		command        func(args []string, stdin io.Reader, stdout io.Writer, open OpenFunc) error
+		args           []string
+		input          string
+		noFiles        bool
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name:           "sha256sum of stdin",
+			command:        RunSha256sum,
+			input:          "hello\n",
+			expectedOutput: helloSHA256 + "  -\n",
+		},
+		{
+			name:           "md5sum of stdin",
+			command:        RunMd5sum,
+			input:          "hello\n",
+			expectedOutput: helloMD5 + "  -\n",
+		},
+		{
+			name:           "files",
+			command:        RunSha256sum,
+			args:           []string{"hello.txt", "empty.txt"},
+			expectedOutput: helloSHA256 + "  hello.txt\n" + emptySHA256 + "  empty.txt\n",
+		},
+		{
+			name:           "binary format",
+			command:        RunMd5sum,
+			args:           []string{"-b", "hello.txt"},
+			expectedOutput: helloMD5 + " *hello.txt\n",
+		},
+		{
+			name:           "tag format",
+			command:        RunSha256sum,
+			args:           []string{"--tag", "hello.txt"},
+			expectedOutput: "SHA256 (hello.txt) = " + helloSHA256 + "\n",
+		},
+		{
+			name:           "check",
+			command:        RunSha256sum,
+			args:           []string{"-c"},
+			input:          helloSHA256 + "  hello.txt\nSHA256 (empty.txt) = " + strings.ToUpper(emptySHA256) + "\n",
+			expectedOutput: "hello.txt: OK\nempty.txt: OK\n",
+		},
+		{
+			name:           "check mismatch",
+			command:        RunMd5sum,
+			args:           []string{"--check"},
+			input:          helloMD5 + "  empty.txt\n" + helloMD5 + " *hello.txt\n",
+			expectedOutput: "empty.txt: FAILED\nhello.txt: OK\n",
+			expectedError:  "md5sum: WARNING: 1 computed checksum did NOT match",
+		},
+		{
+			name:           "check missing file quietly",
+			command:        RunSha256sum,
+			args:           []string{"-c", "--quiet"},
+			input:          helloSHA256 + "  hello.txt\n" + helloSHA256 + "  missing.txt\n",
+			expectedOutput: "missing.txt: FAILED open or read\n",
+			expectedError:  "1 listed file could not be read",
+		},
+		{
+			name:           "check ignoring missing files",
+			command:        RunSha256sum,
+			args:           []string{"-c", "--ignore-missing"},
+			input:          helloSHA256 + "  hello.txt\n" + helloSHA256 + "  missing.txt\n",
+			expectedOutput: "hello.txt: OK\n",
+		},
+		{
+			name:           "check status only",
+			command:        RunSha256sum,
+			args:           []string{"-c", "--status"},
+			input:          emptySHA256 + "  hello.txt\n",
+			expectedOutput: "",
+			expectedError:  "did NOT match",
+		},
+		{
+			name:           "check list from a file",
+			command:        RunMd5sum,
+			args:           []string{"-c", "sums.md5"},
+			expectedOutput: "hello.txt: OK\n",
+		},
+		{
+			name:          "check without proper lines",
+			command:       RunSha256sum,
+			args:          []string{"-c"},
+			input:         "not a checksum\n" + helloMD5 + "  hello.txt\n",
+			expectedError: "sha256sum: -: no properly formatted SHA256 checksum lines found",
+		},
+		{
+			name:           "check strict",
+			command:        RunSha256sum,
+			args:           []string{"-c", "--strict"},
+			input:          "junk\n" + helloSHA256 + "  hello.txt\n",
+			expectedOutput: "hello.txt: OK\n",
+			expectedError:  "1 line is improperly formatted",
+		},
+		{
+			name:          "file operand without files",
+			command:       RunSha256sum,
+			args:          []string{"hello.txt"},
+			noFiles:       true,
+			expectedError: "sha256sum: hello.txt: file operands are not supported",
+		},
+		{
+			name:          "missing file",
+			command:       RunSha256sum,
+			args:          []string{"missing.txt"},
+			expectedError: "sha256sum: missing.txt: file not found",
+		},
+		{
+			name:          "invalid option",
+			command:       RunMd5sum,
+			args:          []string{"-x"},
+			expectedError: "md5sum: -x: invalid option",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opener := OpenFunc(open)
+			if test.noFiles {
+				opener = nil
+			}
+			var output bytes.Buffer
+			err := test.command(test.args, strings.NewReader(test.input), &output, opener)
+			if test.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Errorf("expected error containing %q, got %v", test.expectedError, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.expectedOutput != "" || test.expectedError == "" {
+				if output.String() != test.expectedOutput {
+					t.Errorf("expected output %q, got %q", test.expectedOutput, output.String())
+				}
+			}
+		})
+	}
+}
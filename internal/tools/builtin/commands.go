@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"regexp"
-	"sort"
 	"strconv"
 	"strings"
 )
@@ -28,8 +27,49 @@ var Commands = map[string]CommandFunc{
 	"nl":         Nl,
 	"tee":        Tee,
 	"rev":   Rev,
+	"awk":   Awk,
+	"jq":    Jq,
+	"csvcut":  CsvCut,
+	"csvgrep": CsvGrep,
+	"csvjoin": CsvJoin,
+	"rextract":  Rextract,
+	"yaml2json": Yaml2json,
+	"json2yaml": Json2yaml,
+	"toml2json": Toml2json,
+	"json2toml": Json2toml,
+	"base64":  Base64,
+	"xxd":     Xxd,
+	"gzip":    Gzip,
+	"gunzip":  Gunzip,
+	"zcat":    Zcat,
+	"bzip2":   Bzip2,
+	"bunzip2": Bunzip2,
+	"bzcat":   Bzcat,
+	"xz":      Xz,
+	"unxz":    Unxz,
+	"xzcat":   Xzcat,
+	"tar":     Tar,
+	"sha256sum": Sha256sum,
+	"md5sum":    Md5sum,
+	"join":      Join,
+	"paste":     Paste,
+	"comm":      Comm,
+	"date":      Date,
+	"seq":       Seq,
+	"printf":    Printf,
+	"tac":       Tac,
+	"shuf":      Shuf,
+	"strings":   Strings,
+	"column":    Column,
+	"expand":    Expand,
+	"unexpand":  Unexpand,
+	"fold":      Fold,
+	"fmt":       Fmt,
+	"stats":     Stats,
 	"diff":  Diff,
 	"patch": Patch,
+	"diff3": Diff3,
+	"merge": Merge,
 	"help":  GetHelp,
 }
 
@@ -60,107 +100,6 @@ func Cat(args []string, stdin io.Reader, stdout io.Writer) error {
 	return err
 }
 
-// Grep searches for patterns in text (basic regex support)
-func Grep(args []string, stdin io.Reader, stdout io.Writer) error {
-	if len(args) == 0 {
-		return fmt.Errorf("grep: missing pattern")
-	}
-
-	pattern := args[0]
-	invertMatch := false
-	ignoreCase := false
-	lineNumber := false
-
-	// Parse flags (simplified)
-	finalPattern := pattern
-	for _, arg := range args {
-		if strings.HasPrefix(arg, "-") {
-			switch arg {
-			case "-v":
-				invertMatch = true
-			case "-i":
-				ignoreCase = true
-			case "-n":
-				lineNumber = true
-			}
-		} else {
-			finalPattern = arg
-			break
-		}
-	}
-
-	// Compile regex using common function
-	regex, err := compileRegex(finalPattern, ignoreCase)
-	if err != nil {
-		return err
-	}
-
-	scanner := bufio.NewScanner(stdin)
-	lineNum := 1
-	for scanner.Scan() {
-		line := scanner.Text()
-		matches := regex.MatchString(line)
-
-		if matches != invertMatch { // XOR logic
-			if lineNumber {
-				fmt.Fprintf(stdout, "%d:%s\n", lineNum, line)
-			} else {
-				fmt.Fprintln(stdout, line)
-			}
-		}
-		lineNum++
-	}
-
-	return scanner.Err()
-}
-
-// Sed performs basic text substitution (s/pattern/replacement/flags)
-func Sed(args []string, stdin io.Reader, stdout io.Writer) error {
-	if len(args) == 0 {
-		return fmt.Errorf("sed: missing expression")
-	}
-
-	expr := args[0]
-	if !strings.HasPrefix(expr, "s/") {
-		return fmt.Errorf("sed: only s/// substitution supported")
-	}
-
-	// Parse s/pattern/replacement/flags
-	parts := strings.Split(expr[2:], "/")
-	if len(parts) < 2 {
-		return fmt.Errorf("sed: invalid substitution format")
-	}
-
-	pattern := parts[0]
-	replacement := parts[1]
-	flags := ""
-	if len(parts) > 2 {
-		flags = parts[2]
-	}
-
-	globalReplace := strings.Contains(flags, "g")
-	ignoreCase := strings.Contains(flags, "i")
-
-	// Compile regex using common function
-	regex, err := compileRegex(pattern, ignoreCase)
-	if err != nil {
-		return err
-	}
-
-	scanner := bufio.NewScanner(stdin)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if globalReplace {
-			line = regex.ReplaceAllString(line, replacement)
-		} else {
-			line = regex.ReplaceAllString(line, replacement)
-		}
-		fmt.Fprintln(stdout, line)
-	}
-
-	return scanner.Err()
-}
-
 // Head outputs the first n lines (default 10)
 func Head(args []string, stdin io.Reader, stdout io.Writer) error {
 	n := 10
@@ -180,130 +119,19 @@ func Head(args []string, stdin io.Reader, stdout io.Writer) error {
 	return scanner.Err()
 }
 
-// Tail outputs the last n lines (default 10)
-func Tail(args []string, stdin io.Reader, stdout io.Writer) error {
-	n := 10
-	if len(args) > 0 && strings.HasPrefix(args[0], "-") {
-		if val, err := strconv.Atoi(args[0][1:]); err == nil {
-			n = val
-		}
-	}
-
-	// Read all lines into memory
-	var lines []string
-	scanner := bufio.NewScanner(stdin)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-
-	if err := scanner.Err(); err != nil {
-		return err
-	}
-
-	// Output last n lines
-	start := len(lines) - n
-	if start < 0 {
-		start = 0
-	}
-
-	for i := start; i < len(lines); i++ {
-		fmt.Fprintln(stdout, lines[i])
-	}
-
-	return nil
-}
-
-// Sort sorts lines of text
-func Sort(args []string, stdin io.Reader, stdout io.Writer) error {
-	reverse := false
-	numeric := false
-	unique := false
-
-	// Parse flags
-	for _, arg := range args {
-		switch arg {
-		case "-r":
-			reverse = true
-		case "-n":
-			numeric = true
-		case "-u":
-			unique = true
-		}
-	}
-
-	// Read all lines
-	var lines []string
-	scanner := bufio.NewScanner(stdin)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-
-	if err := scanner.Err(); err != nil {
-		return err
-	}
-
-	// Remove duplicates if unique flag is set
-	if unique {
-		seen := make(map[string]bool)
-		var uniqueLines []string
-		for _, line := range lines {
-			if !seen[line] {
-				seen[line] = true
-				uniqueLines = append(uniqueLines, line)
-			}
-		}
-		lines = uniqueLines
-	}
-
-	// Sort lines
-	if numeric {
-		sort.Slice(lines, func(i, j int) bool {
-			a, errA := strconv.ParseFloat(strings.TrimSpace(lines[i]), 64)
-			b, errB := strconv.ParseFloat(strings.TrimSpace(lines[j]), 64)
-
-			if errA != nil && errB != nil {
-				// Both are not numbers, sort lexically
-				result := lines[i] < lines[j]
-				return result != reverse
-			}
-			if errA != nil {
-				// a is not a number, b is
-				return reverse
-			}
-			if errB != nil {
-				// b is not a number, a is
-				return !reverse
-			}
-			// Both are numbers
-			result := a < b
-			return result != reverse
-		})
-	} else {
-		sort.Slice(lines, func(i, j int) bool {
-			result := lines[i] < lines[j]
-			return result != reverse
-		})
-	}
-
-	// Output sorted lines
-	for _, line := range lines {
-		fmt.Fprintln(stdout, line)
-	}
-
-	return nil
-}
-
 // Wc counts lines, words, and characters
 func Wc(args []string, stdin io.Reader, stdout io.Writer) error {
 	lines := 0
 	words := 0
 	chars := 0
 	bytes := 0
+	longest := 0
 
 	showLines := true
 	showWords := true
 	showChars := true
 	showBytes := false
+	showLongest := false
 
 	// Parse flags
 	flagCount := 0
@@ -333,6 +161,12 @@ func Wc(args []string, stdin io.Reader, stdout io.Writer) error {
 			}
 			showBytes = true
 			flagCount++
+		case "-L", "--max-line-length":
+			if flagCount == 0 {
+				showLines, showWords, showChars = false, false, false
+			}
+			showLongest = true
+			flagCount++
 		}
 	}
 
@@ -342,6 +176,7 @@ func Wc(args []string, stdin io.Reader, stdout io.Writer) error {
 		lines++
 		chars += len([]rune(line)) + 1 // +1 for newline
 		bytes += len(line) + 1
+		longest = max(longest, indentWidth(line))
 
 		// Count words
 		wordScanner := bufio.NewScanner(strings.NewReader(line))
@@ -361,83 +196,9 @@ func Wc(args []string, stdin io.Reader, stdout io.Writer) error {
 	output = appendCount(output, words, showWords)
 	output = appendCount(output, bytes, showBytes)
 	output = appendCount(output, chars, showChars && !showBytes)
+	output = appendCount(output, longest, showLongest)
 
 	fmt.Fprintln(stdout, strings.Join(output, " "))
 	return nil
 }
 
-// Tr translates or deletes characters
-func Tr(args []string, stdin io.Reader, stdout io.Writer) error {
-	if len(args) < 1 {
-		return fmt.Errorf("tr: missing operand")
-	}
-
-	delete := false
-	if args[0] == "-d" {
-		delete = true
-		args = args[1:]
-		if len(args) < 1 {
-			return fmt.Errorf("tr: missing character set")
-		}
-	}
-
-	if delete {
-		// Delete characters mode
-		deleteSet := args[0]
-		deleteRunes := make(map[rune]bool)
-		for _, r := range deleteSet {
-			deleteRunes[r] = true
-		}
-
-		scanner := bufio.NewScanner(stdin)
-		for scanner.Scan() {
-			line := scanner.Text()
-			var result strings.Builder
-			for _, r := range line {
-				if !deleteRunes[r] {
-					result.WriteRune(r)
-				}
-			}
-			fmt.Fprintln(stdout, result.String())
-		}
-		return scanner.Err()
-	}
-
-	// Translation mode
-	if len(args) < 2 {
-		return fmt.Errorf("tr: missing replacement set")
-	}
-
-	fromSet := args[0]
-	toSet := args[1]
-
-	fromRunes := []rune(fromSet)
-	toRunes := []rune(toSet)
-
-	// Create translation map
-	translation := make(map[rune]rune)
-	for i, r := range fromRunes {
-		if i < len(toRunes) {
-			translation[r] = toRunes[i]
-		} else if len(toRunes) > 0 {
-			// Use last character for remaining translations
-			translation[r] = toRunes[len(toRunes)-1]
-		}
-	}
-
-	scanner := bufio.NewScanner(stdin)
-	for scanner.Scan() {
-		line := scanner.Text()
-		var result strings.Builder
-		for _, r := range line {
-			if newR, exists := translation[r]; exists {
-				result.WriteRune(newR)
-			} else {
-				result.WriteRune(r)
-			}
-		}
-		fmt.Fprintln(stdout, result.String())
-	}
-
-	return scanner.Err()
-}
@@ -74,3 +74,21 @@ func TestWc(t *testing.T) {
 		t.Errorf("Wc words = %s, want 6", parts[1])
 	}
 }
+
+func TestWcMaxLineLength(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-L"}, "9\n"},
+		{[]string{"-l", "-L"}, "3 9\n"},
+	} {
+		var output strings.Builder
+		if err := Wc(tt.args, strings.NewReader("ab\n\tx\n日本語\n"), &output); err != nil {
+			t.Fatalf("Wc %v failed: %v", tt.args, err)
+		}
+		if output.String() != tt.want {
+			t.Errorf("Wc %v = %q, want %q", tt.args, output.String(), tt.want)
+		}
+	}
+}
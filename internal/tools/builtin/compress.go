@@ -0,0 +1,191 @@
+package builtin
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// compressOptions are the options gzip, bzip2 and xz share
+type compressOptions struct {
+	decompress bool
+	test       bool // Decompress and discard the output, only checking the data
+	level      int
+}
+
+// parseCompressArgs parses gzip-style options. -c, -k, -f and -q are
+// accepted and ignored, as output always goes to stdout; short options may
+// be combined, as in -dc.
+func parseCompressArgs(name string, args []string) (compressOptions, error) {
+	opts := compressOptions{level: gzip.DefaultCompression}
+	for _, arg := range args {
+		switch arg {
+		case "--decompress", "--uncompress":
+			opts.decompress = true
+			continue
+		case "--test":
+			opts.decompress, opts.test = true, true
+			continue
+		case "--stdout", "--to-stdout", "--keep", "--force", "--quiet":
+			continue
+		case "--best":
+			opts.level = gzip.BestCompression
+			continue
+		case "--fast":
+			opts.level = gzip.BestSpeed
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return opts, fmt.Errorf("%s: %s: file operands are not supported; pipe the input instead", name, arg)
+		}
+		if strings.HasPrefix(arg, "--") {
+			return opts, fmt.Errorf("%s: %s: invalid option", name, arg)
+		}
+		for _, c := range arg[1:] {
+			switch {
+			case c == 'd':
+				opts.decompress = true
+			case c == 't':
+				opts.decompress, opts.test = true, true
+			case c == 'c' || c == 'k' || c == 'f' || c == 'q':
+			case c >= '1' && c <= '9':
+				opts.level, _ = strconv.Atoi(string(c))
+			default:
+				return opts, fmt.Errorf("%s: -%c: invalid option", name, c)
+			}
+		}
+	}
+	return opts, nil
+}
+
+// decompressTo copies a decompressing reader to stdout, or for -t only
+// reads it through
+func decompressTo(name string, r io.Reader, opts compressOptions, stdout io.Writer) error {
+	if opts.test {
+		stdout = io.Discard
+	}
+	if _, err := io.Copy(stdout, r); err != nil {
+		return compressError(name, err)
+	}
+	return nil
+}
+
+// compressError describes a decompression failure the way gzip does
+func compressError(name string, err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%s: unexpected end of file", name)
+	}
+	return fmt.Errorf("%s: %w", name, err)
+}
+
+// Gzip compresses stdin to stdout in gzip format, or with -d decompresses
+// it. Concatenated gzip members are decompressed one after another.
+func Gzip(args []string, stdin io.Reader, stdout io.Writer) error {
+	return runGzip("gzip", args, stdin, stdout)
+}
+
+// Gunzip decompresses gzip data; it is gzip -d
+func Gunzip(args []string, stdin io.Reader, stdout io.Writer) error {
+	return runGzip("gunzip", append([]string{"-d"}, args...), stdin, stdout)
+}
+
+// Zcat decompresses gzip data to stdout; it is gzip -dc
+func Zcat(args []string, stdin io.Reader, stdout io.Writer) error {
+	return runGzip("zcat", append([]string{"-d"}, args...), stdin, stdout)
+}
+
+func runGzip(name string, args []string, stdin io.Reader, stdout io.Writer) error {
+	opts, err := parseCompressArgs(name, args)
+	if err != nil {
+		return err
+	}
+	if !opts.decompress {
+		writer, err := gzip.NewWriterLevel(stdout, opts.level)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if _, err := io.Copy(writer, stdin); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return writer.Close()
+	}
+
+	reader, err := gzip.NewReader(bufio.NewReader(stdin))
+	if err != nil {
+		if errors.Is(err, gzip.ErrHeader) {
+			return fmt.Errorf("%s: not in gzip format", name)
+		}
+		return compressError(name, err)
+	}
+	defer reader.Close()
+	return decompressTo(name, reader, opts, stdout)
+}
+
+// Bzip2 decompresses bzip2 data with -d. The standard library has no bzip2
+// compressor, so compressing is not supported.
+func Bzip2(args []string, stdin io.Reader, stdout io.Writer) error {
+	return runBzip2("bzip2", args, stdin, stdout)
+}
+
+// Bunzip2 decompresses bzip2 data; it is bzip2 -d
+func Bunzip2(args []string, stdin io.Reader, stdout io.Writer) error {
+	return runBzip2("bunzip2", append([]string{"-d"}, args...), stdin, stdout)
+}
+
+// Bzcat decompresses bzip2 data to stdout; it is bzip2 -dc
+func Bzcat(args []string, stdin io.Reader, stdout io.Writer) error {
+	return runBzip2("bzcat", append([]string{"-d"}, args...), stdin, stdout)
+}
+
+func runBzip2(name string, args []string, stdin io.Reader, stdout io.Writer) error {
+	opts, err := parseCompressArgs(name, args)
+	if err != nil {
+		return err
+	}
+	if !opts.decompress {
+		return fmt.Errorf("%s: compression is not supported; use -d to decompress, or gzip", name)
+	}
+	input := bufio.NewReader(stdin)
+	if magic, err := input.Peek(3); err != nil || string(magic) != "BZh" {
+		return fmt.Errorf("%s: not in bzip2 format", name)
+	}
+	return decompressTo(name, bzip2.NewReader(input), opts, stdout)
+}
+
+// Xz decompresses xz data with -d, using the decoder in xz.go; compressing
+// is not supported
+func Xz(args []string, stdin io.Reader, stdout io.Writer) error {
+	return runXz("xz", args, stdin, stdout)
+}
+
+// Unxz decompresses xz data; it is xz -d
+func Unxz(args []string, stdin io.Reader, stdout io.Writer) error {
+	return runXz("unxz", append([]string{"-d"}, args...), stdin, stdout)
+}
+
+// Xzcat decompresses xz data to stdout; it is xz -dc
+func Xzcat(args []string, stdin io.Reader, stdout io.Writer) error {
+	return runXz("xzcat", append([]string{"-d"}, args...), stdin, stdout)
+}
+
+func runXz(name string, args []string, stdin io.Reader, stdout io.Writer) error {
+	opts, err := parseCompressArgs(name, args)
+	if err != nil {
+		return err
+	}
+	if !opts.decompress {
+		return fmt.Errorf("%s: compression is not supported; use -d to decompress, or gzip", name)
+	}
+	if opts.test {
+		stdout = io.Discard
+	}
+	if err := decodeXZ(stdin, stdout); err != nil {
+		return compressError(name, err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package builtin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestCompressCommands(t *testing.T) {
+	mustHex := func(s string) string {
+		data, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	// Made with xz and bzip2; the second xz stream has a SHA-256 check and
+	// an uncompressed LZMA2 chunk
+	xzData := mustHex("fd377a585a000004e6d6b4460200210116000000742fe5a3e0005300115d00341949ee8de9509609250ddcee8b5a880000000000cbbba14c2c956ec700012d54297aaa6c1fb6f37d010000000004595a")
+	xzSHA256 := mustHex("fd377a585a00000ae1fb0ca10200210116000000742fe5a30100067365636f6e640a0000480c2336b410f1ad5f8bf1b28944490255804b65350c527787e74ebdd511e3a400013707bc80e52e189b4b9a01000000000a595a")
+	bzip2Data := mustHex("425a6839314159265359ab6ba1f1000002d9800010400010001264c01020003100d34d04001ea3ef4e51a2078bb9229c284855b5d0f880")
+	helloXZ := strings.Repeat("hello hello hello xz\n", 4)
+
+	var gzipped bytes.Buffer
+	if err := Gzip([]string{"-9"}, strings.NewReader("hello gzip\n"), &gzipped); err != nil {
+		t.Fatalf("gzip failed: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		command        CommandFunc
+		args           []string
+		input          string
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name:           "gunzip round trip",
+			command:        Gunzip,
+			input:          gzipped.String(),
+			expectedOutput: "hello gzip\n",
+		},
+		{
+			name:           "zcat concatenated members",
+			command:        Zcat,
+			input:          gzipped.String() + gzipped.String(),
+			expectedOutput: "hello gzip\nhello gzip\n",
+		},
+		{
+			name:           "gzip -t only checks",
+			command:        Gzip,
+			args:           []string{"-t"},
+			input:          gzipped.String(),
+			expectedOutput: "",
+		},
+		{
+			name:          "gunzip plain text",
+			command:       Gunzip,
+			input:         "hello, this is plain text\n",
+			expectedError: "gunzip: not in gzip format",
+		},
+		{
+			name:          "gunzip truncated",
+			command:       Gunzip,
+			input:         gzipped.String()[:gzipped.Len()-6],
+			expectedError: "gunzip: unexpected end of file",
+		},
+		{
+			name:           "bzcat",
+			command:        Bzcat,
+			input:          bzip2Data,
+			expectedOutput: "hello bzip2\n",
+		},
+		{
+			name:          "bzip2 cannot compress",
+			command:       Bzip2,
+			input:         "x",
+			expectedError: "bzip2: compression is not supported",
+		},
+		{
+			name:           "xzcat",
+			command:        Xzcat,
+			input:          xzData,
+			expectedOutput: helloXZ,
+		},
+		{
+			name:           "unxz concatenated streams with padding",
+			command:        Unxz,
+			input:          xzData + "\x00\x00\x00\x00" + xzSHA256,
+			expectedOutput: helloXZ + "second\n",
+		},
+		{
+			name:          "xz integrity check",
+			command:       Xz,
+			args:          []string{"-dc"},
+			input:         strings.Replace(xzSHA256, "second", "Second", 1),
+			expectedError: "xz: the integrity check failed",
+		},
+		{
+			name:          "xz truncated",
+			command:       Xzcat,
+			input:         xzData[:40],
+			expectedError: "xzcat: unexpected end of file",
+		},
+		{
+			name:          "xz not xz",
+			command:       Unxz,
+			input:         "hello, this is text\n",
+			expectedError: "unxz: not in xz format",
+		},
+		{
+			name:          "file operand",
+			command:       Zcat,
+			args:          []string{"access.log.gz"},
+			expectedError: "file operands are not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output strings.Builder
+			err := tt.command(tt.args, strings.NewReader(tt.input), &output)
+
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != tt.expectedOutput {
+				t.Errorf("expected output %q, got %q", tt.expectedOutput, output.String())
+			}
+		})
+	}
+}
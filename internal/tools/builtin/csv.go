@@ -0,0 +1,428 @@
+package builtin
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// CSVJoinSeparator separates the left and right tables in csvjoin's input
+const CSVJoinSeparator = "---LLMCMD_CSVJOIN_SEPARATOR---"
+
+// csvOptions are the options the csv commands share
+type csvOptions struct {
+	delimiter rune
+	noHeader  bool // The first row is data; columns are only referred to by number
+}
+
+// parseCSVOption handles a shared option at args[i], returning how many
+// arguments it used, or 0 if it is not a shared option
+func parseCSVOption(name string, args []string, i int, opts *csvOptions) (int, error) {
+	switch args[i] {
+	case "-d":
+		if i+1 == len(args) {
+			return 0, fmt.Errorf("%s: -d: missing delimiter", name)
+		}
+		r, size := utf8.DecodeRuneInString(args[i+1])
+		if size == 0 || size != len(args[i+1]) {
+			return 0, fmt.Errorf("%s: -d: delimiter must be a single character", name)
+		}
+		opts.delimiter = r
+		return 2, nil
+	case "-t":
+		opts.delimiter = '\t'
+		return 1, nil
+	case "-H":
+		opts.noHeader = true
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// readCSV reads a table, returning its header, if it has one, and its rows
+func readCSV(name string, r io.Reader, opts csvOptions) ([]string, [][]string, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = opts.delimiter
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if opts.noHeader || len(rows) == 0 {
+		return nil, rows, nil
+	}
+	return rows[0], rows[1:], nil
+}
+
+// csvColumnCount returns the number of columns in a table
+func csvColumnCount(header []string, rows [][]string) int {
+	count := len(header)
+	for _, row := range rows {
+		count = max(count, len(row))
+	}
+	return count
+}
+
+// resolveCSVColumns converts a column list to indices. Each item is a
+// 1-based number, a range such as 2-4, or a header name.
+func resolveCSVColumns(name, spec string, header []string, count int) ([]int, error) {
+	var columns []int
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if index := csvHeaderIndex(header, item); index >= 0 {
+			columns = append(columns, index)
+			continue
+		}
+		from, to, isRange := strings.Cut(item, "-")
+		start, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, fmt.Errorf("%s: column %q not found", name, item)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(to); err != nil {
+				return nil, fmt.Errorf("%s: invalid column range %q", name, item)
+			}
+		}
+		if start < 1 || end < start || end > count {
+			return nil, fmt.Errorf("%s: column %q out of range (1-%d)", name, item, count)
+		}
+		for i := start; i <= end; i++ {
+			columns = append(columns, i-1)
+		}
+	}
+	return columns, nil
+}
+
+func csvHeaderIndex(header []string, name string) int {
+	for i, column := range header {
+		if column == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// csvField returns a row's field, or "" for a short row
+func csvField(row []string, i int) string {
+	if i < len(row) {
+		return row[i]
+	}
+	return ""
+}
+
+// writeCSV writes rows, quoting fields that need it
+func writeCSV(stdout io.Writer, opts csvOptions, rows [][]string) error {
+	writer := csv.NewWriter(stdout)
+	writer.Comma = opts.delimiter
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// CsvCut selects columns of a CSV table by name or number
+func CsvCut(args []string, stdin io.Reader, stdout io.Writer) error {
+	opts := csvOptions{delimiter: ','}
+	var include, exclude string
+	listNames := false
+	for i := 0; i < len(args); i++ {
+		n, err := parseCSVOption("csvcut", args, i, &opts)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			i += n - 1
+			continue
+		}
+		switch args[i] {
+		case "-c", "-C":
+			if i+1 == len(args) {
+				return fmt.Errorf("csvcut: %s: missing column list", args[i])
+			}
+			if args[i] == "-c" {
+				include = args[i+1]
+			} else {
+				exclude = args[i+1]
+			}
+			i++
+		case "-n":
+			listNames = true
+		default:
+			return fmt.Errorf("csvcut: %s: invalid argument", args[i])
+		}
+	}
+
+	header, rows, err := readCSV("csvcut", stdin, opts)
+	if err != nil {
+		return err
+	}
+	count := csvColumnCount(header, rows)
+	if listNames {
+		if header == nil {
+			return fmt.Errorf("csvcut: -n: the table has no header")
+		}
+		for i, column := range header {
+			fmt.Fprintf(stdout, "%3d: %s\n", i+1, column)
+		}
+		return nil
+	}
+
+	var columns []int
+	if include != "" {
+		if columns, err = resolveCSVColumns("csvcut", include, header, count); err != nil {
+			return err
+		}
+	} else {
+		for i := 0; i < count; i++ {
+			columns = append(columns, i)
+		}
+	}
+	if exclude != "" {
+		excluded, err := resolveCSVColumns("csvcut", exclude, header, count)
+		if err != nil {
+			return err
+		}
+		drop := make(map[int]bool)
+		for _, i := range excluded {
+			drop[i] = true
+		}
+		kept := columns[:0]
+		for _, i := range columns {
+			if !drop[i] {
+				kept = append(kept, i)
+			}
+		}
+		columns = kept
+	}
+
+	var out [][]string
+	if header != nil {
+		rows = append([][]string{header}, rows...)
+	}
+	for _, row := range rows {
+		selected := make([]string, len(columns))
+		for j, i := range columns {
+			selected[j] = csvField(row, i)
+		}
+		out = append(out, selected)
+	}
+	return writeCSV(stdout, opts, out)
+}
+
+// CsvGrep keeps the rows of a CSV table whose columns match a string or a
+// regex, keeping the header
+func CsvGrep(args []string, stdin io.Reader, stdout io.Writer) error {
+	opts := csvOptions{delimiter: ','}
+	var columnSpec, literal, pattern string
+	haveLiteral, havePattern := false, false
+	invert, anyMatch := false, false
+	for i := 0; i < len(args); i++ {
+		n, err := parseCSVOption("csvgrep", args, i, &opts)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			i += n - 1
+			continue
+		}
+		switch arg := args[i]; arg {
+		case "-c", "-m", "-r":
+			if i+1 == len(args) {
+				return fmt.Errorf("csvgrep: %s: missing argument", arg)
+			}
+			i++
+			switch arg {
+			case "-c":
+				columnSpec = args[i]
+			case "-m":
+				literal, haveLiteral = args[i], true
+			case "-r":
+				pattern, havePattern = args[i], true
+			}
+		case "-i":
+			invert = true
+		case "-a":
+			anyMatch = true
+		default:
+			return fmt.Errorf("csvgrep: %s: invalid argument", arg)
+		}
+	}
+	if haveLiteral == havePattern {
+		return fmt.Errorf("csvgrep: give exactly one of -m string or -r regex")
+	}
+	match := func(field string) bool { return strings.Contains(field, literal) }
+	if havePattern {
+		re, err := compileRegex(pattern, false)
+		if err != nil {
+			return fmt.Errorf("csvgrep: %w", err)
+		}
+		match = re.MatchString
+	}
+
+	header, rows, err := readCSV("csvgrep", stdin, opts)
+	if err != nil {
+		return err
+	}
+	count := csvColumnCount(header, rows)
+	var columns []int
+	if columnSpec != "" {
+		if columns, err = resolveCSVColumns("csvgrep", columnSpec, header, count); err != nil {
+			return err
+		}
+	} else {
+		// Without -c, a match in any column selects the row
+		anyMatch = true
+		for i := 0; i < count; i++ {
+			columns = append(columns, i)
+		}
+	}
+
+	var out [][]string
+	if header != nil {
+		out = append(out, header)
+	}
+	for _, row := range rows {
+		matched := !anyMatch
+		for _, i := range columns {
+			if m := match(csvField(row, i)); anyMatch && m {
+				matched = true
+				break
+			} else if !anyMatch && !m {
+				matched = false
+				break
+			}
+		}
+		if matched != invert {
+			out = append(out, row)
+		}
+	}
+	return writeCSV(stdout, opts, out)
+}
+
+// CsvJoin joins two CSV tables on a column. The input is the left table, a
+// ---LLMCMD_CSVJOIN_SEPARATOR--- line and the right table. The join is
+// inner unless --left, --right or --outer is given, and the right table's
+// join column is dropped from the output.
+func CsvJoin(args []string, stdin io.Reader, stdout io.Writer) error {
+	opts := csvOptions{delimiter: ','}
+	var columnSpec string
+	keepLeft, keepRight := false, false
+	for i := 0; i < len(args); i++ {
+		n, err := parseCSVOption("csvjoin", args, i, &opts)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			i += n - 1
+			continue
+		}
+		switch args[i] {
+		case "-c":
+			if i+1 == len(args) {
+				return fmt.Errorf("csvjoin: -c: missing column")
+			}
+			i++
+			columnSpec = args[i]
+		case "--left":
+			keepLeft = true
+		case "--right":
+			keepRight = true
+		case "--outer":
+			keepLeft, keepRight = true, true
+		default:
+			return fmt.Errorf("csvjoin: %s: invalid argument", args[i])
+		}
+	}
+	if columnSpec == "" {
+		return fmt.Errorf("csvjoin: -c: the join column is required")
+	}
+
+	content, err := io.ReadAll(stdin)
+	if err != nil {
+		return fmt.Errorf("csvjoin: failed to read input: %w", err)
+	}
+	parts := regexp.MustCompile(`(?m)^`+regexp.QuoteMeta(CSVJoinSeparator)+`\r?\n?`).Split(string(content), -1)
+	if len(parts) != 2 {
+		return fmt.Errorf("csvjoin: input must contain exactly one %s line", CSVJoinSeparator)
+	}
+	leftHeader, leftRows, err := readCSV("csvjoin", strings.NewReader(parts[0]), opts)
+	if err != nil {
+		return err
+	}
+	rightHeader, rightRows, err := readCSV("csvjoin", strings.NewReader(parts[1]), opts)
+	if err != nil {
+		return err
+	}
+
+	// -c names one column for both tables, or left,right
+	leftSpec, rightSpec, different := strings.Cut(columnSpec, ",")
+	if !different {
+		rightSpec = leftSpec
+	}
+	leftCount, rightCount := csvColumnCount(leftHeader, leftRows), csvColumnCount(rightHeader, rightRows)
+	leftKey, err := resolveCSVColumns("csvjoin", leftSpec, leftHeader, leftCount)
+	if err != nil {
+		return err
+	}
+	rightKey, err := resolveCSVColumns("csvjoin", rightSpec, rightHeader, rightCount)
+	if err != nil {
+		return err
+	}
+	if len(leftKey) != 1 || len(rightKey) != 1 {
+		return fmt.Errorf("csvjoin: -c: give a single join column")
+	}
+	lk, rk := leftKey[0], rightKey[0]
+
+	// combine joins a left and right row; a nil side is filled with empty
+	// fields, except the join column, taken from the other side
+	combine := func(left, right []string) []string {
+		row := make([]string, 0, leftCount+rightCount-1)
+		for i := 0; i < leftCount; i++ {
+			if left == nil && i == lk {
+				row = append(row, csvField(right, rk))
+			} else {
+				row = append(row, csvField(left, i))
+			}
+		}
+		for i := 0; i < rightCount; i++ {
+			if i != rk {
+				row = append(row, csvField(right, i))
+			}
+		}
+		return row
+	}
+
+	byKey := make(map[string][]int)
+	for i, row := range rightRows {
+		key := csvField(row, rk)
+		byKey[key] = append(byKey[key], i)
+	}
+	var out [][]string
+	if leftHeader != nil && rightHeader != nil {
+		out = append(out, combine(leftHeader, rightHeader))
+	}
+	matchedRight := make([]bool, len(rightRows))
+	for _, left := range leftRows {
+		matches := byKey[csvField(left, lk)]
+		for _, i := range matches {
+			out = append(out, combine(left, rightRows[i]))
+			matchedRight[i] = true
+		}
+		if len(matches) == 0 && keepLeft {
+			out = append(out, combine(left, []string{}))
+		}
+	}
+	if keepRight {
+		for i, right := range rightRows {
+			if !matchedRight[i] {
+				out = append(out, combine(nil, right))
+			}
+		}
+	}
+	return writeCSV(stdout, opts, out)
+}
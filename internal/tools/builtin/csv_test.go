@@ -0,0 +1,145 @@
+package builtin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCSVCommands(t *testing.T) {
+	const people = `id,name,city
+1,"Smith, John",Tokyo
+2,"O""Neil",Osaka
+3,Ann,"Kyoto
+Station"
+`
+
+	tests := []struct {
+		name           string
+		command        CommandFunc
+		args           []string
+		input          string
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name:           "csvcut by name keeps quoted commas",
+			command:        CsvCut,
+			args:           []string{"-c", "name,id"},
+			input:          people,
+			expectedOutput: "name,id\n\"Smith, John\",1\n\"O\"\"Neil\",2\nAnn,3\n",
+		},
+		{
+			name:           "csvcut by range and exclusion",
+			command:        CsvCut,
+			args:           []string{"-c", "1-3", "-C", "city"},
+			input:          people,
+			expectedOutput: "id,name\n1,\"Smith, John\"\n2,\"O\"\"Neil\"\n3,Ann\n",
+		},
+		{
+			name:           "csvcut keeps embedded newlines",
+			command:        CsvCut,
+			args:           []string{"-c", "3"},
+			input:          people,
+			expectedOutput: "city\nTokyo\nOsaka\n\"Kyoto\nStation\"\n",
+		},
+		{
+			name:           "csvcut lists column names",
+			command:        CsvCut,
+			args:           []string{"-n"},
+			input:          people,
+			expectedOutput: "  1: id\n  2: name\n  3: city\n",
+		},
+		{
+			name:           "csvcut with tab delimiter and no header",
+			command:        CsvCut,
+			args:           []string{"-t", "-H", "-c", "2"},
+			input:          "a\tb\tc\nd\te\tf\n",
+			expectedOutput: "b\ne\n",
+		},
+		{
+			name:          "csvcut unknown column",
+			command:       CsvCut,
+			args:          []string{"-c", "age"},
+			input:         people,
+			expectedError: `csvcut: column "age" not found`,
+		},
+		{
+			name:           "csvgrep literal in a column",
+			command:        CsvGrep,
+			args:           []string{"-c", "name", "-m", ", "},
+			input:          people,
+			expectedOutput: "id,name,city\n1,\"Smith, John\",Tokyo\n",
+		},
+		{
+			name:           "csvgrep regex, inverted",
+			command:        CsvGrep,
+			args:           []string{"-c", "city", "-r", "^(Tokyo|Osaka)$", "-i"},
+			input:          people,
+			expectedOutput: "id,name,city\n3,Ann,\"Kyoto\nStation\"\n",
+		},
+		{
+			name:           "csvgrep any column",
+			command:        CsvGrep,
+			args:           []string{"-m", "Neil"},
+			input:          people,
+			expectedOutput: "id,name,city\n2,\"O\"\"Neil\",Osaka\n",
+		},
+		{
+			name:          "csvgrep needs a match",
+			command:       CsvGrep,
+			args:          []string{"-c", "name"},
+			input:         people,
+			expectedError: "give exactly one of -m string or -r regex",
+		},
+		{
+			name:    "csvjoin inner",
+			command: CsvJoin,
+			args:    []string{"-c", "id"},
+			input: "id,name\n1,\"Smith, John\"\n2,Ann\n" + CSVJoinSeparator + "\n" +
+				"id,score\n2,90\n1,80\n1,85\n",
+			expectedOutput: "id,name,score\n1,\"Smith, John\",80\n1,\"Smith, John\",85\n2,Ann,90\n",
+		},
+		{
+			name:    "csvjoin outer on differently named columns",
+			command: CsvJoin,
+			args:    []string{"-c", "id,user", "--outer"},
+			input: "id,name\n1,Bob\n2,Ann\n" + CSVJoinSeparator + "\n" +
+				"user,score\n2,90\n3,70\n",
+			expectedOutput: "id,name,score\n1,Bob,\n2,Ann,90\n3,,70\n",
+		},
+		{
+			name:          "csvjoin needs the separator",
+			command:       CsvJoin,
+			args:          []string{"-c", "id"},
+			input:         "id\n1\n",
+			expectedError: "csvjoin: input must contain exactly one " + CSVJoinSeparator + " line",
+		},
+		{
+			name:          "malformed csv",
+			command:       CsvCut,
+			args:          []string{"-c", "1"},
+			input:         "a,\"b\n",
+			expectedError: "csvcut: parse error on line 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output strings.Builder
+			err := tt.command(tt.args, strings.NewReader(tt.input), &output)
+
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != tt.expectedOutput {
+				t.Errorf("expected output %q, got %q", tt.expectedOutput, output.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,476 @@
+package builtin
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateNow returns the current time; tests replace it
+var dateNow = time.Now
+
+// Date prints the current time, or with -d the time a date string
+// describes, like GNU date. +FORMAT formats it with strftime conversions
+// such as %Y-%m-%d %H:%M:%S, %s or %:z; -I, -R and --rfc-3339 select
+// standard formats, and -u uses UTC instead of the local time zone.
+// Date strings are ISO 8601 dates and times, @SECONDS, common formats such
+// as Jan 2 2006, and relative items such as yesterday, 3 days ago, next
+// monday or 2024-01-31 +1 month.
+func Date(args []string, stdin io.Reader, stdout io.Writer) error {
+	location := time.Local
+	var dateString, format string
+	hasDate := false
+	setFormat := func(f string) error {
+		if format != "" {
+			return fmt.Errorf("date: multiple output formats specified")
+		}
+		format = f
+		return nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		var err error
+		switch {
+		case strings.HasPrefix(arg, "+"):
+			err = setFormat(arg[1:])
+		case arg == "-u" || arg == "--utc" || arg == "--universal":
+			location = time.UTC
+		case arg == "-d" || arg == "--date":
+			if i+1 == len(args) {
+				return fmt.Errorf("date: %s: missing argument", arg)
+			}
+			i++
+			dateString, hasDate = args[i], true
+		case strings.HasPrefix(arg, "--date="):
+			dateString, hasDate = strings.TrimPrefix(arg, "--date="), true
+		case strings.HasPrefix(arg, "-d"):
+			dateString, hasDate = arg[2:], true
+		case arg == "-R" || arg == "--rfc-email":
+			err = setFormat("%a, %d %b %Y %H:%M:%S %z")
+		case strings.HasPrefix(arg, "-I") || strings.HasPrefix(arg, "--iso-8601"):
+			precision := strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(arg, "-I"), "--iso-8601"), "=")
+			formats := map[string]string{
+				"":        "%Y-%m-%d",
+				"date":    "%Y-%m-%d",
+				"hours":   "%Y-%m-%dT%H%:z",
+				"minutes": "%Y-%m-%dT%H:%M%:z",
+				"seconds": "%Y-%m-%dT%H:%M:%S%:z",
+				"ns":      "%Y-%m-%dT%H:%M:%S,%N%:z",
+			}
+			f, ok := formats[precision]
+			if !ok {
+				return fmt.Errorf("date: invalid argument '%s' for --iso-8601", precision)
+			}
+			err = setFormat(f)
+		case strings.HasPrefix(arg, "--rfc-3339="):
+			formats := map[string]string{
+				"date":    "%Y-%m-%d",
+				"seconds": "%Y-%m-%d %H:%M:%S%:z",
+				"ns":      "%Y-%m-%d %H:%M:%S.%N%:z",
+			}
+			precision := strings.TrimPrefix(arg, "--rfc-3339=")
+			f, ok := formats[precision]
+			if !ok {
+				return fmt.Errorf("date: invalid argument '%s' for --rfc-3339", precision)
+			}
+			err = setFormat(f)
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			return fmt.Errorf("date: %s: invalid option", arg)
+		default:
+			return fmt.Errorf("date: %s: setting the date is not supported; formats start with +", arg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if format == "" {
+		format = "%a %b %e %H:%M:%S %Z %Y"
+	}
+
+	t := dateNow().In(location)
+	if hasDate {
+		var err error
+		if t, err = parseDate(dateString, t, location); err != nil {
+			return err
+		}
+		t = t.In(location)
+	}
+	_, err := io.WriteString(stdout, strftime(t, format)+"\n")
+	return err
+}
+
+// dateLayouts are the absolute date formats parseDate understands
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"01/02/2006",
+	"20060102",
+	time.UnixDate,
+	time.ANSIC,
+	time.RFC1123,
+	time.RFC1123Z,
+	time.RFC850,
+	time.RFC822,
+	time.RFC822Z,
+	"Jan 2 2006 15:04:05",
+	"Jan 2 2006",
+	"Jan 2, 2006",
+	"January 2 2006",
+	"January 2, 2006",
+	"2 Jan 2006",
+	"2 January 2006",
+}
+
+// dateUnits are the units of relative items, by their names
+var dateUnits = map[string]string{
+	"sec": "second", "secs": "second", "second": "second", "seconds": "second",
+	"min": "minute", "mins": "minute", "minute": "minute", "minutes": "minute",
+	"hour": "hour", "hours": "hour",
+	"day": "day", "days": "day",
+	"week": "week", "weeks": "week",
+	"fortnight": "fortnight", "fortnights": "fortnight",
+	"month": "month", "months": "month",
+	"year": "year", "years": "year",
+}
+
+// parseDate parses a date string as date -d does: an absolute date, at
+// the start of the string or implied to be now, followed by relative items
+// and times of day
+func parseDate(s string, now time.Time, location *time.Location) (time.Time, error) {
+	invalid := fmt.Errorf("date: invalid date '%s'", s)
+	text := strings.TrimSpace(s)
+	if seconds, ok := strings.CutPrefix(text, "@"); ok {
+		f, err := strconv.ParseFloat(seconds, 64)
+		if err != nil {
+			return time.Time{}, invalid
+		}
+		whole := int64(f)
+		return time.Unix(whole, int64((f-float64(whole))*1e9)), nil
+	}
+
+	// The longest prefix of words that is an absolute date
+	words := strings.Fields(text)
+	t, rest := now, words
+	for n := len(words); n > 0; n-- {
+		if parsed, ok := parseDateLayouts(strings.Join(words[:n], " "), location); ok {
+			t, rest = parsed, words[n:]
+			break
+		}
+	}
+
+	multiplier := 0
+	hasMultiplier := false
+	for i := 0; i < len(rest); i++ {
+		word := strings.ToLower(rest[i])
+		if clock, ok := parseClock(word); ok {
+			t = time.Date(t.Year(), t.Month(), t.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, t.Location())
+			continue
+		}
+		if n, err := strconv.Atoi(word); err == nil {
+			multiplier, hasMultiplier = n, true
+			continue
+		}
+		explicit, count := hasMultiplier, 1
+		if explicit {
+			count = multiplier
+		}
+		hasMultiplier = false
+		switch word {
+		case "now", "today":
+			continue
+		case "yesterday":
+			t = t.AddDate(0, 0, -1)
+			continue
+		case "tomorrow":
+			t = t.AddDate(0, 0, 1)
+			continue
+		case "midnight":
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+			continue
+		case "noon":
+			t = time.Date(t.Year(), t.Month(), t.Day(), 12, 0, 0, 0, t.Location())
+			continue
+		case "next", "last", "this":
+			multiplier = map[string]int{"next": 1, "last": -1, "this": 0}[word]
+			hasMultiplier = true
+			continue
+		}
+		if unit, ok := dateUnits[word]; ok {
+			if i+1 < len(rest) && strings.EqualFold(rest[i+1], "ago") {
+				count = -count
+				i++
+			}
+			t = addDateUnit(t, unit, count)
+			continue
+		}
+		if weekday, ok := parseWeekday(word); ok {
+			// The weekday on or after the date; next moves a week on from
+			// the date itself, last and negative counts go back
+			days := (int(weekday) - int(t.Weekday()) + 7) % 7
+			switch {
+			case explicit && count > 0 && days == 0:
+				days = 7 * count
+			case count > 1:
+				days += 7 * (count - 1)
+			case count < 0:
+				days += 7 * count
+			}
+			t = time.Date(t.Year(), t.Month(), t.Day()+days, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		return time.Time{}, invalid
+	}
+	if hasMultiplier {
+		return time.Time{}, invalid
+	}
+	return t, nil
+}
+
+// parseDateLayouts parses an absolute date in one of the dateLayouts, a
+// date without a zone being in the given location
+func parseDateLayouts(s string, location *time.Location) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, location); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseClock parses a time of day, such as 15:04 or 3:04:05
+func parseClock(s string) (time.Time, bool) {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func parseWeekday(s string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// addDateUnit adds count units to t; months and years keep the day of the
+// month, normalizing it as GNU date does, so Jan 31 +1 month is Mar 2 or 3
+func addDateUnit(t time.Time, unit string, count int) time.Time {
+	switch unit {
+	case "second":
+		return t.Add(time.Duration(count) * time.Second)
+	case "minute":
+		return t.Add(time.Duration(count) * time.Minute)
+	case "hour":
+		return t.Add(time.Duration(count) * time.Hour)
+	case "day":
+		return t.AddDate(0, 0, count)
+	case "week":
+		return t.AddDate(0, 0, 7*count)
+	case "fortnight":
+		return t.AddDate(0, 0, 14*count)
+	case "month":
+		return t.AddDate(0, count, 0)
+	}
+	return t.AddDate(count, 0, 0)
+}
+
+// strftime formats t with the conversions of GNU date. A conversion may
+// have flags (- no padding, _ pad with spaces, 0 pad with zeros, ^ upper
+// case, # opposite case) and a width.
+func strftime(t time.Time, format string) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		start := i
+		i++
+		var flag byte
+		for i < len(format) && strings.IndexByte("-_0^#", format[i]) >= 0 {
+			flag = format[i]
+			i++
+		}
+		width := -1
+		for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+			if width < 0 {
+				width = 0
+			}
+			width = width*10 + int(format[i]-'0')
+			i++
+		}
+		colons := 0
+		for i < len(format) && format[i] == ':' {
+			colons++
+			i++
+		}
+		if i == len(format) {
+			b.WriteString(format[start:])
+			break
+		}
+
+		value, pad, defaultWidth, known := dateConversion(t, format[i], colons, width)
+		if !known {
+			b.WriteString(format[start : i+1])
+			continue
+		}
+		switch flag {
+		case '-':
+			pad = 0
+		case '_':
+			pad = ' '
+		case '0':
+			pad = '0'
+		case '^':
+			value = strings.ToUpper(value)
+		case '#':
+			if format[i] == 'Z' {
+				value = strings.ToLower(value)
+			} else {
+				value = strings.ToUpper(value)
+			}
+		}
+		if width < 0 {
+			width = defaultWidth
+		}
+		if format[i] == 'N' {
+			width = 0 // Its width is the number of digits
+		}
+		if pad != 0 && len(value) < width {
+			padding := strings.Repeat(string(pad), width-len(value))
+			if pad == '0' && (value[0] == '-' || value[0] == '+') {
+				value = value[:1] + padding + value[1:]
+			} else {
+				value = padding + value
+			}
+		}
+		b.WriteString(value)
+	}
+	return b.String()
+}
+
+// dateConversion returns the text of a strftime conversion, the padding
+// and width numbers get by default, and whether the conversion is known.
+// Composite conversions such as %F are expanded.
+func dateConversion(t time.Time, conversion byte, colons, width int) (value string, pad byte, defaultWidth int, known bool) {
+	number := func(n, digits int) (string, byte, int, bool) {
+		return strconv.Itoa(n), '0', digits, true
+	}
+	spaced := func(n, digits int) (string, byte, int, bool) {
+		return strconv.Itoa(n), ' ', digits, true
+	}
+	text := func(s string) (string, byte, int, bool) {
+		return s, ' ', 0, true
+	}
+	hour12 := t.Hour() % 12
+	if hour12 == 0 {
+		hour12 = 12
+	}
+	isoYear, isoWeek := t.ISOWeek()
+	switch conversion {
+	case 'a':
+		return text(t.Format("Mon"))
+	case 'A':
+		return text(t.Format("Monday"))
+	case 'b', 'h':
+		return text(t.Format("Jan"))
+	case 'B':
+		return text(t.Format("January"))
+	case 'c':
+		return text(strftime(t, "%a %b %e %H:%M:%S %Y"))
+	case 'C':
+		return number(t.Year()/100, 2)
+	case 'd':
+		return number(t.Day(), 2)
+	case 'D', 'x':
+		return text(strftime(t, "%m/%d/%y"))
+	case 'e':
+		return spaced(t.Day(), 2)
+	case 'F':
+		return text(strftime(t, "%Y-%m-%d"))
+	case 'g':
+		return number(isoYear%100, 2)
+	case 'G':
+		return number(isoYear, 0)
+	case 'H':
+		return number(t.Hour(), 2)
+	case 'I':
+		return number(hour12, 2)
+	case 'j':
+		return number(t.YearDay(), 3)
+	case 'k':
+		return spaced(t.Hour(), 2)
+	case 'l':
+		return spaced(hour12, 2)
+	case 'm':
+		return number(int(t.Month()), 2)
+	case 'M':
+		return number(t.Minute(), 2)
+	case 'n':
+		return text("\n")
+	case 'N':
+		digits := 9
+		if width > 0 && width < 9 {
+			digits = width
+		}
+		return fmt.Sprintf("%09d", t.Nanosecond())[:digits], '0', 0, true
+	case 'p':
+		return text(t.Format("PM"))
+	case 'P':
+		return text(strings.ToLower(t.Format("PM")))
+	case 'r':
+		return text(strftime(t, "%I:%M:%S %p"))
+	case 'R':
+		return text(strftime(t, "%H:%M"))
+	case 's':
+		return number(int(t.Unix()), 0)
+	case 'S':
+		return number(t.Second(), 2)
+	case 't':
+		return text("\t")
+	case 'T', 'X':
+		return text(strftime(t, "%H:%M:%S"))
+	case 'u':
+		return number((int(t.Weekday())+6)%7+1, 0)
+	case 'U':
+		return number((t.YearDay()+6-int(t.Weekday()))/7, 2)
+	case 'V':
+		return number(isoWeek, 2)
+	case 'w':
+		return number(int(t.Weekday()), 0)
+	case 'W':
+		return number((t.YearDay()+6-(int(t.Weekday())+6)%7)/7, 2)
+	case 'y':
+		return number(t.Year()%100, 2)
+	case 'Y':
+		return number(t.Year(), 0)
+	case 'z':
+		layouts := []string{"-0700", "-07:00", "-07:00:00"}
+		if colons > 2 {
+			return "", 0, 0, false
+		}
+		return text(t.Format(layouts[colons]))
+	case 'Z':
+		return text(t.Format("MST"))
+	case '%':
+		return text("%")
+	}
+	return "", 0, 0, false
+}
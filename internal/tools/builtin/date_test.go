@@ -0,0 +1,127 @@
+package builtin
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDate(t *testing.T) {
+	// A Wednesday
+	now := time.Date(2024, time.March, 6, 14, 5, 9, 123456789, time.UTC)
+	defer func(saved func() time.Time) { dateNow = saved }(dateNow)
+	dateNow = func() time.Time { return now }
+
+	tests := []struct {
+		name           string
+		args           []string
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name:           "default format",
+			args:           []string{"-u"},
+			expectedOutput: "Wed Mar  6 14:05:09 UTC 2024\n",
+		},
+		{
+			name:           "format",
+			args:           []string{"-u", "+%Y-%m-%d %H:%M:%S %j %s"},
+			expectedOutput: "2024-03-06 14:05:09 066 1709733909\n",
+		},
+		{
+			name:           "conversions with flags and widths",
+			args:           []string{"-u", "+%-d|%_m|%e|%-e|%^a|%3N|%10A|%I%p %l%P|%u %w %U %V %W %G"},
+			expectedOutput: "6| 3| 6|6|WED|123| Wednesday|02PM  2pm|3 3 09 10 10 2024\n",
+		},
+		{
+			name:           "ISO 8601",
+			args:           []string{"-u", "-Iseconds"},
+			expectedOutput: "2024-03-06T14:05:09+00:00\n",
+		},
+		{
+			name:           "RFC 3339",
+			args:           []string{"-u", "--rfc-3339=ns"},
+			expectedOutput: "2024-03-06 14:05:09.123456789+00:00\n",
+		},
+		{
+			name:           "email format",
+			args:           []string{"-u", "-R", "-d", "2024-01-02T03:04:05+09:00"},
+			expectedOutput: "Mon, 01 Jan 2024 18:04:05 +0000\n",
+		},
+		{
+			name:           "seconds since the epoch",
+			args:           []string{"-u", "-d", "@86400", "+%F %T"},
+			expectedOutput: "1970-01-02 00:00:00\n",
+		},
+		{
+			name:           "date",
+			args:           []string{"-u", "-d", "2024-02-29", "+%A %B %-d"},
+			expectedOutput: "Thursday February 29\n",
+		},
+		{
+			name:           "date and time",
+			args:           []string{"-u", "--date=Jan 2 2006 15:04:05", "+%D %r"},
+			expectedOutput: "01/02/06 03:04:05 PM\n",
+		},
+		{
+			name:           "relative to now",
+			args:           []string{"-u", "-d", "yesterday", "+%F"},
+			expectedOutput: "2024-03-05\n",
+		},
+		{
+			name:           "ago",
+			args:           []string{"-u", "-d", "2 hours ago", "+%F %R"},
+			expectedOutput: "2024-03-06 12:05\n",
+		},
+		{
+			name:           "relative to a date",
+			args:           []string{"-u", "-d", "2024-01-31 +1 month -1 day", "+%F"},
+			expectedOutput: "2024-03-01\n",
+		},
+		{
+			name:           "weekdays",
+			args:           []string{"-u", "-d", "next wednesday 9:30", "+%a %F %R"},
+			expectedOutput: "Wed 2024-03-13 09:30\n",
+		},
+		{
+			name:           "last weekday",
+			args:           []string{"-u", "-d", "last fri", "+%F"},
+			expectedOutput: "2024-03-01\n",
+		},
+		{
+			name:          "invalid date",
+			args:          []string{"-d", "the day after"},
+			expectedError: "date: invalid date 'the day after'",
+		},
+		{
+			name:          "setting the date",
+			args:          []string{"0101000024"},
+			expectedError: "setting the date is not supported",
+		},
+		{
+			name:          "multiple formats",
+			args:          []string{"-R", "+%s"},
+			expectedError: "date: multiple output formats specified",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output bytes.Buffer
+			err := Date(test.args, strings.NewReader(""), &output)
+			if test.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != test.expectedOutput {
+				t.Errorf("expected output %q, got %q", test.expectedOutput, output.String())
+			}
+		})
+	}
+}
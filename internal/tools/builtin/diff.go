@@ -1,11 +1,20 @@
 package builtin
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
 )
 
+// diffSeparator separates the two texts of Diff when they come from stdin
+const diffSeparator = "---LLMCMD_DIFF_SEPARATOR---"
+
 // DiffChunk represents a chunk of differences in a unified diff
 type DiffChunk struct {
 	OldStart, OldLines int
@@ -13,116 +22,649 @@ type DiffChunk struct {
 	Lines              []string
 }
 
-// Diff compares two text inputs separated by ---LLMCMD_DIFF_SEPARATOR---
-// and outputs unified diff format
+// diffOptions are the options of diff
+type diffOptions struct {
+	context    int
+	brief      bool
+	recursive  bool
+	newFile    bool // A file missing from one directory compares as empty
+	ignoreCase bool
+	allSpace   bool // Ignore all white space
+	spaceRuns  bool // Ignore changes in the amount of white space
+	switches   []string
+}
+
+// DiffExitError carries diff's exit status: 1 when the files differ, with no
+// Err, or 2 with the error that stopped it
+type DiffExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *DiffExitError) Error() string {
+	if e.Err == nil {
+		return "diff: files differ"
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns the error that stopped diff, if any
+func (e *DiffExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns 1 when the files differ and 2 on errors, as in diff
+func (e *DiffExitError) ExitCode() int {
+	return e.Code
+}
+
+// Diff compares two texts and writes their differences as a unified diff.
+// Without operands stdin holds both texts, separated by a
+// ---LLMCMD_DIFF_SEPARATOR--- line; "-" as an operand also reads stdin.
 func Diff(args []string, stdin io.Reader, stdout io.Writer) error {
-	content, err := io.ReadAll(stdin)
-	if err != nil {
-		return fmt.Errorf("diff: failed to read input: %w", err)
+	return RunDiff(args, stdin, stdout, nil, nil)
+}
+
+// RunDiff is Diff comparing FILE1 and FILE2, opened with open. With a list
+// of the files, names that prefix other names with a slash are directories:
+// their files are compared by name, and with -r their subdirectories too.
+// -U N sets the lines of context (3), -q only reports which files differ,
+// -N compares a file missing from one directory as empty and -i, -w and -b
+// ignore case, all white space and changes in the amount of white space.
+// When the files differ, or on errors, it returns a *DiffExitError.
+func RunDiff(args []string, stdin io.Reader, stdout io.Writer, open OpenFunc, list ListFunc) error {
+	err := runDiff(args, stdin, stdout, open, list)
+	if err != nil && !errors.As(err, new(*DiffExitError)) {
+		return &DiffExitError{Code: 2, Err: err}
+	}
+	return err
+}
+
+// runDiff runs diff for RunDiff, returning its errors as they are
+func runDiff(args []string, stdin io.Reader, stdout io.Writer, open OpenFunc, list ListFunc) error {
+	opts := diffOptions{context: 3}
+	var operands []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			operands = append(operands, args[i+1:]...)
+			i = len(args)
+			continue
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			operands = append(operands, arg)
+			continue
+		}
+		opts.switches = append(opts.switches, arg)
+		switch {
+		case arg == "--unified":
+			arg = "-u"
+		case strings.HasPrefix(arg, "--unified="):
+			arg = "-U" + strings.TrimPrefix(arg, "--unified=")
+		case arg == "--brief":
+			arg = "-q"
+		case arg == "--recursive":
+			arg = "-r"
+		case arg == "--new-file":
+			arg = "-N"
+		case arg == "--ignore-case":
+			arg = "-i"
+		case arg == "--ignore-all-space":
+			arg = "-w"
+		case arg == "--ignore-space-change":
+			arg = "-b"
+		case strings.HasPrefix(arg, "--"):
+			return fmt.Errorf("diff: %s: invalid option", arg)
+		}
+
+		for k := 1; k < len(arg); k++ {
+			switch c := arg[k]; c {
+			case 'u', 'a':
+			case 'q':
+				opts.brief = true
+			case 'r':
+				opts.recursive = true
+			case 'N':
+				opts.newFile = true
+			case 'i':
+				opts.ignoreCase = true
+			case 'w':
+				opts.allSpace = true
+			case 'b':
+				opts.spaceRuns = true
+			case 'U':
+				value := arg[k+1:]
+				if value == "" {
+					if i+1 == len(args) {
+						return fmt.Errorf("diff: -U: missing argument")
+					}
+					i++
+					value = args[i]
+					opts.switches[len(opts.switches)-1] += value
+				}
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 0 {
+					return fmt.Errorf("diff: invalid context length '%s'", value)
+				}
+				opts.context = n
+				k = len(arg)
+			default:
+				return fmt.Errorf("diff: -%c: invalid option", c)
+			}
+		}
+	}
+
+	out := bufio.NewWriter(stdout)
+	d := &differ{opts: opts, stdin: stdin, open: open, list: list, out: out}
+	var err error
+	switch len(operands) {
+	case 0:
+		err = d.compareSeparated()
+	case 1:
+		err = fmt.Errorf("diff: missing operand after '%s'", operands[0])
+	case 2:
+		err = d.compare(operands[0], operands[1])
+	default:
+		err = fmt.Errorf("diff: extra operand '%s'", operands[2])
+	}
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
+	if err == nil && d.differs {
+		err = &DiffExitError{Code: 1}
 	}
+	return err
+}
+
+// differ compares files and directories for diff
+type differ struct {
+	opts  diffOptions
+	stdin io.Reader
+	input []byte // stdin, once read
+	read  bool
+	open  OpenFunc
+	list  ListFunc
+	names []string // The listed files, once listed
+	out   *bufio.Writer
+	// differs is set once a difference has been reported
+	differs bool
+}
 
-	// Split input by separator
-	parts := strings.Split(string(content), "---LLMCMD_DIFF_SEPARATOR---")
+// compareSeparated compares the two texts of stdin. The texts are trimmed,
+// as they always have been in this form.
+func (d *differ) compareSeparated() error {
+	content, err := d.contents("-")
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(string(content), diffSeparator)
 	if len(parts) != 2 {
-		return fmt.Errorf("diff: input must contain exactly one ---LLMCMD_DIFF_SEPARATOR---")
+		return fmt.Errorf("diff: input must contain exactly one %s, or give two files to compare", diffSeparator)
+	}
+	before := strings.TrimSpace(parts[0]) + "\n"
+	after := strings.TrimSpace(parts[1]) + "\n"
+	d.compareFiles("", "a/file", "b/file", []byte(before), []byte(after))
+	return nil
+}
+
+// compare compares two files, two directories, or a file and the file of
+// the same name in a directory
+func (d *differ) compare(name1, name2 string) error {
+	dir1, err := d.isDir(name1)
+	if err != nil {
+		return err
+	}
+	dir2, err := d.isDir(name2)
+	if err != nil {
+		return err
+	}
+	switch {
+	case dir1 && dir2:
+		return d.compareDirs(path.Clean(name1), path.Clean(name2))
+	case dir1:
+		name1 = path.Join(name1, path.Base(name2))
+	case dir2:
+		name2 = path.Join(name2, path.Base(name1))
+	}
+	data1, err := d.contents(name1)
+	if err != nil {
+		return err
+	}
+	data2, err := d.contents(name2)
+	if err != nil {
+		return err
+	}
+	d.compareFiles("", name1, name2, data1, data2)
+	return nil
+}
+
+// compareDirs compares the files of two directories by name
+func (d *differ) compareDirs(dir1, dir2 string) error {
+	entries1, err := d.children(dir1)
+	if err != nil {
+		return err
+	}
+	entries2, err := d.children(dir2)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for name := range entries1 {
+		names = append(names, name)
+	}
+	for name := range entries2 {
+		if _, ok := entries1[name]; !ok {
+			names = append(names, name)
+		}
 	}
+	sort.Strings(names)
 
-	file1Lines := strings.Split(strings.TrimSpace(parts[0]), "\n")
-	file2Lines := strings.Split(strings.TrimSpace(parts[1]), "\n")
+	for _, name := range names {
+		path1, path2 := path.Join(dir1, name), path.Join(dir2, name)
+		isDir1, in1 := entries1[name]
+		isDir2, in2 := entries2[name]
+		switch {
+		case in1 && in2 && isDir1 && isDir2:
+			if !d.opts.recursive {
+				fmt.Fprintf(d.out, "Common subdirectories: %s and %s\n", path1, path2)
+				continue
+			}
+			if err := d.compareDirs(path1, path2); err != nil {
+				return err
+			}
+			continue
+		case in1 && in2 && isDir1 != isDir2:
+			d.differs = true
+			if isDir1 {
+				fmt.Fprintf(d.out, "File %s is a directory while file %s is a regular file\n", path1, path2)
+			} else {
+				fmt.Fprintf(d.out, "File %s is a regular file while file %s is a directory\n", path1, path2)
+			}
+			continue
+		case !in2 && (isDir1 || !d.opts.newFile):
+			d.differs = true
+			fmt.Fprintf(d.out, "Only in %s: %s\n", dir1, name)
+			continue
+		case !in1 && (isDir2 || !d.opts.newFile):
+			d.differs = true
+			fmt.Fprintf(d.out, "Only in %s: %s\n", dir2, name)
+			continue
+		}
 
-	// Generate unified diff
-	chunks := computeDiff(file1Lines, file2Lines)
+		// Two files, or one and an empty file for -N
+		var data1, data2 []byte
+		if in1 {
+			if data1, err = d.contents(path1); err != nil {
+				return err
+			}
+		}
+		if in2 {
+			if data2, err = d.contents(path2); err != nil {
+				return err
+			}
+		}
+		header := strings.Join(append(append([]string{"diff"}, d.opts.switches...), path1, path2), " ")
+		d.compareFiles(header, path1, path2, data1, data2)
+	}
+	return nil
+}
 
-	// Output unified diff format
-	fmt.Fprintf(stdout, "--- a/file\n")
-	fmt.Fprintf(stdout, "+++ b/file\n")
+// compareFiles writes the differences between two files, after the header
+// line if they differ
+func (d *differ) compareFiles(header, label1, label2 string, data1, data2 []byte) {
+	if bytes.Equal(data1, data2) {
+		return
+	}
+	if bytes.IndexByte(data1, 0) >= 0 || bytes.IndexByte(data2, 0) >= 0 {
+		d.differs = true
+		fmt.Fprintf(d.out, "Binary files %s and %s differ\n", label1, label2)
+		return
+	}
 
-	for _, chunk := range chunks {
-		fmt.Fprintf(stdout, "@@ -%d,%d +%d,%d @@\n",
-			chunk.OldStart, chunk.OldLines,
-			chunk.NewStart, chunk.NewLines)
+	before, beforeNoEOL := diffLines(string(data1))
+	after, afterNoEOL := diffLines(string(data2))
+	removed, added := diffLCS(d.keys(before, beforeNoEOL), d.keys(after, afterNoEOL))
+	changed := false
+	for _, r := range removed {
+		changed = changed || r
+	}
+	for _, a := range added {
+		changed = changed || a
+	}
+	if !changed {
+		return
+	}
+	d.differs = true
+	if d.opts.brief {
+		fmt.Fprintf(d.out, "Files %s and %s differ\n", label1, label2)
+		return
+	}
 
+	if header != "" {
+		fmt.Fprintln(d.out, header)
+	}
+	fmt.Fprintf(d.out, "--- %s\n+++ %s\n", label1, label2)
+	for _, chunk := range unifiedChunks(before, after, removed, added, d.opts.context) {
+		fmt.Fprintf(d.out, "@@ -%s +%s @@\n", unifiedRange(chunk.OldStart, chunk.OldLines), unifiedRange(chunk.NewStart, chunk.NewLines))
+		oldLine, newLine := chunk.OldStart, chunk.NewStart
 		for _, line := range chunk.Lines {
-			fmt.Fprintln(stdout, line)
+			fmt.Fprintln(d.out, line)
+			// A context line is last in both files or in neither
+			if line[0] != '+' && oldLine == len(before) && beforeNoEOL || line[0] == '+' && newLine == len(after) && afterNoEOL {
+				fmt.Fprintln(d.out, `\ No newline at end of file`)
+			}
+			if line[0] != '+' {
+				oldLine++
+			}
+			if line[0] != '-' {
+				newLine++
+			}
 		}
 	}
+}
 
-	return nil
+// keys returns the lines as they are compared under the options. A last
+// line without a newline differs from the same line with one.
+func (d *differ) keys(lines []string, noEOL bool) []string {
+	keys := make([]string, len(lines))
+	for i, line := range lines {
+		switch {
+		case d.opts.allSpace:
+			line = strings.Join(strings.Fields(line), "")
+		case d.opts.spaceRuns:
+			line = strings.Join(strings.Fields(line), " ")
+			if line != "" && (lines[i][0] == ' ' || lines[i][0] == '\t') {
+				line = " " + line // Leading white space still counts
+			}
+		}
+		if d.opts.ignoreCase {
+			line = strings.ToLower(line)
+		}
+		if noEOL && i == len(lines)-1 {
+			line += "\n"
+		}
+		keys[i] = line
+	}
+	return keys
 }
 
-// computeDiff computes the differences between two slices of lines
-func computeDiff(oldLines, newLines []string) []DiffChunk {
-	var chunks []DiffChunk
+// contents reads a file, or stdin for "-"
+func (d *differ) contents(name string) ([]byte, error) {
+	if name == "-" {
+		if !d.read {
+			input, err := io.ReadAll(d.stdin)
+			if err != nil {
+				return nil, fmt.Errorf("diff: failed to read input: %w", err)
+			}
+			d.input, d.read = input, true
+		}
+		return d.input, nil
+	}
+	if d.open == nil {
+		return nil, fmt.Errorf("diff: %s: file operands are not supported; pipe the input instead", name)
+	}
+	f, err := d.open(name)
+	if err != nil {
+		return nil, fmt.Errorf("diff: %s: %w", name, err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("diff: %s: %w", name, err)
+	}
+	return data, nil
+}
 
-	// Simple line-by-line comparison for now
-	// This is a basic implementation - a real diff would use LCS algorithm
+// listed returns the names of the files, listing them once
+func (d *differ) listed() ([]string, error) {
+	if d.names == nil && d.list != nil {
+		names, err := d.list()
+		if err != nil {
+			return nil, fmt.Errorf("diff: %w", err)
+		}
+		d.names = append([]string{}, names...)
+	}
+	return d.names, nil
+}
 
-	oldIndex, newIndex := 0, 0
-	contextLines := 3 // Number of context lines to show
+// isDir reports whether name is a directory: "." or the prefix of other
+// names, and not a file itself
+func (d *differ) isDir(name string) (bool, error) {
+	names, err := d.listed()
+	if err != nil || name == "-" || d.list == nil {
+		return false, err
+	}
+	dir := strings.TrimSuffix(path.Clean(name), "/")
+	if dir == "." {
+		return true, nil
+	}
+	found := false
+	for _, listed := range names {
+		if listed == dir {
+			return false, nil
+		}
+		found = found || strings.HasPrefix(listed, dir+"/")
+	}
+	return found, nil
+}
 
-	for oldIndex < len(oldLines) || newIndex < len(newLines) {
-		chunk := DiffChunk{
-			OldStart: oldIndex + 1,
-			NewStart: newIndex + 1,
+// children returns the names in a directory, each mapped to whether it is a
+// directory itself
+func (d *differ) children(dir string) (map[string]bool, error) {
+	names, err := d.listed()
+	if err != nil {
+		return nil, err
+	}
+	prefix := path.Clean(dir) + "/"
+	if prefix == "./" {
+		prefix = ""
+	}
+	entries := make(map[string]bool)
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) || path.IsAbs(name) {
+			continue
 		}
+		rest := name[len(prefix):]
+		if slash := strings.IndexByte(rest, '/'); slash >= 0 {
+			entries[rest[:slash]] = true
+		} else if _, ok := entries[rest]; !ok {
+			entries[rest] = false
+		}
+	}
+	return entries, nil
+}
+
+// diffLines splits text into lines; noEOL is set if the last line has no
+// newline
+func diffLines(text string) (lines []string, noEOL bool) {
+	if text == "" {
+		return nil, false
+	}
+	noEOL = !strings.HasSuffix(text, "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n"), noEOL
+}
 
-		// Find differences
-		chunkLines := []string{}
-		chunkOldCount := 0
-		chunkNewCount := 0
+// unifiedRange formats the range of a hunk header: the start and the
+// number of lines, which is left out when it is one. An empty range starts
+// at the line before it.
+func unifiedRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
 
-		// Add context before changes
-		contextStart := max(0, oldIndex-contextLines)
-		for i := contextStart; i < oldIndex && i < len(oldLines); i++ {
-			chunkLines = append(chunkLines, " "+oldLines[i])
-			chunkOldCount++
-			chunkNewCount++
+// unifiedChunks groups the removed and added lines into hunks with context
+// lines around them; hunks whose context would overlap are merged
+func unifiedChunks(before, after []string, removed, added []bool, context int) []DiffChunk {
+	// The edit script: every line, in the order a unified diff shows them
+	type edit struct {
+		op       byte
+		old, new int
+	}
+	var script []edit
+	for i, j := 0, 0; i < len(before) || j < len(after); {
+		switch {
+		case i < len(before) && removed[i]:
+			script = append(script, edit{'-', i, j})
+			i++
+		case j < len(after) && added[j]:
+			script = append(script, edit{'+', i, j})
+			j++
+		default:
+			script = append(script, edit{' ', i, j})
+			i++
+			j++
 		}
+	}
 
-		// Process differences
-		for oldIndex < len(oldLines) && newIndex < len(newLines) {
-			if oldLines[oldIndex] == newLines[newIndex] {
-				// Lines match, add as context
-				chunkLines = append(chunkLines, " "+oldLines[oldIndex])
-				chunkOldCount++
-				chunkNewCount++
-				oldIndex++
-				newIndex++
+	var chunks []DiffChunk
+	for k := 0; k < len(script); {
+		if script[k].op == ' ' {
+			k++
+			continue
+		}
+		start := max(0, k-context)
+		end := k
+		for end < len(script) {
+			// The hunk goes on while the next change is near enough
+			next := end
+			for next < len(script) && script[next].op == ' ' {
+				next++
+			}
+			if next == len(script) || next-end > 2*context {
 				break
-			} else {
-				// Lines differ
-				chunkLines = append(chunkLines, "-"+oldLines[oldIndex])
-				chunkLines = append(chunkLines, "+"+newLines[newIndex])
-				chunkOldCount++
-				chunkNewCount++
-				oldIndex++
-				newIndex++
 			}
+			for next < len(script) && script[next].op != ' ' {
+				next++
+			}
+			end = next
 		}
-
-		// Handle remaining lines in old file
-		for oldIndex < len(oldLines) {
-			chunkLines = append(chunkLines, "-"+oldLines[oldIndex])
-			chunkOldCount++
-			oldIndex++
+		stop := end + context
+		if stop > len(script) {
+			stop = len(script)
 		}
 
-		// Handle remaining lines in new file
-		for newIndex < len(newLines) {
-			chunkLines = append(chunkLines, "+"+newLines[newIndex])
-			chunkNewCount++
-			newIndex++
+		chunk := DiffChunk{OldStart: script[start].old + 1, NewStart: script[start].new + 1}
+		for _, e := range script[start:stop] {
+			switch e.op {
+			case '-':
+				chunk.Lines = append(chunk.Lines, "-"+before[e.old])
+				chunk.OldLines++
+			case '+':
+				chunk.Lines = append(chunk.Lines, "+"+after[e.new])
+				chunk.NewLines++
+			default:
+				chunk.Lines = append(chunk.Lines, " "+before[e.old])
+				chunk.OldLines++
+				chunk.NewLines++
+			}
 		}
+		chunks = append(chunks, chunk)
+		k = stop
+	}
+	return chunks
+}
+
+// diffLCS finds a longest common subsequence of a and b, by Myers' O(ND)
+// algorithm in linear space, and returns the lines of each that are not in
+// it
+func diffLCS(a, b []string) (removed, added []bool) {
+	m := &myers{a: a, b: b, removed: make([]bool, len(a)), added: make([]bool, len(b))}
+	size := len(a) + len(b) + 4
+	m.forward, m.backward = make([]int, size), make([]int, size)
+	m.compare(0, len(a), 0, len(b))
+	return m.removed, m.added
+}
 
-		if len(chunkLines) > 0 {
-			chunk.Lines = chunkLines
-			chunk.OldLines = chunkOldCount
-			chunk.NewLines = chunkNewCount
-			chunks = append(chunks, chunk)
+// myers holds the state of diffLCS
+type myers struct {
+	a, b              []string
+	removed, added    []bool
+	forward, backward []int // The furthest x on each diagonal
+}
+
+// compare marks the differences between a[aLo:aHi] and b[bLo:bHi], splitting
+// them at the middle of an optimal edit path
+func (m *myers) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && m.a[aLo] == m.b[bLo] {
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && m.a[aHi-1] == m.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+	switch {
+	case aLo == aHi:
+		for j := bLo; j < bHi; j++ {
+			m.added[j] = true
+		}
+		return
+	case bLo == bHi:
+		for i := aLo; i < aHi; i++ {
+			m.removed[i] = true
 		}
+		return
 	}
+	x0, y0, x1, y1 := m.middleSnake(aLo, aHi, bLo, bHi)
+	m.compare(aLo, aLo+x0, bLo, bLo+y0)
+	m.compare(aLo+x1, aHi, bLo+y1, bHi)
+}
 
-	return chunks
+// middleSnake finds the snake, from (x0, y0) to (x1, y1) relative to aLo
+// and bLo, in the middle of an optimal edit path, by searching from both
+// ends until the paths meet
+func (m *myers) middleSnake(aLo, aHi, bLo, bHi int) (x0, y0, x1, y1 int) {
+	n, k := aHi-aLo, bHi-bLo
+	delta := n - k
+	odd := delta%2 != 0
+	limit := (n + k + 1) / 2
+	offset := limit + 1
+	forward, backward := m.forward, m.backward
+	forward[offset+1], backward[offset+1] = 0, 0
+	for d := 0; d <= limit; d++ {
+		for diag := -d; diag <= d; diag += 2 {
+			var x int
+			if diag == -d || diag != d && forward[offset+diag-1] < forward[offset+diag+1] {
+				x = forward[offset+diag+1]
+			} else {
+				x = forward[offset+diag-1] + 1
+			}
+			y := x - diag
+			startX, startY := x, y
+			for x < n && y < k && m.a[aLo+x] == m.b[bLo+y] {
+				x++
+				y++
+			}
+			forward[offset+diag] = x
+			if back := delta - diag; odd && back >= -(d-1) && back <= d-1 && x+backward[offset+back] >= n {
+				return startX, startY, x, y
+			}
+		}
+		for diag := -d; diag <= d; diag += 2 {
+			var x int
+			if diag == -d || diag != d && backward[offset+diag-1] < backward[offset+diag+1] {
+				x = backward[offset+diag+1]
+			} else {
+				x = backward[offset+diag-1] + 1
+			}
+			y := x - diag
+			startX, startY := x, y
+			for x < n && y < k && m.a[aHi-1-x] == m.b[bHi-1-y] {
+				x++
+				y++
+			}
+			backward[offset+diag] = x
+			if front := delta - diag; !odd && front >= -d && front <= d && x+forward[offset+front] >= n {
+				return n - x, k - y, n - startX, k - startY
+			}
+		}
+	}
+	panic("diff: no middle snake") // Unreachable: the paths always meet
 }
 
 // max returns the maximum of two integers
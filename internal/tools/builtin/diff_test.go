@@ -0,0 +1,295 @@
+package builtin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	files := map[string]string{
+		"old.txt":      "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+		"new.txt":      "1\n2\n3\n4\n5\nx\n6\n7\n8\n9\n10\n",
+		"two.txt":      "1\ntwo\n3\n4\n5\n6\n7\n8\n9\n10\n",
+		"eol.txt":      "a\nb",
+		"eol2.txt":     "a\nc",
+		"line.txt":     "a\n",
+		"bare.txt":     "a",
+		"upper.txt":    "A\nB\n",
+		"lower.txt":    "a\nb\n",
+		"a.txt":        "y\n",
+		"v1/a.txt":     "x\n",
+		"v1/only.txt":  "gone\n",
+		"v1/sub/c.txt": "1\n",
+		"v2/a.txt":     "y\n",
+		"v2/new.txt":   "fresh\n",
+		"v2/sub/c.txt": "2\n",
+	}
+	open := func(name string) (io.ReadCloser, error) {
+		data, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("file not found")
+		}
+		return io.NopCloser(strings.NewReader(data)), nil
+	}
+	list := func() ([]string, error) {
+		var names []string
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names, nil
+	}
+
+	tests := []struct {
+		name           string
+		args           []string
+		input          string
+		noFiles        bool
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name:           "separated texts",
+			input:          "a\nb\nc\n---LLMCMD_DIFF_SEPARATOR---\na\nB\nc\n",
+			noFiles:        true,
+			expectedOutput: "--- a/file\n+++ b/file\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name:    "separated identical texts",
+			input:   "a\n---LLMCMD_DIFF_SEPARATOR---\na\n",
+			noFiles: true,
+		},
+		{
+			name:          "no separator",
+			input:         "a\n",
+			noFiles:       true,
+			expectedError: "diff: input must contain exactly one ---LLMCMD_DIFF_SEPARATOR---",
+		},
+		{
+			name:           "inserted line",
+			args:           []string{"old.txt", "new.txt"},
+			expectedOutput: "--- old.txt\n+++ new.txt\n@@ -3,6 +3,7 @@\n 3\n 4\n 5\n+x\n 6\n 7\n 8\n",
+		},
+		{
+			name:           "no context",
+			args:           []string{"-U0", "old.txt", "two.txt"},
+			expectedOutput: "--- old.txt\n+++ two.txt\n@@ -2 +2 @@\n-2\n+two\n",
+		},
+		{
+			name:           "deleted line without context",
+			args:           []string{"-U", "0", "new.txt", "old.txt"},
+			expectedOutput: "--- new.txt\n+++ old.txt\n@@ -6 +5,0 @@\n-x\n",
+		},
+		{
+			name:           "missing newlines",
+			args:           []string{"eol.txt", "eol2.txt"},
+			expectedOutput: "--- eol.txt\n+++ eol2.txt\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+		{
+			name:           "only the newline differs",
+			args:           []string{"line.txt", "bare.txt"},
+			expectedOutput: "--- line.txt\n+++ bare.txt\n@@ -1 +1 @@\n-a\n+a\n\\ No newline at end of file\n",
+		},
+		{
+			name:           "brief",
+			args:           []string{"-q", "old.txt", "new.txt"},
+			expectedOutput: "Files old.txt and new.txt differ\n",
+		},
+		{
+			name: "ignoring case",
+			args: []string{"-i", "upper.txt", "lower.txt"},
+		},
+		{
+			name:           "stdin and a file",
+			args:           []string{"-", "lower.txt"},
+			input:          "a\nc\n",
+			expectedOutput: "--- -\n+++ lower.txt\n@@ -1,2 +1,2 @@\n a\n-c\n+b\n",
+		},
+		{
+			name:           "directories",
+			args:           []string{"v1", "v2"},
+			expectedOutput: "diff v1/a.txt v2/a.txt\n--- v1/a.txt\n+++ v2/a.txt\n@@ -1 +1 @@\n-x\n+y\nOnly in v2: new.txt\nOnly in v1: only.txt\nCommon subdirectories: v1/sub and v2/sub\n",
+		},
+		{
+			name:           "directories recursively",
+			args:           []string{"-r", "-q", "v1", "v2/"},
+			expectedOutput: "Files v1/a.txt and v2/a.txt differ\nOnly in v2: new.txt\nOnly in v1: only.txt\nFiles v1/sub/c.txt and v2/sub/c.txt differ\n",
+		},
+		{
+			name: "missing files as empty",
+			args: []string{"-rN", "v1/sub", "v2"},
+			expectedOutput: "diff -rN v1/sub/a.txt v2/a.txt\n--- v1/sub/a.txt\n+++ v2/a.txt\n@@ -0,0 +1 @@\n+y\n" +
+				"diff -rN v1/sub/c.txt v2/c.txt\n--- v1/sub/c.txt\n+++ v2/c.txt\n@@ -1 +0,0 @@\n-1\n" +
+				"diff -rN v1/sub/new.txt v2/new.txt\n--- v1/sub/new.txt\n+++ v2/new.txt\n@@ -0,0 +1 @@\n+fresh\n" +
+				"Only in v2: sub\n",
+		},
+		{
+			name: "file and directory",
+			args: []string{"a.txt", "v2"},
+		},
+		{
+			name:          "missing file",
+			args:          []string{"old.txt", "none.txt"},
+			expectedError: "diff: none.txt: file not found",
+		},
+		{
+			name:          "file operands without files",
+			args:          []string{"old.txt", "new.txt"},
+			noFiles:       true,
+			expectedError: "diff: old.txt: file operands are not supported",
+		},
+		{
+			name:          "missing operand",
+			args:          []string{"old.txt"},
+			expectedError: "diff: missing operand after 'old.txt'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output bytes.Buffer
+			var err error
+			if test.noFiles {
+				err = Diff(test.args, strings.NewReader(test.input), &output)
+			} else {
+				err = RunDiff(test.args, strings.NewReader(test.input), &output, open, list)
+			}
+			// Differences are an exit status, checked by TestDiffExitStatus
+			var exitErr *DiffExitError
+			if errors.As(err, &exitErr) && exitErr.Err == nil {
+				err = nil
+			}
+			if test.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Errorf("expected error containing %q, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != test.expectedOutput {
+				t.Errorf("expected output %q, got %q", test.expectedOutput, output.String())
+			}
+		})
+	}
+}
+
+func TestDiffExitStatus(t *testing.T) {
+	files := map[string]string{
+		"a.txt":        "a\n",
+		"same.txt":     "a\n",
+		"b.txt":        "b\n",
+		"v1/a.txt":     "a\n",
+		"v1/sub/c.txt": "1\n",
+		"v2/a.txt":     "a\n",
+		"v2/sub/c.txt": "1\n",
+		"v3/a.txt":     "a\n",
+		"v3/sub/c.txt": "2\n",
+		"v4/a.txt":     "a\n",
+		"v4/b.txt":     "b\n",
+		"v4/sub/c.txt": "1\n",
+	}
+	open := func(name string) (io.ReadCloser, error) {
+		data, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("file not found")
+		}
+		return io.NopCloser(strings.NewReader(data)), nil
+	}
+	list := func() ([]string, error) {
+		var names []string
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names, nil
+	}
+
+	tests := []struct {
+		args   []string
+		status int
+	}{
+		{args: []string{"a.txt", "same.txt"}, status: 0},
+		{args: []string{"a.txt", "b.txt"}, status: 1},
+		{args: []string{"-q", "a.txt", "b.txt"}, status: 1},
+		{args: []string{"v1", "v2"}, status: 0},
+		{args: []string{"-r", "v1", "v2"}, status: 0},
+		{args: []string{"v1", "v3"}, status: 0},
+		{args: []string{"-r", "v1", "v3"}, status: 1},
+		{args: []string{"-rq", "v1", "v3"}, status: 1},
+		{args: []string{"-q", "v1", "v4"}, status: 1},
+		{args: []string{"a.txt", "missing.txt"}, status: 2},
+		{args: []string{"-Z", "a.txt", "b.txt"}, status: 2},
+		{args: []string{"a.txt"}, status: 2},
+	}
+
+	for _, test := range tests {
+		err := RunDiff(test.args, nil, io.Discard, open, list)
+		status := 0
+		var exitErr *DiffExitError
+		if errors.As(err, &exitErr) {
+			status = exitErr.ExitCode()
+		} else if err != nil {
+			t.Errorf("RunDiff(%q) error = %v, want a *DiffExitError", test.args, err)
+			continue
+		}
+		if status != test.status {
+			t.Errorf("RunDiff(%q) status = %d, want %d (error %v)", test.args, status, test.status, err)
+		}
+	}
+}
+
+func TestDiffLCS(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	sequence := func() []string {
+		s := make([]string, random.Intn(30))
+		for i := range s {
+			s[i] = string(rune('a' + random.Intn(4)))
+		}
+		return s
+	}
+	for n := 0; n < 500; n++ {
+		a, b := sequence(), sequence()
+		removed, added := diffLCS(a, b)
+		var common1, common2 []string
+		for i, r := range removed {
+			if !r {
+				common1 = append(common1, a[i])
+			}
+		}
+		for j, r := range added {
+			if !r {
+				common2 = append(common2, b[j])
+			}
+		}
+
+		// The longest common subsequence by dynamic programming
+		lengths := make([][]int, len(a)+1)
+		for i := range lengths {
+			lengths[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				switch {
+				case a[i] == b[j]:
+					lengths[i][j] = lengths[i+1][j+1] + 1
+				case lengths[i+1][j] > lengths[i][j+1]:
+					lengths[i][j] = lengths[i+1][j]
+				default:
+					lengths[i][j] = lengths[i][j+1]
+				}
+			}
+		}
+
+		if strings.Join(common1, "") != strings.Join(common2, "") || len(common1) != lengths[0][0] {
+			t.Fatalf("diffLCS(%q, %q) kept %q and %q; the longest common subsequence has %d lines", a, b, common1, common2, lengths[0][0])
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package builtin
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Base64 encodes stdin, or with -d decodes it, following GNU base64.
+// Encoded output is wrapped at 76 columns unless -w sets another width (0
+// disables wrapping). Decoding ignores line breaks, accepts input without
+// padding, and with -i ignores characters outside the alphabet.
+func Base64(args []string, stdin io.Reader, stdout io.Writer) error {
+	decode, ignoreGarbage := false, false
+	wrap := 76
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-d" || arg == "--decode":
+			decode = true
+		case arg == "-i" || arg == "--ignore-garbage":
+			ignoreGarbage = true
+		case arg == "-di" || arg == "-id":
+			decode, ignoreGarbage = true, true
+		case arg == "-w" || strings.HasPrefix(arg, "-w") || strings.HasPrefix(arg, "--wrap="):
+			value := strings.TrimPrefix(strings.TrimPrefix(arg, "--wrap="), "-w")
+			if arg == "-w" {
+				if i+1 == len(args) {
+					return fmt.Errorf("base64: -w: missing column count")
+				}
+				i++
+				value = args[i]
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return fmt.Errorf("base64: invalid wrap size: %q", value)
+			}
+			wrap = n
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			return fmt.Errorf("base64: %s: invalid option", arg)
+		default:
+			return fmt.Errorf("base64: %s: file operands are not supported; pipe the input instead", arg)
+		}
+	}
+
+	input, err := io.ReadAll(stdin)
+	if err != nil {
+		return fmt.Errorf("base64: failed to read input: %w", err)
+	}
+
+	if decode {
+		var text strings.Builder
+		for _, c := range string(input) {
+			switch {
+			case c == '\n' || c == '\r':
+			case strings.ContainsRune("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/=", c):
+				text.WriteRune(c)
+			case !ignoreGarbage:
+				return fmt.Errorf("base64: invalid input")
+			}
+		}
+		decoded, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(text.String(), "="))
+		if err != nil {
+			return fmt.Errorf("base64: invalid input")
+		}
+		_, err = stdout.Write(decoded)
+		return err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(input)
+	if encoded == "" {
+		return nil
+	}
+	var out strings.Builder
+	for wrap > 0 && len(encoded) > wrap {
+		out.WriteString(encoded[:wrap])
+		out.WriteByte('\n')
+		encoded = encoded[wrap:]
+	}
+	out.WriteString(encoded)
+	out.WriteByte('\n')
+	_, err = io.WriteString(stdout, out.String())
+	return err
+}
+
+// xxdOptions are the parsed options of an xxd invocation
+type xxdOptions struct {
+	columns   int
+	groupSize int
+	length    int // -1 means all of the input
+	seek      int
+	plain     bool
+	revert    bool
+	upper     bool
+}
+
+// Xxd makes a hex dump of stdin in the format of xxd, or with -r converts
+// a dump back to binary. -p gives a plain dump of hex digits only; -c sets
+// the bytes per line, -g the bytes per group, -l and -s limit the dump to
+// a length and an offset, and -u uses upper case hex digits.
+func Xxd(args []string, stdin io.Reader, stdout io.Writer) error {
+	opts, err := parseXxdArgs(args)
+	if err != nil {
+		return err
+	}
+	input, err := io.ReadAll(stdin)
+	if err != nil {
+		return fmt.Errorf("xxd: failed to read input: %w", err)
+	}
+	if opts.revert {
+		return xxdRevert(input, opts.plain, stdout)
+	}
+
+	input = input[min(opts.seek, len(input)):]
+	if opts.length >= 0 && opts.length < len(input) {
+		input = input[:opts.length]
+	}
+	digits := "%02x"
+	if opts.upper {
+		digits = "%02X"
+	}
+
+	out := bufio.NewWriter(stdout)
+	if opts.plain {
+		for i := 0; i < len(input); i += opts.columns {
+			for _, b := range input[i:min(i+opts.columns, len(input))] {
+				fmt.Fprintf(out, digits, b)
+			}
+			out.WriteByte('\n')
+		}
+		return out.Flush()
+	}
+
+	groupSize := opts.groupSize
+	if groupSize == 0 {
+		groupSize = opts.columns
+	}
+	width := opts.columns*2 + (opts.columns+groupSize-1)/groupSize
+	for i := 0; i < len(input); i += opts.columns {
+		line := input[i:min(i+opts.columns, len(input))]
+		var hexPart, text strings.Builder
+		for j, b := range line {
+			fmt.Fprintf(&hexPart, digits, b)
+			if (j+1)%groupSize == 0 {
+				hexPart.WriteByte(' ')
+			}
+			if b >= 0x20 && b <= 0x7e {
+				text.WriteByte(b)
+			} else {
+				text.WriteByte('.')
+			}
+		}
+		fmt.Fprintf(out, "%08x: %-*s %s\n", opts.seek+i, width, hexPart.String(), text.String())
+	}
+	return out.Flush()
+}
+
+// parseXxdArgs parses xxd's options; as in xxd, flags may be combined, as
+// in -rp, and -ps is the same as -p
+func parseXxdArgs(args []string) (xxdOptions, error) {
+	opts := xxdOptions{groupSize: 2, length: -1}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-ps", "-postscript", "-plain":
+			opts.plain = true
+			continue
+		case "-revert":
+			opts.revert = true
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return opts, fmt.Errorf("xxd: %s: file operands are not supported; pipe the input instead", arg)
+		}
+		for j := 1; j < len(arg); j++ {
+			switch c := arg[j]; c {
+			case 'p':
+				opts.plain = true
+			case 'r':
+				opts.revert = true
+			case 'u':
+				opts.upper = true
+			case 'c', 'g', 'l', 's':
+				value := arg[j+1:]
+				if value == "" {
+					if i+1 == len(args) {
+						return opts, fmt.Errorf("xxd: -%c: missing number", c)
+					}
+					i++
+					value = args[i]
+				}
+				n, err := strconv.ParseInt(value, 0, 64)
+				if err != nil || n < 0 {
+					return opts, fmt.Errorf("xxd: -%c: invalid number %q", c, value)
+				}
+				switch c {
+				case 'c':
+					if n == 0 || n > 256 {
+						return opts, fmt.Errorf("xxd: -c: invalid number of columns (max. 256)")
+					}
+					opts.columns = int(n)
+				case 'g':
+					opts.groupSize = int(n)
+				case 'l':
+					opts.length = int(n)
+				case 's':
+					opts.seek = int(n)
+				}
+				j = len(arg)
+			default:
+				return opts, fmt.Errorf("xxd: -%c: invalid option", c)
+			}
+		}
+	}
+	if opts.columns == 0 {
+		opts.columns = 16
+		if opts.plain {
+			opts.columns = 30
+		}
+	}
+	return opts, nil
+}
+
+// xxdRevert converts a hex dump back to binary. A plain dump is any run of
+// hex digits, ignoring white space; a normal dump has an offset before the
+// colon of each line, which is honoured by padding with zero bytes, and hex
+// digits up to the two spaces before the text column.
+func xxdRevert(input []byte, plain bool, stdout io.Writer) error {
+	var data []byte
+	decodeDigits := func(digits string, line int) error {
+		if len(digits)%2 == 1 {
+			digits = digits[:len(digits)-1]
+		}
+		decoded, err := hex.DecodeString(digits)
+		if err != nil {
+			return fmt.Errorf("xxd: line %d: invalid hex digits", line)
+		}
+		data = append(data, decoded...)
+		return nil
+	}
+
+	for n, line := range strings.Split(string(input), "\n") {
+		if plain {
+			if err := decodeDigits(strings.Join(strings.Fields(line), ""), n+1); err != nil {
+				return err
+			}
+			continue
+		}
+		offsetText, rest, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		offset, err := strconv.ParseInt(strings.TrimSpace(offsetText), 16, 64)
+		if err != nil {
+			return fmt.Errorf("xxd: line %d: invalid offset %q", n+1, offsetText)
+		}
+		if int(offset) > len(data) {
+			data = append(data, make([]byte, int(offset)-len(data))...)
+		}
+		data = data[:offset]
+		rest = strings.TrimPrefix(rest, " ")
+		if end := strings.Index(rest, "  "); end >= 0 {
+			rest = rest[:end]
+		}
+		if err := decodeDigits(strings.ReplaceAll(rest, " ", ""), n+1); err != nil {
+			return err
+		}
+	}
+	_, err := stdout.Write(data)
+	return err
+}
@@ -0,0 +1,127 @@
+package builtin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncodingCommands(t *testing.T) {
+	tests := []struct {
+		name           string
+		command        CommandFunc
+		args           []string
+		input          string
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name:           "base64 encode",
+			command:        Base64,
+			input:          "hello\n",
+			expectedOutput: "aGVsbG8K\n",
+		},
+		{
+			name:           "base64 wraps long output",
+			command:        Base64,
+			args:           []string{"-w", "8"},
+			input:          "hello, world",
+			expectedOutput: "aGVsbG8s\nIHdvcmxk\n",
+		},
+		{
+			name:           "base64 without wrapping",
+			command:        Base64,
+			args:           []string{"-w0"},
+			input:          strings.Repeat("x", 60),
+			expectedOutput: strings.Repeat("eHh4", 20) + "\n",
+		},
+		{
+			name:           "base64 decode across lines and without padding",
+			command:        Base64,
+			args:           []string{"-d"},
+			input:          "aGVsbG8s\nIHdvcmxkIQ\n",
+			expectedOutput: "hello, world!",
+		},
+		{
+			name:           "base64 decode ignoring garbage",
+			command:        Base64,
+			args:           []string{"-d", "-i"},
+			input:          "aGV*sbG8=\n",
+			expectedOutput: "hello",
+		},
+		{
+			name:          "base64 invalid input",
+			command:       Base64,
+			args:          []string{"--decode"},
+			input:         "aGV*sbG8=",
+			expectedError: "base64: invalid input",
+		},
+		{
+			name:    "xxd dump",
+			command: Xxd,
+			input:   "Hello, world!\n\x00\x7f\xff",
+			expectedOutput: "00000000: 4865 6c6c 6f2c 2077 6f72 6c64 210a 007f  Hello, world!...\n" +
+				"00000010: ff                                       .\n",
+		},
+		{
+			name:           "xxd columns, groups, seek and length",
+			command:        Xxd,
+			args:           []string{"-c", "4", "-g1", "-s", "2", "-l", "6", "-u"},
+			input:          "\x89PNG\r\n\x1a\n",
+			expectedOutput: "00000002: 4E 47 0D 0A  NG..\n00000006: 1A 0A        ..\n",
+		},
+		{
+			name:           "xxd plain",
+			command:        Xxd,
+			args:           []string{"-ps"},
+			input:          strings.Repeat("a", 32),
+			expectedOutput: strings.Repeat("61", 30) + "\n6161\n",
+		},
+		{
+			name:           "xxd revert plain",
+			command:        Xxd,
+			args:           []string{"-r", "-p"},
+			input:          "4865 6c6c\n6f0a\n",
+			expectedOutput: "Hello\n",
+		},
+		{
+			name:    "xxd revert dump",
+			command: Xxd,
+			args:    []string{"-r"},
+			input: "00000000: 4865 6c6c 6f2c 2077 6f72 6c64 210a 007f  Hello, world!...\n" +
+				"00000010: ff                                       .\n",
+			expectedOutput: "Hello, world!\n\x00\x7f\xff",
+		},
+		{
+			name:          "xxd invalid columns",
+			command:       Xxd,
+			args:          []string{"-c", "0"},
+			expectedError: "xxd: -c: invalid number of columns",
+		},
+		{
+			name:          "xxd file operand",
+			command:       Xxd,
+			args:          []string{"image.png"},
+			expectedError: "file operands are not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output strings.Builder
+			err := tt.command(tt.args, strings.NewReader(tt.input), &output)
+
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != tt.expectedOutput {
+				t.Errorf("expected output %q, got %q", tt.expectedOutput, output.String())
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -80,91 +81,120 @@ func Cut(args []string, stdin io.Reader, stdout io.Writer) error {
 	return scanner.Err()
 }
 
-// Uniq removes duplicate consecutive lines
-func Uniq(args []string, stdin io.Reader, stdout io.Writer) error {
-	countOnly := false
-	duplicatesOnly := false
-	uniqueOnly := false
-
-	// Parse flags
-	for _, arg := range args {
-		switch arg {
-		case "-c":
-			countOnly = true
-		case "-d":
-			duplicatesOnly = true
-		case "-u":
-			uniqueOnly = true
-		}
+// Nl numbers lines like GNU nl: -b selects the lines to number (a all, t
+// non-empty, the default, n none, or pREGEX lines matching REGEX), -w the
+// width of numbers, -s the separator after them, -v the first number, -i
+// the increment and -n the format (rn right justified, ln left, rz zero
+// padded). Lines that are not numbered are indented to line up.
+func Nl(args []string, stdin io.Reader, stdout io.Writer) error {
+	style, width, separator, format := "t", 6, "\t", "rn"
+	start, increment := 1, 1
+	var pattern *regexp.Regexp
+	long := map[string]byte{
+		"--body-numbering": 'b', "--number-width": 'w', "--number-separator": 's',
+		"--starting-line-number": 'v', "--line-increment": 'i', "--number-format": 'n',
 	}
-
-	scanner := bufio.NewScanner(stdin)
-	var prevLine string
-	var count int
-	first := true
-
-	outputLine := func(line string, cnt int) {
-		if countOnly {
-			fmt.Fprintf(stdout, "%4d %s\n", cnt, line)
-		} else if duplicatesOnly && cnt > 1 {
-			fmt.Fprintln(stdout, line)
-		} else if uniqueOnly && cnt == 1 {
-			fmt.Fprintln(stdout, line)
-		} else if !duplicatesOnly && !uniqueOnly {
-			fmt.Fprintln(stdout, line)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		var option byte
+		var value string
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			name, v, hasValue := strings.Cut(arg, "=")
+			c, ok := long[name]
+			if !ok {
+				return fmt.Errorf("nl: %s: invalid option", name)
+			}
+			if !hasValue {
+				return fmt.Errorf("nl: %s: missing argument", name)
+			}
+			option, value = c, v
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			option, value = arg[1], arg[2:]
+			if strings.IndexByte("bwsvin", option) < 0 {
+				return fmt.Errorf("nl: -%c: invalid option", option)
+			}
+			if value == "" {
+				if i+1 == len(args) {
+					return fmt.Errorf("nl: -%c: missing argument", option)
+				}
+				i++
+				value = args[i]
+			}
+		default:
+			return fmt.Errorf("nl: %s: file operands are not supported; pipe the input instead", arg)
 		}
-	}
-
-	for scanner.Scan() {
-		line := scanner.Text()
 
-		if first {
-			prevLine = line
-			count = 1
-			first = false
-		} else if line == prevLine {
-			count++
-		} else {
-			outputLine(prevLine, count)
-			prevLine = line
-			count = 1
+		switch option {
+		case 'b':
+			if rest, ok := strings.CutPrefix(value, "p"); ok {
+				re, err := regexp.Compile(rest)
+				if err != nil {
+					return fmt.Errorf("nl: invalid regular expression: %w", err)
+				}
+				pattern = re
+			} else if value != "a" && value != "t" && value != "n" {
+				return fmt.Errorf("nl: invalid body numbering style: '%s'", value)
+			}
+			style = value[:1]
+		case 'n':
+			if value != "ln" && value != "rn" && value != "rz" {
+				return fmt.Errorf("nl: invalid line number format: '%s'", value)
+			}
+			format = value
+		case 's':
+			separator = value
+		default:
+			n, err := strconv.Atoi(value)
+			if err != nil || (option == 'w' && n <= 0) {
+				return fmt.Errorf("nl: invalid line number field width or number: '%s'", value)
+			}
+			switch option {
+			case 'w':
+				width = n
+			case 'v':
+				start = n
+			case 'i':
+				increment = n
+			}
 		}
 	}
 
-	// Output the last line
-	if !first {
-		outputLine(prevLine, count)
-	}
-
-	return scanner.Err()
-}
-
-// Nl numbers lines
-func Nl(args []string, stdin io.Reader, stdout io.Writer) error {
-	numberNonEmpty := false
-
-	// Parse flags
-	for _, arg := range args {
-		switch arg {
-		case "-b":
-			numberNonEmpty = true
+	numberFormat := map[string]string{"rn": "%*d", "ln": "%-*d", "rz": "%0*d"}[format]
+	blank := strings.Repeat(" ", width+len(separator))
+	out := bufio.NewWriter(stdout)
+	reader := bufio.NewReader(stdin)
+	number := start
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			text := strings.TrimSuffix(line, "\n")
+			numbered := false
+			switch style {
+			case "a":
+				numbered = true
+			case "t":
+				numbered = text != ""
+			case "p":
+				numbered = pattern.MatchString(text)
+			}
+			if numbered {
+				fmt.Fprintf(out, numberFormat, width, number)
+				out.WriteString(separator)
+				number += increment
+			} else {
+				out.WriteString(blank)
+			}
+			out.WriteString(text)
+			out.WriteByte('\n')
 		}
-	}
-
-	scanner := bufio.NewScanner(stdin)
-	lineNum := 1
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		if numberNonEmpty && strings.TrimSpace(line) == "" {
-			fmt.Fprintln(stdout, line)
-		} else {
-			fmt.Fprintf(stdout, "%6d\t%s\n", lineNum, line)
-			lineNum++
+		if err == io.EOF {
+			return out.Flush()
+		}
+		if err != nil {
+			return err
 		}
 	}
-
-	return scanner.Err()
 }
 
 // Tee writes input to both stdout and multiple files
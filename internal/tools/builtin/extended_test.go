@@ -0,0 +1,82 @@
+package builtin
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNl(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		input          string
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name:           "non-empty lines by default",
+			input:          "a\n\nb",
+			expectedOutput: "     1\ta\n       \n     2\tb\n",
+		},
+		{
+			name:           "all lines",
+			args:           []string{"-ba"},
+			input:          "a\n\nb\n",
+			expectedOutput: "     1\ta\n     2\t\n     3\tb\n",
+		},
+		{
+			name:           "width and separator",
+			args:           []string{"-w", "3", "-s", ": "},
+			input:          "a\nb\n",
+			expectedOutput: "  1: a\n  2: b\n",
+		},
+		{
+			name:           "lines matching a pattern",
+			args:           []string{"-bp^E", "-w1", "-s "},
+			input:          "ERROR x\nok\nERROR y\n",
+			expectedOutput: "1 ERROR x\n  ok\n2 ERROR y\n",
+		},
+		{
+			name:           "start, increment and format",
+			args:           []string{"-v", "10", "-i", "5", "-n", "rz", "-w", "4"},
+			input:          "a\nb\n",
+			expectedOutput: "0010\ta\n0015\tb\n",
+		},
+		{
+			name:           "left justified",
+			args:           []string{"--number-format=ln", "--number-width=3"},
+			input:          "a\n",
+			expectedOutput: "1  \ta\n",
+		},
+		{
+			name:          "invalid style",
+			args:          []string{"-b", "x"},
+			expectedError: "nl: invalid body numbering style: 'x'",
+		},
+		{
+			name:          "file operand",
+			args:          []string{"log.txt"},
+			expectedError: "nl: log.txt: file operands are not supported",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output bytes.Buffer
+			err := Nl(test.args, strings.NewReader(test.input), &output)
+			if test.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != test.expectedOutput {
+				t.Errorf("expected output %q, got %q", test.expectedOutput, output.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package builtin
+
+import "io"
+
+// Builtins read stdin and write stdout. A few can also work with named
+// files when their caller has files to offer, as llmsh does with its
+// virtual files; these have a Run variant taking an OpenFunc or Files.
+
+// OpenFunc opens a named file, for commands that can take file operands
+type OpenFunc func(name string) (io.ReadCloser, error)
+
+// ListFunc lists the names of the files an OpenFunc opens, for commands
+// that compare directories. Names are slash-separated paths; a directory is
+// the prefix of the names of its files.
+type ListFunc func() ([]string, error)
+
+// Files gives a command access to named files it reads and creates, such
+// as the archive and members of tar or the chunks of split
+type Files interface {
+	Open(name string) (io.ReadCloser, error)
+	Create(name string) (io.WriteCloser, error)
+}
@@ -0,0 +1,403 @@
+package builtin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// grepOptions are the parsed options of a grep invocation
+type grepOptions struct {
+	patterns    []string
+	syntax      byte // 'G' basic (the default), 'E' extended or 'F' fixed strings
+	ignoreCase  bool
+	invert      bool
+	lineNumber  bool
+	count       bool
+	onlyMatch   bool
+	wordRegexp  bool
+	lineRegexp  bool
+	after       int
+	before      int
+	maxCount    int // 0 means no limit
+	hasPatterns bool
+}
+
+// GrepExitError carries grep's exit status: 1 when no line was selected,
+// with no Err, or 2 with the error that stopped it
+type GrepExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *GrepExitError) Error() string {
+	if e.Err == nil {
+		return "grep: no lines selected"
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns the error that stopped grep, if any
+func (e *GrepExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns 1 when no line was selected and 2 on errors, as in grep
+func (e *GrepExitError) ExitCode() int {
+	return e.Code
+}
+
+// Grep searches stdin for lines matching a pattern. It follows GNU grep:
+// patterns are basic regular expressions unless -E or -F is given, -o
+// prints only the matching parts, -c counts the selected lines and
+// -A/-B/-C print context, separating non-adjacent groups with "--". When no
+// line is selected, or on errors, it returns a *GrepExitError.
+func Grep(args []string, stdin io.Reader, stdout io.Writer) error {
+	opts, err := parseGrepArgs(args)
+	if err != nil {
+		return &GrepExitError{Code: 2, Err: err}
+	}
+	regex, err := opts.compile()
+	if err != nil {
+		return &GrepExitError{Code: 2, Err: err}
+	}
+	if opts.onlyMatch || opts.count {
+		// GNU grep prints no context with -o or -c
+		opts.after, opts.before = 0, 0
+	}
+
+	type grepLine struct {
+		number int
+		text   string
+	}
+	var beforeLines []grepLine
+	afterLeft, lastPrinted, selected := 0, 0, 0
+	printLine := func(number int, text string, sep byte) {
+		if opts.lineNumber {
+			fmt.Fprintf(stdout, "%d%c", number, sep)
+		}
+		fmt.Fprintln(stdout, text)
+		lastPrinted = number
+	}
+
+	scanner := bufio.NewScanner(stdin)
+	for number := 1; scanner.Scan(); number++ {
+		line := scanner.Text()
+		if opts.maxCount > 0 && selected == opts.maxCount {
+			// Stop after the last match's trailing context
+			if afterLeft == 0 {
+				break
+			}
+			printLine(number, line, '-')
+			afterLeft--
+			continue
+		}
+		if regex.MatchString(line) == opts.invert {
+			if afterLeft > 0 {
+				printLine(number, line, '-')
+				afterLeft--
+			} else if opts.before > 0 {
+				if len(beforeLines) == opts.before {
+					beforeLines = beforeLines[1:]
+				}
+				beforeLines = append(beforeLines, grepLine{number, line})
+			}
+			continue
+		}
+
+		selected++
+		switch {
+		case opts.count:
+		case opts.onlyMatch:
+			if !opts.invert {
+				for _, match := range regex.FindAllString(line, -1) {
+					if match != "" {
+						printLine(number, match, ':')
+					}
+				}
+			}
+		default:
+			first := number
+			if len(beforeLines) > 0 {
+				first = beforeLines[0].number
+			}
+			if lastPrinted > 0 && first > lastPrinted+1 && (opts.after > 0 || opts.before > 0) {
+				fmt.Fprintln(stdout, "--")
+			}
+			for _, before := range beforeLines {
+				printLine(before.number, before.text, '-')
+			}
+			beforeLines = beforeLines[:0]
+			printLine(number, line, ':')
+			afterLeft = opts.after
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return &GrepExitError{Code: 2, Err: err}
+	}
+	if opts.count {
+		fmt.Fprintln(stdout, selected)
+	}
+	if selected == 0 {
+		return &GrepExitError{Code: 1}
+	}
+	return nil
+}
+
+// parseGrepArgs parses grep's options. Short options may be combined, as in
+// -in or -A2, and -NUM is the same as -C NUM. The first operand is the
+// pattern unless -e is given; grep only reads stdin, so further operands
+// are rejected.
+func parseGrepArgs(args []string) (grepOptions, error) {
+	opts := grepOptions{syntax: 'G'}
+	setNumber := func(option, value string, target *int) error {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("grep: %s: invalid number %q", option, value)
+		}
+		*target = n
+		return nil
+	}
+	setContext := func(option, value string) error {
+		if err := setNumber(option, value, &opts.after); err != nil {
+			return err
+		}
+		opts.before = opts.after
+		return nil
+	}
+	addPattern := func(pattern string) {
+		opts.patterns = append(opts.patterns, strings.Split(pattern, "\n")...)
+		opts.hasPatterns = true
+	}
+
+	var operands []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			operands = append(operands, args[i+1:]...)
+			break
+		}
+		if strings.HasPrefix(arg, "--") {
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			takesValue := name == "regexp" || name == "after-context" || name == "before-context" ||
+				name == "context" || name == "max-count"
+			if takesValue && !hasValue {
+				if i+1 == len(args) {
+					return opts, fmt.Errorf("grep: --%s: missing argument", name)
+				}
+				i++
+				value = args[i]
+			}
+			var err error
+			switch name {
+			case "basic-regexp":
+				opts.syntax = 'G'
+			case "extended-regexp":
+				opts.syntax = 'E'
+			case "fixed-strings":
+				opts.syntax = 'F'
+			case "ignore-case":
+				opts.ignoreCase = true
+			case "invert-match":
+				opts.invert = true
+			case "line-number":
+				opts.lineNumber = true
+			case "count":
+				opts.count = true
+			case "only-matching":
+				opts.onlyMatch = true
+			case "word-regexp":
+				opts.wordRegexp = true
+			case "line-regexp":
+				opts.lineRegexp = true
+			case "regexp":
+				addPattern(value)
+			case "after-context":
+				err = setNumber(arg, value, &opts.after)
+			case "before-context":
+				err = setNumber(arg, value, &opts.before)
+			case "context":
+				err = setContext(arg, value)
+			case "max-count":
+				err = setNumber(arg, value, &opts.maxCount)
+			default:
+				return opts, fmt.Errorf("grep: %s: invalid option", arg)
+			}
+			if err != nil {
+				return opts, err
+			}
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			operands = append(operands, arg)
+			continue
+		}
+
+		for j := 1; j < len(arg); j++ {
+			c := arg[j]
+			if c >= '0' && c <= '9' {
+				k := j
+				for k < len(arg) && arg[k] >= '0' && arg[k] <= '9' {
+					k++
+				}
+				if err := setContext("-"+arg[j:k], arg[j:k]); err != nil {
+					return opts, err
+				}
+				j = k - 1
+				continue
+			}
+			switch c {
+			case 'G', 'E', 'F':
+				opts.syntax = c
+			case 'i', 'y':
+				opts.ignoreCase = true
+			case 'v':
+				opts.invert = true
+			case 'n':
+				opts.lineNumber = true
+			case 'c':
+				opts.count = true
+			case 'o':
+				opts.onlyMatch = true
+			case 'w':
+				opts.wordRegexp = true
+			case 'x':
+				opts.lineRegexp = true
+			case 'e', 'A', 'B', 'C', 'm':
+				value := arg[j+1:]
+				if value == "" {
+					if i+1 == len(args) {
+						return opts, fmt.Errorf("grep: -%c: missing argument", c)
+					}
+					i++
+					value = args[i]
+				}
+				option := "-" + string(c)
+				var err error
+				switch c {
+				case 'e':
+					addPattern(value)
+				case 'A':
+					err = setNumber(option, value, &opts.after)
+				case 'B':
+					err = setNumber(option, value, &opts.before)
+				case 'C':
+					err = setContext(option, value)
+				case 'm':
+					err = setNumber(option, value, &opts.maxCount)
+				}
+				if err != nil {
+					return opts, err
+				}
+				j = len(arg)
+			default:
+				return opts, fmt.Errorf("grep: -%c: invalid option", c)
+			}
+		}
+	}
+
+	if !opts.hasPatterns {
+		if len(operands) == 0 {
+			return opts, fmt.Errorf("grep: missing pattern")
+		}
+		addPattern(operands[0])
+		operands = operands[1:]
+	}
+	if len(operands) > 0 {
+		return opts, fmt.Errorf("grep: %s: file operands are not supported; pipe the input instead", operands[0])
+	}
+	return opts, nil
+}
+
+// compile builds one regex from the patterns, any of which may match
+func (o grepOptions) compile() (*regexp.Regexp, error) {
+	alternatives := make([]string, len(o.patterns))
+	for i, pattern := range o.patterns {
+		switch o.syntax {
+		case 'F':
+			pattern = regexp.QuoteMeta(pattern)
+		case 'G':
+			pattern = translateBRE(pattern)
+		}
+		alternatives[i] = "(?:" + pattern + ")"
+	}
+	pattern := strings.Join(alternatives, "|")
+	if o.lineRegexp {
+		pattern = "^(?:" + pattern + ")$"
+	} else if o.wordRegexp {
+		pattern = `\b(?:` + pattern + `)\b`
+	}
+	regex, err := compileRegex(pattern, o.ignoreCase)
+	if err != nil {
+		return nil, fmt.Errorf("grep: %w", err)
+	}
+	return regex, nil
+}
+
+// translateBRE converts a POSIX basic regular expression to Go syntax. In a
+// BRE, \( \) \{ \} \| \+ and \? are operators and the bare characters are
+// literals; a * at the start of an expression or group is also a literal.
+func translateBRE(pattern string) string {
+	var b strings.Builder
+	atStart := true
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '[':
+			// Copy a bracket expression unchanged; a ] first in it is literal,
+			// as in Go
+			end := i + 1
+			if end < len(pattern) && pattern[end] == '^' {
+				end++
+			}
+			if end < len(pattern) && pattern[end] == ']' {
+				end++
+			}
+			for end < len(pattern) && pattern[end] != ']' {
+				if pattern[end] == '[' && end+1 < len(pattern) && strings.IndexByte(":.=", pattern[end+1]) >= 0 {
+					if close := strings.Index(pattern[end+2:], string(pattern[end+1])+"]"); close >= 0 {
+						end += close + 3
+						continue
+					}
+				}
+				end++
+			}
+			if end == len(pattern) {
+				b.WriteString(regexp.QuoteMeta(pattern[i:]))
+				return b.String()
+			}
+			b.WriteString(pattern[i : end+1])
+			i = end
+			atStart = false
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			next := pattern[i]
+			switch next {
+			case '(', '|':
+				b.WriteByte(next)
+				atStart = true
+				continue
+			case ')', '{', '}', '+', '?':
+				b.WriteByte(next)
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(next)
+			}
+			atStart = false
+		case c == '*' && atStart:
+			b.WriteString(`\*`)
+			atStart = false
+		case strings.IndexByte("(){}|+?", c) >= 0:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+			atStart = false
+		default:
+			b.WriteByte(c)
+			atStart = c == '^' && atStart
+		}
+	}
+	return b.String()
+}
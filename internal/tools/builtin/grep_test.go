@@ -0,0 +1,164 @@
+package builtin
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGrepOptions(t *testing.T) {
+	const log = "start\nerror: disk\nok\nok\nok\nwarn: cpu\nerror: net\nend\n"
+
+	tests := []struct {
+		name           string
+		args           []string
+		input          string
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name:           "basic regex operators are escaped",
+			args:           []string{`a\(b\|c\)\{2\}`},
+			input:          "ab\nabc\nacb\n",
+			expectedOutput: "abc\nacb\n",
+		},
+		{
+			name:           "basic regex treats bare operators as literals",
+			args:           []string{"a+(b)"},
+			input:          "aab\na+(b)\n",
+			expectedOutput: "a+(b)\n",
+		},
+		{
+			name:           "extended regex",
+			args:           []string{"-E", "^(error|warn): [a-z]+$"},
+			input:          log,
+			expectedOutput: "error: disk\nwarn: cpu\nerror: net\n",
+		},
+		{
+			name:           "fixed strings",
+			args:           []string{"-F", "a.c"},
+			input:          "abc\na.c\n",
+			expectedOutput: "a.c\n",
+		},
+		{
+			name:           "only matching with line numbers",
+			args:           []string{"-on", `[0-9]\+`},
+			input:          "a1b22\nnone\n333\n",
+			expectedOutput: "1:1\n1:22\n3:333\n",
+		},
+		{
+			name:           "count inverted",
+			args:           []string{"-vc", "ok"},
+			input:          log,
+			expectedOutput: "5\n",
+		},
+		{
+			name:           "after context",
+			args:           []string{"-A", "1", "error"},
+			input:          log,
+			expectedOutput: "error: disk\nok\n--\nerror: net\nend\n",
+		},
+		{
+			name:           "before context with line numbers",
+			args:           []string{"-n", "-B1", "error"},
+			input:          log,
+			expectedOutput: "1-start\n2:error: disk\n--\n6-warn: cpu\n7:error: net\n",
+		},
+		{
+			name:           "overlapping context is merged",
+			args:           []string{"-C", "2", "-E", "disk|cpu"},
+			input:          log,
+			expectedOutput: "start\nerror: disk\nok\nok\nok\nwarn: cpu\nerror: net\nend\n",
+		},
+		{
+			name:           "numeric context shorthand",
+			args:           []string{"-1", "warn"},
+			input:          log,
+			expectedOutput: "ok\nwarn: cpu\nerror: net\n",
+		},
+		{
+			name:           "multiple patterns, ignore case and whole words",
+			args:           []string{"-iw", "-e", "OK", "-e", "end"},
+			input:          "ok\nokay\nEND\nending\n",
+			expectedOutput: "ok\nEND\n",
+		},
+		{
+			name:           "max count keeps trailing context",
+			args:           []string{"-m1", "-A1", "ok"},
+			input:          log,
+			expectedOutput: "ok\nok\n",
+		},
+		{
+			name:           "whole line",
+			args:           []string{"-x", "ok"},
+			input:          "ok\nnot ok\n",
+			expectedOutput: "ok\n",
+		},
+		{
+			name:          "missing pattern",
+			args:          []string{"-i"},
+			expectedError: "grep: missing pattern",
+		},
+		{
+			name:          "invalid context",
+			args:          []string{"-A", "x", "a"},
+			expectedError: `grep: -A: invalid number "x"`,
+		},
+		{
+			name:          "file operand",
+			args:          []string{"a", "log.txt"},
+			expectedError: "file operands are not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output strings.Builder
+			err := Grep(tt.args, strings.NewReader(tt.input), &output)
+
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != tt.expectedOutput {
+				t.Errorf("expected output %q, got %q", tt.expectedOutput, output.String())
+			}
+		})
+	}
+}
+
+func TestGrepExitStatus(t *testing.T) {
+	tests := []struct {
+		args   []string
+		status int
+	}{
+		{args: []string{"b"}, status: 0},
+		{args: []string{"x"}, status: 1},
+		{args: []string{"-c", "x"}, status: 1},
+		{args: []string{"-v", "."}, status: 1},
+		{args: []string{"-E", "("}, status: 2},
+		{args: []string{"-Z", "b"}, status: 2},
+		{args: []string{"b", "file"}, status: 2},
+	}
+
+	for _, tt := range tests {
+		var output strings.Builder
+		err := Grep(tt.args, strings.NewReader("a\nb\n"), &output)
+		status := 0
+		var exitErr *GrepExitError
+		if errors.As(err, &exitErr) {
+			status = exitErr.ExitCode()
+		} else if err != nil {
+			t.Errorf("Grep(%q) error = %v, want a *GrepExitError", tt.args, err)
+			continue
+		}
+		if status != tt.status {
+			t.Errorf("Grep(%q) status = %d, want %d (error %v)", tt.args, status, tt.status, err)
+		}
+	}
+}
@@ -83,14 +83,30 @@ exit(code) - Terminate program (0=success, 1=error)`
 
 	u.Subsections["spawn_commands"] = `TEXT PROCESSING:
 - cat: Display/concatenate data
-- grep: Pattern search/filter
-- sed: String replacement/transformation
-- head/tail: Line limit/range extraction
-- sort: Sort
-- uniq: Remove duplicates
-- wc: Count (lines/words/characters)
-- tr: Character transformation
+- grep: Pattern search/filter (-E, -F, -o, -c, -A/-B/-C context)
+- sed: String replacement/transformation (s///g with groups, -n/p, address ranges)
+- head/tail: Line limit/range extraction; tail -f --timeout N follows a running command
+- sort: Sort (-n numeric, -r reverse, -u unique, -t sep, -k key)
+- uniq: Remove adjacent duplicates (-c count, -d repeated, -u unique, -i ignore case, -f/-s skip fields/chars)
+- wc: Count (lines/words/characters, -L longest line)
+- tr: Character transformation ([:upper:]/[:lower:]/[:digit:] classes, a-z ranges, -d delete, -s squeeze, -c complement)
 - cut: Field extraction
+- awk: Field processing, patterns, printf, BEGIN/END (subset)
+- jq: JSON paths, filters, map/select, raw output (subset)
+- csvcut/csvgrep/csvjoin: CSV columns, row filters and joins (quote-aware)
+- rextract: Print regex capture groups per match (-g groups, -f '$1 ${name}' template, --json objects)
+- yaml2json/toml2json: Normalize YAML or TOML to JSON for jq; json2yaml/json2toml convert back
+- base64: Encode/decode (-d)
+- xxd: Hex dump, -p plain, -r reverse
+- gzip/gunzip/zcat: gzip compress/decompress; bunzip2/bzcat, unxz/xzcat: decompress
+- sha256sum/md5sum: Checksums of stdin, or -c to verify a checksum list
+- paste: Merge lines side by side (-d delimiters, -s one line per input)
+- printf/seq/date: Formatted output, number sequences, date formatting and arithmetic (date -d)
+- tac/shuf: Reverse lines; random order or a random sample (shuf -n)
+- strings: Printable text runs in binary input (-n MIN, -t d|o|x offsets)
+- column/fmt/fold/expand: Align tables (column -t), reflow paragraphs, wrap lines, convert tabs
+- stats: One-pass summary: counts, min/max/mean/sum of numeric columns, most frequent values
+- tar: List (-t) or extract to stdout (-xO) archive members; gzip/bzip2/xz detected
 
 PIPELINE EXAMPLES:
 - spawn("grep ERROR | head -10"): Top 10 error lines
@@ -201,8 +217,13 @@ spawn("sed '/pattern/d'") - Delete pattern lines`
 3. spawn("diff file1_temp file2_temp")
 
 LINE-BY-LINE DIFF:
-spawn("diff -u file1 file2") - Unified diff format
-spawn("diff --side-by-side file1 file2") - Side-by-side display
+spawn("diff -u file1 file2") - Unified diff format (the default)
+spawn("diff -q file1 file2") - Only report whether they differ
+spawn("diff -r dir1 dir2") - Compare virtual files by name (dir1/x with dir2/x)
+
+THREE-WAY MERGE:
+spawn("diff3 -m mine base yours") - Merge base->yours into mine; conflicts get <<<<<<< markers
+spawn("merge mine base yours") - The same, overwriting mine
 
 CONTENT ANALYSIS:
 spawn("comm -12 file1 file2") - Common lines
@@ -216,7 +237,7 @@ Solution: Test with spawn("echo test") to verify spawn works
 FILE CONSUMED:
 Problem: read() returns EOF, "file already consumed"
 Cause: PIPE behavior - file already read
-Solution: Recreate virtual file
+Solution: Recreate virtual file; open it with "w+" next time to keep it readable
 
 SYNTAX ERROR:
 Problem: spawn() gives "command not found"
@@ -243,6 +264,11 @@ VIRTUAL FILE DEBUG:
 spawn("wc -l") - Line count
 spawn("wc -w") - Word count
 spawn("wc -c") - Character count
+spawn("wc -L") - Longest line width
+
+SUMMARY:
+spawn("stats") - Counts plus min/max/mean/sum and top values of each column
+spawn("stats --csv -c price,qty") - The same for CSV columns, by name or number
 
 FREQUENCY ANALYSIS:
 spawn("sort | uniq -c") - Count occurrences
@@ -515,20 +541,34 @@ func (u *UsageData) initKeyMappings() {
 	}
 }
 
-// GetHelp implements the help command
+// GetHelp implements the help command. Keys are usage topics or builtin
+// names, whose manuals list their synopsis, options, examples and common
+// errors; --list prints the keys.
 func GetHelp(args []string, stdin io.Reader, stdout io.Writer) error {
 	if len(args) == 0 {
-		return fmt.Errorf("no keys provided")
+		return fmt.Errorf("no keys provided; help --list shows the keys")
 	}
 
-	// Validate keys
 	usageData := NewUsageData()
 	for _, key := range args {
-		if _, exists := usageData.KeyMappings[key]; !exists {
-			return fmt.Errorf("invalid key: %s", key)
+		if key == "--list" {
+			writeHelpList(stdout, usageData)
+			return nil
 		}
 	}
 
+	// Validate keys
+	var commands []string
+	for _, key := range args {
+		if _, exists := usageData.KeyMappings[key]; exists {
+			continue
+		}
+		if _, exists := manuals[key]; !exists {
+			return fmt.Errorf("invalid key: %s; help --list shows the keys", key)
+		}
+		commands = append(commands, key)
+	}
+
 	// Collect all subsections from requested keys
 	subsectionSet := make(map[string]bool)
 	for _, key := range args {
@@ -560,5 +600,12 @@ func GetHelp(args []string, stdin io.Reader, stdout io.Writer) error {
 		}
 	}
 
+	for i, name := range commands {
+		if i > 0 || len(subsections) > 0 {
+			fmt.Fprint(stdout, "\n")
+		}
+		writeManual(stdout, name, manuals[name])
+	}
+
 	return nil
 }
@@ -76,3 +76,59 @@ func TestGetHelp(t *testing.T) {
 		})
 	}
 }
+
+func TestGetHelpManuals(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		expectedInText []string
+	}{
+		{
+			name:           "builtin",
+			args:           []string{"jq"},
+			expectedInText: []string{"=== JQ ===", "SYNOPSIS:", "OPTIONS:", "  -r: print strings without quotes", "EXAMPLES:", "COMMON ERRORS:", "    Fix: "},
+		},
+		{
+			name:           "builtins and topics",
+			args:           []string{"sort", "debugging", "uniq"},
+			expectedInText: []string{"USAGE INFORMATION FOR: sort, debugging, uniq", "=== DEBUG_TECHNIQUES ===", "=== SORT ===", "=== UNIQ ==="},
+		},
+		{
+			name:           "list",
+			args:           []string{"--list"},
+			expectedInText: []string{"USAGE KEYS:\n", "  log_analysis\n", "BUILTIN KEYS:\n", "  rextract   rextract [-1inz]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := GetHelp(tt.args, nil, &buf); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for _, expected := range tt.expectedInText {
+				if !strings.Contains(buf.String(), expected) {
+					t.Errorf("Expected output to contain %q, but it didn't.\nOutput: %s", expected, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestEveryBuiltinHasManual(t *testing.T) {
+	for name := range Commands {
+		m, ok := manuals[name]
+		if !ok {
+			t.Errorf("%s has no manual", name)
+			continue
+		}
+		if m.Synopsis == "" || m.Summary == "" || len(m.Examples) == 0 {
+			t.Errorf("%s: manual needs a synopsis, a summary and an example", name)
+		}
+	}
+	for name := range manuals {
+		if _, ok := Commands[name]; !ok {
+			t.Errorf("manual for %s, which is not a builtin", name)
+		}
+	}
+}
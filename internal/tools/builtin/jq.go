@@ -0,0 +1,401 @@
+package builtin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Jq runs a subset of jq over a stream of JSON values on stdin: paths,
+// iteration, pipes, object and array construction, conditionals, reduce,
+// variables, assignments and the common builtins such as map, select, keys,
+// sort_by and group_by. def, label and file operands are not supported.
+func Jq(args []string, stdin io.Reader, stdout io.Writer) error {
+	var raw, join, compact, nullInput, slurp, sortKeys bool
+	env := (*jqEnv)(nil)
+	filter := ""
+	haveFilter := false
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--arg" || arg == "--argjson":
+			if i+2 >= len(args) {
+				return fmt.Errorf("jq: %s: expected a name and a value", arg)
+			}
+			name, value := args[i+1], args[i+2]
+			i += 2
+			var v interface{} = value
+			if arg == "--argjson" {
+				var err error
+				if v, err = jqParseJSON(value); err != nil {
+					return fmt.Errorf("jq: --argjson %s: invalid JSON: %w", name, err)
+				}
+			}
+			env = env.bind(name, v)
+		case arg == "--raw-output":
+			raw = true
+		case arg == "--join-output":
+			raw, join = true, true
+		case arg == "--compact-output":
+			compact = true
+		case arg == "--null-input":
+			nullInput = true
+		case arg == "--slurp":
+			slurp = true
+		case arg == "--sort-keys":
+			sortKeys = true
+		case len(arg) > 1 && arg[0] == '-' && arg[1] != '-':
+			for _, flag := range arg[1:] {
+				switch flag {
+				case 'r':
+					raw = true
+				case 'j':
+					raw, join = true, true
+				case 'c':
+					compact = true
+				case 'n':
+					nullInput = true
+				case 's':
+					slurp = true
+				case 'S':
+					sortKeys = true
+				default:
+					return fmt.Errorf("jq: -%c: invalid option", flag)
+				}
+			}
+		case strings.HasPrefix(arg, "--"):
+			return fmt.Errorf("jq: %s: invalid option", arg)
+		case !haveFilter:
+			filter, haveFilter = arg, true
+		default:
+			return fmt.Errorf("jq: %s: file operands are not supported; pipe the input instead", arg)
+		}
+	}
+	if !haveFilter {
+		filter = "."
+	}
+
+	program, err := parseJq(filter)
+	if err != nil {
+		return fmt.Errorf("jq: syntax error: %w", err)
+	}
+
+	dec := json.NewDecoder(bufio.NewReader(stdin))
+	dec.UseNumber()
+	q := &jqInterp{
+		next: func() (interface{}, bool, error) {
+			v, err := jqDecode(dec)
+			if err == io.EOF {
+				return nil, false, nil
+			}
+			if err != nil {
+				return nil, false, fmt.Errorf("jq: invalid JSON input: %w", err)
+			}
+			return v, true, nil
+		},
+	}
+	if slurp {
+		var all []interface{}
+		for {
+			v, ok, err := q.next()
+			if err != nil {
+				return err
+			}
+			if !ok {
+				break
+			}
+			all = append(all, v)
+		}
+		slurped := false
+		q.next = func() (interface{}, bool, error) {
+			if slurped {
+				return nil, false, nil
+			}
+			slurped = true
+			if all == nil {
+				all = []interface{}{}
+			}
+			return all, true, nil
+		}
+	}
+
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+	run := func(input interface{}) error {
+		results, err := q.eval(program, input, env)
+		for _, result := range results {
+			if s, ok := result.(string); ok && raw {
+				out.WriteString(s)
+			} else {
+				out.WriteString(jqEncode(result, !compact, sortKeys))
+			}
+			if !join {
+				out.WriteByte('\n')
+			}
+		}
+		if err != nil {
+			out.Flush()
+			return fmt.Errorf("jq: error: %w", err)
+		}
+		return nil
+	}
+
+	if nullInput {
+		if err := run(nil); err != nil {
+			return err
+		}
+		return out.Flush()
+	}
+	for {
+		input, ok, err := q.next()
+		if err != nil {
+			out.Flush()
+			return err
+		}
+		if !ok {
+			break
+		}
+		if err := run(input); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// jqObject is a JSON object keeping its keys in insertion order, as jq does
+type jqObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newJqObject() *jqObject {
+	return &jqObject{values: make(map[string]interface{})}
+}
+
+func (o *jqObject) get(key string) (interface{}, bool) {
+	v, ok := o.values[key]
+	return v, ok
+}
+
+// set adds or replaces a key in place; objects are shared, so callers set
+// keys only on objects they have just created or cloned
+func (o *jqObject) set(key string, v interface{}) {
+	if _, exists := o.values[key]; !exists {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = v
+}
+
+func (o *jqObject) delete(key string) {
+	if _, exists := o.values[key]; !exists {
+		return
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+func (o *jqObject) clone() *jqObject {
+	c := &jqObject{keys: append([]string(nil), o.keys...), values: make(map[string]interface{}, len(o.values))}
+	for k, v := range o.values {
+		c.values[k] = v
+	}
+	return c
+}
+
+// sortedKeys returns the keys in codepoint order
+func (o *jqObject) sortedKeys() []string {
+	keys := append([]string(nil), o.keys...)
+	sort.Strings(keys)
+	return keys
+}
+
+// jqDecode reads the next JSON value, keeping object keys in order. Numbers
+// are float64, as in jq.
+func jqDecode(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			obj := newJqObject()
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, _ := keyTok.(string)
+				v, err := jqDecode(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj.set(key, v)
+			}
+			_, err := dec.Token()
+			return obj, err
+		case '[':
+			arr := []interface{}{}
+			for dec.More() {
+				v, err := jqDecode(dec)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, v)
+			}
+			_, err := dec.Token()
+			return arr, err
+		}
+		return nil, fmt.Errorf("unexpected %v", t)
+	case json.Number:
+		n, err := strconv.ParseFloat(string(t), 64)
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
+			return nil, err
+		}
+		return n, nil
+	}
+	return tok, nil
+}
+
+// jqParseJSON parses a single JSON value from a string
+func jqParseJSON(s string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	v, err := jqDecode(dec)
+	if err == io.EOF {
+		return nil, fmt.Errorf("no JSON value")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the JSON value")
+	}
+	return v, nil
+}
+
+// jqEncode formats a value as JSON, indented by two spaces when pretty
+func jqEncode(v interface{}, pretty, sortKeys bool) string {
+	var b strings.Builder
+	jqWriteJSON(&b, v, pretty, sortKeys, 0)
+	return b.String()
+}
+
+func jqWriteJSON(b *strings.Builder, v interface{}, pretty, sortKeys bool, depth int) {
+	newline := func(depth int) {
+		if pretty {
+			b.WriteByte('\n')
+			b.WriteString(strings.Repeat("  ", depth))
+		}
+	}
+	switch t := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(t))
+	case float64:
+		b.WriteString(jqFormatNumber(t))
+	case string:
+		b.WriteString(jqQuote(t))
+	case []interface{}:
+		if len(t) == 0 {
+			b.WriteString("[]")
+			return
+		}
+		b.WriteByte('[')
+		for i, elem := range t {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			newline(depth + 1)
+			jqWriteJSON(b, elem, pretty, sortKeys, depth+1)
+		}
+		newline(depth)
+		b.WriteByte(']')
+	case *jqObject:
+		if len(t.keys) == 0 {
+			b.WriteString("{}")
+			return
+		}
+		keys := t.keys
+		if sortKeys {
+			keys = t.sortedKeys()
+		}
+		b.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			newline(depth + 1)
+			b.WriteString(jqQuote(key))
+			b.WriteByte(':')
+			if pretty {
+				b.WriteByte(' ')
+			}
+			jqWriteJSON(b, t.values[key], pretty, sortKeys, depth+1)
+		}
+		newline(depth)
+		b.WriteByte('}')
+	}
+}
+
+// jqFormatNumber formats a number as jq prints it: integers in full, others
+// in the shortest form that reads back the same
+func jqFormatNumber(n float64) string {
+	switch {
+	case math.IsNaN(n):
+		return "null"
+	case math.IsInf(n, 1):
+		return "1.7976931348623157e+308"
+	case math.IsInf(n, -1):
+		return "-1.7976931348623157e+308"
+	case n == math.Trunc(n) && math.Abs(n) < 1e17:
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}
+
+// jqQuote quotes a string as JSON, leaving non-ASCII characters as they are
+func jqQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,181 @@
+package builtin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJq(t *testing.T) {
+	const doc = `{"name":"app","items":[{"id":1,"ok":true,"tags":["x","y"]},{"id":2,"ok":false,"tags":[]}],"meta":{"b":2,"a":1}}`
+
+	tests := []struct {
+		name           string
+		args           []string
+		input          string
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name:           "identity pretty prints in key order",
+			args:           []string{"."},
+			input:          `{"b":[1,{}],"a":null}`,
+			expectedOutput: "{\n  \"b\": [\n    1,\n    {}\n  ],\n  \"a\": null\n}\n",
+		},
+		{
+			name:           "path extraction",
+			args:           []string{".items[0].tags[1]"},
+			input:          doc,
+			expectedOutput: "\"y\"\n",
+		},
+		{
+			name:           "raw output of iterated values",
+			args:           []string{"-r", ".items[].tags[]"},
+			input:          doc,
+			expectedOutput: "x\ny\n",
+		},
+		{
+			name:           "select and object construction",
+			args:           []string{"-c", ".items[] | select(.ok) | {id, count: (.tags | length)}"},
+			input:          doc,
+			expectedOutput: "{\"id\":1,\"count\":2}\n",
+		},
+		{
+			name:           "map and add",
+			args:           []string{"-c", "[.items[].id] | map(. * 10), add"},
+			input:          doc,
+			expectedOutput: "[10,20]\n3\n",
+		},
+		{
+			name:           "stream of inputs",
+			args:           []string{"-c", ".a"},
+			input:          "{\"a\":1}\n{\"a\":[2]}\n",
+			expectedOutput: "1\n[2]\n",
+		},
+		{
+			name:           "slurp",
+			args:           []string{"-c", "-s", "map(.n) | sort"},
+			input:          `{"n":3} {"n":1}`,
+			expectedOutput: "[1,3]\n",
+		},
+		{
+			name:           "null input and inputs",
+			args:           []string{"-n", "[inputs | .n] | length"},
+			input:          `{"n":3} {"n":1}`,
+			expectedOutput: "2\n",
+		},
+		{
+			name:           "string interpolation and formats",
+			args:           []string{"-r", `.items[] | "\(.id): \(.tags | join(","))", ([.id, .ok, "a\"b"] | @csv)`},
+			input:          doc,
+			expectedOutput: "1: x,y\n1,true,\"a\"\"b\"\n2: \n2,false,\"a\"\"b\"\n",
+		},
+		{
+			name:           "keys, entries and sorting",
+			args:           []string{"-c", ".meta | keys, with_entries(.value += 1), ([.[]] | sort)"},
+			input:          doc,
+			expectedOutput: "[\"a\",\"b\"]\n{\"b\":3,\"a\":2}\n[1,2]\n",
+		},
+		{
+			name:           "group_by and sort_by",
+			args:           []string{"-c", ".items | (sort_by(-.id) | map(.id)), (group_by(.ok) | map(length))"},
+			input:          doc,
+			expectedOutput: "[2,1]\n[1,1]\n",
+		},
+		{
+			name:           "conditionals and alternative",
+			args:           []string{"-c", `.items[] | if .ok then "on" else "off" end, (.missing // "none")`},
+			input:          doc,
+			expectedOutput: "\"on\"\n\"none\"\n\"off\"\n\"none\"\n",
+		},
+		{
+			name:           "reduce and variables",
+			args:           []string{"-c", "--arg", "unit", "ms", `reduce .items[] as $i (0; . + $i.id) | "\(.)\($unit)"`},
+			input:          doc,
+			expectedOutput: "\"3ms\"\n",
+		},
+		{
+			name:           "assignment and del",
+			args:           []string{"-c", `del(.items) | .meta.c = 3 | .name |= ascii_upcase`},
+			input:          doc,
+			expectedOutput: "{\"name\":\"APP\",\"meta\":{\"b\":2,\"a\":1,\"c\":3}}\n",
+		},
+		{
+			name:           "regex functions",
+			args:           []string{"-c", `.name | test("^A"; "i"), sub("p+"; "P"), gsub("p"; "-")`},
+			input:          doc,
+			expectedOutput: "true\n\"aP\"\n\"a--\"\n",
+		},
+		{
+			name:           "try and catch",
+			args:           []string{"-c", `(try error("boom") catch .), (.name | tonumber?), "done"`},
+			input:          doc,
+			expectedOutput: "\"boom\"\n\"done\"\n",
+		},
+		{
+			name:           "numbers",
+			args:           []string{"-c", "1.5, 3.0, 1e100, -0.25, (10 / 4)"},
+			input:          "null",
+			expectedOutput: "1.5\n3\n1e+100\n-0.25\n2.5\n",
+		},
+		{
+			name:           "join output",
+			args:           []string{"-j", ".[]"},
+			input:          `["a", 1, "b"]`,
+			expectedOutput: "a1b",
+		},
+		{
+			name:           "error after earlier output",
+			args:           []string{".[]"},
+			input:          "[1] 2",
+			expectedOutput: "1\n",
+			expectedError:  "jq: error: Cannot iterate over number (2)",
+		},
+		{
+			name:          "index error",
+			args:          []string{".name.x"},
+			input:         doc,
+			expectedError: `Cannot index string with "x"`,
+		},
+		{
+			name:          "syntax error",
+			args:          []string{"{a"},
+			input:         "{}",
+			expectedError: "jq: syntax error: expected '}', got end of filter at position 2",
+		},
+		{
+			name:          "invalid input",
+			args:          []string{"."},
+			input:         "{",
+			expectedError: "jq: invalid JSON input",
+		},
+		{
+			name:          "unsupported def",
+			args:          []string{"def f: .; f"},
+			input:         "{}",
+			expectedError: "def is not supported",
+		},
+		{
+			name:          "file operand",
+			args:          []string{".", "data.json"},
+			expectedError: "file operands are not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output strings.Builder
+			err := Jq(tt.args, strings.NewReader(tt.input), &output)
+
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedError, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != tt.expectedOutput {
+				t.Errorf("expected output %q, got %q", tt.expectedOutput, output.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,2282 @@
+package builtin
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// jqEnv binds variables, innermost first
+type jqEnv struct {
+	name   string
+	value  interface{}
+	parent *jqEnv
+}
+
+func (e *jqEnv) bind(name string, value interface{}) *jqEnv {
+	return &jqEnv{name: name, value: value, parent: e}
+}
+
+func (e *jqEnv) lookup(name string) (interface{}, bool) {
+	for ; e != nil; e = e.parent {
+		if e.name == name {
+			return e.value, true
+		}
+	}
+	return nil, false
+}
+
+// jqError is an error raised while running a filter; try and catch see its
+// value
+type jqError struct {
+	value interface{}
+}
+
+func (e *jqError) Error() string {
+	if s, ok := e.value.(string); ok {
+		return s
+	}
+	return jqEncode(e.value, false, false) + " (not a string)"
+}
+
+func jqErrorf(format string, args ...interface{}) error {
+	return &jqError{value: fmt.Sprintf(format, args...)}
+}
+
+// jqInterp runs a parsed filter
+type jqInterp struct {
+	// next returns the next input value, for input and inputs
+	next func() (interface{}, bool, error)
+}
+
+// jqTypeName returns the jq type of a value
+func jqTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// jqDescribe names a value in errors, as jq does: its type and a short form
+func jqDescribe(v interface{}) string {
+	text := jqEncode(v, false, false)
+	if len(text) > 30 {
+		text = text[:27] + "..."
+	}
+	return fmt.Sprintf("%s (%s)", jqTypeName(v), text)
+}
+
+func jqTruthy(v interface{}) bool {
+	b, isBool := v.(bool)
+	return v != nil && (!isBool || b)
+}
+
+// jqTypeOrder orders types for sorting: null, false, true, numbers, strings,
+// arrays, objects
+func jqTypeOrder(v interface{}) int {
+	switch t := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if t {
+			return 2
+		}
+		return 1
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	}
+	return 6
+}
+
+// jqCompare orders two values as jq's sort does
+func jqCompare(a, b interface{}) int {
+	if ta, tb := jqTypeOrder(a), jqTypeOrder(b); ta != tb {
+		if ta < tb {
+			return -1
+		}
+		return 1
+	}
+	switch x := a.(type) {
+	case float64:
+		y := b.(float64)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(x, b.(string))
+	case []interface{}:
+		y := b.([]interface{})
+		for i := 0; i < len(x) && i < len(y); i++ {
+			if c := jqCompare(x[i], y[i]); c != 0 {
+				return c
+			}
+		}
+		return len(x) - len(y)
+	case *jqObject:
+		y := b.(*jqObject)
+		xk, yk := x.sortedKeys(), y.sortedKeys()
+		for i := 0; i < len(xk) && i < len(yk); i++ {
+			if c := strings.Compare(xk[i], yk[i]); c != 0 {
+				return c
+			}
+		}
+		if len(xk) != len(yk) {
+			return len(xk) - len(yk)
+		}
+		for _, k := range xk {
+			if c := jqCompare(x.values[k], y.values[k]); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
+
+// eval runs a filter on an input, returning its outputs. Outputs produced
+// before an error are returned with it.
+func (q *jqInterp) eval(node jqNode, in interface{}, env *jqEnv) ([]interface{}, error) {
+	switch n := node.(type) {
+	case *jqIdentity:
+		return []interface{}{in}, nil
+	case *jqRecurse:
+		var out []interface{}
+		jqWalk(in, func(v interface{}) { out = append(out, v) })
+		return out, nil
+	case *jqLiteral:
+		return []interface{}{n.value}, nil
+	case *jqVar:
+		if n.name == "__loc__" {
+			return nil, jqErrorf("$__loc__ is not supported")
+		}
+		v, ok := env.lookup(n.name)
+		if !ok {
+			return nil, jqErrorf("$%s is not defined", n.name)
+		}
+		return []interface{}{v}, nil
+	case *jqIndex:
+		return q.product(in, env, []jqNode{n.key, n.target}, func(vals []interface{}) ([]interface{}, error) {
+			v, err := jqIndexValue(vals[1], vals[0])
+			return []interface{}{v}, err
+		})
+	case *jqSlice:
+		from, to := n.from, n.to
+		if from == nil {
+			from = &jqLiteral{}
+		}
+		if to == nil {
+			to = &jqLiteral{}
+		}
+		return q.product(in, env, []jqNode{to, from, n.target}, func(vals []interface{}) ([]interface{}, error) {
+			v, err := jqSliceValue(vals[2], vals[1], vals[0])
+			return []interface{}{v}, err
+		})
+	case *jqIterate:
+		targets, err := q.eval(n.target, in, env)
+		var out []interface{}
+		for _, target := range targets {
+			values, iterErr := jqValues(target)
+			out = append(out, values...)
+			if iterErr != nil {
+				return out, iterErr
+			}
+		}
+		return out, err
+	case *jqTry:
+		out, err := q.eval(n.body, in, env)
+		if err == nil {
+			return out, nil
+		}
+		jerr, ok := err.(*jqError)
+		if !ok {
+			return out, err
+		}
+		if n.catch == nil {
+			return out, nil
+		}
+		caught, err := q.eval(n.catch, jerr.value, env)
+		return append(out, caught...), err
+	case *jqString:
+		return q.evalString(n, in, env)
+	case *jqFormat:
+		s, err := jqApplyFormat(n.name, in)
+		return []interface{}{s}, err
+	case *jqArray:
+		arr := []interface{}{}
+		if n.body != nil {
+			out, err := q.eval(n.body, in, env)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, out...)
+		}
+		return []interface{}{arr}, nil
+	case *jqObjNode:
+		return q.evalObject(n.entries, in, env, newJqObject())
+	case *jqCall:
+		return q.call(n, in, env)
+	case *jqPipe:
+		lefts, err := q.eval(n.left, in, env)
+		var out []interface{}
+		for _, left := range lefts {
+			rights, rightErr := q.eval(n.right, left, env)
+			out = append(out, rights...)
+			if rightErr != nil {
+				return out, rightErr
+			}
+		}
+		return out, err
+	case *jqComma:
+		left, err := q.eval(n.left, in, env)
+		if err != nil {
+			return left, err
+		}
+		right, err := q.eval(n.right, in, env)
+		return append(left, right...), err
+	case *jqNeg:
+		values, err := q.eval(n.operand, in, env)
+		out := make([]interface{}, 0, len(values))
+		for _, v := range values {
+			f, ok := v.(float64)
+			if !ok {
+				return out, jqErrorf("%s cannot be negated", jqDescribe(v))
+			}
+			out = append(out, -f)
+		}
+		return out, err
+	case *jqBinary:
+		return q.evalBinary(n, in, env)
+	case *jqAssign:
+		return q.evalAssign(n, in, env)
+	case *jqIf:
+		conds, err := q.eval(n.cond, in, env)
+		var out []interface{}
+		for _, cond := range conds {
+			branch := n.then
+			if !jqTruthy(cond) {
+				branch = n.otherwise
+			}
+			if branch == nil {
+				out = append(out, in)
+				continue
+			}
+			values, branchErr := q.eval(branch, in, env)
+			out = append(out, values...)
+			if branchErr != nil {
+				return out, branchErr
+			}
+		}
+		return out, err
+	case *jqReduce:
+		return q.evalReduce(n, in, env)
+	case *jqAs:
+		sources, err := q.eval(n.source, in, env)
+		var out []interface{}
+		for _, source := range sources {
+			values, bodyErr := q.eval(n.body, in, env.bind(n.name, source))
+			out = append(out, values...)
+			if bodyErr != nil {
+				return out, bodyErr
+			}
+		}
+		return out, err
+	}
+	return nil, jqErrorf("unknown filter %T", node)
+}
+
+// product evaluates filters on the same input and calls f with each
+// combination of their outputs, the last filter varying slowest
+func (q *jqInterp) product(in interface{}, env *jqEnv, nodes []jqNode, f func([]interface{}) ([]interface{}, error)) ([]interface{}, error) {
+	vals := make([]interface{}, len(nodes))
+	var out []interface{}
+	var walk func(i int) error
+	walk = func(i int) error {
+		if i < 0 {
+			values, err := f(append([]interface{}(nil), vals...))
+			if err != nil {
+				return err
+			}
+			out = append(out, values...)
+			return nil
+		}
+		values, err := q.eval(nodes[i], in, env)
+		if err != nil {
+			return err
+		}
+		for _, v := range values {
+			vals[i] = v
+			if err := walk(i - 1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := walk(len(nodes) - 1)
+	return out, err
+}
+
+// jqWalk calls f on v and everything inside it, parents first
+func jqWalk(v interface{}, f func(interface{})) {
+	f(v)
+	switch t := v.(type) {
+	case []interface{}:
+		for _, elem := range t {
+			jqWalk(elem, f)
+		}
+	case *jqObject:
+		for _, k := range t.keys {
+			jqWalk(t.values[k], f)
+		}
+	}
+}
+
+// jqValues returns the elements of an array or the values of an object
+func jqValues(v interface{}) ([]interface{}, error) {
+	switch t := v.(type) {
+	case []interface{}:
+		return t, nil
+	case *jqObject:
+		out := make([]interface{}, len(t.keys))
+		for i, k := range t.keys {
+			out[i] = t.values[k]
+		}
+		return out, nil
+	}
+	return nil, jqErrorf("Cannot iterate over %s", jqDescribe(v))
+}
+
+// jqIndexValue indexes an object by a string or an array by a number; null
+// indexes to null
+func jqIndexValue(v, key interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case nil:
+		switch key.(type) {
+		case string, float64, nil:
+			return nil, nil
+		}
+	case *jqObject:
+		if k, ok := key.(string); ok {
+			value, _ := t.get(k)
+			return value, nil
+		}
+	case []interface{}:
+		if k, ok := key.(float64); ok {
+			i := int(math.Floor(k))
+			if i < 0 {
+				i += len(t)
+			}
+			if i < 0 || i >= len(t) {
+				return nil, nil
+			}
+			return t[i], nil
+		}
+	}
+	if k, ok := key.(string); ok {
+		return nil, jqErrorf("Cannot index %s with %q", jqTypeName(v), k)
+	}
+	return nil, jqErrorf("Cannot index %s with %s", jqTypeName(v), jqTypeName(key))
+}
+
+// jqSliceValue slices an array or a string, by codepoints; null bounds are
+// the ends
+func jqSliceValue(v, from, to interface{}) (interface{}, error) {
+	length := 0
+	switch t := v.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		length = len(t)
+	case string:
+		length = utf8.RuneCountInString(t)
+	default:
+		return nil, jqErrorf("Cannot index %s with object", jqTypeName(v))
+	}
+	bound := func(b interface{}, def int) (int, error) {
+		switch n := b.(type) {
+		case nil:
+			return def, nil
+		case float64:
+			i := int(math.Floor(n))
+			if i < 0 {
+				i += length
+			}
+			return max(0, min(i, length)), nil
+		}
+		return 0, jqErrorf("Start and end indices of an array slice must be numbers")
+	}
+	start, err := bound(from, 0)
+	if err != nil {
+		return nil, err
+	}
+	end, err := bound(to, length)
+	if err != nil {
+		return nil, err
+	}
+	end = max(start, end)
+	if s, ok := v.(string); ok {
+		return string([]rune(s)[start:end]), nil
+	}
+	return append([]interface{}{}, v.([]interface{})[start:end]...), nil
+}
+
+// evalString builds an interpolated string, formatting the interpolated
+// values with the string's format
+func (q *jqInterp) evalString(n *jqString, in interface{}, env *jqEnv) ([]interface{}, error) {
+	var nodes []jqNode
+	for _, part := range n.parts {
+		if part.expr != nil {
+			nodes = append(nodes, part.expr)
+		}
+	}
+	// product varies the last node slowest; reverse so the first does
+	reversed := make([]jqNode, len(nodes))
+	for i, node := range nodes {
+		reversed[len(nodes)-1-i] = node
+	}
+	format := n.format
+	if format == "" {
+		format = "text"
+	}
+	return q.product(in, env, reversed, func(vals []interface{}) ([]interface{}, error) {
+		var b strings.Builder
+		next := len(vals) - 1
+		for _, part := range n.parts {
+			if part.expr == nil {
+				b.WriteString(part.text)
+				continue
+			}
+			s, err := jqApplyFormat(format, vals[next])
+			if err != nil {
+				return nil, err
+			}
+			b.WriteString(s)
+			next--
+		}
+		return []interface{}{b.String()}, nil
+	})
+}
+
+// evalObject builds objects from entries, one for each combination of the
+// keys' and values' outputs
+func (q *jqInterp) evalObject(entries []jqEntry, in interface{}, env *jqEnv, obj *jqObject) ([]interface{}, error) {
+	if len(entries) == 0 {
+		return []interface{}{obj}, nil
+	}
+	keys, err := q.eval(entries[0].key, in, env)
+	if err != nil {
+		return nil, err
+	}
+	var out []interface{}
+	for _, key := range keys {
+		k, ok := key.(string)
+		if !ok {
+			return out, jqErrorf("Object keys must be strings")
+		}
+		values, err := q.eval(entries[0].value, in, env)
+		if err != nil {
+			return out, err
+		}
+		for _, v := range values {
+			next := obj.clone()
+			next.set(k, v)
+			objs, err := q.evalObject(entries[1:], in, env, next)
+			out = append(out, objs...)
+			if err != nil {
+				return out, err
+			}
+		}
+	}
+	return out, nil
+}
+
+func (q *jqInterp) evalBinary(n *jqBinary, in interface{}, env *jqEnv) ([]interface{}, error) {
+	switch n.op {
+	case "and", "or":
+		lefts, err := q.eval(n.left, in, env)
+		var out []interface{}
+		for _, left := range lefts {
+			if jqTruthy(left) == (n.op == "or") {
+				out = append(out, n.op == "or")
+				continue
+			}
+			rights, rightErr := q.eval(n.right, in, env)
+			for _, right := range rights {
+				out = append(out, jqTruthy(right))
+			}
+			if rightErr != nil {
+				return out, rightErr
+			}
+		}
+		return out, err
+	case "//":
+		lefts, err := q.eval(n.left, in, env)
+		var out []interface{}
+		for _, left := range lefts {
+			if jqTruthy(left) {
+				out = append(out, left)
+			}
+		}
+		if len(out) > 0 {
+			return out, nil
+		}
+		if _, ok := err.(*jqError); err != nil && !ok {
+			return nil, err
+		}
+		return q.eval(n.right, in, env)
+	}
+	return q.product(in, env, []jqNode{n.left, n.right}, func(vals []interface{}) ([]interface{}, error) {
+		v, err := jqBinaryOp(n.op, vals[0], vals[1])
+		return []interface{}{v}, err
+	})
+}
+
+// jqBinaryOp applies an arithmetic or comparison operator
+func jqBinaryOp(op string, a, b interface{}) (interface{}, error) {
+	switch op {
+	case "==":
+		return jqCompare(a, b) == 0, nil
+	case "!=":
+		return jqCompare(a, b) != 0, nil
+	case "<":
+		return jqCompare(a, b) < 0, nil
+	case "<=":
+		return jqCompare(a, b) <= 0, nil
+	case ">":
+		return jqCompare(a, b) > 0, nil
+	case ">=":
+		return jqCompare(a, b) >= 0, nil
+	}
+
+	x, xNum := a.(float64)
+	y, yNum := b.(float64)
+	switch op {
+	case "+":
+		switch {
+		case a == nil:
+			return b, nil
+		case b == nil:
+			return a, nil
+		case xNum && yNum:
+			return x + y, nil
+		}
+		switch l := a.(type) {
+		case string:
+			if r, ok := b.(string); ok {
+				return l + r, nil
+			}
+		case []interface{}:
+			if r, ok := b.([]interface{}); ok {
+				return append(append([]interface{}{}, l...), r...), nil
+			}
+		case *jqObject:
+			if r, ok := b.(*jqObject); ok {
+				merged := l.clone()
+				for _, k := range r.keys {
+					merged.set(k, r.values[k])
+				}
+				return merged, nil
+			}
+		}
+		return nil, jqErrorf("%s and %s cannot be added", jqDescribe(a), jqDescribe(b))
+	case "-":
+		if xNum && yNum {
+			return x - y, nil
+		}
+		l, lok := a.([]interface{})
+		r, rok := b.([]interface{})
+		if lok && rok {
+			out := []interface{}{}
+			for _, elem := range l {
+				keep := true
+				for _, remove := range r {
+					if jqCompare(elem, remove) == 0 {
+						keep = false
+						break
+					}
+				}
+				if keep {
+					out = append(out, elem)
+				}
+			}
+			return out, nil
+		}
+		return nil, jqErrorf("%s and %s cannot be subtracted", jqDescribe(a), jqDescribe(b))
+	case "*":
+		if xNum && yNum {
+			return x * y, nil
+		}
+		if s, ok := a.(string); ok && yNum {
+			if y <= 0 {
+				return nil, nil
+			}
+			return strings.Repeat(s, int(math.Ceil(y))), nil
+		}
+		if s, ok := b.(string); ok && xNum {
+			return jqBinaryOp("*", s, x)
+		}
+		l, lok := a.(*jqObject)
+		r, rok := b.(*jqObject)
+		if lok && rok {
+			return jqDeepMerge(l, r), nil
+		}
+		return nil, jqErrorf("%s and %s cannot be multiplied", jqDescribe(a), jqDescribe(b))
+	case "/":
+		if xNum && yNum {
+			if y == 0 {
+				return nil, jqErrorf("%s and %s cannot be divided because the divisor is zero", jqDescribe(a), jqDescribe(b))
+			}
+			return x / y, nil
+		}
+		l, lok := a.(string)
+		r, rok := b.(string)
+		if lok && rok {
+			return jqSplit(l, r), nil
+		}
+		return nil, jqErrorf("%s and %s cannot be divided", jqDescribe(a), jqDescribe(b))
+	case "%":
+		if xNum && yNum {
+			if int64(y) == 0 {
+				return nil, jqErrorf("%s and %s cannot be divided because the divisor is zero", jqDescribe(a), jqDescribe(b))
+			}
+			return float64(int64(x) % int64(y)), nil
+		}
+		return nil, jqErrorf("%s and %s cannot be divided", jqDescribe(a), jqDescribe(b))
+	}
+	return nil, jqErrorf("unknown operator %s", op)
+}
+
+// jqDeepMerge merges r into l, merging the objects both have under a key
+func jqDeepMerge(l, r *jqObject) *jqObject {
+	merged := l.clone()
+	for _, k := range r.keys {
+		lv, lok := merged.values[k].(*jqObject)
+		rv, rok := r.values[k].(*jqObject)
+		if lok && rok {
+			merged.set(k, jqDeepMerge(lv, rv))
+		} else {
+			merged.set(k, r.values[k])
+		}
+	}
+	return merged
+}
+
+// jqSplit splits a string by a literal separator
+func jqSplit(s, sep string) []interface{} {
+	out := []interface{}{}
+	if s == "" {
+		return out
+	}
+	for _, part := range strings.Split(s, sep) {
+		out = append(out, part)
+	}
+	return out
+}
+
+func (q *jqInterp) evalReduce(n *jqReduce, in interface{}, env *jqEnv) ([]interface{}, error) {
+	inits, err := q.eval(n.init, in, env)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := q.eval(n.source, in, env)
+	if err != nil {
+		return nil, err
+	}
+	var out []interface{}
+	for _, state := range inits {
+		for _, source := range sources {
+			scope := env.bind(n.name, source)
+			states, err := q.eval(n.update, state, scope)
+			if err != nil {
+				return out, err
+			}
+			if !n.foreach {
+				state = nil
+				if len(states) > 0 {
+					state = states[len(states)-1]
+				}
+				continue
+			}
+			for _, s := range states {
+				state = s
+				if n.extract == nil {
+					out = append(out, s)
+					continue
+				}
+				extracted, err := q.eval(n.extract, s, scope)
+				out = append(out, extracted...)
+				if err != nil {
+					return out, err
+				}
+			}
+		}
+		if !n.foreach {
+			out = append(out, state)
+		}
+	}
+	return out, nil
+}
+
+// jqPathValue is a path into the input and the value found there
+type jqPathValue struct {
+	path  []interface{}
+	value interface{}
+}
+
+// paths evaluates a path expression, such as .a[0] or .[] | select(.x),
+// returning the paths it refers to within in
+func (q *jqInterp) paths(node jqNode, in interface{}, env *jqEnv) ([]jqPathValue, error) {
+	switch n := node.(type) {
+	case *jqIdentity:
+		return []jqPathValue{{path: []interface{}{}, value: in}}, nil
+	case *jqRecurse:
+		var out []jqPathValue
+		var walk func(path []interface{}, v interface{})
+		walk = func(path []interface{}, v interface{}) {
+			out = append(out, jqPathValue{path: path, value: v})
+			switch t := v.(type) {
+			case []interface{}:
+				for i, elem := range t {
+					walk(jqAppendPath(path, float64(i)), elem)
+				}
+			case *jqObject:
+				for _, k := range t.keys {
+					walk(jqAppendPath(path, k), t.values[k])
+				}
+			}
+		}
+		walk([]interface{}{}, in)
+		return out, nil
+	case *jqIndex, *jqIterate:
+		var target jqNode
+		if index, ok := n.(*jqIndex); ok {
+			target = index.target
+		} else {
+			target = n.(*jqIterate).target
+		}
+		targets, err := q.paths(target, in, env)
+		if err != nil {
+			return nil, err
+		}
+		var out []jqPathValue
+		for _, t := range targets {
+			if index, ok := n.(*jqIndex); ok {
+				keys, err := q.eval(index.key, in, env)
+				if err != nil {
+					return out, err
+				}
+				for _, key := range keys {
+					v, err := jqIndexValue(t.value, key)
+					if err != nil {
+						return out, err
+					}
+					out = append(out, jqPathValue{path: jqAppendPath(t.path, key), value: v})
+				}
+				continue
+			}
+			switch v := t.value.(type) {
+			case nil:
+			case []interface{}:
+				for i, elem := range v {
+					out = append(out, jqPathValue{path: jqAppendPath(t.path, float64(i)), value: elem})
+				}
+			case *jqObject:
+				for _, k := range v.keys {
+					out = append(out, jqPathValue{path: jqAppendPath(t.path, k), value: v.values[k]})
+				}
+			default:
+				return out, jqErrorf("Cannot iterate over %s", jqDescribe(v))
+			}
+		}
+		return out, nil
+	case *jqPipe:
+		lefts, err := q.paths(n.left, in, env)
+		if err != nil {
+			return nil, err
+		}
+		var out []jqPathValue
+		for _, left := range lefts {
+			rights, err := q.paths(n.right, left.value, env)
+			if err != nil {
+				return out, err
+			}
+			for _, right := range rights {
+				out = append(out, jqPathValue{path: append(append([]interface{}{}, left.path...), right.path...), value: right.value})
+			}
+		}
+		return out, nil
+	case *jqComma:
+		left, err := q.paths(n.left, in, env)
+		if err != nil {
+			return left, err
+		}
+		right, err := q.paths(n.right, in, env)
+		return append(left, right...), err
+	case *jqTry:
+		out, err := q.paths(n.body, in, env)
+		if _, ok := err.(*jqError); ok {
+			return out, nil
+		}
+		return out, err
+	case *jqIf:
+		conds, err := q.eval(n.cond, in, env)
+		if err != nil {
+			return nil, err
+		}
+		var out []jqPathValue
+		for _, cond := range conds {
+			branch := n.then
+			if !jqTruthy(cond) {
+				branch = n.otherwise
+			}
+			if branch == nil {
+				branch = &jqIdentity{}
+			}
+			paths, err := q.paths(branch, in, env)
+			out = append(out, paths...)
+			if err != nil {
+				return out, err
+			}
+		}
+		return out, nil
+	case *jqAs:
+		sources, err := q.eval(n.source, in, env)
+		if err != nil {
+			return nil, err
+		}
+		var out []jqPathValue
+		for _, source := range sources {
+			paths, err := q.paths(n.body, in, env.bind(n.name, source))
+			out = append(out, paths...)
+			if err != nil {
+				return out, err
+			}
+		}
+		return out, nil
+	case *jqCall:
+		switch jqArity(n) {
+		case "empty/0":
+			return nil, nil
+		case "select/1":
+			conds, err := q.eval(n.args[0], in, env)
+			if err != nil {
+				return nil, err
+			}
+			var out []jqPathValue
+			for _, cond := range conds {
+				if jqTruthy(cond) {
+					out = append(out, jqPathValue{path: []interface{}{}, value: in})
+				}
+			}
+			return out, nil
+		case "recurse/0":
+			return q.paths(&jqRecurse{}, in, env)
+		case "first/1", "last/1":
+			paths, err := q.paths(n.args[0], in, env)
+			if err != nil || len(paths) == 0 {
+				return nil, err
+			}
+			if n.name == "first" {
+				return paths[:1], nil
+			}
+			return paths[len(paths)-1:], nil
+		case "getpath/1":
+			paths, err := q.eval(n.args[0], in, env)
+			if err != nil {
+				return nil, err
+			}
+			var out []jqPathValue
+			for _, p := range paths {
+				path, ok := p.([]interface{})
+				if !ok {
+					return out, jqErrorf("Path must be specified as an array")
+				}
+				v, err := jqGetPath(in, path)
+				if err != nil {
+					return out, err
+				}
+				out = append(out, jqPathValue{path: path, value: v})
+			}
+			return out, nil
+		}
+	}
+	values, err := q.eval(node, in, env)
+	if err == nil && len(values) > 0 {
+		err = jqErrorf("Invalid path expression with result %s", jqEncode(values[0], false, false))
+	}
+	return nil, err
+}
+
+func jqAppendPath(path []interface{}, key interface{}) []interface{} {
+	return append(append(make([]interface{}, 0, len(path)+1), path...), key)
+}
+
+// jqGetPath returns the value at a path; missing parts are null
+func jqGetPath(v interface{}, path []interface{}) (interface{}, error) {
+	for _, key := range path {
+		if v == nil {
+			return nil, nil
+		}
+		var err error
+		if v, err = jqIndexValue(v, key); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// jqSetPath returns a copy of root with the value at path set to v
+func jqSetPath(root interface{}, path []interface{}, v interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	switch key := path[0].(type) {
+	case string:
+		var obj *jqObject
+		switch t := root.(type) {
+		case nil:
+			obj = newJqObject()
+		case *jqObject:
+			obj = t.clone()
+		default:
+			return nil, jqErrorf("Cannot index %s with %q", jqTypeName(root), key)
+		}
+		child, _ := obj.get(key)
+		child, err := jqSetPath(child, path[1:], v)
+		if err != nil {
+			return nil, err
+		}
+		obj.set(key, child)
+		return obj, nil
+	case float64:
+		var arr []interface{}
+		switch t := root.(type) {
+		case nil:
+		case []interface{}:
+			arr = append([]interface{}{}, t...)
+		default:
+			return nil, jqErrorf("Cannot index %s with number", jqTypeName(root))
+		}
+		i := int(key)
+		if i < 0 {
+			i += len(arr)
+			if i < 0 {
+				return nil, jqErrorf("Out of bounds negative array index")
+			}
+		}
+		for len(arr) <= i {
+			arr = append(arr, nil)
+		}
+		child, err := jqSetPath(arr[i], path[1:], v)
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = child
+		return arr, nil
+	}
+	return nil, jqErrorf("Invalid path component %s", jqDescribe(path[0]))
+}
+
+// jqDeletePaths returns a copy of root without the values at paths
+func jqDeletePaths(root interface{}, paths [][]interface{}) (interface{}, error) {
+	// Delete the last paths first, so array indices stay valid
+	sorted := append([][]interface{}(nil), paths...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return jqCompare(sorted[i], sorted[j]) > 0
+	})
+	var err error
+	for _, path := range sorted {
+		if root, err = jqDeletePath(root, path); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+func jqDeletePath(root interface{}, path []interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+	if root == nil {
+		return nil, nil
+	}
+	if len(path) > 1 {
+		child, err := jqIndexValue(root, path[0])
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			return root, nil
+		}
+		child, err = jqDeletePath(child, path[1:])
+		if err != nil {
+			return nil, err
+		}
+		return jqSetPath(root, path[:1], child)
+	}
+	switch t := root.(type) {
+	case *jqObject:
+		key, ok := path[0].(string)
+		if !ok {
+			return nil, jqErrorf("Cannot delete field at object index of %s", jqTypeName(path[0]))
+		}
+		obj := t.clone()
+		obj.delete(key)
+		return obj, nil
+	case []interface{}:
+		key, ok := path[0].(float64)
+		if !ok {
+			return nil, jqErrorf("Cannot delete field at array index of %s", jqTypeName(path[0]))
+		}
+		i := int(key)
+		if i < 0 {
+			i += len(t)
+		}
+		if i < 0 || i >= len(t) {
+			return t, nil
+		}
+		return append(append([]interface{}{}, t[:i]...), t[i+1:]...), nil
+	}
+	return nil, jqErrorf("Cannot delete field of %s", jqTypeName(root))
+}
+
+func (q *jqInterp) evalAssign(n *jqAssign, in interface{}, env *jqEnv) ([]interface{}, error) {
+	paths, err := q.paths(n.left, in, env)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.op == "|=" {
+		result := in
+		var deleted [][]interface{}
+		for _, p := range paths {
+			old, err := jqGetPath(result, p.path)
+			if err != nil {
+				return nil, err
+			}
+			values, err := q.eval(n.right, old, env)
+			if err != nil {
+				return nil, err
+			}
+			if len(values) == 0 {
+				deleted = append(deleted, p.path)
+				continue
+			}
+			if result, err = jqSetPath(result, p.path, values[0]); err != nil {
+				return nil, err
+			}
+		}
+		if len(deleted) > 0 {
+			if result, err = jqDeletePaths(result, deleted); err != nil {
+				return nil, err
+			}
+		}
+		return []interface{}{result}, nil
+	}
+
+	rights, err := q.eval(n.right, in, env)
+	if err != nil {
+		return nil, err
+	}
+	var out []interface{}
+	for _, right := range rights {
+		result := in
+		for _, p := range paths {
+			v := right
+			if n.op != "=" {
+				old, err := jqGetPath(result, p.path)
+				if err != nil {
+					return out, err
+				}
+				if op := strings.TrimSuffix(n.op, "="); op == "//" {
+					if jqTruthy(old) {
+						v = old
+					}
+				} else if v, err = jqBinaryOp(op, old, right); err != nil {
+					return out, err
+				}
+			}
+			if result, err = jqSetPath(result, p.path, v); err != nil {
+				return out, err
+			}
+		}
+		out = append(out, result)
+	}
+	return out, nil
+}
+
+// jqArity names a call by its name and argument count, as jq does: map/1
+func jqArity(n *jqCall) string {
+	return n.name + "/" + strconv.Itoa(len(n.args))
+}
+
+// call runs a builtin
+func (q *jqInterp) call(n *jqCall, in interface{}, env *jqEnv) ([]interface{}, error) {
+	one := func(v interface{}, err error) ([]interface{}, error) {
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{v}, nil
+	}
+	// args evaluates the arguments on the input and calls f with each
+	// combination of their values
+	args := func(f func(vals []interface{}) (interface{}, error)) ([]interface{}, error) {
+		reversed := make([]jqNode, len(n.args))
+		for i, arg := range n.args {
+			reversed[len(n.args)-1-i] = arg
+		}
+		return q.product(in, env, reversed, func(vals []interface{}) ([]interface{}, error) {
+			for i, j := 0, len(vals)-1; i < j; i, j = i+1, j-1 {
+				vals[i], vals[j] = vals[j], vals[i]
+			}
+			return one(f(vals))
+		})
+	}
+
+	switch jqArity(n) {
+	case "empty/0":
+		return nil, nil
+	case "error/0":
+		return nil, &jqError{value: in}
+	case "error/1":
+		values, err := q.eval(n.args[0], in, env)
+		if err != nil || len(values) == 0 {
+			return nil, err
+		}
+		return nil, &jqError{value: values[0]}
+	case "not/0":
+		return []interface{}{!jqTruthy(in)}, nil
+	case "length/0":
+		return one(jqLength(in))
+	case "utf8bytelength/0":
+		s, ok := in.(string)
+		if !ok {
+			return nil, jqErrorf("%s only strings have UTF-8 byte length", jqDescribe(in))
+		}
+		return []interface{}{float64(len(s))}, nil
+	case "type/0":
+		return []interface{}{jqTypeName(in)}, nil
+	case "keys/0", "keys_unsorted/0":
+		switch t := in.(type) {
+		case *jqObject:
+			keys := t.keys
+			if n.name == "keys" {
+				keys = t.sortedKeys()
+			}
+			out := make([]interface{}, len(keys))
+			for i, k := range keys {
+				out[i] = k
+			}
+			return []interface{}{out}, nil
+		case []interface{}:
+			out := make([]interface{}, len(t))
+			for i := range t {
+				out[i] = float64(i)
+			}
+			return []interface{}{out}, nil
+		}
+		return nil, jqErrorf("%s has no keys", jqDescribe(in))
+	case "values/0":
+		if in == nil {
+			return nil, nil
+		}
+		return []interface{}{in}, nil
+	case "has/1":
+		return args(func(vals []interface{}) (interface{}, error) {
+			return jqHas(in, vals[0])
+		})
+	case "in/1":
+		return args(func(vals []interface{}) (interface{}, error) {
+			return jqHas(vals[0], in)
+		})
+	case "contains/1":
+		return args(func(vals []interface{}) (interface{}, error) {
+			return jqContains(in, vals[0])
+		})
+	case "inside/1":
+		return args(func(vals []interface{}) (interface{}, error) {
+			return jqContains(vals[0], in)
+		})
+	case "map/1":
+		values, err := jqValues(in)
+		if err != nil {
+			return nil, err
+		}
+		out := []interface{}{}
+		for _, v := range values {
+			mapped, err := q.eval(n.args[0], v, env)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, mapped...)
+		}
+		return []interface{}{out}, nil
+	case "map_values/1":
+		return q.evalAssign(&jqAssign{op: "|=", left: &jqIterate{target: &jqIdentity{}}, right: n.args[0]}, in, env)
+	case "with_entries/1":
+		entries, err := jqToEntries(in)
+		if err != nil {
+			return nil, err
+		}
+		var mapped []interface{}
+		for _, entry := range entries {
+			values, err := q.eval(n.args[0], entry, env)
+			if err != nil {
+				return nil, err
+			}
+			mapped = append(mapped, values...)
+		}
+		return one(jqFromEntries(mapped))
+	case "select/1":
+		conds, err := q.eval(n.args[0], in, env)
+		var out []interface{}
+		for _, cond := range conds {
+			if jqTruthy(cond) {
+				out = append(out, in)
+			}
+		}
+		return out, err
+	case "recurse/0":
+		return q.eval(&jqRecurse{}, in, env)
+	case "recurse/1":
+		var out []interface{}
+		var walk func(v interface{}, depth int) error
+		walk = func(v interface{}, depth int) error {
+			if depth > 10000 {
+				return jqErrorf("recurse: too deep")
+			}
+			out = append(out, v)
+			children, err := q.eval(n.args[0], v, env)
+			if err != nil {
+				return err
+			}
+			for _, child := range children {
+				if err := walk(child, depth+1); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return out, walk(in, 0)
+	case "add/0":
+		values, err := jqValues(in)
+		if err != nil {
+			return nil, err
+		}
+		var sum interface{}
+		for _, v := range values {
+			if sum, err = jqBinaryOp("+", sum, v); err != nil {
+				return nil, err
+			}
+		}
+		return []interface{}{sum}, nil
+	case "any/0", "all/0", "any/1", "all/1":
+		values, err := jqValues(in)
+		if err != nil {
+			return nil, err
+		}
+		want := n.name == "any"
+		for _, v := range values {
+			truth := jqTruthy(v)
+			if len(n.args) == 1 {
+				conds, err := q.eval(n.args[0], v, env)
+				if err != nil {
+					return nil, err
+				}
+				truth = false
+				for _, cond := range conds {
+					truth = truth || jqTruthy(cond)
+				}
+			}
+			if truth == want {
+				return []interface{}{want}, nil
+			}
+		}
+		return []interface{}{!want}, nil
+	case "range/1", "range/2":
+		return q.callRange(n, in, env)
+	case "first/0":
+		return one(jqIndexValue(in, 0.0))
+	case "last/0":
+		return one(jqIndexValue(in, -1.0))
+	case "first/1", "last/1":
+		values, err := q.eval(n.args[0], in, env)
+		if len(values) == 0 {
+			return nil, err
+		}
+		if n.name == "first" {
+			return values[:1], nil
+		}
+		return values[len(values)-1:], err
+	case "limit/2":
+		limits, err := q.eval(n.args[0], in, env)
+		if err != nil {
+			return nil, err
+		}
+		var out []interface{}
+		for _, limit := range limits {
+			count, ok := limit.(float64)
+			if !ok {
+				return out, jqErrorf("Invalid limit: %s", jqDescribe(limit))
+			}
+			if count <= 0 {
+				continue
+			}
+			values, err := q.eval(n.args[1], in, env)
+			if len(values) > int(count) {
+				values, err = values[:int(count)], nil
+			}
+			out = append(out, values...)
+			if err != nil {
+				return out, err
+			}
+		}
+		return out, nil
+	case "reverse/0":
+		switch t := in.(type) {
+		case nil:
+			return []interface{}{[]interface{}{}}, nil
+		case string:
+			runes := []rune(t)
+			for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+				runes[i], runes[j] = runes[j], runes[i]
+			}
+			return []interface{}{string(runes)}, nil
+		case []interface{}:
+			out := make([]interface{}, len(t))
+			for i, v := range t {
+				out[len(t)-1-i] = v
+			}
+			return []interface{}{out}, nil
+		}
+		return nil, jqErrorf("Cannot reverse %s", jqDescribe(in))
+	case "sort/0", "sort_by/1", "group_by/1", "unique/0", "unique_by/1", "min/0", "max/0", "min_by/1", "max_by/1":
+		return q.callSorted(n, in, env)
+	case "flatten/0", "flatten/1":
+		depth := []interface{}{1e9}
+		if len(n.args) == 1 {
+			var err error
+			if depth, err = q.eval(n.args[0], in, env); err != nil {
+				return nil, err
+			}
+		}
+		var out []interface{}
+		for _, d := range depth {
+			arr, ok := in.([]interface{})
+			if !ok {
+				return out, jqErrorf("Cannot flatten %s", jqDescribe(in))
+			}
+			limit, ok := d.(float64)
+			if !ok || limit < 0 {
+				return out, jqErrorf("flatten depth must not be negative")
+			}
+			out = append(out, jqFlatten(arr, int(limit)))
+		}
+		return out, nil
+	case "to_entries/0":
+		entries, err := jqToEntries(in)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{append([]interface{}{}, entries...)}, nil
+	case "from_entries/0":
+		values, err := jqValues(in)
+		if err != nil {
+			return nil, err
+		}
+		return one(jqFromEntries(values))
+	case "tostring/0":
+		if s, ok := in.(string); ok {
+			return []interface{}{s}, nil
+		}
+		return []interface{}{jqEncode(in, false, false)}, nil
+	case "tonumber/0":
+		switch t := in.(type) {
+		case float64:
+			return []interface{}{t}, nil
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+			if err != nil {
+				return nil, jqErrorf("Cannot parse %q as a number", t)
+			}
+			return []interface{}{f}, nil
+		}
+		return nil, jqErrorf("%s cannot be parsed as a number", jqDescribe(in))
+	case "tojson/0":
+		return []interface{}{jqEncode(in, false, false)}, nil
+	case "fromjson/0":
+		s, ok := in.(string)
+		if !ok {
+			return nil, jqErrorf("%s cannot be parsed as JSON", jqDescribe(in))
+		}
+		v, err := jqParseJSON(s)
+		if err != nil {
+			return nil, jqErrorf("%s (while parsing '%s')", err, s)
+		}
+		return []interface{}{v}, nil
+	case "ascii_downcase/0", "ascii_upcase/0", "ltrimstr/1", "rtrimstr/1", "startswith/1", "endswith/1",
+		"split/1", "join/1", "test/1", "test/2", "explode/0", "implode/0", "trim/0", "ltrim/0", "rtrim/0",
+		"split/2", "ascii/0", "index/1", "rindex/1", "indices/1":
+		return args(func(vals []interface{}) (interface{}, error) {
+			return q.callValue(n.name, in, vals...)
+		})
+	case "sub/2", "sub/3", "gsub/2", "gsub/3":
+		return q.callSub(n, in, env)
+	case "floor/0", "ceil/0", "round/0", "sqrt/0", "fabs/0", "abs/0", "log/0", "exp/0", "log10/0", "log2/0", "exp10/0":
+		f, ok := in.(float64)
+		if !ok {
+			return nil, jqErrorf("%s number required", jqDescribe(in))
+		}
+		return []interface{}{jqMath(n.name, f)}, nil
+	case "pow/2":
+		return args(func(vals []interface{}) (interface{}, error) {
+			x, xok := vals[0].(float64)
+			y, yok := vals[1].(float64)
+			if !xok || !yok {
+				return nil, jqErrorf("pow: number required")
+			}
+			return math.Pow(x, y), nil
+		})
+	case "path/1":
+		paths, err := q.paths(n.args[0], in, env)
+		out := make([]interface{}, len(paths))
+		for i, p := range paths {
+			out[i] = p.path
+		}
+		return out, err
+	case "paths/0", "leaf_paths/0":
+		paths, err := q.paths(&jqRecurse{}, in, env)
+		var out []interface{}
+		for _, p := range paths {
+			if len(p.path) == 0 {
+				continue
+			}
+			if n.name == "leaf_paths" {
+				switch p.value.(type) {
+				case []interface{}, *jqObject:
+					continue
+				}
+			}
+			out = append(out, p.path)
+		}
+		return out, err
+	case "getpath/1":
+		return args(func(vals []interface{}) (interface{}, error) {
+			path, ok := vals[0].([]interface{})
+			if !ok {
+				return nil, jqErrorf("Path must be specified as an array")
+			}
+			v, err := jqGetPath(in, path)
+			if err != nil {
+				return nil, nil
+			}
+			return v, nil
+		})
+	case "setpath/2":
+		return args(func(vals []interface{}) (interface{}, error) {
+			path, ok := vals[0].([]interface{})
+			if !ok {
+				return nil, jqErrorf("Path must be specified as an array")
+			}
+			return jqSetPath(in, path, vals[1])
+		})
+	case "delpaths/1":
+		return args(func(vals []interface{}) (interface{}, error) {
+			list, ok := vals[0].([]interface{})
+			if !ok {
+				return nil, jqErrorf("Paths must be specified as an array")
+			}
+			paths := make([][]interface{}, len(list))
+			for i, p := range list {
+				if paths[i], ok = p.([]interface{}); !ok {
+					return nil, jqErrorf("Path must be specified as an array")
+				}
+			}
+			return jqDeletePaths(in, paths)
+		})
+	case "del/1":
+		paths, err := q.paths(n.args[0], in, env)
+		if err != nil {
+			return nil, err
+		}
+		list := make([][]interface{}, len(paths))
+		for i, p := range paths {
+			list[i] = p.path
+		}
+		return one(jqDeletePaths(in, list))
+	case "to_array/0":
+		if arr, ok := in.([]interface{}); ok {
+			return []interface{}{arr}, nil
+		}
+		return []interface{}{[]interface{}{in}}, nil
+	case "input/0":
+		v, ok, err := q.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, jqErrorf("No more inputs")
+		}
+		return []interface{}{v}, nil
+	case "inputs/0":
+		var out []interface{}
+		for {
+			v, ok, err := q.next()
+			if err != nil {
+				return out, err
+			}
+			if !ok {
+				return out, nil
+			}
+			out = append(out, v)
+		}
+	case "objects/0", "arrays/0", "strings/0", "numbers/0", "booleans/0", "nulls/0", "iterables/0", "scalars/0":
+		if jqTypeMatches(n.name, in) {
+			return []interface{}{in}, nil
+		}
+		return nil, nil
+	case "isempty/1":
+		values, err := q.eval(n.args[0], in, env)
+		return []interface{}{len(values) == 0}, err
+	case "splits/1", "splits/2":
+		// splits always splits by a regex, which split/1 does not
+		splitArgs := n.args
+		if len(splitArgs) == 1 {
+			splitArgs = []jqNode{n.args[0], &jqLiteral{}}
+		}
+		parts, err := q.call(&jqCall{name: "split", args: splitArgs}, in, env)
+		var out []interface{}
+		for _, p := range parts {
+			out = append(out, p.([]interface{})...)
+		}
+		return out, err
+	}
+	if strings.HasPrefix(n.name, "@") {
+		return nil, jqErrorf("%s is not a valid format", n.name)
+	}
+	return nil, jqErrorf("%s is not defined", jqArity(n))
+}
+
+// callValue runs a builtin that takes values only, with the input first
+func (q *jqInterp) callValue(name string, in interface{}, args ...interface{}) (interface{}, error) {
+	str := func(v interface{}, what string) (string, error) {
+		s, ok := v.(string)
+		if !ok {
+			return "", jqErrorf("%s %s", jqDescribe(v), what)
+		}
+		return s, nil
+	}
+
+	switch name {
+	case "ascii_downcase", "ascii_upcase":
+		s, err := str(in, "cannot be case-converted")
+		if err != nil {
+			return nil, err
+		}
+		return strings.Map(func(r rune) rune {
+			switch {
+			case name == "ascii_downcase" && r >= 'A' && r <= 'Z':
+				return r + 'a' - 'A'
+			case name == "ascii_upcase" && r >= 'a' && r <= 'z':
+				return r - 'a' + 'A'
+			}
+			return r
+		}, s), nil
+	case "ltrimstr", "rtrimstr":
+		s, ok := in.(string)
+		affix, affixOK := args[0].(string)
+		if !ok || !affixOK {
+			return in, nil
+		}
+		if name == "ltrimstr" {
+			return strings.TrimPrefix(s, affix), nil
+		}
+		return strings.TrimSuffix(s, affix), nil
+	case "startswith", "endswith":
+		s, err := str(in, name+"() requires string inputs")
+		if err != nil {
+			return nil, err
+		}
+		affix, err := str(args[0], name+"() requires string inputs")
+		if err != nil {
+			return nil, err
+		}
+		if name == "startswith" {
+			return strings.HasPrefix(s, affix), nil
+		}
+		return strings.HasSuffix(s, affix), nil
+	case "trim", "ltrim", "rtrim":
+		s, err := str(in, "cannot be trimmed")
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "ltrim":
+			return strings.TrimLeft(s, " \t\n\r\f\v"), nil
+		case "rtrim":
+			return strings.TrimRight(s, " \t\n\r\f\v"), nil
+		}
+		return strings.TrimSpace(s), nil
+	case "split":
+		s, err := str(in, "cannot be split")
+		if err != nil {
+			return nil, err
+		}
+		sep, err := str(args[0], "is not a valid separator")
+		if err != nil {
+			return nil, err
+		}
+		if len(args) == 1 {
+			return jqSplit(s, sep), nil
+		}
+		re, err := jqRegexp(sep, args[1])
+		if err != nil {
+			return nil, err
+		}
+		out := []interface{}{}
+		for _, part := range re.Split(s, -1) {
+			out = append(out, part)
+		}
+		return out, nil
+	case "join":
+		values, err := jqValues(in)
+		if err != nil {
+			return nil, err
+		}
+		sep, err := str(args[0], "is not a valid separator")
+		if err != nil {
+			return nil, err
+		}
+		parts := make([]string, len(values))
+		for i, v := range values {
+			switch t := v.(type) {
+			case nil:
+			case string:
+				parts[i] = t
+			case float64, bool:
+				parts[i] = jqEncode(t, false, false)
+			default:
+				return nil, jqErrorf("Cannot join with %s", jqTypeName(v))
+			}
+		}
+		return strings.Join(parts, sep), nil
+	case "test":
+		s, err := str(in, "cannot be matched, as it is not a string")
+		if err != nil {
+			return nil, err
+		}
+		pattern, err := str(args[0], "cannot be matched, as it is not a string")
+		if err != nil {
+			return nil, err
+		}
+		var flags interface{}
+		if len(args) == 2 {
+			flags = args[1]
+		}
+		re, err := jqRegexp(pattern, flags)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(s), nil
+	case "explode":
+		s, err := str(in, "cannot be exploded")
+		if err != nil {
+			return nil, err
+		}
+		out := []interface{}{}
+		for _, r := range s {
+			out = append(out, float64(r))
+		}
+		return out, nil
+	case "implode":
+		arr, ok := in.([]interface{})
+		if !ok {
+			return nil, jqErrorf("%s cannot be imploded", jqDescribe(in))
+		}
+		var b strings.Builder
+		for _, v := range arr {
+			r, ok := v.(float64)
+			if !ok {
+				return nil, jqErrorf("Unicode codepoints must be numbers")
+			}
+			b.WriteRune(rune(r))
+		}
+		return b.String(), nil
+	case "ascii":
+		r, ok := in.(float64)
+		if !ok {
+			return nil, jqErrorf("ascii: number required")
+		}
+		return string(rune(r)), nil
+	case "index", "rindex", "indices":
+		return jqIndices(name, in, args[0])
+	}
+	return nil, jqErrorf("%s is not defined", name)
+}
+
+// jqIndices finds where a string occurs in a string, or a value or
+// subarray in an array
+func jqIndices(name string, in, target interface{}) (interface{}, error) {
+	var found []interface{}
+	switch t := in.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		sub, ok := target.(string)
+		if !ok {
+			return nil, jqErrorf("Cannot determine the indices of %s in a string", jqTypeName(target))
+		}
+		if sub == "" {
+			return nil, nil
+		}
+		for i := 0; i+len(sub) <= len(t); i++ {
+			if strings.HasPrefix(t[i:], sub) {
+				found = append(found, float64(utf8.RuneCountInString(t[:i])))
+			}
+		}
+	case []interface{}:
+		sub, ok := target.([]interface{})
+		if !ok {
+			sub = []interface{}{target}
+		}
+		if len(sub) == 0 {
+			return nil, nil
+		}
+		for i := 0; i+len(sub) <= len(t); i++ {
+			match := true
+			for j := range sub {
+				if jqCompare(t[i+j], sub[j]) != 0 {
+					match = false
+					break
+				}
+			}
+			if match {
+				found = append(found, float64(i))
+			}
+		}
+	default:
+		return nil, jqErrorf("Cannot determine the indices in %s", jqDescribe(in))
+	}
+
+	switch name {
+	case "index":
+		if len(found) == 0 {
+			return nil, nil
+		}
+		return found[0], nil
+	case "rindex":
+		if len(found) == 0 {
+			return nil, nil
+		}
+		return found[len(found)-1], nil
+	}
+	if found == nil {
+		found = []interface{}{}
+	}
+	return found, nil
+}
+
+// jqRegexp compiles a regex with jq's flags: i ignores case, x allows
+// extended syntax, s lets . match newlines, g is accepted and handled by the
+// caller
+func jqRegexp(pattern string, flags interface{}) (*regexp.Regexp, error) {
+	prefix := ""
+	if flags != nil {
+		f, ok := flags.(string)
+		if !ok {
+			return nil, jqErrorf("%s is not a string", jqDescribe(flags))
+		}
+		for _, flag := range f {
+			switch flag {
+			case 'i', 's':
+				prefix += string(flag)
+			case 'x':
+				pattern = regexp.MustCompile(`\s+|#.*`).ReplaceAllString(pattern, "")
+			case 'g', 'n':
+			default:
+				return nil, jqErrorf("%s is not a valid modifier string", f)
+			}
+		}
+	}
+	if prefix != "" {
+		pattern = "(?" + prefix + ")" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, jqErrorf("%s (at offset 0) is not a valid regex: %v", pattern, err)
+	}
+	return re, nil
+}
+
+// callSub runs sub and gsub. The replacement is a filter run on an object of
+// the named captures, as in jq, so "\(.name)" refers to a group.
+func (q *jqInterp) callSub(n *jqCall, in interface{}, env *jqEnv) ([]interface{}, error) {
+	s, ok := in.(string)
+	if !ok {
+		return nil, jqErrorf("%s cannot be matched, as it is not a string", jqDescribe(in))
+	}
+	patterns, err := q.eval(n.args[0], in, env)
+	if err != nil {
+		return nil, err
+	}
+	var flags interface{}
+	if len(n.args) == 3 {
+		values, err := q.eval(n.args[2], in, env)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) > 0 {
+			flags = values[0]
+		}
+	}
+	global := n.name == "gsub"
+	if f, ok := flags.(string); ok && strings.Contains(f, "g") {
+		global = true
+	}
+
+	var out []interface{}
+	for _, p := range patterns {
+		pattern, ok := p.(string)
+		if !ok {
+			return out, jqErrorf("%s cannot be matched, as it is not a string", jqDescribe(p))
+		}
+		re, err := jqRegexp(pattern, flags)
+		if err != nil {
+			return out, err
+		}
+		matches := re.FindAllStringSubmatchIndex(s, -1)
+		if !global && len(matches) > 1 {
+			matches = matches[:1]
+		}
+		var b strings.Builder
+		last := 0
+		for _, m := range matches {
+			captures := newJqObject()
+			for i, name := range re.SubexpNames() {
+				if name == "" {
+					continue
+				}
+				var v interface{}
+				if m[2*i] >= 0 {
+					v = s[m[2*i]:m[2*i+1]]
+				}
+				captures.set(name, v)
+			}
+			repl, err := q.eval(n.args[1], captures, env)
+			if err != nil {
+				return out, err
+			}
+			if len(repl) == 0 {
+				continue
+			}
+			r, ok := repl[0].(string)
+			if !ok {
+				return out, jqErrorf("%s cannot be added to a string", jqDescribe(repl[0]))
+			}
+			b.WriteString(s[last:m[0]])
+			b.WriteString(r)
+			last = m[1]
+		}
+		b.WriteString(s[last:])
+		out = append(out, b.String())
+	}
+	return out, nil
+}
+
+func (q *jqInterp) callRange(n *jqCall, in interface{}, env *jqEnv) ([]interface{}, error) {
+	bounds := make([][]interface{}, len(n.args))
+	for i, arg := range n.args {
+		values, err := q.eval(arg, in, env)
+		if err != nil {
+			return nil, err
+		}
+		bounds[i] = values
+	}
+	var out []interface{}
+	emit := func(from, upto interface{}) error {
+		start, ok1 := from.(float64)
+		end, ok2 := upto.(float64)
+		if !ok1 || !ok2 {
+			return jqErrorf("Range bounds must be numeric")
+		}
+		for x := start; x < end; x++ {
+			out = append(out, x)
+			if len(out) > 10000000 {
+				return jqErrorf("range: too many values")
+			}
+		}
+		return nil
+	}
+	if len(n.args) == 1 {
+		for _, upto := range bounds[0] {
+			if err := emit(0.0, upto); err != nil {
+				return out, err
+			}
+		}
+		return out, nil
+	}
+	for _, from := range bounds[0] {
+		for _, upto := range bounds[1] {
+			if err := emit(from, upto); err != nil {
+				return out, err
+			}
+		}
+	}
+	return out, nil
+}
+
+// callSorted runs the builtins that order an array, by value or by a filter
+func (q *jqInterp) callSorted(n *jqCall, in interface{}, env *jqEnv) ([]interface{}, error) {
+	arr, ok := in.([]interface{})
+	if !ok {
+		return nil, jqErrorf("%s cannot be sorted, as it is not an array", jqDescribe(in))
+	}
+	type keyed struct {
+		key   interface{}
+		value interface{}
+	}
+	items := make([]keyed, len(arr))
+	for i, v := range arr {
+		items[i] = keyed{key: v, value: v}
+		if len(n.args) == 1 {
+			keys, err := q.eval(n.args[0], v, env)
+			if err != nil {
+				return nil, err
+			}
+			// Several outputs sort as the array of them, as in jq
+			items[i].key = append([]interface{}{}, keys...)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return jqCompare(items[i].key, items[j].key) < 0
+	})
+
+	switch n.name {
+	case "min", "min_by", "max", "max_by":
+		if len(items) == 0 {
+			return []interface{}{nil}, nil
+		}
+		if strings.HasPrefix(n.name, "min") {
+			return []interface{}{items[0].value}, nil
+		}
+		return []interface{}{items[len(items)-1].value}, nil
+	case "group_by", "unique", "unique_by":
+		groups := []interface{}{}
+		for i := 0; i < len(items); {
+			j := i
+			var group []interface{}
+			for ; j < len(items) && jqCompare(items[i].key, items[j].key) == 0; j++ {
+				group = append(group, items[j].value)
+			}
+			if n.name == "group_by" {
+				groups = append(groups, group)
+			} else {
+				groups = append(groups, group[0])
+			}
+			i = j
+		}
+		return []interface{}{groups}, nil
+	}
+	out := make([]interface{}, len(items))
+	for i, item := range items {
+		out[i] = item.value
+	}
+	return []interface{}{out}, nil
+}
+
+// jqHas reports whether an object has a key or an array an index
+func jqHas(v, key interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case *jqObject:
+		if k, ok := key.(string); ok {
+			_, exists := t.get(k)
+			return exists, nil
+		}
+	case []interface{}:
+		if k, ok := key.(float64); ok {
+			return k >= 0 && int(k) < len(t), nil
+		}
+	}
+	return nil, jqErrorf("Cannot check whether %s has a %s key", jqTypeName(v), jqTypeName(key))
+}
+
+// jqLength is the length of a string in codepoints, of an array or object,
+// or the absolute value of a number
+func jqLength(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case nil:
+		return 0.0, nil
+	case float64:
+		return math.Abs(t), nil
+	case string:
+		return float64(utf8.RuneCountInString(t)), nil
+	case []interface{}:
+		return float64(len(t)), nil
+	case *jqObject:
+		return float64(len(t.keys)), nil
+	}
+	return nil, jqErrorf("%s has no length", jqDescribe(v))
+}
+
+// jqContains reports whether b is contained in a: substrings, array
+// elements contained in some element, and object values contained under
+// the same key
+func jqContains(a, b interface{}) (bool, error) {
+	if jqTypeName(a) != jqTypeName(b) {
+		return false, jqErrorf("%s and %s cannot have their containment checked", jqDescribe(a), jqDescribe(b))
+	}
+	switch x := a.(type) {
+	case string:
+		return strings.Contains(x, b.(string)), nil
+	case []interface{}:
+		for _, want := range b.([]interface{}) {
+			found := false
+			for _, have := range x {
+				if jqTypeName(have) != jqTypeName(want) {
+					continue
+				}
+				if ok, _ := jqContains(have, want); ok {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false, nil
+			}
+		}
+		return true, nil
+	case *jqObject:
+		for _, k := range b.(*jqObject).keys {
+			have, exists := x.get(k)
+			want := b.(*jqObject).values[k]
+			if !exists || jqTypeName(have) != jqTypeName(want) {
+				return false, nil
+			}
+			if ok, _ := jqContains(have, want); !ok {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	return jqCompare(a, b) == 0, nil
+}
+
+// jqToEntries converts an object to an array of {key, value}; an array's
+// keys are its indices
+func jqToEntries(v interface{}) ([]interface{}, error) {
+	var entries []interface{}
+	add := func(key, value interface{}) {
+		entry := newJqObject()
+		entry.set("key", key)
+		entry.set("value", value)
+		entries = append(entries, entry)
+	}
+	switch t := v.(type) {
+	case *jqObject:
+		for _, k := range t.keys {
+			add(k, t.values[k])
+		}
+	case []interface{}:
+		for i, elem := range t {
+			add(float64(i), elem)
+		}
+	default:
+		return nil, jqErrorf("%s has no keys", jqDescribe(v))
+	}
+	return entries, nil
+}
+
+// jqFromEntries builds an object from {key, value} entries; k, name and
+// v are accepted too, as in jq
+func jqFromEntries(entries []interface{}) (interface{}, error) {
+	obj := newJqObject()
+	for _, e := range entries {
+		entry, ok := e.(*jqObject)
+		if !ok {
+			return nil, jqErrorf("Cannot index %s with \"key\"", jqTypeName(e))
+		}
+		var key interface{}
+		for _, name := range []string{"key", "k", "name", "Name", "Key", "K"} {
+			if k, exists := entry.get(name); exists && k != nil {
+				key = k
+				break
+			}
+		}
+		var value interface{}
+		for _, name := range []string{"value", "v", "Value", "V"} {
+			if v, exists := entry.get(name); exists {
+				value = v
+				break
+			}
+		}
+		switch k := key.(type) {
+		case string:
+			obj.set(k, value)
+		case float64, bool:
+			obj.set(jqEncode(k, false, false), value)
+		default:
+			return nil, jqErrorf("Cannot use %s as object key", jqDescribe(key))
+		}
+	}
+	return obj, nil
+}
+
+// jqFlatten flattens nested arrays up to depth levels
+func jqFlatten(arr []interface{}, depth int) []interface{} {
+	out := []interface{}{}
+	for _, v := range arr {
+		if inner, ok := v.([]interface{}); ok && depth > 0 {
+			out = append(out, jqFlatten(inner, depth-1)...)
+		} else {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func jqMath(name string, f float64) float64 {
+	switch name {
+	case "floor":
+		return math.Floor(f)
+	case "ceil":
+		return math.Ceil(f)
+	case "round":
+		return math.Round(f)
+	case "sqrt":
+		return math.Sqrt(f)
+	case "log":
+		return math.Log(f)
+	case "log10":
+		return math.Log10(f)
+	case "log2":
+		return math.Log2(f)
+	case "exp":
+		return math.Exp(f)
+	case "exp10":
+		return math.Pow(10, f)
+	}
+	return math.Abs(f)
+}
+
+// jqTypeMatches implements the type selectors such as strings and iterables
+func jqTypeMatches(selector string, v interface{}) bool {
+	switch t := jqTypeName(v); selector {
+	case "iterables":
+		return t == "array" || t == "object"
+	case "scalars":
+		return t != "array" && t != "object"
+	case "booleans":
+		return t == "boolean"
+	case "nulls":
+		return t == "null"
+	default:
+		return t+"s" == selector
+	}
+}
+
+// jqApplyFormat applies a format such as @csv or @base64 to a value
+func jqApplyFormat(name string, v interface{}) (string, error) {
+	text := func(v interface{}) string {
+		if s, ok := v.(string); ok {
+			return s
+		}
+		return jqEncode(v, false, false)
+	}
+	switch name {
+	case "text":
+		return text(v), nil
+	case "json":
+		return jqEncode(v, false, false), nil
+	case "csv", "tsv":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return "", jqErrorf("%s cannot be %s-formatted, only an array can be", jqDescribe(v), name)
+		}
+		fields := make([]string, len(arr))
+		for i, elem := range arr {
+			switch t := elem.(type) {
+			case nil:
+			case bool, float64:
+				fields[i] = jqEncode(t, false, false)
+			case string:
+				if name == "csv" {
+					fields[i] = `"` + strings.ReplaceAll(t, `"`, `""`) + `"`
+				} else {
+					fields[i] = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`).Replace(t)
+				}
+			default:
+				return "", jqErrorf("%s is not valid in a csv row", jqDescribe(elem))
+			}
+		}
+		sep := ","
+		if name == "tsv" {
+			sep = "\t"
+		}
+		return strings.Join(fields, sep), nil
+	case "html":
+		return strings.NewReplacer("<", "&lt;", ">", "&gt;", "&", "&amp;", "'", "&#39;", `"`, "&quot;").Replace(text(v)), nil
+	case "uri":
+		var b strings.Builder
+		for _, c := range []byte(text(v)) {
+			if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~", c) >= 0 {
+				b.WriteByte(c)
+			} else {
+				fmt.Fprintf(&b, "%%%02X", c)
+			}
+		}
+		return b.String(), nil
+	case "sh":
+		quote := func(v interface{}) (string, error) {
+			switch t := v.(type) {
+			case string:
+				return "'" + strings.ReplaceAll(t, "'", `'\''`) + "'", nil
+			case []interface{}, *jqObject:
+				return "", jqErrorf("%s can not be escaped for shell", jqDescribe(v))
+			}
+			return jqEncode(v, false, false), nil
+		}
+		arr, ok := v.([]interface{})
+		if !ok {
+			return quote(v)
+		}
+		words := make([]string, len(arr))
+		for i, elem := range arr {
+			word, err := quote(elem)
+			if err != nil {
+				return "", err
+			}
+			words[i] = word
+		}
+		return strings.Join(words, " "), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString([]byte(text(v))), nil
+	case "base64d":
+		s := strings.TrimRight(text(v), "=")
+		data, err := base64.RawStdEncoding.DecodeString(s)
+		if err != nil {
+			return "", jqErrorf("%s is not valid base64 data", jqDescribe(v))
+		}
+		return string(data), nil
+	}
+	return "", jqErrorf("%s is not a valid format", name)
+}
@@ -0,0 +1,837 @@
+package builtin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jqTokenKind classifies jq tokens
+type jqTokenKind int
+
+const (
+	jqEOF jqTokenKind = iota
+	jqIdent
+	jqField  // .name
+	jqVarTok // $name
+	jqFormatTok
+	jqNumber
+	jqStringTok
+	jqPunct
+)
+
+// jqStringPart is a literal piece of a string or an interpolated \(...)
+type jqStringPart struct {
+	text string
+	expr jqNode
+}
+
+// jqToken is a token of a jq filter
+type jqToken struct {
+	kind  jqTokenKind
+	text  string // Name, operator or format
+	num   float64
+	parts []jqStringPart // For strings
+	pos   int
+}
+
+// jqOperators are the operators, longest first
+var jqOperators = []string{
+	"//=", "|=", "+=", "-=", "*=", "/=", "%=", "==", "!=", "<=", ">=", "//", "..",
+	"|", ",", "+", "-", "*", "/", "%", "=", "<", ">", "(", ")", "[", "]", "{", "}",
+	":", ";", "?", ".",
+}
+
+func isJqIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isJqIdentChar(c byte) bool {
+	return isJqIdentStart(c) || c >= '0' && c <= '9'
+}
+
+// lexJq splits a jq filter into tokens
+func lexJq(src string) ([]jqToken, error) {
+	var tokens []jqToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '.' && i+1 < len(src) && isJqIdentStart(src[i+1]):
+			start := i + 1
+			for i = start; i < len(src) && isJqIdentChar(src[i]); i++ {
+			}
+			tokens = append(tokens, jqToken{kind: jqField, text: src[start:i], pos: start - 1})
+		case (c == '$' || c == '@') && i+1 < len(src) && isJqIdentStart(src[i+1]):
+			start := i + 1
+			for i = start; i < len(src) && isJqIdentChar(src[i]); i++ {
+			}
+			kind := jqVarTok
+			if c == '@' {
+				kind = jqFormatTok
+			}
+			tokens = append(tokens, jqToken{kind: kind, text: src[start:i], pos: start - 1})
+		case isJqIdentStart(c):
+			start := i
+			for i < len(src) && isJqIdentChar(src[i]) {
+				i++
+			}
+			tokens = append(tokens, jqToken{kind: jqIdent, text: src[start:i], pos: start})
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				i++
+				if i < len(src) && (src[i] == '+' || src[i] == '-') {
+					i++
+				}
+				for i < len(src) && src[i] >= '0' && src[i] <= '9' {
+					i++
+				}
+			}
+			n, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", src[start:i])
+			}
+			tokens = append(tokens, jqToken{kind: jqNumber, num: n, pos: start})
+		case c == '"':
+			parts, n, err := lexJqString(src[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, jqToken{kind: jqStringTok, parts: parts, pos: i})
+			i += n
+		default:
+			op := ""
+			for _, candidate := range jqOperators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+			tokens = append(tokens, jqToken{kind: jqPunct, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, jqToken{kind: jqEOF, pos: len(src)}), nil
+}
+
+// lexJqString reads a string at the start of src, parsing the filters it
+// interpolates, and returns its parts and length in src
+func lexJqString(src string) ([]jqStringPart, int, error) {
+	var parts []jqStringPart
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '"':
+			if b.Len() > 0 || len(parts) == 0 {
+				parts = append(parts, jqStringPart{text: b.String()})
+			}
+			return parts, i + 1, nil
+		case c != '\\':
+			b.WriteByte(c)
+			continue
+		case i+1 == len(src):
+			return nil, 0, fmt.Errorf("unterminated string")
+		}
+
+		i++
+		switch e := src[i]; e {
+		case '(':
+			end, err := jqInterpolationEnd(src, i+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			expr, err := parseJq(src[i+1 : end])
+			if err != nil {
+				return nil, 0, err
+			}
+			if b.Len() > 0 {
+				parts = append(parts, jqStringPart{text: b.String()})
+				b.Reset()
+			}
+			parts = append(parts, jqStringPart{expr: expr})
+			i = end
+		case 'u':
+			if i+4 >= len(src) {
+				return nil, 0, fmt.Errorf("invalid \\u escape")
+			}
+			r, err := strconv.ParseUint(src[i+1:i+5], 16, 32)
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid \\u escape %q", src[i-1:i+5])
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case '"', '\\', '/':
+			b.WriteByte(e)
+		default:
+			return nil, 0, fmt.Errorf("invalid escape \\%c", e)
+		}
+	}
+	return nil, 0, fmt.Errorf("unterminated string")
+}
+
+// jqInterpolationEnd finds the ) closing an interpolation that starts at
+// start, skipping nested parentheses and strings
+func jqInterpolationEnd(src string, start int) (int, error) {
+	depth := 0
+	for i := start; i < len(src); i++ {
+		switch src[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i, nil
+			}
+			depth--
+		case '"':
+			// Skip a nested string, with its escapes
+			for i++; i < len(src) && src[i] != '"'; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+	return 0, fmt.Errorf("unterminated string interpolation")
+}
+
+// jqNode is a node of a parsed jq filter
+type jqNode interface{}
+
+type (
+	jqIdentity struct{}
+	jqRecurse  struct{} // ..
+	jqIndex    struct {
+		target, key jqNode
+	}
+	jqSlice struct {
+		target, from, to jqNode // from and to may be nil
+	}
+	jqIterate struct{ target jqNode }
+	jqTry     struct{ body, catch jqNode } // catch is nil for ? and a bare try
+	jqLiteral struct{ value interface{} }
+	jqString  struct {
+		parts  []jqStringPart
+		format string // Applied to interpolated values, "" for text
+	}
+	jqFormat  struct{ name string }
+	jqArray   struct{ body jqNode } // body is nil for []
+	jqObjNode struct{ entries []jqEntry }
+	jqVar     struct{ name string }
+	jqCall    struct {
+		name string
+		args []jqNode
+	}
+	jqPipe   struct{ left, right jqNode }
+	jqComma  struct{ left, right jqNode }
+	jqBinary struct {
+		op          string // Arithmetic, comparison, "and", "or" or "//"
+		left, right jqNode
+	}
+	jqNeg    struct{ operand jqNode }
+	jqAssign struct {
+		op          string // "=", "|=" or an arithmetic operator or "//" followed by "="
+		left, right jqNode
+	}
+	jqIf struct {
+		cond, then, otherwise jqNode // otherwise is nil without else
+	}
+	jqReduce struct {
+		source       jqNode
+		name         string
+		init, update jqNode
+		extract      jqNode // foreach only, may be nil
+		foreach      bool
+	}
+	jqAs struct {
+		source jqNode
+		name   string
+		body   jqNode
+	}
+)
+
+// jqEntry is a key and value of an object construction
+type jqEntry struct {
+	key, value jqNode
+}
+
+// jqParser parses a token list
+type jqParser struct {
+	tokens []jqToken
+	pos    int
+}
+
+// parseJq parses a jq filter
+func parseJq(src string) (jqNode, error) {
+	tokens, err := lexJq(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &jqParser{tokens: tokens}
+	node, err := p.pipe()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok().kind != jqEOF {
+		return nil, p.errorf("unexpected %s", p.describe())
+	}
+	return node, nil
+}
+
+func (p *jqParser) tok() jqToken {
+	return p.tokens[p.pos]
+}
+
+func (p *jqParser) is(text string) bool {
+	t := p.tok()
+	return (t.kind == jqPunct || t.kind == jqIdent) && t.text == text
+}
+
+func (p *jqParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at position %d", fmt.Sprintf(format, args...), p.tok().pos)
+}
+
+// describe names the current token in errors
+func (p *jqParser) describe() string {
+	switch t := p.tok(); t.kind {
+	case jqEOF:
+		return "end of filter"
+	case jqField:
+		return "'." + t.text + "'"
+	case jqVarTok:
+		return "'$" + t.text + "'"
+	case jqFormatTok:
+		return "'@" + t.text + "'"
+	case jqNumber:
+		return "number"
+	case jqStringTok:
+		return "string"
+	default:
+		return "'" + t.text + "'"
+	}
+}
+
+func (p *jqParser) expect(text string) error {
+	if !p.is(text) {
+		return p.errorf("expected '%s', got %s", text, p.describe())
+	}
+	p.pos++
+	return nil
+}
+
+// pipe parses filters joined by |, the lowest precedence
+func (p *jqParser) pipe() (jqNode, error) {
+	if p.is("def") {
+		return nil, p.errorf("def is not supported")
+	}
+	left, err := p.comma()
+	if err != nil {
+		return nil, err
+	}
+	if !p.is("|") {
+		return left, nil
+	}
+	p.pos++
+	right, err := p.pipe()
+	if err != nil {
+		return nil, err
+	}
+	return &jqPipe{left: left, right: right}, nil
+}
+
+func (p *jqParser) comma() (jqNode, error) {
+	left, err := p.alternative()
+	if err != nil {
+		return nil, err
+	}
+	for p.is(",") {
+		p.pos++
+		right, err := p.alternative()
+		if err != nil {
+			return nil, err
+		}
+		left = &jqComma{left: left, right: right}
+	}
+	return left, nil
+}
+
+// alternative parses //, which is right associative
+func (p *jqParser) alternative() (jqNode, error) {
+	left, err := p.assignment()
+	if err != nil {
+		return nil, err
+	}
+	if !p.is("//") {
+		return left, nil
+	}
+	p.pos++
+	right, err := p.alternative()
+	if err != nil {
+		return nil, err
+	}
+	return &jqBinary{op: "//", left: left, right: right}, nil
+}
+
+func (p *jqParser) assignment() (jqNode, error) {
+	left, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"=", "|=", "+=", "-=", "*=", "/=", "%=", "//="} {
+		if p.is(op) {
+			p.pos++
+			right, err := p.alternative()
+			if err != nil {
+				return nil, err
+			}
+			return &jqAssign{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *jqParser) or() (jqNode, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("or") {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &jqBinary{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *jqParser) and() (jqNode, error) {
+	left, err := p.comparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("and") {
+		p.pos++
+		right, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+		left = &jqBinary{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+// comparison parses a comparison, which does not chain
+func (p *jqParser) comparison() (jqNode, error) {
+	left, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<", "<=", ">", ">="} {
+		if p.is(op) {
+			p.pos++
+			right, err := p.additive()
+			if err != nil {
+				return nil, err
+			}
+			return &jqBinary{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *jqParser) additive() (jqNode, error) {
+	left, err := p.multiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("+") || p.is("-") {
+		op := p.tok().text
+		p.pos++
+		right, err := p.multiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &jqBinary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *jqParser) multiplicative() (jqNode, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("*") || p.is("/") || p.is("%") {
+		op := p.tok().text
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &jqBinary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *jqParser) unary() (jqNode, error) {
+	if p.is("-") {
+		p.pos++
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &jqNeg{operand: operand}, nil
+	}
+	return p.postfix(true)
+}
+
+// postfix parses a term and its suffixes: .name, [...], ? and, when
+// allowAs is set, "as $name | body"
+func (p *jqParser) postfix(allowAs bool) (jqNode, error) {
+	term, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.tok().kind == jqField:
+			term = &jqIndex{target: term, key: &jqLiteral{value: p.tok().text}}
+			p.pos++
+		case p.is(".") && p.tokens[p.pos+1].kind == jqStringTok:
+			p.pos++
+			key, err := p.primary()
+			if err != nil {
+				return nil, err
+			}
+			term = &jqIndex{target: term, key: key}
+		case p.is(".") && p.tokens[p.pos+1].kind == jqPunct && p.tokens[p.pos+1].text == "[":
+			p.pos++
+		case p.is("["):
+			if term, err = p.bracketSuffix(term); err != nil {
+				return nil, err
+			}
+		case p.is("?"):
+			p.pos++
+			term = &jqTry{body: term}
+		case allowAs && p.is("as"):
+			p.pos++
+			if p.tok().kind != jqVarTok {
+				return nil, p.errorf("expected a variable after as, got %s", p.describe())
+			}
+			name := p.tok().text
+			p.pos++
+			if err := p.expect("|"); err != nil {
+				return nil, err
+			}
+			body, err := p.pipe()
+			if err != nil {
+				return nil, err
+			}
+			return &jqAs{source: term, name: name, body: body}, nil
+		default:
+			return term, nil
+		}
+	}
+}
+
+// bracketSuffix parses [], [index] or [from:to] after a term
+func (p *jqParser) bracketSuffix(target jqNode) (jqNode, error) {
+	p.pos++
+	if p.is("]") {
+		p.pos++
+		return &jqIterate{target: target}, nil
+	}
+	var from jqNode
+	if !p.is(":") {
+		var err error
+		if from, err = p.pipe(); err != nil {
+			return nil, err
+		}
+		if p.is("]") {
+			p.pos++
+			return &jqIndex{target: target, key: from}, nil
+		}
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	slice := &jqSlice{target: target, from: from}
+	if !p.is("]") {
+		to, err := p.pipe()
+		if err != nil {
+			return nil, err
+		}
+		slice.to = to
+	}
+	return slice, p.expect("]")
+}
+
+func (p *jqParser) primary() (jqNode, error) {
+	t := p.tok()
+	switch t.kind {
+	case jqNumber:
+		p.pos++
+		return &jqLiteral{value: t.num}, nil
+	case jqStringTok:
+		p.pos++
+		return jqStringNode(t.parts, ""), nil
+	case jqFormatTok:
+		p.pos++
+		if p.tok().kind == jqStringTok {
+			parts := p.tok().parts
+			p.pos++
+			return jqStringNode(parts, t.text), nil
+		}
+		return &jqFormat{name: t.text}, nil
+	case jqField:
+		p.pos++
+		return &jqIndex{target: &jqIdentity{}, key: &jqLiteral{value: t.text}}, nil
+	case jqVarTok:
+		p.pos++
+		return &jqVar{name: t.text}, nil
+	case jqIdent:
+		return p.identifier()
+	}
+
+	switch t.text {
+	case ".":
+		p.pos++
+		if p.tok().kind == jqStringTok {
+			key, err := p.primary()
+			if err != nil {
+				return nil, err
+			}
+			return &jqIndex{target: &jqIdentity{}, key: key}, nil
+		}
+		return &jqIdentity{}, nil
+	case "..":
+		p.pos++
+		return &jqRecurse{}, nil
+	case "(":
+		p.pos++
+		body, err := p.pipe()
+		if err != nil {
+			return nil, err
+		}
+		return body, p.expect(")")
+	case "[":
+		p.pos++
+		if p.is("]") {
+			p.pos++
+			return &jqArray{}, nil
+		}
+		body, err := p.pipe()
+		if err != nil {
+			return nil, err
+		}
+		return &jqArray{body: body}, p.expect("]")
+	case "{":
+		return p.object()
+	}
+	return nil, p.errorf("unexpected %s", p.describe())
+}
+
+// jqStringNode makes a literal of a string without interpolations
+func jqStringNode(parts []jqStringPart, format string) jqNode {
+	if len(parts) == 1 && parts[0].expr == nil {
+		return &jqLiteral{value: parts[0].text}
+	}
+	return &jqString{parts: parts, format: format}
+}
+
+// identifier parses a keyword construct, a constant or a function call
+func (p *jqParser) identifier() (jqNode, error) {
+	name := p.tok().text
+	p.pos++
+	switch name {
+	case "null":
+		return &jqLiteral{value: nil}, nil
+	case "true", "false":
+		return &jqLiteral{value: name == "true"}, nil
+	case "if":
+		return p.ifRest()
+	case "try":
+		body, err := p.postfix(false)
+		if err != nil {
+			return nil, err
+		}
+		node := &jqTry{body: body}
+		if p.is("catch") {
+			p.pos++
+			if node.catch, err = p.postfix(false); err != nil {
+				return nil, err
+			}
+		}
+		return node, nil
+	case "reduce", "foreach":
+		source, err := p.postfix(false)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("as"); err != nil {
+			return nil, err
+		}
+		if p.tok().kind != jqVarTok {
+			return nil, p.errorf("expected a variable after as, got %s", p.describe())
+		}
+		node := &jqReduce{source: source, name: p.tok().text, foreach: name == "foreach"}
+		p.pos++
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		if node.init, err = p.pipe(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(";"); err != nil {
+			return nil, err
+		}
+		if node.update, err = p.pipe(); err != nil {
+			return nil, err
+		}
+		if node.foreach && p.is(";") {
+			p.pos++
+			if node.extract, err = p.pipe(); err != nil {
+				return nil, err
+			}
+		}
+		return node, p.expect(")")
+	case "def", "label", "import", "include":
+		p.pos--
+		return nil, p.errorf("%s is not supported", name)
+	case "then", "elif", "else", "end", "as", "catch", "and", "or":
+		p.pos--
+		return nil, p.errorf("unexpected %s", p.describe())
+	}
+
+	call := &jqCall{name: name}
+	if !p.is("(") {
+		return call, nil
+	}
+	p.pos++
+	for {
+		arg, err := p.pipe()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+		if !p.is(";") {
+			break
+		}
+		p.pos++
+	}
+	return call, p.expect(")")
+}
+
+// ifRest parses the rest of if cond then a [elif ...] [else b] end
+func (p *jqParser) ifRest() (jqNode, error) {
+	cond, err := p.pipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("then"); err != nil {
+		return nil, err
+	}
+	then, err := p.pipe()
+	if err != nil {
+		return nil, err
+	}
+	node := &jqIf{cond: cond, then: then}
+	switch {
+	case p.is("elif"):
+		p.pos++
+		node.otherwise, err = p.ifRest()
+		return node, err
+	case p.is("else"):
+		p.pos++
+		if node.otherwise, err = p.pipe(); err != nil {
+			return nil, err
+		}
+	}
+	return node, p.expect("end")
+}
+
+// object parses an object construction
+func (p *jqParser) object() (jqNode, error) {
+	p.pos++
+	node := &jqObjNode{}
+	for !p.is("}") {
+		var entry jqEntry
+		t := p.tok()
+		switch {
+		case t.kind == jqIdent:
+			p.pos++
+			entry.key = &jqLiteral{value: t.text}
+			entry.value = &jqIndex{target: &jqIdentity{}, key: entry.key}
+		case t.kind == jqVarTok:
+			p.pos++
+			entry.key = &jqLiteral{value: t.text}
+			entry.value = &jqVar{name: t.text}
+		case t.kind == jqStringTok:
+			p.pos++
+			entry.key = jqStringNode(t.parts, "")
+			entry.value = &jqIndex{target: &jqIdentity{}, key: entry.key}
+		case p.is("("):
+			p.pos++
+			key, err := p.pipe()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			entry.key = key
+			if !p.is(":") {
+				return nil, p.errorf("expected ':' after a computed key")
+			}
+		default:
+			return nil, p.errorf("unexpected %s in object", p.describe())
+		}
+
+		if p.is(":") {
+			p.pos++
+			value, err := p.alternative()
+			if err != nil {
+				return nil, err
+			}
+			for p.is("|") {
+				p.pos++
+				right, err := p.alternative()
+				if err != nil {
+					return nil, err
+				}
+				value = &jqPipe{left: value, right: right}
+			}
+			entry.value = value
+		}
+		node.entries = append(node.entries, entry)
+		if !p.is(",") {
+			break
+		}
+		p.pos++
+	}
+	return node, p.expect("}")
+}
@@ -0,0 +1,834 @@
+package builtin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Column arranges the lines of stdin for reading, like util-linux column.
+// By default the lines are filled into as many columns as fit the output
+// width (-c, 80 columns), down the columns or across them with -x. With -t
+// the fields of each line are aligned as a table: -s sets the characters
+// that separate fields (runs of white space by default; with -s empty
+// fields are kept), -o the text between output columns, -R the columns to
+// align right and -N names for the columns, printed as a header.
+func Column(args []string, stdin io.Reader, stdout io.Writer) error {
+	table, fillRows := false, false
+	separator, outputSeparator := "", "  "
+	width := 80
+	var right, names string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			if i+1 < len(args) {
+				return fmt.Errorf("column: %s: file operands are not supported; pipe the input instead", args[i+1])
+			}
+			continue
+		case arg == "-":
+			continue
+		case !strings.HasPrefix(arg, "-"):
+			return fmt.Errorf("column: %s: file operands are not supported; pipe the input instead", arg)
+		case arg == "--table":
+			arg = "-t"
+		case arg == "--fillrows":
+			arg = "-x"
+		case strings.HasPrefix(arg, "--separator="):
+			separator = strings.TrimPrefix(arg, "--separator=")
+			continue
+		case strings.HasPrefix(arg, "--output-separator="):
+			outputSeparator = strings.TrimPrefix(arg, "--output-separator=")
+			continue
+		case strings.HasPrefix(arg, "--output-width="):
+			arg = "-c" + strings.TrimPrefix(arg, "--output-width=")
+		case strings.HasPrefix(arg, "--table-right="):
+			arg = "-R" + strings.TrimPrefix(arg, "--table-right=")
+		case strings.HasPrefix(arg, "--table-columns="):
+			arg = "-N" + strings.TrimPrefix(arg, "--table-columns=")
+		case strings.HasPrefix(arg, "--"):
+			return fmt.Errorf("column: %s: invalid option", arg)
+		}
+
+		for k := 1; k < len(arg); k++ {
+			switch c := arg[k]; c {
+			case 't':
+				table = true
+			case 'x':
+				fillRows = true
+			case 's', 'o', 'c', 'R', 'N':
+				value := arg[k+1:]
+				if value == "" {
+					if i+1 == len(args) {
+						return fmt.Errorf("column: -%c: missing argument", c)
+					}
+					i++
+					value = args[i]
+				}
+				switch c {
+				case 's':
+					separator = value
+				case 'o':
+					outputSeparator = value
+				case 'c':
+					n, err := strconv.Atoi(value)
+					if err != nil || n < 1 {
+						return fmt.Errorf("column: invalid output width: '%s'", value)
+					}
+					width = n
+				case 'R':
+					right = value
+				case 'N':
+					names = value
+				}
+				k = len(arg)
+			default:
+				return fmt.Errorf("column: -%c: invalid option", c)
+			}
+		}
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(stdin)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		// Empty lines are left out, as util-linux column does
+		if line := scanner.Text(); strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("column: %w", err)
+	}
+
+	out := bufio.NewWriter(stdout)
+	if table {
+		if err := columnTable(out, lines, separator, outputSeparator, right, names); err != nil {
+			return err
+		}
+		return out.Flush()
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	// Each column is as wide as the widest line, rounded up to a tab stop
+	columnWidth := 0
+	for _, line := range lines {
+		if w := textWidth(line); w > columnWidth {
+			columnWidth = w
+		}
+	}
+	columnWidth = (columnWidth + 8) &^ 7
+	if columnWidth > width {
+		for _, line := range lines {
+			out.WriteString(line)
+			out.WriteByte('\n')
+		}
+		return out.Flush()
+	}
+	columns := width / columnWidth
+	rows := (len(lines) + columns - 1) / columns
+	for row := 0; row < rows; row++ {
+		used, end := 0, columnWidth
+		for col := 0; col < columns; col++ {
+			index := col*rows + row
+			next := (col+1)*rows + row
+			if fillRows {
+				index, next = row*columns+col, row*columns+col+1
+			}
+			out.WriteString(lines[index])
+			used += textWidth(lines[index])
+			if next >= len(lines) || col+1 == columns {
+				break
+			}
+			for stop := (used + 8) &^ 7; stop <= end; stop = (used + 8) &^ 7 {
+				out.WriteByte('\t')
+				used = stop
+			}
+			end += columnWidth
+		}
+		out.WriteByte('\n')
+	}
+	return out.Flush()
+}
+
+// columnTable writes the lines as a table of aligned columns
+func columnTable(out *bufio.Writer, lines []string, separator, outputSeparator, right, names string) error {
+	var rows [][]string
+	if names != "" {
+		rows = append(rows, strings.Split(names, ","))
+	}
+	for _, line := range lines {
+		if separator == "" {
+			rows = append(rows, strings.Fields(line))
+			continue
+		}
+		var row []string
+		start := 0
+		for i, r := range line {
+			if strings.ContainsRune(separator, r) {
+				row = append(row, line[start:i])
+				start = i + len(string(r))
+			}
+		}
+		rows = append(rows, append(row, line[start:]))
+	}
+
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			if w := textWidth(cell); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+
+	alignRight := make([]bool, len(widths))
+	if right != "" {
+		var header []string
+		if names != "" {
+			header = rows[0]
+		}
+		for _, column := range strings.Split(right, ",") {
+			n, err := strconv.Atoi(column)
+			if err != nil {
+				n = 0
+				for i, name := range header {
+					if name == column {
+						n = i + 1
+					}
+				}
+			}
+			if n < 1 {
+				return fmt.Errorf("column: undefined column name '%s'", column)
+			}
+			if n <= len(alignRight) {
+				alignRight[n-1] = true
+			}
+		}
+	}
+
+	for _, row := range rows {
+		for i, cell := range row {
+			padding := strings.Repeat(" ", widths[i]-textWidth(cell))
+			if i > 0 {
+				out.WriteString(outputSeparator)
+			}
+			switch {
+			case alignRight[i]:
+				out.WriteString(padding + cell)
+			case i+1 < len(row):
+				out.WriteString(cell + padding)
+			default:
+				out.WriteString(cell) // The last column is not padded
+			}
+		}
+		out.WriteByte('\n')
+	}
+	return nil
+}
+
+// tabStops are the tab stops of expand and unexpand: the listed columns,
+// then every `every` columns after the last one if every is set
+type tabStops struct {
+	stops    []int
+	every    int
+	relative bool // every counts from the last listed stop (+N) rather than from 0 (/N)
+}
+
+// parseTabStops parses a -t value: a tab size, or a list of tab stops
+// separated by commas or blanks whose last item may be /N or +N
+func parseTabStops(cmd, value string) (tabStops, error) {
+	items := strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+	var t tabStops
+	for i, item := range items {
+		suffix := item[0] == '/' || item[0] == '+'
+		if suffix && i+1 < len(items) {
+			return t, fmt.Errorf("%s: '%c' specifier only allowed with the last value", cmd, item[0])
+		}
+		number := item
+		if suffix {
+			number = item[1:]
+		}
+		n, err := strconv.Atoi(number)
+		switch {
+		case err != nil || n < 0:
+			return t, fmt.Errorf("%s: tab size contains invalid character(s): '%s'", cmd, item)
+		case n == 0:
+			return t, fmt.Errorf("%s: tab size cannot be 0", cmd)
+		case suffix:
+			t.every, t.relative = n, item[0] == '+'
+		case len(t.stops) > 0 && n <= t.stops[len(t.stops)-1]:
+			return t, fmt.Errorf("%s: tab sizes must be ascending", cmd)
+		default:
+			t.stops = append(t.stops, n)
+		}
+	}
+	switch {
+	case len(items) == 0:
+		return t, fmt.Errorf("%s: tab size contains invalid character(s): '%s'", cmd, value)
+	case len(t.stops) == 1 && t.every == 0:
+		t.every, t.stops = t.stops[0], nil // A single number is the tab size
+	}
+	return t, nil
+}
+
+// next returns the first tab stop after column col, or -1 if there is none
+func (t tabStops) next(col int) int {
+	for _, stop := range t.stops {
+		if stop > col {
+			return stop
+		}
+	}
+	if t.every == 0 {
+		return -1
+	}
+	base := 0
+	if t.relative && len(t.stops) > 0 {
+		base = t.stops[len(t.stops)-1]
+	}
+	return base + ((col-base)/t.every+1)*t.every
+}
+
+// Expand converts tabs in stdin to spaces, like GNU expand. -t sets the tab
+// size (8 by default) or a list of tab stops; a tab after the last listed
+// stop becomes one space. With -i only tabs before the first non-blank
+// character of a line are converted.
+func Expand(args []string, stdin io.Reader, stdout io.Writer) error {
+	stops, flags, err := parseTabArgs("expand", args, "i", map[string]byte{"--initial": 'i'})
+	if err != nil {
+		return err
+	}
+	initial := flags['i']
+
+	in := bufio.NewReader(stdin)
+	out := bufio.NewWriter(stdout)
+	for {
+		line, err := in.ReadString('\n')
+		col, leading := 0, true
+		for _, r := range line {
+			switch r {
+			case '\t':
+				next := stops.next(col)
+				if next < 0 {
+					next = col + 1
+				}
+				if leading || !initial {
+					out.WriteString(strings.Repeat(" ", next-col))
+					col = next
+					continue
+				}
+				col = next
+			case ' ':
+				col++
+			case '\b':
+				if col > 0 {
+					col--
+				}
+			case '\n':
+			default:
+				leading = false
+				col += runeWidth(r)
+			}
+			out.WriteRune(r)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("expand: %w", err)
+		}
+	}
+	return out.Flush()
+}
+
+// Unexpand converts blanks in stdin to tabs, like GNU unexpand: by default
+// only the blanks at the start of each line, with -a (or -t) all runs of
+// two or more blanks that reach a tab stop. -t sets the tab size or a list
+// of tab stops; --first-only converts only leading blanks even with -t.
+func Unexpand(args []string, stdin io.Reader, stdout io.Writer) error {
+	stops, flags, err := parseTabArgs("unexpand", args, "a", map[string]byte{"--all": 'a', "--first-only": 'f'})
+	if err != nil {
+		return err
+	}
+	all := (flags['a'] || flags['t']) && !flags['f']
+
+	in := bufio.NewReader(stdin)
+	out := bufio.NewWriter(stdout)
+	for {
+		line, err := in.ReadString('\n')
+		col, converting := 0, true
+		var pending strings.Builder
+		for _, r := range line {
+			if converting && (r == ' ' || r == '\t') {
+				if r == ' ' {
+					col++
+				} else if next := stops.next(col); next >= 0 {
+					col = next
+				} else {
+					col++
+				}
+				pending.WriteRune(r)
+				if stops.next(col-1) == col {
+					if pending.String() == " " {
+						out.WriteByte(' ') // A single space is not worth a tab
+					} else {
+						out.WriteByte('\t')
+					}
+					pending.Reset()
+				}
+				continue
+			}
+			out.WriteString(pending.String())
+			pending.Reset()
+			switch r {
+			case '\b':
+				if col > 0 {
+					col--
+				}
+			case '\n':
+			default:
+				col += runeWidth(r)
+				converting = all
+			}
+			out.WriteRune(r)
+		}
+		out.WriteString(pending.String())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("unexpand: %w", err)
+		}
+	}
+	return out.Flush()
+}
+
+// parseTabArgs parses the options of expand and unexpand: -t LIST,
+// --tabs=LIST and -N, which set the tab stops, and the single letter
+// options in letters and long options in long. The options seen are
+// returned, 't' if tab stops were given.
+func parseTabArgs(cmd string, args []string, letters string, long map[string]byte) (tabStops, map[byte]bool, error) {
+	stops := tabStops{every: 8}
+	seen := make(map[byte]bool)
+	tabs := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if c, ok := long[arg]; ok {
+			seen[c] = true
+			continue
+		}
+		switch {
+		case arg == "--":
+			if i+1 < len(args) {
+				return stops, nil, fmt.Errorf("%s: %s: file operands are not supported; pipe the input instead", cmd, args[i+1])
+			}
+			continue
+		case arg == "-":
+			continue
+		case !strings.HasPrefix(arg, "-"):
+			return stops, nil, fmt.Errorf("%s: %s: file operands are not supported; pipe the input instead", cmd, arg)
+		case strings.HasPrefix(arg, "--tabs="):
+			arg = "-t" + strings.TrimPrefix(arg, "--tabs=")
+		case strings.HasPrefix(arg, "--"):
+			return stops, nil, fmt.Errorf("%s: %s: invalid option", cmd, arg)
+		case arg[1] >= '0' && arg[1] <= '9':
+			arg = "-t" + arg[1:] // -N, or -N,M,...
+		}
+
+		for k := 1; k < len(arg); k++ {
+			c := arg[k]
+			if strings.IndexByte(letters, c) >= 0 {
+				seen[c] = true
+				continue
+			}
+			if c != 't' {
+				return stops, nil, fmt.Errorf("%s: -%c: invalid option", cmd, c)
+			}
+			value := arg[k+1:]
+			if value == "" {
+				if i+1 == len(args) {
+					return stops, nil, fmt.Errorf("%s: -t: missing argument", cmd)
+				}
+				i++
+				value = args[i]
+			}
+			if tabs != "" {
+				tabs += ","
+			}
+			tabs += value
+			k = len(arg)
+		}
+	}
+	if tabs != "" {
+		var err error
+		if stops, err = parseTabStops(cmd, tabs); err != nil {
+			return stops, nil, err
+		}
+		seen['t'] = true
+	}
+	return stops, seen, nil
+}
+
+// Fold wraps the lines of stdin so none is wider than -w columns (80 by
+// default), like GNU fold. Tabs advance to the next multiple of eight; -b
+// counts bytes instead of columns and -s breaks after the last blank that
+// fits, rather than in the middle of a word.
+func Fold(args []string, stdin io.Reader, stdout io.Writer) error {
+	width := 80
+	countBytes, atSpaces := false, false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			if i+1 < len(args) {
+				return fmt.Errorf("fold: %s: file operands are not supported; pipe the input instead", args[i+1])
+			}
+			continue
+		case arg == "-":
+			continue
+		case !strings.HasPrefix(arg, "-"):
+			return fmt.Errorf("fold: %s: file operands are not supported; pipe the input instead", arg)
+		case arg == "--bytes":
+			arg = "-b"
+		case arg == "--spaces":
+			arg = "-s"
+		case strings.HasPrefix(arg, "--width="):
+			arg = "-w" + strings.TrimPrefix(arg, "--width=")
+		case strings.HasPrefix(arg, "--"):
+			return fmt.Errorf("fold: %s: invalid option", arg)
+		case arg[1] >= '0' && arg[1] <= '9':
+			arg = "-w" + arg[1:] // -WIDTH
+		}
+
+		for k := 1; k < len(arg); k++ {
+			switch c := arg[k]; c {
+			case 'b':
+				countBytes = true
+			case 's':
+				atSpaces = true
+			case 'w':
+				value := arg[k+1:]
+				if value == "" {
+					if i+1 == len(args) {
+						return fmt.Errorf("fold: -w: missing argument")
+					}
+					i++
+					value = args[i]
+				}
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 1 {
+					return fmt.Errorf("fold: invalid number of columns: '%s'", value)
+				}
+				width = n
+				k = len(arg)
+			default:
+				return fmt.Errorf("fold: -%c: invalid option", c)
+			}
+		}
+	}
+
+	advance := func(col int, r rune) int {
+		switch {
+		case countBytes:
+			return col + len(string(r))
+		case r == '\t':
+			return col + 8 - col%8
+		case r == '\b':
+			if col > 0 {
+				return col - 1
+			}
+			return 0
+		case r == '\r':
+			return 0
+		}
+		return col + runeWidth(r)
+	}
+
+	in := bufio.NewReader(stdin)
+	out := bufio.NewWriter(stdout)
+	for {
+		line, err := in.ReadString('\n')
+		text := strings.TrimSuffix(line, "\n")
+		var pending []rune
+		col := 0
+		for _, r := range text {
+			next := advance(col, r)
+			if next > width && len(pending) > 0 {
+				blank := -1
+				if atSpaces {
+					for i := len(pending) - 1; i >= 0; i-- {
+						if pending[i] == ' ' || pending[i] == '\t' {
+							blank = i
+							break
+						}
+					}
+				}
+				out.WriteString(string(pending[:blank+1]))
+				if blank < 0 {
+					out.WriteString(string(pending))
+					pending = pending[:0]
+				} else {
+					pending = append([]rune(nil), pending[blank+1:]...)
+				}
+				out.WriteByte('\n')
+				col = 0
+				for _, p := range pending {
+					col = advance(col, p)
+				}
+				next = advance(col, r)
+			}
+			pending = append(pending, r)
+			col = next
+		}
+		out.WriteString(string(pending))
+		if len(text) < len(line) {
+			out.WriteByte('\n')
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("fold: %w", err)
+		}
+	}
+	return out.Flush()
+}
+
+// fmtWord is a word of a paragraph and the number of spaces that follow it
+type fmtWord struct {
+	text  string
+	space int
+}
+
+// Fmt fills and joins the paragraphs of stdin so lines are at most -w
+// columns wide (75 by default), like GNU fmt. Paragraphs are separated by
+// blank lines and by changes of indentation, which is kept. -s only splits
+// long lines, -u puts one space between words and two after sentences, -c
+// indents the rest of a paragraph like its second line and -p PREFIX only
+// fills lines starting with PREFIX, keeping it on the filled lines.
+func Fmt(args []string, stdin io.Reader, stdout io.Writer) error {
+	width := 75
+	splitOnly, uniform, crown := false, false, false
+	prefix := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			if i+1 < len(args) {
+				return fmt.Errorf("fmt: %s: file operands are not supported; pipe the input instead", args[i+1])
+			}
+			continue
+		case arg == "-":
+			continue
+		case !strings.HasPrefix(arg, "-"):
+			return fmt.Errorf("fmt: %s: file operands are not supported; pipe the input instead", arg)
+		case arg == "--split-only":
+			arg = "-s"
+		case arg == "--uniform-spacing":
+			arg = "-u"
+		case arg == "--crown-margin":
+			arg = "-c"
+		case strings.HasPrefix(arg, "--width="):
+			arg = "-w" + strings.TrimPrefix(arg, "--width=")
+		case strings.HasPrefix(arg, "--prefix="):
+			prefix = strings.TrimPrefix(arg, "--prefix=")
+			continue
+		case strings.HasPrefix(arg, "--"):
+			return fmt.Errorf("fmt: %s: invalid option", arg)
+		case arg[1] >= '0' && arg[1] <= '9':
+			arg = "-w" + arg[1:] // -WIDTH
+		}
+
+		for k := 1; k < len(arg); k++ {
+			switch c := arg[k]; c {
+			case 's':
+				splitOnly = true
+			case 'u':
+				uniform = true
+			case 'c':
+				crown = true
+			case 'w', 'p':
+				value := arg[k+1:]
+				if value == "" {
+					if i+1 == len(args) {
+						return fmt.Errorf("fmt: -%c: missing argument", c)
+					}
+					i++
+					value = args[i]
+				}
+				if c == 'p' {
+					prefix = value
+				} else {
+					n, err := strconv.Atoi(value)
+					if err != nil || n < 1 {
+						return fmt.Errorf("fmt: invalid width: '%s'", value)
+					}
+					width = n
+				}
+				k = len(arg)
+			default:
+				return fmt.Errorf("fmt: -%c: invalid option", c)
+			}
+		}
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(stdin)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("fmt: %w", err)
+	}
+
+	// split separates a line into the part kept as it is (the prefix and the
+	// blanks before it), the indentation and the text; ok is false for lines
+	// that are not filled
+	split := func(line string) (lead, indent, text string, ok bool) {
+		if prefix != "" {
+			trimmed := strings.TrimLeft(line, " \t")
+			if !strings.HasPrefix(trimmed, prefix) {
+				return "", "", "", false
+			}
+			lead, line = line[:len(line)-len(trimmed)+len(prefix)], trimmed[len(prefix):]
+		}
+		text = strings.TrimLeft(line, " \t")
+		indent = line[:len(line)-len(text)]
+		return lead, indent, strings.TrimRight(text, " \t"), text != ""
+	}
+
+	out := bufio.NewWriter(stdout)
+	for i := 0; i < len(lines); {
+		lead, indent, text, ok := split(lines[i])
+		if !ok {
+			out.WriteString(lines[i])
+			out.WriteByte('\n')
+			i++
+			continue
+		}
+		words := fmtWords(nil, text, uniform)
+		restIndent := indent
+		j := i + 1
+		for ; !splitOnly && j < len(lines); j++ {
+			nextLead, nextIndent, nextText, ok := split(lines[j])
+			// Lines with another indentation start a new paragraph, unless the
+			// margin is a crown
+			if !ok || nextLead != lead || !crown && nextIndent != indent {
+				break
+			}
+			if crown && j == i+1 {
+				restIndent = nextIndent
+			}
+			words = fmtWords(words, nextText, uniform)
+		}
+		fmtFill(out, words, lead+indent, lead+restIndent, width)
+		i = j
+	}
+	return out.Flush()
+}
+
+// fmtWords appends the words of a line of text. Words keep the spaces that
+// follow them, but with uniform spacing, and at the end of a line, a word
+// is followed by one space or, if it ends a sentence, two.
+func fmtWords(words []fmtWord, text string, uniform bool) []fmtWord {
+	for text != "" {
+		end := strings.IndexAny(text, " \t")
+		if end < 0 {
+			end = len(text)
+		}
+		word := fmtWord{text: text[:end]}
+		rest := strings.TrimLeft(text[end:], " \t")
+		gap := len(text) - end - len(rest)
+		bare := strings.TrimRight(word.text, `)]"'`)
+		sentence := bare != "" && strings.IndexByte(".?!", bare[len(bare)-1]) >= 0
+		switch {
+		case rest == "" || uniform:
+			word.space = 1
+			if sentence && (rest == "" || gap >= 2) {
+				word.space = 2
+			}
+		default:
+			word.space = gap
+		}
+		words = append(words, word)
+		text = rest
+	}
+	return words
+}
+
+// fmtFill writes the words filled into lines at most width columns wide; a
+// word wider than that gets a line of its own
+func fmtFill(out *bufio.Writer, words []fmtWord, firstIndent, restIndent string, width int) {
+	indent := firstIndent
+	for len(words) > 0 {
+		out.WriteString(indent)
+		used := indentWidth(indent) + textWidth(words[0].text)
+		out.WriteString(words[0].text)
+		k := 1
+		for ; k < len(words); k++ {
+			w := textWidth(words[k].text)
+			if used+words[k-1].space+w > width {
+				break
+			}
+			out.WriteString(strings.Repeat(" ", words[k-1].space))
+			out.WriteString(words[k].text)
+			used += words[k-1].space + w
+		}
+		out.WriteByte('\n')
+		words = words[k:]
+		indent = restIndent
+	}
+}
+
+// indentWidth returns the width of indentation, tabs advancing to the next
+// multiple of eight
+func indentWidth(indent string) int {
+	col := 0
+	for _, r := range indent {
+		if r == '\t' {
+			col += 8 - col%8
+		} else {
+			col += runeWidth(r)
+		}
+	}
+	return col
+}
+
+// textWidth returns the number of terminal columns s takes
+func textWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		n += runeWidth(r)
+	}
+	return n
+}
+
+// runeWidth returns the number of terminal columns r takes: two for East
+// Asian wide characters, none for combining marks and one otherwise
+func runeWidth(r rune) int {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case r >= 0x1100 && r <= 0x115F, // Hangul Jamo
+		r >= 0x2E80 && r <= 0xA4CF && r != 0x303F, // CJK ... Yi
+		r >= 0xAC00 && r <= 0xD7A3,                // Hangul syllables
+		r >= 0xF900 && r <= 0xFAFF,                // CJK compatibility ideographs
+		r >= 0xFE30 && r <= 0xFE4F,                // CJK compatibility forms
+		r >= 0xFF00 && r <= 0xFF60,                // Fullwidth forms
+		r >= 0xFFE0 && r <= 0xFFE6,
+		r >= 0x1F300 && r <= 0x1F64F, // Emoji
+		r >= 0x1F900 && r <= 0x1F9FF,
+		r >= 0x20000 && r <= 0x3FFFD:
+		return 2
+	}
+	return 1
+}
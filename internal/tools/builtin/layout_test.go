@@ -0,0 +1,227 @@
+package builtin
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLayoutCommands(t *testing.T) {
+	tests := []struct {
+		name           string
+		command        CommandFunc
+		args           []string
+		input          string
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name:           "column fills columns",
+			command:        Column,
+			args:           []string{"-c", "20"},
+			input:          "a\nbb\nccc\ndddd\ne\n",
+			expectedOutput: "a\tdddd\nbb\te\nccc\n",
+		},
+		{
+			name:           "column fills rows",
+			command:        Column,
+			args:           []string{"-x", "-c20"},
+			input:          "a\nbb\nccc\ndddd\ne\n",
+			expectedOutput: "a\tbb\nccc\tdddd\ne\n",
+		},
+		{
+			name:           "column table",
+			command:        Column,
+			args:           []string{"-t"},
+			input:          "name age\nalice 30\n\nbob 4\n",
+			expectedOutput: "name   age\nalice  30\nbob    4\n",
+		},
+		{
+			name:           "column table right aligned with header",
+			command:        Column,
+			args:           []string{"-t", "-N", "NAME,AGE", "-R", "AGE"},
+			input:          "alice 30\nbob 4\n",
+			expectedOutput: "NAME   AGE\nalice   30\nbob      4\n",
+		},
+		{
+			name:           "column table with separators keeps empty cells",
+			command:        Column,
+			args:           []string{"-ts,", "-o", " | "},
+			input:          "a,,c\nlong,b,c\n",
+			expectedOutput: "a    |   | c\nlong | b | c\n",
+		},
+		{
+			name:           "column table of wide characters",
+			command:        Column,
+			args:           []string{"-t"},
+			input:          "名前 年齢\nbob 4\n",
+			expectedOutput: "名前  年齢\nbob   4\n",
+		},
+		{
+			name:          "column file operand",
+			command:       Column,
+			args:          []string{"report.txt"},
+			expectedError: "column: report.txt: file operands are not supported",
+		},
+		{
+			name:           "expand",
+			command:        Expand,
+			input:          "a\tb\n\tc",
+			expectedOutput: "a       b\n        c",
+		},
+		{
+			name:           "expand tab size",
+			command:        Expand,
+			args:           []string{"-4"},
+			input:          "ab\tc\n",
+			expectedOutput: "ab  c\n",
+		},
+		{
+			name:           "expand tab stop list",
+			command:        Expand,
+			args:           []string{"-t", "2,5"},
+			input:          "\ta\tb\tc\n",
+			expectedOutput: "  a  b c\n",
+		},
+		{
+			name:           "expand repeating after a list",
+			command:        Expand,
+			args:           []string{"--tabs=2,+4"},
+			input:          "\ta\tb\tc\n",
+			expectedOutput: "  a   b   c\n",
+		},
+		{
+			name:           "expand initial tabs",
+			command:        Expand,
+			args:           []string{"-i", "-t", "4"},
+			input:          "\tx\ty\n",
+			expectedOutput: "    x\ty\n",
+		},
+		{
+			name:          "expand descending tab stops",
+			command:       Expand,
+			args:          []string{"-t", "4,2"},
+			expectedError: "expand: tab sizes must be ascending",
+		},
+		{
+			name:           "unexpand leading blanks",
+			command:        Unexpand,
+			input:          "        a       b\n",
+			expectedOutput: "\ta       b\n",
+		},
+		{
+			name:           "unexpand all blanks",
+			command:        Unexpand,
+			args:           []string{"-a"},
+			input:          "        a       b\nabcdefg h\n",
+			expectedOutput: "\ta\tb\nabcdefg h\n",
+		},
+		{
+			name:           "unexpand tab size",
+			command:        Unexpand,
+			args:           []string{"-t", "4", "--first-only"},
+			input:          "      x   y\n",
+			expectedOutput: "\t  x   y\n",
+		},
+		{
+			name:           "fold",
+			command:        Fold,
+			args:           []string{"-w", "4"},
+			input:          "abcdefghij\nxy",
+			expectedOutput: "abcd\nefgh\nij\nxy",
+		},
+		{
+			name:           "fold at spaces",
+			command:        Fold,
+			args:           []string{"-s", "-w10"},
+			input:          "the quick brown fox\n",
+			expectedOutput: "the quick \nbrown fox\n",
+		},
+		{
+			name:           "fold counts tabs and wide characters",
+			command:        Fold,
+			args:           []string{"-4"},
+			input:          "a\tb\n日本語です\n",
+			expectedOutput: "a\n\t\nb\n日本\n語で\nす\n",
+		},
+		{
+			name:           "fold bytes",
+			command:        Fold,
+			args:           []string{"-b", "-w", "3"},
+			input:          "a\tbcd\n",
+			expectedOutput: "a\tb\ncd\n",
+		},
+		{
+			name:           "fmt",
+			command:        Fmt,
+			args:           []string{"-w", "12"},
+			input:          "one two\nthree four five\n\nsix\n",
+			expectedOutput: "one two\nthree four\nfive\n\nsix\n",
+		},
+		{
+			name:           "fmt keeps indentation",
+			command:        Fmt,
+			input:          "  a b\n  c\nd\n",
+			expectedOutput: "  a b c\nd\n",
+		},
+		{
+			name:           "fmt spacing",
+			command:        Fmt,
+			input:          "Hi.  There   now.\nEnd\n",
+			expectedOutput: "Hi.  There   now.  End\n",
+		},
+		{
+			name:           "fmt uniform spacing",
+			command:        Fmt,
+			args:           []string{"-u"},
+			input:          "Hi.  There   now.\nEnd\n",
+			expectedOutput: "Hi.  There now.  End\n",
+		},
+		{
+			name:           "fmt split only",
+			command:        Fmt,
+			args:           []string{"-s", "-5"},
+			input:          "aaa bbb\nccc\n",
+			expectedOutput: "aaa\nbbb\nccc\n",
+		},
+		{
+			name:           "fmt prefix",
+			command:        Fmt,
+			args:           []string{"-p", "#"},
+			input:          "# one\n# two\ncode\n",
+			expectedOutput: "# one two\ncode\n",
+		},
+		{
+			name:           "fmt crown margin",
+			command:        Fmt,
+			args:           []string{"-c", "-w", "14"},
+			input:          "  first line\nsecond\nthird\n",
+			expectedOutput: "  first line\nsecond third\n",
+		},
+		{
+			name:          "fmt invalid width",
+			command:       Fmt,
+			args:          []string{"-w", "x"},
+			expectedError: "fmt: invalid width: 'x'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output bytes.Buffer
+			err := test.command(test.args, strings.NewReader(test.input), &output)
+			if test.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Errorf("expected error containing %q, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != test.expectedOutput {
+				t.Errorf("expected output %q, got %q", test.expectedOutput, output.String())
+			}
+		})
+	}
+}
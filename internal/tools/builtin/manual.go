@@ -0,0 +1,928 @@
+package builtin
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// manual is the reference entry of a builtin: how it is called, what its
+// options do, a few examples and the mistakes it commonly reports
+type manual struct {
+	Synopsis string
+	Summary  string
+	Options  []manualOption
+	Examples []manualExample
+	Errors   []manualError
+}
+
+// manualOption documents one option or operand form
+type manualOption struct {
+	Flag        string
+	Description string
+}
+
+// manualExample is a command line with what it does
+type manualExample struct {
+	Command     string
+	Description string
+}
+
+// manualError is an error a builtin reports, or a symptom of misuse, with
+// the way to correct the call
+type manualError struct {
+	Message string
+	Fix     string
+}
+
+// manuals holds the manual of every builtin in Commands
+var manuals = map[string]manual{
+	"cat": {
+		Synopsis: "cat [FILE...]",
+		Summary:  "copy stdin, or the named virtual files in turn, to stdout",
+		Examples: []manualExample{
+			{"cat notes.txt", "Print a virtual file"},
+			{"cat header.csv rows.csv", "Concatenate files"},
+		},
+		Errors: []manualError{
+			{"cat: FILE: ...", "the virtual file does not exist; list them with vls"},
+		},
+	},
+	"grep": {
+		Synopsis: "grep [-EFivwxnco] [-m N] [-A N] [-B N] [-C N] [-e PATTERN]... [PATTERN]",
+		Summary:  "print the lines of stdin that match a pattern (basic regex unless -E or -F)",
+		Options: []manualOption{
+			{"-E", "extended regex: | + ? () without backslashes"},
+			{"-F", "fixed strings"},
+			{"-e PATTERN", "add a pattern; may be repeated"},
+			{"-i", "ignore case"},
+			{"-v", "print the lines that do not match"},
+			{"-w, -x", "match whole words or whole lines"},
+			{"-n", "prefix lines with their numbers"},
+			{"-c", "print the number of matching lines"},
+			{"-o", "print only the matching parts"},
+			{"-m N", "stop after N matching lines"},
+			{"-A N, -B N, -C N", "N lines of context after, before or around matches"},
+		},
+		Examples: []manualExample{
+			{"grep -E -C2 'panic|fatal'", "Matches with two lines of context"},
+			{"grep -c ERROR", "Count the error lines"},
+			{"grep -o '[0-9]\\+\\.[0-9]\\+'", "Print each decimal number"},
+		},
+		Errors: []manualError{
+			{"grep: missing pattern", "give the pattern as the first operand or with -e"},
+			{"grep: FILE: file operands are not supported; pipe the input instead", "use grep PATTERN < FILE"},
+			{"no match for 'a|b'", "alternation needs -E, or \\| in a basic regex"},
+		},
+	},
+	"sed": {
+		Synopsis: "sed [-n] [-E] [-e SCRIPT]... [SCRIPT]",
+		Summary:  "edit a stream of lines with s, d, p and the other sed commands (basic regex unless -E)",
+		Options: []manualOption{
+			{"-n", "print only what p and s///p print"},
+			{"-E, -r", "extended regex"},
+			{"-e SCRIPT", "add a script; may be repeated"},
+		},
+		Examples: []manualExample{
+			{"sed 's/old/new/g'", "Replace every occurrence"},
+			{"sed -E 's/([0-9]+)-([0-9]+)/\\2-\\1/'", "Swap two numbers with groups"},
+			{"sed -n '10,20p'", "Print lines 10 to 20"},
+			{"sed '/^#/d'", "Delete comment lines"},
+		},
+		Errors: []manualError{
+			{"sed: -i: in-place editing is not supported; redirect the output instead", "write sed SCRIPT < in > out"},
+			{"sed: -e expression, char N: unterminated `/' delimited text", "escape a / in the pattern or replacement, or use another delimiter as in s|a/b|c|"},
+			{"\\1 is not replaced", "groups are \\( \\) in a basic regex; use -E for ( )"},
+		},
+	},
+	"head": {
+		Synopsis: "head [-N]",
+		Summary:  "print the first N lines of stdin (default 10)",
+		Options: []manualOption{
+			{"-N", "print N lines, as in head -20"},
+		},
+		Examples: []manualExample{
+			{"head -5", "The first five lines"},
+			{"sort -rn | head -3", "The three largest numbers"},
+		},
+		Errors: []manualError{
+			{"head -n 5 prints 10 lines", "only the -N form is understood; write head -5"},
+		},
+	},
+	"tail": {
+		Synopsis: "tail [-n N | -n +N | -N] [-f [--timeout SECONDS]]",
+		Summary:  "print the last lines of stdin, or follow what is appended to it",
+		Options: []manualOption{
+			{"-n N, -N", "print the last N lines (default 10)"},
+			{"-n +N", "print from line N on"},
+			{"-f", "follow the input until it ends"},
+			{"--timeout SECONDS", "with -f, stop after no input for SECONDS"},
+		},
+		Examples: []manualExample{
+			{"tail -n 20", "The last 20 lines"},
+			{"tail -n +2", "Skip a header line"},
+			{"long_job | tail -f --timeout 30", "Watch a command until it is quiet for 30 seconds"},
+		},
+		Errors: []manualError{
+			{"tail: invalid number of lines: \"x\"", "N must be a number, optionally with a leading +"},
+			{"tail: FILE: file operands are not supported; pipe the input instead", "use tail < FILE"},
+		},
+	},
+	"sort": {
+		Synopsis: "sort [-bfnrsu] [-t SEP] [-k POS1[,POS2]]...",
+		Summary:  "sort lines (coreutils semantics, C locale)",
+		Options: []manualOption{
+			{"-n", "compare leading numbers"},
+			{"-r", "reverse the order"},
+			{"-u", "keep only the first of lines with equal keys"},
+			{"-t SEP", "field separator (default: runs of blanks)"},
+			{"-k POS1[,POS2]", "sort on fields POS1 to POS2; a position is F[.C] with optional b, f, n or r"},
+			{"-f", "fold lower case to upper case"},
+			{"-b", "ignore leading blanks in keys"},
+			{"-s", "stable: keep the input order of equal keys"},
+		},
+		Examples: []manualExample{
+			{"sort -t, -k2,2n", "Sort CSV by the numeric second column"},
+			{"sort | uniq -c | sort -rn", "Rank lines by frequency"},
+		},
+		Errors: []manualError{
+			{"sort: -t: the separator must be a single character", "give one character, as in -t ,"},
+			{"sort: -k X: invalid key specification", "keys are field numbers counted from 1, as in -k2 or -k2,2n"},
+			{"-k2 sorts on the rest of the line", "end the key too: -k2,2"},
+		},
+	},
+	"wc": {
+		Synopsis: "wc [-l] [-w] [-c] [-m] [-L]",
+		Summary:  "count the lines, words and characters of stdin",
+		Options: []manualOption{
+			{"-l", "lines"},
+			{"-w", "words"},
+			{"-c", "characters"},
+			{"-m", "bytes"},
+			{"-L", "width of the longest line"},
+		},
+		Examples: []manualExample{
+			{"grep ERROR | wc -l", "Count the error lines"},
+		},
+	},
+	"tr": {
+		Synopsis: "tr [-cdst] SET1 [SET2]",
+		Summary:  "translate, delete or squeeze characters (GNU semantics; newlines are characters too)",
+		Options: []manualOption{
+			{"-d", "delete the characters in SET1"},
+			{"-s", "squeeze runs of a character of the last set into one"},
+			{"-c", "use the complement of SET1"},
+			{"-t", "truncate SET1 to the length of SET2"},
+			{"SET", "characters, escapes such as \\n, ranges such as a-z, classes such as [:upper:]; [c*] in SET2 repeats c"},
+		},
+		Examples: []manualExample{
+			{"tr '[:upper:]' '[:lower:]'", "Lower-case the input"},
+			{"tr -cs '[:alpha:]' '\\n'", "One word per line"},
+			{"tr -d '\\r'", "Strip carriage returns"},
+		},
+		Errors: []manualError{
+			{"tr: missing operand after \"a\"; two strings must be given when translating", "give SET2, or -d to delete"},
+			{"tr: invalid character class 'x'", "classes are alnum alpha blank cntrl digit graph lower print punct space upper xdigit"},
+		},
+	},
+	"cut": {
+		Synopsis: "cut (-f LIST | -c LIST) [-d DELIM]",
+		Summary:  "print selected fields or characters of each line",
+		Options: []manualOption{
+			{"-f LIST", "fields, as comma-separated numbers counted from 1"},
+			{"-c LIST", "characters, as comma-separated numbers counted from 1"},
+			{"-d DELIM", "field delimiter (default tab)"},
+		},
+		Examples: []manualExample{
+			{"cut -d , -f 1,3", "Columns 1 and 3 of simple CSV"},
+			{"cut -d ' ' -f 2", "The second word of each line"},
+		},
+		Errors: []manualError{
+			{"cut: missing field specification", "give -f or -c"},
+			{"cut -d, -f1 prints lines unchanged", "put the values in separate words: cut -d , -f 1"},
+			{"cut -f 2-4 prints nothing", "ranges are not understood; list the fields: -f 2,3,4, or use awk"},
+		},
+	},
+	"uniq": {
+		Synopsis: "uniq [-cdiu] [-f N] [-s N] [-w N]",
+		Summary:  "collapse runs of adjacent equal lines; sort | uniq runs as one step",
+		Options: []manualOption{
+			{"-c", "prefix lines with the number of occurrences"},
+			{"-d", "only print lines that are repeated"},
+			{"-u", "only print lines that are not repeated"},
+			{"-i", "ignore case"},
+			{"-f N", "skip the first N fields before comparing"},
+			{"-s N", "skip the first N characters before comparing"},
+			{"-w N", "compare at most N characters"},
+		},
+		Examples: []manualExample{
+			{"sort | uniq -c | sort -rn", "Count and rank lines"},
+			{"sort | uniq -d", "Lines that occur more than once"},
+		},
+		Errors: []manualError{
+			{"duplicates remain", "uniq only collapses adjacent lines; sort first"},
+			{"uniq: -f: invalid number: \"x\"", "-f, -s and -w take a count"},
+		},
+	},
+	"nl": {
+		Synopsis: "nl [-b STYLE] [-w N] [-s SEP] [-v N] [-i N] [-n FORMAT]",
+		Summary:  "number the lines of stdin; by default non-empty lines are numbered",
+		Options: []manualOption{
+			{"-b STYLE", "lines to number: a all, t non-empty, n none, pREGEX matching"},
+			{"-w N", "width of numbers (default 6)"},
+			{"-s SEP", "separator after numbers (default tab)"},
+			{"-v N, -i N", "first number and increment (default 1)"},
+			{"-n FORMAT", "ln left justified, rn right justified, rz zero padded"},
+		},
+		Examples: []manualExample{
+			{"nl -ba -w3 -s': '", "Number every line compactly"},
+		},
+		Errors: []manualError{
+			{"nl: invalid body numbering style: 'x'", "use a, t, n or pREGEX"},
+		},
+	},
+	"tee": {
+		Synopsis: "tee",
+		Summary:  "copy stdin to stdout; writing files is left to the shell's redirections",
+		Examples: []manualExample{
+			{"grep ERROR | tee", "Pass lines through unchanged"},
+		},
+	},
+	"rev": {
+		Synopsis: "rev",
+		Summary:  "reverse the characters of each line",
+		Examples: []manualExample{
+			{"rev | cut -d . -f 1 | rev", "The last dot-separated field of each line"},
+		},
+	},
+	"awk": {
+		Synopsis: "awk [-F FS] [-v VAR=VALUE]... 'PROGRAM' [VAR=VALUE...]",
+		Summary:  "pattern scanning and processing (subset: no getline, user functions or output redirection)",
+		Options: []manualOption{
+			{"-F FS", "field separator: a character, a regex, or t for a tab"},
+			{"-v VAR=VALUE", "assign a variable before BEGIN"},
+		},
+		Examples: []manualExample{
+			{"awk '{print $2}'", "The second field of each line"},
+			{"awk -F, '$3 > 100 {n++} END {print n}'", "Count CSV rows whose third column exceeds 100"},
+			{"awk '{s[$1] += $2} END {for (k in s) print k, s[k]}'", "Sum the second column by the first"},
+		},
+		Errors: []manualError{
+			{"awk: missing program", "quote the program in single quotes so the shell leaves $1 alone"},
+			{"awk: NAME: unknown function", "user functions and getline are not supported; use the builtin functions"},
+			{"awk: FILE: file operands are not supported; pipe the input instead", "use awk 'PROGRAM' < FILE"},
+		},
+	},
+	"jq": {
+		Synopsis: "jq [-rjcnsS] [--arg NAME VALUE] [--argjson NAME JSON] [FILTER]",
+		Summary:  "process a stream of JSON values (subset: no def, label or file operands)",
+		Options: []manualOption{
+			{"-r", "print strings without quotes"},
+			{"-j", "like -r, without newlines"},
+			{"-c", "compact output, one value per line"},
+			{"-n", "run the filter once on null; read the input with input and inputs"},
+			{"-s", "read all input values into one array"},
+			{"-S", "sort object keys"},
+			{"--arg NAME VALUE", "bind $NAME to a string"},
+			{"--argjson NAME JSON", "bind $NAME to a JSON value"},
+		},
+		Examples: []manualExample{
+			{"jq '.items[] | select(.ok) | .name'", "Names of the items that are ok"},
+			{"jq -r '.[] | [.id, .name] | @csv'", "An array of objects as CSV"},
+			{"jq -s 'length'", "Count the values of a JSON lines stream"},
+		},
+		Errors: []manualError{
+			{"jq: error: Cannot iterate over null", "the path does not exist; check it with keys, or use .[]? to skip"},
+			{"jq: error: Cannot index array with \"name\"", "iterate first: .[].name"},
+			{"jq: invalid JSON input: ...", "the input is not JSON; convert YAML or TOML with yaml2json or toml2json first"},
+			{"jq: syntax error: ...", "quote the filter in single quotes"},
+		},
+	},
+	"csvcut": {
+		Synopsis: "csvcut [-c COLUMNS] [-C COLUMNS] [-n] [-d DELIM] [-t] [-H]",
+		Summary:  "select CSV columns by name or number, respecting quoted fields",
+		Options: []manualOption{
+			{"-c COLUMNS", "columns to keep: names, numbers or ranges such as 2-4"},
+			{"-C COLUMNS", "columns to drop"},
+			{"-n", "list the column names"},
+			{"-d DELIM", "field delimiter (default ,)"},
+			{"-t", "tab-delimited input and output"},
+			{"-H", "the input has no header row"},
+		},
+		Examples: []manualExample{
+			{"csvcut -n", "See the column names"},
+			{"csvcut -c name,email", "Keep two columns"},
+		},
+		Errors: []manualError{
+			{"csvcut: -c: missing column list", "give the columns right after -c"},
+			{"csvcut: -n: the table has no header", "drop -H, or select columns by number"},
+		},
+	},
+	"csvgrep": {
+		Synopsis: "csvgrep [-c COLUMNS] (-m STRING | -r REGEX) [-i] [-a] [-d DELIM] [-t] [-H]",
+		Summary:  "keep the CSV rows whose columns match, with the header",
+		Options: []manualOption{
+			{"-c COLUMNS", "columns to search (default: any column)"},
+			{"-m STRING", "match a substring"},
+			{"-r REGEX", "match a regular expression"},
+			{"-i", "keep the rows that do not match"},
+			{"-a", "a match in any of the columns is enough"},
+		},
+		Examples: []manualExample{
+			{"csvgrep -c status -m failed", "Rows whose status contains failed"},
+		},
+		Errors: []manualError{
+			{"csvgrep: give exactly one of -m string or -r regex", "choose -m for text or -r for a regex"},
+		},
+	},
+	"csvjoin": {
+		Synopsis: "csvjoin -c COLUMN[,RIGHT_COLUMN] [--left | --right | --outer] [-d DELIM] [-t] [-H]",
+		Summary:  "join two CSV tables given on stdin, separated by a " + CSVJoinSeparator + " line",
+		Options: []manualOption{
+			{"-c COLUMN", "join column, or LEFT,RIGHT when the names differ"},
+			{"--left, --right, --outer", "keep the unmatched rows of the left, right or both tables"},
+		},
+		Examples: []manualExample{
+			{"{ cat < users.csv; echo " + CSVJoinSeparator + "; cat < orders.csv; } | csvjoin -c id", "Join users and orders on id"},
+		},
+		Errors: []manualError{
+			{"csvjoin: input must contain exactly one " + CSVJoinSeparator + " line", "put the separator line between the two tables"},
+			{"csvjoin: -c: the join column is required", "name the column both tables share"},
+		},
+	},
+	"rextract": {
+		Synopsis: "rextract [-1inz] [-g GROUPS | -f FORMAT | --json] PATTERN",
+		Summary:  "print what a Go (RE2) regular expression captures from each line, one result per match",
+		Options: []manualOption{
+			{"-g GROUPS", "print these groups, by number or name, separated by tabs"},
+			{"-f FORMAT", "fill a template: $1, ${1} or ${name} for groups, \\t and \\n for tab and newline"},
+			{"--json", "print an object per match with the match and each group"},
+			{"-1", "only the first match of each line"},
+			{"-i", "ignore case"},
+			{"-n", "prefix results with their line number"},
+			{"-z", "match across the whole input"},
+		},
+		Examples: []manualExample{
+			{"rextract 'user=(\\w+) ip=(\\S+)'", "User and address of each login, tab-separated"},
+			{"rextract '(?P<ts>\\S+) ERROR (?P<msg>.*)' -f '${ts}: ${msg}'", "Reformat error lines"},
+		},
+		Errors: []manualError{
+			{"rextract: group 3 does not exist; the pattern has 2", "count the ( ) groups of the pattern"},
+			{"rextract: invalid pattern: ...", "RE2 has no lookarounds or backreferences"},
+			{"$1x prints nothing", "write ${1}x: $1x names a group called 1x"},
+		},
+	},
+	"yaml2json": {
+		Synopsis: "yaml2json [-c] [-S]",
+		Summary:  "convert YAML documents on stdin to JSON, one value per document",
+		Options: []manualOption{
+			{"-c", "compact output"},
+			{"-S", "sort object keys"},
+		},
+		Examples: []manualExample{
+			{"yaml2json | jq '.spec.containers[].image'", "Images of a Kubernetes manifest"},
+		},
+		Errors: []manualError{
+			{"yaml2json: line N: ...", "check the indentation of that line; tabs are not allowed for indentation"},
+		},
+	},
+	"json2yaml": {
+		Synopsis: "json2yaml",
+		Summary:  "convert JSON values on stdin to block-style YAML, one document per value",
+		Examples: []manualExample{
+			{"yaml2json | jq '.replicas = 3' | json2yaml", "Edit YAML with jq"},
+		},
+		Errors: []manualError{
+			{"json2yaml: invalid JSON input: ...", "the input must be JSON"},
+		},
+	},
+	"toml2json": {
+		Synopsis: "toml2json [-c] [-S]",
+		Summary:  "convert a TOML document on stdin to a JSON object; dates and times become strings",
+		Options: []manualOption{
+			{"-c", "compact output"},
+			{"-S", "sort object keys"},
+		},
+		Examples: []manualExample{
+			{"toml2json | jq -r '.dependencies | keys[]'", "Dependencies of a Cargo.toml"},
+		},
+	},
+	"json2toml": {
+		Synopsis: "json2toml",
+		Summary:  "convert a JSON object on stdin to TOML",
+		Examples: []manualExample{
+			{"toml2json | jq '.server.port = 9090' | json2toml", "Edit TOML with jq"},
+		},
+		Errors: []manualError{
+			{"json2toml: the top level must be an object, not array", "wrap the value: jq '{items: .}'"},
+			{"json2toml: KEY: null cannot be represented in TOML", "remove nulls first: jq 'del(..|nulls)'"},
+		},
+	},
+	"base64": {
+		Synopsis: "base64 [-d] [-i] [-w COLS]",
+		Summary:  "encode stdin in base64, or decode it",
+		Options: []manualOption{
+			{"-d", "decode"},
+			{"-i", "when decoding, ignore characters outside the alphabet"},
+			{"-w COLS", "wrap encoded lines at COLS characters (default 76, 0 disables)"},
+		},
+		Examples: []manualExample{
+			{"base64 -w0", "Encode on one line"},
+			{"base64 -d", "Decode"},
+		},
+		Errors: []manualError{
+			{"base64: invalid input", "the data has characters outside the alphabet; add -i to skip them"},
+		},
+	},
+	"xxd": {
+		Synopsis: "xxd [-p] [-r] [-u] [-c COLS] [-g BYTES] [-l LEN] [-s OFFSET]",
+		Summary:  "make a hex dump of stdin, or convert one back with -r",
+		Options: []manualOption{
+			{"-p", "plain dump: hex digits only"},
+			{"-r", "convert a dump (or with -p, plain hex digits) back to binary"},
+			{"-c COLS", "bytes per line (default 16)"},
+			{"-g BYTES", "bytes per group (default 2)"},
+			{"-l LEN, -s OFFSET", "dump LEN bytes starting at OFFSET"},
+			{"-u", "upper case hex digits"},
+		},
+		Examples: []manualExample{
+			{"xxd -l 16", "Inspect the magic number of a file"},
+			{"xxd -r -p", "Decode hex digits"},
+		},
+		Errors: []manualError{
+			{"xxd: line N: invalid hex digits", "plain hex needs -r -p"},
+		},
+	},
+	"gzip": {
+		Synopsis: "gzip [-d] [-t] [-1..-9]",
+		Summary:  "compress stdin in gzip format, or decompress it with -d",
+		Options: []manualOption{
+			{"-d", "decompress"},
+			{"-t", "check the compressed data without printing it"},
+			{"-1..-9", "compression level, fastest to best"},
+		},
+		Examples: []manualExample{
+			{"gzip -d | grep ' 500 '", "Search a compressed log"},
+		},
+		Errors: []manualError{
+			{"gzip: not in gzip format", "check the data with xxd -l 4; gzip starts with 1f8b"},
+		},
+	},
+	"gunzip": {
+		Synopsis: "gunzip [-t]",
+		Summary:  "decompress gzip data; the same as gzip -d",
+		Examples: []manualExample{
+			{"gunzip | head", "The start of a compressed file"},
+		},
+	},
+	"zcat": {
+		Synopsis: "zcat",
+		Summary:  "decompress gzip data to stdout; the same as gzip -d",
+		Examples: []manualExample{
+			{"zcat | wc -l", "Count the lines of a compressed log"},
+		},
+	},
+	"bzip2": {
+		Synopsis: "bzip2 -d [-t]",
+		Summary:  "decompress bzip2 data; compressing is not supported",
+		Options: []manualOption{
+			{"-d", "decompress"},
+			{"-t", "check the data without printing it"},
+		},
+		Examples: []manualExample{
+			{"bzip2 -d | tail", "The end of a bzip2 file"},
+		},
+		Errors: []manualError{
+			{"bzip2: compression is not supported; use -d to decompress, or gzip", "use gzip to compress"},
+		},
+	},
+	"bunzip2": {
+		Synopsis: "bunzip2 [-t]",
+		Summary:  "decompress bzip2 data; the same as bzip2 -d",
+		Examples: []manualExample{
+			{"bunzip2 | grep ERROR", "Search a bzip2 file"},
+		},
+	},
+	"bzcat": {
+		Synopsis: "bzcat",
+		Summary:  "decompress bzip2 data to stdout; the same as bzip2 -d",
+		Examples: []manualExample{
+			{"bzcat | wc -l", "Count the lines of a bzip2 file"},
+		},
+	},
+	"xz": {
+		Synopsis: "xz -d [-t]",
+		Summary:  "decompress xz data; compressing is not supported",
+		Options: []manualOption{
+			{"-d", "decompress"},
+			{"-t", "check the data without printing it"},
+		},
+		Examples: []manualExample{
+			{"xz -d | head", "The start of an xz file"},
+		},
+		Errors: []manualError{
+			{"xz: compression is not supported; use -d to decompress, or gzip", "use gzip to compress"},
+		},
+	},
+	"unxz": {
+		Synopsis: "unxz [-t]",
+		Summary:  "decompress xz data; the same as xz -d",
+		Examples: []manualExample{
+			{"unxz | grep ERROR", "Search an xz file"},
+		},
+	},
+	"xzcat": {
+		Synopsis: "xzcat",
+		Summary:  "decompress xz data to stdout; the same as xz -d",
+		Examples: []manualExample{
+			{"xzcat | wc -l", "Count the lines of an xz file"},
+		},
+	},
+	"tar": {
+		Synopsis: "tar -t|-x [-vzjJO] [-f ARCHIVE] [--strip-components=N] [MEMBER...]",
+		Summary:  "list or extract the members of a tar archive, optionally compressed with gzip, bzip2 or xz",
+		Options: []manualOption{
+			{"-t", "list the members"},
+			{"-x", "extract the members"},
+			{"-O", "write extracted members to stdout"},
+			{"-f ARCHIVE", "read the archive from a virtual file instead of stdin"},
+			{"-v", "list in the long format"},
+			{"-z, -j, -J", "force gzip, bzip2 or xz; otherwise it is detected"},
+		},
+		Examples: []manualExample{
+			{"tar -tz", "List an archive"},
+			{"tar -xO pkg/config.json | jq .", "Read one member"},
+		},
+		Errors: []manualError{
+			{"tar: you must specify one of -t or -x", "tar only lists and extracts"},
+			{"tar: NAME: not found in archive", "list the members with tar -t to get the exact name"},
+			{"tar: this does not look like a tar archive", "check the data with xxd -l 512"},
+		},
+	},
+	"sha256sum": {
+		Synopsis: "sha256sum [-b] [--tag] | sha256sum -c [--quiet] [--status] [--ignore-missing] [--strict]",
+		Summary:  "print the SHA-256 checksum of stdin, or check a list of checksums",
+		Options: []manualOption{
+			{"-c", "read HASH  NAME lines and check each file"},
+			{"--tag", "print BSD-style lines"},
+			{"--quiet, --status", "with -c, print only failures, or nothing"},
+		},
+		Examples: []manualExample{
+			{"sha256sum < release.tar.gz", "Checksum a file"},
+			{"sha256sum -c < SHA256SUMS", "Verify a checksum list"},
+		},
+		Errors: []manualError{
+			{"sha256sum: WARNING: N computed checksums did NOT match", "those files changed since the list was made"},
+		},
+	},
+	"md5sum": {
+		Synopsis: "md5sum [-b] [--tag] | md5sum -c [--quiet] [--status]",
+		Summary:  "print the MD5 checksum of stdin, or check a list; the options are those of sha256sum",
+		Examples: []manualExample{
+			{"md5sum < data.bin", "Checksum a file"},
+		},
+	},
+	"join": {
+		Synopsis: "join [-1 FIELD] [-2 FIELD] [-t CHAR] [-a FILENUM] [-v FILENUM] [-o FORMAT] [-e EMPTY] [-i] FILE1 FILE2",
+		Summary:  "join the lines of two files sorted on a key field; one of them may be - for stdin",
+		Options: []manualOption{
+			{"-1 FIELD, -2 FIELD, -j FIELD", "join field of file 1, file 2 or both (default 1)"},
+			{"-t CHAR", "field separator (default runs of blanks)"},
+			{"-a FILENUM, -v FILENUM", "also print, or only print, unpaired lines of a file"},
+			{"-o FORMAT", "output fields, such as 0,1.2,2.3 or auto"},
+			{"-e EMPTY", "text for missing fields in -o output"},
+		},
+		Examples: []manualExample{
+			{"sort users > u; sort orders > o; join u o", "Join two tables on their first column"},
+		},
+		Errors: []manualError{
+			{"join: FILE:N: is not sorted: ...", "sort both files on the join field first"},
+			{"join: multi-character tab 'X'", "-t takes one character"},
+		},
+	},
+	"paste": {
+		Synopsis: "paste [-s] [-d LIST] [FILE...]",
+		Summary:  "merge lines side by side, separated by tabs; each - reads the next line of stdin",
+		Options: []manualOption{
+			{"-d LIST", "use the delimiters in LIST in turn"},
+			{"-s", "paste the lines of each input into one line"},
+		},
+		Examples: []manualExample{
+			{"paste -sd, -", "Join the lines of stdin with commas"},
+			{"paste - - -", "Combine every three lines into one"},
+		},
+	},
+	"comm": {
+		Synopsis: "comm [-123] [--output-delimiter=STR] [--total] FILE1 FILE2",
+		Summary:  "compare two sorted files: lines only in FILE1, only in FILE2, and in both",
+		Options: []manualOption{
+			{"-1, -2, -3", "suppress column 1, 2 or 3"},
+			{"--output-delimiter=STR", "separate columns with STR"},
+			{"--total", "print the number of lines in each column"},
+		},
+		Examples: []manualExample{
+			{"comm -12 old new", "Lines in both files"},
+			{"comm -23 old new", "Lines only in old"},
+		},
+		Errors: []manualError{
+			{"comm: file N is not in sorted order", "sort both files first"},
+		},
+	},
+	"date": {
+		Synopsis: "date [-u] [-d STRING] [+FORMAT | -I[PRECISION] | -R | --rfc-3339=PRECISION]",
+		Summary:  "print the current time, or the time a date string describes",
+		Options: []manualOption{
+			{"-u", "UTC"},
+			{"-d STRING", "2024-01-31, @SECONDS, yesterday, 3 days ago, next monday, 2024-01-31 +1 month"},
+			{"+FORMAT", "strftime format such as +%Y-%m-%d"},
+			{"-I[PRECISION]", "ISO 8601: date, hours, minutes, seconds or ns"},
+		},
+		Examples: []manualExample{
+			{"date +%F", "Today's date"},
+			{"date -d '2024-03-01 -1 day' +%F", "Date arithmetic"},
+		},
+		Errors: []manualError{
+			{"date: invalid date 'X'", "use ISO dates or phrases such as 3 days ago"},
+			{"date: X: setting the date is not supported; formats start with +", "prefix the format with +"},
+		},
+	},
+	"seq": {
+		Synopsis: "seq [-s SEP] [-w] [-f FORMAT] [FIRST [INCREMENT]] LAST",
+		Summary:  "print numbers from FIRST (default 1) to LAST",
+		Options: []manualOption{
+			{"-s SEP", "separate numbers with SEP"},
+			{"-w", "pad with zeros to equal width"},
+			{"-f FORMAT", "printf format such as %.2f"},
+		},
+		Examples: []manualExample{
+			{"seq 5", "1 to 5"},
+			{"seq 0 0.25 1", "Fractional steps"},
+		},
+		Errors: []manualError{
+			{"seq: invalid floating point argument: 'X'", "operands are numbers"},
+		},
+	},
+	"printf": {
+		Synopsis: "printf FORMAT [ARGUMENT...]",
+		Summary:  "format and print arguments; the format is reused while arguments remain",
+		Options: []manualOption{
+			{"%d %x %f %e %g %s %c", "conversions, with flags, width and precision"},
+			{"%b, %q", "string with escapes expanded, or quoted for the shell"},
+		},
+		Examples: []manualExample{
+			{"printf '%-10s %5.1f\\n' apple 1.5 pear 22", "Aligned columns"},
+		},
+		Errors: []manualError{
+			{"printf: 'X': expected a numeric value", "numeric conversions need numbers"},
+			{"printf: missing operand", "give a format"},
+		},
+	},
+	"tac": {
+		Synopsis: "tac [-s SEP] [-b]",
+		Summary:  "print the lines of stdin in reverse order",
+		Options: []manualOption{
+			{"-s SEP", "records end with SEP"},
+			{"-b", "the separator starts records"},
+		},
+		Examples: []manualExample{
+			{"tac | head -20", "The last 20 lines, newest first"},
+		},
+	},
+	"shuf": {
+		Synopsis: "shuf [-n COUNT] [-r] [-e ARG... | -i LO-HI]",
+		Summary:  "print lines in random order, or a random sample with -n",
+		Options: []manualOption{
+			{"-n COUNT", "print at most COUNT lines"},
+			{"-e ARG...", "shuffle the arguments"},
+			{"-i LO-HI", "shuffle the numbers LO to HI"},
+			{"-r", "choose with replacement; needs -n"},
+		},
+		Examples: []manualExample{
+			{"shuf -n 50", "A random sample of 50 lines"},
+		},
+		Errors: []manualError{
+			{"shuf: -r needs -n to limit the output", "add -n COUNT"},
+		},
+	},
+	"strings": {
+		Synopsis: "strings [-a] [-n MIN] [-t d|o|x] [-e s|S|l|b] [-w] [-s SEP]",
+		Summary:  "print the runs of printable characters in binary input",
+		Options: []manualOption{
+			{"-n MIN", "minimum length (default 4)"},
+			{"-t d|o|x", "prefix each string with its offset"},
+			{"-e s|S|l|b", "7-bit, 8-bit, 16-bit little or big endian characters"},
+		},
+		Examples: []manualExample{
+			{"strings -n 8 | head -50", "Text in a binary"},
+		},
+	},
+	"column": {
+		Synopsis: "column [-t] [-s SEP] [-o SEP] [-R COLUMNS] [-N NAMES] [-x] [-c WIDTH]",
+		Summary:  "align fields as a table (-t), or fill lines into columns",
+		Options: []manualOption{
+			{"-t", "align fields as a table"},
+			{"-s SEP", "input field separators"},
+			{"-o SEP", "text between output columns"},
+			{"-R COLUMNS", "right-align these columns"},
+			{"-N NAMES", "column names, printed as a header"},
+		},
+		Examples: []manualExample{
+			{"column -t -s,", "Align a CSV report"},
+		},
+		Errors: []manualError{
+			{"column: undefined column name 'X'", "-R names must be listed with -N"},
+		},
+	},
+	"expand": {
+		Synopsis: "expand [-i] [-t N | -t LIST]",
+		Summary:  "convert tabs to spaces",
+		Options: []manualOption{
+			{"-t N", "tab stops every N columns (default 8)"},
+			{"-i", "only leading tabs"},
+		},
+		Examples: []manualExample{
+			{"expand -t 4", "Four-column tabs"},
+		},
+	},
+	"unexpand": {
+		Synopsis: "unexpand [-a] [--first-only] [-t N | -t LIST]",
+		Summary:  "convert leading spaces to tabs",
+		Options: []manualOption{
+			{"-a", "convert all runs of blanks"},
+			{"-t N", "tab stops every N columns; implies -a"},
+		},
+		Examples: []manualExample{
+			{"unexpand --first-only -t 4", "Indent with tabs"},
+		},
+	},
+	"fold": {
+		Synopsis: "fold [-s] [-b] [-w WIDTH]",
+		Summary:  "wrap lines to a width",
+		Options: []manualOption{
+			{"-w WIDTH", "wrap at WIDTH columns (default 80)"},
+			{"-s", "break at blanks"},
+			{"-b", "count bytes"},
+		},
+		Examples: []manualExample{
+			{"fold -s -w 72", "Wrap at word boundaries"},
+		},
+		Errors: []manualError{
+			{"fold: invalid number of columns: 'X'", "give a positive width"},
+		},
+	},
+	"fmt": {
+		Synopsis: "fmt [-w WIDTH] [-s] [-u] [-c] [-p PREFIX]",
+		Summary:  "refill paragraphs, keeping blank lines and indentation",
+		Options: []manualOption{
+			{"-w WIDTH", "maximum line width (default 75)"},
+			{"-s", "only split long lines"},
+			{"-u", "uniform spacing"},
+			{"-p PREFIX", "only refill lines starting with PREFIX"},
+		},
+		Examples: []manualExample{
+			{"fmt -w 72", "Reflow paragraphs"},
+		},
+	},
+	"stats": {
+		Synopsis: "stats [-d DELIM | -t | --csv] [--header | -H] [-c COLUMNS] [-n N]",
+		Summary:  "summarize input in one pass: counts, min/max/mean/sum of numeric columns, most frequent values",
+		Options: []manualOption{
+			{"-d DELIM, -t, --csv", "split fields on DELIM, tabs, or quoted CSV"},
+			{"--header, -H", "the first line names the columns, or is data"},
+			{"-c COLUMNS", "only these columns: numbers, ranges or names"},
+			{"-n N", "the N most frequent values (default 5)"},
+		},
+		Examples: []manualExample{
+			{"stats --csv", "Get a feel for a CSV file"},
+		},
+		Errors: []manualError{
+			{"stats: -d: delimiter must be a single character", "use -t for tabs or --csv for CSV"},
+		},
+	},
+	"diff": {
+		Synopsis: "diff [-u] [-U N] [-q] [-r] [-N] [-i] [-w] [-b] [FILE1 FILE2]",
+		Summary:  "compare two texts as a unified diff; without operands stdin holds both, separated by a " + diffSeparator + " line",
+		Options: []manualOption{
+			{"-U N", "N lines of context (default 3)"},
+			{"-q", "only report whether they differ"},
+			{"-r", "compare directories of virtual files"},
+			{"-i, -w, -b", "ignore case, all white space, or changes in white space"},
+		},
+		Examples: []manualExample{
+			{"diff old.txt new.txt", "What changed between two virtual files"},
+			{"diff -q expected.txt - < actual.txt", "Compare a file with stdin"},
+		},
+		Errors: []manualError{
+			{"diff: input must contain exactly one " + diffSeparator + ", or give two files to compare", "name two files, or separate the texts on stdin"},
+		},
+	},
+	"patch": {
+		Synopsis: "patch [-pN] [-F N] [--dry-run] [-i PATCHFILE] [-o OUTFILE] [FILE]",
+		Summary:  "apply a unified diff; without files stdin holds the text, a " + patchSeparator + " line and the patch",
+		Options: []manualOption{
+			{"-pN", "strip N leading directories from file names"},
+			{"-F N", "context lines that may mismatch (default 2)"},
+			{"--dry-run", "only check that the patch applies"},
+		},
+		Examples: []manualExample{
+			{"patch -p1 < fix.diff", "Apply a git-style patch to virtual files"},
+		},
+		Errors: []manualError{
+			{"patch: N hunks FAILED", "the file changed; look at FILE.rej and regenerate the patch"},
+			{"patch: only garbage was found in the patch input", "the input is not a unified diff"},
+		},
+	},
+	"diff3": {
+		Synopsis: "diff3 [-m] [-A|-E] [-T] [-L LABEL]... MINE OLDER YOURS",
+		Summary:  "compare three texts, or merge OLDER to YOURS into MINE with -m; without files stdin holds them, separated by " + mergeSeparator + " lines",
+		Options: []manualOption{
+			{"-m", "output the merged text; conflicts are bracketed with <<<<<<< and >>>>>>>"},
+			{"-E", "leave the OLDER lines out of conflicts"},
+			{"-L LABEL", "label for the conflict markers"},
+		},
+		Examples: []manualExample{
+			{"diff3 -m ours base theirs > merged", "Three-way merge"},
+		},
+		Errors: []manualError{
+			{"diff3: N conflicts during merge", "resolve the <<<<<<< blocks in the output"},
+		},
+	},
+	"merge": {
+		Synopsis: "merge [-p] [-A|-E] [-L LABEL]... FILE1 FILE2 FILE3",
+		Summary:  "merge the changes from FILE2 to FILE3 into FILE1",
+		Options: []manualOption{
+			{"-p", "write the result to stdout instead of FILE1"},
+			{"-A", "show the FILE2 lines of conflicts"},
+		},
+		Examples: []manualExample{
+			{"merge -p ours base theirs", "Merge without changing anything"},
+		},
+		Errors: []manualError{
+			{"merge: warning: conflicts during merge", "resolve the <<<<<<< blocks"},
+		},
+	},
+	"help": {
+		Synopsis: "help --list | help KEY...",
+		Summary:  "print usage guides by topic key, or the manual of builtins by name",
+		Options: []manualOption{
+			{"--list", "list the topic keys and the builtins"},
+		},
+		Examples: []manualExample{
+			{"help jq sort", "Manuals of jq and sort"},
+			{"help log_analysis", "Patterns for analysing logs"},
+		},
+		Errors: []manualError{
+			{"invalid key: X", "help --list shows the keys"},
+		},
+	},
+}
+
+// writeManual writes the manual of a builtin
+func writeManual(w io.Writer, name string, m manual) {
+	fmt.Fprintf(w, "=== %s ===\n", strings.ToUpper(name))
+	fmt.Fprintf(w, "SYNOPSIS:\n  %s\n", m.Synopsis)
+	fmt.Fprintf(w, "  %s\n", m.Summary)
+	if len(m.Options) > 0 {
+		fmt.Fprint(w, "OPTIONS:\n")
+		for _, option := range m.Options {
+			fmt.Fprintf(w, "  %s: %s\n", option.Flag, option.Description)
+		}
+	}
+	if len(m.Examples) > 0 {
+		fmt.Fprint(w, "EXAMPLES:\n")
+		for _, example := range m.Examples {
+			fmt.Fprintf(w, "  %s - %s\n", example.Command, example.Description)
+		}
+	}
+	if len(m.Errors) > 0 {
+		fmt.Fprint(w, "COMMON ERRORS:\n")
+		for _, e := range m.Errors {
+			fmt.Fprintf(w, "  %s\n    Fix: %s\n", e.Message, e.Fix)
+		}
+	}
+}
+
+// writeHelpList writes the keys help accepts: the usage topics and the
+// builtins with their synopses
+func writeHelpList(w io.Writer, usageData *UsageData) {
+	var keys []string
+	for key := range usageData.KeyMappings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fmt.Fprint(w, "USAGE KEYS:\n")
+	for _, key := range keys {
+		fmt.Fprintf(w, "  %s\n", key)
+	}
+
+	var names []string
+	for name := range manuals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprint(w, "\nBUILTIN KEYS:\n")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, manuals[name].Synopsis)
+	}
+}
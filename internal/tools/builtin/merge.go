@@ -0,0 +1,433 @@
+package builtin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// mergeSeparator separates the three texts of diff3 and merge when they
+// come from stdin
+const mergeSeparator = "---LLMCMD_MERGE_SEPARATOR---"
+
+// mergeOptions are the options diff3 and merge share
+type mergeOptions struct {
+	merge    bool      // -m: output the merged file rather than the differences
+	showBase bool      // -A: show the older lines in conflicts
+	tab      bool      // -T: start the lines of diff3 output with a tab
+	toStdout bool      // merge -p
+	labels   [3]string // -L, for the conflict markers
+	operands []string
+}
+
+// Diff3 compares three texts, mine, older and yours, given on stdin
+// separated by ---LLMCMD_MERGE_SEPARATOR---
+func Diff3(args []string, stdin io.Reader, stdout io.Writer) error {
+	return RunDiff3(args, stdin, stdout, nil)
+}
+
+// RunDiff3 is Diff3 comparing the three files MINE OLDER YOURS it is given,
+// as GNU diff3 does: each ==== block shows a change, numbered with the
+// file that differs from the other two, and -m merges the changes from
+// OLDER to YOURS into MINE, bracketing the conflicting ones with <<<<<<<
+// and >>>>>>> lines.
+func RunDiff3(args []string, stdin io.Reader, stdout io.Writer, open OpenFunc) error {
+	opts, err := parseMergeOptions("diff3", args, "mAETL")
+	if err != nil {
+		return err
+	}
+	texts, err := readMergeInputs("diff3", opts.operands, stdin, open)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+	blocks := mergeBlocks(texts[0], texts[1], texts[2])
+	if !opts.merge {
+		writeDiff3(out, texts, blocks, opts.tab)
+		return nil
+	}
+	if conflicts := writeMerged(out, texts, blocks, opts); conflicts > 0 {
+		out.Flush()
+		return fmt.Errorf("diff3: %d %s during merge", conflicts, plural(conflicts, "conflict"))
+	}
+	return nil
+}
+
+// Merge merges three texts, given on stdin as for Diff3, to stdout
+func Merge(args []string, stdin io.Reader, stdout io.Writer) error {
+	return RunMerge(args, stdin, stdout, nil)
+}
+
+// RunMerge is Merge working like RCS merge on the files FILE1 FILE2 FILE3:
+// the changes from FILE2 to FILE3 are merged into FILE1, which is
+// overwritten unless -p asks for stdout. Conflicts are bracketed with
+// <<<<<<< and >>>>>>> lines and make merge fail.
+func RunMerge(args []string, stdin io.Reader, stdout io.Writer, files Files) error {
+	opts, err := parseMergeOptions("merge", args, "pAEL")
+	if err != nil {
+		return err
+	}
+	var open OpenFunc
+	if files != nil {
+		open = files.Open
+	}
+	texts, err := readMergeInputs("merge", opts.operands, stdin, open)
+	if err != nil {
+		return err
+	}
+
+	opts.merge = true
+	var merged strings.Builder
+	conflicts := writeMerged(&merged, texts, mergeBlocks(texts[0], texts[1], texts[2]), opts)
+	if opts.toStdout || len(opts.operands) == 0 {
+		if _, err := io.WriteString(stdout, merged.String()); err != nil {
+			return err
+		}
+	} else {
+		file, err := files.Create(opts.operands[0])
+		if err != nil {
+			return fmt.Errorf("merge: %s: %w", opts.operands[0], err)
+		}
+		if _, err := io.WriteString(file, merged.String()); err != nil {
+			file.Close()
+			return fmt.Errorf("merge: %s: %w", opts.operands[0], err)
+		}
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("merge: %s: %w", opts.operands[0], err)
+		}
+	}
+	if conflicts > 0 {
+		return fmt.Errorf("merge: warning: conflicts during merge")
+	}
+	return nil
+}
+
+// parseMergeOptions parses the options of diff3 or merge, of which letters
+// are allowed. The labels default to the file names.
+func parseMergeOptions(cmd string, args []string, letters string) (mergeOptions, error) {
+	// diff3 -m shows the older lines in conflicts unless -E is given;
+	// merge only shows them with -A
+	opts := mergeOptions{showBase: cmd == "diff3"}
+	labels := 0
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			opts.operands = append(opts.operands, args[i+1:]...)
+			i = len(args)
+			continue
+		case arg == "-" || !strings.HasPrefix(arg, "-"):
+			opts.operands = append(opts.operands, arg)
+			continue
+		case cmd == "diff3" && arg == "--merge":
+			arg = "-m"
+		case cmd == "diff3" && arg == "--show-all":
+			arg = "-A"
+		case cmd == "diff3" && arg == "--show-overlap":
+			arg = "-E"
+		case cmd == "diff3" && arg == "--initial-tab":
+			arg = "-T"
+		case cmd == "diff3" && strings.HasPrefix(arg, "--label="):
+			arg = "-L" + strings.TrimPrefix(arg, "--label=")
+		case strings.HasPrefix(arg, "--"):
+			return opts, fmt.Errorf("%s: %s: invalid option", cmd, arg)
+		}
+
+		for k := 1; k < len(arg); k++ {
+			c := arg[k]
+			if !strings.ContainsRune(letters, rune(c)) {
+				return opts, fmt.Errorf("%s: -%c: invalid option", cmd, c)
+			}
+			switch c {
+			case 'm':
+				opts.merge = true
+			case 'A':
+				opts.showBase = true
+			case 'E':
+				opts.showBase = false
+			case 'T':
+				opts.tab = true
+			case 'p':
+				opts.toStdout = true
+			case 'L':
+				value := arg[k+1:]
+				if value == "" {
+					if i+1 == len(args) {
+						return opts, fmt.Errorf("%s: -L: missing argument", cmd)
+					}
+					i++
+					value = args[i]
+				}
+				if labels == len(opts.labels) {
+					return opts, fmt.Errorf("%s: too many -L options", cmd)
+				}
+				opts.labels[labels] = value
+				labels++
+				k = len(arg)
+			}
+		}
+	}
+	if len(opts.operands) != 0 && len(opts.operands) != 3 {
+		return opts, fmt.Errorf("%s: expected three files, got %d", cmd, len(opts.operands))
+	}
+	for i, name := range opts.operands {
+		if opts.labels[i] == "" {
+			opts.labels[i] = name
+		}
+	}
+	if len(opts.operands) == 0 {
+		for i, name := range []string{"mine", "older", "yours"} {
+			if opts.labels[i] == "" {
+				opts.labels[i] = name
+			}
+		}
+	}
+	return opts, nil
+}
+
+// readMergeInputs returns the lines of the three files of a merge, or of
+// the three texts on stdin when no files are given
+func readMergeInputs(cmd string, operands []string, stdin io.Reader, open OpenFunc) ([3][]string, error) {
+	var texts [3][]string
+	if len(operands) == 0 {
+		input, err := io.ReadAll(stdin)
+		if err != nil {
+			return texts, fmt.Errorf("%s: failed to read input: %w", cmd, err)
+		}
+		parts := strings.Split(string(input), mergeSeparator)
+		if len(parts) != 3 {
+			return texts, fmt.Errorf("%s: input must contain exactly two %s, or give three files to merge", cmd, mergeSeparator)
+		}
+		for i, part := range parts {
+			texts[i], _ = diffLines(strings.TrimSpace(part) + "\n")
+		}
+		return texts, nil
+	}
+
+	var input []byte
+	read := false
+	for i, name := range operands {
+		var data []byte
+		switch {
+		case name == "-" && read:
+			data = input
+		case name == "-":
+			var err error
+			if input, err = io.ReadAll(stdin); err != nil {
+				return texts, fmt.Errorf("%s: failed to read input: %w", cmd, err)
+			}
+			data, read = input, true
+		case open == nil:
+			return texts, fmt.Errorf("%s: %s: file operands are not supported; pipe the input instead", cmd, name)
+		default:
+			f, err := open(name)
+			if err != nil {
+				return texts, fmt.Errorf("%s: %s: %w", cmd, name, err)
+			}
+			data, err = io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				return texts, fmt.Errorf("%s: %s: %w", cmd, name, err)
+			}
+		}
+		texts[i], _ = diffLines(string(data))
+	}
+	return texts, nil
+}
+
+// mergeHunk is a change from the older file to another: lines
+// [oldStart, oldEnd) of the older file became [start, end) of the other
+type mergeHunk struct {
+	oldStart, oldEnd int
+	start, end       int
+}
+
+// mergeHunks returns the changes from older to other
+func mergeHunks(older, other []string) []mergeHunk {
+	removed, added := diffLCS(older, other)
+	var hunks []mergeHunk
+	i, j := 0, 0
+	for i < len(older) || j < len(other) {
+		if (i == len(older) || !removed[i]) && (j == len(other) || !added[j]) {
+			i++
+			j++
+			continue
+		}
+		hunk := mergeHunk{oldStart: i, start: j}
+		for i < len(older) && removed[i] {
+			i++
+		}
+		for j < len(other) && added[j] {
+			j++
+		}
+		hunk.oldEnd, hunk.end = i, j
+		hunks = append(hunks, hunk)
+	}
+	return hunks
+}
+
+// diff3Block is a part of the older file that mine or yours changed, with
+// the lines it became in each; ranges are [start, end) of mine, older and
+// yours
+type diff3Block struct {
+	ranges [3][2]int
+	differ int // The file that differs from the other two, or -1 if all do
+}
+
+// mergeBlocks lines the changes from older to mine and to yours up against
+// each other. Changes from both that overlap or touch make one block.
+func mergeBlocks(mine, older, yours []string) []diff3Block {
+	sides := [2][]mergeHunk{mergeHunks(older, mine), mergeHunks(older, yours)}
+	var next [2]int  // The next hunk of each side
+	var delta [2]int // How far the lines of each side are from those of older
+	var blocks []diff3Block
+	for next[0] < len(sides[0]) || next[1] < len(sides[1]) {
+		// The block starts with whichever hunk comes first, and takes in
+		// the hunks that start before it ends
+		var used [2][]mergeHunk
+		low, high := -1, -1
+		for {
+			side := -1
+			for s := range sides {
+				if next[s] == len(sides[s]) {
+					continue
+				}
+				hunk := sides[s][next[s]]
+				if (low < 0 || hunk.oldStart <= high) && (side < 0 || hunk.oldStart < sides[side][next[side]].oldStart) {
+					side = s
+				}
+			}
+			if side < 0 {
+				break
+			}
+			hunk := sides[side][next[side]]
+			next[side]++
+			if low < 0 {
+				low, high = hunk.oldStart, hunk.oldEnd
+			}
+			high = max(high, hunk.oldEnd)
+			used[side] = append(used[side], hunk)
+		}
+
+		block := diff3Block{differ: -1}
+		block.ranges[1] = [2]int{low, high}
+		for s, hunks := range used {
+			file := s * 2
+			if len(hunks) == 0 {
+				block.ranges[file] = [2]int{low + delta[s], high + delta[s]}
+				continue
+			}
+			first, last := hunks[0], hunks[len(hunks)-1]
+			block.ranges[file] = [2]int{first.start - (first.oldStart - low), last.end + (high - last.oldEnd)}
+			delta[s] = block.ranges[file][1] - high
+		}
+		switch {
+		case len(used[1]) == 0:
+			block.differ = 0
+		case len(used[0]) == 0:
+			block.differ = 2
+		case equalLines(mine[block.ranges[0][0]:block.ranges[0][1]], yours[block.ranges[2][0]:block.ranges[2][1]]):
+			block.differ = 1
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// equalLines reports whether a and b hold the same lines
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// writeDiff3 writes the blocks in the format of GNU diff3: a ==== line,
+// with the number of the file that differs from the other two, and the
+// range and lines of each file. The lines two files share are shown once,
+// under the second of them.
+func writeDiff3(w io.Writer, texts [3][]string, blocks []diff3Block, tab bool) {
+	prefix := "  "
+	if tab {
+		prefix = "\t"
+	}
+	for _, block := range blocks {
+		fmt.Fprint(w, "====")
+		if block.differ >= 0 {
+			fmt.Fprint(w, block.differ+1)
+		}
+		fmt.Fprintln(w)
+
+		skip := -1
+		switch block.differ {
+		case 0:
+			skip = 1
+		case 1, 2:
+			skip = 0
+		}
+		for file, r := range block.ranges {
+			switch r[1] - r[0] {
+			case 0:
+				fmt.Fprintf(w, "%d:%da\n", file+1, r[0])
+			case 1:
+				fmt.Fprintf(w, "%d:%dc\n", file+1, r[1])
+			default:
+				fmt.Fprintf(w, "%d:%d,%dc\n", file+1, r[0]+1, r[1])
+			}
+			if file == skip {
+				continue
+			}
+			for _, line := range texts[file][r[0]:r[1]] {
+				fmt.Fprintf(w, "%s%s\n", prefix, line)
+			}
+		}
+	}
+}
+
+// writeMerged writes mine with the changes from older to yours merged in,
+// and returns the number of conflicts, which are written between
+// <<<<<<< and >>>>>>> lines
+func writeMerged(w io.Writer, texts [3][]string, blocks []diff3Block, opts mergeOptions) int {
+	mine := texts[0]
+	writeLines := func(lines []string) {
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
+	}
+	lines := func(file int, block diff3Block) []string {
+		return texts[file][block.ranges[file][0]:block.ranges[file][1]]
+	}
+
+	conflicts, pos := 0, 0
+	for _, block := range blocks {
+		writeLines(mine[pos:block.ranges[0][0]])
+		pos = block.ranges[0][1]
+		switch block.differ {
+		case 0, 1:
+			writeLines(lines(0, block))
+		case 2:
+			writeLines(lines(2, block))
+		default:
+			conflicts++
+			fmt.Fprintf(w, "<<<<<<< %s\n", opts.labels[0])
+			writeLines(lines(0, block))
+			if opts.showBase {
+				fmt.Fprintf(w, "||||||| %s\n", opts.labels[1])
+				writeLines(lines(1, block))
+			}
+			fmt.Fprintln(w, "=======")
+			writeLines(lines(2, block))
+			fmt.Fprintf(w, ">>>>>>> %s\n", opts.labels[2])
+		}
+	}
+	writeLines(mine[pos:])
+	return conflicts
+}
@@ -0,0 +1,131 @@
+package builtin
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDiff3AndMerge(t *testing.T) {
+	files := func() memoryFiles {
+		return memoryFiles{
+			"older": bytes.NewBufferString("a\nb\nc\nd\ne\nf\ng\n"),
+			"mine":  bytes.NewBufferString("a\nB\nc\nd\ne\nf\ng\n"),
+			"yours": bytes.NewBufferString("a\nb\nc\nd\nE\nf\ng\nh\n"),
+			"both":  bytes.NewBufferString("a\nB\nc\nd\nE\nf\ng\n"),
+			"other": bytes.NewBufferString("a\nX\nc\nd\ne\nf\ng\n"),
+		}
+	}
+	tests := []struct {
+		name    string
+		command func(args []string, stdin *strings.Reader, stdout *strings.Builder, files memoryFiles) error
+		args    []string
+		stdin   string
+		output  string
+		err     string
+		file    string // The expected content of mine afterwards
+	}{
+		{
+			name:   "diff3 blocks",
+			args:   []string{"mine", "older", "both"},
+			output: "====2\n1:2c\n2:2c\n  b\n3:2c\n  B\n====3\n1:5c\n2:5c\n  e\n3:5c\n  E\n",
+		},
+		{
+			name:   "diff3 conflict and insertion",
+			args:   []string{"-T", "other", "older", "yours"},
+			output: "====1\n1:2c\n\tX\n2:2c\n3:2c\n\tb\n====3\n1:5c\n2:5c\n\te\n3:5c\n\tE\n====3\n1:7a\n2:7a\n3:8c\n\th\n",
+		},
+		{
+			name:   "diff3 merge",
+			args:   []string{"-m", "mine", "older", "yours"},
+			output: "a\nB\nc\nd\nE\nf\ng\nh\n",
+		},
+		{
+			name:   "diff3 merge conflict",
+			args:   []string{"-m", "-L", "HEAD", "--label=base", "mine", "older", "other"},
+			output: "a\n<<<<<<< HEAD\nB\n||||||| base\nb\n=======\nX\n>>>>>>> other\nc\nd\ne\nf\ng\n",
+			err:    "diff3: 1 conflict during merge",
+		},
+		{
+			name:   "diff3 merge overlap only",
+			args:   []string{"-mE", "mine", "older", "other"},
+			output: "a\n<<<<<<< mine\nB\n=======\nX\n>>>>>>> other\nc\nd\ne\nf\ng\n",
+			err:    "diff3: 1 conflict during merge",
+		},
+		{
+			name:   "diff3 stdin",
+			args:   []string{"-m"},
+			stdin:  "X\ny\nz\n---LLMCMD_MERGE_SEPARATOR---\nx\ny\nz\n---LLMCMD_MERGE_SEPARATOR---\nx\ny\nZ\n",
+			output: "X\ny\nZ\n",
+		},
+		{
+			name: "diff3 missing separator",
+			args: []string{"-m"},
+			err:  "diff3: input must contain exactly two ---LLMCMD_MERGE_SEPARATOR---, or give three files to merge",
+		},
+		{
+			name: "diff3 two files",
+			args: []string{"mine", "older"},
+			err:  "diff3: expected three files, got 2",
+		},
+		{
+			name:    "merge into the first file",
+			command: runMerge,
+			args:    []string{"mine", "older", "yours"},
+			file:    "a\nB\nc\nd\nE\nf\ng\nh\n",
+		},
+		{
+			name:    "merge conflict",
+			command: runMerge,
+			args:    []string{"-A", "mine", "older", "other"},
+			err:     "merge: warning: conflicts during merge",
+			file:    "a\n<<<<<<< mine\nB\n||||||| older\nb\n=======\nX\n>>>>>>> other\nc\nd\ne\nf\ng\n",
+		},
+		{
+			name:    "merge to stdout",
+			command: runMerge,
+			args:    []string{"-p", "-L", "ours", "-L", "base", "-L", "theirs", "mine", "older", "other"},
+			output:  "a\n<<<<<<< ours\nB\n=======\nX\n>>>>>>> theirs\nc\nd\ne\nf\ng\n",
+			err:     "merge: warning: conflicts during merge",
+			file:    "a\nB\nc\nd\ne\nf\ng\n",
+		},
+		{
+			name:    "merge invalid option",
+			command: runMerge,
+			args:    []string{"-m", "mine", "older", "yours"},
+			err:     "merge: -m: invalid option",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := tt.command
+			if command == nil {
+				command = runDiff3
+			}
+			files := files()
+			var output strings.Builder
+			err := command(tt.args, strings.NewReader(tt.stdin), &output, files)
+			if tt.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err != "" && (err == nil || err.Error() != tt.err) {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+			if output.String() != tt.output {
+				t.Errorf("expected output %q, got %q", tt.output, output.String())
+			}
+			if tt.file != "" && files["mine"].String() != tt.file {
+				t.Errorf("expected mine to be %q, got %q", tt.file, files["mine"].String())
+			}
+		})
+	}
+}
+
+func runDiff3(args []string, stdin *strings.Reader, stdout *strings.Builder, files memoryFiles) error {
+	return RunDiff3(args, stdin, stdout, files.Open)
+}
+
+func runMerge(args []string, stdin *strings.Reader, stdout *strings.Builder, files memoryFiles) error {
+	return RunMerge(args, stdin, stdout, files)
+}
@@ -1,17 +1,26 @@
 package builtin
 
 import (
+	"bufio"
 	"fmt"
 	"io"
+	"path"
 	"strconv"
 	"strings"
 )
 
+// patchSeparator separates the original text from the patch when both come
+// from stdin
+const patchSeparator = "---LLMCMD_PATCH_SEPARATOR---"
+
 // PatchChunk represents a chunk in a unified diff patch
 type PatchChunk struct {
 	OldStart, OldLines int
 	NewStart, NewLines int
 	Changes            []PatchLine
+
+	oldNoEOL, newNoEOL bool     // The last old or new line has no newline
+	text               []string // The hunk as it was written, for rejects
 }
 
 // PatchLine represents a line in a patch chunk
@@ -20,48 +29,139 @@ type PatchLine struct {
 	Content string
 }
 
+// patchOptions are the options of patch
+type patchOptions struct {
+	strip  int // -p: leading directories to strip; -1 keeps only the base name
+	fuzz   int
+	dryRun bool
+	silent bool
+	input  string // -i: the patch file, stdin if empty
+	output string // -o: where the patched file is written
+	target string // The file operand, which all hunks apply to
+}
+
 // Patch applies a unified diff patch to text input
 // Input format: original_text + ---LLMCMD_PATCH_SEPARATOR--- + patch_content
-// Args: [--validate] - optional pre-validation without applying patch
+// Args: [--dry-run] - optional pre-validation without applying patch
 func Patch(args []string, stdin io.Reader, stdout io.Writer) error {
-	// Parse arguments
-	dryRun := false
-	for _, arg := range args {
-		switch arg {
-		case "--dry-run":
-			dryRun = true
-		case "--help", "-h":
+	return RunPatch(args, stdin, stdout, nil)
+}
+
+// RunPatch is Patch applying the patch on stdin to the files it names, like
+// GNU patch: -pN strips N leading directories from the names (all of them
+// without -p), hunks that do not apply where the patch says are looked for
+// nearby and with up to -F (2) lines of context ignored, and hunks that
+// still fail are saved to FILE.rej. Input with a
+// ---LLMCMD_PATCH_SEPARATOR--- is patched as by Patch.
+func RunPatch(args []string, stdin io.Reader, stdout io.Writer, files Files) error {
+	opts := patchOptions{strip: -1, fuzz: 2}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--dry-run":
+			opts.dryRun = true
+			continue
+		case arg == "--help" || arg == "-h":
 			fmt.Fprint(stdout, `patch - Apply unified diff patches to text
 
-Usage: patch [--dry-run]
+Usage: patch [-pN] [-F N] [--dry-run] [-i PATCHFILE] [-o OUTFILE] [FILE]
 
 Options:
+  -pN, --strip=N    Strip N leading directories from file names in the patch
+  -F N, --fuzz=N    Ignore up to N lines of context of hunks that do not match (default 2)
   --dry-run         Don't actually apply patch (validation only)
+  -i PATCHFILE      Read the patch from PATCHFILE instead of stdin
+  -o OUTFILE        Write the patched file to OUTFILE
+  -s, --silent      Only report failures
   --help, -h        Show this help message
 
-Input format: original_text + ---LLMCMD_PATCH_SEPARATOR--- + patch_content
+The patch on stdin is applied to the files it names; hunks that fail are
+saved to FILE.rej.
+
+Input format without files: original_text + ---LLMCMD_PATCH_SEPARATOR--- + patch_content
 `)
 			return nil
-		default:
-			return fmt.Errorf("patch: unknown argument %q. Use --help for usage information", arg)
+		case arg == "-s" || arg == "--silent" || arg == "--quiet":
+			opts.silent = true
+			continue
+		case strings.HasPrefix(arg, "--strip="):
+			arg = "-p" + strings.TrimPrefix(arg, "--strip=")
+		case strings.HasPrefix(arg, "--fuzz="):
+			arg = "-F" + strings.TrimPrefix(arg, "--fuzz=")
+		case strings.HasPrefix(arg, "--input="):
+			arg = "-i" + strings.TrimPrefix(arg, "--input=")
+		case strings.HasPrefix(arg, "--output="):
+			arg = "-o" + strings.TrimPrefix(arg, "--output=")
+		case !strings.HasPrefix(arg, "-") && opts.target == "":
+			opts.target = arg
+			continue
+		}
+		if len(arg) < 2 || arg[0] != '-' || !strings.ContainsRune("pFio", rune(arg[1])) {
+			return fmt.Errorf("patch: unknown argument %q. Use --help for usage information", args[i])
+		}
+
+		// -pN, -p N and the like
+		value := arg[2:]
+		if value == "" {
+			if i+1 == len(args) {
+				return fmt.Errorf("patch: -%c: missing argument", arg[1])
+			}
+			i++
+			value = args[i]
+		}
+		switch arg[1] {
+		case 'p', 'F':
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return fmt.Errorf("patch: -%c: invalid number %q", arg[1], value)
+			}
+			if arg[1] == 'p' {
+				opts.strip = n
+			} else {
+				opts.fuzz = n
+			}
+		case 'i':
+			opts.input = value
+		case 'o':
+			opts.output = value
+		}
+	}
+
+	if files == nil {
+		for _, name := range []string{opts.target, opts.input, opts.output} {
+			if name != "" {
+				return fmt.Errorf("patch: %s: file operands are not supported; pipe the input instead", name)
+			}
 		}
 	}
 
-	content, err := io.ReadAll(stdin)
+	var input io.Reader = stdin
+	if opts.input != "" {
+		file, err := files.Open(opts.input)
+		if err != nil {
+			return fmt.Errorf("patch: %s: %w", opts.input, err)
+		}
+		defer file.Close()
+		input = file
+	}
+	content, err := io.ReadAll(input)
 	if err != nil {
 		return fmt.Errorf("patch: failed to read input: %w", err)
 	}
 
-	// Split input by separator
-	parts := strings.Split(string(content), "---LLMCMD_PATCH_SEPARATOR---")
+	// Split input by separator; without one the patch names its files
+	parts := strings.Split(string(content), patchSeparator)
+	if len(parts) == 1 && files != nil {
+		return patchFiles(string(content), opts, stdout, files)
+	}
 	if len(parts) != 2 {
-		return fmt.Errorf("patch: input must contain exactly one ---LLMCMD_PATCH_SEPARATOR---")
+		return fmt.Errorf("patch: input must contain exactly one %s", patchSeparator)
 	}
 
 	originalText := strings.TrimSpace(parts[0])
 	patchContent := strings.TrimSpace(parts[1])
 
-	if dryRun {
+	if opts.dryRun {
 		// Dry-run mode: only check if patch is valid
 		err := validatePatch(originalText, patchContent)
 		if err != nil {
@@ -302,3 +402,362 @@ func applyChunk(lines []string, chunk PatchChunk) ([]string, error) {
 
 	return result, nil
 }
+
+// filePatch is the part of a patch that changes one file
+type filePatch struct {
+	oldName, newName string
+	line             int // The line of the patch the file names are on
+	chunks           []PatchChunk
+}
+
+// parseFilePatches splits a unified diff into the patches of the files it
+// names. Lines outside hunks, such as diff and Index lines, are ignored.
+func parseFilePatches(text string) ([]filePatch, error) {
+	lines, _ := diffLines(text)
+	var patches []filePatch
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			patches = append(patches, filePatch{
+				oldName: patchFileName(line[4:]),
+				newName: patchFileName(lines[i+1][4:]),
+				line:    i + 1,
+			})
+			i++
+		case strings.HasPrefix(line, "@@") && len(patches) > 0:
+			chunk, next, err := parseFileChunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			current := &patches[len(patches)-1]
+			current.chunks = append(current.chunks, chunk)
+			i = next - 1
+		}
+	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("patch: only garbage was found in the patch input")
+	}
+	return patches, nil
+}
+
+// patchFileName returns the file name of a ---/+++ line, without the
+// timestamp that follows a tab
+func patchFileName(name string) string {
+	if i := strings.IndexByte(name, '\t'); i >= 0 {
+		name = name[:i]
+	}
+	return strings.TrimSpace(name)
+}
+
+// parseFileChunk parses the hunk whose header is lines[start], reading as
+// many lines as its ranges count, and returns the index after it
+func parseFileChunk(lines []string, start int) (PatchChunk, int, error) {
+	var chunk PatchChunk
+	parts := strings.Fields(lines[start])
+	if len(parts) < 4 || parts[3] != "@@" || !strings.HasPrefix(parts[1], "-") || !strings.HasPrefix(parts[2], "+") {
+		return chunk, 0, fmt.Errorf("patch: malformed hunk header at line %d: %q", start+1, lines[start])
+	}
+	var err error
+	if chunk.OldStart, chunk.OldLines, err = parseRange(parts[1][1:]); err != nil {
+		return chunk, 0, fmt.Errorf("patch: malformed hunk header at line %d: %w", start+1, err)
+	}
+	if chunk.NewStart, chunk.NewLines, err = parseRange(parts[2][1:]); err != nil {
+		return chunk, 0, fmt.Errorf("patch: malformed hunk header at line %d: %w", start+1, err)
+	}
+
+	chunk.text = []string{lines[start]}
+	oldLeft, newLeft := chunk.OldLines, chunk.NewLines
+	i := start + 1
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, `\`) {
+			// "\ No newline at end of file" applies to the line before it
+			if len(chunk.Changes) > 0 {
+				last := chunk.Changes[len(chunk.Changes)-1].Type
+				chunk.oldNoEOL = chunk.oldNoEOL || last != "+"
+				chunk.newNoEOL = chunk.newNoEOL || last != "-"
+			}
+			chunk.text = append(chunk.text, line)
+			continue
+		}
+		if oldLeft == 0 && newLeft == 0 {
+			break
+		}
+		kind := " "
+		if line != "" {
+			kind = line[:1]
+		}
+		switch kind {
+		case " ":
+			oldLeft--
+			newLeft--
+		case "-":
+			oldLeft--
+		case "+":
+			newLeft--
+		default:
+			return chunk, 0, fmt.Errorf("patch: malformed patch at line %d: %q", i+1, line)
+		}
+		if oldLeft < 0 || newLeft < 0 {
+			return chunk, 0, fmt.Errorf("patch: malformed patch at line %d: the hunk has more lines than its header says", i+1)
+		}
+		content := ""
+		if line != "" {
+			content = line[1:]
+		}
+		chunk.Changes = append(chunk.Changes, PatchLine{Type: kind, Content: content})
+		chunk.text = append(chunk.text, line)
+	}
+	if oldLeft > 0 || newLeft > 0 {
+		return chunk, 0, fmt.Errorf("patch: premature end of patch at line %d", i+1)
+	}
+	return chunk, i, nil
+}
+
+// stripName removes the leading directories -p asks for from a file name
+// of the patch; without -p only the base name is kept
+func (o patchOptions) stripName(name string) string {
+	if name == "/dev/null" {
+		return ""
+	}
+	if o.strip < 0 {
+		return path.Base(name)
+	}
+	parts := strings.Split(name, "/")
+	if o.strip >= len(parts) {
+		return ""
+	}
+	return strings.Join(parts[o.strip:], "/")
+}
+
+// patchFiles applies a patch to the files it names, reporting each file and
+// each hunk that needed an offset or fuzz like GNU patch. Hunks that do
+// not apply are saved to a .rej file next to the patched file.
+func patchFiles(text string, opts patchOptions, stdout io.Writer, files Files) error {
+	patches, err := parseFilePatches(text)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+	failed := 0
+	for _, patch := range patches {
+		total := len(patch.chunks)
+		name, content, found := opts.findTarget(patch, files)
+		if !found {
+			fmt.Fprintf(out, "can't find file to patch at input line %d\n", patch.line)
+			fmt.Fprintln(out, "Perhaps you used the wrong -p or --strip option?")
+			fmt.Fprintf(out, "%d out of %d %s ignored\n", total, total, plural(total, "hunk"))
+			failed += total
+			continue
+		}
+		if !opts.silent {
+			verb := "patching"
+			if opts.dryRun {
+				verb = "checking"
+			}
+			fmt.Fprintf(out, "%s file %s\n", verb, name)
+		}
+
+		result, rejects := opts.applyChunks(content, patch.chunks, out)
+		if opts.output != "" {
+			name = opts.output
+		}
+		if !opts.dryRun {
+			if err := writePatchFile(files, name, result); err != nil {
+				return err
+			}
+		}
+		if len(rejects) == 0 {
+			continue
+		}
+		failed += len(rejects)
+		fmt.Fprintf(out, "%d out of %d %s FAILED", len(rejects), total, plural(total, "hunk"))
+		if opts.dryRun {
+			fmt.Fprintln(out)
+			continue
+		}
+		fmt.Fprintf(out, " -- saving rejects to file %s.rej\n", name)
+		reject := fmt.Sprintf("--- %s\n+++ %s\n", patch.oldName, patch.newName)
+		for _, chunk := range rejects {
+			reject += strings.Join(chunk.text, "\n") + "\n"
+		}
+		if err := writePatchFile(files, name+".rej", reject); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		out.Flush()
+		return fmt.Errorf("patch: %d %s FAILED", failed, plural(failed, "hunk"))
+	}
+	return nil
+}
+
+// plural returns word, with an s unless n is one
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
+
+// findTarget returns the name and content of the file a patch applies to:
+// the file operand, or the old or new name of the patch, whichever exists.
+// A patch from /dev/null, or one that only adds lines, creates its file.
+func (o patchOptions) findTarget(patch filePatch, files Files) (string, string, bool) {
+	names := []string{o.target}
+	if o.target == "" {
+		names = []string{o.stripName(patch.oldName), o.stripName(patch.newName)}
+	}
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		file, err := files.Open(name)
+		if err != nil {
+			continue
+		}
+		content, err := io.ReadAll(file)
+		file.Close()
+		if err == nil {
+			return name, string(content), true
+		}
+	}
+
+	creates := true
+	for _, chunk := range patch.chunks {
+		creates = creates && chunk.OldLines == 0
+	}
+	name := names[len(names)-1]
+	if name == "" {
+		name = names[0]
+	}
+	return name, "", creates && name != ""
+}
+
+// applyChunks applies the hunks of a patch to the content of a file and
+// returns the result and the hunks that did not apply. A hunk is looked
+// for where the patch puts it, moved by the offset the hunk before it
+// needed, then ever further away; failing that, with up to -F lines of
+// context at either end ignored.
+func (o patchOptions) applyChunks(content string, chunks []PatchChunk, out io.Writer) (string, []PatchChunk) {
+	lines, noEOL := diffLines(content)
+	var result []string
+	var rejects []PatchChunk
+	pos, lastOffset := 0, 0
+	for n, chunk := range chunks {
+		var before, after []string
+		lead, trail := 0, 0
+		for _, change := range chunk.Changes {
+			if change.Type != "+" {
+				before = append(before, change.Content)
+			}
+			if change.Type != "-" {
+				after = append(after, change.Content)
+			}
+			if change.Type != " " {
+				trail = 0
+				continue
+			}
+			trail++
+			if len(before) == trail && len(after) == trail {
+				lead++
+			}
+		}
+
+		// The line the hunk starts at, counting from zero
+		start := chunk.OldStart - 1
+		if chunk.OldLines == 0 {
+			start = chunk.OldStart
+		}
+
+		at, fuzz := -1, 0
+		for ; fuzz <= o.fuzz; fuzz++ {
+			top, bottom := min(fuzz, lead), min(fuzz, trail)
+			if top+bottom >= len(before) && fuzz > 0 {
+				break
+			}
+			at = findLines(lines, before[top:len(before)-bottom], start+top+lastOffset, pos)
+			if at < 0 {
+				continue
+			}
+			result = append(result, lines[pos:at]...)
+			result = append(result, after[top:len(after)-bottom]...)
+			pos = at + len(before) - top - bottom
+			if bottom == 0 && pos == len(lines) {
+				noEOL = chunk.newNoEOL
+			}
+			at -= top
+			break
+		}
+
+		if at < 0 {
+			fmt.Fprintf(out, "Hunk #%d FAILED at %d.\n", n+1, chunk.OldStart+lastOffset)
+			rejects = append(rejects, chunk)
+			continue
+		}
+		offset := at - start
+		lastOffset = offset
+		if (fuzz == 0 && offset == 0) || o.silent {
+			continue
+		}
+		fmt.Fprintf(out, "Hunk #%d succeeded at %d", n+1, chunk.NewStart+offset)
+		if fuzz > 0 {
+			fmt.Fprintf(out, " with fuzz %d", fuzz)
+		}
+		if offset != 0 {
+			fmt.Fprintf(out, " (offset %d %s)", offset, plural(max(offset, -offset), "line"))
+		}
+		fmt.Fprintln(out, ".")
+	}
+	result = append(result, lines[pos:]...)
+
+	if len(result) == 0 {
+		return "", rejects
+	}
+	text := strings.Join(result, "\n")
+	if !noEOL {
+		text += "\n"
+	}
+	return text, rejects
+}
+
+// findLines returns where want is in lines, looking at near first and then
+// ever further away, but not before from; or -1 if it is nowhere
+func findLines(lines, want []string, near, from int) int {
+	last := len(lines) - len(want)
+	near = min(max(near, from), max(last, from))
+	for distance := 0; near-distance >= from || near+distance <= last; distance++ {
+		for _, at := range []int{near - distance, near + distance} {
+			if at < from || at > last {
+				continue
+			}
+			matched := true
+			for i, line := range want {
+				if lines[at+i] != line {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				return at
+			}
+		}
+	}
+	return -1
+}
+
+// writePatchFile writes a patched file or a reject file
+func writePatchFile(files Files, name, content string) error {
+	file, err := files.Create(name)
+	if err != nil {
+		return fmt.Errorf("patch: %s: %w", name, err)
+	}
+	if _, err := io.WriteString(file, content); err != nil {
+		file.Close()
+		return fmt.Errorf("patch: %s: %w", name, err)
+	}
+	return file.Close()
+}
@@ -1,6 +1,7 @@
 package builtin
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 )
@@ -103,13 +104,21 @@ line 3
 			input: "",
 			expectedOutput: `patch - Apply unified diff patches to text
 
-Usage: patch [--dry-run]
+Usage: patch [-pN] [-F N] [--dry-run] [-i PATCHFILE] [-o OUTFILE] [FILE]
 
 Options:
+  -pN, --strip=N    Strip N leading directories from file names in the patch
+  -F N, --fuzz=N    Ignore up to N lines of context of hunks that do not match (default 2)
   --dry-run         Don't actually apply patch (validation only)
+  -i PATCHFILE      Read the patch from PATCHFILE instead of stdin
+  -o OUTFILE        Write the patched file to OUTFILE
+  -s, --silent      Only report failures
   --help, -h        Show this help message
 
-Input format: original_text + ---LLMCMD_PATCH_SEPARATOR--- + patch_content
+The patch on stdin is applied to the files it names; hunks that fail are
+saved to FILE.rej.
+
+Input format without files: original_text + ---LLMCMD_PATCH_SEPARATOR--- + patch_content
 `,
 		},
 	}
@@ -138,3 +147,119 @@ Input format: original_text + ---LLMCMD_PATCH_SEPARATOR--- + patch_content
 		})
 	}
 }
+
+func TestPatchFiles(t *testing.T) {
+	const original = "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	tests := []struct {
+		name   string
+		args   []string
+		files  map[string]string // Files besides src/num.txt, which is original
+		patch  string
+		output string
+		err    string
+		want   map[string]string // Expected files; a missing name is not checked
+	}{
+		{
+			name:   "strip one directory",
+			args:   []string{"-p1"},
+			patch:  "--- a/src/num.txt\n+++ b/src/num.txt\n@@ -2,3 +2,3 @@\n two\n-three\n+THREE\n four\n",
+			output: "patching file src/num.txt\n",
+			want:   map[string]string{"src/num.txt": strings.Replace(original, "three", "THREE", 1)},
+		},
+		{
+			name:   "base name without -p",
+			files:  map[string]string{"num.txt": "two\nthree\nfour\n"},
+			patch:  "--- a/src/num.txt\n+++ b/src/num.txt\n@@ -1,3 +1,3 @@\n two\n-three\n+THREE\n four\n",
+			output: "patching file num.txt\n",
+			want:   map[string]string{"num.txt": "two\nTHREE\nfour\n", "src/num.txt": original},
+		},
+		{
+			name:   "offset carried to the next hunk",
+			args:   []string{"-p1"},
+			patch:  "--- a/src/num.txt\n+++ b/src/num.txt\n@@ -2,3 +2,3 @@\n four\n-five\n+FIVE\n six\n@@ -7,2 +7,2 @@\n nine\n-ten\n+TEN\n",
+			output: "patching file src/num.txt\nHunk #1 succeeded at 4 (offset 2 lines).\nHunk #2 succeeded at 9 (offset 2 lines).\n",
+			want:   map[string]string{"src/num.txt": "one\ntwo\nthree\nfour\nFIVE\nsix\nseven\neight\nnine\nTEN\n"},
+		},
+		{
+			name:   "fuzz",
+			args:   []string{"-p1"},
+			patch:  "--- a/src/num.txt\n+++ b/src/num.txt\n@@ -3,5 +3,5 @@\n changed\n four\n-five\n+FIVE\n six\n seven\n",
+			output: "patching file src/num.txt\nHunk #1 succeeded at 3 with fuzz 1.\n",
+			want:   map[string]string{"src/num.txt": "one\ntwo\nthree\nfour\nFIVE\nsix\nseven\neight\nnine\nten\n"},
+		},
+		{
+			name:   "rejects",
+			args:   []string{"-p1"},
+			patch:  "--- a/src/num.txt\n+++ b/src/num.txt\n@@ -1,2 +1,2 @@\n-one\n+ONE\n two\n@@ -5,1 +5,1 @@\n-cinq\n+FIVE\n",
+			output: "patching file src/num.txt\nHunk #2 FAILED at 5.\n1 out of 2 hunks FAILED -- saving rejects to file src/num.txt.rej\n",
+			err:    "patch: 1 hunk FAILED",
+			want: map[string]string{
+				"src/num.txt":     strings.Replace(original, "one", "ONE", 1),
+				"src/num.txt.rej": "--- a/src/num.txt\n+++ b/src/num.txt\n@@ -5,1 +5,1 @@\n-cinq\n+FIVE\n",
+			},
+		},
+		{
+			name:   "dry run",
+			args:   []string{"--dry-run", "-p", "1"},
+			patch:  "--- a/src/num.txt\n+++ b/src/num.txt\n@@ -5 +5 @@\n-cinq\n+FIVE\n",
+			output: "checking file src/num.txt\nHunk #1 FAILED at 5.\n1 out of 1 hunk FAILED\n",
+			err:    "patch: 1 hunk FAILED",
+			want:   map[string]string{"src/num.txt": original, "src/num.txt.rej": ""},
+		},
+		{
+			name:   "new file and missing newline",
+			args:   []string{"--strip=1"},
+			patch:  "--- /dev/null\n+++ b/docs/new.md\n@@ -0,0 +1,2 @@\n+# New\n+text\n\\ No newline at end of file\n",
+			output: "patching file docs/new.md\n",
+			want:   map[string]string{"docs/new.md": "# New\ntext"},
+		},
+		{
+			name:   "file operand and output",
+			args:   []string{"-o", "out.txt", "src/num.txt"},
+			patch:  "--- old\n+++ new\n@@ -10 +10,2 @@\n ten\n+eleven\n",
+			output: "patching file src/num.txt\n",
+			want:   map[string]string{"out.txt": original + "eleven\n", "src/num.txt": original},
+		},
+		{
+			name:   "missing file",
+			args:   []string{"-p0"},
+			patch:  "--- a/src/num.txt\n+++ b/src/num.txt\n@@ -1 +1 @@\n-one\n+ONE\n",
+			output: "can't find file to patch at input line 1\nPerhaps you used the wrong -p or --strip option?\n1 out of 1 hunk ignored\n",
+			err:    "patch: 1 hunk FAILED",
+		},
+		{
+			name:  "garbage",
+			patch: "no patch here\n",
+			err:   "patch: only garbage was found in the patch input",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := memoryFiles{"src/num.txt": bytes.NewBufferString(original)}
+			for name, content := range tt.files {
+				files[name] = bytes.NewBufferString(content)
+			}
+			var output strings.Builder
+			err := RunPatch(tt.args, strings.NewReader(tt.patch), &output, files)
+			if tt.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err != "" && (err == nil || err.Error() != tt.err) {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+			if output.String() != tt.output {
+				t.Errorf("expected output %q, got %q", tt.output, output.String())
+			}
+			for name, want := range tt.want {
+				got, ok := files[name]
+				if want == "" && !ok {
+					continue
+				}
+				if !ok || got.String() != want {
+					t.Errorf("%s: expected %q, got %v", name, want, got)
+				}
+			}
+		})
+	}
+}
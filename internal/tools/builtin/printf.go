@@ -0,0 +1,293 @@
+package builtin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Printf formats its arguments under control of the format, like the
+// printf of the shell: backslash escapes are understood, conversions are
+// %d, %i, %o, %u, %x, %X, %f, %e, %g, %c, %s, %b (the argument's escapes
+// expanded) and %q (the argument quoted for the shell), and the format is
+// used again as long as arguments remain. A numeric argument that is not a
+// number is reported after the output is written, as 0.
+func Printf(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("printf: missing operand")
+	}
+	p := &printfFormatter{args: args[1:]}
+	out := bufio.NewWriter(stdout)
+	for {
+		remaining := len(p.args)
+		text, stop, err := p.format(args[0])
+		if err != nil {
+			return err
+		}
+		out.WriteString(text)
+		// The format is reused for the remaining arguments, unless it took
+		// none of them
+		if stop || len(p.args) == 0 || len(p.args) == remaining {
+			break
+		}
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	return p.err
+}
+
+// printfFormatter formats printf's arguments, remembering the first
+// argument that was not a valid number
+type printfFormatter struct {
+	args []string
+	err  error
+}
+
+func (p *printfFormatter) next() string {
+	if len(p.args) == 0 {
+		return ""
+	}
+	arg := p.args[0]
+	p.args = p.args[1:]
+	return arg
+}
+
+// format formats the arguments once. stop is set by \c, which ends the
+// output.
+func (p *printfFormatter) format(format string) (text string, stop bool, err error) {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		switch format[i] {
+		case '\\':
+			n, stop := printfEscape(&b, format[i:], false)
+			if stop {
+				return b.String(), true, nil
+			}
+			i += n - 1
+			continue
+		case '%':
+		default:
+			b.WriteByte(format[i])
+			continue
+		}
+
+		start := i
+		i++
+		if i < len(format) && format[i] == '%' {
+			b.WriteByte('%')
+			continue
+		}
+		spec := []byte{'%'}
+		for i < len(format) && strings.IndexByte("-+ #0", format[i]) >= 0 {
+			spec = append(spec, format[i])
+			i++
+		}
+		// Width and precision, either of which may be * to take an argument
+		hasPrecision := false
+		for part := 0; part < 2; part++ {
+			if part == 1 {
+				if i == len(format) || format[i] != '.' {
+					break
+				}
+				hasPrecision = true
+				spec = append(spec, '.')
+				i++
+			}
+			if i < len(format) && format[i] == '*' {
+				spec = strconv.AppendInt(spec, p.integer(p.next()), 10)
+				i++
+				continue
+			}
+			for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+				spec = append(spec, format[i])
+				i++
+			}
+		}
+		if i == len(format) {
+			return "", false, fmt.Errorf("printf: %s: missing conversion specifier", format[start:])
+		}
+
+		switch verb := format[i]; verb {
+		case 'd', 'i':
+			fmt.Fprintf(&b, string(append(spec, 'd')), p.integer(p.next()))
+		case 'u', 'o', 'x', 'X':
+			if verb == 'u' {
+				verb = 'd'
+			}
+			// Negative numbers are printed in two's complement, as in C
+			fmt.Fprintf(&b, string(append(spec, verb)), uint64(p.integer(p.next())))
+		case 'f', 'F', 'e', 'E', 'g', 'G':
+			if verb == 'F' {
+				verb = 'f'
+			}
+			if (verb == 'g' || verb == 'G') && !hasPrecision {
+				spec = append(spec, ".6"...)
+			}
+			fmt.Fprintf(&b, string(append(spec, verb)), p.float(p.next()))
+		case 'c':
+			s := p.next()
+			if r, size := utf8.DecodeRuneInString(s); size > 0 {
+				s = string(r)
+			}
+			fmt.Fprintf(&b, string(append(spec, 's')), s)
+		case 's':
+			fmt.Fprintf(&b, string(append(spec, 's')), p.next())
+		case 'b':
+			var expanded strings.Builder
+			arg := p.next()
+			stop := false
+			for k := 0; k < len(arg) && !stop; k++ {
+				if arg[k] != '\\' {
+					expanded.WriteByte(arg[k])
+					continue
+				}
+				var n int
+				n, stop = printfEscape(&expanded, arg[k:], true)
+				k += n - 1
+			}
+			fmt.Fprintf(&b, string(append(spec, 's')), expanded.String())
+			if stop {
+				return b.String(), true, nil
+			}
+		case 'q':
+			fmt.Fprintf(&b, string(append(spec, 's')), shellQuote(p.next()))
+		default:
+			return "", false, fmt.Errorf("printf: %s: invalid conversion specification", format[start:i+1])
+		}
+	}
+	return b.String(), false, nil
+}
+
+// integer converts an argument to an integer as printf does: decimal, 0x
+// hexadecimal or 0 octal, or the code of the character after a quote
+func (p *printfFormatter) integer(arg string) int64 {
+	if arg == "" {
+		return 0
+	}
+	if arg[0] == '\'' || arg[0] == '"' {
+		r, _ := utf8.DecodeRuneInString(arg[1:])
+		return int64(r)
+	}
+	n, err := strconv.ParseInt(strings.TrimPrefix(arg, "+"), 0, 64)
+	if err != nil {
+		// Values too large for int64 may still fit %u and %x
+		if u, uerr := strconv.ParseUint(strings.TrimPrefix(arg, "+"), 0, 64); uerr == nil {
+			return int64(u)
+		}
+		p.invalid(arg)
+		return 0
+	}
+	return n
+}
+
+func (p *printfFormatter) float(arg string) float64 {
+	if arg == "" {
+		return 0
+	}
+	if arg[0] == '\'' || arg[0] == '"' {
+		r, _ := utf8.DecodeRuneInString(arg[1:])
+		return float64(r)
+	}
+	f, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		if n, ierr := strconv.ParseInt(arg, 0, 64); ierr == nil {
+			return float64(n)
+		}
+		p.invalid(arg)
+		return 0
+	}
+	return f
+}
+
+func (p *printfFormatter) invalid(arg string) {
+	if p.err == nil {
+		p.err = fmt.Errorf("printf: '%s': expected a numeric value", arg)
+	}
+}
+
+// printfEscape writes the character of the backslash escape s starts with
+// and returns its length; stop is set by \c. In the argument of %b, octal
+// escapes are written \0NNN instead of \NNN.
+func printfEscape(b *strings.Builder, s string, inArgument bool) (n int, stop bool) {
+	if len(s) < 2 {
+		b.WriteByte('\\')
+		return 1, false
+	}
+	simple := map[byte]byte{
+		'\\': '\\', 'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n',
+		'r': '\r', 't': '\t', 'v': '\v', '"': '"', '\'': '\'',
+	}
+	if c, ok := simple[s[1]]; ok {
+		b.WriteByte(c)
+		return 2, false
+	}
+	digits := func(start, limit, base int) (int, int) {
+		value, end := 0, start
+		for end < len(s) && end-start < limit {
+			d, err := strconv.ParseUint(s[end:end+1], base, 8)
+			if err != nil {
+				break
+			}
+			value = value*base + int(d)
+			end++
+		}
+		return value, end
+	}
+	switch c := s[1]; {
+	case c == 'c':
+		return 2, true
+	case c >= '0' && c <= '7':
+		start := 1
+		if inArgument && c == '0' {
+			start = 2
+		}
+		value, end := digits(start, 3, 8)
+		b.WriteByte(byte(value))
+		return end, false
+	case c == 'x':
+		value, end := digits(2, 2, 16)
+		if end == 2 {
+			break
+		}
+		b.WriteByte(byte(value))
+		return end, false
+	case c == 'u' || c == 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		value, end := digits(2, size, 16)
+		if end == 2 {
+			break
+		}
+		b.WriteRune(rune(value))
+		return end, false
+	}
+	b.WriteString(s[:2])
+	return 2, false
+}
+
+// shellQuote quotes s so the shell reads it back as one word
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("@%+=:,./-_", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
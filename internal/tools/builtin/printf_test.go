@@ -0,0 +1,99 @@
+package builtin
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrintf(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name:           "strings and escapes",
+			args:           []string{`%s=%s\n`, "a", "1"},
+			expectedOutput: "a=1\n",
+		},
+		{
+			name:           "format reused for remaining arguments",
+			args:           []string{`%s:%d\n`, "x", "1", "y"},
+			expectedOutput: "x:1\ny:0\n",
+		},
+		{
+			name:           "integer conversions",
+			args:           []string{"%d %i %o %x %X %u|%5d|%-5d|%05d|%+d", "42", "0x10", "8", "255", "255", "-1", "7", "7", "7", "7"},
+			expectedOutput: "42 16 10 ff FF 18446744073709551615|    7|7    |00007|+7",
+		},
+		{
+			name:           "floating point conversions",
+			args:           []string{"%.2f %e %g %g %G", "3.14159", "1234.5", "0.0001", "1e10", "2.5"},
+			expectedOutput: "3.14 1.234500e+03 0.0001 1e+10 2.5",
+		},
+		{
+			name:           "width and precision from arguments",
+			args:           []string{"[%*.*s]", "6", "3", "abcdef"},
+			expectedOutput: "[   abc]",
+		},
+		{
+			name:           "character codes and characters",
+			args:           []string{"%d %c %c", "'A", "hello", "é"},
+			expectedOutput: "65 h é",
+		},
+		{
+			name:           "octal, hex and unicode escapes",
+			args:           []string{`\101\x42é\\%%`},
+			expectedOutput: `ABé\%`,
+		},
+		{
+			name:           "expanded argument",
+			args:           []string{"%b|%s", `a\tb\0101`, `a\tb`},
+			expectedOutput: "a\tbA|a\\tb",
+		},
+		{
+			name:           "stop output",
+			args:           []string{"%s %b never", "shown", `here\c`},
+			expectedOutput: "shown here",
+		},
+		{
+			name:           "quoted for the shell",
+			args:           []string{"%q %q %q", "plain", "it's", ""},
+			expectedOutput: `plain 'it'\''s' ''`,
+		},
+		{
+			name:           "invalid number",
+			args:           []string{"%d|%s", "abc", "ok"},
+			expectedOutput: "0|ok",
+			expectedError:  "printf: 'abc': expected a numeric value",
+		},
+		{
+			name:          "invalid conversion",
+			args:          []string{"%y"},
+			expectedError: "printf: %y: invalid conversion specification",
+		},
+		{
+			name:          "missing format",
+			expectedError: "printf: missing operand",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output bytes.Buffer
+			err := Printf(test.args, strings.NewReader(""), &output)
+			if test.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Errorf("expected error containing %q, got %v", test.expectedError, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != test.expectedOutput {
+				t.Errorf("expected output %q, got %q", test.expectedOutput, output.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,677 @@
+package builtin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Join joins the lines of two sorted files on a common field. Without
+// access to files only one of them can be stdin, so use RunJoin.
+func Join(args []string, stdin io.Reader, stdout io.Writer) error {
+	return RunJoin(args, stdin, stdout, nil)
+}
+
+// Paste merges the lines of its inputs side by side
+func Paste(args []string, stdin io.Reader, stdout io.Writer) error {
+	return RunPaste(args, stdin, stdout, nil)
+}
+
+// Comm compares two sorted files line by line. Without access to files
+// only one of them can be stdin, so use RunComm.
+func Comm(args []string, stdin io.Reader, stdout io.Writer) error {
+	return RunComm(args, stdin, stdout, nil)
+}
+
+// readOperandLines reads the lines of each operand, "-" meaning stdin. A
+// final line without a newline still counts.
+func readOperandLines(command string, operands []string, stdin io.Reader, open OpenFunc) ([][]string, error) {
+	stdinUsed := false
+	result := make([][]string, len(operands))
+	for i, name := range operands {
+		reader := stdin
+		if name == "-" {
+			if stdinUsed {
+				return nil, fmt.Errorf("%s: both files cannot be standard input", command)
+			}
+			stdinUsed = true
+		} else {
+			if open == nil {
+				return nil, fmt.Errorf("%s: %s: file operands are not supported; pipe the input instead", command, name)
+			}
+			file, err := open(name)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", command, name, err)
+			}
+			defer file.Close()
+			reader = file
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", command, name, err)
+		}
+		text := strings.TrimSuffix(string(data), "\n")
+		if len(data) > 0 {
+			result[i] = strings.Split(text, "\n")
+		}
+	}
+	return result, nil
+}
+
+// twoOperands checks that exactly two file operands were given
+func twoOperands(command string, operands []string) error {
+	switch len(operands) {
+	case 0:
+		return fmt.Errorf("%s: missing operand", command)
+	case 1:
+		return fmt.Errorf("%s: missing operand after '%s'", command, operands[0])
+	case 2:
+		return nil
+	}
+	return fmt.Errorf("%s: extra operand '%s'", command, operands[2])
+}
+
+// joinField is an output field of join -o: file 0 is the join field
+type joinField struct {
+	file  int
+	field int
+}
+
+// joinOptions are the parsed options of a join invocation
+type joinOptions struct {
+	fields       [2]int // Join fields, 0-based
+	separator    string // Input and output separator; blanks if unset
+	hasSep       bool
+	unpaired     [2]bool // -a: print unpairable lines of the file
+	onlyUnpaired bool    // -v: print only unpairable lines
+	format       []joinField
+	autoFormat   bool
+	empty        string
+	ignoreCase   bool
+	header       bool
+	checkOrder   bool
+	noCheck      bool
+	operands     []string
+}
+
+// RunJoin joins the lines of two files sorted on the join field, like GNU
+// join: for each pair of lines with equal keys it prints the key and the
+// other fields of both lines. -1, -2 and -j select the fields, -t the
+// separator (blanks by default), -a and -v print unpairable lines, -o
+// chooses the output fields and -e fills in missing ones. Either file may
+// be "-" for stdin.
+func RunJoin(args []string, stdin io.Reader, stdout io.Writer, open OpenFunc) error {
+	opts, err := parseJoinArgs(args)
+	if err != nil {
+		return err
+	}
+	if err := twoOperands("join", opts.operands); err != nil {
+		return err
+	}
+	inputs, err := readOperandLines("join", opts.operands, stdin, open)
+	if err != nil {
+		return err
+	}
+
+	j := &joiner{opts: opts, outSep: " ", out: bufio.NewWriter(stdout)}
+	if opts.hasSep {
+		j.outSep = opts.separator
+	}
+	if opts.autoFormat {
+		j.setAutoFormat(inputs)
+	}
+	lines1, lines2 := inputs[0], inputs[1]
+	if opts.header && len(lines1) > 0 && len(lines2) > 0 {
+		j.writeLine(j.split(lines1[0]), j.split(lines2[0]))
+		lines1, lines2 = lines1[1:], lines2[1:]
+	}
+
+	var disorder error
+	for i, lines := range [][]string{lines1, lines2} {
+		if n := j.unsortedAt(lines, i); n > 0 && disorder == nil {
+			lineNumber := n + 1
+			if opts.header {
+				lineNumber++
+			}
+			disorder = fmt.Errorf("join: %s:%d: is not sorted: %s", opts.operands[i], lineNumber, lines[n])
+		}
+	}
+	if disorder != nil && opts.checkOrder {
+		return disorder
+	}
+
+	a, b := 0, 0
+	for a < len(lines1) && b < len(lines2) {
+		fields1, fields2 := j.split(lines1[a]), j.split(lines2[b])
+		switch c := strings.Compare(j.key(fields1, 0), j.key(fields2, 1)); {
+		case c < 0:
+			j.writeUnpaired(0, fields1)
+			a++
+		case c > 0:
+			j.writeUnpaired(1, fields2)
+			b++
+		default:
+			key := j.key(fields1, 0)
+			endA, endB := a+1, b+1
+			for endA < len(lines1) && j.key(j.split(lines1[endA]), 0) == key {
+				endA++
+			}
+			for endB < len(lines2) && j.key(j.split(lines2[endB]), 1) == key {
+				endB++
+			}
+			if !opts.onlyUnpaired {
+				for _, line1 := range lines1[a:endA] {
+					for _, line2 := range lines2[b:endB] {
+						j.writeLine(j.split(line1), j.split(line2))
+					}
+				}
+			}
+			a, b = endA, endB
+		}
+	}
+	for ; a < len(lines1); a++ {
+		j.writeUnpaired(0, j.split(lines1[a]))
+	}
+	for ; b < len(lines2); b++ {
+		j.writeUnpaired(1, j.split(lines2[b]))
+	}
+
+	if err := j.out.Flush(); err != nil {
+		return err
+	}
+	if disorder != nil && !opts.noCheck {
+		return disorder
+	}
+	return nil
+}
+
+// parseJoinArgs parses join's options; those taking a value take it from
+// the rest of the argument or the next one, as in -t: or -t ':'
+func parseJoinArgs(args []string) (joinOptions, error) {
+	var opts joinOptions
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--":
+			opts.operands = append(opts.operands, args[i+1:]...)
+			return opts, nil
+		case "--ignore-case":
+			opts.ignoreCase = true
+			continue
+		case "--header":
+			opts.header = true
+			continue
+		case "--check-order":
+			opts.checkOrder = true
+			continue
+		case "--nocheck-order":
+			opts.noCheck = true
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			opts.operands = append(opts.operands, arg)
+			continue
+		}
+		if strings.HasPrefix(arg, "--") {
+			return opts, fmt.Errorf("join: %s: invalid option", arg)
+		}
+		for k := 1; k < len(arg); k++ {
+			c := arg[k]
+			if c == 'i' {
+				opts.ignoreCase = true
+				continue
+			}
+			if !strings.ContainsRune("12jtavoe", rune(c)) {
+				return opts, fmt.Errorf("join: -%c: invalid option", c)
+			}
+			value := arg[k+1:]
+			if value == "" {
+				if i+1 == len(args) {
+					return opts, fmt.Errorf("join: -%c: missing argument", c)
+				}
+				i++
+				value = args[i]
+			}
+			if err := opts.set(c, value); err != nil {
+				return opts, err
+			}
+			break
+		}
+	}
+	return opts, nil
+}
+
+// set applies an option that takes a value
+func (opts *joinOptions) set(option byte, value string) error {
+	switch option {
+	case '1', '2', 'j':
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("join: invalid field number: '%s'", value)
+		}
+		if option != '2' {
+			opts.fields[0] = n - 1
+		}
+		if option != '1' {
+			opts.fields[1] = n - 1
+		}
+	case 't':
+		if len([]rune(value)) > 1 {
+			return fmt.Errorf("join: multi-character tab '%s'", value)
+		}
+		opts.separator, opts.hasSep = value, true
+	case 'a', 'v':
+		if value != "1" && value != "2" {
+			return fmt.Errorf("join: invalid file number: '%s'", value)
+		}
+		opts.unpaired[value[0]-'1'] = true
+		if option == 'v' {
+			opts.onlyUnpaired = true
+		}
+	case 'o':
+		if value == "auto" {
+			opts.autoFormat = true
+			return nil
+		}
+		for _, spec := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+			if spec == "0" {
+				opts.format = append(opts.format, joinField{})
+				continue
+			}
+			file, field, _ := strings.Cut(spec, ".")
+			n, err := strconv.Atoi(field)
+			if (file != "1" && file != "2") || err != nil || n <= 0 {
+				return fmt.Errorf("join: invalid field specifier: '%s'", spec)
+			}
+			opts.format = append(opts.format, joinField{file: int(file[0] - '0'), field: n - 1})
+		}
+	case 'e':
+		opts.empty = value
+	}
+	return nil
+}
+
+// joiner writes the output of a join
+type joiner struct {
+	opts   joinOptions
+	outSep string
+	out    *bufio.Writer
+}
+
+// split splits a line into fields: at the separator if one was given, the
+// whole line being one field if it is empty, or else at runs of blanks
+func (j *joiner) split(line string) []string {
+	switch {
+	case !j.opts.hasSep:
+		return strings.FieldsFunc(line, func(r rune) bool { return r == ' ' || r == '\t' })
+	case j.opts.separator == "":
+		return []string{line}
+	}
+	return strings.Split(line, j.opts.separator)
+}
+
+// key returns the join field of a line of file i (0 or 1), as compared
+func (j *joiner) key(fields []string, i int) string {
+	key := ""
+	if n := j.opts.fields[i]; n < len(fields) {
+		key = fields[n]
+	}
+	if j.opts.ignoreCase {
+		key = strings.ToLower(key)
+	}
+	return key
+}
+
+// unsortedAt returns the index of the first line of file i whose key sorts
+// before the key of the line before it, or 0 if the lines are sorted
+func (j *joiner) unsortedAt(lines []string, i int) int {
+	for n := 1; n < len(lines); n++ {
+		if j.key(j.split(lines[n-1]), i) > j.key(j.split(lines[n]), i) {
+			return n
+		}
+	}
+	return 0
+}
+
+// setAutoFormat makes -o auto's format: the join field, then the other
+// fields of the first line of each file
+func (j *joiner) setAutoFormat(inputs [][]string) {
+	j.opts.format = []joinField{{}}
+	for i, lines := range inputs {
+		count := 0
+		if len(lines) > 0 {
+			count = len(j.split(lines[0]))
+		}
+		for n := 0; n < count; n++ {
+			if n != j.opts.fields[i] {
+				j.opts.format = append(j.opts.format, joinField{file: i + 1, field: n})
+			}
+		}
+	}
+}
+
+func (j *joiner) field(fields []string, n int) string {
+	if fields != nil && n < len(fields) {
+		return fields[n]
+	}
+	return j.opts.empty
+}
+
+// writeLine writes the output for a pair of lines; one of them is nil for
+// an unpairable line
+func (j *joiner) writeLine(fields1, fields2 []string) {
+	var out []string
+	if j.opts.format != nil {
+		for _, f := range j.opts.format {
+			switch {
+			case f.file == 1:
+				out = append(out, j.field(fields1, f.field))
+			case f.file == 2:
+				out = append(out, j.field(fields2, f.field))
+			case fields1 != nil:
+				out = append(out, j.field(fields1, j.opts.fields[0]))
+			default:
+				out = append(out, j.field(fields2, j.opts.fields[1]))
+			}
+		}
+	} else {
+		if fields1 != nil {
+			out = append(out, j.field(fields1, j.opts.fields[0]))
+		} else {
+			out = append(out, j.field(fields2, j.opts.fields[1]))
+		}
+		for i, fields := range [][]string{fields1, fields2} {
+			for n, field := range fields {
+				if n != j.opts.fields[i] {
+					out = append(out, field)
+				}
+			}
+		}
+	}
+	j.out.WriteString(strings.Join(out, j.outSep))
+	j.out.WriteByte('\n')
+}
+
+// writeUnpaired writes an unpairable line of file i if -a or -v asks for it
+func (j *joiner) writeUnpaired(i int, fields []string) {
+	if !j.opts.unpaired[i] {
+		return
+	}
+	if i == 0 {
+		j.writeLine(fields, nil)
+	} else {
+		j.writeLine(nil, fields)
+	}
+}
+
+// RunPaste writes the first lines of its inputs side by side, separated by
+// tabs, then the second lines and so on, like GNU paste. -d gives a list of
+// delimiters used in turn, -s pastes the lines of each input into one line
+// instead. The inputs are stdin ("-" or no operands) and files; each "-"
+// takes the next line of stdin, so paste - - joins pairs of lines.
+func RunPaste(args []string, stdin io.Reader, stdout io.Writer, open OpenFunc) error {
+	delimiters := []string{"\t"}
+	serial := false
+	terminator := byte('\n')
+	var operands []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			operands = append(operands, args[i+1:]...)
+			i = len(args)
+			continue
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			operands = append(operands, arg)
+			continue
+		case arg == "--serial":
+			serial = true
+			continue
+		case arg == "--zero-terminated":
+			terminator = 0
+			continue
+		case strings.HasPrefix(arg, "--delimiters="):
+			delimiters = parsePasteDelimiters(strings.TrimPrefix(arg, "--delimiters="))
+			continue
+		case strings.HasPrefix(arg, "--"):
+			return fmt.Errorf("paste: %s: invalid option", arg)
+		}
+		for k := 1; k < len(arg); k++ {
+			switch c := arg[k]; c {
+			case 's':
+				serial = true
+			case 'z':
+				terminator = 0
+			case 'd':
+				value := arg[k+1:]
+				if value == "" {
+					if i+1 == len(args) {
+						return fmt.Errorf("paste: -d: missing argument")
+					}
+					i++
+					value = args[i]
+				}
+				delimiters = parsePasteDelimiters(value)
+				k = len(arg)
+			default:
+				return fmt.Errorf("paste: -%c: invalid option", c)
+			}
+		}
+	}
+	if len(operands) == 0 {
+		operands = []string{"-"}
+	}
+
+	// All "-" operands share one reader, so each takes the next line
+	shared := bufio.NewReader(stdin)
+	readers := make([]*bufio.Reader, len(operands))
+	for i, name := range operands {
+		if name == "-" {
+			readers[i] = shared
+			continue
+		}
+		if open == nil {
+			return fmt.Errorf("paste: %s: file operands are not supported; pipe the input instead", name)
+		}
+		file, err := open(name)
+		if err != nil {
+			return fmt.Errorf("paste: %s: %w", name, err)
+		}
+		defer file.Close()
+		readers[i] = bufio.NewReader(file)
+	}
+	readLine := func(r *bufio.Reader) (string, bool, error) {
+		line, err := r.ReadString(terminator)
+		if err == io.EOF {
+			return line, line != "", nil
+		}
+		return strings.TrimSuffix(line, string(terminator)), true, err
+	}
+
+	out := bufio.NewWriter(stdout)
+	if serial {
+		for i, r := range readers {
+			for n := 0; ; n++ {
+				line, ok, err := readLine(r)
+				if err != nil {
+					return fmt.Errorf("paste: %s: %w", operands[i], err)
+				}
+				if !ok {
+					break
+				}
+				if n > 0 {
+					out.WriteString(delimiters[(n-1)%len(delimiters)])
+				}
+				out.WriteString(line)
+			}
+			out.WriteByte(terminator)
+		}
+		return out.Flush()
+	}
+
+	done := make([]bool, len(readers))
+	for {
+		var fields []string
+		anyLine := false
+		for i, r := range readers {
+			line := ""
+			if !done[i] {
+				var ok bool
+				var err error
+				line, ok, err = readLine(r)
+				if err != nil {
+					return fmt.Errorf("paste: %s: %w", operands[i], err)
+				}
+				done[i] = !ok
+				anyLine = anyLine || ok
+			}
+			fields = append(fields, line)
+		}
+		if !anyLine {
+			break
+		}
+		for i, field := range fields {
+			if i > 0 {
+				out.WriteString(delimiters[(i-1)%len(delimiters)])
+			}
+			out.WriteString(field)
+		}
+		out.WriteByte(terminator)
+	}
+	return out.Flush()
+}
+
+// parsePasteDelimiters splits a -d list into delimiters, understanding
+// \n, \t, \\ and \0, the empty delimiter
+func parsePasteDelimiters(list string) []string {
+	var delimiters []string
+	for i := 0; i < len(list); i++ {
+		if list[i] != '\\' || i+1 == len(list) {
+			delimiters = append(delimiters, list[i:i+1])
+			continue
+		}
+		i++
+		switch list[i] {
+		case 'n':
+			delimiters = append(delimiters, "\n")
+		case 't':
+			delimiters = append(delimiters, "\t")
+		case '0':
+			delimiters = append(delimiters, "")
+		default:
+			delimiters = append(delimiters, list[i:i+1])
+		}
+	}
+	if len(delimiters) == 0 {
+		return []string{""}
+	}
+	return delimiters
+}
+
+// RunComm compares two sorted files, like GNU comm: lines only in the
+// first file are printed in column 1, lines only in the second in column 2
+// and lines in both in column 3, each column indented by a tab more than
+// the one before. -1, -2 and -3 suppress columns. Either file may be "-".
+func RunComm(args []string, stdin io.Reader, stdout io.Writer, open OpenFunc) error {
+	var suppress [3]bool
+	delimiter := "\t"
+	total, checkOrder, noCheck := false, false, false
+	var operands []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			operands = append(operands, args[i+1:]...)
+			i = len(args)
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			operands = append(operands, arg)
+		case strings.HasPrefix(arg, "--output-delimiter="):
+			delimiter = strings.TrimPrefix(arg, "--output-delimiter=")
+		case arg == "--total":
+			total = true
+		case arg == "--check-order":
+			checkOrder = true
+		case arg == "--nocheck-order":
+			noCheck = true
+		case strings.HasPrefix(arg, "--"):
+			return fmt.Errorf("comm: %s: invalid option", arg)
+		default:
+			for _, c := range arg[1:] {
+				if c < '1' || c > '3' {
+					return fmt.Errorf("comm: -%c: invalid option", c)
+				}
+				suppress[c-'1'] = true
+			}
+		}
+	}
+	if err := twoOperands("comm", operands); err != nil {
+		return err
+	}
+	inputs, err := readOperandLines("comm", operands, stdin, open)
+	if err != nil {
+		return err
+	}
+
+	var disorder error
+	for i, lines := range inputs {
+		for n := 1; n < len(lines); n++ {
+			if lines[n-1] > lines[n] {
+				disorder = fmt.Errorf("comm: file %d is not in sorted order", i+1)
+				break
+			}
+		}
+		if disorder != nil {
+			break
+		}
+	}
+	if disorder != nil && checkOrder {
+		return disorder
+	}
+
+	// Each column is indented by the delimiters of the columns before it
+	var prefixes [3]string
+	for column := 1; column < 3; column++ {
+		prefixes[column] = prefixes[column-1]
+		if !suppress[column-1] {
+			prefixes[column] += delimiter
+		}
+	}
+	out := bufio.NewWriter(stdout)
+	var counts [3]int
+	write := func(column int, line string) {
+		counts[column]++
+		if !suppress[column] {
+			out.WriteString(prefixes[column])
+			out.WriteString(line)
+			out.WriteByte('\n')
+		}
+	}
+	lines1, lines2 := inputs[0], inputs[1]
+	a, b := 0, 0
+	for a < len(lines1) || b < len(lines2) {
+		switch {
+		case b == len(lines2) || (a < len(lines1) && lines1[a] < lines2[b]):
+			write(0, lines1[a])
+			a++
+		case a == len(lines1) || lines1[a] > lines2[b]:
+			write(1, lines2[b])
+			b++
+		default:
+			write(2, lines1[a])
+			a++
+			b++
+		}
+	}
+	if total {
+		fmt.Fprintf(out, "%d%s%d%s%d%stotal\n", counts[0], delimiter, counts[1], delimiter, counts[2], delimiter)
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	if disorder != nil && !noCheck {
+		return disorder
+	}
+	return nil
+}
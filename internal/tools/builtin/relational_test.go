@@ -0,0 +1,227 @@
+package builtin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRelationalCommands(t *testing.T) {
+	files := map[string]string{
+		"names.txt": "1 alice\n2 bob\n4 dave\n",
+		"ages.txt":  "1 30\n2 25\n3 40\n",
+		"left.txt":  "a\nb\n",
+		"right.txt": "1\n2\n3",
+		"s1.txt":    "a\nb\nc\n",
+		"s2.txt":    "b\nc\nd\n",
+		"names.csv": "id,name\n1,x\n2,y\n",
+		"pairs.txt": "a x\na y\n",
+	}
+	open := func(name string) (io.ReadCloser, error) {
+		data, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("file not found")
+		}
+		return io.NopCloser(strings.NewReader(data)), nil
+	}
+
+	tests := []struct {
+		name           string
+		command        func(args []string, stdin io.Reader, stdout io.Writer, open OpenFunc) error
+		args           []string
+		input          string
+		noFiles        bool
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name:           "join",
+			command:        RunJoin,
+			args:           []string{"names.txt", "ages.txt"},
+			expectedOutput: "1 alice 30\n2 bob 25\n",
+		},
+		{
+			name:           "join with unpairable lines",
+			command:        RunJoin,
+			args:           []string{"-a1", "-a", "2", "names.txt", "ages.txt"},
+			expectedOutput: "1 alice 30\n2 bob 25\n3 40\n4 dave\n",
+		},
+		{
+			name:           "join only unpairable lines",
+			command:        RunJoin,
+			args:           []string{"-v", "2", "names.txt", "ages.txt"},
+			expectedOutput: "3 40\n",
+		},
+		{
+			name:           "join output format with empty fields",
+			command:        RunJoin,
+			args:           []string{"-a1", "-e", "NA", "-o", "1.2,2.2", "names.txt", "ages.txt"},
+			expectedOutput: "alice 30\nbob 25\ndave NA\n",
+		},
+		{
+			name:           "join on other fields",
+			command:        RunJoin,
+			args:           []string{"-1", "2", "-2", "1", "-", "ages.txt"},
+			input:          "alice 1\nbob 2\n",
+			expectedOutput: "1 alice 30\n2 bob 25\n",
+		},
+		{
+			name:           "join with separator and header",
+			command:        RunJoin,
+			args:           []string{"--header", "-t,", "-", "names.csv"},
+			input:          "id,score\n1,9\n2,8\n",
+			expectedOutput: "id,score,name\n1,9,x\n2,8,y\n",
+		},
+		{
+			name:           "join many to many",
+			command:        RunJoin,
+			args:           []string{"-", "pairs.txt"},
+			input:          "a 1\na 2\n",
+			expectedOutput: "a 1 x\na 1 y\na 2 x\na 2 y\n",
+		},
+		{
+			name:           "join ignoring case with automatic format",
+			command:        RunJoin,
+			args:           []string{"-i", "-o", "auto", "-a2", "-", "pairs.txt"},
+			input:          "A 1 extra\n",
+			expectedOutput: "A 1 extra x\nA 1 extra y\n",
+		},
+		{
+			name:           "join unsorted input",
+			command:        RunJoin,
+			args:           []string{"-", "ages.txt"},
+			input:          "2 b\n1 a\n",
+			expectedOutput: "2 b 25\n",
+			expectedError:  "join: -:2: is not sorted: 1 a",
+		},
+		{
+			name:           "join unsorted input unchecked",
+			command:        RunJoin,
+			args:           []string{"--nocheck-order", "-", "ages.txt"},
+			input:          "2 b\n1 a\n",
+			expectedOutput: "2 b 25\n",
+		},
+		{
+			name:          "join both inputs stdin",
+			command:       RunJoin,
+			args:          []string{"-", "-"},
+			expectedError: "join: both files cannot be standard input",
+		},
+		{
+			name:          "join missing operand",
+			command:       RunJoin,
+			args:          []string{"names.txt"},
+			expectedError: "join: missing operand after 'names.txt'",
+		},
+		{
+			name:          "join invalid field",
+			command:       RunJoin,
+			args:          []string{"-1", "0", "names.txt", "ages.txt"},
+			expectedError: "join: invalid field number: '0'",
+		},
+		{
+			name:           "paste pairs of lines",
+			command:        RunPaste,
+			args:           []string{"-", "-"},
+			input:          "1\n2\n3\n4\n5\n",
+			expectedOutput: "1\t2\n3\t4\n5\t\n",
+		},
+		{
+			name:           "paste serially",
+			command:        RunPaste,
+			args:           []string{"-s", "-d", ",;"},
+			input:          "1\n2\n3\n4\n5\n",
+			expectedOutput: "1,2;3,4;5\n",
+		},
+		{
+			name:           "paste files of different lengths",
+			command:        RunPaste,
+			args:           []string{"-d,", "left.txt", "right.txt"},
+			expectedOutput: "a,1\nb,2\n,3\n",
+		},
+		{
+			name:           "paste with escaped delimiters",
+			command:        RunPaste,
+			args:           []string{"-sd", `\t\0`, "right.txt"},
+			expectedOutput: "1\t23\n",
+		},
+		{
+			name:          "paste file operand without files",
+			command:       RunPaste,
+			args:          []string{"left.txt"},
+			noFiles:       true,
+			expectedError: "paste: left.txt: file operands are not supported",
+		},
+		{
+			name:           "comm",
+			command:        RunComm,
+			args:           []string{"s1.txt", "s2.txt"},
+			expectedOutput: "a\n\t\tb\n\t\tc\n\td\n",
+		},
+		{
+			name:           "comm common lines",
+			command:        RunComm,
+			args:           []string{"-12", "s1.txt", "s2.txt"},
+			expectedOutput: "b\nc\n",
+		},
+		{
+			name:           "comm without common lines",
+			command:        RunComm,
+			args:           []string{"-3", "-", "s2.txt"},
+			input:          "a\nb\n",
+			expectedOutput: "a\n\tc\n\td\n",
+		},
+		{
+			name:           "comm output delimiter and total",
+			command:        RunComm,
+			args:           []string{"--output-delimiter=|", "--total", "s1.txt", "s2.txt"},
+			expectedOutput: "a\n||b\n||c\n|d\n1|1|2|total\n",
+		},
+		{
+			name:           "comm unsorted input",
+			command:        RunComm,
+			args:           []string{"-", "s2.txt"},
+			input:          "c\na\n",
+			expectedOutput: "\tb\n\t\tc\na\n\td\n",
+			expectedError:  "comm: file 1 is not in sorted order",
+		},
+		{
+			name:          "comm checking order",
+			command:       RunComm,
+			args:          []string{"--check-order", "-", "s2.txt"},
+			input:         "c\na\n",
+			expectedError: "comm: file 1 is not in sorted order",
+		},
+		{
+			name:          "comm invalid option",
+			command:       RunComm,
+			args:          []string{"-4", "s1.txt", "s2.txt"},
+			expectedError: "comm: -4: invalid option",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opener := OpenFunc(open)
+			if test.noFiles {
+				opener = nil
+			}
+			var output bytes.Buffer
+			err := test.command(test.args, strings.NewReader(test.input), &output, opener)
+			if test.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Errorf("expected error containing %q, got %v", test.expectedError, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.expectedOutput != "" || test.expectedError == "" {
+				if output.String() != test.expectedOutput {
+					t.Errorf("expected output %q, got %q", test.expectedOutput, output.String())
+				}
+			}
+		})
+	}
+}
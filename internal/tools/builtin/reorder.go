@@ -0,0 +1,257 @@
+package builtin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shufRand returns the random source of shuf; tests replace it
+var shufRand = func() *rand.Rand {
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
+// Tac prints the lines of stdin in reverse order, like GNU tac. -s sets the
+// separator that ends each record (a newline by default) and -b makes it
+// start records instead.
+func Tac(args []string, stdin io.Reader, stdout io.Writer) error {
+	separator := "\n"
+	before := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-b" || arg == "--before":
+			before = true
+		case arg == "-s" || arg == "--separator":
+			if i+1 == len(args) {
+				return fmt.Errorf("tac: %s: missing argument", arg)
+			}
+			i++
+			separator = args[i]
+		case strings.HasPrefix(arg, "--separator="):
+			separator = strings.TrimPrefix(arg, "--separator=")
+		case strings.HasPrefix(arg, "-s"):
+			separator = arg[2:]
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			return fmt.Errorf("tac: %s: invalid option", arg)
+		default:
+			if arg != "-" {
+				return fmt.Errorf("tac: %s: file operands are not supported; pipe the input instead", arg)
+			}
+		}
+	}
+	if separator == "" {
+		return fmt.Errorf("tac: separator cannot be empty")
+	}
+
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return fmt.Errorf("tac: %w", err)
+	}
+	text := string(data)
+	var records []string
+	for text != "" {
+		i := strings.Index(text, separator)
+		if before && i == 0 {
+			i = strings.Index(text[len(separator):], separator)
+			if i >= 0 {
+				i += len(separator)
+			}
+		}
+		switch {
+		case i < 0:
+			records = append(records, text)
+			text = ""
+		case before:
+			records = append(records, text[:i])
+			text = text[i:]
+		default:
+			records = append(records, text[:i+len(separator)])
+			text = text[i+len(separator):]
+		}
+	}
+
+	out := bufio.NewWriter(stdout)
+	for i := len(records) - 1; i >= 0; i-- {
+		out.WriteString(records[i])
+	}
+	return out.Flush()
+}
+
+// Shuf prints a random permutation of the lines of stdin, like GNU shuf.
+// -n COUNT prints at most COUNT lines, sampling them without holding all of
+// the input in memory; -e uses the operands as the lines and -i LO-HI the
+// numbers from LO to HI. With -r lines are chosen with replacement, so
+// COUNT lines are printed however few there are.
+func Shuf(args []string, stdin io.Reader, stdout io.Writer) error {
+	count := -1
+	repeat, echo := false, false
+	var lineRange string
+	var operands []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			operands = append(operands, args[i+1:]...)
+			i = len(args)
+			continue
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			operands = append(operands, arg)
+			continue
+		case arg == "--echo":
+			arg = "-e"
+		case arg == "--repeat":
+			arg = "-r"
+		case strings.HasPrefix(arg, "--head-count="):
+			arg = "-n" + strings.TrimPrefix(arg, "--head-count=")
+		case strings.HasPrefix(arg, "--input-range="):
+			arg = "-i" + strings.TrimPrefix(arg, "--input-range=")
+		case strings.HasPrefix(arg, "--"):
+			return fmt.Errorf("shuf: %s: invalid option", arg)
+		}
+
+		for k := 1; k < len(arg); k++ {
+			switch c := arg[k]; c {
+			case 'e':
+				echo = true
+			case 'r':
+				repeat = true
+			case 'n', 'i':
+				value := arg[k+1:]
+				if value == "" {
+					if i+1 == len(args) {
+						return fmt.Errorf("shuf: -%c: missing argument", c)
+					}
+					i++
+					value = args[i]
+				}
+				if c == 'n' {
+					n, err := strconv.Atoi(value)
+					if err != nil || n < 0 {
+						return fmt.Errorf("shuf: invalid line count: '%s'", value)
+					}
+					count = n
+				} else {
+					lineRange = value
+				}
+				k = len(arg)
+			default:
+				return fmt.Errorf("shuf: -%c: invalid option", c)
+			}
+		}
+	}
+	if repeat && count < 0 {
+		return fmt.Errorf("shuf: -r needs -n to limit the output")
+	}
+
+	var lines []string
+	switch {
+	case echo && lineRange != "":
+		return fmt.Errorf("shuf: cannot combine -e and -i options")
+	case echo:
+		lines = operands
+	case lineRange != "":
+		if len(operands) > 0 {
+			return fmt.Errorf("shuf: extra operand '%s'", operands[0])
+		}
+		lo, hi, ok := strings.Cut(lineRange, "-")
+		low, err1 := strconv.Atoi(lo)
+		high, err2 := strconv.Atoi(hi)
+		if !ok || err1 != nil || err2 != nil || high < low-1 {
+			return fmt.Errorf("shuf: invalid input range: '%s'", lineRange)
+		}
+		if count < 0 && high-low >= 1<<20 {
+			return fmt.Errorf("shuf: input range '%s' is too large; use -n", lineRange)
+		}
+		if count >= 0 && !repeat {
+			return shufRange(low, high, count, stdout)
+		}
+		for n := low; n <= high; n++ {
+			lines = append(lines, strconv.Itoa(n))
+		}
+	default:
+		for _, operand := range operands {
+			if operand != "-" {
+				return fmt.Errorf("shuf: %s: file operands are not supported; pipe the input instead", operand)
+			}
+		}
+		var err error
+		if lines, err = shufSample(stdin, count, repeat); err != nil {
+			return err
+		}
+	}
+
+	random := shufRand()
+	out := bufio.NewWriter(stdout)
+	if repeat {
+		for k := 0; k < count && len(lines) > 0; k++ {
+			out.WriteString(lines[random.Intn(len(lines))])
+			out.WriteByte('\n')
+		}
+		return out.Flush()
+	}
+	random.Shuffle(len(lines), func(i, j int) { lines[i], lines[j] = lines[j], lines[i] })
+	if count >= 0 && count < len(lines) {
+		lines = lines[:count]
+	}
+	for _, line := range lines {
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.Flush()
+}
+
+// shufSample reads the lines of stdin. With a count and no repetition only
+// a uniform sample of count lines is kept, by reservoir sampling.
+func shufSample(stdin io.Reader, count int, repeat bool) ([]string, error) {
+	random := shufRand()
+	scanner := bufio.NewScanner(stdin)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var lines []string
+	seen := 0
+	for scanner.Scan() {
+		seen++
+		switch {
+		case count < 0 || repeat || len(lines) < count:
+			lines = append(lines, scanner.Text())
+		default:
+			if k := random.Intn(seen); k < count {
+				lines[k] = scanner.Text()
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("shuf: %w", err)
+	}
+	return lines, nil
+}
+
+// shufRange prints count distinct random numbers from low to high without
+// listing the whole range
+func shufRange(low, high, count int, stdout io.Writer) error {
+	random := shufRand()
+	size := high - low + 1
+	if count > size {
+		count = size
+	}
+	// A partial Fisher-Yates shuffle over a sparse permutation
+	swapped := make(map[int]int)
+	at := func(i int) int {
+		if v, ok := swapped[i]; ok {
+			return v
+		}
+		return i
+	}
+	out := bufio.NewWriter(stdout)
+	for k := 0; k < count; k++ {
+		j := k + random.Intn(size-k)
+		vk, vj := at(k), at(j)
+		swapped[k], swapped[j] = vj, vk
+		fmt.Fprintln(out, low+vj)
+	}
+	return out.Flush()
+}
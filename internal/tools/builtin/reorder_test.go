@@ -0,0 +1,138 @@
+package builtin
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestTac(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		input          string
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name:           "lines",
+			input:          "1\n2\n3\n",
+			expectedOutput: "3\n2\n1\n",
+		},
+		{
+			name:           "last line without a newline",
+			input:          "a\nb",
+			expectedOutput: "ba\n",
+		},
+		{
+			name:           "separator",
+			args:           []string{"-s", ","},
+			input:          "a,b,c,",
+			expectedOutput: "c,b,a,",
+		},
+		{
+			name:           "separator before records",
+			args:           []string{"-b"},
+			input:          "a\nb\n",
+			expectedOutput: "\n\nba",
+		},
+		{
+			name:          "empty separator",
+			args:          []string{"-s", ""},
+			expectedError: "tac: separator cannot be empty",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output bytes.Buffer
+			err := Tac(test.args, strings.NewReader(test.input), &output)
+			if test.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != test.expectedOutput {
+				t.Errorf("expected output %q, got %q", test.expectedOutput, output.String())
+			}
+		})
+	}
+}
+
+func TestShuf(t *testing.T) {
+	defer func(saved func() *rand.Rand) { shufRand = saved }(shufRand)
+	shufRand = func() *rand.Rand { return rand.New(rand.NewSource(1)) }
+
+	run := func(args []string, input string) []string {
+		t.Helper()
+		var output bytes.Buffer
+		if err := Shuf(args, strings.NewReader(input), &output); err != nil {
+			t.Fatalf("shuf %q failed: %v", args, err)
+		}
+		return strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	}
+	sorted := func(lines []string) string {
+		lines = append([]string(nil), lines...)
+		sort.Strings(lines)
+		return strings.Join(lines, ",")
+	}
+	distinct := func(lines []string) bool {
+		seen := make(map[string]bool)
+		for _, line := range lines {
+			if seen[line] {
+				return false
+			}
+			seen[line] = true
+		}
+		return true
+	}
+
+	input := "a\nb\nc\nd\ne\n"
+	if got := run(nil, input); sorted(got) != "a,b,c,d,e" {
+		t.Errorf("shuf = %q, want a permutation of the input", got)
+	}
+	if got := run([]string{"-n", "2"}, input); len(got) != 2 || !distinct(got) || !strings.Contains(input, got[0]+"\n") {
+		t.Errorf("shuf -n 2 = %q, want 2 distinct input lines", got)
+	}
+	if got := run([]string{"-n10"}, input); sorted(got) != "a,b,c,d,e" {
+		t.Errorf("shuf -n10 = %q, want the whole input", got)
+	}
+	if got := run([]string{"-e", "x", "y", "z"}, ""); sorted(got) != "x,y,z" {
+		t.Errorf("shuf -e = %q, want a permutation of the operands", got)
+	}
+	if got := run([]string{"-i", "1-1000", "-n", "5"}, ""); len(got) != 5 || !distinct(got) {
+		t.Errorf("shuf -i 1-1000 -n 5 = %q, want 5 distinct numbers", got)
+	}
+	if got := run([]string{"-i", "3-5"}, ""); sorted(got) != "3,4,5" {
+		t.Errorf("shuf -i 3-5 = %q, want a permutation of 3..5", got)
+	}
+	if got := run([]string{"-rn", "6", "-e", "x"}, ""); strings.Join(got, "") != "xxxxxx" {
+		t.Errorf("shuf -rn 6 -e x = %q, want x six times", got)
+	}
+
+	// Sampling picks each line about equally often
+	counts := make(map[string]int)
+	for seed := int64(0); seed < 2000; seed++ {
+		shufRand = func() *rand.Rand { return rand.New(rand.NewSource(seed)) }
+		for _, line := range run([]string{"-n", "1"}, input) {
+			counts[line]++
+		}
+	}
+	for _, line := range []string{"a", "b", "c", "d", "e"} {
+		if counts[line] < 300 || counts[line] > 500 {
+			t.Errorf("line %s sampled %d times in 2000, want about 400", line, counts[line])
+		}
+	}
+
+	for _, args := range [][]string{{"-r"}, {"-n", "x"}, {"-i", "5-1"}, {"-e", "-i", "1-2"}, {"log.txt"}} {
+		if err := Shuf(args, strings.NewReader(""), &bytes.Buffer{}); err == nil {
+			t.Errorf("shuf %q succeeded, want an error", args)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package builtin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// rextractOptions are the parsed options of an rextract invocation
+type rextractOptions struct {
+	pattern    string
+	groups     []string // --group: numbers or names, printed tab-separated
+	format     string   // --format template; empty when not given
+	hasFormat  bool
+	json       bool
+	ignoreCase bool
+	first      bool // only the first match of each line
+	lineNumber bool
+	whole      bool // match across the whole input instead of line by line
+}
+
+// Rextract prints what a Go regular expression (RE2 syntax) captures from
+// each line of stdin, one result per match. By default the capture groups of
+// a match are printed tab-separated, or the whole match when the pattern has
+// none; --group picks groups by number or name, --format fills a template
+// where $1, ${1} and ${name} stand for groups, and --json prints an object
+// per match. Lines without a match print nothing.
+func Rextract(args []string, stdin io.Reader, stdout io.Writer) error {
+	opts, err := parseRextractArgs(args)
+	if err != nil {
+		return err
+	}
+	pattern := opts.pattern
+	if opts.ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	if opts.whole {
+		pattern = "(?m)" + pattern
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("rextract: invalid pattern: %w", err)
+	}
+	emit, err := opts.emitter(regex)
+	if err != nil {
+		return err
+	}
+
+	limit := -1
+	if opts.first {
+		limit = 1
+	}
+	out := bufio.NewWriter(stdout)
+	extract := func(text string, lineOf func(offset int) int) {
+		for _, match := range regex.FindAllStringSubmatchIndex(text, limit) {
+			if opts.lineNumber {
+				fmt.Fprintf(out, "%d:", lineOf(match[0]))
+			}
+			out.WriteString(emit(text, match))
+			out.WriteByte('\n')
+		}
+	}
+
+	if opts.whole {
+		input, err := io.ReadAll(stdin)
+		if err != nil {
+			return fmt.Errorf("rextract: failed to read input: %w", err)
+		}
+		text := string(input)
+		extract(text, func(offset int) int { return strings.Count(text[:offset], "\n") + 1 })
+		return out.Flush()
+	}
+
+	scanner := bufio.NewScanner(stdin)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for number := 1; scanner.Scan(); number++ {
+		extract(scanner.Text(), func(int) int { return number })
+	}
+	if err := scanner.Err(); err != nil {
+		out.Flush()
+		return err
+	}
+	return out.Flush()
+}
+
+// parseRextractArgs parses rextract's options; the first operand is the
+// pattern
+func parseRextractArgs(args []string) (rextractOptions, error) {
+	var opts rextractOptions
+	hasPattern := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := arg, "", false
+		if strings.HasPrefix(arg, "--") {
+			name, value, hasValue = strings.Cut(arg, "=")
+		} else if len(arg) > 2 && arg[0] == '-' && strings.IndexByte("gf", arg[1]) >= 0 {
+			name, value, hasValue = arg[:2], arg[2:], true
+		}
+		needValue := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i+1 == len(args) {
+				return "", fmt.Errorf("rextract: %s: missing argument", name)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch {
+		case name == "-g" || name == "--group":
+			v, err := needValue()
+			if err != nil {
+				return opts, err
+			}
+			for _, group := range strings.Split(v, ",") {
+				if group == "" {
+					return opts, fmt.Errorf("rextract: %s: empty group in %q", name, v)
+				}
+				opts.groups = append(opts.groups, group)
+			}
+		case name == "-f" || name == "--format":
+			v, err := needValue()
+			if err != nil {
+				return opts, err
+			}
+			opts.format, opts.hasFormat = v, true
+		case name == "--json":
+			opts.json = true
+		case name == "--ignore-case":
+			opts.ignoreCase = true
+		case name == "--first":
+			opts.first = true
+		case name == "--line-number":
+			opts.lineNumber = true
+		case name == "--whole":
+			opts.whole = true
+		case name == "--":
+			if i+1 < len(args) && !hasPattern {
+				opts.pattern, hasPattern = args[i+1], true
+				i++
+			}
+		case len(arg) > 1 && arg[0] == '-' && !strings.HasPrefix(arg, "--"):
+			for _, c := range arg[1:] {
+				switch c {
+				case 'i':
+					opts.ignoreCase = true
+				case '1':
+					opts.first = true
+				case 'n':
+					opts.lineNumber = true
+				case 'z':
+					opts.whole = true
+				default:
+					return opts, fmt.Errorf("rextract: -%c: invalid option", c)
+				}
+			}
+		case strings.HasPrefix(arg, "--"):
+			return opts, fmt.Errorf("rextract: %s: invalid option", arg)
+		case !hasPattern:
+			opts.pattern, hasPattern = arg, true
+		default:
+			return opts, fmt.Errorf("rextract: %s: file operands are not supported; pipe the input instead", arg)
+		}
+	}
+	if !hasPattern {
+		return opts, fmt.Errorf("rextract: missing pattern")
+	}
+	if opts.json && (opts.hasFormat || len(opts.groups) > 0) {
+		return opts, fmt.Errorf("rextract: --json cannot be combined with --group or --format")
+	}
+	if opts.hasFormat && len(opts.groups) > 0 {
+		return opts, fmt.Errorf("rextract: --group and --format cannot be combined")
+	}
+	return opts, nil
+}
+
+// emitter returns the function that formats one match, given as the
+// submatch indexes into text
+func (opts rextractOptions) emitter(regex *regexp.Regexp) (func(text string, match []int) string, error) {
+	names := regex.SubexpNames()
+	group := func(text string, match []int, n int) string {
+		if match[2*n] < 0 {
+			return ""
+		}
+		return text[match[2*n]:match[2*n+1]]
+	}
+
+	switch {
+	case opts.hasFormat:
+		template := rextractUnescape(opts.format)
+		return func(text string, match []int) string {
+			return string(regex.ExpandString(nil, template, text, match))
+		}, nil
+	case opts.json:
+		return func(text string, match []int) string {
+			obj := newJqObject()
+			obj.set("match", group(text, match, 0))
+			for n := 1; n < len(names); n++ {
+				key := names[n]
+				if key == "" {
+					key = strconv.Itoa(n)
+				}
+				if match[2*n] < 0 {
+					obj.set(key, nil)
+				} else {
+					obj.set(key, group(text, match, n))
+				}
+			}
+			return jqEncode(obj, false, false)
+		}, nil
+	}
+
+	indexes := make([]int, 0, len(opts.groups))
+	for _, g := range opts.groups {
+		n, err := strconv.Atoi(g)
+		if err != nil {
+			if n = regex.SubexpIndex(g); n < 0 {
+				return nil, fmt.Errorf("rextract: no group named %q in the pattern", g)
+			}
+		} else if n < 0 || n >= len(names) {
+			return nil, fmt.Errorf("rextract: group %d does not exist; the pattern has %d", n, len(names)-1)
+		}
+		indexes = append(indexes, n)
+	}
+	if len(indexes) == 0 {
+		for n := 1; n < len(names); n++ {
+			indexes = append(indexes, n)
+		}
+		if len(indexes) == 0 {
+			indexes = append(indexes, 0)
+		}
+	}
+	return func(text string, match []int) string {
+		parts := make([]string, len(indexes))
+		for i, n := range indexes {
+			parts[i] = group(text, match, n)
+		}
+		return strings.Join(parts, "\t")
+	}, nil
+}
+
+// rextractUnescape turns \t, \n and \\ in a format into the characters, as
+// the template is usually written inside single quotes
+func rextractUnescape(format string) string {
+	return strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n").Replace(format)
+}
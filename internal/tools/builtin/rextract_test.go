@@ -0,0 +1,51 @@
+package builtin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRextract(t *testing.T) {
+	const log = "2024-01-02 ERROR id=42 user=bob\n2024-01-03 INFO id=7\nno match\n2024-01-04 ERROR id=9 user=amy id=10\n"
+
+	tests := []struct {
+		name   string
+		args   []string
+		input  string
+		output string
+		err    string
+	}{
+		{"whole match without groups", []string{`id=\d+`}, log, "id=42\nid=7\nid=9\nid=10\n", ""},
+		{"groups are tab-separated", []string{`(\S+) ERROR .*user=(\w+)`}, log, "2024-01-02\tbob\n2024-01-04\tamy\n", ""},
+		{"group by number and name", []string{`(?P<date>\S+) (\w+)`, "--group", "2,date", "--first"}, log, "ERROR\t2024-01-02\nINFO\t2024-01-03\nmatch\tno\nERROR\t2024-01-04\n", ""},
+		{"format template", []string{`user=(?P<user>\w+)`, "--format", `${user}\t$0`}, log, "bob\tuser=bob\namy\tuser=amy\n", ""},
+		{"json objects", []string{"--json", "-1", `id=(?P<id>\d+)(?: user=(\w+))?`}, log, `{"match":"id=42 user=bob","id":"42","2":"bob"}` + "\n" + `{"match":"id=7","id":"7","2":null}` + "\n" + `{"match":"id=9 user=amy","id":"9","2":"amy"}` + "\n", ""},
+		{"line numbers and ignore case", []string{"-in", `^(\S+) error`}, log, "1:2024-01-02\n4:2024-01-04\n", ""},
+		{"whole input", []string{"-z", "-n", `(?s)INFO.*?\n(\S+)`, "-g1"}, log, "2:no\n", ""},
+		{"missing pattern", nil, "", "", "rextract: missing pattern"},
+		{"bad pattern", []string{"("}, "", "", "rextract: invalid pattern: error parsing regexp: missing closing ): `(`"},
+		{"unknown group", []string{`(a)`, "-g", "2"}, "", "", "rextract: group 2 does not exist; the pattern has 1"},
+		{"unknown group name", []string{`(a)`, "-g", "x"}, "", "", `rextract: no group named "x" in the pattern`},
+		{"conflicting outputs", []string{`(a)`, "--json", "-g1"}, "", "", "rextract: --json cannot be combined with --group or --format"},
+		{"file operand", []string{"a", "log.txt"}, "", "", "rextract: log.txt: file operands are not supported; pipe the input instead"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output strings.Builder
+			err := Rextract(tt.args, strings.NewReader(tt.input), &output)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != tt.output {
+				t.Errorf("expected output %q, got %q", tt.output, output.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,460 @@
+package builtin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Sed is a stream editor following GNU sed. Scripts are made of commands
+// separated by semicolons or newlines, each optionally preceded by an
+// address (a line number, $, /regex/ or first~step), a range such as
+// 10,20, /start/,/end/ or 5,+3, and !. Supported commands are s (with
+// groups, & and the g, p, i and number flags), p, P, d, D, n, N, q, Q, =,
+// a, i, c, y, h, H, g, G, x, z, {...}, and b, t, T and : for branching.
+// Regexes are basic unless -E or -r is given. Input comes from stdin; file
+// operands, in-place editing and the r and w commands are not supported.
+func Sed(args []string, stdin io.Reader, stdout io.Writer) error {
+	quiet, extended := false, false
+	var scripts, operands []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			operands = append(operands, args[i+1:]...)
+			i = len(args)
+		case arg == "--quiet" || arg == "--silent":
+			quiet = true
+		case arg == "--regexp-extended":
+			extended = true
+		case strings.HasPrefix(arg, "--expression="):
+			scripts = append(scripts, strings.TrimPrefix(arg, "--expression="))
+		case arg == "--expression":
+			if i+1 == len(args) {
+				return fmt.Errorf("sed: --expression: missing script")
+			}
+			i++
+			scripts = append(scripts, args[i])
+		case strings.HasPrefix(arg, "-") && len(arg) > 1 && !strings.HasPrefix(arg, "--"):
+			for j := 1; j < len(arg); j++ {
+				switch c := arg[j]; c {
+				case 'n':
+					quiet = true
+				case 'E', 'r':
+					extended = true
+				case 's', 'u':
+				case 'e':
+					script := arg[j+1:]
+					if script == "" {
+						if i+1 == len(args) {
+							return fmt.Errorf("sed: -e: missing script")
+						}
+						i++
+						script = args[i]
+					}
+					scripts = append(scripts, script)
+					j = len(arg)
+				case 'i':
+					return fmt.Errorf("sed: -i: in-place editing is not supported; redirect the output instead")
+				default:
+					return fmt.Errorf("sed: -%c: invalid option", c)
+				}
+			}
+		case strings.HasPrefix(arg, "--"):
+			return fmt.Errorf("sed: %s: invalid option", arg)
+		default:
+			operands = append(operands, arg)
+		}
+	}
+	if len(scripts) == 0 {
+		if len(operands) == 0 {
+			return fmt.Errorf("sed: missing expression")
+		}
+		scripts, operands = operands[:1], operands[1:]
+	}
+	if len(operands) > 0 {
+		return fmt.Errorf("sed: %s: file operands are not supported; pipe the input instead", operands[0])
+	}
+
+	parser := &sedParser{script: strings.Join(scripts, "\n"), extended: extended}
+	commands, err := parser.parse()
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(stdout)
+	s := &sedState{commands: commands, quiet: quiet, in: newSedInput(stdin), out: out}
+	err = s.run()
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+// SedExitError is returned when q or Q exits with a non-zero status
+type SedExitError struct {
+	Code int
+}
+
+func (e *SedExitError) Error() string {
+	return fmt.Sprintf("sed: exit %d", e.Code)
+}
+
+// ExitCode returns the status given to q or Q
+func (e *SedExitError) ExitCode() int {
+	return e.Code
+}
+
+// sedInput reads lines one ahead, so $ can tell the last line
+type sedInput struct {
+	scanner *bufio.Scanner
+	next    string
+	hasNext bool
+}
+
+func newSedInput(r io.Reader) *sedInput {
+	in := &sedInput{scanner: bufio.NewScanner(r)}
+	in.advance()
+	return in
+}
+
+func (in *sedInput) advance() {
+	in.hasNext = in.scanner.Scan()
+	in.next = in.scanner.Text()
+}
+
+func (in *sedInput) read() (string, bool) {
+	if !in.hasNext {
+		return "", false
+	}
+	line := in.next
+	in.advance()
+	return line, true
+}
+
+// sedState is the state of a running script
+type sedState struct {
+	commands    []sedCommand
+	quiet       bool
+	in          *sedInput
+	out         *bufio.Writer
+	line        int
+	pattern     string
+	hold        string
+	appended    []string
+	substituted bool // An s command succeeded since the last input line or t
+	lastRegex   *regexp.Regexp
+}
+
+func (s *sedState) run() error {
+	restart := false
+	for {
+		if !restart {
+			line, ok := s.in.read()
+			if !ok {
+				break
+			}
+			s.line++
+			s.pattern = line
+			s.substituted = false
+		}
+		restart = false
+
+		print, quit, err := s.cycle(&restart)
+		if err != nil {
+			return err
+		}
+		if print && !s.quiet {
+			s.println(s.pattern)
+		}
+		s.flushAppended()
+		if quit != nil {
+			if quit.Code != 0 {
+				return quit
+			}
+			break
+		}
+	}
+	return s.in.scanner.Err()
+}
+
+// cycle runs the script over the pattern space, reporting whether to print
+// it and whether to quit
+func (s *sedState) cycle(restart *bool) (bool, *SedExitError, error) {
+	for pc := 0; pc < len(s.commands); pc++ {
+		cmd := &s.commands[pc]
+		matched, err := s.matches(cmd)
+		if err != nil {
+			return false, nil, err
+		}
+		if !matched {
+			if cmd.name == '{' {
+				pc = cmd.target
+			}
+			continue
+		}
+
+		switch cmd.name {
+		case '=':
+			fmt.Fprintf(s.out, "%d\n", s.line)
+		case 'p':
+			s.println(s.pattern)
+		case 'P':
+			first, _, _ := strings.Cut(s.pattern, "\n")
+			s.println(first)
+		case 'd':
+			return false, nil, nil
+		case 'D':
+			_, rest, found := strings.Cut(s.pattern, "\n")
+			if !found {
+				return false, nil, nil
+			}
+			s.pattern = rest
+			*restart = true
+			return false, nil, nil
+		case 'n':
+			if !s.in.hasNext {
+				return true, &SedExitError{}, nil
+			}
+			if !s.quiet {
+				s.println(s.pattern)
+			}
+			s.flushAppended()
+			s.pattern, _ = s.in.read()
+			s.line++
+		case 'N':
+			if !s.in.hasNext {
+				return true, &SedExitError{}, nil
+			}
+			next, _ := s.in.read()
+			s.pattern += "\n" + next
+			s.line++
+		case 'q':
+			return true, &SedExitError{Code: cmd.exitCode}, nil
+		case 'Q':
+			return false, &SedExitError{Code: cmd.exitCode}, nil
+		case 'a':
+			s.appended = append(s.appended, cmd.text)
+		case 'i':
+			s.println(cmd.text)
+		case 'c':
+			// A range is replaced by one copy of the text, at its end
+			if cmd.addr2.kind == 0 || cmd.negate || !cmd.inRange {
+				s.println(cmd.text)
+			}
+			return false, nil, nil
+		case 'h':
+			s.hold = s.pattern
+		case 'H':
+			s.hold += "\n" + s.pattern
+		case 'g':
+			s.pattern = s.hold
+		case 'G':
+			s.pattern += "\n" + s.hold
+		case 'x':
+			s.pattern, s.hold = s.hold, s.pattern
+		case 'z':
+			s.pattern = ""
+		case 'y':
+			s.pattern = strings.Map(func(r rune) rune {
+				if to, ok := cmd.mapping[r]; ok {
+					return to
+				}
+				return r
+			}, s.pattern)
+		case 's':
+			regex, err := s.regex(cmd.regex)
+			if err != nil {
+				return false, nil, err
+			}
+			if result, ok := substitute(cmd, regex, s.pattern); ok {
+				s.pattern = result
+				s.substituted = true
+				if cmd.print {
+					s.println(s.pattern)
+				}
+			}
+		case 'b':
+			pc = cmd.target - 1
+		case 't':
+			if s.substituted {
+				s.substituted = false
+				pc = cmd.target - 1
+			}
+		case 'T':
+			if !s.substituted {
+				pc = cmd.target - 1
+			} else {
+				s.substituted = false
+			}
+		}
+	}
+	return true, nil, nil
+}
+
+func (s *sedState) println(text string) {
+	s.out.WriteString(text)
+	s.out.WriteByte('\n')
+}
+
+func (s *sedState) flushAppended() {
+	for _, text := range s.appended {
+		s.println(text)
+	}
+	s.appended = s.appended[:0]
+}
+
+// regex returns a command's regex, or for //, the last regex used
+func (s *sedState) regex(regex *regexp.Regexp) (*regexp.Regexp, error) {
+	if regex == nil {
+		if s.lastRegex == nil {
+			return nil, fmt.Errorf("sed: no previous regular expression")
+		}
+		return s.lastRegex, nil
+	}
+	s.lastRegex = regex
+	return regex, nil
+}
+
+// matches reports whether a command applies to the current line, updating
+// the state of ranges
+func (s *sedState) matches(cmd *sedCommand) (bool, error) {
+	if cmd.addr1.kind == 0 {
+		return true, nil
+	}
+	if cmd.addr2.kind == 0 {
+		matched, err := s.matchAddress(cmd.addr1)
+		return matched != cmd.negate, err
+	}
+
+	if !cmd.inRange {
+		matched, err := s.matchAddress(cmd.addr1)
+		if err != nil || !matched {
+			return cmd.negate, err
+		}
+		switch addr := cmd.addr2; addr.kind {
+		case 'n':
+			cmd.inRange = addr.line > s.line
+		case '+':
+			cmd.rangeEnd = s.line + addr.line
+			cmd.inRange = addr.line > 0
+		case 'm':
+			if addr.line > 0 && s.line%addr.line != 0 {
+				cmd.rangeEnd = (s.line/addr.line + 1) * addr.line
+				cmd.inRange = true
+			}
+		case '$':
+			cmd.inRange = s.in.hasNext
+		default:
+			cmd.inRange = true
+		}
+		return !cmd.negate, nil
+	}
+
+	switch addr := cmd.addr2; addr.kind {
+	case 'n':
+		cmd.inRange = s.line < addr.line
+	case '+', 'm':
+		cmd.inRange = s.line < cmd.rangeEnd
+	case '$':
+		cmd.inRange = s.in.hasNext
+	case '/':
+		matched, err := s.matchAddress(addr)
+		if err != nil {
+			return false, err
+		}
+		cmd.inRange = !matched
+	}
+	return !cmd.negate, nil
+}
+
+func (s *sedState) matchAddress(addr sedAddress) (bool, error) {
+	switch addr.kind {
+	case 'n':
+		return s.line == addr.line, nil
+	case '$':
+		return !s.in.hasNext, nil
+	case '~':
+		if addr.step <= 0 {
+			return s.line == addr.line, nil
+		}
+		return s.line >= addr.line && (s.line-addr.line)%addr.step == 0, nil
+	case '/':
+		regex, err := s.regex(addr.regex)
+		if err != nil {
+			return false, err
+		}
+		return regex.MatchString(s.pattern), nil
+	}
+	return false, nil
+}
+
+// substitute performs an s command, reporting whether anything was replaced
+func substitute(cmd *sedCommand, regex *regexp.Regexp, text string) (string, bool) {
+	occurrence := max(cmd.occurrence, 1)
+	var b strings.Builder
+	last, replaced := 0, false
+	for n, match := range regex.FindAllStringSubmatchIndex(text, -1) {
+		if n+1 < occurrence {
+			continue
+		}
+		if replaced && !cmd.global {
+			break
+		}
+		b.WriteString(text[last:match[0]])
+		expandSedReplacement(&b, cmd.replacement, text, match)
+		last = match[1]
+		replaced = true
+	}
+	if !replaced {
+		return text, false
+	}
+	b.WriteString(text[last:])
+	return b.String(), true
+}
+
+// expandSedReplacement writes a replacement for a match, applying the GNU
+// case conversions: \U and \L until \E, and \u and \l for one character
+func expandSedReplacement(b *strings.Builder, parts []sedReplacement, text string, match []int) {
+	var mode, once byte
+	for _, part := range parts {
+		if part.caseOp != 0 {
+			switch part.caseOp {
+			case 'U', 'L':
+				mode, once = part.caseOp, 0
+			case 'E':
+				mode, once = 0, 0
+			default:
+				once = part.caseOp
+			}
+			continue
+		}
+		value := part.literal
+		if part.group >= 0 {
+			value = ""
+			if 2*part.group+1 < len(match) && match[2*part.group] >= 0 {
+				value = text[match[2*part.group]:match[2*part.group+1]]
+			}
+		}
+		switch mode {
+		case 'U':
+			value = strings.ToUpper(value)
+		case 'L':
+			value = strings.ToLower(value)
+		}
+		if once != 0 && value != "" {
+			r, size := utf8.DecodeRuneInString(value)
+			if once == 'u' {
+				r = unicode.ToUpper(r)
+			} else {
+				r = unicode.ToLower(r)
+			}
+			value = string(r) + value[size:]
+			once = 0
+		}
+		b.WriteString(value)
+	}
+}
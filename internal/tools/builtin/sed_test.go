@@ -0,0 +1,174 @@
+package builtin
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSed(t *testing.T) {
+	const lines = "one\ntwo\nthree\nfour\nfive\n"
+
+	tests := []struct {
+		name           string
+		args           []string
+		input          string
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name:           "first match only without g",
+			args:           []string{"s/o/0/"},
+			input:          "foo boo\n",
+			expectedOutput: "f0o boo\n",
+		},
+		{
+			name:           "global with groups and &",
+			args:           []string{`s/\([a-z]*\)=\([0-9]*\)/\2:\1 [&]/g`},
+			input:          "a=1 b=22\n",
+			expectedOutput: "1:a [a=1] 22:b [b=22]\n",
+		},
+		{
+			name:           "extended regex and case conversion",
+			args:           []string{"-E", `s/(\w+) (\w+)/\u\1 \U\2\E!/`},
+			input:          "hello world\n",
+			expectedOutput: "Hello WORLD!\n",
+		},
+		{
+			name:           "numbered occurrence and alternate delimiter",
+			args:           []string{"s|/|_|2g"},
+			input:          "/a/b/c\n",
+			expectedOutput: "/a_b_c\n",
+		},
+		{
+			name:           "quiet with p",
+			args:           []string{"-n", "/t/p"},
+			input:          lines,
+			expectedOutput: "two\nthree\n",
+		},
+		{
+			name:           "line range delete",
+			args:           []string{"2,4d"},
+			input:          lines,
+			expectedOutput: "one\nfive\n",
+		},
+		{
+			name:           "regex range print",
+			args:           []string{"-n", "/two/,/four/p"},
+			input:          lines,
+			expectedOutput: "two\nthree\nfour\n",
+		},
+		{
+			name:           "relative range and negation",
+			args:           []string{"-n", "2,+1!p"},
+			input:          lines,
+			expectedOutput: "one\nfour\nfive\n",
+		},
+		{
+			name:           "last line and step addresses",
+			args:           []string{"-n", "-e", "1~2p", "-e", "$="},
+			input:          lines,
+			expectedOutput: "one\nthree\nfive\n5\n",
+		},
+		{
+			name:           "substitution print flag",
+			args:           []string{"-n", "s/e$/E/p"},
+			input:          lines,
+			expectedOutput: "onE\nthreE\nfivE\n",
+		},
+		{
+			name:           "blocks and quit",
+			args:           []string{"/two/,${/four/q;s/^/> /}"},
+			input:          lines,
+			expectedOutput: "one\n> two\n> three\nfour\n",
+		},
+		{
+			name:           "append insert and change",
+			args:           []string{"1i\\\nheader\n2a after two\n4,5c\\\nrest"},
+			input:          lines,
+			expectedOutput: "header\none\ntwo\nafter two\nthree\nrest\n",
+		},
+		{
+			name:           "join lines with a label loop",
+			args:           []string{":a;N;$!ba;s/\\n/,/g"},
+			input:          lines,
+			expectedOutput: "one,two,three,four,five\n",
+		},
+		{
+			name:           "hold space reverses lines",
+			args:           []string{"-n", "1!G;h;$p"},
+			input:          "a\nb\nc\n",
+			expectedOutput: "c\nb\na\n",
+		},
+		{
+			name:           "transliterate",
+			args:           []string{"y/abc/xyz/"},
+			input:          "aabbcc\n",
+			expectedOutput: "xxyyzz\n",
+		},
+		{
+			name:           "empty regex reuses the last one",
+			args:           []string{"/o/s//0/g"},
+			input:          "foo\nbar\n",
+			expectedOutput: "f00\nbar\n",
+		},
+		{
+			name:          "invalid group reference",
+			args:          []string{`s/a/\1/`},
+			expectedError: "invalid reference \\1 on `s' command's RHS",
+		},
+		{
+			name:          "unknown command",
+			args:          []string{"2k"},
+			expectedError: "unknown command: `k'",
+		},
+		{
+			name:          "unterminated substitution",
+			args:          []string{"s/a/b"},
+			expectedError: "unterminated `/' delimited text",
+		},
+		{
+			name:          "in-place editing",
+			args:          []string{"-i", "s/a/b/"},
+			expectedError: "in-place editing is not supported",
+		},
+		{
+			name:          "file operand",
+			args:          []string{"s/a/b/", "file.txt"},
+			expectedError: "file operands are not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output strings.Builder
+			err := Sed(tt.args, strings.NewReader(tt.input), &output)
+
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != tt.expectedOutput {
+				t.Errorf("expected output %q, got %q", tt.expectedOutput, output.String())
+			}
+		})
+	}
+}
+
+func TestSedExitCode(t *testing.T) {
+	var output strings.Builder
+	err := Sed([]string{"2q5"}, strings.NewReader("a\nb\nc\n"), &output)
+
+	var exitErr *SedExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 5 {
+		t.Fatalf("expected exit status 5, got %v", err)
+	}
+	if output.String() != "a\nb\n" {
+		t.Errorf("expected output %q, got %q", "a\nb\n", output.String())
+	}
+}
@@ -0,0 +1,506 @@
+package builtin
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// sedAddress is one side of a sed address. Kinds: 'n' a line number, '$'
+// the last line, '/' a regex, '~' first~step, and only as the second
+// address, '+' a line count and 'm' the next multiple of a number.
+type sedAddress struct {
+	kind  byte
+	line  int
+	step  int
+	regex *regexp.Regexp // nil for // , which reuses the last regex
+}
+
+// sedReplacement is a part of an s command's replacement: literal text, a
+// group reference (0 for &), or a case conversion: \U \L \E \u \l
+type sedReplacement struct {
+	literal string
+	group   int
+	caseOp  byte
+}
+
+// sedCommand is a compiled sed command
+type sedCommand struct {
+	addr1, addr2 sedAddress
+	negate       bool
+	name         byte
+	inRange      bool
+	rangeEnd     int
+
+	// s
+	regex       *regexp.Regexp
+	replacement []sedReplacement
+	global      bool
+	occurrence  int
+	print       bool
+
+	text     string        // a, i and c
+	label    string        // b, t, T and :
+	target   int           // Jump target of b, t and T; the matching } of {
+	exitCode int           // q and Q
+	mapping  map[rune]rune // y
+}
+
+// sedParser compiles a sed script
+type sedParser struct {
+	script   string
+	pos      int
+	extended bool
+}
+
+func (p *sedParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("sed: -e expression, char %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *sedParser) eof() bool {
+	return p.pos >= len(p.script)
+}
+
+func (p *sedParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.script[p.pos]
+}
+
+func (p *sedParser) skipSpaces() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+func (p *sedParser) number() (int, bool) {
+	start := p.pos
+	for !p.eof() && p.peek() >= '0' && p.peek() <= '9' {
+		p.pos++
+	}
+	if start == p.pos {
+		return 0, false
+	}
+	n, err := strconv.Atoi(p.script[start:p.pos])
+	return n, err == nil
+}
+
+// parse compiles the script into a list of commands
+func (p *sedParser) parse() ([]sedCommand, error) {
+	var commands []sedCommand
+	var blocks []int
+	for {
+		for !p.eof() && strings.IndexByte(" \t\n;", p.peek()) >= 0 {
+			p.pos++
+		}
+		if p.eof() {
+			break
+		}
+		if p.peek() == '#' {
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+			continue
+		}
+
+		var cmd sedCommand
+		if err := p.parseAddresses(&cmd); err != nil {
+			return nil, err
+		}
+		if p.eof() {
+			return nil, p.errorf("missing command")
+		}
+		cmd.name = p.peek()
+		p.pos++
+		if err := p.parseArguments(&cmd); err != nil {
+			return nil, err
+		}
+
+		switch cmd.name {
+		case '{':
+			blocks = append(blocks, len(commands))
+		case '}':
+			if cmd.addr1.kind != 0 {
+				return nil, p.errorf("} doesn't want any addresses")
+			}
+			if len(blocks) == 0 {
+				return nil, p.errorf("unexpected `}'")
+			}
+			commands[blocks[len(blocks)-1]].target = len(commands)
+			blocks = blocks[:len(blocks)-1]
+		}
+		commands = append(commands, cmd)
+
+		p.skipSpaces()
+		if cmd.name != '{' && !p.eof() && strings.IndexByte(";\n}#", p.peek()) < 0 {
+			return nil, p.errorf("extra characters after command")
+		}
+	}
+	if len(blocks) > 0 {
+		return nil, p.errorf("unmatched `{'")
+	}
+
+	labels := make(map[string]int)
+	for i, cmd := range commands {
+		if cmd.name == ':' {
+			if _, ok := labels[cmd.label]; ok {
+				return nil, fmt.Errorf("sed: duplicate label %q", cmd.label)
+			}
+			labels[cmd.label] = i
+		}
+	}
+	for i := range commands {
+		switch cmd := &commands[i]; cmd.name {
+		case 'b', 't', 'T':
+			if cmd.label == "" {
+				cmd.target = len(commands)
+				continue
+			}
+			target, ok := labels[cmd.label]
+			if !ok {
+				return nil, fmt.Errorf("sed: can't find label for jump to %q", cmd.label)
+			}
+			cmd.target = target
+		}
+	}
+	return commands, nil
+}
+
+// parseAddresses parses an optional address or range and a following !
+func (p *sedParser) parseAddresses(cmd *sedCommand) error {
+	ok, err := p.parseAddress(&cmd.addr1, false)
+	if err != nil || !ok {
+		return err
+	}
+	p.skipSpaces()
+	if p.peek() == ',' {
+		p.pos++
+		p.skipSpaces()
+		if ok, err := p.parseAddress(&cmd.addr2, true); err != nil {
+			return err
+		} else if !ok {
+			return p.errorf("unexpected `,'")
+		}
+	}
+	if cmd.addr1.kind == 'n' && cmd.addr1.line == 0 {
+		if cmd.addr2.kind != '/' {
+			return p.errorf("invalid usage of line address 0")
+		}
+		// 0,/re/ may end the range on the first line
+		cmd.inRange = true
+	}
+	p.skipSpaces()
+	for p.peek() == '!' {
+		cmd.negate = true
+		p.pos++
+		p.skipSpaces()
+	}
+	return nil
+}
+
+func (p *sedParser) parseAddress(addr *sedAddress, second bool) (bool, error) {
+	switch c := p.peek(); {
+	case c >= '0' && c <= '9':
+		addr.kind = 'n'
+		addr.line, _ = p.number()
+		if p.peek() == '~' {
+			p.pos++
+			addr.kind = '~'
+			addr.step, _ = p.number()
+		}
+	case c == '$':
+		p.pos++
+		addr.kind = '$'
+	case second && (c == '+' || c == '~'):
+		p.pos++
+		n, ok := p.number()
+		if !ok {
+			return false, p.errorf("expected a number after %c", c)
+		}
+		addr.kind, addr.line = c, n
+		if c == '~' {
+			addr.kind = 'm'
+		}
+	case c == '/' || c == '\\':
+		p.pos++
+		delim := byte('/')
+		if c == '\\' {
+			if p.eof() {
+				return false, p.errorf("unterminated address regex")
+			}
+			delim = p.peek()
+			p.pos++
+		}
+		pattern, err := p.delimited(delim, true)
+		if err != nil {
+			return false, err
+		}
+		ignoreCase := false
+		for p.peek() == 'I' || p.peek() == 'M' {
+			ignoreCase = ignoreCase || p.peek() == 'I'
+			p.pos++
+		}
+		addr.kind = '/'
+		if addr.regex, err = p.compile(pattern, ignoreCase); err != nil {
+			return false, err
+		}
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// delimited reads up to an unescaped delimiter, which it consumes. An
+// escaped delimiter stands for itself; in a regex other escapes are kept
+// for the regex compiler.
+func (p *sedParser) delimited(delim byte, regex bool) (string, error) {
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated `%c' delimited text", delim)
+		}
+		c := p.peek()
+		p.pos++
+		switch {
+		case c == delim:
+			return b.String(), nil
+		case c == '\\' && !p.eof():
+			next := p.peek()
+			p.pos++
+			switch {
+			case next == delim:
+				b.WriteByte(delim)
+			case next == 'n' && regex:
+				b.WriteString(`\n`)
+			case next == '\n':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(next)
+			}
+		case c == '\n' && regex:
+			return "", p.errorf("unterminated regex")
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+// compile compiles a regex; an empty pattern reuses the last regex
+func (p *sedParser) compile(pattern string, ignoreCase bool) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	if !p.extended {
+		pattern = translateBRE(pattern)
+	}
+	regex, err := compileRegex(pattern, ignoreCase)
+	if err != nil {
+		return nil, fmt.Errorf("sed: %w", err)
+	}
+	return regex, nil
+}
+
+// parseArguments parses what follows a command's name
+func (p *sedParser) parseArguments(cmd *sedCommand) error {
+	switch cmd.name {
+	case '{', '}', '=', 'd', 'D', 'g', 'G', 'h', 'H', 'n', 'N', 'p', 'P', 'x', 'z':
+	case 'q', 'Q':
+		p.skipSpaces()
+		cmd.exitCode, _ = p.number()
+	case 'a', 'i', 'c':
+		cmd.text = p.text()
+	case ':':
+		p.skipSpaces()
+		cmd.label = p.label()
+		if cmd.label == "" {
+			return p.errorf("\":\" lacks a label")
+		}
+	case 'b', 't', 'T':
+		p.skipSpaces()
+		cmd.label = p.label()
+	case 's':
+		return p.parseSubstitution(cmd)
+	case 'y':
+		return p.parseTransliteration(cmd)
+	case 'r', 'R', 'w', 'W':
+		return p.errorf("%c: reading and writing files is not supported", cmd.name)
+	default:
+		return p.errorf("unknown command: `%c'", cmd.name)
+	}
+	return nil
+}
+
+// label reads a label, which ends at a newline or semicolon
+func (p *sedParser) label() string {
+	start := p.pos
+	for !p.eof() && p.peek() != '\n' && p.peek() != ';' {
+		p.pos++
+	}
+	return strings.TrimRight(p.script[start:p.pos], " \t")
+}
+
+// text reads the text of a, i or c: either "a text" or "a\" followed by
+// lines, where a backslash at the end of a line continues the text
+func (p *sedParser) text() string {
+	p.skipSpaces()
+	if p.peek() == '\\' {
+		p.pos++
+		if p.peek() == '\n' {
+			p.pos++
+		}
+	}
+	var b strings.Builder
+	for !p.eof() && p.peek() != '\n' {
+		c := p.peek()
+		p.pos++
+		if c == '\\' && !p.eof() {
+			c = p.peek()
+			p.pos++
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func (p *sedParser) parseSubstitution(cmd *sedCommand) error {
+	if p.eof() || p.peek() == '\n' || p.peek() == '\\' {
+		return p.errorf("unterminated `s' command")
+	}
+	delim := p.peek()
+	p.pos++
+	pattern, err := p.delimited(delim, true)
+	if err != nil {
+		return err
+	}
+	replacement, err := p.delimited(delim, false)
+	if err != nil {
+		return err
+	}
+	cmd.replacement = parseSedReplacement(replacement)
+
+	ignoreCase := false
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == 'g':
+			cmd.global = true
+		case c == 'p':
+			cmd.print = true
+		case c == 'i' || c == 'I':
+			ignoreCase = true
+		case c == 'm' || c == 'M':
+		case c >= '0' && c <= '9':
+			n, _ := p.number()
+			if n == 0 {
+				return p.errorf("number option to `s' command may not be zero")
+			}
+			cmd.occurrence = n
+			continue
+		case c == 'w' || c == 'e':
+			return p.errorf("s///%c is not supported", c)
+		default:
+			return p.compileSubstitution(cmd, pattern, ignoreCase)
+		}
+		p.pos++
+	}
+	return p.compileSubstitution(cmd, pattern, ignoreCase)
+}
+
+// compileSubstitution compiles an s command's regex and checks that the
+// replacement refers only to groups it has
+func (p *sedParser) compileSubstitution(cmd *sedCommand, pattern string, ignoreCase bool) error {
+	regex, err := p.compile(pattern, ignoreCase)
+	if err != nil || regex == nil {
+		return err
+	}
+	for _, part := range cmd.replacement {
+		if part.group > regex.NumSubexp() {
+			return p.errorf("invalid reference \\%d on `s' command's RHS", part.group)
+		}
+	}
+	cmd.regex = regex
+	return nil
+}
+
+// parseSedReplacement splits an s replacement into literals, group
+// references and case conversions
+func parseSedReplacement(s string) []sedReplacement {
+	var parts []sedReplacement
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			parts = append(parts, sedReplacement{literal: literal.String(), group: -1})
+			literal.Reset()
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '&':
+			flush()
+			parts = append(parts, sedReplacement{group: 0})
+		case c == '\\' && i+1 < len(s):
+			i++
+			next := s[i]
+			switch {
+			case next >= '0' && next <= '9':
+				flush()
+				parts = append(parts, sedReplacement{group: int(next - '0')})
+			case strings.IndexByte("ULEul", next) >= 0:
+				flush()
+				parts = append(parts, sedReplacement{group: -1, caseOp: next})
+			case next == 'n':
+				literal.WriteByte('\n')
+			case next == 't':
+				literal.WriteByte('\t')
+			default:
+				literal.WriteByte(next)
+			}
+		default:
+			literal.WriteByte(c)
+		}
+	}
+	flush()
+	return parts
+}
+
+func (p *sedParser) parseTransliteration(cmd *sedCommand) error {
+	if p.eof() || p.peek() == '\n' || p.peek() == '\\' {
+		return p.errorf("unterminated `y' command")
+	}
+	delim := p.peek()
+	p.pos++
+	var sides [2][]rune
+	for i := range sides {
+		text, err := p.delimited(delim, false)
+		if err != nil {
+			return err
+		}
+		for len(text) > 0 {
+			r, size := utf8.DecodeRuneInString(text)
+			text = text[size:]
+			if r == '\\' && len(text) > 0 {
+				r, size = utf8.DecodeRuneInString(text)
+				text = text[size:]
+				switch r {
+				case 'n':
+					r = '\n'
+				case 't':
+					r = '\t'
+				}
+			}
+			sides[i] = append(sides[i], r)
+		}
+	}
+	if len(sides[0]) != len(sides[1]) {
+		return p.errorf("strings for `y' command are different lengths")
+	}
+	cmd.mapping = make(map[rune]rune)
+	for i, r := range sides[0] {
+		cmd.mapping[r] = sides[1][i]
+	}
+	return nil
+}
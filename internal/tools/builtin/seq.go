@@ -0,0 +1,185 @@
+package builtin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Seq prints numbers from FIRST to LAST in steps of INCREMENT, like GNU
+// seq: seq LAST, seq FIRST LAST or seq FIRST INCREMENT LAST, FIRST and
+// INCREMENT defaulting to 1. Numbers may be fractional; they are printed
+// with as many decimals as FIRST and INCREMENT have. -s sets the separator
+// (a newline by default), -w pads with zeros to equal width and -f formats
+// each number with a printf floating point format such as %.2f.
+func Seq(args []string, stdin io.Reader, stdout io.Writer) error {
+	separator, format := "\n", ""
+	equalWidth := false
+	var operands []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if _, err := strconv.ParseFloat(arg, 64); err == nil || !strings.HasPrefix(arg, "-") || arg == "-" {
+			operands = append(operands, arg) // Negative numbers are operands
+			continue
+		}
+		switch {
+		case arg == "--":
+			operands = append(operands, args[i+1:]...)
+			i = len(args)
+			continue
+		case arg == "--equal-width":
+			equalWidth = true
+			continue
+		case strings.HasPrefix(arg, "--separator="):
+			separator = strings.TrimPrefix(arg, "--separator=")
+			continue
+		case strings.HasPrefix(arg, "--format="):
+			format = strings.TrimPrefix(arg, "--format=")
+			continue
+		case strings.HasPrefix(arg, "--"):
+			return fmt.Errorf("seq: %s: invalid option", arg)
+		}
+		for k := 1; k < len(arg); k++ {
+			switch c := arg[k]; c {
+			case 'w':
+				equalWidth = true
+			case 's', 'f':
+				value := arg[k+1:]
+				if value == "" {
+					if i+1 == len(args) {
+						return fmt.Errorf("seq: -%c: missing argument", c)
+					}
+					i++
+					value = args[i]
+				}
+				if c == 's' {
+					separator = value
+				} else {
+					format = value
+				}
+				k = len(arg)
+			default:
+				return fmt.Errorf("seq: -%c: invalid option", c)
+			}
+		}
+	}
+
+	switch {
+	case len(operands) == 0:
+		return fmt.Errorf("seq: missing operand")
+	case len(operands) > 3:
+		return fmt.Errorf("seq: extra operand '%s'", operands[3])
+	case format != "" && equalWidth:
+		return fmt.Errorf("seq: format string may not be specified when printing equal width strings")
+	}
+	if format != "" {
+		if err := checkSeqFormat(format); err != nil {
+			return err
+		}
+	}
+	values := make([]float64, len(operands))
+	for i, operand := range operands {
+		v, err := strconv.ParseFloat(operand, 64)
+		if err != nil || math.IsNaN(v) {
+			return fmt.Errorf("seq: invalid floating point argument: '%s'", operand)
+		}
+		values[i] = v
+	}
+	first, increment, last := 1.0, 1.0, values[len(values)-1]
+	// Every number gets the decimals of first or increment, whichever has
+	// more, as in coreutils: seq 1 0.5 2 prints 1.0, 1.5 and 2.0
+	precision := 0
+	if len(values) > 1 {
+		first = values[0]
+		precision = seqDecimals(operands[0])
+	}
+	if len(values) == 3 {
+		increment = values[1]
+		if increment == 0 {
+			return fmt.Errorf("seq: invalid Zero increment value: '%s'", operands[1])
+		}
+		if d := seqDecimals(operands[1]); d > precision {
+			precision = d
+		}
+	}
+
+	if format == "" {
+		format = fmt.Sprintf("%%.%df", precision)
+		if equalWidth {
+			width := len(fmt.Sprintf(format, first))
+			if w := len(fmt.Sprintf(format, last)); w > width {
+				width = w
+			}
+			format = fmt.Sprintf("%%0%d.%df", width, precision)
+		}
+	}
+
+	out := bufio.NewWriter(stdout)
+	// Rounding must not lose the last number, as in seq 0.1 0.1 0.3
+	tolerance := math.Abs(increment) * 1e-9
+	i := 0
+	for ; ; i++ {
+		v := first + float64(i)*increment
+		if (increment > 0 && v > last+tolerance) || (increment < 0 && v < last-tolerance) {
+			break
+		}
+		if i > 0 {
+			out.WriteString(separator)
+		}
+		text, _, _ := (&printfFormatter{args: []string{strconv.FormatFloat(v, 'g', -1, 64)}}).format(format)
+		if _, err := out.WriteString(text); err != nil {
+			return err // The reader has gone
+		}
+		if math.IsInf(v, 0) {
+			i++
+			break
+		}
+	}
+	if i > 0 {
+		out.WriteString("\n")
+	}
+	return out.Flush()
+}
+
+// seqDecimals returns the number of decimals a number is written with
+func seqDecimals(number string) int {
+	dot := strings.IndexByte(number, '.')
+	if dot < 0 || strings.ContainsAny(number, "eExX") {
+		return 0
+	}
+	return len(number) - dot - 1
+}
+
+// checkSeqFormat checks that a -f format has exactly one floating point
+// conversion
+func checkSeqFormat(format string) error {
+	count := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		if i+1 < len(format) && format[i+1] == '%' {
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(format) && strings.IndexByte("-+ #0123456789.", format[j]) >= 0 {
+			j++
+		}
+		if j == len(format) || strings.IndexByte("eEfFgG", format[j]) < 0 {
+			return fmt.Errorf("seq: format '%s' has an invalid conversion", format)
+		}
+		count++
+		i = j
+	}
+	switch {
+	case count == 0:
+		return fmt.Errorf("seq: format '%s' has no %% directive", format)
+	case count > 1:
+		return fmt.Errorf("seq: format '%s' has too many %% directives", format)
+	}
+	return nil
+}
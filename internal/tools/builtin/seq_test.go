@@ -0,0 +1,106 @@
+package builtin
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSeq(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name:           "last",
+			args:           []string{"3"},
+			expectedOutput: "1\n2\n3\n",
+		},
+		{
+			name:           "first and last",
+			args:           []string{"-2", "1"},
+			expectedOutput: "-2\n-1\n0\n1\n",
+		},
+		{
+			name:           "decreasing",
+			args:           []string{"10", "-3", "1"},
+			expectedOutput: "10\n7\n4\n1\n",
+		},
+		{
+			name:           "fractional increment keeps the last number",
+			args:           []string{"0.1", "0.1", "0.3"},
+			expectedOutput: "0.1\n0.2\n0.3\n",
+		},
+		{
+			// As coreutils: the decimals of first and increment, for every number
+			name:           "fractional increment pads whole numbers",
+			args:           []string{"1", "0.5", "2"},
+			expectedOutput: "1.0\n1.5\n2.0\n",
+		},
+		{
+			name:           "decimals of last are ignored",
+			args:           []string{"1", "2.50"},
+			expectedOutput: "1\n2\n",
+		},
+		{
+			name:           "separator",
+			args:           []string{"-s", ",", "4"},
+			expectedOutput: "1,2,3,4\n",
+		},
+		{
+			name:           "equal width",
+			args:           []string{"-w", "8", "10"},
+			expectedOutput: "08\n09\n10\n",
+		},
+		{
+			name:           "format",
+			args:           []string{"-f", "item%03g", "2"},
+			expectedOutput: "item001\nitem002\n",
+		},
+		{
+			name:           "empty range",
+			args:           []string{"5", "1"},
+			expectedOutput: "",
+		},
+		{
+			name:          "zero increment",
+			args:          []string{"1", "0", "5"},
+			expectedError: "seq: invalid Zero increment value: '0'",
+		},
+		{
+			name:          "invalid number",
+			args:          []string{"x"},
+			expectedError: "seq: invalid floating point argument: 'x'",
+		},
+		{
+			name:          "format without a directive",
+			args:          []string{"-f", "x", "3"},
+			expectedError: "seq: format 'x' has no % directive",
+		},
+		{
+			name:          "missing operand",
+			expectedError: "seq: missing operand",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output bytes.Buffer
+			err := Seq(test.args, strings.NewReader(""), &output)
+			if test.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != test.expectedOutput {
+				t.Errorf("expected output %q, got %q", test.expectedOutput, output.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,402 @@
+package builtin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sortOrder holds the ordering options that apply to the whole line or to
+// a single key: -b, -f, -n and -r
+type sortOrder struct {
+	skipBlanks bool
+	foldCase   bool
+	numeric    bool
+	reverse    bool
+}
+
+// set applies an ordering option letter, reporting whether it is one
+func (o *sortOrder) set(c byte) bool {
+	switch c {
+	case 'b':
+		o.skipBlanks = true
+	case 'f':
+		o.foldCase = true
+	case 'n':
+		o.numeric = true
+	case 'r':
+		o.reverse = true
+	default:
+		return false
+	}
+	return true
+}
+
+// sortKey is a -k POS1[,POS2] key. Fields and characters are 1-based; an
+// endField of 0 means the end of the line and an endChar of 0 the end of
+// the field.
+type sortKey struct {
+	startField, startChar int
+	endField, endChar     int
+	order                 sortOrder
+	hasOrder              bool // The key has its own options and ignores the global ones
+}
+
+// sortOptions are the parsed options of a sort invocation
+type sortOptions struct {
+	order     sortOrder
+	keys      []sortKey
+	separator string // -t; empty means fields are separated by runs of blanks
+	unique    bool
+	stable    bool
+}
+
+// Sort sorts lines of text. It follows coreutils in the C locale: -n
+// compares leading numbers, treating lines without one as 0, -k selects
+// keys, -t sets the field separator, -r reverses, -u keeps the first of
+// each run of equal lines, and lines whose keys compare equal fall back to
+// a whole-line comparison unless -s or -u is given.
+func Sort(args []string, stdin io.Reader, stdout io.Writer) error {
+	opts, err := parseSortArgs(args)
+	if err != nil {
+		return err
+	}
+	var texts []string
+	scanner := bufio.NewScanner(stdin)
+	for scanner.Scan() {
+		texts = append(texts, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for _, text := range opts.sortLines(texts) {
+		fmt.Fprintln(stdout, text)
+	}
+	return nil
+}
+
+// sortLines returns texts in sorted order, keeping only the first of each
+// run of equal keys with -u
+func (opts sortOptions) sortLines(texts []string) []string {
+	keys := append([]sortKey(nil), opts.keys...)
+	if len(keys) == 0 {
+		keys = []sortKey{{startField: 1, startChar: 1}}
+	}
+	for i := range keys {
+		if !keys[i].hasOrder {
+			keys[i].order = opts.order
+		}
+	}
+
+	type sortLine struct {
+		text string
+		keys []string
+	}
+	lines := make([]sortLine, len(texts))
+	for n, text := range texts {
+		lines[n] = sortLine{text: text, keys: make([]string, len(keys))}
+		for i, key := range keys {
+			lines[n].keys[i] = key.extract(text, opts.separator)
+		}
+	}
+
+	// compareKeys orders two lines by their keys alone
+	compareKeys := func(a, b sortLine) int {
+		for i, key := range keys {
+			if c := key.order.compare(a.keys[i], b.keys[i]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		c := compareKeys(lines[i], lines[j])
+		if c == 0 && !opts.stable && !opts.unique {
+			c = strings.Compare(lines[i].text, lines[j].text)
+			if opts.order.reverse {
+				c = -c
+			}
+		}
+		return c < 0
+	})
+
+	sorted := make([]string, 0, len(lines))
+	for i, line := range lines {
+		if opts.unique && i > 0 && compareKeys(lines[i-1], line) == 0 {
+			continue
+		}
+		sorted = append(sorted, line.text)
+	}
+	return sorted
+}
+
+// parseSortArgs parses sort's options. Short options may be combined, as in
+// -rn or -nk2, and -k and -t take their value attached or as the next
+// argument.
+func parseSortArgs(args []string) (sortOptions, error) {
+	var opts sortOptions
+	addKey := func(spec string) error {
+		key, err := parseSortKey(spec)
+		if err != nil {
+			return err
+		}
+		opts.keys = append(opts.keys, key)
+		return nil
+	}
+	setSeparator := func(sep string) error {
+		if len(sep) != 1 {
+			return fmt.Errorf("sort: -t: the separator must be a single character")
+		}
+		opts.separator = sep
+		return nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if strings.HasPrefix(arg, "--") {
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			if (name == "key" || name == "field-separator") && !hasValue {
+				if i+1 == len(args) {
+					return opts, fmt.Errorf("sort: --%s: missing argument", name)
+				}
+				i++
+				value = args[i]
+			}
+			var err error
+			switch name {
+			case "reverse":
+				opts.order.reverse = true
+			case "numeric-sort":
+				opts.order.numeric = true
+			case "ignore-case":
+				opts.order.foldCase = true
+			case "ignore-leading-blanks":
+				opts.order.skipBlanks = true
+			case "unique":
+				opts.unique = true
+			case "stable":
+				opts.stable = true
+			case "key":
+				err = addKey(value)
+			case "field-separator":
+				err = setSeparator(value)
+			default:
+				return opts, fmt.Errorf("sort: %s: invalid option", arg)
+			}
+			if err != nil {
+				return opts, err
+			}
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return opts, fmt.Errorf("sort: %s: file operands are not supported; pipe the input instead", arg)
+		}
+
+		for j := 1; j < len(arg); j++ {
+			c := arg[j]
+			if opts.order.set(c) {
+				continue
+			}
+			switch c {
+			case 'u':
+				opts.unique = true
+			case 's':
+				opts.stable = true
+			case 'k', 't':
+				value := arg[j+1:]
+				if value == "" {
+					if i+1 == len(args) {
+						return opts, fmt.Errorf("sort: -%c: missing argument", c)
+					}
+					i++
+					value = args[i]
+				}
+				var err error
+				if c == 'k' {
+					err = addKey(value)
+				} else {
+					err = setSeparator(value)
+				}
+				if err != nil {
+					return opts, err
+				}
+				j = len(arg)
+			default:
+				return opts, fmt.Errorf("sort: -%c: invalid option", c)
+			}
+		}
+	}
+	return opts, nil
+}
+
+// parseSortKey parses a key definition: F[.C][OPTS][,F[.C][OPTS]]
+func parseSortKey(spec string) (sortKey, error) {
+	invalid := fmt.Errorf("sort: -k %s: invalid key specification", spec)
+	var key sortKey
+
+	// position parses F[.C][OPTS], returning the field and character
+	position := func(s string) (int, int, bool) {
+		end := strings.TrimRight(s, "bfnr")
+		for _, c := range []byte(s[len(end):]) {
+			key.order.set(c)
+			key.hasOrder = true
+		}
+		fieldText, charText, hasChar := strings.Cut(end, ".")
+		field, err := strconv.Atoi(fieldText)
+		if err != nil || field < 0 {
+			return 0, 0, false
+		}
+		char := 0
+		if hasChar {
+			if char, err = strconv.Atoi(charText); err != nil || char < 0 {
+				return 0, 0, false
+			}
+		}
+		return field, char, true
+	}
+
+	start, end, hasEnd := strings.Cut(spec, ",")
+	var ok bool
+	if key.startField, key.startChar, ok = position(start); !ok || key.startField == 0 {
+		return key, invalid
+	}
+	if key.startChar == 0 {
+		if strings.Contains(start, ".") {
+			return key, invalid
+		}
+		key.startChar = 1
+	}
+	if hasEnd {
+		if key.endField, key.endChar, ok = position(end); !ok || key.endField == 0 {
+			return key, invalid
+		}
+	}
+	return key, nil
+}
+
+// extract returns the part of a line the key covers
+func (k sortKey) extract(line, separator string) string {
+	fields := sortFieldSpans(line, separator)
+
+	start := len(line)
+	if k.startField <= len(fields) {
+		span := fields[k.startField-1]
+		start = span[0]
+		if k.order.skipBlanks {
+			start += sortBlankPrefix(line[start:span[1]])
+		}
+		start = min(start+k.startChar-1, span[1])
+	}
+
+	end := len(line)
+	if k.endField > 0 && k.endField <= len(fields) {
+		span := fields[k.endField-1]
+		end = span[1]
+		if k.endChar > 0 {
+			pos := span[0]
+			if k.order.skipBlanks {
+				pos += sortBlankPrefix(line[pos:span[1]])
+			}
+			end = min(pos+k.endChar, span[1])
+		}
+	}
+	if end < start {
+		return ""
+	}
+	return line[start:end]
+}
+
+// sortFieldSpans returns the [start, end) offsets of a line's fields. With
+// no separator a field is a run of blanks followed by non-blanks, so the
+// leading blanks belong to the field, as in coreutils.
+func sortFieldSpans(line, separator string) [][2]int {
+	var spans [][2]int
+	if separator != "" {
+		start := 0
+		for {
+			i := strings.Index(line[start:], separator)
+			if i < 0 {
+				return append(spans, [2]int{start, len(line)})
+			}
+			spans = append(spans, [2]int{start, start + i})
+			start += i + len(separator)
+		}
+	}
+	for pos := 0; pos < len(line); {
+		start := pos
+		pos += sortBlankPrefix(line[pos:])
+		for pos < len(line) && !isSortBlank(line[pos]) {
+			pos++
+		}
+		spans = append(spans, [2]int{start, pos})
+	}
+	return spans
+}
+
+func isSortBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+func sortBlankPrefix(s string) int {
+	n := 0
+	for n < len(s) && isSortBlank(s[n]) {
+		n++
+	}
+	return n
+}
+
+// compare orders two keys, returning -1, 0 or 1
+func (o sortOrder) compare(a, b string) int {
+	var c int
+	switch {
+	case o.numeric:
+		x, y := sortNumber(a), sortNumber(b)
+		if x < y {
+			c = -1
+		} else if x > y {
+			c = 1
+		}
+	default:
+		if o.foldCase {
+			a, b = strings.ToUpper(a), strings.ToUpper(b)
+		}
+		c = strings.Compare(a, b)
+	}
+	if o.reverse {
+		c = -c
+	}
+	return c
+}
+
+// sortNumber returns the number at the start of s, after any blanks: an
+// optional minus sign, digits and a decimal fraction. Anything else is 0.
+func sortNumber(s string) float64 {
+	s = s[sortBlankPrefix(s):]
+	end := 0
+	if end < len(s) && s[end] == '-' {
+		end++
+	}
+	digits := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+		digits++
+	}
+	if end < len(s) && s[end] == '.' {
+		end++
+		for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+			end++
+			digits++
+		}
+	}
+	if digits == 0 {
+		return 0
+	}
+	n, err := strconv.ParseFloat(strings.TrimSuffix(s[:end], "."), 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,122 @@
+package builtin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSortOptions(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		input          string
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name:           "lexicographic by default",
+			input:          "10\n9\nB\na\n",
+			expectedOutput: "10\n9\nB\na\n",
+		},
+		{
+			name:           "numeric treats non-numbers as zero",
+			args:           []string{"-n"},
+			input:          "10\nx\n9\n-1.5\n2.5kb\n",
+			expectedOutput: "-1.5\nx\n2.5kb\n9\n10\n",
+		},
+		{
+			name:           "combined reverse numeric",
+			args:           []string{"-rn"},
+			input:          "1\n10\n2\n",
+			expectedOutput: "10\n2\n1\n",
+		},
+		{
+			name:           "key with separator",
+			args:           []string{"-t", ",", "-k2,2n"},
+			input:          "a,10,x\nb,9,y\nc,10,a\n",
+			expectedOutput: "b,9,y\na,10,x\nc,10,a\n",
+		},
+		{
+			name:           "attached key and separator",
+			args:           []string{"-t:", "-nk3"},
+			input:          "root:x:0\nuser:x:1000\nbin:x:2\n",
+			expectedOutput: "root:x:0\nbin:x:2\nuser:x:1000\n",
+		},
+		{
+			name:           "blank separated keys with per-key reverse",
+			args:           []string{"-k2,2nr", "-k1,1"},
+			input:          "b 1\na 2\nc 2\n",
+			expectedOutput: "a 2\nc 2\nb 1\n",
+		},
+		{
+			name:           "key from a character offset",
+			args:           []string{"-k1.2"},
+			input:          "xb\nya\nzc\n",
+			expectedOutput: "ya\nxb\nzc\n",
+		},
+		{
+			name:           "unique compares keys only",
+			args:           []string{"-u", "-t,", "-k1,1"},
+			input:          "b,2\na,1\nb,1\na,2\n",
+			expectedOutput: "a,1\nb,2\n",
+		},
+		{
+			name:           "numeric unique",
+			args:           []string{"-nu"},
+			input:          "01\n1\n2\n",
+			expectedOutput: "01\n2\n",
+		},
+		{
+			name:           "reverse applies to the last-resort comparison",
+			args:           []string{"-r", "-f"},
+			input:          "a\nB\nA\n",
+			expectedOutput: "B\na\nA\n",
+		},
+		{
+			name:           "long options",
+			args:           []string{"--numeric-sort", "--reverse", "--key=2"},
+			input:          "x 1\ny 3\nz 2\n",
+			expectedOutput: "y 3\nz 2\nx 1\n",
+		},
+		{
+			name:          "invalid key",
+			args:          []string{"-k", "0"},
+			expectedError: "sort: -k 0: invalid key specification",
+		},
+		{
+			name:          "multi-character separator",
+			args:          []string{"-t", "::"},
+			expectedError: "the separator must be a single character",
+		},
+		{
+			name:          "unknown option",
+			args:          []string{"-z"},
+			expectedError: "sort: -z: invalid option",
+		},
+		{
+			name:          "file operand",
+			args:          []string{"data.txt"},
+			expectedError: "file operands are not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output strings.Builder
+			err := Sort(tt.args, strings.NewReader(tt.input), &output)
+
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != tt.expectedOutput {
+				t.Errorf("expected output %q, got %q", tt.expectedOutput, output.String())
+			}
+		})
+	}
+}
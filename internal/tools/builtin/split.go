@@ -0,0 +1,386 @@
+package builtin
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// splitOptions are the parsed options of a split invocation
+type splitOptions struct {
+	mode             byte  // 'l' lines, 'b' bytes, 'C' line bytes or 'n' chunks
+	size             int64 // Lines or bytes per file, or the number of files
+	wholeLines       bool  // -n l/N: do not split lines
+	suffixLength     int
+	suffixDigits     string
+	additionalSuffix string
+	elideEmpty       bool
+	input            string
+	prefix           string
+}
+
+// RunSplit splits its input into files named PREFIXaa, PREFIXab and so on,
+// following GNU split: -l N lines per file (1000 by default), -b SIZE bytes
+// per file, -C SIZE bytes of whole lines per file, or -n N (or l/N) files.
+// The names of the files are printed as they are written, so the pieces
+// can be processed one by one. The input is stdin or a file operand, and
+// the second operand is the prefix, x by default.
+func RunSplit(args []string, stdin io.Reader, stdout io.Writer, files Files) error {
+	opts, err := parseSplitArgs(args)
+	if err != nil {
+		return err
+	}
+	if files == nil {
+		return fmt.Errorf("split: writing files is not supported here")
+	}
+
+	input := stdin
+	if opts.input != "-" {
+		file, err := files.Open(opts.input)
+		if err != nil {
+			return fmt.Errorf("split: %s: %w", opts.input, err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	w := &splitWriter{opts: opts, files: files, out: bufio.NewWriter(stdout)}
+	switch opts.mode {
+	case 'l':
+		err = w.splitLines(bufio.NewReader(input))
+	case 'b':
+		err = w.splitBytes(bufio.NewReader(input))
+	case 'C':
+		err = w.splitLineBytes(bufio.NewReader(input))
+	default:
+		err = w.splitChunks(input)
+	}
+	if closeErr := w.close(); err == nil {
+		err = closeErr
+	}
+	if flushErr := w.out.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+// parseSplitArgs parses split's options. Short options may be combined and
+// take their value from the rest of the argument or the next one, as in
+// -l10 or -l 10; -N is -l N. Long options take theirs after =.
+func parseSplitArgs(args []string) (splitOptions, error) {
+	opts := splitOptions{mode: 'l', size: 1000, suffixLength: 2, suffixDigits: "abcdefghijklmnopqrstuvwxyz"}
+	var operands []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			operands = append(operands, args[i+1:]...)
+			i = len(args)
+			continue
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			operands = append(operands, arg)
+			continue
+		case arg[1] >= '0' && arg[1] <= '9':
+			if err := opts.set('l', arg[1:]); err != nil {
+				return opts, err
+			}
+			continue
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg, "=")
+			long := map[string]byte{
+				"--lines": 'l', "--bytes": 'b', "--line-bytes": 'C', "--number": 'n',
+				"--suffix-length": 'a', "--additional-suffix": 's',
+			}
+			switch name {
+			case "--numeric-suffixes":
+				opts.suffixDigits = "0123456789"
+			case "--hex-suffixes":
+				opts.suffixDigits = "0123456789abcdef"
+			case "--elide-empty-files":
+				opts.elideEmpty = true
+			case "--verbose":
+				// The names are always printed
+			default:
+				c, ok := long[name]
+				if !ok {
+					return opts, fmt.Errorf("split: %s: invalid option", name)
+				}
+				if !hasValue {
+					return opts, fmt.Errorf("split: %s: missing argument", name)
+				}
+				if err := opts.set(c, value); err != nil {
+					return opts, err
+				}
+			}
+			continue
+		}
+
+		for j := 1; j < len(arg); j++ {
+			switch c := arg[j]; c {
+			case 'd':
+				opts.suffixDigits = "0123456789"
+			case 'x':
+				opts.suffixDigits = "0123456789abcdef"
+			case 'e':
+				opts.elideEmpty = true
+			case 'l', 'b', 'C', 'n', 'a':
+				value := arg[j+1:]
+				if value == "" {
+					if i+1 == len(args) {
+						return opts, fmt.Errorf("split: -%c: missing argument", c)
+					}
+					i++
+					value = args[i]
+				}
+				if err := opts.set(c, value); err != nil {
+					return opts, err
+				}
+				j = len(arg)
+			default:
+				return opts, fmt.Errorf("split: -%c: invalid option", c)
+			}
+		}
+	}
+
+	opts.input, opts.prefix = "-", "x"
+	switch len(operands) {
+	case 2:
+		opts.prefix = operands[1]
+		fallthrough
+	case 1:
+		opts.input = operands[0]
+	case 0:
+	default:
+		return opts, fmt.Errorf("split: extra operand '%s'", operands[2])
+	}
+	return opts, nil
+}
+
+// set applies an option that takes a value; s is --additional-suffix
+func (opts *splitOptions) set(option byte, value string) error {
+	switch option {
+	case 'l':
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("split: invalid number of lines: '%s'", value)
+		}
+		opts.mode, opts.size = 'l', n
+	case 'b', 'C':
+		n, err := parseSplitSize(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("split: invalid number of bytes: '%s'", value)
+		}
+		opts.mode, opts.size = option, n
+	case 'n':
+		text, whole := strings.CutPrefix(value, "l/")
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("split: invalid number of chunks: '%s'; use N or l/N", value)
+		}
+		opts.mode, opts.size, opts.wholeLines = 'n', n, whole
+	case 'a':
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("split: invalid suffix length: '%s'", value)
+		}
+		opts.suffixLength = n
+	case 's':
+		if strings.Contains(value, "/") {
+			return fmt.Errorf("split: invalid suffix '%s', contains directory separator", value)
+		}
+		opts.additionalSuffix = value
+	}
+	return nil
+}
+
+// parseSplitSize parses a size with an optional multiplier: K, M and G are
+// powers of 1024, KB, MB and GB powers of 1000
+func parseSplitSize(value string) (int64, error) {
+	multipliers := []struct {
+		suffix string
+		factor int64
+	}{
+		{"KB", 1000}, {"MB", 1000 * 1000}, {"GB", 1000 * 1000 * 1000},
+		{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+	}
+	factor := int64(1)
+	for _, m := range multipliers {
+		if strings.HasSuffix(value, m.suffix) {
+			value, factor = strings.TrimSuffix(value, m.suffix), m.factor
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	return n * factor, err
+}
+
+// splitWriter writes the output files one after another
+type splitWriter struct {
+	opts  splitOptions
+	files Files
+	out   *bufio.Writer
+	index int
+	file  io.WriteCloser
+}
+
+// next closes the current file and starts the next one
+func (w *splitWriter) next() error {
+	if err := w.close(); err != nil {
+		return err
+	}
+	name, err := w.name(w.index)
+	if err != nil {
+		return err
+	}
+	w.index++
+	file, err := w.files.Create(name)
+	if err != nil {
+		return fmt.Errorf("split: %s: %w", name, err)
+	}
+	w.file = file
+	w.out.WriteString(name)
+	w.out.WriteByte('\n')
+	return nil
+}
+
+func (w *splitWriter) close() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// name returns the name of the nth file
+func (w *splitWriter) name(n int) (string, error) {
+	digits := w.opts.suffixDigits
+	suffix := make([]byte, w.opts.suffixLength)
+	for i := len(suffix) - 1; i >= 0; i-- {
+		suffix[i] = digits[n%len(digits)]
+		n /= len(digits)
+	}
+	if n > 0 {
+		return "", fmt.Errorf("split: output file suffixes exhausted; use -a to make them longer")
+	}
+	return w.opts.prefix + string(suffix) + w.opts.additionalSuffix, nil
+}
+
+func (w *splitWriter) write(data []byte) error {
+	_, err := w.file.Write(data)
+	return err
+}
+
+// splitLines writes a file per N lines
+func (w *splitWriter) splitLines(r *bufio.Reader) error {
+	var count int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			if count%w.opts.size == 0 {
+				if err := w.next(); err != nil {
+					return err
+				}
+			}
+			if err := w.write(line); err != nil {
+				return err
+			}
+			count++
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("split: %w", err)
+		}
+	}
+}
+
+// splitBytes writes a file per N bytes
+func (w *splitWriter) splitBytes(r *bufio.Reader) error {
+	for {
+		if _, err := r.Peek(1); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("split: %w", err)
+		}
+		if err := w.next(); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(w.file, r, w.opts.size); err != nil && err != io.EOF {
+			return fmt.Errorf("split: %w", err)
+		}
+	}
+}
+
+// splitLineBytes writes as many whole lines as fit in N bytes per file;
+// a line longer than that is broken into pieces of N bytes
+func (w *splitWriter) splitLineBytes(r *bufio.Reader) error {
+	var used int64
+	for {
+		line, err := r.ReadBytes('\n')
+		for len(line) > 0 {
+			if w.file == nil || used+int64(len(line)) > w.opts.size && used > 0 {
+				if err := w.next(); err != nil {
+					return err
+				}
+				used = 0
+			}
+			piece := line[:min(int64(len(line)), w.opts.size-used)]
+			if err := w.write(piece); err != nil {
+				return err
+			}
+			used += int64(len(piece))
+			line = line[len(piece):]
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("split: %w", err)
+		}
+	}
+}
+
+// splitChunks writes N files of equal size, the last taking the rest. With
+// l/N, each file ends at the end of the line its share ends in, so a file
+// may be empty; -e leaves empty files out.
+func (w *splitWriter) splitChunks(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("split: %w", err)
+	}
+	n := w.opts.size
+	chunk := int64(len(data)) / n
+	start := int64(0)
+	for k := int64(0); k < n; k++ {
+		end := (k + 1) * chunk
+		if k == n-1 {
+			end = int64(len(data))
+		}
+		if w.opts.wholeLines && end > start && end < int64(len(data)) {
+			if i := bytes.IndexByte(data[end-1:], '\n'); i >= 0 {
+				end += int64(i)
+			} else {
+				end = int64(len(data))
+			}
+		}
+		if end < start {
+			end = start
+		}
+		if end == start && w.opts.elideEmpty {
+			continue
+		}
+		if err := w.next(); err != nil {
+			return err
+		}
+		if err := w.write(data[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package builtin
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		input          string
+		expectedOutput string
+		expectedFiles  map[string]string
+		expectedError  string
+	}{
+		{
+			name:           "lines",
+			args:           []string{"-l", "2"},
+			input:          "1\n2\n3\n4\n5",
+			expectedOutput: "xaa\nxab\nxac\n",
+			expectedFiles:  map[string]string{"xaa": "1\n2\n", "xab": "3\n4\n", "xac": "5"},
+		},
+		{
+			name:           "legacy line count with prefix",
+			args:           []string{"-3", "-", "part."},
+			input:          "a\nb\nc\nd\n",
+			expectedOutput: "part.aa\npart.ab\n",
+			expectedFiles:  map[string]string{"part.aa": "a\nb\nc\n", "part.ab": "d\n"},
+		},
+		{
+			name:           "bytes with numeric suffixes",
+			args:           []string{"-d", "-a", "3", "-b4", "--additional-suffix=.txt"},
+			input:          "abcdefghij",
+			expectedOutput: "x000.txt\nx001.txt\nx002.txt\n",
+			expectedFiles:  map[string]string{"x000.txt": "abcd", "x001.txt": "efgh", "x002.txt": "ij"},
+		},
+		{
+			name:           "line bytes",
+			args:           []string{"-C", "6"},
+			input:          "ab\ncd\nefghijklm\nn\n",
+			expectedOutput: "xaa\nxab\nxac\n",
+			expectedFiles:  map[string]string{"xaa": "ab\ncd\n", "xab": "efghij", "xac": "klm\nn\n"},
+		},
+		{
+			name:           "chunks",
+			args:           []string{"-n", "3"},
+			input:          "abcdefgh",
+			expectedOutput: "xaa\nxab\nxac\n",
+			expectedFiles:  map[string]string{"xaa": "ab", "xab": "cd", "xac": "efgh"},
+		},
+		{
+			name:           "chunks of whole lines",
+			args:           []string{"--number=l/2"},
+			input:          "one\ntwo\nthree\nfour\n",
+			expectedOutput: "xaa\nxab\n",
+			expectedFiles:  map[string]string{"xaa": "one\ntwo\nthree\n", "xab": "four\n"},
+		},
+		{
+			name:           "empty chunks elided",
+			args:           []string{"-e", "-n", "l/3"},
+			input:          "a very long line\n",
+			expectedOutput: "xaa\n",
+			expectedFiles:  map[string]string{"xaa": "a very long line\n"},
+		},
+		{
+			name:           "input from a file",
+			args:           []string{"-l1", "in.txt", "in."},
+			expectedOutput: "in.aa\nin.ab\n",
+			expectedFiles:  map[string]string{"in.aa": "x\n", "in.ab": "y\n"},
+		},
+		{
+			name:           "empty input",
+			args:           []string{"-l", "10"},
+			expectedOutput: "",
+			expectedFiles:  map[string]string{},
+		},
+		{
+			name:          "suffixes exhausted",
+			args:          []string{"-a", "1", "-l", "1"},
+			input:         strings.Repeat("x\n", 27),
+			expectedError: "split: output file suffixes exhausted",
+		},
+		{
+			name:          "invalid size",
+			args:          []string{"-b", "10Q"},
+			expectedError: "split: invalid number of bytes: '10Q'",
+		},
+		{
+			name:          "invalid option",
+			args:          []string{"-z"},
+			expectedError: "split: -z: invalid option",
+		},
+		{
+			name:          "extra operand",
+			args:          []string{"a", "b", "c"},
+			expectedError: "split: extra operand 'c'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files := memoryFiles{"in.txt": bytes.NewBufferString("x\ny\n")}
+			var output bytes.Buffer
+			err := RunSplit(test.args, strings.NewReader(test.input), &output, files)
+			if test.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != test.expectedOutput {
+				t.Errorf("expected output %q, got %q", test.expectedOutput, output.String())
+			}
+			delete(files, "in.txt")
+			if len(files) != len(test.expectedFiles) {
+				t.Errorf("expected %d files, got %d", len(test.expectedFiles), len(files))
+			}
+			for name, want := range test.expectedFiles {
+				if got, ok := files[name]; !ok || got.String() != want {
+					t.Errorf("file %s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+
+	if err := RunSplit(nil, strings.NewReader("x\n"), &bytes.Buffer{}, nil); err == nil {
+		t.Error("split without files succeeded, want an error")
+	}
+}
@@ -0,0 +1,308 @@
+package builtin
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// statsOptions are the options of stats
+type statsOptions struct {
+	delimiter rune   // Splits fields; 0 splits them on white space
+	csv       bool   // Fields are quoted as in CSV
+	header    bool   // The first row names the columns
+	columns   string // -c: the columns to summarize, all if empty
+	top       int    // -n: how many of the most frequent values to show
+}
+
+// Stats summarizes its input in one pass: the counts of lines, words,
+// characters and bytes, and for each column the number of values, the
+// minimum, maximum, mean and sum of those that are numbers, and the most
+// frequent values. Fields are split on white space, or on -d DELIM; --csv
+// reads quoted CSV with a header row.
+func Stats(args []string, stdin io.Reader, stdout io.Writer) error {
+	opts := statsOptions{top: 5}
+	headerSet := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--csv":
+			opts.csv = true
+			if opts.delimiter == 0 {
+				opts.delimiter = ','
+			}
+			continue
+		case arg == "--header":
+			opts.header, headerSet = true, true
+			continue
+		case strings.HasPrefix(arg, "--delimiter="):
+			arg = "-d" + strings.TrimPrefix(arg, "--delimiter=")
+		case strings.HasPrefix(arg, "--columns="):
+			arg = "-c" + strings.TrimPrefix(arg, "--columns=")
+		case strings.HasPrefix(arg, "--top="):
+			arg = "-n" + strings.TrimPrefix(arg, "--top=")
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			return fmt.Errorf("stats: %s: file operands are not supported; pipe the input instead", arg)
+		case strings.HasPrefix(arg, "--"):
+			return fmt.Errorf("stats: %s: invalid option", arg)
+		}
+
+		for k := 1; k < len(arg); k++ {
+			c := arg[k]
+			switch c {
+			case 't':
+				opts.delimiter = '\t'
+			case 'H':
+				opts.header, headerSet = false, true
+			case 'd', 'c', 'n':
+				value := arg[k+1:]
+				if value == "" {
+					if i+1 == len(args) {
+						return fmt.Errorf("stats: -%c: missing argument", c)
+					}
+					i++
+					value = args[i]
+				}
+				k = len(arg)
+				switch c {
+				case 'd':
+					r, size := utf8.DecodeRuneInString(value)
+					if size == 0 || size != len(value) {
+						return fmt.Errorf("stats: -d: delimiter must be a single character")
+					}
+					opts.delimiter = r
+				case 'c':
+					opts.columns = value
+				case 'n':
+					n, err := strconv.Atoi(value)
+					if err != nil || n < 0 {
+						return fmt.Errorf("stats: -n: invalid number %q", value)
+					}
+					opts.top = n
+				}
+			default:
+				return fmt.Errorf("stats: -%c: invalid option", c)
+			}
+		}
+	}
+	if !headerSet {
+		// A CSV table has a header, as for the csv commands
+		opts.header = opts.csv
+	}
+
+	var counter textCounter
+	rows := statsRows(io.TeeReader(stdin, &counter), opts)
+	var columns []*columnStats
+	first := true
+	for {
+		row, err := rows()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("stats: %w", err)
+		}
+		if len(row) == 0 {
+			continue
+		}
+
+		if first {
+			// The first row names the columns, or is the first of their values
+			first = false
+			var header []string
+			if opts.header {
+				header = row
+			}
+			if opts.columns != "" {
+				selected, err := resolveCSVColumns("stats", opts.columns, header, len(row))
+				if err != nil {
+					return err
+				}
+				for _, index := range selected {
+					columns = append(columns, newColumnStats(index, header))
+				}
+			} else {
+				for i := range header {
+					columns = append(columns, newColumnStats(i, header))
+				}
+			}
+			if header != nil {
+				continue
+			}
+		}
+
+		if opts.columns == "" {
+			for len(columns) < len(row) {
+				columns = append(columns, newColumnStats(len(columns), nil))
+			}
+		}
+		for _, column := range columns {
+			column.add(csvField(row, column.index), column.index < len(row))
+		}
+	}
+
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+	fmt.Fprintf(out, "lines %d, words %d, chars %d, bytes %d\n", counter.lineCount(), counter.words, counter.chars, counter.bytes)
+	for _, column := range columns {
+		column.write(out, opts.top)
+	}
+	return nil
+}
+
+// statsRows returns a function reading the rows of the input one at a
+// time, and io.EOF after the last one
+func statsRows(r io.Reader, opts statsOptions) func() ([]string, error) {
+	if opts.csv {
+		reader := csv.NewReader(r)
+		reader.Comma = opts.delimiter
+		reader.FieldsPerRecord = -1
+		return reader.Read
+	}
+	reader := bufio.NewReader(r)
+	return func() ([]string, error) {
+		line, err := reader.ReadString('\n')
+		if line == "" {
+			if err == nil {
+				err = io.EOF
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if opts.delimiter == 0 {
+			return strings.Fields(line), nil
+		}
+		if line == "" {
+			return nil, nil
+		}
+		return strings.Split(line, string(opts.delimiter)), nil
+	}
+}
+
+// textCounter counts the lines, words, characters and bytes written to it,
+// as wc does
+type textCounter struct {
+	lines, words, chars, bytes int
+	inWord                     bool
+	last                       byte
+}
+
+func (c *textCounter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		c.bytes++
+		if !utf8.RuneStart(b) {
+			continue
+		}
+		c.chars++
+		if b == '\n' {
+			c.lines++
+		}
+		space := b == ' ' || (b >= '\t' && b <= '\r')
+		if !space && !c.inWord {
+			c.words++
+		}
+		c.inWord = !space
+	}
+	if len(p) > 0 {
+		c.last = p[len(p)-1]
+	}
+	return len(p), nil
+}
+
+// lineCount returns the number of lines, counting a last line without a
+// newline
+func (c *textCounter) lineCount() int {
+	if c.bytes > 0 && c.last != '\n' {
+		return c.lines + 1
+	}
+	return c.lines
+}
+
+// columnStats summarizes the values of a column
+type columnStats struct {
+	index                int
+	name                 string
+	count, empty, number int
+	min, max, sum        float64
+	values               map[string]int
+}
+
+func newColumnStats(index int, header []string) *columnStats {
+	return &columnStats{index: index, name: csvField(header, index), values: map[string]int{}}
+}
+
+// add adds a value; a missing field of a short row only counts as empty
+func (c *columnStats) add(value string, present bool) {
+	value = strings.TrimSpace(value)
+	if value == "" || !present {
+		c.empty++
+		return
+	}
+	c.count++
+	c.values[value]++
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+		return
+	}
+	if c.number == 0 || n < c.min {
+		c.min = n
+	}
+	if c.number == 0 || n > c.max {
+		c.max = n
+	}
+	c.number++
+	c.sum += n
+}
+
+// write writes the summary of the column, with its top most frequent
+// values unless every value is different
+func (c *columnStats) write(w io.Writer, top int) {
+	fmt.Fprintf(w, "column %d", c.index+1)
+	if c.name != "" {
+		fmt.Fprintf(w, " (%s)", c.name)
+	}
+	fmt.Fprintf(w, ": %d values, %d distinct", c.count, len(c.values))
+	if c.empty > 0 {
+		fmt.Fprintf(w, ", %d empty", c.empty)
+	}
+	if c.number > 0 {
+		fmt.Fprintf(w, ", %d numeric: min %s, max %s, mean %s, sum %s", c.number,
+			formatStat(c.min, -1), formatStat(c.max, -1), formatStat(c.sum/float64(c.number), 6), formatStat(c.sum, -1))
+	}
+	fmt.Fprintln(w)
+
+	if top == 0 || len(c.values) == c.count {
+		return
+	}
+	values := make([]string, 0, len(c.values))
+	for value := range c.values {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if c.values[values[i]] != c.values[values[j]] {
+			return c.values[values[i]] > c.values[values[j]]
+		}
+		return values[i] < values[j]
+	})
+	var shown []string
+	for _, value := range values[:min(top, len(values))] {
+		shown = append(shown, fmt.Sprintf("%q %d", value, c.values[value]))
+	}
+	fmt.Fprintf(w, "  top: %s\n", strings.Join(shown, ", "))
+}
+
+// formatStat formats a number with at most decimals digits after the
+// point, or as many as it takes if decimals is negative
+func formatStat(n float64, decimals int) string {
+	s := strconv.FormatFloat(n, 'f', decimals, 64)
+	if decimals > 0 && strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
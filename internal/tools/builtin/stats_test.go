@@ -0,0 +1,73 @@
+package builtin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		input  string
+		output string
+		err    string
+	}{
+		{
+			name:  "white space columns",
+			input: "GET 200 12\nPOST 500 3.5\nGET 200 7\n\nGET 404\n",
+			output: "lines 5, words 11, chars 43, bytes 43\n" +
+				"column 1: 4 values, 2 distinct\n  top: \"GET\" 3, \"POST\" 1\n" +
+				"column 2: 4 values, 3 distinct, 4 numeric: min 200, max 500, mean 326, sum 1304\n  top: \"200\" 2, \"404\" 1, \"500\" 1\n" +
+				"column 3: 3 values, 3 distinct, 1 empty, 3 numeric: min 3.5, max 12, mean 7.5, sum 22.5\n",
+		},
+		{
+			name:  "csv with header",
+			args:  []string{"--csv", "-n", "1"},
+			input: "name,price\n\"Smith, J\",1.25\nLée,-2\n\"Smith, J\",n/a\n",
+			output: "lines 4, words 6, chars 49, bytes 50\n" +
+				"column 1 (name): 3 values, 2 distinct\n  top: \"Smith, J\" 2\n" +
+				"column 2 (price): 3 values, 3 distinct, 2 numeric: min -2, max 1.25, mean -0.375, sum -0.75\n",
+		},
+		{
+			name:   "selected columns",
+			args:   []string{"-d", ":", "--header", "-c", "uid"},
+			input:  "user:uid\nroot:0\ndaemon:1\nbin:2",
+			output: "lines 4, words 4, chars 30, bytes 30\ncolumn 2 (uid): 3 values, 3 distinct, 3 numeric: min 0, max 2, mean 1, sum 3\n",
+		},
+		{
+			name:   "empty input",
+			output: "lines 0, words 0, chars 0, bytes 0\n",
+		},
+		{
+			name:  "unknown column",
+			args:  []string{"--csv", "-c", "cost"},
+			input: "name,price\n",
+			err:   "stats: column \"cost\" not found",
+		},
+		{
+			name: "file operand",
+			args: []string{"data.csv"},
+			err:  "stats: data.csv: file operands are not supported; pipe the input instead",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output strings.Builder
+			err := Stats(tt.args, strings.NewReader(tt.input), &output)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != tt.output {
+				t.Errorf("expected output %q, got %q", tt.output, output.String())
+			}
+		})
+	}
+}
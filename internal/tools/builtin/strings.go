@@ -0,0 +1,171 @@
+package builtin
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Strings prints the runs of printable characters in stdin that are at least
+// four characters long, like GNU strings, so a binary input can be looked at
+// without reading its bytes. -n MIN sets the minimum length; -t d|o|x (or -o
+// for octal) prefixes each string with its byte offset; -e s|S|l|b selects
+// 7-bit, 8-bit, or 16-bit little or big endian characters; -w counts all
+// white space as printable and -s sets the separator written after each
+// string. -a is accepted: the whole input is always scanned.
+func Strings(args []string, stdin io.Reader, stdout io.Writer) error {
+	minLength := 4
+	radix, encoding := byte(0), byte('s')
+	allWhitespace := false
+	separator := "\n"
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			for _, operand := range args[i+1:] {
+				if operand != "-" {
+					return fmt.Errorf("strings: %s: file operands are not supported; pipe the input instead", operand)
+				}
+			}
+			i = len(args)
+			continue
+		case arg == "-":
+			continue
+		case !strings.HasPrefix(arg, "-"):
+			return fmt.Errorf("strings: %s: file operands are not supported; pipe the input instead", arg)
+		case arg == "--all":
+			arg = "-a"
+		case arg == "--include-all-whitespace":
+			arg = "-w"
+		case strings.HasPrefix(arg, "--bytes="):
+			arg = "-n" + strings.TrimPrefix(arg, "--bytes=")
+		case strings.HasPrefix(arg, "--radix="):
+			arg = "-t" + strings.TrimPrefix(arg, "--radix=")
+		case strings.HasPrefix(arg, "--encoding="):
+			arg = "-e" + strings.TrimPrefix(arg, "--encoding=")
+		case strings.HasPrefix(arg, "--output-separator="):
+			separator = strings.TrimPrefix(arg, "--output-separator=")
+			continue
+		case strings.HasPrefix(arg, "--"):
+			return fmt.Errorf("strings: %s: invalid option", arg)
+		case arg[1] >= '0' && arg[1] <= '9':
+			arg = "-n" + arg[1:] // -MIN
+		}
+
+		for k := 1; k < len(arg); k++ {
+			switch c := arg[k]; c {
+			case 'a':
+			case 'w':
+				allWhitespace = true
+			case 'o':
+				radix = 'o'
+			case 'n', 't', 'e', 's':
+				value := arg[k+1:]
+				if value == "" {
+					if i+1 == len(args) {
+						return fmt.Errorf("strings: -%c: missing argument", c)
+					}
+					i++
+					value = args[i]
+				}
+				switch c {
+				case 'n':
+					n, err := strconv.Atoi(value)
+					if err != nil || n < 1 {
+						return fmt.Errorf("strings: invalid minimum string length: '%s'", value)
+					}
+					minLength = n
+				case 't':
+					if len(value) != 1 || strings.IndexByte("dox", value[0]) < 0 {
+						return fmt.Errorf("strings: invalid radix: '%s'", value)
+					}
+					radix = value[0]
+				case 'e':
+					if len(value) != 1 || strings.IndexByte("sSlb", value[0]) < 0 {
+						return fmt.Errorf("strings: invalid encoding: '%s'", value)
+					}
+					encoding = value[0]
+				case 's':
+					separator = value
+				}
+				k = len(arg)
+			default:
+				return fmt.Errorf("strings: -%c: invalid option", c)
+			}
+		}
+	}
+
+	width := 1
+	if encoding == 'l' || encoding == 'b' {
+		width = 2
+	}
+	printable := func(c int) bool {
+		switch {
+		case c >= 0x20 && c < 0x7f, c == '\t':
+			return true
+		case c >= 0x80 && c <= 0xff:
+			return encoding == 'S'
+		case c == '\n', c == '\r', c == '\v', c == '\f':
+			return allWhitespace
+		}
+		return false
+	}
+
+	in := bufio.NewReader(stdin)
+	out := bufio.NewWriter(stdout)
+	// A run is held back until it is long enough, then written as it grows,
+	// so long runs do not have to be kept in memory
+	var pending []byte
+	started := false
+	var offset, start int64
+	char := make([]byte, 2)
+	for {
+		if _, err := io.ReadFull(in, char[:width]); err != nil {
+			if err != io.EOF && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return fmt.Errorf("strings: %w", err)
+			}
+			break
+		}
+		c := int(char[0])
+		switch encoding {
+		case 'l':
+			c |= int(char[1]) << 8
+		case 'b':
+			c = c<<8 | int(char[1])
+		}
+		offset += int64(width)
+
+		if !printable(c) {
+			if started {
+				out.WriteString(separator)
+			}
+			pending, started = pending[:0], false
+			continue
+		}
+		if len(pending) == 0 && !started {
+			start = offset - int64(width)
+		}
+		if started {
+			out.WriteByte(byte(c))
+			continue
+		}
+		pending = append(pending, byte(c))
+		if len(pending) < minLength {
+			continue
+		}
+		if radix != 0 {
+			fmt.Fprintf(out, "%7"+string(radix)+" ", start)
+		}
+		if _, err := out.Write(pending); err != nil {
+			return err // The reader has gone
+		}
+		pending, started = pending[:0], true
+	}
+	if started {
+		out.WriteString(separator)
+	}
+	return out.Flush()
+}
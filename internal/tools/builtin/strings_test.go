@@ -0,0 +1,106 @@
+package builtin
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStrings(t *testing.T) {
+	binary := "\x7fELF\x02\x01\x00\x00hello world\x00ab\x00\xffGLIBC_2.2.5\n"
+	tests := []struct {
+		name           string
+		args           []string
+		input          string
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name:           "default minimum length",
+			input:          binary,
+			expectedOutput: "hello world\nGLIBC_2.2.5\n",
+		},
+		{
+			name:           "minimum length",
+			args:           []string{"-n", "3"},
+			input:          binary,
+			expectedOutput: "ELF\nhello world\nGLIBC_2.2.5\n",
+		},
+		{
+			name:           "minimum length as a number",
+			args:           []string{"-2"},
+			input:          binary,
+			expectedOutput: "ELF\nhello world\nab\nGLIBC_2.2.5\n",
+		},
+		{
+			name:           "decimal offsets",
+			args:           []string{"-a", "-t", "d"},
+			input:          binary,
+			expectedOutput: "      8 hello world\n     24 GLIBC_2.2.5\n",
+		},
+		{
+			name:           "hexadecimal offsets",
+			args:           []string{"--radix=x"},
+			input:          binary,
+			expectedOutput: "      8 hello world\n     18 GLIBC_2.2.5\n",
+		},
+		{
+			name:           "octal offsets",
+			args:           []string{"-o", "--bytes=5"},
+			input:          binary,
+			expectedOutput: "     10 hello world\n     30 GLIBC_2.2.5\n",
+		},
+		{
+			name:           "8-bit characters",
+			args:           []string{"-e", "S"},
+			input:          binary,
+			expectedOutput: "hello world\n\xffGLIBC_2.2.5\n",
+		},
+		{
+			name:           "16-bit little endian",
+			args:           []string{"-el"},
+			input:          "\x00\x00W\x00i\x00d\x00e\x00\x00\x00",
+			expectedOutput: "Wide\n",
+		},
+		{
+			name:           "all white space and separator",
+			args:           []string{"-w", "-s", "|"},
+			input:          "line one\nline two\n\x00tail",
+			expectedOutput: "line one\nline two\n|tail|",
+		},
+		{
+			name:          "invalid radix",
+			args:          []string{"-t", "b"},
+			expectedError: "strings: invalid radix: 'b'",
+		},
+		{
+			name:          "invalid length",
+			args:          []string{"-n", "0"},
+			expectedError: "strings: invalid minimum string length: '0'",
+		},
+		{
+			name:          "file operand",
+			args:          []string{"a.out"},
+			expectedError: "strings: a.out: file operands are not supported",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output bytes.Buffer
+			err := Strings(test.args, strings.NewReader(test.input), &output)
+			if test.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Errorf("expected error containing %q, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != test.expectedOutput {
+				t.Errorf("expected output %q, got %q", test.expectedOutput, output.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package builtin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tailSettle is how long tail -f waits for more input before it takes what
+// it has read as the file to show the end of, and starts following
+const tailSettle = 100 * time.Millisecond
+
+// Tail outputs the last lines of its input, 10 by default: -n N or -N sets
+// the number, and -n +N starts at line N instead. -f follows the input:
+// once it pauses, the last lines read so far are written and then whatever
+// is appended, as it arrives, until the input ends or nothing has arrived
+// for --timeout seconds. This lets a pipe from a long-running command be
+// watched without waiting for it to finish.
+func Tail(args []string, stdin io.Reader, stdout io.Writer) error {
+	n := 10
+	fromStart := false
+	follow := false
+	var timeout time.Duration
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-f" || arg == "--follow":
+			follow = true
+			continue
+		case arg == "--timeout" || strings.HasPrefix(arg, "--timeout="):
+			value := strings.TrimPrefix(arg, "--timeout=")
+			if arg == "--timeout" {
+				if i+1 == len(args) {
+					return fmt.Errorf("tail: --timeout: missing argument")
+				}
+				i++
+				value = args[i]
+			}
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds <= 0 {
+				return fmt.Errorf("tail: invalid timeout %q", value)
+			}
+			timeout = time.Duration(seconds * float64(time.Second))
+			continue
+		case strings.HasPrefix(arg, "--lines="):
+			arg = "-n" + strings.TrimPrefix(arg, "--lines=")
+		case len(arg) > 1 && arg[0] == '-' && arg[1] >= '0' && arg[1] <= '9':
+			arg = "-n" + arg[1:]
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			return fmt.Errorf("tail: %s: file operands are not supported; pipe the input instead", arg)
+		}
+
+		for k := 1; k < len(arg); k++ {
+			switch c := arg[k]; c {
+			case 'f':
+				follow = true
+			case 'n':
+				value := arg[k+1:]
+				if value == "" {
+					if i+1 == len(args) {
+						return fmt.Errorf("tail: -n: missing argument")
+					}
+					i++
+					value = args[i]
+				}
+				k = len(arg)
+				fromStart = strings.HasPrefix(value, "+")
+				count, err := strconv.Atoi(strings.TrimPrefix(value, "+"))
+				if err != nil || count < 0 {
+					return fmt.Errorf("tail: invalid number of lines: %q", value)
+				}
+				n = count
+			default:
+				return fmt.Errorf("tail: -%c: invalid option", c)
+			}
+		}
+	}
+
+	if follow {
+		return followTail(stdin, stdout, n, fromStart, timeout)
+	}
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	data = data[tailOffset(data, n, fromStart):]
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	_, err = stdout.Write(data)
+	return err
+}
+
+// followTail is tail -f: it writes the end of what stdin has to offer once
+// it pauses, then everything that follows, until stdin ends or has been
+// idle for timeout, if it is not zero
+func followTail(stdin io.Reader, stdout io.Writer, n int, fromStart bool, timeout time.Duration) error {
+	chunks := make(chan []byte)
+	done := make(chan struct{})
+	defer close(done)
+	var readErr error
+	go func() {
+		defer close(chunks)
+		buf := make([]byte, 32*1024)
+		for {
+			count, err := stdin.Read(buf)
+			if count > 0 {
+				select {
+				case chunks <- bytes.Clone(buf[:count]):
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				if err != io.EOF {
+					readErr = err
+				}
+				return
+			}
+		}
+	}()
+
+	// What arrives before the first pause is the file whose end is shown
+	var data []byte
+	for settled := false; !settled; {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				_, err := stdout.Write(data[tailOffset(data, n, fromStart):])
+				if err == nil {
+					err = readErr
+				}
+				return err
+			}
+			data = append(data, chunk...)
+		case <-time.After(tailSettle):
+			settled = true
+		}
+	}
+	if _, err := stdout.Write(data[tailOffset(data, n, fromStart):]); err != nil {
+		return err
+	}
+
+	var idle <-chan time.Time
+	for {
+		if timeout > 0 {
+			idle = time.After(timeout)
+		}
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				return readErr
+			}
+			if _, err := stdout.Write(chunk); err != nil {
+				return err
+			}
+		case <-idle:
+			return nil
+		}
+	}
+}
+
+// tailOffset returns where the last n lines of data start, or with
+// fromStart where line n starts
+func tailOffset(data []byte, n int, fromStart bool) int {
+	if fromStart {
+		offset := 0
+		for line := 1; line < n; line++ {
+			i := bytes.IndexByte(data[offset:], '\n')
+			if i < 0 {
+				return len(data)
+			}
+			offset += i + 1
+		}
+		return offset
+	}
+	if n == 0 {
+		return len(data)
+	}
+	end := len(data)
+	if end > 0 && data[end-1] == '\n' {
+		end--
+	}
+	for ; n > 0; n-- {
+		i := bytes.LastIndexByte(data[:end], '\n')
+		if i < 0 {
+			return 0
+		}
+		end = i
+	}
+	return end + 1
+}
@@ -0,0 +1,71 @@
+package builtin
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTail(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		input  string
+		output string
+		err    string
+	}{
+		{"default", nil, "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n", "3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n", ""},
+		{"dash number", []string{"-2"}, "a\nb\nc\n", "b\nc\n", ""},
+		{"n option", []string{"-n", "1"}, "a\nb\nc", "c\n", ""},
+		{"from line", []string{"-n", "+2"}, "a\nb\nc\n", "b\nc\n", ""},
+		{"lines long option", []string{"--lines=0"}, "a\nb\n", "", ""},
+		{"more than there are", []string{"-n5"}, "a\nb\n", "a\nb\n", ""},
+		{"invalid count", []string{"-n", "x"}, "", "", `tail: invalid number of lines: "x"`},
+		{"file operand", []string{"log.txt"}, "", "", "tail: log.txt: file operands are not supported; pipe the input instead"},
+		{"follow to the end", []string{"-f", "-n", "2"}, "a\nb\nc\n", "b\nc\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output strings.Builder
+			err := Tail(tt.args, strings.NewReader(tt.input), &output)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != tt.output {
+				t.Errorf("expected output %q, got %q", tt.output, output.String())
+			}
+		})
+	}
+}
+
+func TestTailFollowTimeout(t *testing.T) {
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	go func() {
+		io.WriteString(writer, "old 1\nold 2\nold 3\n")
+		time.Sleep(3 * tailSettle)
+		io.WriteString(writer, "new 1\n")
+		io.WriteString(writer, "new 2\n")
+		// The writer stays open; tail stops when it goes idle
+	}()
+
+	var output strings.Builder
+	start := time.Now()
+	if err := Tail([]string{"-f", "-n", "1", "--timeout", "0.5"}, reader, &output); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "old 3\nnew 1\nnew 2\n"; output.String() != want {
+		t.Errorf("expected output %q, got %q", want, output.String())
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("tail -f took %v to time out", elapsed)
+	}
+}
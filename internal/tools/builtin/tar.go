@@ -0,0 +1,367 @@
+package builtin
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// tarOptions are the parsed options of a tar invocation
+type tarOptions struct {
+	mode            byte // 't' or 'x'
+	verbose         bool
+	archive         string
+	compression     byte // 'z', 'j' or 'J' when forced; detected otherwise
+	toStdout        bool
+	stripComponents int
+	members         []string
+}
+
+// Tar lists (-t) or extracts (-x) the members of a tar archive read from
+// stdin. The archive may be compressed with gzip, bzip2 or xz, which is
+// detected from its first bytes or forced with -z, -j or -J. -v lists
+// members in the long format; -O writes extracted members to stdout.
+// Operands select members by name, directory or glob. Creating archives is
+// not supported.
+func Tar(args []string, stdin io.Reader, stdout io.Writer) error {
+	return RunTar(args, stdin, stdout, nil)
+}
+
+// RunTar is Tar with access to files, so -f can name an archive and -x can
+// extract members into them; without them, tar can only extract members to
+// stdout with -O. Leading slashes are removed from member names
+// and members whose names contain .. are skipped.
+func RunTar(args []string, stdin io.Reader, stdout io.Writer, files Files) error {
+	opts, err := parseTarArgs(args)
+	if err != nil {
+		return err
+	}
+
+	input := stdin
+	if opts.archive != "" && opts.archive != "-" {
+		if files == nil {
+			return fmt.Errorf("tar: %s: file operands are not supported; pipe the input instead", opts.archive)
+		}
+		file, err := files.Open(opts.archive)
+		if err != nil {
+			return fmt.Errorf("tar: %s: %w", opts.archive, err)
+		}
+		defer file.Close()
+		input = file
+	}
+	if opts.mode == 'x' && !opts.toStdout && files == nil {
+		return fmt.Errorf("tar: extracting to files is not supported here; use -O to write members to stdout")
+	}
+
+	reader, err := tarDecompress(input, opts.compression)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	out := bufio.NewWriter(stdout)
+	err = runTar(tar.NewReader(reader), opts, out, files)
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+// parseTarArgs parses tar's options. Short options may be combined, and as
+// in traditional tar the first argument may omit the dash, as in tzf; f
+// takes the rest of its argument or the next argument.
+func parseTarArgs(args []string) (tarOptions, error) {
+	var opts tarOptions
+	setMode := func(mode byte) error {
+		if opts.mode != 0 && opts.mode != mode {
+			return fmt.Errorf("tar: you may not specify more than one of -t and -x")
+		}
+		opts.mode = mode
+		return nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if i == 0 && arg != "" && !strings.HasPrefix(arg, "-") {
+			arg = "-" + arg
+		}
+		switch {
+		case arg == "--":
+			opts.members = append(opts.members, args[i+1:]...)
+			i = len(args)
+			continue
+		case arg == "--list":
+			if err := setMode('t'); err != nil {
+				return opts, err
+			}
+			continue
+		case arg == "--extract" || arg == "--get":
+			if err := setMode('x'); err != nil {
+				return opts, err
+			}
+			continue
+		case arg == "--verbose":
+			opts.verbose = true
+			continue
+		case arg == "--to-stdout":
+			opts.toStdout = true
+			continue
+		case arg == "--gzip" || arg == "--gunzip":
+			opts.compression = 'z'
+			continue
+		case arg == "--bzip2":
+			opts.compression = 'j'
+			continue
+		case arg == "--xz":
+			opts.compression = 'J'
+			continue
+		case arg == "--auto-compress" || arg == "--wildcards":
+			continue
+		case strings.HasPrefix(arg, "--file="):
+			opts.archive = strings.TrimPrefix(arg, "--file=")
+			continue
+		case strings.HasPrefix(arg, "--strip-components="):
+			value := strings.TrimPrefix(arg, "--strip-components=")
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return opts, fmt.Errorf("tar: invalid number of components: %q", value)
+			}
+			opts.stripComponents = n
+			continue
+		case strings.HasPrefix(arg, "--"):
+			return opts, fmt.Errorf("tar: %s: invalid option", arg)
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			opts.members = append(opts.members, arg)
+			continue
+		}
+
+		for j := 1; j < len(arg); j++ {
+			switch c := arg[j]; c {
+			case 't', 'x':
+				if err := setMode(c); err != nil {
+					return opts, err
+				}
+			case 'c', 'r', 'u', 'A':
+				return opts, fmt.Errorf("tar: -%c: creating or changing archives is not supported", c)
+			case 'v':
+				opts.verbose = true
+			case 'O':
+				opts.toStdout = true
+			case 'z', 'j', 'J':
+				opts.compression = c
+			case 'a':
+			case 'f':
+				value := arg[j+1:]
+				if value == "" {
+					if i+1 == len(args) {
+						return opts, fmt.Errorf("tar: -f: missing archive name")
+					}
+					i++
+					value = args[i]
+				}
+				opts.archive = value
+				j = len(arg)
+			default:
+				return opts, fmt.Errorf("tar: -%c: invalid option", c)
+			}
+		}
+	}
+	if opts.mode == 0 {
+		return opts, fmt.Errorf("tar: you must specify one of -t or -x")
+	}
+	return opts, nil
+}
+
+// tarDecompress returns the archive data, decompressing it as forced or as
+// its first bytes show
+func tarDecompress(input io.Reader, compression byte) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(input)
+	if compression == 0 {
+		magic, _ := buffered.Peek(len(xzMagic))
+		switch {
+		case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+			compression = 'z'
+		case bytes.HasPrefix(magic, []byte("BZh")):
+			compression = 'j'
+		case bytes.Equal(magic, xzMagic):
+			compression = 'J'
+		}
+	}
+
+	switch compression {
+	case 'z':
+		reader, err := gzip.NewReader(buffered)
+		if err != nil {
+			if errors.Is(err, gzip.ErrHeader) {
+				return nil, fmt.Errorf("tar: archive is not in gzip format")
+			}
+			return nil, compressError("tar", err)
+		}
+		return reader, nil
+	case 'j':
+		if magic, err := buffered.Peek(3); err != nil || string(magic) != "BZh" {
+			return nil, fmt.Errorf("tar: archive is not in bzip2 format")
+		}
+		return io.NopCloser(bzip2.NewReader(buffered)), nil
+	case 'J':
+		// The xz decoder writes its output, so it runs into a pipe
+		reader, writer := io.Pipe()
+		go func() {
+			writer.CloseWithError(decodeXZ(buffered, writer))
+		}()
+		return reader, nil
+	}
+	return io.NopCloser(buffered), nil
+}
+
+// runTar lists or extracts the selected members, reporting operands that
+// matched nothing once the whole archive has been read
+func runTar(archive *tar.Reader, opts tarOptions, out *bufio.Writer, files Files) error {
+	found := make([]bool, len(opts.members))
+	var firstErr error
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if errors.Is(err, tar.ErrHeader) {
+				return fmt.Errorf("tar: this does not look like a tar archive")
+			}
+			return compressError("tar", err)
+		}
+
+		if !tarSelected(header.Name, opts.members, found) {
+			continue
+		}
+		if opts.mode == 't' {
+			if opts.verbose {
+				out.WriteString(tarLongListing(header))
+			} else {
+				out.WriteString(header.Name)
+			}
+			out.WriteByte('\n')
+			continue
+		}
+
+		if err := extractTarMember(archive, header, opts, out, files); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	for i, member := range opts.members {
+		if !found[i] && firstErr == nil {
+			firstErr = fmt.Errorf("tar: %s: not found in archive", member)
+		}
+	}
+	return firstErr
+}
+
+// tarSelected reports whether a member is selected by the operands: its
+// name, a directory above it, or a glob matching it. All members are
+// selected when there are none.
+func tarSelected(name string, members []string, found []bool) bool {
+	if len(members) == 0 {
+		return true
+	}
+	name = strings.TrimSuffix(name, "/")
+	selected := false
+	for i, member := range members {
+		member = strings.TrimSuffix(member, "/")
+		matched, _ := path.Match(member, name)
+		if matched || name == member || strings.HasPrefix(name, member+"/") {
+			found[i] = true
+			selected = true
+		}
+	}
+	return selected
+}
+
+// tarLongListing formats a member as tar -tv does
+func tarLongListing(header *tar.Header) string {
+	kind := byte('-')
+	switch header.Typeflag {
+	case tar.TypeDir:
+		kind = 'd'
+	case tar.TypeSymlink:
+		kind = 'l'
+	case tar.TypeLink:
+		kind = 'h'
+	case tar.TypeChar:
+		kind = 'c'
+	case tar.TypeBlock:
+		kind = 'b'
+	case tar.TypeFifo:
+		kind = 'p'
+	}
+	owner, group := header.Uname, header.Gname
+	if owner == "" {
+		owner = strconv.Itoa(header.Uid)
+	}
+	if group == "" {
+		group = strconv.Itoa(header.Gid)
+	}
+	name := header.Name
+	switch header.Typeflag {
+	case tar.TypeSymlink:
+		name += " -> " + header.Linkname
+	case tar.TypeLink:
+		name += " link to " + header.Linkname
+	}
+	perm := os.FileMode(header.Mode).Perm().String()[1:]
+	return fmt.Sprintf("%c%s %s/%s %8d %s %s", kind, perm, owner, group, header.Size,
+		header.ModTime.UTC().Format("2006-01-02 15:04"), name)
+}
+
+// extractTarMember writes a regular file member to stdout or to a file;
+// other members are skipped, as files have no directories or links
+func extractTarMember(archive *tar.Reader, header *tar.Header, opts tarOptions, out *bufio.Writer, files Files) error {
+	if header.Typeflag != tar.TypeReg {
+		return nil
+	}
+	if opts.toStdout {
+		if _, err := io.Copy(out, archive); err != nil {
+			return compressError("tar", err)
+		}
+		return nil
+	}
+
+	parts := strings.Split(strings.TrimLeft(header.Name, "/"), "/")
+	for _, part := range parts {
+		if part == ".." {
+			return fmt.Errorf("tar: %s: member name contains '..'", header.Name)
+		}
+	}
+	if opts.stripComponents >= len(parts) {
+		return nil
+	}
+	name := strings.Join(parts[opts.stripComponents:], "/")
+
+	if opts.verbose {
+		out.WriteString(name)
+		out.WriteByte('\n')
+	}
+	file, err := files.Create(name)
+	if err != nil {
+		return fmt.Errorf("tar: %s: %w", name, err)
+	}
+	if _, err := io.Copy(file, archive); err != nil {
+		file.Close()
+		return compressError("tar", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("tar: %s: %w", name, err)
+	}
+	return nil
+}
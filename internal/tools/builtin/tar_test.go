@@ -0,0 +1,176 @@
+package builtin
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryFiles is a Files over a map
+type memoryFiles map[string]*bytes.Buffer
+
+func (m memoryFiles) Open(name string) (io.ReadCloser, error) {
+	data, ok := m[name]
+	if !ok {
+		return nil, fmt.Errorf("file not found")
+	}
+	return io.NopCloser(bytes.NewReader(data.Bytes())), nil
+}
+
+func (m memoryFiles) Create(name string) (io.WriteCloser, error) {
+	m[name] = &bytes.Buffer{}
+	return nopWriteCloser{m[name]}, nil
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestTar(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)
+	var plain bytes.Buffer
+	archive := tar.NewWriter(&plain)
+	for _, header := range []*tar.Header{
+		{Name: "proj/", Typeflag: tar.TypeDir, Mode: 0755, Uname: "dev", Gname: "dev", ModTime: modTime},
+		{Name: "proj/README", Typeflag: tar.TypeReg, Mode: 0644, Size: 6, Uname: "dev", Gname: "dev", ModTime: modTime},
+		{Name: "proj/src/main.go", Typeflag: tar.TypeReg, Mode: 0644, Size: 13, Uid: 1000, Gid: 1000, ModTime: modTime},
+		{Name: "proj/latest", Typeflag: tar.TypeSymlink, Linkname: "README", Mode: 0777, ModTime: modTime},
+		{Name: "../evil", Typeflag: tar.TypeReg, Mode: 0644, Size: 4, ModTime: modTime},
+	} {
+		if err := archive.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		switch header.Name {
+		case "proj/README":
+			archive.Write([]byte("hello\n"))
+		case "proj/src/main.go":
+			archive.Write([]byte("package main\n"))
+		case "../evil":
+			archive.Write([]byte("bad\n"))
+		}
+	}
+	archive.Close()
+
+	var gzipped bytes.Buffer
+	writer := gzip.NewWriter(&gzipped)
+	writer.Write(plain.Bytes())
+	writer.Close()
+
+	tests := []struct {
+		name           string
+		args           []string
+		input          string
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name:           "list",
+			args:           []string{"-tf", "-"},
+			input:          plain.String(),
+			expectedOutput: "proj/\nproj/README\nproj/src/main.go\nproj/latest\n../evil\n",
+		},
+		{
+			name:           "list gzip detected",
+			args:           []string{"-t"},
+			input:          gzipped.String(),
+			expectedOutput: "proj/\nproj/README\nproj/src/main.go\nproj/latest\n../evil\n",
+		},
+		{
+			name:  "verbose list of selected members",
+			args:  []string{"tvz", "proj/src", "proj/latest"},
+			input: gzipped.String(),
+			expectedOutput: "-rw-r--r-- 1000/1000       13 2024-01-02 03:04 proj/src/main.go\n" +
+				"lrwxrwxrwx 0/0        0 2024-01-02 03:04 proj/latest -> README\n",
+		},
+		{
+			name:           "list glob",
+			args:           []string{"-t", "proj/*/*.go"},
+			input:          plain.String(),
+			expectedOutput: "proj/src/main.go\n",
+		},
+		{
+			name:           "extract to stdout",
+			args:           []string{"-xzOf", "-", "proj/README", "proj/src/main.go"},
+			input:          gzipped.String(),
+			expectedOutput: "hello\npackage main\n",
+		},
+		{
+			name:          "member not found",
+			args:          []string{"-t", "missing"},
+			input:         plain.String(),
+			expectedError: "tar: missing: not found in archive",
+		},
+		{
+			name:          "forced gzip on plain archive",
+			args:          []string{"-tz"},
+			input:         plain.String(),
+			expectedError: "tar: archive is not in gzip format",
+		},
+		{
+			name:          "not a tar archive",
+			args:          []string{"-t"},
+			input:         strings.Repeat("not a tar archive\n", 40),
+			expectedError: "does not look like a tar archive",
+		},
+		{
+			name:          "extract without files",
+			args:          []string{"-x"},
+			input:         plain.String(),
+			expectedError: "use -O to write members to stdout",
+		},
+		{
+			name:          "archive operand without files",
+			args:          []string{"-tf", "a.tar"},
+			expectedError: "tar: a.tar: file operands are not supported",
+		},
+		{
+			name:          "create",
+			args:          []string{"-cf", "-"},
+			expectedError: "tar: -c: creating or changing archives is not supported",
+		},
+		{
+			name:          "no mode",
+			args:          []string{"-f", "-"},
+			expectedError: "you must specify one of -t or -x",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output bytes.Buffer
+			err := Tar(test.args, strings.NewReader(test.input), &output)
+			if test.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != test.expectedOutput {
+				t.Errorf("expected output %q, got %q", test.expectedOutput, output.String())
+			}
+		})
+	}
+
+	t.Run("extract into files", func(t *testing.T) {
+		files := memoryFiles{"a.tar.gz": &gzipped}
+		var output bytes.Buffer
+		err := RunTar([]string{"-xvf", "a.tar.gz", "--strip-components=1"}, nil, &output, files)
+		if err == nil || !strings.Contains(err.Error(), "../evil: member name contains '..'") {
+			t.Errorf("expected an error for ../evil, got %v", err)
+		}
+		if output.String() != "README\nsrc/main.go\n" {
+			t.Errorf("unexpected verbose output %q", output.String())
+		}
+		if len(files) != 3 || files["README"].String() != "hello\n" || files["src/main.go"].String() != "package main\n" {
+			t.Errorf("unexpected files %v", files)
+		}
+	})
+}
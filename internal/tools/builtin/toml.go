@@ -0,0 +1,640 @@
+package builtin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Toml2json converts a TOML document on stdin to a JSON object. Tables,
+// arrays of tables, dotted keys, inline tables and all string forms are
+// supported; dates and times become strings, as JSON has no such type.
+func Toml2json(args []string, stdin io.Reader, stdout io.Writer) error {
+	compact, sortKeys, err := parseToJSONFlags("toml2json", args)
+	if err != nil {
+		return err
+	}
+	input, err := io.ReadAll(stdin)
+	if err != nil {
+		return fmt.Errorf("toml2json: failed to read input: %w", err)
+	}
+	doc, err := parseTOML(string(input))
+	if err != nil {
+		return fmt.Errorf("toml2json: %w", err)
+	}
+	_, err = fmt.Fprintln(stdout, jqEncode(doc, !compact, sortKeys))
+	return err
+}
+
+// Json2toml converts a JSON object on stdin to TOML. Nested objects become
+// tables and arrays of objects become arrays of tables; null has no TOML
+// form and is an error.
+func Json2toml(args []string, stdin io.Reader, stdout io.Writer) error {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") && arg != "-" {
+			return fmt.Errorf("json2toml: %s: invalid option", arg)
+		}
+		return fmt.Errorf("json2toml: %s: file operands are not supported; pipe the input instead", arg)
+	}
+	dec := json.NewDecoder(bufio.NewReader(stdin))
+	dec.UseNumber()
+	v, err := jqDecode(dec)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("json2toml: invalid JSON input: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("json2toml: expected a single JSON object")
+	}
+	obj, ok := v.(*jqObject)
+	if !ok {
+		return fmt.Errorf("json2toml: the top level must be an object, not %s", jqTypeName(v))
+	}
+	var b strings.Builder
+	if err := writeTOMLTable(&b, obj, nil); err != nil {
+		return fmt.Errorf("json2toml: %w", err)
+	}
+	_, err = io.WriteString(stdout, b.String())
+	return err
+}
+
+// tomlParser reads a TOML document from a string
+type tomlParser struct {
+	text string
+	pos  int
+	root *jqObject
+	// defined records the tables that have a [header] or were assigned
+	// whole, so that defining them again is an error
+	defined map[*jqObject]bool
+}
+
+var (
+	tomlBareKey  = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	tomlDateTime = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}([Tt ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?)?|\d{2}:\d{2}(:\d{2}(\.\d+)?)?)([Zz]|[-+]\d{2}:\d{2})?`)
+)
+
+func parseTOML(text string) (*jqObject, error) {
+	p := &tomlParser{text: strings.ReplaceAll(text, "\r\n", "\n"), root: newJqObject(), defined: make(map[*jqObject]bool)}
+	current := p.root
+	for {
+		p.skipSpace(true)
+		if p.pos == len(p.text) {
+			return p.root, nil
+		}
+		var err error
+		switch {
+		case strings.HasPrefix(p.text[p.pos:], "[["):
+			current, err = p.parseArrayTable()
+		case p.text[p.pos] == '[':
+			current, err = p.parseTableHeader()
+		default:
+			err = p.parseKeyValue(current)
+		}
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if err := p.endOfLine(); err != nil {
+			return nil, p.errorf("%v", err)
+		}
+	}
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", strings.Count(p.text[:p.pos], "\n")+1, fmt.Sprintf(format, args...))
+}
+
+// skipSpace skips blanks and, when newlines is set, comments and line breaks
+func (p *tomlParser) skipSpace(newlines bool) {
+	for p.pos < len(p.text) {
+		switch c := p.text[p.pos]; {
+		case c == ' ' || c == '\t':
+			p.pos++
+		case newlines && c == '\n':
+			p.pos++
+		case newlines && c == '#':
+			p.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) skipComment() {
+	for p.pos < len(p.text) && p.text[p.pos] != '\n' {
+		p.pos++
+	}
+}
+
+// endOfLine checks that only a comment follows on the current line
+func (p *tomlParser) endOfLine() error {
+	p.skipSpace(false)
+	if p.pos < len(p.text) && p.text[p.pos] == '#' {
+		p.skipComment()
+	}
+	if p.pos < len(p.text) && p.text[p.pos] != '\n' {
+		return fmt.Errorf("unexpected %q at the end of the line", tomlRestOfLine(p.text[p.pos:]))
+	}
+	return nil
+}
+
+func tomlRestOfLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// parseKey reads a possibly dotted key
+func (p *tomlParser) parseKey() ([]string, error) {
+	var parts []string
+	for {
+		p.skipSpace(false)
+		if p.pos == len(p.text) {
+			return nil, fmt.Errorf("expected a key")
+		}
+		switch p.text[p.pos] {
+		case '"', '\'':
+			s, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, s)
+		default:
+			start := p.pos
+			for p.pos < len(p.text) && tomlBareKey.MatchString(p.text[p.pos:p.pos+1]) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, fmt.Errorf("invalid key %q", tomlRestOfLine(p.text[p.pos:]))
+			}
+			parts = append(parts, p.text[start:p.pos])
+		}
+		p.skipSpace(false)
+		if p.pos == len(p.text) || p.text[p.pos] != '.' {
+			return parts, nil
+		}
+		p.pos++
+	}
+}
+
+// descend walks to the table named by path, creating missing tables; an
+// array of tables resolves to its last element
+func (p *tomlParser) descend(table *jqObject, path []string) (*jqObject, error) {
+	for _, name := range path {
+		v, ok := table.get(name)
+		if !ok {
+			next := newJqObject()
+			table.set(name, next)
+			table = next
+			continue
+		}
+		switch t := v.(type) {
+		case *jqObject:
+			table = t
+		case []interface{}:
+			last, isTable := interface{}(nil), false
+			if len(t) > 0 {
+				last = t[len(t)-1]
+				_, isTable = last.(*jqObject)
+			}
+			if !isTable {
+				return nil, fmt.Errorf("key %q is already defined as an array", name)
+			}
+			table = last.(*jqObject)
+		default:
+			return nil, fmt.Errorf("key %q is already defined as a %s", name, jqTypeName(v))
+		}
+	}
+	return table, nil
+}
+
+func (p *tomlParser) parseTableHeader() (*jqObject, error) {
+	p.pos++
+	path, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos == len(p.text) || p.text[p.pos] != ']' {
+		return nil, fmt.Errorf("expected ] after the table name")
+	}
+	p.pos++
+	table, err := p.descend(p.root, path)
+	if err != nil {
+		return nil, err
+	}
+	if p.defined[table] {
+		return nil, fmt.Errorf("table [%s] is defined twice", strings.Join(path, "."))
+	}
+	p.defined[table] = true
+	return table, nil
+}
+
+func (p *tomlParser) parseArrayTable() (*jqObject, error) {
+	p.pos += 2
+	path, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(p.text[p.pos:], "]]") {
+		return nil, fmt.Errorf("expected ]] after the table name")
+	}
+	p.pos += 2
+	parent, err := p.descend(p.root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	name := path[len(path)-1]
+	table := newJqObject()
+	switch existing, ok := parent.get(name); {
+	case !ok:
+		parent.set(name, []interface{}{table})
+	default:
+		arr, isArray := existing.([]interface{})
+		if !isArray {
+			return nil, fmt.Errorf("key %q is already defined as a %s", name, jqTypeName(existing))
+		}
+		parent.set(name, append(arr, table))
+	}
+	return table, nil
+}
+
+// parseKeyValue reads "key = value" into table
+func (p *tomlParser) parseKeyValue(table *jqObject) error {
+	path, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if p.pos == len(p.text) || p.text[p.pos] != '=' {
+		return fmt.Errorf("expected = after the key")
+	}
+	p.pos++
+	p.skipSpace(false)
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+	parent, err := p.descend(table, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	name := path[len(path)-1]
+	if _, exists := parent.get(name); exists {
+		return fmt.Errorf("key %q is defined twice", strings.Join(path, "."))
+	}
+	if obj, ok := value.(*jqObject); ok {
+		p.defined[obj] = true
+	}
+	parent.set(name, value)
+	return nil
+}
+
+func (p *tomlParser) parseValue() (interface{}, error) {
+	if p.pos == len(p.text) {
+		return nil, fmt.Errorf("expected a value")
+	}
+	rest := p.text[p.pos:]
+	switch c := rest[0]; {
+	case c == '"' || c == '\'':
+		return p.parseString()
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return p.parseInlineTable()
+	case strings.HasPrefix(rest, "true") && !tomlBareKey.MatchString(rest[4:min(5, len(rest))]):
+		p.pos += 4
+		return true, nil
+	case strings.HasPrefix(rest, "false") && !tomlBareKey.MatchString(rest[5:min(6, len(rest))]):
+		p.pos += 5
+		return false, nil
+	}
+	if m := tomlDateTime.FindString(rest); m != "" {
+		// a space between date and time only counts when a time follows
+		m = strings.TrimRight(m, " ")
+		p.pos += len(m)
+		return m, nil
+	}
+	end := 0
+	for end < len(rest) && strings.IndexByte(" \t\n#,]}", rest[end]) < 0 {
+		end++
+	}
+	token := rest[:end]
+	n, err := tomlNumber(token)
+	if err != nil {
+		return nil, err
+	}
+	p.pos += end
+	return n, nil
+}
+
+// tomlNumber parses an integer or float, with _ separators and the 0x, 0o
+// and 0b prefixes
+func tomlNumber(token string) (float64, error) {
+	if token == "" {
+		return 0, fmt.Errorf("expected a value")
+	}
+	digits := strings.ReplaceAll(token, "_", "")
+	switch strings.TrimLeft(digits, "+-") {
+	case "inf":
+		if strings.HasPrefix(digits, "-") {
+			return math.Inf(-1), nil
+		}
+		return math.Inf(1), nil
+	case "nan":
+		return math.NaN(), nil
+	}
+	if len(digits) > 2 && digits[0] == '0' && strings.IndexByte("xob", digits[1]) >= 0 {
+		n, err := strconv.ParseUint(digits[2:], map[byte]int{'x': 16, 'o': 8, 'b': 2}[digits[1]], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q", token)
+		}
+		return float64(n), nil
+	}
+	if strings.HasPrefix(token, "_") || strings.HasSuffix(token, "_") || strings.Contains(token, "__") {
+		return 0, fmt.Errorf("invalid number %q", token)
+	}
+	n, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", token)
+	}
+	return n, nil
+}
+
+func (p *tomlParser) parseArray() (interface{}, error) {
+	p.pos++
+	arr := []interface{}{}
+	for {
+		p.skipSpace(true)
+		if p.pos == len(p.text) {
+			return nil, fmt.Errorf("unterminated array")
+		}
+		if p.text[p.pos] == ']' {
+			p.pos++
+			return arr, nil
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+		p.skipSpace(true)
+		if p.pos < len(p.text) && p.text[p.pos] == ',' {
+			p.pos++
+		} else if p.pos == len(p.text) || p.text[p.pos] != ']' {
+			return nil, fmt.Errorf("expected , or ] in array")
+		}
+	}
+}
+
+func (p *tomlParser) parseInlineTable() (interface{}, error) {
+	p.pos++
+	table := newJqObject()
+	p.skipSpace(false)
+	if p.pos < len(p.text) && p.text[p.pos] == '}' {
+		p.pos++
+		return table, nil
+	}
+	for {
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+		p.skipSpace(false)
+		if p.pos == len(p.text) {
+			return nil, fmt.Errorf("unterminated inline table")
+		}
+		switch p.text[p.pos] {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return table, nil
+		default:
+			return nil, fmt.Errorf("expected , or } in inline table")
+		}
+	}
+}
+
+// parseString reads a basic, literal or multi-line string
+func (p *tomlParser) parseString() (string, error) {
+	quote := p.text[p.pos]
+	multi := strings.HasPrefix(p.text[p.pos:], strings.Repeat(string(quote), 3))
+	if multi {
+		p.pos += 3
+		// a newline right after the opening delimiter is trimmed
+		if p.pos < len(p.text) && p.text[p.pos] == '\n' {
+			p.pos++
+		}
+	} else {
+		p.pos++
+	}
+	var b strings.Builder
+	for p.pos < len(p.text) {
+		c := p.text[p.pos]
+		switch {
+		case c == quote && !multi:
+			p.pos++
+			return b.String(), nil
+		case c == quote && strings.HasPrefix(p.text[p.pos:], strings.Repeat(string(quote), 3)):
+			// up to two quotes may sit right before the closing delimiter
+			extra := 0
+			for extra < 2 && p.pos+3+extra < len(p.text) && p.text[p.pos+3+extra] == quote {
+				extra++
+			}
+			b.WriteString(strings.Repeat(string(quote), extra))
+			p.pos += 3 + extra
+			return b.String(), nil
+		case c == '\n' && !multi:
+			return "", fmt.Errorf("unterminated string")
+		case c == '\\' && quote == '"':
+			p.pos++
+			if p.pos == len(p.text) {
+				return "", fmt.Errorf("unterminated string")
+			}
+			esc := p.text[p.pos]
+			p.pos++
+			switch esc {
+			case 'b':
+				b.WriteByte('\b')
+			case 't':
+				b.WriteByte('\t')
+			case 'n':
+				b.WriteByte('\n')
+			case 'f':
+				b.WriteByte('\f')
+			case 'r':
+				b.WriteByte('\r')
+			case 'e':
+				b.WriteByte(0x1b)
+			case '"', '\\':
+				b.WriteByte(esc)
+			case 'u', 'U':
+				size := 4
+				if esc == 'U' {
+					size = 8
+				}
+				if p.pos+size > len(p.text) {
+					return "", fmt.Errorf("invalid escape \\%c", esc)
+				}
+				code, err := strconv.ParseUint(p.text[p.pos:p.pos+size], 16, 32)
+				if err != nil {
+					return "", fmt.Errorf("invalid escape \\%c%s", esc, p.text[p.pos:p.pos+size])
+				}
+				b.WriteRune(rune(code))
+				p.pos += size
+			case ' ', '\t', '\n':
+				// a line-ending backslash trims the following white space
+				if !multi {
+					return "", fmt.Errorf("invalid escape \\%c", esc)
+				}
+				rest := strings.TrimLeft(p.text[p.pos-1:], " \t")
+				if !strings.HasPrefix(rest, "\n") {
+					return "", fmt.Errorf("invalid escape \\%c", esc)
+				}
+				p.pos = len(p.text) - len(strings.TrimLeft(rest, " \t\n"))
+			default:
+				return "", fmt.Errorf("invalid escape \\%c", esc)
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+// writeTOMLTable writes the plain values of a table, then its sub-tables
+// and arrays of tables under headers named by path
+func writeTOMLTable(b *strings.Builder, table *jqObject, path []string) error {
+	var tables []string
+	for _, key := range table.keys {
+		v := table.values[key]
+		if tomlIsTable(v) || tomlIsTableArray(v) {
+			tables = append(tables, key)
+			continue
+		}
+		s, err := tomlInline(v, append(path, key))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(b, "%s = %s\n", tomlKey(key), s)
+	}
+	for _, key := range tables {
+		sub := append(append([]string(nil), path...), key)
+		name := make([]string, len(sub))
+		for i, part := range sub {
+			name[i] = tomlKey(part)
+		}
+		if obj, ok := table.values[key].(*jqObject); ok {
+			// a table holding only other tables needs no header of its own
+			if !tomlOnlyTables(obj) {
+				if b.Len() > 0 {
+					b.WriteByte('\n')
+				}
+				fmt.Fprintf(b, "[%s]\n", strings.Join(name, "."))
+			}
+			if err := writeTOMLTable(b, obj, sub); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, elem := range table.values[key].([]interface{}) {
+			if b.Len() > 0 {
+				b.WriteByte('\n')
+			}
+			fmt.Fprintf(b, "[[%s]]\n", strings.Join(name, "."))
+			if err := writeTOMLTable(b, elem.(*jqObject), sub); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func tomlIsTable(v interface{}) bool {
+	_, ok := v.(*jqObject)
+	return ok
+}
+
+// tomlOnlyTables reports whether a non-empty table holds nothing but
+// tables and arrays of tables
+func tomlOnlyTables(table *jqObject) bool {
+	for _, v := range table.values {
+		if !tomlIsTable(v) && !tomlIsTableArray(v) {
+			return false
+		}
+	}
+	return len(table.keys) > 0
+}
+
+// tomlIsTableArray reports whether v is a non-empty array of objects only
+func tomlIsTableArray(v interface{}) bool {
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) == 0 {
+		return false
+	}
+	for _, elem := range arr {
+		if !tomlIsTable(elem) {
+			return false
+		}
+	}
+	return true
+}
+
+// tomlInline formats a value for the right-hand side of key = value
+func tomlInline(v interface{}, path []string) (string, error) {
+	switch t := v.(type) {
+	case nil:
+		return "", fmt.Errorf("%s: null cannot be represented in TOML", strings.Join(path, "."))
+	case bool:
+		return strconv.FormatBool(t), nil
+	case float64:
+		switch {
+		case math.IsNaN(t):
+			return "nan", nil
+		case math.IsInf(t, 1):
+			return "inf", nil
+		case math.IsInf(t, -1):
+			return "-inf", nil
+		}
+		return jqFormatNumber(t), nil
+	case string:
+		return jqQuote(t), nil
+	case []interface{}:
+		parts := make([]string, len(t))
+		for i, elem := range t {
+			s, err := tomlInline(elem, append(path, strconv.Itoa(i)))
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return "[" + strings.Join(parts, ", ") + "]", nil
+	case *jqObject:
+		parts := make([]string, len(t.keys))
+		for i, key := range t.keys {
+			s, err := tomlInline(t.values[key], append(path, key))
+			if err != nil {
+				return "", err
+			}
+			parts[i] = tomlKey(key) + " = " + s
+		}
+		if len(parts) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(parts, ", ") + " }", nil
+	}
+	return "", fmt.Errorf("%s: unsupported value", strings.Join(path, "."))
+}
+
+func tomlKey(key string) string {
+	if tomlBareKey.MatchString(key) {
+		return key
+	}
+	return jqQuote(key)
+}
@@ -0,0 +1,84 @@
+package builtin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToml2json(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output string
+		err    string
+	}{
+		{"key values", "title = \"demo\" # comment\nport = 8_080\nhex = 0xff\nratio = 1.5e3\non = true\n", `{"title":"demo","port":8080,"hex":255,"ratio":1500,"on":true}`, ""},
+		{"tables and dotted keys", "a.b = 1\n[server]\nhost = 'localhost'\n[server.tls]\nenabled = false\n", `{"a":{"b":1},"server":{"host":"localhost","tls":{"enabled":false}}}`, ""},
+		{"arrays of tables", "[[bin]]\nname = \"x\"\n[[bin]]\nname = \"y\"\n[bin.opts]\nv = 1\n", `{"bin":[{"name":"x"},{"name":"y","opts":{"v":1}}]}`, ""},
+		{"arrays and inline tables", "deps = [\n  \"a\",\n  \"b\", # trailing\n]\npoint = { x = 1, y = { z = 2 } }\n", `{"deps":["a","b"],"point":{"x":1,"y":{"z":2}}}`, ""},
+		{"strings", "basic = \"tab\\tq\\\"\"\nlit = 'C:\\dir'\nml = \"\"\"\none \\\n  two\"\"\"\nraw = '''\nline\\n'''\n", `{"basic":"tab\tq\"","lit":"C:\\dir","ml":"one two","raw":"line\\n"}`, ""},
+		{"dates stay strings", "d = 1979-05-27\ndt = 1979-05-27T07:32:00Z\nt = 07:32:00\n", `{"d":"1979-05-27","dt":"1979-05-27T07:32:00Z","t":"07:32:00"}`, ""},
+		{"duplicate key", "a = 1\na = 2\n", "", `toml2json: line 2: key "a" is defined twice`},
+		{"duplicate table", "[a]\n[a]\n", "", "toml2json: line 2: table [a] is defined twice"},
+		{"trailing garbage", "a = 1 2\n", "", `toml2json: line 1: unexpected "2" at the end of the line`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output strings.Builder
+			err := Toml2json([]string{"-c"}, strings.NewReader(tt.input), &output)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != tt.output+"\n" {
+				t.Errorf("expected output %q, got %q", tt.output+"\n", output.String())
+			}
+		})
+	}
+}
+
+func TestJson2toml(t *testing.T) {
+	input := `{"title":"demo","tags":["a","b"],"mixed":[1,{"k":"v"}],"server":{"host":"h","tls":{"on":true}},"db":{"primary":{"port":5432}},"bin":[{"name":"x"},{"name":"y"}],"odd key":1}`
+	want := `title = "demo"
+tags = ["a", "b"]
+mixed = [1, { k = "v" }]
+"odd key" = 1
+
+[server]
+host = "h"
+
+[server.tls]
+on = true
+
+[db.primary]
+port = 5432
+
+[[bin]]
+name = "x"
+
+[[bin]]
+name = "y"
+`
+	var output strings.Builder
+	if err := Json2toml(nil, strings.NewReader(input), &output); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output.String() != want {
+		t.Fatalf("expected output %q, got %q", want, output.String())
+	}
+
+	for _, tt := range []struct{ input, err string }{
+		{`{"a":{"b":null}}`, "json2toml: a.b: null cannot be represented in TOML"},
+		{`[1]`, "json2toml: the top level must be an object, not array"},
+	} {
+		if err := Json2toml(nil, strings.NewReader(tt.input), &strings.Builder{}); err == nil || err.Error() != tt.err {
+			t.Errorf("Json2toml(%s) error = %v, want %q", tt.input, err, tt.err)
+		}
+	}
+}
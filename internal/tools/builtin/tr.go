@@ -0,0 +1,352 @@
+package builtin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tr translates, deletes or squeezes characters, following GNU tr. Sets
+// accept backslash escapes, ranges such as a-z, the classes [:alpha:],
+// [:digit:], [:lower:], [:upper:], [:space:] and the rest, [=c=], and in
+// SET2 the repeats [c*n] and [c*]. -d deletes SET1, -s squeezes runs of the
+// last set given, -c complements SET1 and -t truncates SET1 to the length of
+// SET2. Input is processed as a stream, so newlines can be translated too.
+func Tr(args []string, stdin io.Reader, stdout io.Writer) error {
+	var del, squeeze, complement, truncate bool
+	var operands []string
+	for i, arg := range args {
+		if arg == "--" {
+			operands = append(operands, args[i+1:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' || len(operands) > 0 {
+			operands = append(operands, arg)
+			continue
+		}
+		switch arg {
+		case "--delete":
+			del = true
+			continue
+		case "--squeeze-repeats":
+			squeeze = true
+			continue
+		case "--complement":
+			complement = true
+			continue
+		case "--truncate-set1":
+			truncate = true
+			continue
+		}
+		for _, c := range arg[1:] {
+			switch c {
+			case 'd':
+				del = true
+			case 's':
+				squeeze = true
+			case 'c', 'C':
+				complement = true
+			case 't':
+				truncate = true
+			default:
+				return fmt.Errorf("tr: invalid option -- '%c'", c)
+			}
+		}
+	}
+
+	translate := !del && len(operands) >= 2
+	switch {
+	case len(operands) == 0:
+		return fmt.Errorf("tr: missing operand")
+	case del && squeeze && len(operands) < 2:
+		return fmt.Errorf("tr: missing operand after %q; two strings must be given when both deleting and squeezing", operands[0])
+	case !del && !squeeze && len(operands) < 2:
+		return fmt.Errorf("tr: missing operand after %q; two strings must be given when translating", operands[0])
+	case len(operands) > 2 || (del && !squeeze && len(operands) > 1):
+		return fmt.Errorf("tr: extra operand %q", operands[len(operands)-1])
+	}
+
+	set1, err := parseTrSet(operands[0], false, 0)
+	if err != nil {
+		return err
+	}
+	var set2 *trSet
+	if len(operands) == 2 {
+		if set2, err = parseTrSet(operands[1], translate, len(set1.runes)); err != nil {
+			return err
+		}
+	}
+
+	var mapping map[rune]rune
+	var complementTo rune
+	caseMap := func(r rune) (rune, bool) { return r, false }
+	if translate {
+		if len(set2.runes) == 0 && !truncate {
+			return fmt.Errorf("tr: when not truncating set1, string2 must be non-empty")
+		}
+		if complement {
+			complementTo = set2.runes[len(set2.runes)-1]
+		} else {
+			mapping = make(map[rune]rune)
+			for i, r := range set1.runes {
+				switch {
+				case i < len(set2.runes):
+					mapping[r] = set2.runes[i]
+				case !truncate:
+					mapping[r] = set2.runes[len(set2.runes)-1]
+				}
+			}
+			// [:lower:] and [:upper:] at the same position convert case
+			// beyond ASCII as well
+			for pos, class := range set1.classAt {
+				switch {
+				case class == "lower" && set2.classAt[pos] == "upper":
+					caseMap = func(r rune) (rune, bool) { return unicode.ToUpper(r), unicode.IsLower(r) }
+				case class == "upper" && set2.classAt[pos] == "lower":
+					caseMap = func(r rune) (rune, bool) { return unicode.ToLower(r), unicode.IsUpper(r) }
+				}
+			}
+		}
+	}
+	inSet1 := func(r rune) bool { return set1.contains(r) != complement }
+	squeezeSet := set1
+	if set2 != nil {
+		squeezeSet = set2
+	}
+
+	in := bufio.NewReader(stdin)
+	out := bufio.NewWriter(stdout)
+	last, haveLast := rune(0), false
+	for {
+		r, size, err := in.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			out.Flush()
+			return err
+		}
+		if r == utf8.RuneError && size == 1 {
+			// pass bytes that are not UTF-8 through untouched
+			in.UnreadRune()
+			b, _ := in.ReadByte()
+			out.WriteByte(b)
+			haveLast = false
+			continue
+		}
+		switch {
+		case del:
+			if inSet1(r) {
+				continue
+			}
+		case translate && complement:
+			if inSet1(r) {
+				r = complementTo
+			}
+		case translate:
+			if to, ok := mapping[r]; ok {
+				r = to
+			} else if to, ok := caseMap(r); ok {
+				r = to
+			}
+		}
+		if squeeze && haveLast && r == last && (squeezeSet.contains(r) != (complement && set2 == nil)) {
+			continue
+		}
+		last, haveLast = r, true
+		out.WriteRune(r)
+		// write line by line, as the commands downstream may be following
+		if r == '\n' {
+			if err := out.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return out.Flush()
+}
+
+// trSet is a parsed tr character set
+type trSet struct {
+	runes   []rune         // the characters in order, classes expanded over ASCII
+	classes []string       // the classes named in the set
+	classAt map[int]string // the class starting at each position of runes
+	members map[rune]bool
+}
+
+// trClasses lists the characters of each class in the C locale, in order
+var trClasses = map[string]func(rune) bool{
+	"alnum":  func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) },
+	"alpha":  unicode.IsLetter,
+	"blank":  func(r rune) bool { return r == ' ' || r == '\t' },
+	"cntrl":  unicode.IsControl,
+	"digit":  func(r rune) bool { return r >= '0' && r <= '9' },
+	"graph":  func(r rune) bool { return unicode.IsGraphic(r) && !unicode.IsSpace(r) },
+	"lower":  unicode.IsLower,
+	"print":  unicode.IsPrint,
+	"punct":  func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSymbol(r) },
+	"space":  unicode.IsSpace,
+	"upper":  unicode.IsUpper,
+	"xdigit": func(r rune) bool { return strings.ContainsRune("0123456789ABCDEFabcdef", r) },
+}
+
+// contains reports whether r is in the set; classes match beyond ASCII
+func (s *trSet) contains(r rune) bool {
+	if s.members[r] {
+		return true
+	}
+	for _, class := range s.classes {
+		if trClasses[class](r) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrSet parses a set. In SET2 of a translation, [c*n] repeats c and
+// [c*] fills the set to set1Len characters.
+func parseTrSet(spec string, isSet2 bool, set1Len int) (*trSet, error) {
+	s := &trSet{classAt: make(map[int]string), members: make(map[rune]bool)}
+	fill := -1 // where [c*] puts its characters
+	var fillRune rune
+	for i := 0; i < len(spec); {
+		rest := spec[i:]
+		if strings.HasPrefix(rest, "[:") {
+			if end := strings.Index(rest[2:], ":]"); end >= 0 {
+				name := rest[2 : 2+end]
+				if _, ok := trClasses[name]; !ok {
+					return nil, fmt.Errorf("tr: invalid character class '%s'", name)
+				}
+				if isSet2 && name != "lower" && name != "upper" {
+					return nil, fmt.Errorf("tr: when translating, the only character classes that may appear in string2 are 'upper' and 'lower'")
+				}
+				s.classAt[len(s.runes)] = name
+				s.classes = append(s.classes, name)
+				for c := rune(0); c < 128; c++ {
+					if trClasses[name](c) {
+						s.runes = append(s.runes, c)
+					}
+				}
+				i += end + 4
+				continue
+			}
+		}
+		if strings.HasPrefix(rest, "[=") {
+			if end := strings.Index(rest[2:], "=]"); end > 0 {
+				j := i + 2
+				if c := trNextChar(spec, &j); j == i+2+end {
+					s.runes = append(s.runes, c)
+					i = j + 2
+					continue
+				}
+			}
+		}
+		if rest[0] == '[' && len(rest) > 1 {
+			j := i + 1
+			c := trNextChar(spec, &j)
+			if j < len(spec) && spec[j] == '*' {
+				if end := strings.IndexByte(spec[j:], ']'); end >= 0 {
+					count := spec[j+1 : j+end]
+					if !isSet2 {
+						return nil, fmt.Errorf("tr: the [c*] repeat construct may not appear in string1")
+					}
+					if count == "" {
+						if fill >= 0 {
+							return nil, fmt.Errorf("tr: only one [c*] repeat construct may appear in string2")
+						}
+						fill, fillRune = len(s.runes), c
+					} else {
+						base := 10
+						if strings.HasPrefix(count, "0") {
+							base = 8
+						}
+						n, err := strconv.ParseInt(count, base, 32)
+						if err != nil {
+							return nil, fmt.Errorf("tr: invalid repeat count '%s' in [c*n] construct", count)
+						}
+						if n == 0 {
+							fill, fillRune = len(s.runes), c
+						}
+						for ; n > 0; n-- {
+							s.runes = append(s.runes, c)
+						}
+					}
+					i = j + end + 1
+					continue
+				}
+			}
+		}
+
+		start := i
+		c := trNextChar(spec, &i)
+		if i+1 < len(spec) && spec[i] == '-' {
+			j := i + 1
+			hi := trNextChar(spec, &j)
+			if hi < c {
+				return nil, fmt.Errorf("tr: range-endpoints of '%s' are in reverse collating sequence order", spec[start:j])
+			}
+			for r := c; r <= hi; r++ {
+				s.runes = append(s.runes, r)
+			}
+			i = j
+			continue
+		}
+		s.runes = append(s.runes, c)
+	}
+	if fill >= 0 {
+		n := max(set1Len-len(s.runes), 0)
+		s.runes = append(s.runes[:fill], append([]rune(strings.Repeat(string(fillRune), n)), s.runes[fill:]...)...)
+		shifted := make(map[int]string, len(s.classAt))
+		for pos, class := range s.classAt {
+			if pos >= fill {
+				pos += n
+			}
+			shifted[pos] = class
+		}
+		s.classAt = shifted
+	}
+	for _, r := range s.runes {
+		s.members[r] = true
+	}
+	return s, nil
+}
+
+// trNextChar reads one character of a set at *i, decoding backslash escapes
+func trNextChar(spec string, i *int) rune {
+	if spec[*i] != '\\' || *i+1 == len(spec) {
+		r, size := utf8.DecodeRuneInString(spec[*i:])
+		*i += size
+		return r
+	}
+	*i += 2
+	switch c := spec[*i-1]; c {
+	case 'a':
+		return '\a'
+	case 'b':
+		return '\b'
+	case 'f':
+		return '\f'
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'v':
+		return '\v'
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		start := *i - 1
+		for *i < len(spec) && *i-start < 3 && spec[*i] >= '0' && spec[*i] <= '7' {
+			*i++
+		}
+		n, _ := strconv.ParseUint(spec[start:*i], 8, 32)
+		return rune(n)
+	default:
+		r, size := utf8.DecodeRuneInString(spec[*i-1:])
+		*i += size - 1
+		return r
+	}
+}
@@ -0,0 +1,55 @@
+package builtin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTr(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		input  string
+		output string
+		err    string
+	}{
+		{"classes to upper case", []string{"[:lower:]", "[:upper:]"}, "Hello, World\n", "HELLO, WORLD\n", ""},
+		{"classes beyond ASCII", []string{"[:upper:]", "[:lower:]"}, "ÄÖ Ab\n", "äö ab\n", ""},
+		{"ranges", []string{"a-z", "A-Z"}, "abc xyz\n", "ABC XYZ\n", ""},
+		{"newlines are characters", []string{"\\n", " "}, "a\nb\nc\n", "a b c ", ""},
+		{"short set2 is padded", []string{"abc", "x"}, "aabbcc\n", "xxxxxx\n", ""},
+		{"truncate set1", []string{"-t", "abc", "xy"}, "abc\n", "xyc\n", ""},
+		{"repeat fills set2", []string{"a-f", "xy[z*]"}, "abcdef\n", "xyzzzz\n", ""},
+		{"delete digits", []string{"-d", "[:digit:]"}, "a1b22c333\n", "abc\n", ""},
+		{"delete carriage returns", []string{"-d", "\\r"}, "a\r\nb\r\n", "a\nb\n", ""},
+		{"keep only alphanumerics", []string{"-cd", "[:alnum:]\\n"}, "a-1, b_2!\n", "a1b2\n", ""},
+		{"squeeze spaces", []string{"-s", " "}, "a   b  c\n", "a b c\n", ""},
+		{"words one per line", []string{"-cs", "[:alpha:]", "\\n"}, "the cat, the hat.\n", "the\ncat\nthe\nhat\n", ""},
+		{"delete and squeeze", []string{"-ds", "[:digit:]", "[:space:]"}, "a1  2b\n\n", "a b\n", ""},
+		{"octal escapes", []string{"\\101-\\103", "x"}, "ABCD\n", "xxxD\n", ""},
+		{"reverse range", []string{"z-a", "x"}, "", "", "tr: range-endpoints of 'z-a' are in reverse collating sequence order"},
+		{"unknown class", []string{"-d", "[:vowel:]"}, "", "", "tr: invalid character class 'vowel'"},
+		{"class in set2", []string{"a", "[:digit:]"}, "", "", "tr: when translating, the only character classes that may appear in string2 are 'upper' and 'lower'"},
+		{"missing set2", []string{"abc"}, "", "", `tr: missing operand after "abc"; two strings must be given when translating`},
+		{"invalid option", []string{"-x", "a", "b"}, "", "", "tr: invalid option -- 'x'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output strings.Builder
+			err := Tr(tt.args, strings.NewReader(tt.input), &output)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != tt.output {
+				t.Errorf("expected output %q, got %q", tt.output, output.String())
+			}
+		})
+	}
+}
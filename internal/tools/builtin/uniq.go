@@ -0,0 +1,233 @@
+package builtin
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// uniqOptions are the parsed options of a uniq invocation
+type uniqOptions struct {
+	count      bool // -c: prefix lines with the number of occurrences
+	repeated   bool // -d: only print lines that occur more than once
+	unique     bool // -u: only print lines that occur once
+	ignoreCase bool // -i
+	skipFields int  // -f N
+	skipChars  int  // -s N
+	checkChars int  // -w N; 0 compares the rest of the line
+}
+
+// Uniq collapses runs of adjacent lines that compare equal, printing the
+// first of each run, following GNU uniq: -c counts the lines of each run,
+// -d prints only repeated lines and -u only lines that are not, -i ignores
+// case, -f and -s skip fields and characters before comparing and -w
+// compares at most that many characters.
+func Uniq(args []string, stdin io.Reader, stdout io.Writer) error {
+	opts, err := parseUniqArgs(args)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(stdout)
+	w := &uniqWriter{opts: opts, out: out}
+	scanner := bufio.NewScanner(stdin)
+	for scanner.Scan() {
+		w.add(scanner.Text(), 1)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	w.flush()
+	return out.Flush()
+}
+
+// SortUniq runs "sort SORTARGS | uniq UNIQARGS" in one pass. It counts the
+// distinct lines of the input, sorts only those and hands them to uniq with
+// their counts, so the sorted copy of the whole input is never built. Equal
+// lines end up adjacent after any sort except a stable (-s) or unique (-u)
+// one; those run as the two commands would.
+func SortUniq(sortArgs, uniqArgs []string, stdin io.Reader, stdout io.Writer) error {
+	sortOpts, err := parseSortArgs(sortArgs)
+	if err != nil {
+		return err
+	}
+	uniqOpts, err := parseUniqArgs(uniqArgs)
+	if err != nil {
+		return err
+	}
+	if sortOpts.stable || sortOpts.unique {
+		var sorted bytes.Buffer
+		if err := Sort(sortArgs, stdin, &sorted); err != nil {
+			return err
+		}
+		return Uniq(uniqArgs, &sorted, stdout)
+	}
+
+	counts := make(map[string]int)
+	var distinct []string
+	scanner := bufio.NewScanner(stdin)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if counts[line] == 0 {
+			distinct = append(distinct, line)
+		}
+		counts[line]++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(stdout)
+	w := &uniqWriter{opts: uniqOpts, out: out}
+	for _, line := range sortOpts.sortLines(distinct) {
+		w.add(line, counts[line])
+	}
+	w.flush()
+	return out.Flush()
+}
+
+// parseUniqArgs parses uniq's options. Short options may be combined, as in
+// -cd, and -f, -s and -w take their value attached or as the next argument.
+func parseUniqArgs(args []string) (uniqOptions, error) {
+	var opts uniqOptions
+	setNumber := func(option string, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("uniq: %s: invalid number: %q", option, value)
+		}
+		switch option {
+		case "-f", "--skip-fields":
+			opts.skipFields = n
+		case "-s", "--skip-chars":
+			opts.skipChars = n
+		default:
+			opts.checkChars = n
+		}
+		return nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if strings.HasPrefix(arg, "--") {
+			name, value, hasValue := strings.Cut(arg, "=")
+			switch name {
+			case "--count":
+				opts.count = true
+			case "--repeated":
+				opts.repeated = true
+			case "--unique":
+				opts.unique = true
+			case "--ignore-case":
+				opts.ignoreCase = true
+			case "--skip-fields", "--skip-chars", "--check-chars":
+				if !hasValue {
+					if i+1 == len(args) {
+						return opts, fmt.Errorf("uniq: %s: missing argument", name)
+					}
+					i++
+					value = args[i]
+				}
+				if err := setNumber(name, value); err != nil {
+					return opts, err
+				}
+			default:
+				return opts, fmt.Errorf("uniq: %s: invalid option", arg)
+			}
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return opts, fmt.Errorf("uniq: %s: file operands are not supported; pipe the input instead", arg)
+		}
+
+		for j := 1; j < len(arg); j++ {
+			switch c := arg[j]; c {
+			case 'c':
+				opts.count = true
+			case 'd':
+				opts.repeated = true
+			case 'u':
+				opts.unique = true
+			case 'i':
+				opts.ignoreCase = true
+			case 'f', 's', 'w':
+				value := arg[j+1:]
+				if value == "" {
+					if i+1 == len(args) {
+						return opts, fmt.Errorf("uniq: -%c: missing argument", c)
+					}
+					i++
+					value = args[i]
+				}
+				if err := setNumber("-"+string(c), value); err != nil {
+					return opts, err
+				}
+				j = len(arg)
+			default:
+				return opts, fmt.Errorf("uniq: -%c: invalid option", c)
+			}
+		}
+	}
+	return opts, nil
+}
+
+// key returns the part of line that uniq compares
+func (opts uniqOptions) key(line string) string {
+	for n := 0; n < opts.skipFields; n++ {
+		line = strings.TrimLeftFunc(line, unicode.IsSpace)
+		if end := strings.IndexFunc(line, unicode.IsSpace); end >= 0 {
+			line = line[end:]
+		} else {
+			line = ""
+		}
+	}
+	runes := []rune(line)
+	runes = runes[min(opts.skipChars, len(runes)):]
+	if opts.checkChars > 0 && opts.checkChars < len(runes) {
+		runes = runes[:opts.checkChars]
+	}
+	if opts.ignoreCase {
+		return strings.ToLower(string(runes))
+	}
+	return string(runes)
+}
+
+// uniqWriter collapses adjacent lines with equal keys and writes each run
+// as the options ask
+type uniqWriter struct {
+	opts  uniqOptions
+	out   *bufio.Writer
+	first string // the first line of the current run
+	key   string
+	count int // lines in the current run; 0 before the first line
+}
+
+// add appends n copies of line to the input
+func (w *uniqWriter) add(line string, n int) {
+	key := w.opts.key(line)
+	if w.count > 0 && key == w.key {
+		w.count += n
+		return
+	}
+	w.flush()
+	w.first, w.key, w.count = line, key, n
+}
+
+// flush writes the current run, if any
+func (w *uniqWriter) flush() {
+	if w.count == 0 {
+		return
+	}
+	if (w.opts.repeated && w.count == 1) || (w.opts.unique && w.count > 1) {
+		w.count = 0
+		return
+	}
+	if w.opts.count {
+		fmt.Fprintf(w.out, "%4d ", w.count)
+	}
+	w.out.WriteString(w.first)
+	w.out.WriteByte('\n')
+	w.count = 0
+}
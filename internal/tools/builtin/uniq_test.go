@@ -0,0 +1,83 @@
+package builtin
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestUniq(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		input  string
+		output string
+		err    string
+	}{
+		{"adjacent runs", nil, "a\na\nb\na\n", "a\nb\na\n", ""},
+		{"count", []string{"-c"}, "a\na\nb\n", "   2 a\n   1 b\n", ""},
+		{"repeated with count", []string{"-cd"}, "a\na\nb\nc\nc\nc\n", "   2 a\n   3 c\n", ""},
+		{"unique only", []string{"-u"}, "a\na\nb\n", "b\n", ""},
+		{"repeated and unique", []string{"-d", "-u"}, "a\na\nb\n", "", ""},
+		{"ignore case keeps the first", []string{"-i", "-c"}, "Apple\napple\nAPPLE\npear\n", "   3 Apple\n   1 pear\n", ""},
+		{"skip fields", []string{"-f", "1"}, "1 err\n2 err\n3 ok\n", "1 err\n3 ok\n", ""},
+		{"skip chars and check chars", []string{"-s2", "-w", "3"}, "a:abcX\nb:abcY\nc:abd\n", "a:abcX\nc:abd\n", ""},
+		{"long options", []string{"--count", "--skip-fields=1"}, "x a\ny a\n", "   2 x a\n", ""},
+		{"invalid option", []string{"-z"}, "", "", "uniq: -z: invalid option"},
+		{"invalid number", []string{"-f", "x"}, "", "", `uniq: -f: invalid number: "x"`},
+		{"file operand", []string{"in.txt"}, "", "", "uniq: in.txt: file operands are not supported; pipe the input instead"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output strings.Builder
+			err := Uniq(tt.args, strings.NewReader(tt.input), &output)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != tt.output {
+				t.Errorf("expected output %q, got %q", tt.output, output.String())
+			}
+		})
+	}
+}
+
+// TestSortUniqMatchesPipeline checks that the fused command prints what
+// sort piped into uniq prints
+func TestSortUniqMatchesPipeline(t *testing.T) {
+	input := "b 2\nA 1\na 1\nb 2\nc 10\nB 2\na 1\nc 9\n\nb 2\n"
+	for _, tt := range []struct{ sortArgs, uniqArgs []string }{
+		{nil, nil},
+		{nil, []string{"-c"}},
+		{[]string{"-r"}, []string{"-d"}},
+		{[]string{"-f"}, []string{"-ci"}},
+		{[]string{"-k2n"}, []string{"-c", "-f1"}},
+		{[]string{"-s", "-k2n"}, []string{"-c"}},
+		{[]string{"-u", "-k1,1"}, []string{"-u"}},
+	} {
+		var sorted bytes.Buffer
+		var want, got strings.Builder
+		if err := Sort(tt.sortArgs, strings.NewReader(input), &sorted); err != nil {
+			t.Fatalf("Sort %v failed: %v", tt.sortArgs, err)
+		}
+		if err := Uniq(tt.uniqArgs, &sorted, &want); err != nil {
+			t.Fatalf("Uniq %v failed: %v", tt.uniqArgs, err)
+		}
+		if err := SortUniq(tt.sortArgs, tt.uniqArgs, strings.NewReader(input), &got); err != nil {
+			t.Fatalf("SortUniq %v %v failed: %v", tt.sortArgs, tt.uniqArgs, err)
+		}
+		if got.String() != want.String() {
+			t.Errorf("SortUniq %v %v = %q, want %q", tt.sortArgs, tt.uniqArgs, got.String(), want.String())
+		}
+	}
+
+	if err := SortUniq([]string{"-q"}, nil, strings.NewReader(""), &strings.Builder{}); err == nil || err.Error() != "sort: -q: invalid option" {
+		t.Errorf("SortUniq with a bad sort option = %v", err)
+	}
+}
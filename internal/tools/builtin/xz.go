@@ -0,0 +1,731 @@
+package builtin
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+)
+
+// This file holds an xz decoder, since the standard library has none. It
+// supports the .xz container with the LZMA2 filter, which is what xz
+// writes by default, and CRC32, CRC64 and SHA-256 checks. Decoded data is
+// written out as it is produced; the dictionary is grown as needed up to
+// the size the stream declares.
+
+var (
+	xzMagic       = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	xzFooterMagic = []byte{'Y', 'Z'}
+	errXZData     = errors.New("compressed data is corrupt")
+	crc64Table    = crc64.MakeTable(crc64.ECMA)
+)
+
+// xzInput reads the compressed stream, counting bytes and optionally
+// feeding them to a CRC32
+type xzInput struct {
+	r     *bufio.Reader
+	count int64
+	crc   hash.Hash32
+}
+
+func (in *xzInput) ReadByte() (byte, error) {
+	b, err := in.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	in.count++
+	if in.crc != nil {
+		in.crc.Write([]byte{b})
+	}
+	return b, nil
+}
+
+func (in *xzInput) readFull(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(in.r, buf); err != nil {
+		return nil, err
+	}
+	in.count += int64(n)
+	if in.crc != nil {
+		in.crc.Write(buf)
+	}
+	return buf, nil
+}
+
+// readVLI reads a variable-length integer of up to 9 bytes
+func (in *xzInput) readVLI() (uint64, error) {
+	var value uint64
+	for i := 0; i < 9; i++ {
+		b, err := in.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		value |= uint64(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			if b == 0 && i > 0 {
+				return 0, errXZData
+			}
+			return value, nil
+		}
+	}
+	return 0, errXZData
+}
+
+// decodeXZ decompresses one or more concatenated xz streams
+func decodeXZ(r io.Reader, w io.Writer) error {
+	in := &xzInput{r: bufio.NewReader(r)}
+	out := bufio.NewWriter(w)
+	for first := true; ; first = false {
+		if !first {
+			// Stream padding is a multiple of four zero bytes
+			for {
+				peek, err := in.r.Peek(4)
+				if len(peek) == 0 && err == io.EOF {
+					return out.Flush()
+				}
+				if err != nil || !bytes.Equal(peek, []byte{0, 0, 0, 0}) {
+					break
+				}
+				in.readFull(4)
+			}
+		}
+		if err := decodeXZStream(in, out); err != nil {
+			return err
+		}
+	}
+}
+
+// decodeXZStream decodes a stream: header, blocks, index and footer
+func decodeXZStream(in *xzInput, out *bufio.Writer) error {
+	header, err := in.readFull(12)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(header[:6], xzMagic) {
+		return fmt.Errorf("not in xz format")
+	}
+	if header[6] != 0 || header[7] > 0x0f || crc32.ChecksumIEEE(header[6:8]) != binary.LittleEndian.Uint32(header[8:]) {
+		return fmt.Errorf("unsupported or corrupt stream header")
+	}
+	checkType := header[7]
+
+	var blocks int
+	for {
+		in.crc = crc32.NewIEEE()
+		size, err := in.ReadByte()
+		if err != nil {
+			return err
+		}
+		if size == 0 {
+			break // The index follows
+		}
+		if err := decodeXZBlock(in, out, int(size+1)*4, checkType); err != nil {
+			return err
+		}
+		blocks++
+	}
+
+	// Index: the record count and a record per block, which are not
+	// needed to decode, then padding and a CRC32
+	records, err := in.readVLI()
+	if err != nil {
+		return err
+	}
+	if records != uint64(blocks) {
+		return errXZData
+	}
+	for i := uint64(0); i < 2*records; i++ {
+		if _, err := in.readVLI(); err != nil {
+			return err
+		}
+	}
+	for in.count%4 != 0 {
+		if b, err := in.ReadByte(); err != nil {
+			return err
+		} else if b != 0 {
+			return errXZData
+		}
+	}
+	sum := in.crc.Sum32()
+	in.crc = nil
+	crc, err := in.readFull(4)
+	if err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint32(crc) != sum {
+		return errXZData
+	}
+
+	footer, err := in.readFull(12)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(footer[10:], xzFooterMagic) || !bytes.Equal(footer[8:10], header[6:8]) ||
+		crc32.ChecksumIEEE(footer[4:10]) != binary.LittleEndian.Uint32(footer[:4]) {
+		return errXZData
+	}
+	return out.Flush()
+}
+
+// decodeXZBlock decodes a block whose header size byte has been read
+func decodeXZBlock(in *xzInput, out *bufio.Writer, headerSize int, checkType byte) error {
+	blockStart := in.count - 1
+	rest, err := in.readFull(headerSize - 5)
+	if err != nil {
+		return err
+	}
+	sum := in.crc.Sum32()
+	in.crc = nil
+	crc, err := in.readFull(4)
+	if err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint32(crc) != sum {
+		return errXZData
+	}
+
+	// Parse the header fields from the bytes already read
+	fields := &xzInput{r: bufio.NewReader(bytes.NewReader(rest))}
+	flags, err := fields.ReadByte()
+	if err != nil {
+		return errXZData
+	}
+	if flags&0x3c != 0 {
+		return fmt.Errorf("unsupported block options")
+	}
+	if flags&0x40 != 0 {
+		if _, err := fields.readVLI(); err != nil {
+			return errXZData
+		}
+	}
+	if flags&0x80 != 0 {
+		if _, err := fields.readVLI(); err != nil {
+			return errXZData
+		}
+	}
+	filters := int(flags&0x03) + 1
+	var dictSize uint32
+	for i := 0; i < filters; i++ {
+		id, err := fields.readVLI()
+		if err != nil {
+			return errXZData
+		}
+		propsSize, err := fields.readVLI()
+		if err != nil || propsSize > 1<<10 {
+			return errXZData
+		}
+		props, err := fields.readFull(int(propsSize))
+		if err != nil {
+			return errXZData
+		}
+		if id != 0x21 || i != filters-1 {
+			return fmt.Errorf("unsupported filter 0x%x; only LZMA2 is supported", id)
+		}
+		if len(props) != 1 || props[0] > 40 {
+			return errXZData
+		}
+		if props[0] == 40 {
+			dictSize = 0xffffffff
+		} else {
+			dictSize = (2 | uint32(props[0])&1) << (props[0]/2 + 11)
+		}
+	}
+
+	check := newXZCheck(checkType)
+	var target io.Writer = out
+	if check != nil {
+		target = io.MultiWriter(out, check)
+	}
+	if err := decodeLZMA2(in, target, dictSize); err != nil {
+		return err
+	}
+
+	// Block padding, then the check
+	for (in.count-blockStart)%4 != 0 {
+		if b, err := in.ReadByte(); err != nil {
+			return err
+		} else if b != 0 {
+			return errXZData
+		}
+	}
+	stored, err := in.readFull(xzCheckSize(checkType))
+	if err != nil {
+		return err
+	}
+	if check != nil && !bytes.Equal(stored, xzCheckSum(checkType, check)) {
+		return fmt.Errorf("the integrity check failed")
+	}
+	return nil
+}
+
+// xzCheckSize returns the size of a block's check field
+func xzCheckSize(checkType byte) int {
+	if checkType == 0 {
+		return 0
+	}
+	return 4 << ((checkType - 1) / 3)
+}
+
+// newXZCheck returns a hash for a check type, or nil for none or an
+// unsupported type, which is skipped
+func newXZCheck(checkType byte) hash.Hash {
+	switch checkType {
+	case 0x01:
+		return crc32.NewIEEE()
+	case 0x04:
+		return crc64.New(crc64Table)
+	case 0x0a:
+		return sha256.New()
+	}
+	return nil
+}
+
+// xzCheckSum returns a check as it is stored: CRC32 and CRC64 little-endian
+func xzCheckSum(checkType byte, check hash.Hash) []byte {
+	sum := check.Sum(nil)
+	if checkType == 0x01 || checkType == 0x04 {
+		for i, j := 0, len(sum)-1; i < j; i, j = i+1, j-1 {
+			sum[i], sum[j] = sum[j], sum[i]
+		}
+	}
+	return sum
+}
+
+// lzmaWindow is the LZMA dictionary. It grows up to its size and then
+// wraps; the bytes put in it are written out every half window.
+type lzmaWindow struct {
+	buf     []byte
+	size    int
+	pos     int   // Where the next byte goes
+	full    bool  // The buffer has wrapped, so all of it is history
+	len     int64 // Bytes since the last dictionary reset
+	pending int   // Bytes not yet written out
+	out     io.Writer
+	err     error
+}
+
+func (w *lzmaWindow) reset() {
+	w.flush()
+	w.buf, w.pos, w.full, w.len = w.buf[:0], 0, false, 0
+}
+
+func (w *lzmaWindow) put(b byte) {
+	if !w.full {
+		w.buf = append(w.buf, b)
+		w.pos = len(w.buf)
+		if w.pos == w.size {
+			w.full, w.pos = true, 0
+		}
+	} else {
+		w.buf[w.pos] = b
+		w.pos++
+		if w.pos == w.size {
+			w.pos = 0
+		}
+	}
+	w.len++
+	w.pending++
+	if w.pending >= w.size/2 {
+		w.flush()
+	}
+}
+
+// get returns the byte dist bytes back, 1 being the last byte
+func (w *lzmaWindow) get(dist int) byte {
+	i := w.pos - dist
+	if i < 0 {
+		i += len(w.buf)
+	}
+	return w.buf[i]
+}
+
+// has reports whether dist bytes of history are available
+func (w *lzmaWindow) has(dist uint32) bool {
+	return int64(dist) <= w.len && int(dist) <= w.size
+}
+
+// flush writes out the pending bytes
+func (w *lzmaWindow) flush() {
+	n := w.pending
+	w.pending = 0
+	if w.err != nil || n == 0 {
+		return
+	}
+	end := w.pos
+	if end == 0 && w.full {
+		end = len(w.buf)
+	}
+	start := end - n
+	if start < 0 {
+		// The bytes wrapped around the end of the buffer
+		if _, w.err = w.out.Write(w.buf[len(w.buf)+start:]); w.err != nil {
+			return
+		}
+		start = 0
+	}
+	_, w.err = w.out.Write(w.buf[start:end])
+}
+
+// rangeDecoder is the LZMA range decoder over one LZMA2 chunk
+type rangeDecoder struct {
+	data  []byte
+	pos   int
+	rng   uint32
+	code  uint32
+	error bool
+}
+
+func newRangeDecoder(data []byte) (*rangeDecoder, error) {
+	if len(data) < 5 || data[0] != 0 {
+		return nil, errXZData
+	}
+	return &rangeDecoder{data: data, pos: 5, rng: 0xffffffff, code: binary.BigEndian.Uint32(data[1:5])}, nil
+}
+
+func (rc *rangeDecoder) normalize() {
+	if rc.rng < 1<<24 {
+		if rc.pos == len(rc.data) {
+			rc.error = true
+			return
+		}
+		rc.rng <<= 8
+		rc.code = rc.code<<8 | uint32(rc.data[rc.pos])
+		rc.pos++
+	}
+}
+
+func (rc *rangeDecoder) bit(prob *uint16) uint32 {
+	rc.normalize()
+	bound := (rc.rng >> 11) * uint32(*prob)
+	if rc.code < bound {
+		rc.rng = bound
+		*prob += (2048 - *prob) >> 5
+		return 0
+	}
+	rc.rng -= bound
+	rc.code -= bound
+	*prob -= *prob >> 5
+	return 1
+}
+
+func (rc *rangeDecoder) bitTree(probs []uint16, bits int) uint32 {
+	m := uint32(1)
+	for i := 0; i < bits; i++ {
+		m = m<<1 | rc.bit(&probs[m])
+	}
+	return m - 1<<bits
+}
+
+func (rc *rangeDecoder) bitTreeReverse(probs []uint16, bits int) uint32 {
+	m, result := uint32(1), uint32(0)
+	for i := 0; i < bits; i++ {
+		bit := rc.bit(&probs[m])
+		m = m<<1 | bit
+		result |= bit << i
+	}
+	return result
+}
+
+func (rc *rangeDecoder) direct(bits int) uint32 {
+	var result uint32
+	for i := 0; i < bits; i++ {
+		rc.normalize()
+		rc.rng >>= 1
+		var bit uint32
+		if rc.code >= rc.rng {
+			rc.code -= rc.rng
+			bit = 1
+		}
+		result = result<<1 | bit
+	}
+	return result
+}
+
+// lzmaLengthDecoder decodes match lengths, 0 meaning the minimum of 2
+type lzmaLengthDecoder struct {
+	choice, choice2 uint16
+	low, mid        [16][8]uint16
+	high            [256]uint16
+}
+
+func (d *lzmaLengthDecoder) decode(rc *rangeDecoder, posState uint32) uint32 {
+	if rc.bit(&d.choice) == 0 {
+		return rc.bitTree(d.low[posState][:], 3)
+	}
+	if rc.bit(&d.choice2) == 0 {
+		return 8 + rc.bitTree(d.mid[posState][:], 3)
+	}
+	return 16 + rc.bitTree(d.high[:], 8)
+}
+
+// lzmaState is the LZMA decoder state, kept across LZMA2 chunks
+type lzmaState struct {
+	lc, lp, pb uint32
+	state      int
+	reps       [4]uint32
+
+	isMatch    [12 << 4]uint16
+	isRep      [12]uint16
+	isRepG0    [12]uint16
+	isRepG1    [12]uint16
+	isRepG2    [12]uint16
+	isRep0Long [12 << 4]uint16
+	posSlot    [4][64]uint16
+	posSpecial [115]uint16 // Indexed from 1, as the bit trees are
+	align      [16]uint16
+	length     lzmaLengthDecoder
+	repLength  lzmaLengthDecoder
+	literal    []uint16
+}
+
+func (s *lzmaState) reset() {
+	s.state = 0
+	s.reps = [4]uint32{}
+	probs := [][]uint16{s.isMatch[:], s.isRep[:], s.isRepG0[:], s.isRepG1[:], s.isRepG2[:],
+		s.isRep0Long[:], s.posSpecial[:], s.align[:], s.literal}
+	for i := range s.posSlot {
+		probs = append(probs, s.posSlot[i][:])
+	}
+	for _, d := range []*lzmaLengthDecoder{&s.length, &s.repLength} {
+		probs = append(probs, d.high[:])
+		d.choice, d.choice2 = 1024, 1024
+		for i := range d.low {
+			probs = append(probs, d.low[i][:], d.mid[i][:])
+		}
+	}
+	for _, p := range probs {
+		for i := range p {
+			p[i] = 1024
+		}
+	}
+}
+
+// setProperties applies an LZMA2 properties byte
+func (s *lzmaState) setProperties(props byte) error {
+	if props >= 9*5*5 {
+		return errXZData
+	}
+	s.lc, s.lp, s.pb = uint32(props%9), uint32(props/9%5), uint32(props/45)
+	if s.lc+s.lp > 4 {
+		return errXZData
+	}
+	s.literal = make([]uint16, 0x300<<(s.lc+s.lp))
+	return nil
+}
+
+// decodeLZMA2 decodes LZMA2 chunks up to the end marker
+func decodeLZMA2(in *xzInput, out io.Writer, dictSize uint32) error {
+	window := &lzmaWindow{size: int(min(dictSize, 1<<30)), out: out}
+	window.size = max(window.size, 4096)
+	var state lzmaState
+	needProps, needDictReset := true, true
+	for {
+		control, err := in.ReadByte()
+		if err != nil {
+			return err
+		}
+		if control == 0 {
+			return window.err
+		}
+
+		if control < 0x80 {
+			// An uncompressed chunk, resetting the dictionary for 1
+			if control > 2 || (control == 2 && needDictReset) {
+				return errXZData
+			}
+			if control == 1 {
+				window.reset()
+				needDictReset = false
+			}
+			sizeBytes, err := in.readFull(2)
+			if err != nil {
+				return err
+			}
+			data, err := in.readFull(int(binary.BigEndian.Uint16(sizeBytes)) + 1)
+			if err != nil {
+				return err
+			}
+			for _, b := range data {
+				window.put(b)
+			}
+			window.flush()
+			continue
+		}
+
+		header, err := in.readFull(4)
+		if err != nil {
+			return err
+		}
+		unpacked := int(control&0x1f)<<16 + int(binary.BigEndian.Uint16(header[:2])) + 1
+		packed := int(binary.BigEndian.Uint16(header[2:])) + 1
+		reset := (control >> 5) & 3
+		if reset == 3 {
+			window.reset()
+			needDictReset = false
+		} else if needDictReset {
+			return errXZData
+		}
+		if reset >= 2 {
+			props, err := in.ReadByte()
+			if err != nil {
+				return err
+			}
+			if err := state.setProperties(props); err != nil {
+				return err
+			}
+			needProps = false
+		} else if needProps {
+			return errXZData
+		}
+		if reset >= 1 {
+			state.reset()
+		}
+		data, err := in.readFull(packed)
+		if err != nil {
+			return err
+		}
+		if err := state.decodeChunk(data, window, unpacked); err != nil {
+			return err
+		}
+		if window.err != nil {
+			return window.err
+		}
+	}
+}
+
+// decodeChunk decodes one LZMA chunk of a known unpacked size
+func (s *lzmaState) decodeChunk(data []byte, window *lzmaWindow, unpacked int) error {
+	rc, err := newRangeDecoder(data)
+	if err != nil {
+		return err
+	}
+	for produced := 0; produced < unpacked; {
+		pos := uint32(window.len)
+		posState := pos & (1<<s.pb - 1)
+		if rc.bit(&s.isMatch[s.state<<4|int(posState)]) == 0 {
+			var prev uint32
+			if window.len > 0 {
+				prev = uint32(window.get(1))
+			}
+			litState := (pos&(1<<s.lp-1))<<s.lc | prev>>(8-s.lc)
+			probs := s.literal[0x300*litState:]
+			symbol := uint32(1)
+			if s.state >= 7 {
+				if !window.has(s.reps[0] + 1) {
+					return errXZData
+				}
+				match := uint32(window.get(int(s.reps[0]) + 1))
+				for symbol < 0x100 {
+					matchBit := match >> 7 & 1
+					match <<= 1
+					bit := rc.bit(&probs[0x100+matchBit<<8+symbol])
+					symbol = symbol<<1 | bit
+					if matchBit != bit {
+						break
+					}
+				}
+			}
+			for symbol < 0x100 {
+				symbol = symbol<<1 | rc.bit(&probs[symbol])
+			}
+			window.put(byte(symbol))
+			produced++
+			switch {
+			case s.state < 4:
+				s.state = 0
+			case s.state < 10:
+				s.state -= 3
+			default:
+				s.state -= 6
+			}
+			continue
+		}
+
+		var length uint32
+		if rc.bit(&s.isRep[s.state]) == 0 {
+			length = s.length.decode(rc, posState)
+			s.reps[3], s.reps[2], s.reps[1] = s.reps[2], s.reps[1], s.reps[0]
+			s.reps[0] = s.decodeDistance(rc, length)
+			if s.state < 7 {
+				s.state = 7
+			} else {
+				s.state = 10
+			}
+		} else {
+			if rc.bit(&s.isRepG0[s.state]) == 0 {
+				if rc.bit(&s.isRep0Long[s.state<<4|int(posState)]) == 0 {
+					// A short rep: one byte from the last distance
+					if !window.has(s.reps[0] + 1) {
+						return errXZData
+					}
+					if s.state < 7 {
+						s.state = 9
+					} else {
+						s.state = 11
+					}
+					window.put(window.get(int(s.reps[0]) + 1))
+					produced++
+					continue
+				}
+			} else {
+				var dist uint32
+				if rc.bit(&s.isRepG1[s.state]) == 0 {
+					dist = s.reps[1]
+				} else {
+					if rc.bit(&s.isRepG2[s.state]) == 0 {
+						dist = s.reps[2]
+					} else {
+						dist = s.reps[3]
+						s.reps[3] = s.reps[2]
+					}
+					s.reps[2] = s.reps[1]
+				}
+				s.reps[1] = s.reps[0]
+				s.reps[0] = dist
+			}
+			length = s.repLength.decode(rc, posState)
+			if s.state < 7 {
+				s.state = 8
+			} else {
+				s.state = 11
+			}
+		}
+
+		n := int(length) + 2
+		if rc.error || !window.has(s.reps[0]+1) || produced+n > unpacked {
+			return errXZData
+		}
+		dist := int(s.reps[0]) + 1
+		for i := 0; i < n; i++ {
+			window.put(window.get(dist))
+		}
+		produced += n
+	}
+	window.flush()
+	rc.normalize()
+	if rc.error || rc.pos != len(rc.data) || rc.code != 0 {
+		return errXZData
+	}
+	return nil
+}
+
+// decodeDistance decodes a match distance, less one
+func (s *lzmaState) decodeDistance(rc *rangeDecoder, length uint32) uint32 {
+	slot := rc.bitTree(s.posSlot[min(length, 3)][:], 6)
+	if slot < 4 {
+		return slot
+	}
+	bits := int(slot>>1) - 1
+	dist := (2 | slot&1) << bits
+	if slot < 14 {
+		return dist + rc.bitTreeReverse(s.posSpecial[dist-slot:], bits)
+	}
+	dist += rc.direct(bits-4) << 4
+	return dist + rc.bitTreeReverse(s.align[:], 4)
+}
@@ -0,0 +1,942 @@
+package builtin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Yaml2json converts the YAML documents on stdin to JSON, one value per
+// document, so configuration files can be piped into jq. It reads block and
+// flow collections, plain and quoted scalars, literal and folded block
+// scalars, anchors, aliases and << merge keys; scalars are typed with the
+// YAML 1.2 core schema. Complex (?) keys are not supported.
+func Yaml2json(args []string, stdin io.Reader, stdout io.Writer) error {
+	compact, sortKeys, err := parseToJSONFlags("yaml2json", args)
+	if err != nil {
+		return err
+	}
+	input, err := io.ReadAll(stdin)
+	if err != nil {
+		return fmt.Errorf("yaml2json: failed to read input: %w", err)
+	}
+	docs, err := parseYAML(string(input))
+	if err != nil {
+		return fmt.Errorf("yaml2json: %w", err)
+	}
+	out := bufio.NewWriter(stdout)
+	for _, doc := range docs {
+		out.WriteString(jqEncode(doc, !compact, sortKeys))
+		out.WriteByte('\n')
+	}
+	return out.Flush()
+}
+
+// Json2yaml converts the JSON values on stdin to block-style YAML, writing
+// one document per value separated by ---.
+func Json2yaml(args []string, stdin io.Reader, stdout io.Writer) error {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") && arg != "-" {
+			return fmt.Errorf("json2yaml: %s: invalid option", arg)
+		}
+		return fmt.Errorf("json2yaml: %s: file operands are not supported; pipe the input instead", arg)
+	}
+	dec := json.NewDecoder(bufio.NewReader(stdin))
+	dec.UseNumber()
+	out := bufio.NewWriter(stdout)
+	for n := 0; ; n++ {
+		v, err := jqDecode(dec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			out.Flush()
+			return fmt.Errorf("json2yaml: invalid JSON input: %w", err)
+		}
+		if n > 0 {
+			out.WriteString("---\n")
+		}
+		var b strings.Builder
+		writeYAML(&b, v, 0)
+		out.WriteString(b.String())
+	}
+	return out.Flush()
+}
+
+// parseToJSONFlags reads the -c and -S options shared by the converters
+// that produce JSON
+func parseToJSONFlags(name string, args []string) (compact, sortKeys bool, err error) {
+	for _, arg := range args {
+		switch arg {
+		case "-c", "--compact-output":
+			compact = true
+		case "-S", "--sort-keys":
+			sortKeys = true
+		case "-cS", "-Sc":
+			compact, sortKeys = true, true
+		default:
+			if strings.HasPrefix(arg, "-") && arg != "-" {
+				return false, false, fmt.Errorf("%s: %s: invalid option", name, arg)
+			}
+			return false, false, fmt.Errorf("%s: %s: file operands are not supported; pipe the input instead", name, arg)
+		}
+	}
+	return compact, sortKeys, nil
+}
+
+// yamlParser reads the block structure of one YAML document line by line;
+// flow collections and quoted scalars within a line are read by yamlFlow
+type yamlParser struct {
+	lines   []string
+	first   int // line number of lines[0], for error messages
+	pos     int
+	anchors map[string]interface{}
+}
+
+// parseYAML splits the input into documents and parses each. An empty
+// stream yields no documents; an explicit --- with no content yields null.
+func parseYAML(input string) ([]interface{}, error) {
+	lines := strings.Split(strings.ReplaceAll(input, "\r\n", "\n"), "\n")
+	var docs []interface{}
+	start, explicit := 0, false
+	finish := func(end int) error {
+		p := &yamlParser{lines: lines[start:end], first: start + 1, anchors: make(map[string]interface{})}
+		p.skipBlank()
+		if p.pos == len(p.lines) {
+			if explicit {
+				docs = append(docs, nil)
+			}
+			return nil
+		}
+		v, err := p.parseBlock(0)
+		if err != nil {
+			return err
+		}
+		if p.skipBlank(); p.pos < len(p.lines) {
+			return p.errorf("unexpected content at this indentation")
+		}
+		docs = append(docs, v)
+		return nil
+	}
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "%") && !explicit:
+			lines[i] = ""
+		case line == "---" || strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "---\t"):
+			if err := finish(i); err != nil {
+				return nil, err
+			}
+			lines[i] = "   " + line[3:]
+			start, explicit = i, true
+		case line == "..." || strings.HasPrefix(line, "... "):
+			if err := finish(i); err != nil {
+				return nil, err
+			}
+			start, explicit = i+1, false
+		}
+	}
+	if err := finish(len(lines)); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.first+p.pos, fmt.Sprintf(format, args...))
+}
+
+// skipBlank moves past empty and comment-only lines
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) {
+		text := strings.TrimLeft(p.lines[p.pos], " \t")
+		if text != "" && text[0] != '#' {
+			return
+		}
+		p.pos++
+	}
+}
+
+// current returns the indentation and content of the current line
+func (p *yamlParser) current() (int, string) {
+	line := p.lines[p.pos]
+	indent := len(line) - len(strings.TrimLeft(line, " "))
+	return indent, strings.TrimRight(line[indent:], " \t")
+}
+
+func yamlIsSeqEntry(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ") || strings.HasPrefix(text, "-\t")
+}
+
+// parseBlock parses the node starting at the next content line, which must be
+// indented by at least minIndent; a missing node is null
+func (p *yamlParser) parseBlock(minIndent int) (interface{}, error) {
+	p.skipBlank()
+	if p.pos == len(p.lines) {
+		return nil, nil
+	}
+	indent, text := p.current()
+	if indent < minIndent {
+		return nil, nil
+	}
+	if strings.HasPrefix(text, "\t") {
+		return nil, p.errorf("tabs are not allowed for indentation")
+	}
+	if yamlIsSeqEntry(text) {
+		return p.parseSeq(indent)
+	}
+	if _, _, ok, err := yamlSplitKey(text); err != nil {
+		return nil, p.errorf("%v", err)
+	} else if ok {
+		return p.parseMap(indent)
+	}
+	return p.parseValue(text, minIndent-1, false)
+}
+
+// parseSeq parses block sequence entries at the given indentation. The
+// content after "- " is re-read as if it started its own line, so that
+// "- key: value" begins a mapping indented past the dash.
+func (p *yamlParser) parseSeq(indent int) (interface{}, error) {
+	seq := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos == len(p.lines) {
+			return seq, nil
+		}
+		lineIndent, text := p.current()
+		if lineIndent != indent || !yamlIsSeqEntry(text) {
+			if lineIndent > indent {
+				return nil, p.errorf("bad indentation of a sequence entry")
+			}
+			return seq, nil
+		}
+		rest := strings.TrimLeft(text[1:], " \t")
+		var item interface{}
+		var err error
+		if rest == "" || rest[0] == '#' {
+			p.pos++
+			item, err = p.parseBlock(indent + 1)
+		} else {
+			p.lines[p.pos] = strings.Repeat(" ", len(text)-len(rest)+indent) + rest
+			item, err = p.parseBlock(indent + 1)
+		}
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, item)
+	}
+}
+
+// parseMap parses block mapping entries at the given indentation
+func (p *yamlParser) parseMap(indent int) (interface{}, error) {
+	obj := newJqObject()
+	var merges []interface{}
+	for {
+		p.skipBlank()
+		if p.pos == len(p.lines) {
+			break
+		}
+		lineIndent, text := p.current()
+		if lineIndent < indent {
+			break
+		}
+		if lineIndent > indent {
+			return nil, p.errorf("bad indentation of a mapping entry")
+		}
+		if yamlIsSeqEntry(text) {
+			break
+		}
+		key, rest, ok, err := yamlSplitKey(text)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if !ok {
+			return nil, p.errorf("expected a key: value entry")
+		}
+		value, err := p.parseValue(rest, indent, true)
+		if err != nil {
+			return nil, err
+		}
+		if key == "<<" {
+			merges = append(merges, value)
+			continue
+		}
+		obj.set(key, value)
+	}
+	for _, merge := range merges {
+		sources, isList := merge.([]interface{})
+		if !isList {
+			sources = []interface{}{merge}
+		}
+		for _, source := range sources {
+			src, ok := source.(*jqObject)
+			if !ok {
+				return nil, p.errorf("<< must merge a mapping or a list of mappings")
+			}
+			for _, k := range src.keys {
+				if _, exists := obj.get(k); !exists {
+					obj.set(k, src.values[k])
+				}
+			}
+		}
+	}
+	return obj, nil
+}
+
+// parseValue parses the value that starts with text on the current line of a
+// node whose parent is at parentIndent. When sameIndentSeq is set, a block
+// sequence at the parent's own indentation may follow, as in "key:\n- a".
+func (p *yamlParser) parseValue(text string, parentIndent int, sameIndentSeq bool) (interface{}, error) {
+	anchor, tag := "", ""
+	for {
+		text = strings.TrimLeft(text, " \t")
+		if len(text) == 0 || (text[0] != '&' && text[0] != '!') {
+			break
+		}
+		end := strings.IndexAny(text, " \t")
+		if end < 0 {
+			end = len(text)
+		}
+		if text[0] == '&' {
+			anchor = text[1:end]
+		} else {
+			tag = text[:end]
+		}
+		text = text[end:]
+	}
+
+	var value interface{}
+	var err error
+	switch {
+	case text == "" || text[0] == '#':
+		p.pos++
+		p.skipBlank()
+		if sameIndentSeq && p.pos < len(p.lines) {
+			if indent, next := p.current(); indent == parentIndent && yamlIsSeqEntry(next) {
+				value, err = p.parseSeq(indent)
+				break
+			}
+		}
+		value, err = p.parseBlock(parentIndent + 1)
+	case text[0] == '|' || text[0] == '>':
+		value, err = p.parseBlockScalar(text, parentIndent)
+	case text[0] == '*':
+		name := strings.TrimRight(strings.SplitN(text[1:], " ", 2)[0], " \t")
+		v, ok := p.anchors[name]
+		if !ok {
+			return nil, p.errorf("unknown alias *%s", name)
+		}
+		if rest := strings.TrimLeft(text[1+len(name):], " \t"); rest != "" && rest[0] != '#' {
+			return nil, p.errorf("unexpected %q after alias", rest)
+		}
+		p.pos++
+		value = v
+	default:
+		value, err = p.parseInline(text, parentIndent, tag == "!!str" || tag == "!str")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if anchor != "" {
+		p.anchors[anchor] = value
+	}
+	return value, nil
+}
+
+// parseInline parses a flow collection or scalar, joining continuation lines
+// indented past the parent for multi-line plain scalars and for quoted
+// scalars and flow collections that are not yet closed
+func (p *yamlParser) parseInline(text string, parentIndent int, forceString bool) (interface{}, error) {
+	startLine := p.pos
+	p.pos++
+	for {
+		f := &yamlFlow{text: text, anchors: p.anchors}
+		v, err := f.parseTop(forceString)
+		if err == nil {
+			if _, isPlain := v.(yamlPlain); !isPlain {
+				return yamlResolve(v, forceString), nil
+			}
+			// a plain scalar continues on more-indented lines
+			for p.pos < len(p.lines) {
+				indent, next := p.current()
+				if next == "" || indent <= parentIndent || next[0] == '#' {
+					break
+				}
+				if _, _, ok, _ := yamlSplitKey(next); ok || yamlIsSeqEntry(next) {
+					break
+				}
+				text += " " + next
+				p.pos++
+			}
+			f = &yamlFlow{text: text, anchors: p.anchors}
+			v, err = f.parseTop(forceString)
+			if err != nil {
+				p.pos = startLine
+				return nil, p.errorf("%v", err)
+			}
+			return yamlResolve(v, forceString), nil
+		}
+		if err != errYAMLUnterminated || p.pos == len(p.lines) {
+			p.pos = startLine
+			return nil, p.errorf("%v", err)
+		}
+		_, next := p.current()
+		text += " " + next
+		p.pos++
+	}
+}
+
+// parseBlockScalar reads a literal (|) or folded (>) block scalar whose
+// header is text, with its optional chomping and indentation indicators
+func (p *yamlParser) parseBlockScalar(header string, parentIndent int) (interface{}, error) {
+	folded := header[0] == '>'
+	chomp, contentIndent := byte(0), 0
+	rest := header[1:]
+	for len(rest) > 0 && rest[0] != ' ' && rest[0] != '\t' {
+		switch c := rest[0]; {
+		case c == '-' || c == '+':
+			chomp = c
+		case c >= '1' && c <= '9':
+			contentIndent = max(parentIndent, 0) + int(c-'0')
+		default:
+			return nil, p.errorf("invalid block scalar header %q", header)
+		}
+		rest = rest[1:]
+	}
+	if rest = strings.TrimLeft(rest, " \t"); rest != "" && rest[0] != '#' {
+		return nil, p.errorf("unexpected %q after block scalar header", rest)
+	}
+	p.pos++
+
+	var lines []string
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := strings.TrimRight(p.lines[p.pos], "\r")
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+		if strings.TrimSpace(line) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if contentIndent == 0 {
+			if indent <= parentIndent {
+				break
+			}
+			contentIndent = indent
+		}
+		if indent < contentIndent {
+			break
+		}
+		lines = append(lines, line[contentIndent:])
+	}
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	var b strings.Builder
+	moreIndented := func(line string) bool {
+		return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+	}
+	for i, line := range lines {
+		if i > 0 {
+			prev := lines[i-1]
+			switch {
+			case !folded || line == "" || moreIndented(line) || moreIndented(prev):
+				b.WriteByte('\n')
+			case prev != "":
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(line)
+	}
+	if len(lines) > 0 && chomp != '-' {
+		b.WriteByte('\n')
+	}
+	if chomp == '+' {
+		b.WriteString(strings.Repeat("\n", trailing))
+	}
+	return b.String(), nil
+}
+
+// yamlSplitKey splits a block mapping entry "key: value" into its key and
+// the rest of the line; ok is false when the line is not a mapping entry
+func yamlSplitKey(text string) (key, rest string, ok bool, err error) {
+	if text == "" || text[0] == '[' || text[0] == '{' || text[0] == '#' {
+		return "", "", false, nil
+	}
+	if text[0] == '?' && (len(text) == 1 || text[1] == ' ') {
+		return "", "", false, fmt.Errorf("complex mapping keys (?) are not supported")
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		f := &yamlFlow{text: text}
+		k, err := f.parseQuoted()
+		if err != nil {
+			return "", "", false, nil
+		}
+		f.skipSpaces()
+		if !f.keyColon() {
+			return "", "", false, nil
+		}
+		return k, f.text[f.pos+1:], true, nil
+	}
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case ':':
+			if i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\t' {
+				return strings.TrimRight(text[:i], " \t"), text[i+1:], true, nil
+			}
+		case '#':
+			if i > 0 && (text[i-1] == ' ' || text[i-1] == '\t') {
+				return "", "", false, nil
+			}
+		}
+	}
+	return "", "", false, nil
+}
+
+// yamlPlain marks an unquoted scalar until yamlResolve types it
+type yamlPlain string
+
+var (
+	errYAMLUnterminated = fmt.Errorf("unterminated quoted scalar or flow collection")
+	yamlIntPattern      = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloatPattern    = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+	// yamlLegacyBools are strings that YAML 1.1 readers take for booleans,
+	// so json2yaml quotes them
+	yamlLegacyBools = map[string]bool{"y": true, "n": true, "yes": true, "no": true, "on": true, "off": true}
+)
+
+// yamlFlow reads flow-style YAML within a single (possibly joined) line
+type yamlFlow struct {
+	text    string
+	pos     int
+	anchors map[string]interface{}
+}
+
+func (f *yamlFlow) skipSpaces() {
+	for f.pos < len(f.text) && (f.text[f.pos] == ' ' || f.text[f.pos] == '\t') {
+		f.pos++
+	}
+}
+
+// keyColon reports whether the next character is a ':' that ends a key
+func (f *yamlFlow) keyColon() bool {
+	return f.pos < len(f.text) && f.text[f.pos] == ':' &&
+		(f.pos+1 == len(f.text) || strings.IndexByte(" \t,]}", f.text[f.pos+1]) >= 0)
+}
+
+// parseTop parses a whole value and checks that only a comment follows
+func (f *yamlFlow) parseTop(forceString bool) (interface{}, error) {
+	v, err := f.parseNode(false)
+	if err != nil {
+		return nil, err
+	}
+	f.skipSpaces()
+	if f.pos < len(f.text) && f.text[f.pos] != '#' {
+		return nil, fmt.Errorf("unexpected %q", f.text[f.pos:])
+	}
+	return v, nil
+}
+
+func (f *yamlFlow) parseNode(inFlow bool) (interface{}, error) {
+	f.skipSpaces()
+	if f.pos == len(f.text) {
+		if inFlow {
+			return nil, errYAMLUnterminated
+		}
+		return nil, nil
+	}
+	anchor, forceString := "", false
+	for f.pos < len(f.text) && (f.text[f.pos] == '&' || f.text[f.pos] == '!') {
+		start := f.pos
+		for f.pos < len(f.text) && strings.IndexByte(" \t,[]{}", f.text[f.pos]) < 0 {
+			f.pos++
+		}
+		if f.text[start] == '&' {
+			anchor = f.text[start+1 : f.pos]
+		} else if tag := f.text[start:f.pos]; tag == "!!str" || tag == "!str" {
+			forceString = true
+		}
+		f.skipSpaces()
+	}
+
+	var v interface{}
+	var err error
+	switch c := f.text[f.pos]; c {
+	case '[':
+		v, err = f.parseFlowSeq()
+	case '{':
+		v, err = f.parseFlowMap()
+	case '"', '\'':
+		v, err = f.parseQuoted()
+	case '*':
+		start := f.pos + 1
+		for f.pos++; f.pos < len(f.text) && strings.IndexByte(" \t,[]{}", f.text[f.pos]) < 0; f.pos++ {
+		}
+		name := f.text[start:f.pos]
+		var ok bool
+		if v, ok = f.anchors[name]; !ok {
+			return nil, fmt.Errorf("unknown alias *%s", name)
+		}
+	default:
+		v = f.parsePlain(inFlow)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if inFlow {
+		v = yamlResolve(v, forceString)
+	} else if forceString {
+		v = yamlResolve(v, true)
+	}
+	if anchor != "" && f.anchors != nil {
+		f.anchors[anchor] = yamlResolve(v, forceString)
+	}
+	return v, nil
+}
+
+// parsePlain reads an unquoted scalar, which in a flow collection also ends
+// at ',', ']', '}' and ': '
+func (f *yamlFlow) parsePlain(inFlow bool) yamlPlain {
+	start := f.pos
+	for f.pos < len(f.text) {
+		c := f.text[f.pos]
+		if c == '#' && f.pos > start && (f.text[f.pos-1] == ' ' || f.text[f.pos-1] == '\t') {
+			break
+		}
+		if inFlow && (c == ',' || c == ']' || c == '}' || f.keyColon()) {
+			break
+		}
+		f.pos++
+	}
+	return yamlPlain(strings.TrimRight(f.text[start:f.pos], " \t"))
+}
+
+func (f *yamlFlow) parseFlowSeq() (interface{}, error) {
+	f.pos++
+	seq := []interface{}{}
+	for {
+		f.skipSpaces()
+		if f.pos == len(f.text) {
+			return nil, errYAMLUnterminated
+		}
+		if f.text[f.pos] == ']' {
+			f.pos++
+			return seq, nil
+		}
+		v, err := f.parseNode(true)
+		if err != nil {
+			return nil, err
+		}
+		f.skipSpaces()
+		if f.keyColon() {
+			// [a: 1] is a sequence of single-pair mappings
+			f.pos++
+			value, err := f.parseNode(true)
+			if err != nil {
+				return nil, err
+			}
+			pair := newJqObject()
+			pair.set(yamlKeyString(v), value)
+			v = pair
+			f.skipSpaces()
+		}
+		seq = append(seq, v)
+		if f.pos == len(f.text) {
+			return nil, errYAMLUnterminated
+		}
+		switch f.text[f.pos] {
+		case ',':
+			f.pos++
+		case ']':
+		default:
+			return nil, fmt.Errorf("expected ',' or ']' in flow sequence")
+		}
+	}
+}
+
+func (f *yamlFlow) parseFlowMap() (interface{}, error) {
+	f.pos++
+	obj := newJqObject()
+	for {
+		f.skipSpaces()
+		if f.pos == len(f.text) {
+			return nil, errYAMLUnterminated
+		}
+		if f.text[f.pos] == '}' {
+			f.pos++
+			return obj, nil
+		}
+		k, err := f.parseNode(true)
+		if err != nil {
+			return nil, err
+		}
+		f.skipSpaces()
+		var value interface{}
+		if f.keyColon() {
+			f.pos++
+			f.skipSpaces()
+			if f.pos < len(f.text) && (f.text[f.pos] == ',' || f.text[f.pos] == '}') {
+				value = nil
+			} else if value, err = f.parseNode(true); err != nil {
+				return nil, err
+			}
+			f.skipSpaces()
+		}
+		obj.set(yamlKeyString(k), value)
+		if f.pos == len(f.text) {
+			return nil, errYAMLUnterminated
+		}
+		switch f.text[f.pos] {
+		case ',':
+			f.pos++
+		case '}':
+		default:
+			return nil, fmt.Errorf("expected ',' or '}' in flow mapping")
+		}
+	}
+}
+
+// parseQuoted reads a single- or double-quoted scalar
+func (f *yamlFlow) parseQuoted() (string, error) {
+	quote := f.text[f.pos]
+	f.pos++
+	var b strings.Builder
+	for f.pos < len(f.text) {
+		c := f.text[f.pos]
+		switch {
+		case c == quote && quote == '\'' && f.pos+1 < len(f.text) && f.text[f.pos+1] == '\'':
+			b.WriteByte('\'')
+			f.pos += 2
+		case c == quote:
+			f.pos++
+			return b.String(), nil
+		case c == '\\' && quote == '"':
+			if f.pos+1 == len(f.text) {
+				return "", errYAMLUnterminated
+			}
+			f.pos++
+			esc := f.text[f.pos]
+			f.pos++
+			switch esc {
+			case 'n':
+				b.WriteByte('\n')
+			case 't', '\t':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'v':
+				b.WriteByte('\v')
+			case 'a':
+				b.WriteByte('\a')
+			case 'e':
+				b.WriteByte(0x1b)
+			case '0':
+				b.WriteByte(0)
+			case ' ', '"', '/', '\\':
+				b.WriteByte(esc)
+			case 'N':
+				b.WriteString("\u0085")
+			case '_':
+				b.WriteString(" ")
+			case 'x', 'u', 'U':
+				size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[esc]
+				if f.pos+size > len(f.text) {
+					return "", fmt.Errorf("invalid escape \\%c", esc)
+				}
+				code, err := strconv.ParseUint(f.text[f.pos:f.pos+size], 16, 32)
+				if err != nil {
+					return "", fmt.Errorf("invalid escape \\%c%s", esc, f.text[f.pos:f.pos+size])
+				}
+				b.WriteRune(rune(code))
+				f.pos += size
+			default:
+				return "", fmt.Errorf("invalid escape \\%c", esc)
+			}
+		default:
+			b.WriteByte(c)
+			f.pos++
+		}
+	}
+	return "", errYAMLUnterminated
+}
+
+// yamlKeyString turns a mapping key into a JSON object key; non-string
+// scalar keys keep the text they were written with
+func yamlKeyString(k interface{}) string {
+	switch t := k.(type) {
+	case yamlPlain:
+		return string(t)
+	case string:
+		return t
+	case nil:
+		return ""
+	}
+	return jqEncode(k, false, false)
+}
+
+// yamlResolve types a plain scalar with the YAML 1.2 core schema
+func yamlResolve(v interface{}, forceString bool) interface{} {
+	plain, ok := v.(yamlPlain)
+	if !ok {
+		return v
+	}
+	s := string(plain)
+	if forceString {
+		return s
+	}
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return math.Inf(1)
+	case "-.inf", "-.Inf", "-.INF":
+		return math.Inf(-1)
+	case ".nan", ".NaN", ".NAN":
+		return math.NaN()
+	}
+	if yamlIntPattern.MatchString(s) || yamlFloatPattern.MatchString(s) {
+		if n, err := strconv.ParseFloat(s, 64); err == nil {
+			return n
+		}
+	}
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0o") {
+		if n, err := strconv.ParseUint(s[2:], map[string]int{"0x": 16, "0o": 8}[s[:2]], 64); err == nil {
+			return float64(n)
+		}
+	}
+	return s
+}
+
+// writeYAML writes v as block-style YAML indented by indent spaces
+func writeYAML(b *strings.Builder, v interface{}, indent int) {
+	pad := strings.Repeat(" ", indent)
+	switch t := v.(type) {
+	case *jqObject:
+		if len(t.keys) == 0 {
+			b.WriteString(pad + "{}\n")
+			return
+		}
+		for _, key := range t.keys {
+			b.WriteString(pad + yamlScalar(key) + ":")
+			writeYAMLValue(b, t.values[key], " ", indent+2)
+		}
+	case []interface{}:
+		if len(t) == 0 {
+			b.WriteString(pad + "[]\n")
+			return
+		}
+		for _, elem := range t {
+			var item strings.Builder
+			writeYAML(&item, elem, indent+2)
+			b.WriteString(pad + "- " + item.String()[indent+2:])
+		}
+	default:
+		b.WriteString(pad)
+		writeYAMLValue(b, v, "", indent+2)
+	}
+}
+
+// writeYAMLValue writes the value of a mapping entry after its key, or a
+// scalar on its own line after space: collections start on the next line,
+// multi-line strings become literal block scalars
+func writeYAMLValue(b *strings.Builder, v interface{}, space string, indent int) {
+	switch t := v.(type) {
+	case *jqObject:
+		if len(t.keys) == 0 {
+			b.WriteString(space + "{}\n")
+			return
+		}
+		b.WriteByte('\n')
+		writeYAML(b, t, indent)
+	case []interface{}:
+		if len(t) == 0 {
+			b.WriteString(space + "[]\n")
+			return
+		}
+		b.WriteByte('\n')
+		writeYAML(b, t, indent)
+	case string:
+		if yamlLiteralBlock(t) {
+			body := strings.TrimSuffix(t, "\n")
+			header := "|-"
+			if strings.HasSuffix(t, "\n") {
+				header = "|"
+				if strings.HasSuffix(body, "\n") {
+					header = "|+"
+				}
+			}
+			b.WriteString(space + header + "\n")
+			for _, line := range strings.Split(strings.TrimRight(body, "\n"), "\n") {
+				if line != "" {
+					b.WriteString(strings.Repeat(" ", indent) + line)
+				}
+				b.WriteByte('\n')
+			}
+			if header == "|+" {
+				b.WriteString(strings.Repeat("\n", len(body)-len(strings.TrimRight(body, "\n"))))
+			}
+			return
+		}
+		b.WriteString(space + yamlScalar(t) + "\n")
+	default:
+		b.WriteString(space + yamlScalar(v) + "\n")
+	}
+}
+
+// yamlLiteralBlock reports whether a string reads back unchanged from a
+// literal block scalar
+func yamlLiteralBlock(s string) bool {
+	if !strings.Contains(strings.TrimRight(s, "\n"), "\n") || strings.HasPrefix(s, " ") || strings.HasPrefix(s, "\n") {
+		return false
+	}
+	for _, r := range s {
+		if (r < 0x20 && r != '\n' && r != '\t') || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// yamlScalar formats a scalar, quoting strings that would otherwise read back
+// as another type or break the YAML syntax
+func yamlScalar(v interface{}) string {
+	s, ok := v.(string)
+	if !ok {
+		if n, isNum := v.(float64); isNum && (math.IsInf(n, 0) || math.IsNaN(n)) {
+			switch {
+			case math.IsNaN(n):
+				return ".nan"
+			case n > 0:
+				return ".inf"
+			}
+			return "-.inf"
+		}
+		return jqEncode(v, false, false)
+	}
+	if s == "" || strings.TrimSpace(s) != s || strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") ||
+		yamlResolve(yamlPlain(s), false) != interface{}(s) || yamlLegacyBools[strings.ToLower(s)] {
+		return jqQuote(s)
+	}
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			return jqQuote(s)
+		}
+	}
+	return s
+}
@@ -0,0 +1,93 @@
+package builtin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestYaml2json(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		input  string
+		output string
+		err    string
+	}{
+		{"block mapping", []string{"-c"}, "name: app # the name\nport: 8080\ndebug: false\nratio: .5\nnone: ~\nword: yes\n", `{"name":"app","port":8080,"debug":false,"ratio":0.5,"none":null,"word":"yes"}` + "\n", ""},
+		{"nested collections", []string{"-c"}, "servers:\n- host: a\n  ports: [80, 443]\n- host: b\n  env: {TZ: UTC}\n", `{"servers":[{"host":"a","ports":[80,443]},{"host":"b","env":{"TZ":"UTC"}}]}` + "\n", ""},
+		{"quoted scalars", []string{"-c"}, "a: '1'\nb: \"tab\\there\"\nc: 'it''s'\n\"d: e\": !!str 10\n", `{"a":"1","b":"tab\there","c":"it's","d: e":"10"}` + "\n", ""},
+		{"block scalars", []string{"-c"}, "lit: |\n  one\n    two\nfold: >-\n  a\n  b\n\n  c\nnext: 1\n", `{"lit":"one\n  two\n","fold":"a b\nc","next":1}` + "\n", ""},
+		{"anchors and merge keys", []string{"-c"}, "base: &base\n  a: 1\n  b: 2\nchild:\n  <<: *base\n  b: 3\nlist: [*base]\n", `{"base":{"a":1,"b":2},"child":{"b":3,"a":1},"list":[{"a":1,"b":2}]}` + "\n", ""},
+		{"multi-line plain and flow", []string{"-c"}, "text: one\n  two\nlist: [1,\n  2]\n", `{"text":"one two","list":[1,2]}` + "\n", ""},
+		{"documents", []string{"-c"}, "---\na: 1\n---\n- x\n...\n", "{\"a\":1}\n[\"x\"]\n", ""},
+		{"sorted keys pretty", []string{"-S"}, "b: 1\na: [x]\n", "{\n  \"a\": [\n    \"x\"\n  ],\n  \"b\": 1\n}\n", ""},
+		{"empty input", nil, "# nothing\n", "", ""},
+		{"bad indentation", nil, "a:\n  b: 1\n   c: 2\n", "", "yaml2json: line 3: bad indentation of a mapping entry"},
+		{"unknown alias", nil, "a: *nope\n", "", "yaml2json: line 1: unknown alias *nope"},
+		{"file operand", []string{"config.yaml"}, "", "", "yaml2json: config.yaml: file operands are not supported; pipe the input instead"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output strings.Builder
+			err := Yaml2json(tt.args, strings.NewReader(tt.input), &output)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output.String() != tt.output {
+				t.Errorf("expected output %q, got %q", tt.output, output.String())
+			}
+		})
+	}
+}
+
+func TestJson2yaml(t *testing.T) {
+	input := `{"name":"app","ports":[80,443],"env":{"TZ":"UTC","EMPTY":""},"servers":[{"host":"a","tags":[]},{"host":"b"}],"script":"echo hi\necho bye\n","quoted":["yes","12","- x","a: b",null,true]} {"second":1}`
+	want := `name: app
+ports:
+  - 80
+  - 443
+env:
+  TZ: UTC
+  EMPTY: ""
+servers:
+  - host: a
+    tags: []
+  - host: b
+script: |
+  echo hi
+  echo bye
+quoted:
+  - "yes"
+  - "12"
+  - "- x"
+  - "a: b"
+  - null
+  - true
+---
+second: 1
+`
+	var output strings.Builder
+	if err := Json2yaml(nil, strings.NewReader(input), &output); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output.String() != want {
+		t.Fatalf("expected output %q, got %q", want, output.String())
+	}
+
+	// the YAML reads back as the same JSON
+	var roundTrip strings.Builder
+	if err := Yaml2json([]string{"-c"}, strings.NewReader(output.String()), &roundTrip); err != nil {
+		t.Fatalf("yaml2json failed on json2yaml output: %v", err)
+	}
+	wantJSON := strings.Replace(input, "} {", "}\n{", 1) + "\n"
+	if roundTrip.String() != wantJSON {
+		t.Errorf("round trip = %q, want %q", roundTrip.String(), wantJSON)
+	}
+}
@@ -0,0 +1,16 @@
+package tools
+
+import "time"
+
+// Clock is the engine's time source for latency, durations and the wait,
+// poll and spawn timeouts, so tests can substitute a fake clock
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the Clock backed by the time package
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+)
+
+// executeCopy implements the copy tool - streams data from one fd to another
+// inside the engine, without passing it through the conversation
+func (e *Engine) executeCopy(args map[string]interface{}) (string, error) {
+	srcFd, err := e.fdArg(args, "src_fd")
+	if err != nil {
+		e.countError()
+		return "", fmt.Errorf("copy: %w", err)
+	}
+	dstFd, err := e.fdArg(args, "dst_fd")
+	if err != nil {
+		e.countError()
+		return "", fmt.Errorf("copy: %w", err)
+	}
+
+	count := int64(-1)
+	if value, ok := args["count"].(float64); ok {
+		if value <= 0 {
+			e.countError()
+			return "", fmt.Errorf("copy: count must be positive")
+		}
+		count = int64(value)
+	}
+
+	if !e.isOpenFd(srcFd) {
+		return "", e.fdError("copy", "invalid source file descriptor %d", srcFd)
+	}
+	if !e.isOpenFd(dstFd) {
+		return "", e.fdError("copy", "invalid destination file descriptor %d", dstFd)
+	}
+	if srcFd == dstFd {
+		e.countError()
+		return "", fmt.Errorf("copy: src_fd and dst_fd must differ")
+	}
+	reader, ok := e.fdObject(srcFd).(io.Reader)
+	if !ok || !strings.Contains(e.fdMode(srcFd), "r") {
+		return "", e.fdAccessError("copy", "file descriptor %d is not readable", srcFd)
+	}
+	writer, ok := e.fdObject(dstFd).(io.Writer)
+	if !ok || !strings.Contains(e.fdMode(dstFd), "w") {
+		return "", e.fdAccessError("copy", "file descriptor %d is not writable", dstFd)
+	}
+
+	if e.dryRun {
+		amount := "all data"
+		if count >= 0 {
+			amount = fmt.Sprintf("up to %d bytes", count)
+		}
+		return e.dryRunResult("copy", fmt.Sprintf("copy %s from fd %d (%s) to fd %d (%s)", amount, srcFd, e.fdLabel(srcFd), dstFd, e.fdLabel(dstFd)),
+			map[string]interface{}{"src_fd": srcFd, "dst_fd": dstFd})
+	}
+
+	var n int64
+	if count >= 0 {
+		n, err = io.CopyN(writer, reader, count)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	} else {
+		n, err = io.Copy(writer, reader)
+	}
+	e.countRead(srcFd, int(n))
+	e.countWrite(dstFd, int(n))
+	if err != nil {
+		e.countError()
+		if dstFd == 1 && errors.Is(err, syscall.EPIPE) {
+			return "", fmt.Errorf("copy: %w", ErrOutputClosed)
+		}
+		return "", fmt.Errorf("copy: copied %d bytes before error: %w", n, err)
+	}
+
+	resultBytes, _ := json.Marshal(map[string]interface{}{
+		"src_fd": srcFd,
+		"dst_fd": dstFd,
+		"bytes":  n,
+		"eof":    count < 0 || n < count,
+	})
+	return string(resultBytes), nil
+}
+
+// isOpenFd reports whether fd refers to an open file descriptor
+func (e *Engine) isOpenFd(fd int) bool {
+	return e.fdObject(fd) != nil && !e.isFdClosed(fd)
+}
@@ -0,0 +1,36 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// dryRunFd stands in for the fds spawn and open would create in dry-run mode.
+// Reads return EOF and writes are discarded.
+type dryRunFd struct{}
+
+func (dryRunFd) Read(p []byte) (int, error)  { return 0, io.EOF }
+func (dryRunFd) Write(p []byte) (int, error) { return len(p), nil }
+func (dryRunFd) Close() error                { return nil }
+
+// dryRunResult records an action skipped in dry-run mode and reports it to the LLM
+func (e *Engine) dryRunResult(tool, action string, fields map[string]interface{}) (string, error) {
+	e.dryRunActions = append(e.dryRunActions, fmt.Sprintf("%s: %s", tool, action))
+
+	result := map[string]interface{}{
+		"success": true,
+		"dry_run": true,
+		"message": "dry run: would " + action,
+	}
+	for key, value := range fields {
+		result[key] = value
+	}
+	resultBytes, _ := json.Marshal(result)
+	return string(resultBytes), nil
+}
+
+// DryRunActions returns the actions skipped in dry-run mode, in call order
+func (e *Engine) DryRunActions() []string {
+	return e.dryRunActions
+}
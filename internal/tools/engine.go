@@ -3,17 +3,27 @@ package tools
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/mako10k/llmcmd/internal/faultinject"
 	"github.com/mako10k/llmcmd/internal/tools/builtin"
+	"github.com/mako10k/llmcmd/internal/vfsproxy"
 )
 
 // ShellExecutor interface for executing shell commands
@@ -24,6 +34,33 @@ type ShellExecutor interface {
 	SetVFS(vfs VirtualFileSystem)
 }
 
+// EnvShellExecutor is implemented by shell executors that can run a command
+// with an explicit environment (used by spawn's env and clear_env)
+type EnvShellExecutor interface {
+	ExecuteWithEnv(command string, env []string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+// ContextShellExecutor is implemented by shell executors that kill the command
+// when ctx is done (used to enforce the spawn timeout). A nil env inherits the
+// current environment.
+type ContextShellExecutor interface {
+	ExecuteContext(ctx context.Context, command string, env []string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+// VFSShellExecutor is implemented by shell executors that can hand the command a
+// connection to the parent VFS as fd 3, announced in vfsproxy.EnvVar, so llmsh
+// redirections inside spawned scripts read and create virtual files
+type VFSShellExecutor interface {
+	ExecuteWithVFS(ctx context.Context, command string, env []string, stdin io.Reader, stdout, stderr io.Writer, vfsConn *os.File) error
+}
+
+// SyntaxCheckingShellExecutor is implemented by shell executors that can
+// validate a script without running it. spawn rejects a script that fails the
+// check with its error, which locates the problem for the model to fix.
+type SyntaxCheckingShellExecutor interface {
+	CheckSyntax(script string) error
+}
+
 // VirtualFileSystem interface for managing virtual files
 type VirtualFileSystem interface {
 	OpenFile(name string, flag int, perm os.FileMode) (io.ReadWriteCloser, error)
@@ -32,6 +69,12 @@ type VirtualFileSystem interface {
 	ListFiles() []string
 }
 
+// FifoMaker is implemented by virtual file systems that can make named pipes,
+// for open with fifo
+type FifoMaker interface {
+	MakeFifo(name string, perm os.FileMode) error
+}
+
 // isBinaryFile checks if a file is binary by examining its extension and content
 func isBinaryFile(filename string) bool {
 	// Check common binary file extensions
@@ -87,6 +130,10 @@ func isBinaryFile(filename string) bool {
 	return false
 }
 
+// ErrOutputClosed is returned when the reader of stdout has gone away (EPIPE),
+// e.g. when llmcmd output is piped into head
+var ErrOutputClosed = errors.New("stdout closed by reader (broken pipe)")
+
 // RunningCommand tracks a running command and its pipes
 type RunningCommand struct {
 	cmd      *exec.Cmd
@@ -103,6 +150,54 @@ type RunningCommand struct {
 	outputFd    int    // The fd this command writes to
 	pid         int    // Process ID
 	commandName string // Command name for debugging
+
+	// Completion details reported by the wait tool
+	startTime  time.Time     // When the command was started
+	duration   time.Duration // Run time, set once finished
+	stderrTail *tailBuffer   // Last bytes written to stderr
+	exited     chan struct{} // Closed once the command has finished
+	timedOut   bool          // Killed after exceeding the spawn timeout
+	cancel     func()        // Kills the command when the executor supports a context
+}
+
+// timeoutExitCode is reported for commands killed by the spawn timeout, as timeout(1) does
+const timeoutExitCode = 124
+
+// stderrTailSize is how much trailing stderr output wait reports
+const stderrTailSize = 2048
+
+// tailBuffer keeps the last bytes written to it and forwards all writes
+type tailBuffer struct {
+	mu    sync.Mutex
+	data  []byte
+	limit int
+	out   io.Writer
+}
+
+// newTailBuffer creates a tail buffer forwarding writes to out
+func newTailBuffer(limit int, out io.Writer) *tailBuffer {
+	return &tailBuffer{limit: limit, out: out}
+}
+
+// Write records p in the tail and forwards it
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	b.data = append(b.data, p...)
+	if len(b.data) > b.limit {
+		b.data = b.data[len(b.data)-b.limit:]
+	}
+	b.mu.Unlock()
+	if b.out != nil {
+		b.out.Write(p)
+	}
+	return len(p), nil
+}
+
+// String returns the recorded tail
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.data)
 }
 
 // FdDependency represents a file descriptor dependency relationship
@@ -117,6 +212,7 @@ type Engine struct {
 	inputFiles      []*os.File
 	outputFile      *os.File
 	fileDescriptors []interface{}           // Can hold io.Reader, io.Writer, or io.ReadWriter
+	fdMutex         sync.RWMutex            // Protects fileDescriptors, nextFd, fdLabels, fdModes and fdBytes
 	runningCommands map[int]*RunningCommand // Maps fd to running command
 	commandsMutex   sync.RWMutex
 	fdDependencies  []FdDependency // Tracks fd dependencies for spawns and tees
@@ -125,11 +221,37 @@ type Engine struct {
 	nextFd          int            // Next available file descriptor number
 	maxFileSize     int64
 	bufferSize      int
+	spawnTimeout    time.Duration     // Wall-clock limit for spawned commands, 0 for none
+	spawnLimits     SpawnLimits       // Resource limits applied to spawned commands
+	resultThreshold int               // Results larger than this are replaced by handles, 0 to disable
+	resultsMutex    sync.Mutex        // Protects results, resultSeq and notes
+	results         map[string]string // Stored tool results by handle, for fetch
+	resultSeq       int               // Number of handles issued
+	notes           map[string]string // Scratchpad notes kept between turns, for note_write/note_read
+	hooks           []ToolHook        // Receive events around every tool call
+	dryRun          bool              // Report spawn/write/open instead of performing them
+	dryRunActions   []string          // Actions skipped in dry-run mode
+	quiet           bool              // Only show exit messages for failures
+	scratchDir      string            // Real per-run directory exported as $LLMCMD_TMPDIR
+	clock           Clock             // Time source for durations and timeouts
 	stats           ExecutionStats
-	noStdin         bool // Skip reading from stdin
+	statsMutex      sync.Mutex     // Protects stats
+	noStdin         bool           // Skip reading from stdin
+	fdNames         map[string]int // Logical names from the FD mapping ($1, file names, stdin...) to fds
+	fdPaths         map[int]string // Real paths of input files by fd, for re-opening by name
+	fdLabels        map[int]string // Human-readable origin of each fd, shown in fd tables
+	fdModes         map[int]string // Direction of each fd: "r", "w" or "rw"
+	fdBytes         map[int]int64  // Bytes transferred through each fd
+	readyCh         chan struct{}  // Closed and replaced on spawn pipe activity, for poll
+	readyMutex      sync.Mutex
 	// New components for llmsh integration
 	shellExecutor ShellExecutor
 	virtualFS     VirtualFileSystem
+	// Unix socket serving virtual files to spawned scripts, started on first use
+	vfsSocketOnce sync.Once
+	vfsListener   net.Listener
+	vfsSocketDir  string
+	vfsServed     chan struct{} // Closed once the socket's connections are closed
 }
 
 // ExecutionStats tracks tool execution statistics
@@ -138,21 +260,75 @@ type ExecutionStats struct {
 	WriteCalls   int   `json:"write_calls"`
 	SpawnCalls   int   `json:"spawn_calls"`
 	CloseCalls   int   `json:"close_calls"`
+	WaitCalls    int   `json:"wait_calls"`
 	ExitCalls    int   `json:"exit_calls"`
 	BytesRead    int64 `json:"bytes_read"`
 	BytesWritten int64 `json:"bytes_written"`
 	ErrorCount   int   `json:"error_count"`
+
+	Tools map[string]ToolLatency `json:"tools,omitempty"` // Per-tool call counts and latency
+}
+
+// ToolLatency is the call count and time spent in one tool
+type ToolLatency struct {
+	Calls        int           `json:"calls"`
+	TotalLatency time.Duration `json:"total_latency"`
+	MaxLatency   time.Duration `json:"max_latency"`
+}
+
+// AverageLatency returns the mean latency of the tool's calls
+func (l ToolLatency) AverageLatency() time.Duration {
+	if l.Calls == 0 {
+		return 0
+	}
+	return l.TotalLatency / time.Duration(l.Calls)
+}
+
+// SpawnLimits are resource limits (setrlimit) applied to spawned commands.
+// Zero values mean no limit.
+type SpawnLimits struct {
+	CPUSeconds  int   // RLIMIT_CPU
+	MemoryBytes int64 // RLIMIT_AS
+	OpenFiles   int   // RLIMIT_NOFILE
+}
+
+// prelude returns shell commands that apply the limits, or "" if there are none.
+// ulimit without -S/-H sets both limits, so the script cannot raise them again.
+func (l SpawnLimits) prelude() string {
+	var limits []string
+	if l.CPUSeconds > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -t %d", l.CPUSeconds))
+	}
+	if l.MemoryBytes > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -v %d", (l.MemoryBytes+1023)/1024))
+	}
+	if l.OpenFiles > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -n %d", l.OpenFiles))
+	}
+	if len(limits) == 0 {
+		return ""
+	}
+	return "{ " + strings.Join(limits, " && ") + "; } || exit 126\n"
 }
 
 // EngineConfig holds configuration for the tool engine
 type EngineConfig struct {
-	InputFiles    []string
-	OutputFile    string
-	MaxFileSize   int64
-	BufferSize    int
-	NoStdin       bool // Skip reading from stdin
-	ShellExecutor ShellExecutor
-	VirtualFS     VirtualFileSystem
+	InputFiles   []string
+	OutputFile   string
+	MaxFileSize  int64
+	BufferSize   int
+	NoStdin      bool          // Skip reading from stdin
+	SpawnTimeout time.Duration // Kill spawned commands after this long, 0 for no limit
+	SpawnLimits  SpawnLimits   // Resource limits for spawned commands
+	// ResultHandleThreshold is the result size in bytes above which tool results
+	// are stored and returned as a handle plus preview (0 disables handles)
+	ResultHandleThreshold int
+	Hooks                 []ToolHook // Receive structured events around every tool call
+	DryRun                bool       // Report what spawn, write and open would do without side effects
+	Quiet                 bool       // Suppress informational output (exit messages with code 0)
+	Clock                 Clock      // Time source, defaults to the system clock
+	ShellExecutor         ShellExecutor
+	VirtualFS             VirtualFileSystem
 }
 
 // NewEngine creates a new tool execution engine
@@ -161,16 +337,59 @@ func NewEngine(config EngineConfig) (*Engine, error) {
 		maxFileSize:     config.MaxFileSize,
 		bufferSize:      config.BufferSize,
 		noStdin:         config.NoStdin,
+		spawnTimeout:    config.SpawnTimeout,
+		spawnLimits:     config.SpawnLimits,
+		resultThreshold: config.ResultHandleThreshold,
+		results:         make(map[string]string),
+		notes:           make(map[string]string),
+		hooks:           config.Hooks,
+		dryRun:          config.DryRun,
+		quiet:           config.Quiet,
 		runningCommands: make(map[int]*RunningCommand),
 		fdDependencies:  []FdDependency{},
 		closedFds:       make(map[int]bool),
-		nextFd:          10, // Start at 10, reserving 0-9 for standard fds
+		fdNames:         map[string]int{"stdin": 0, "stdout": 1, "stderr": 2},
+		fdPaths:         make(map[int]string),
+		fdLabels:        map[int]string{0: "stdin", 1: "stdout", 2: "stderr"},
+		fdModes:         map[int]string{0: "r", 1: "w", 2: "w"},
+		fdBytes:         make(map[int]int64),
+		readyCh:         make(chan struct{}),
+		clock:           config.Clock,
 		shellExecutor:   config.ShellExecutor,
 		virtualFS:       config.VirtualFS,
 	}
 
+	if engine.clock == nil {
+		engine.clock = systemClock{}
+	}
+
+	// Open output file if specified
+	if config.OutputFile != "" {
+		if config.OutputFile == "-" {
+			// Use stdout for "-"
+			engine.outputFile = os.Stdout
+		} else if config.DryRun {
+			// Writes are only reported, so don't create (and truncate) the file
+			engine.fdLabels[1] = fmt.Sprintf("stdout -> %s (dry run, not created)", config.OutputFile)
+		} else {
+			file, err := os.Create(config.OutputFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create output file %s: %w", config.OutputFile, err)
+			}
+			engine.outputFile = file
+			engine.fdLabels[1] = fmt.Sprintf("stdout -> %s", config.OutputFile)
+		}
+	}
+
+	// Per-run scratch directory for tools that insist on real paths
+	if !config.DryRun {
+		if err := engine.createScratchDir(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Initialize file descriptors array
-	// 0=stdin, 1=stdout, 2=stderr, 3+=input files
+	// 0=stdin, 1=stdout, 2=stderr, 3+=input files (same numbering as the FD mapping message)
 	engine.fileDescriptors = make([]interface{}, 3)
 	if !config.NoStdin {
 		engine.fileDescriptors[0] = os.Stdin
@@ -186,40 +405,74 @@ func NewEngine(config EngineConfig) (*Engine, error) {
 	// Open input files and add to file descriptors
 	for _, filename := range config.InputFiles {
 		if filename == "-" {
-			// "-" means stdin, so add stdin as an additional file descriptor
-			engine.fileDescriptors = append(engine.fileDescriptors, os.Stdin)
-		} else {
-			// Check if file is binary before opening
-			if isBinaryFile(filename) {
-				return nil, fmt.Errorf("binary file detected: %s - llmcmd only supports text files for security and cost reasons", filename)
-			}
+			// "-" means stdin, which is always available as fd 0
+			continue
+		}
 
-			file, err := os.Open(filename)
-			if err != nil {
-				return nil, fmt.Errorf("failed to open input file %s: %w", filename, err)
-			}
-			engine.inputFiles = append(engine.inputFiles, file)
-			engine.fileDescriptors = append(engine.fileDescriptors, file)
+		// Check if file is binary before opening
+		if isBinaryFile(filename) {
+			return nil, fmt.Errorf("binary file detected: %s - llmcmd only supports text files for security and cost reasons", filename)
 		}
-	}
 
-	// Open output file if specified
-	if config.OutputFile != "" {
-		if config.OutputFile == "-" {
-			// Use stdout for "-"
-			engine.outputFile = os.Stdout
-		} else {
-			file, err := os.Create(config.OutputFile)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create output file %s: %w", config.OutputFile, err)
-			}
-			engine.outputFile = file
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open input file %s: %w", filename, err)
 		}
+		engine.inputFiles = append(engine.inputFiles, file)
+		engine.fileDescriptors = append(engine.fileDescriptors, file)
+		fd := len(engine.fileDescriptors) - 1
+		engine.registerInputName(filename, len(engine.inputFiles), fd)
+		engine.fdLabels[fd] = fmt.Sprintf("input file #%d (%s)", len(engine.inputFiles), filename)
+		engine.fdModes[fd] = "r"
 	}
 
+	// fds created by open/spawn are numbered right after the input files
+	engine.nextFd = len(engine.fileDescriptors)
+
 	return engine, nil
 }
 
+// registerInputName records the names under which the FD mapping message refers
+// to an input file: its $N reference, the path as given and its base name
+func (e *Engine) registerInputName(filename string, number int, fd int) {
+	e.fdPaths[fd] = filename
+	e.fdNames[fmt.Sprintf("$%d", number)] = fd
+	e.fdNames[filename] = fd
+	if base := filepath.Base(filename); base != filename {
+		if _, exists := e.fdNames[base]; !exists {
+			e.fdNames[base] = fd
+		}
+	}
+}
+
+// resolveFdName resolves a logical file name ($1, input file path, stdin...) to its fd
+func (e *Engine) resolveFdName(name string) (int, bool) {
+	name = strings.TrimSpace(name)
+	if fd, exists := e.fdNames[name]; exists {
+		return fd, true
+	}
+	// Accept the "fd=3" and "3" spellings used in the FD mapping message
+	if fd, err := strconv.Atoi(strings.TrimPrefix(name, "fd=")); err == nil {
+		return fd, true
+	}
+	return 0, false
+}
+
+// fdArg extracts a file descriptor argument given either as a number or as a logical name
+func (e *Engine) fdArg(args map[string]interface{}, key string) (int, error) {
+	switch v := args[key].(type) {
+	case float64:
+		return int(v), nil
+	case string:
+		if fd, ok := e.resolveFdName(v); ok {
+			return fd, nil
+		}
+		return 0, newToolError(CodeBadFd, "", "unknown file reference %q", v)
+	default:
+		return 0, fmt.Errorf("%s parameter must be a number or file reference", key)
+	}
+}
+
 // addFdDependency adds a new file descriptor dependency relationship
 func (e *Engine) addFdDependency(source int, targets []int, toolType string) {
 	e.chainMutex.Lock()
@@ -241,6 +494,13 @@ func (e *Engine) markFdClosed(fd int) {
 	e.closedFds[fd] = true
 }
 
+// isFdClosed reports whether a file descriptor has been closed
+func (e *Engine) isFdClosed(fd int) bool {
+	e.chainMutex.RLock()
+	defer e.chainMutex.RUnlock()
+	return e.closedFds[fd]
+}
+
 // traverseChainOnEOF traverses the chain when EOF is detected and collects exit codes
 func (e *Engine) traverseChainOnEOF(startFd int) []ChainResult {
 	e.chainMutex.RLock()
@@ -314,17 +574,162 @@ func (e *Engine) traverseChainRecursive(fd int, visited map[int]bool, results *[
 
 // allocateFd allocates a new file descriptor number
 func (e *Engine) allocateFd() int {
-	e.chainMutex.Lock()
-	defer e.chainMutex.Unlock()
+	e.fdMutex.Lock()
+	defer e.fdMutex.Unlock()
 	fd := e.nextFd
 	e.nextFd++
 	return fd
 }
 
+// assignFd allocates a new file descriptor number and registers obj under it
+func (e *Engine) assignFd(obj interface{}, label, direction string) int {
+	fd := e.allocateFd()
+	e.setFdObject(fd, obj)
+
+	e.fdMutex.Lock()
+	e.fdLabels[fd] = label
+	e.fdModes[fd] = direction
+	e.fdMutex.Unlock()
+
+	return fd
+}
+
+// setFdObject registers obj under fd, growing the table as needed
+func (e *Engine) setFdObject(fd int, obj interface{}) {
+	e.fdMutex.Lock()
+	defer e.fdMutex.Unlock()
+	for len(e.fileDescriptors) <= fd {
+		e.fileDescriptors = append(e.fileDescriptors, nil)
+	}
+	e.fileDescriptors[fd] = obj
+}
+
+// fdObject returns the object registered under fd, or nil if there is none
+func (e *Engine) fdObject(fd int) interface{} {
+	e.fdMutex.RLock()
+	defer e.fdMutex.RUnlock()
+	if fd < 0 || fd >= len(e.fileDescriptors) {
+		return nil
+	}
+	return e.fileDescriptors[fd]
+}
+
+// fdObjects returns a snapshot of the fd table
+func (e *Engine) fdObjects() []interface{} {
+	e.fdMutex.RLock()
+	defer e.fdMutex.RUnlock()
+	return append([]interface{}(nil), e.fileDescriptors...)
+}
+
+// fdMode returns the direction of fd: "r", "w", "rw" or "" if unknown
+func (e *Engine) fdMode(fd int) string {
+	e.fdMutex.RLock()
+	defer e.fdMutex.RUnlock()
+	return e.fdModes[fd]
+}
+
+// fdLabel returns the human-readable origin of fd
+func (e *Engine) fdLabel(fd int) string {
+	e.fdMutex.RLock()
+	defer e.fdMutex.RUnlock()
+	return e.fdLabels[fd]
+}
+
+// countRead records n bytes read from fd
+func (e *Engine) countRead(fd, n int) {
+	e.countBytes(int64(n), 0)
+	e.fdMutex.Lock()
+	e.fdBytes[fd] += int64(n)
+	e.fdMutex.Unlock()
+}
+
+// countWrite records n bytes written to fd
+func (e *Engine) countWrite(fd, n int) {
+	e.countBytes(0, int64(n))
+	e.fdMutex.Lock()
+	e.fdBytes[fd] += int64(n)
+	e.fdMutex.Unlock()
+}
+
+// countBytes adds to the byte totals, for transfers that bypass fds
+func (e *Engine) countBytes(read, written int64) {
+	e.statsMutex.Lock()
+	e.stats.BytesRead += read
+	e.stats.BytesWritten += written
+	e.statsMutex.Unlock()
+}
+
+// bytesTransferred returns the total bytes read and written so far
+func (e *Engine) bytesTransferred() int64 {
+	e.statsMutex.Lock()
+	defer e.statsMutex.Unlock()
+	return e.stats.BytesRead + e.stats.BytesWritten
+}
+
+// countCall increments one of the per-tool call counters in stats
+func (e *Engine) countCall(counter *int) {
+	e.statsMutex.Lock()
+	*counter++
+	e.statsMutex.Unlock()
+}
+
+// countError records a failed tool call
+func (e *Engine) countError() {
+	e.statsMutex.Lock()
+	e.stats.ErrorCount++
+	e.statsMutex.Unlock()
+}
+
+// openDirection maps an open mode to the fd direction shown by the fds tool
+func openDirection(mode string) string {
+	switch mode {
+	case "r":
+		return "r"
+	case "w", "a":
+		return "w"
+	default:
+		return "rw"
+	}
+}
+
+// fdTable describes the currently open file descriptors
+func (e *Engine) fdTable() string {
+	e.chainMutex.RLock()
+	defer e.chainMutex.RUnlock()
+
+	e.fdMutex.RLock()
+	defer e.fdMutex.RUnlock()
+
+	var entries []string
+	for fd, obj := range e.fileDescriptors {
+		if obj == nil || e.closedFds[fd] {
+			continue
+		}
+		entries = append(entries, fmt.Sprintf("%d=%s", fd, e.fdLabels[fd]))
+	}
+	if len(entries) == 0 {
+		return "open fds: none"
+	}
+	return "open fds: " + strings.Join(entries, ", ")
+}
+
+// fdError reports a file descriptor error together with the live fd table,
+// so the LLM can recover without guessing fd numbers
+func (e *Engine) fdError(tool string, format string, args ...interface{}) error {
+	e.countError()
+	return newToolError(CodeBadFd, tool, "%s [%s]", fmt.Sprintf(format, args...), e.fdTable())
+}
+
+// fdAccessError reports an fd used in the wrong direction, with the live fd table
+func (e *Engine) fdAccessError(tool string, format string, args ...interface{}) error {
+	e.countError()
+	return newToolError(CodeAccess, tool, "%s [%s]", fmt.Sprintf(format, args...), e.fdTable())
+}
+
 // spawnError creates a standardized spawn error with stats increment
 func (e *Engine) spawnError(message string, err error) (string, error) {
-	e.stats.ErrorCount++
-	return "", fmt.Errorf("spawn: %s: %w", message, err)
+	e.countError()
+	return "", &ToolError{Code: CodeSpawn, Tool: "spawn", Message: message, Err: err}
 }
 
 // spawnSuccess creates a standardized spawn success result
@@ -388,13 +793,8 @@ func (e *Engine) startBackgroundCommand(cmd string, args []string) (int, int, er
 	e.runningCommands[outFd] = runningCmd
 	e.commandsMutex.Unlock()
 
-	// Extend file descriptors array if needed
-	for len(e.fileDescriptors) <= outFd {
-		e.fileDescriptors = append(e.fileDescriptors, nil)
-	}
-
 	// Set up file descriptors for reading/writing
-	e.fileDescriptors[outFd] = outReader // For reading command output
+	e.setFdObject(outFd, outReader) // For reading command output
 
 	// Start goroutine to execute built-in command
 	go func() {
@@ -435,7 +835,7 @@ func (e *Engine) startBackgroundCommand(cmd string, args []string) (int, int, er
 // startBackgroundCommandWithInput starts a command that reads from existing in_fd
 func (e *Engine) startBackgroundCommandWithInput(cmd string, args []string, inputFd int, size int) (int, error) {
 	// Validate input file descriptor
-	if inputFd < 0 || inputFd >= len(e.fileDescriptors) || e.fileDescriptors[inputFd] == nil {
+	if e.fdObject(inputFd) == nil {
 		return 0, fmt.Errorf("invalid input file descriptor: %d", inputFd)
 	}
 
@@ -451,13 +851,8 @@ func (e *Engine) startBackgroundCommandWithInput(cmd string, args []string, inpu
 	// Create and store running command tracker
 	runningCmd := e.createRunningCommand(cmd, args, outFd, inputFd, outFd, nil, outReader)
 
-	// Extend file descriptors array if needed
-	for len(e.fileDescriptors) <= outFd {
-		e.fileDescriptors = append(e.fileDescriptors, nil)
-	}
-
 	// Set up file descriptor for reading command output
-	e.fileDescriptors[outFd] = outReader
+	e.setFdObject(outFd, outReader)
 
 	// Start goroutine to execute built-in command
 	go func() {
@@ -476,7 +871,7 @@ func (e *Engine) startBackgroundCommandWithInput(cmd string, args []string, inpu
 		var inputData []byte
 		if size > 0 {
 			buf := make([]byte, size)
-			reader, ok := e.fileDescriptors[inputFd].(io.Reader)
+			reader, ok := e.fdObject(inputFd).(io.Reader)
 			if !ok {
 				runningCmd.mu.Lock()
 				runningCmd.exitCode = 1
@@ -519,7 +914,7 @@ func (e *Engine) startBackgroundCommandWithInput(cmd string, args []string, inpu
 // startBackgroundCommandWithExistingInput starts a command that reads from existing in_fd (reads all available data)
 func (e *Engine) startBackgroundCommandWithExistingInput(cmd string, args []string, inputFd int) (int, error) {
 	// Validate input file descriptor
-	if inputFd < 0 || inputFd >= len(e.fileDescriptors) || e.fileDescriptors[inputFd] == nil {
+	if e.fdObject(inputFd) == nil {
 		return 0, fmt.Errorf("invalid input file descriptor: %d", inputFd)
 	}
 
@@ -535,13 +930,8 @@ func (e *Engine) startBackgroundCommandWithExistingInput(cmd string, args []stri
 	// Create and store running command tracker
 	runningCmd := e.createRunningCommand(cmd, args, outFd, inputFd, outFd, nil, outReader)
 
-	// Extend file descriptors array if needed
-	for len(e.fileDescriptors) <= outFd {
-		e.fileDescriptors = append(e.fileDescriptors, nil)
-	}
-
 	// Set up file descriptor for reading command output
-	e.fileDescriptors[outFd] = outReader
+	e.setFdObject(outFd, outReader)
 
 	// Start goroutine to execute built-in command
 	go func() {
@@ -565,7 +955,7 @@ func (e *Engine) startBackgroundCommandWithExistingInput(cmd string, args []stri
 			return
 		}
 
-		reader, ok := e.fileDescriptors[inputFd].(io.Reader)
+		reader, ok := e.fdObject(inputFd).(io.Reader)
 		if !ok {
 			runningCmd.mu.Lock()
 			runningCmd.exitCode = 1
@@ -590,7 +980,7 @@ func (e *Engine) startBackgroundCommandWithExistingInput(cmd string, args []stri
 // startBackgroundCommandWithInputOutput starts a command that reads from in_fd and writes to out_fd (pipe chain middle)
 func (e *Engine) startBackgroundCommandWithInputOutput(cmd string, args []string, inputFd int) error {
 	// Validate input file descriptor
-	if inputFd < 0 || inputFd >= len(e.fileDescriptors) || e.fileDescriptors[inputFd] == nil {
+	if e.fdObject(inputFd) == nil {
 		return fmt.Errorf("invalid input file descriptor: %d", inputFd)
 	}
 
@@ -601,7 +991,7 @@ func (e *Engine) startBackgroundCommandWithInputOutput(cmd string, args []string
 // startBackgroundCommandWithOutput starts a command that writes to existing out_fd
 func (e *Engine) startBackgroundCommandWithOutput(cmd string, args []string, outputFd int) (int, error) {
 	// Validate output file descriptor exists
-	if outputFd < 0 || outputFd >= len(e.fileDescriptors) || e.fileDescriptors[outputFd] == nil {
+	if e.fdObject(outputFd) == nil {
 		return 0, fmt.Errorf("invalid output file descriptor: %d", outputFd)
 	}
 
@@ -614,11 +1004,15 @@ func (e *Engine) Close() error {
 	var errors []error
 
 	// Close file descriptors (skip fd 0 as it's managed by the parent process)
-	for i, fdObj := range e.fileDescriptors {
+	for i, fdObj := range e.fdObjects() {
 		if i == 0 {
 			// Skip stdin (fd 0) - managed by parent process
 			continue
 		}
+		if fdObj == os.Stdout || fdObj == os.Stderr {
+			// Process-wide streams stay open for the caller
+			continue
+		}
 		if fdObj != nil {
 			if closer, ok := fdObj.(io.Closer); ok {
 				if err := closer.Close(); err != nil {
@@ -636,12 +1030,21 @@ func (e *Engine) Close() error {
 	}
 
 	// Close output file (this might overlap with fd 1, but Close() is idempotent)
-	if e.outputFile != nil {
+	if e.outputFile != nil && e.outputFile != os.Stdout {
 		if err := e.outputFile.Close(); err != nil {
 			errors = append(errors, err)
 		}
 	}
 
+	if e.scratchDir != "" {
+		if err := os.RemoveAll(e.scratchDir); err != nil {
+			errors = append(errors, err)
+		}
+	}
+	if err := e.closeVFSSocket(); err != nil {
+		errors = append(errors, err)
+	}
+
 	if len(errors) > 0 {
 		return fmt.Errorf("errors closing files: %v", errors)
 	}
@@ -650,27 +1053,66 @@ func (e *Engine) Close() error {
 
 // ExecuteToolCall executes a tool call and returns the result
 func (e *Engine) ExecuteToolCall(toolCall map[string]interface{}) (string, error) {
+	start := e.clock.Now()
+	result, err := e.traceToolCall(toolCall, func() (string, error) {
+		return e.executeToolCall(toolCall)
+	})
+	if name, ok := toolCall["name"].(string); ok && name != "" {
+		e.recordToolLatency(name, e.clock.Now().Sub(start))
+	}
+	return result, err
+}
+
+// recordToolLatency adds one call of the named tool to the statistics
+func (e *Engine) recordToolLatency(name string, latency time.Duration) {
+	e.statsMutex.Lock()
+	defer e.statsMutex.Unlock()
+	if e.stats.Tools == nil {
+		e.stats.Tools = make(map[string]ToolLatency)
+	}
+	entry := e.stats.Tools[name]
+	entry.Calls++
+	entry.TotalLatency += latency
+	entry.MaxLatency = max(entry.MaxLatency, latency)
+	e.stats.Tools[name] = entry
+}
+
+// executeToolCall parses a tool call and executes it
+func (e *Engine) executeToolCall(toolCall map[string]interface{}) (string, error) {
 	// Extract function name
 	functionName, ok := toolCall["name"].(string)
 	if !ok {
-		e.stats.ErrorCount++
+		e.countError()
 		return "", fmt.Errorf("invalid tool call: missing function name")
 	}
 
 	// Extract arguments
 	argsStr, ok := toolCall["arguments"].(string)
 	if !ok {
-		e.stats.ErrorCount++
+		e.countError()
 		return "", fmt.Errorf("invalid tool call: missing arguments")
 	}
 
 	var args map[string]interface{}
 	if err := json.Unmarshal([]byte(argsStr), &args); err != nil {
-		e.stats.ErrorCount++
+		e.countError()
 		return "", fmt.Errorf("invalid tool call arguments: %w", err)
 	}
 
-	// Execute the appropriate function
+	// Simulated slow tool execution for LLMCMD_FAULT_INJECT
+	if delay := faultinject.ToolDelay(); delay > 0 {
+		time.Sleep(delay)
+	}
+
+	result, err := e.executeTool(functionName, args)
+	if err != nil {
+		return result, err
+	}
+	return e.storeLargeResult(functionName, result), nil
+}
+
+// executeTool dispatches a parsed tool call to its implementation
+func (e *Engine) executeTool(functionName string, args map[string]interface{}) (string, error) {
 	switch functionName {
 	case "read":
 		return e.executeRead(args)
@@ -680,35 +1122,52 @@ func (e *Engine) ExecuteToolCall(toolCall map[string]interface{}) (string, error
 		return e.executeOpen(args)
 	case "spawn":
 		return e.executeSpawn(args)
+	case "wait":
+		return e.executeWait(args)
+	case "fds":
+		return e.executeFds(args)
+	case "poll":
+		return e.executePoll(args)
 	case "close":
 		return e.executeClose(args)
 	case "exit":
 		return e.executeExit(args)
 	case "help":
 		return e.executeHelp(args)
+	case "fetch":
+		return e.executeFetch(args)
+	case "copy":
+		return e.executeCopy(args)
+	case "hash":
+		return e.executeHash(args)
+	case "batch":
+		return e.executeBatch(args)
+	case "note_write":
+		return e.executeNoteWrite(args)
+	case "note_read":
+		return e.executeNoteRead(args)
 	default:
-		e.stats.ErrorCount++
+		e.countError()
 		return "", fmt.Errorf("unknown function: %s", functionName)
 	}
 }
 
 // executeRead implements the read tool
 func (e *Engine) executeRead(args map[string]interface{}) (string, error) {
-	e.stats.ReadCalls++
+	e.countCall(&e.stats.ReadCalls)
 
 	// Extract file descriptor
-	fdFloat, ok := args["fd"].(float64)
-	if !ok {
-		e.stats.ErrorCount++
-		return "", fmt.Errorf("read: fd parameter must be a number")
+	fd, err := e.fdArg(args, "fd")
+	if err != nil {
+		e.countError()
+		return "", fmt.Errorf("read: %w", err)
 	}
-	fd := int(fdFloat)
 
 	// Check for lines parameter (alternative to count)
 	if linesFloat, hasLines := args["lines"].(float64); hasLines {
 		lines := int(linesFloat)
 		if lines <= 0 || lines > 1000 {
-			e.stats.ErrorCount++
+			e.countError()
 			return "", fmt.Errorf("read: lines must be between 1 and 1000")
 		}
 		return e.readLines(fd, lines)
@@ -719,29 +1178,31 @@ func (e *Engine) executeRead(args map[string]interface{}) (string, error) {
 	if countFloat, ok := args["count"].(float64); ok {
 		count = int(countFloat)
 		if count <= 0 || count > e.bufferSize {
-			e.stats.ErrorCount++
+			e.countError()
 			return "", fmt.Errorf("read: count must be between 1 and %d", e.bufferSize)
 		}
 	}
 
 	// Get the appropriate reader
 	var reader io.Reader
-	if fd < 0 || fd >= len(e.fileDescriptors) {
-		e.stats.ErrorCount++
-		return "", fmt.Errorf("read: invalid file descriptor %d", fd)
+	if fd < 0 {
+		return "", e.fdError("read", "invalid file descriptor %d", fd)
 	}
 
-	fdObj := e.fileDescriptors[fd]
+	fdObj := e.fdObject(fd)
 	if fdObj == nil {
-		e.stats.ErrorCount++
-		return "", fmt.Errorf("read: file descriptor %d not available", fd)
+		return "", e.fdError("read", "file descriptor %d not available", fd)
 	}
 
 	var readerOk bool
 	reader, readerOk = fdObj.(io.Reader)
 	if !readerOk {
-		e.stats.ErrorCount++
-		return "", fmt.Errorf("read: file descriptor %d is not readable", fd)
+		return "", e.fdAccessError("read", "file descriptor %d is not readable", fd)
+	}
+
+	// A continuation offset reads a file at that position, leaving the fd's own position alone
+	if offsetFloat, hasOffset := args["offset"].(float64); hasOffset {
+		return e.readAt(fd, fdObj, int64(offsetFloat), count)
 	}
 
 	// Read data with blocking I/O
@@ -752,7 +1213,7 @@ func (e *Engine) executeRead(args map[string]interface{}) (string, error) {
 	if err != nil {
 		if err == io.EOF {
 			// EOF is a normal termination condition - report it clearly
-			e.stats.BytesRead += int64(n)
+			e.countRead(fd, n)
 			if n > 0 {
 				// Return partial data with EOF indication
 				return fmt.Sprintf("%s\n--- EOF reached after %d bytes ---", string(buffer[:n]), n), nil
@@ -762,34 +1223,89 @@ func (e *Engine) executeRead(args map[string]interface{}) (string, error) {
 			}
 		} else {
 			// All other errors are failures (Fail-First)
-			e.stats.ErrorCount++
+			e.countError()
 			return "", fmt.Errorf("read: %w", err)
 		}
 	}
 
-	e.stats.BytesRead += int64(n)
+	e.countRead(fd, n)
 	result := string(buffer[:n])
 
+	// Files report where to continue, so large files can be paged through deterministically
+	if seeker, ok := fdObj.(io.Seeker); ok {
+		if pos, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			if next, more := nextOffset(fdObj, pos); more {
+				result += fmt.Sprintf("\n--- more data: next_offset=%d ---", next)
+			}
+		}
+	}
+
 	// Contract: Always return clear information about what was read
 	return result, nil
 }
 
-// executeWrite implements the write tool
-func (e *Engine) executeWrite(args map[string]interface{}) (string, error) {
-	e.stats.WriteCalls++
+// readAt implements read with an offset: count bytes of a file from offset
+func (e *Engine) readAt(fd int, fdObj interface{}, offset int64, count int) (string, error) {
+	readerAt, ok := fdObj.(io.ReaderAt)
+	if _, isFile := fileSize(fdObj); !ok || !isFile {
+		e.countError()
+		return "", newToolError(CodeInvalid, "read", "offset requires a file; fd %d is a stream, read it without offset", fd)
+	}
+	if offset < 0 {
+		e.countError()
+		return "", newToolError(CodeInvalid, "read", "offset must not be negative")
+	}
 
-	// Extract file descriptor
-	fdFloat, ok := args["fd"].(float64)
+	buffer := make([]byte, count)
+	n, err := readerAt.ReadAt(buffer, offset)
+	if err != nil && err != io.EOF {
+		e.countError()
+		return "", fmt.Errorf("read: %w", err)
+	}
+	e.countRead(fd, n)
+
+	next, more := nextOffset(fdObj, offset+int64(n))
+	switch {
+	case n == 0:
+		return "--- EOF: No more data available ---", nil
+	case !more:
+		return fmt.Sprintf("%s\n--- EOF reached after %d bytes ---", string(buffer[:n]), n), nil
+	default:
+		return fmt.Sprintf("%s\n--- more data: next_offset=%d ---", string(buffer[:n]), next), nil
+	}
+}
+
+// nextOffset reports whether a regular file has data after pos
+func nextOffset(fdObj interface{}, pos int64) (int64, bool) {
+	size, isFile := fileSize(fdObj)
+	if !isFile || pos >= size {
+		return 0, false
+	}
+	return pos, true
+}
+
+// fileSize returns the size of fdObj when it is a regular file; pipes and
+// terminals have no offsets to continue from
+func fileSize(fdObj interface{}) (int64, bool) {
+	statter, ok := fdObj.(interface{ Stat() (os.FileInfo, error) })
 	if !ok {
-		e.stats.ErrorCount++
-		return "", fmt.Errorf("write: fd parameter must be a number")
+		return 0, false
 	}
-	fd := int(fdFloat)
+	info, err := statter.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return 0, false
+	}
+	return info.Size(), true
+}
+
+// executeWrite implements the write tool
+func (e *Engine) executeWrite(args map[string]interface{}) (string, error) {
+	e.countCall(&e.stats.WriteCalls)
 
 	// Extract data
 	data, ok := args["data"].(string)
 	if !ok {
-		e.stats.ErrorCount++
+		e.countError()
 		return "", fmt.Errorf("write: data parameter must be a string")
 	}
 
@@ -798,6 +1314,26 @@ func (e *Engine) executeWrite(args map[string]interface{}) (string, error) {
 	if newlineVal, ok := args["newline"].(bool); ok {
 		addNewline = newlineVal
 	}
+	if addNewline {
+		data += "\n"
+	}
+
+	// path is a shortcut for open(path, "a") + write + close
+	if path, hasPath := args["path"].(string); hasPath {
+		if _, hasFd := args["fd"]; hasFd {
+			e.countError()
+			return "", fmt.Errorf("write: specify either fd or path, not both")
+		}
+		return e.appendToPath(path, data)
+	}
+
+	// Extract file descriptor
+	fdFloat, ok := args["fd"].(float64)
+	if !ok {
+		e.countError()
+		return "", fmt.Errorf("write: fd parameter must be a number (or use path)")
+	}
+	fd := int(fdFloat)
 
 	// Extract eof parameter (optional, default false)
 	isEof := false
@@ -809,12 +1345,11 @@ func (e *Engine) executeWrite(args map[string]interface{}) (string, error) {
 	var writer io.Writer
 
 	// First check if it's a special fd (0-2) from fileDescriptors
-	if fd >= 0 && fd < len(e.fileDescriptors) && e.fileDescriptors[fd] != nil {
-		if w, ok := e.fileDescriptors[fd].(io.Writer); ok {
+	if fdObj := e.fdObject(fd); fdObj != nil {
+		if w, ok := fdObj.(io.Writer); ok && e.fdMode(fd) != "r" {
 			writer = w
 		} else {
-			e.stats.ErrorCount++
-			return "", fmt.Errorf("write: file descriptor %d is not writable", fd)
+			return "", e.fdAccessError("write", "file descriptor %d is not writable", fd)
 		}
 	} else {
 		// Check if this is a running command's input fd
@@ -825,29 +1360,30 @@ func (e *Engine) executeWrite(args map[string]interface{}) (string, error) {
 				e.commandsMutex.RUnlock()
 			} else {
 				e.commandsMutex.RUnlock()
-				e.stats.ErrorCount++
-				return "", fmt.Errorf("write: fd %d is not an input fd for a running command", fd)
+				return "", e.fdError("write", "fd %d is not an input fd for a running command", fd)
 			}
 		} else {
 			e.commandsMutex.RUnlock()
-			e.stats.ErrorCount++
-			return "", fmt.Errorf("write: invalid file descriptor %d", fd)
+			return "", e.fdError("write", "invalid file descriptor %d", fd)
 		}
 	}
 
-	// Add newline if requested
-	if addNewline {
-		data += "\n"
+	if e.dryRun {
+		return e.dryRunResult("write", fmt.Sprintf("write %d bytes to fd %d (%s)", len(data), fd, e.fdLabel(fd)),
+			map[string]interface{}{"fd": fd, "bytes": len(data)})
 	}
 
 	// Write data
 	n, err := writer.Write([]byte(data))
 	if err != nil {
-		e.stats.ErrorCount++
+		e.countError()
+		if fd == 1 && errors.Is(err, syscall.EPIPE) {
+			return "", fmt.Errorf("write: %w", ErrOutputClosed)
+		}
 		return "", fmt.Errorf("write: %w", err)
 	}
 
-	e.stats.BytesWritten += int64(n)
+	e.countWrite(fd, n)
 
 	// Handle EOF - trigger chain cleanup if eof is true
 	if isEof {
@@ -883,121 +1419,544 @@ func (e *Engine) executeWrite(args map[string]interface{}) (string, error) {
 	return fmt.Sprintf("wrote %d bytes to fd %d", n, fd), nil
 }
 
+// appendToPath opens a virtual file in append mode, writes data and closes it in one step
+func (e *Engine) appendToPath(path string, data string) (string, error) {
+	if e.dryRun {
+		return e.dryRunResult("write", fmt.Sprintf("append %d bytes to '%s'", len(data), path),
+			map[string]interface{}{"path": path, "bytes": len(data)})
+	}
+
+	if e.virtualFS == nil {
+		e.countError()
+		return "", fmt.Errorf("write: virtual file system not available")
+	}
+
+	file, err := e.virtualFS.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		e.countError()
+		return "", fmt.Errorf("write: failed to open '%s': %w", path, err)
+	}
+
+	n, err := file.Write([]byte(data))
+	closeErr := file.Close()
+	if err != nil {
+		e.countError()
+		return "", fmt.Errorf("write: %w", err)
+	}
+	if closeErr != nil {
+		e.countError()
+		return "", fmt.Errorf("write: failed to close '%s': %w", path, closeErr)
+	}
+
+	e.countBytes(0, int64(n))
+	return fmt.Sprintf("appended %d bytes to '%s'", n, path), nil
+}
+
 // executeSpawn implements the spawn tool using the shell executor
 func (e *Engine) executeSpawn(args map[string]interface{}) (string, error) {
-	e.stats.SpawnCalls++
+	e.countCall(&e.stats.SpawnCalls)
 
 	// Extract script (required)
 	script, ok := args["script"].(string)
 	if !ok {
-		e.stats.ErrorCount++
+		e.countError()
 		return "", fmt.Errorf("spawn: script parameter is required")
 	}
 
 	// Validate script is not empty
 	if strings.TrimSpace(script) == "" {
-		e.stats.ErrorCount++
+		e.countError()
 		return "", fmt.Errorf("spawn: script cannot be empty")
 	}
 
-	// Extract optional parameters
+	// Existing fds to connect directly to the script's stdin/stdout (optional)
 	var inFd *int
 	var outFd *int
 
-	if inFdFloat, hasInFd := args["in_fd"].(float64); hasInFd {
-		inFdInt := int(inFdFloat)
-		inFd = &inFdInt
+	if _, hasInFd := args["in_fd"]; hasInFd {
+		fd, err := e.spawnFdArg(args, "in_fd", "r")
+		if err != nil {
+			return "", err
+		}
+		inFd = &fd
 	}
 
-	if outFdFloat, hasOutFd := args["out_fd"].(float64); hasOutFd {
-		outFdInt := int(outFdFloat)
-		outFd = &outFdInt
+	if _, hasOutFd := args["out_fd"]; hasOutFd {
+		fd, err := e.spawnFdArg(args, "out_fd", "w")
+		if err != nil {
+			return "", err
+		}
+		outFd = &fd
+	}
+
+	captureStderr, _ := args["capture_stderr"].(bool)
+
+	env, err := spawnEnv(args)
+	if err != nil {
+		e.countError()
+		return "", fmt.Errorf("spawn: %w", err)
 	}
 
 	// Use shell executor if available
 	if e.shellExecutor == nil {
-		e.stats.ErrorCount++
-		return "", fmt.Errorf("shell executor not available")
+		e.countError()
+		return "", newToolError(CodeSpawn, "spawn", "shell executor not available")
+	}
+	envExecutor, supportsEnv := e.shellExecutor.(EnvShellExecutor)
+	if env != nil && !supportsEnv {
+		e.countError()
+		return "", newToolError(CodeSpawn, "spawn", "env and clear_env are not supported by this shell executor")
+	}
+	ctxExecutor, supportsContext := e.shellExecutor.(ContextShellExecutor)
+	if e.spawnTimeout > 0 && !supportsContext {
+		e.countError()
+		return "", newToolError(CodeSpawn, "spawn", "spawn timeout is not supported by this shell executor")
+	}
+	if checker, ok := e.shellExecutor.(SyntaxCheckingShellExecutor); ok {
+		if err := checker.CheckSyntax(script); err != nil {
+			e.countError()
+			return "", &ToolError{Code: CodeInvalid, Tool: "spawn", Message: "invalid script", Err: err}
+		}
+	}
+	if supportsContext || supportsEnv {
+		env = e.withScratchEnv(env)
 	}
 
-	// Execute script using shell executor
-	err := e.shellExecutor.Execute(script)
-	if err != nil {
-		e.stats.ErrorCount++
-		return "", fmt.Errorf("failed to execute script '%s': %w", script, err)
+	if e.dryRun {
+		fields := map[string]interface{}{"script": script}
+		if inFd == nil {
+			fields["in_fd"] = e.assignFd(dryRunFd{}, fmt.Sprintf("spawn stdin (%s, dry run)", script), "w")
+		}
+		if outFd == nil {
+			fields["out_fd"] = e.assignFd(dryRunFd{}, fmt.Sprintf("spawn stdout (%s, dry run)", script), "r")
+		}
+		if captureStderr {
+			fields["err_fd"] = e.assignFd(dryRunFd{}, fmt.Sprintf("spawn stderr (%s, dry run)", script), "r")
+		}
+		return e.dryRunResult("spawn", fmt.Sprintf("run script %q", script), fields)
 	}
 
-	// Handle input/output file descriptors if specified
 	result := map[string]interface{}{
 		"success": true,
 	}
 
-	// For now, just return success since shell executor doesn't return output
-	// In the future, we can use ExecuteWithIO for more complex scenarios
+	runningCmd := &RunningCommand{
+		done:        make(chan error, 1),
+		commandName: script,
+		startTime:   e.clock.Now(),
+		stderrTail:  newTailBuffer(stderrTailSize, os.Stderr),
+		exited:      make(chan struct{}),
+	}
+
+	// Child-side pipe ends, closed once the script finishes
+	var childStdin io.Reader
+	childStdout := io.Discard
+	var childEnds []io.Closer
+
+	// Pipe for script input unless an existing in_fd was given
+	if inFd == nil {
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			return e.spawnError("failed to create input pipe", err)
+		}
+		childStdin = reader
+		childEnds = append(childEnds, reader)
+		runningCmd.stdin = writer
+		runningCmd.inputFd = e.assignFd(writer, fmt.Sprintf("spawn stdin (%s)", script), "w")
+		result["in_fd"] = runningCmd.inputFd
+	}
+
+	// Pipe for script output unless an existing out_fd was given
+	if outFd == nil {
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			for _, end := range childEnds {
+				end.Close()
+			}
+			return e.spawnError("failed to create output pipe", err)
+		}
+		childStdout = writer
+		childEnds = append(childEnds, writer)
+		stdout := newPipeReader(reader, e.notifyReady)
+		runningCmd.stdout = stdout
+		runningCmd.outputFd = e.assignFd(stdout, fmt.Sprintf("spawn stdout (%s)", script), "r")
+		result["out_fd"] = runningCmd.outputFd
+	}
+
+	// Optional pipe for script errors, still recorded in the stderr tail for wait
+	if captureStderr {
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			for _, end := range childEnds {
+				end.Close()
+			}
+			return e.spawnError("failed to create error pipe", err)
+		}
+		childEnds = append(childEnds, writer)
+		runningCmd.stderrTail.out = writer
+		stderr := newPipeReader(reader, e.notifyReady)
+		runningCmd.stderr = stderr
+		errFd := e.assignFd(stderr, fmt.Sprintf("spawn stderr (%s)", script), "r")
+		result["err_fd"] = errFd
+	}
 
-	// For compatibility, assign new fds if requested
+	// Connect the given fds directly; data does not pass through read/write calls
+	if inFd != nil {
+		childStdin = e.fdObject(*inFd).(io.Reader)
+		runningCmd.inputFd = *inFd
+	}
+	if outFd != nil {
+		childStdout = e.fdObject(*outFd).(io.Writer)
+		runningCmd.outputFd = *outFd
+	}
+
+	if inFd == nil {
+		runningCmd.pid = runningCmd.inputFd
+	} else {
+		runningCmd.pid = runningCmd.outputFd
+	}
+
+	// Track the command under both of its fds
+	e.commandsMutex.Lock()
+	if inFd == nil {
+		e.runningCommands[runningCmd.inputFd] = runningCmd
+	}
+	if outFd == nil {
+		e.runningCommands[runningCmd.outputFd] = runningCmd
+	}
+	e.commandsMutex.Unlock()
 	if inFd == nil && outFd == nil {
-		// Create pipe-like behavior for background compatibility
-		inNewFd := e.nextFd
-		e.nextFd++
-		outNewFd := e.nextFd
-		e.nextFd++
-
-		result["in_fd"] = inNewFd
-		result["out_fd"] = outNewFd
-	} else if inFd != nil && outFd == nil {
-		outNewFd := e.nextFd
-		e.nextFd++
-		result["out_fd"] = outNewFd
-	} else if inFd == nil && outFd != nil {
-		inNewFd := e.nextFd
-		e.nextFd++
-		result["in_fd"] = inNewFd
+		e.addFdDependency(runningCmd.inputFd, []int{runningCmd.outputFd}, "spawn")
+	}
+
+	// Run the script in the background; the LLM drives it through the returned fds
+	ctx, cancel := context.WithCancel(context.Background())
+	runningCmd.cancel = cancel
+	go func() {
+		stdin, stdout := faultinject.WrapChildInput(childStdin), faultinject.WrapChildOutput(childStdout)
+		var timedOut atomic.Bool
+		if e.spawnTimeout > 0 {
+			go func() {
+				select {
+				case <-e.clock.After(e.spawnTimeout):
+					timedOut.Store(true)
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+		}
+		command := e.spawnLimits.prelude() + script
+		var err error
+		if vfsExecutor, ok := e.shellExecutor.(VFSShellExecutor); ok && e.virtualFS != nil {
+			err = e.executeWithVFS(vfsExecutor, ctx, command, env, stdin, stdout, runningCmd.stderrTail)
+		} else if supportsContext {
+			err = ctxExecutor.ExecuteContext(ctx, command, env, stdin, stdout, runningCmd.stderrTail)
+		} else if env != nil {
+			err = envExecutor.ExecuteWithEnv(command, env, stdin, stdout, runningCmd.stderrTail)
+		} else {
+			err = e.shellExecutor.ExecuteWithIO(command, stdin, stdout, runningCmd.stderrTail)
+		}
+		cancel()
+		for _, end := range childEnds {
+			end.Close()
+		}
+
+		runningCmd.mu.Lock()
+		runningCmd.finished = true
+		runningCmd.exitCode = exitCodeOf(err)
+		if timedOut.Load() {
+			runningCmd.timedOut = true
+			runningCmd.exitCode = timeoutExitCode
+		}
+		runningCmd.duration = e.clock.Now().Sub(runningCmd.startTime)
+		runningCmd.mu.Unlock()
+		close(runningCmd.exited)
+
+		runningCmd.done <- err
+		close(runningCmd.done)
+	}()
+
+	// With both ends connected there is nothing to drive, so run synchronously
+	if inFd != nil && outFd != nil {
+		<-runningCmd.exited
+		result["exit_code"] = runningCmd.exitCode
+		if runningCmd.timedOut {
+			result["timed_out"] = true
+			result["code"] = CodeTimeout
+			result["message"] = fmt.Sprintf("killed after exceeding the %s spawn timeout", e.spawnTimeout)
+		}
+		tail, failure := splitScriptFailure(runningCmd.stderrTail.String())
+		if tail != "" {
+			result["stderr_tail"] = tail
+		}
+		if failure != nil {
+			result["failure"] = failure
+		}
 	}
 
 	return e.spawnSuccess(result)
 }
 
+// spawnFdArg resolves an in_fd/out_fd argument to an open fd with the given direction
+func (e *Engine) spawnFdArg(args map[string]interface{}, key, direction string) (int, error) {
+	fd, err := e.fdArg(args, key)
+	if err != nil {
+		e.countError()
+		return 0, fmt.Errorf("spawn: %w", err)
+	}
+	if e.fdObject(fd) == nil || e.isFdClosed(fd) {
+		return 0, e.fdError("spawn", "%s %d is not an open file descriptor", key, fd)
+	}
+	if !strings.Contains(e.fdMode(fd), direction) {
+		if direction == "r" {
+			return 0, e.fdAccessError("spawn", "in_fd %d is not readable", fd)
+		}
+		return 0, e.fdAccessError("spawn", "out_fd %d is not writable", fd)
+	}
+	return fd, nil
+}
+
+// spawnEnv builds the environment for spawn's env and clear_env arguments.
+// It returns nil when the script should simply inherit the environment.
+func spawnEnv(args map[string]interface{}) ([]string, error) {
+	clearEnv, _ := args["clear_env"].(bool)
+	rawEnv, hasEnv := args["env"]
+	if !hasEnv && !clearEnv {
+		return nil, nil
+	}
+
+	vars := make(map[string]string)
+	if hasEnv {
+		envMap, ok := rawEnv.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("env must be an object of NAME: value pairs")
+		}
+		for name, value := range envMap {
+			if name == "" || strings.ContainsAny(name, "=\x00") {
+				return nil, fmt.Errorf("invalid environment variable name %q", name)
+			}
+			switch value.(type) {
+			case string, float64, bool:
+				vars[name] = fmt.Sprint(value)
+			default:
+				return nil, fmt.Errorf("env %s: value must be a string, number or boolean", name)
+			}
+		}
+	}
+
+	// Not nil even when empty: a nil environment would inherit everything
+	env := []string{}
+	if clearEnv {
+		// Keep PATH so the script can still find commands
+		if path, ok := os.LookupEnv("PATH"); ok {
+			if _, overridden := vars["PATH"]; !overridden {
+				env = append(env, "PATH="+path)
+			}
+		}
+	} else {
+		for _, entry := range os.Environ() {
+			name, _, _ := strings.Cut(entry, "=")
+			if _, overridden := vars[name]; !overridden {
+				env = append(env, entry)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+vars[name])
+	}
+	return env, nil
+}
+
+// executeWithVFS runs a spawned script with a VFS proxy connection, serving its
+// requests against the engine's VFS until the script exits, and announces the
+// engine's VFS socket to it. Without socket support the script runs without
+// the connection.
+func (e *Engine) executeWithVFS(executor VFSShellExecutor, ctx context.Context, command string, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if path := e.vfsSocketPath(); path != "" {
+		if env == nil {
+			env = os.Environ()
+		}
+		env = append(env, vfsproxy.SocketEnvVar+"="+path)
+	}
+	parentEnd, childEnd, err := vfsproxy.Pair()
+	if err != nil {
+		return executor.ExecuteWithVFS(ctx, command, env, stdin, stdout, stderr, nil)
+	}
+
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		vfsproxy.ServeProcesses(parentEnd, e.virtualFS, spawnedProcesses{e})
+	}()
+
+	err = executor.ExecuteWithVFS(ctx, command, env, stdin, stdout, stderr, childEnd)
+	childEnd.Close()
+	// Background children may still hold the child end; don't wait for them
+	parentEnd.SetDeadline(time.Now())
+	<-served
+	parentEnd.Close()
+	return err
+}
+
+// exitCodeOf converts a command error into a process exit code
+func exitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+	// *exec.ExitError and fake processes in tests report their own exit code
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// defaultWaitTimeout and maxWaitTimeout bound how long the wait tool blocks
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 300 * time.Second
+)
+
+// executeWait implements the wait tool - blocks until a spawned command exits
+func (e *Engine) executeWait(args map[string]interface{}) (string, error) {
+	e.countCall(&e.stats.WaitCalls)
+
+	fd, err := e.fdArg(args, "fd")
+	if err != nil {
+		e.countError()
+		return "", fmt.Errorf("wait: %w", err)
+	}
+
+	timeout := defaultWaitTimeout
+	if seconds, ok := args["timeout"].(float64); ok {
+		if seconds < 0 {
+			e.countError()
+			return "", fmt.Errorf("wait: timeout must not be negative")
+		}
+		timeout = time.Duration(seconds * float64(time.Second))
+		if timeout > maxWaitTimeout {
+			timeout = maxWaitTimeout
+		}
+	}
+
+	e.commandsMutex.RLock()
+	runningCmd, exists := e.runningCommands[fd]
+	e.commandsMutex.RUnlock()
+	if !exists || runningCmd.exited == nil {
+		return "", e.fdError("wait", "fd %d does not belong to a spawned command", fd)
+	}
+
+	select {
+	case <-runningCmd.exited:
+	case <-e.clock.After(timeout):
+	}
+
+	tail, failure := splitScriptFailure(runningCmd.stderrTail.String())
+	result := map[string]interface{}{
+		"fd":          fd,
+		"script":      runningCmd.commandName,
+		"stderr_tail": tail,
+	}
+	runningCmd.mu.RLock()
+	result["finished"] = runningCmd.finished
+	if runningCmd.finished {
+		result["exit_code"] = runningCmd.exitCode
+		if failure != nil {
+			result["failure"] = failure
+		}
+		result["duration_ms"] = runningCmd.duration.Milliseconds()
+		if runningCmd.timedOut {
+			result["timed_out"] = true
+			result["code"] = CodeTimeout
+			result["message"] = fmt.Sprintf("killed after exceeding the %s spawn timeout", e.spawnTimeout)
+		}
+	} else {
+		result["duration_ms"] = e.clock.Now().Sub(runningCmd.startTime).Milliseconds()
+		result["code"] = CodeTimeout
+		result["message"] = fmt.Sprintf("still running after %s; call wait again or close its fds", timeout)
+	}
+	runningCmd.mu.RUnlock()
+
+	resultBytes, _ := json.Marshal(result)
+	return string(resultBytes), nil
+}
+
+// fdInfo describes one open file descriptor in the fds tool result
+type fdInfo struct {
+	Fd        int    `json:"fd"`
+	Origin    string `json:"origin"`
+	Direction string `json:"direction"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// executeFds implements the fds tool - lists open file descriptors
+func (e *Engine) executeFds(args map[string]interface{}) (string, error) {
+	e.chainMutex.RLock()
+	e.fdMutex.RLock()
+	fds := []fdInfo{}
+	for fd, obj := range e.fileDescriptors {
+		if obj == nil || e.closedFds[fd] {
+			continue
+		}
+		fds = append(fds, fdInfo{
+			Fd:        fd,
+			Origin:    e.fdLabels[fd],
+			Direction: e.fdModes[fd],
+			Bytes:     e.fdBytes[fd],
+		})
+	}
+	e.fdMutex.RUnlock()
+	e.chainMutex.RUnlock()
+
+	resultBytes, _ := json.Marshal(map[string]interface{}{"fds": fds})
+	return string(resultBytes), nil
+}
+
 // executeClose implements the close tool - explicitly closes file descriptors
 func (e *Engine) executeClose(args map[string]interface{}) (string, error) {
-	e.stats.CloseCalls++
+	e.countCall(&e.stats.CloseCalls)
 
 	// Extract file descriptor
 	fdFloat, ok := args["fd"].(float64)
 	if !ok {
-		e.stats.ErrorCount++
+		e.countError()
 		return "", fmt.Errorf("close: fd parameter must be a number")
 	}
 	fd := int(fdFloat)
 
 	// Validate file descriptor
-	if fd < 0 || fd >= len(e.fileDescriptors) || e.fileDescriptors[fd] == nil {
-		e.stats.ErrorCount++
-		return "", fmt.Errorf("close: invalid file descriptor %d", fd)
+	fdObj := e.fdObject(fd)
+	if fdObj == nil {
+		return "", e.fdError("close", "invalid file descriptor %d", fd)
 	}
 
 	// Check if already closed
 	e.chainMutex.RLock()
 	if e.closedFds[fd] {
 		e.chainMutex.RUnlock()
-		e.stats.ErrorCount++
-		return "", fmt.Errorf("close: file descriptor %d is already closed", fd)
+		e.countError()
+		return "", newToolError(CodeBadFd, "close", "file descriptor %d is already closed", fd)
 	}
 	e.chainMutex.RUnlock()
 
 	// Perform the close operation
-	fdObj := e.fileDescriptors[fd]
 	if closer, ok := fdObj.(io.Closer); ok {
 		if fd < 3 {
 			// Pipeline endpoints (0,1,2): explicit close for flush and EOF notification
 			if err := closer.Close(); err != nil {
-				e.stats.ErrorCount++
+				e.countError()
 				return "", fmt.Errorf("close: error closing fd %d: %w", fd, err)
 			}
 		} else {
 			// Internal fds (3+): should already be auto-closed, but allow explicit close
 			if err := closer.Close(); err != nil {
-				e.stats.ErrorCount++
+				e.countError()
 				return "", fmt.Errorf("close: error closing fd %d: %w", fd, err)
 			}
 		}
@@ -1032,12 +1991,12 @@ func getSupportedCommands() []string {
 
 // executeExit implements the exit tool
 func (e *Engine) executeExit(args map[string]interface{}) (string, error) {
-	e.stats.ExitCalls++
+	e.countCall(&e.stats.ExitCalls)
 
 	// Extract exit code
 	codeFloat, ok := args["code"].(float64)
 	if !ok {
-		e.stats.ErrorCount++
+		e.countError()
 		return "", fmt.Errorf("exit: code parameter must be a number")
 	}
 	code := int(codeFloat)
@@ -1048,12 +2007,17 @@ func (e *Engine) executeExit(args map[string]interface{}) (string, error) {
 		message = msg
 	}
 
-	if message != "" {
+	if message != "" && (!e.quiet || code != 0) {
 		fmt.Fprintf(os.Stderr, "%s\n", message)
 	}
 
+	result := fmt.Sprintf("Exit requested with code %d", code)
+	if reaped := e.reapCommands(); len(reaped) > 0 {
+		result += "; " + reapSummary(reaped)
+	}
+
 	// Return a special error to indicate exit request instead of calling os.Exit directly
-	return fmt.Sprintf("Exit requested with code %d", code), fmt.Errorf("EXIT_REQUESTED:%d", code)
+	return result, fmt.Errorf("EXIT_REQUESTED:%d", code)
 }
 
 // executeOpen handles virtual file operations using the VFS
@@ -1061,12 +2025,12 @@ func (e *Engine) executeOpen(args map[string]interface{}) (string, error) {
 	// Extract required path parameter
 	pathVal, ok := args["path"]
 	if !ok {
-		e.stats.ErrorCount++
+		e.countError()
 		return "", fmt.Errorf("missing required parameter: path")
 	}
 	path, ok := pathVal.(string)
 	if !ok {
-		e.stats.ErrorCount++
+		e.countError()
 		return "", fmt.Errorf("path must be a string")
 	}
 
@@ -1095,60 +2059,165 @@ func (e *Engine) executeOpen(args map[string]interface{}) (string, error) {
 	case "a+":
 		flag = os.O_RDWR | os.O_CREATE | os.O_APPEND
 	default:
-		e.stats.ErrorCount++
+		e.countError()
 		return "", fmt.Errorf("invalid mode: %s (valid modes: r, w, a, r+, w+, a+)", mode)
 	}
 
+	fifo, _ := args["fifo"].(bool)
+	if fifo && mode != "r" && mode != "w" {
+		e.countError()
+		return "", fmt.Errorf("open: a named pipe is opened with mode 'r' or 'w', not '%s'", mode)
+	}
+
+	// Names from the FD mapping ($1, input file paths, stdin...) refer to existing fds
+	if fd, ok := e.fdNames[strings.TrimSpace(path)]; ok {
+		return e.openByName(path, fd, flag)
+	}
+
+	// Reading has no side effects; anything that may create or truncate is only reported
+	if e.dryRun && (mode != "r" || fifo) {
+		fd := e.assignFd(dryRunFd{}, fmt.Sprintf("virtual file '%s' (mode %s, dry run)", path, mode), openDirection(mode))
+		return e.dryRunResult("open", fmt.Sprintf("open '%s' with mode '%s'", path, mode), map[string]interface{}{"fd": fd})
+	}
+
+	// The scratch directory holds real files, shared with spawned scripts
+	if realPath, isScratch, err := e.scratchPath(path); isScratch {
+		if err == nil && fifo {
+			err = fmt.Errorf("named pipes are virtual files, not scratch files")
+		}
+		if err != nil {
+			e.countError()
+			return "", fmt.Errorf("open: %w", err)
+		}
+		file, err := os.OpenFile(realPath, flag, perm)
+		if err != nil {
+			e.countError()
+			return "", fmt.Errorf("failed to open file '%s': %w", path, err)
+		}
+		fd := e.assignFd(file, fmt.Sprintf("scratch file '%s' (mode %s)", filepath.Base(realPath), mode), openDirection(mode))
+		return fmt.Sprintf("Opened scratch file '%s' with mode '%s', assigned fd=%d", realPath, mode, fd), nil
+	}
+
 	// Use VFS to open the file
 	if e.virtualFS == nil {
-		e.stats.ErrorCount++
-		return "", fmt.Errorf("virtual file system not available")
+		e.countError()
+		return "", newToolError(CodeIO, "open", "virtual file system not available")
+	}
+
+	created := ""
+	if fifo {
+		maker, ok := e.virtualFS.(FifoMaker)
+		if !ok {
+			e.countError()
+			return "", newToolError(CodeIO, "open", "named pipes are not supported")
+		}
+		// An existing file or pipe is opened as it is
+		if err := maker.MakeFifo(path, perm); err == nil {
+			created = "Created named pipe '" + path + "'. "
+		} else if !errors.Is(err, os.ErrExist) {
+			e.countError()
+			return "", fmt.Errorf("failed to make named pipe '%s': %w", path, err)
+		}
 	}
 
 	file, err := e.virtualFS.OpenFile(path, flag, perm)
 	if err != nil {
-		e.stats.ErrorCount++
+		e.countError()
 		return "", fmt.Errorf("failed to open file '%s': %w", path, err)
 	}
+	var obj interface{} = file
+	if fifo && mode == "r" {
+		// Drained in the background, so poll can tell whether a read would wait
+		obj = newPipeReader(file, e.notifyReady)
+	}
 
 	// Assign a new file descriptor
-	e.commandsMutex.Lock()
-	fd := e.nextFd
-	e.nextFd++
+	fd := e.assignFd(obj, fmt.Sprintf("virtual file '%s' (mode %s)", path, mode), openDirection(mode))
 
-	// Extend fileDescriptors slice if needed
-	for len(e.fileDescriptors) <= fd {
-		e.fileDescriptors = append(e.fileDescriptors, nil)
+	return fmt.Sprintf("%sOpened file '%s' with mode '%s', assigned fd=%d", created, path, mode, fd), nil
+}
+
+// openByName opens a file known from the FD mapping. Input files are re-opened
+// read-only from the start so they can be read independently of their original fd.
+func (e *Engine) openByName(name string, fd int, flag int) (string, error) {
+	path, isInput := e.fdPaths[fd]
+	if !isInput {
+		return fmt.Sprintf("'%s' is already open as fd=%d", name, fd), nil
 	}
-	e.fileDescriptors[fd] = file
-	e.commandsMutex.Unlock()
 
-	return fmt.Sprintf("Opened file '%s' with mode '%s', assigned fd=%d", path, mode, fd), nil
+	if flag != os.O_RDONLY {
+		e.countError()
+		return "", newToolError(CodeAccess, "open", "input file '%s' (fd=%d) is read-only", name, fd)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		e.countError()
+		return "", fmt.Errorf("failed to open file '%s': %w", name, err)
+	}
+	e.inputFiles = append(e.inputFiles, file)
+	newFd := e.assignFd(file, fmt.Sprintf("%s (re-opened, read-only)", e.fdLabel(fd)), "r")
+
+	return fmt.Sprintf("Opened input file '%s' (%s, also available as fd=%d) with mode 'r', assigned fd=%d", name, path, fd, newFd), nil
+}
+
+// WriteDebugState writes the fd table and the table of spawned commands to w
+func (e *Engine) WriteDebugState(w io.Writer) {
+	fmt.Fprintf(w, "%s\n", e.fdTable())
+
+	e.commandsMutex.RLock()
+	seen := make(map[*RunningCommand]bool)
+	var commands []*RunningCommand
+	for _, cmd := range e.runningCommands {
+		if !seen[cmd] {
+			seen[cmd] = true
+			commands = append(commands, cmd)
+		}
+	}
+	e.commandsMutex.RUnlock()
+	sort.Slice(commands, func(i, j int) bool { return commands[i].pid < commands[j].pid })
+
+	fmt.Fprintf(w, "processes: %d\n", len(commands))
+	for _, cmd := range commands {
+		cmd.mu.RLock()
+		status := "running"
+		if cmd.finished {
+			status = fmt.Sprintf("exited %d", cmd.exitCode)
+		}
+		age := time.Duration(0)
+		if !cmd.startTime.IsZero() {
+			age = e.clock.Now().Sub(cmd.startTime).Round(time.Millisecond)
+		}
+		fmt.Fprintf(w, "  pid=%d in_fd=%d out_fd=%d %s after %v: %s\n",
+			cmd.pid, cmd.inputFd, cmd.outputFd, status, age, cmd.commandName)
+		cmd.mu.RUnlock()
+	}
 }
 
 // GetStats returns current execution statistics
 func (e *Engine) GetStats() ExecutionStats {
-	return e.stats
+	e.statsMutex.Lock()
+	defer e.statsMutex.Unlock()
+	stats := e.stats
+	stats.Tools = maps.Clone(e.stats.Tools)
+	return stats
 }
 
 // readLines reads a specified number of lines from a file descriptor
 func (e *Engine) readLines(fd int, lines int) (string, error) {
 	// Get the appropriate reader
-	if fd < 0 || fd >= len(e.fileDescriptors) {
-		e.stats.ErrorCount++
-		return "", fmt.Errorf("read: invalid file descriptor %d", fd)
+	if fd < 0 {
+		return "", e.fdError("read", "invalid file descriptor %d", fd)
 	}
 
-	fdObj := e.fileDescriptors[fd]
+	fdObj := e.fdObject(fd)
 	if fdObj == nil {
-		e.stats.ErrorCount++
-		return "", fmt.Errorf("read: file descriptor %d not available", fd)
+		return "", e.fdError("read", "file descriptor %d not available", fd)
 	}
 
 	reader, readerOk := fdObj.(io.Reader)
 	if !readerOk {
-		e.stats.ErrorCount++
-		return "", fmt.Errorf("read: file descriptor %d is not readable", fd)
+		return "", e.fdAccessError("read", "file descriptor %d is not readable", fd)
 	}
 
 	var result strings.Builder
@@ -1164,12 +2233,12 @@ func (e *Engine) readLines(fd int, lines int) (string, error) {
 	}
 
 	if err := scanner.Err(); err != nil {
-		e.stats.ErrorCount++
+		e.countError()
 		return "", fmt.Errorf("read: %w", err)
 	}
 
 	resultStr := result.String()
-	e.stats.BytesRead += int64(len(resultStr))
+	e.countRead(fd, len(resultStr))
 	return resultStr, nil
 }
 
@@ -1177,7 +2246,7 @@ func (e *Engine) readLines(fd int, lines int) (string, error) {
 func (e *Engine) executeHelp(args map[string]interface{}) (string, error) {
 	keysInterface, ok := args["keys"].([]interface{})
 	if !ok {
-		e.stats.ErrorCount++
+		e.countError()
 		return "", fmt.Errorf("help: missing or invalid 'keys' parameter")
 	}
 
@@ -1185,7 +2254,7 @@ func (e *Engine) executeHelp(args map[string]interface{}) (string, error) {
 	for i, keyInterface := range keysInterface {
 		key, ok := keyInterface.(string)
 		if !ok {
-			e.stats.ErrorCount++
+			e.countError()
 			return "", fmt.Errorf("help: invalid key at index %d", i)
 		}
 		keys[i] = key
@@ -1197,7 +2266,7 @@ func (e *Engine) executeHelp(args map[string]interface{}) (string, error) {
 	// Call builtin GetHelp function
 	err := builtin.GetHelp(keys, nil, &outputBuf)
 	if err != nil {
-		e.stats.ErrorCount++
+		e.countError()
 		return "", fmt.Errorf("help: %w", err)
 	}
 
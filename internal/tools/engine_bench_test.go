@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newBenchEngine creates an engine over a single input file of size bytes
+func newBenchEngine(b *testing.B, size int) *Engine {
+	b.Helper()
+
+	path := filepath.Join(b.TempDir(), "input.txt")
+	line := strings.Repeat("x", 79) + "\n"
+	if err := os.WriteFile(path, []byte(strings.Repeat(line, size/len(line))), 0644); err != nil {
+		b.Fatalf("Failed to write input file: %v", err)
+	}
+
+	engine, err := NewEngine(EngineConfig{
+		InputFiles: []string{path},
+		BufferSize: 64 * 1024,
+		NoStdin:    true,
+		VirtualFS:  newMemVFS(),
+	})
+	if err != nil {
+		b.Fatalf("Failed to create engine: %v", err)
+	}
+	b.Cleanup(func() { engine.Close() })
+	return engine
+}
+
+func BenchmarkEngineRead(b *testing.B) {
+	const size = 1 << 20
+	b.SetBytes(size)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		engine := newBenchEngine(b, size)
+		b.StartTimer()
+		for {
+			result, err := call(engine, "read", `{"fd": 3, "count": 65536}`)
+			if err != nil {
+				b.Fatalf("read failed: %v", err)
+			}
+			if strings.Contains(result, "--- EOF") {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkEngineWrite(b *testing.B) {
+	engine := newBenchEngine(b, 0)
+	if _, err := call(engine, "open", `{"path": "out.txt", "mode": "w"}`); err != nil {
+		b.Fatalf("open failed: %v", err)
+	}
+
+	data := strings.Repeat("x", 4096)
+	args := fmt.Sprintf(`{"fd": 4, "data": %q}`, data)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := call(engine, "write", args); err != nil {
+			b.Fatalf("write failed: %v", err)
+		}
+	}
+}
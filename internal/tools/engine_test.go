@@ -0,0 +1,1222 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mako10k/llmcmd/internal/vfsproxy"
+)
+
+// memVFS is a minimal in-memory VirtualFileSystem for engine tests
+type memVFS struct {
+	mu    sync.Mutex
+	files map[string]*bytes.Buffer
+}
+
+func newMemVFS() *memVFS {
+	return &memVFS{files: make(map[string]*bytes.Buffer)}
+}
+
+type memFile struct {
+	*bytes.Buffer
+}
+
+func (f memFile) Close() error { return nil }
+
+func (v *memVFS) OpenFile(name string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	buf, exists := v.files[name]
+	if !exists {
+		if flag&os.O_CREATE == 0 {
+			return nil, os.ErrNotExist
+		}
+		buf = &bytes.Buffer{}
+		v.files[name] = buf
+	}
+	if flag&os.O_TRUNC != 0 {
+		buf.Reset()
+	}
+	return memFile{buf}, nil
+}
+
+func (v *memVFS) CreateTemp(pattern string) (io.ReadWriteCloser, string, error) {
+	file, err := v.OpenFile(pattern, os.O_CREATE|os.O_RDWR, 0644)
+	return file, pattern, err
+}
+
+func (v *memVFS) RemoveFile(name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.files, name)
+	return nil
+}
+
+func (v *memVFS) ListFiles() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	var names []string
+	for name := range v.files {
+		names = append(names, name)
+	}
+	return names
+}
+
+// shExecutor runs scripts with /bin/sh for engine tests
+type shExecutor struct{}
+
+func (shExecutor) Execute(command string) error {
+	return exec.Command("sh", "-c", command).Run()
+}
+
+func (shExecutor) ExecuteWithIO(command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+func (shExecutor) ExecuteWithEnv(command string, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = env
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+func (e shExecutor) ExecuteContext(ctx context.Context, command string, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return e.ExecuteWithVFS(ctx, command, env, stdin, stdout, stderr, nil)
+}
+
+func (shExecutor) ExecuteWithVFS(ctx context.Context, command string, env []string, stdin io.Reader, stdout, stderr io.Writer, vfsConn *os.File) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = env
+	if vfsConn != nil {
+		cmd.ExtraFiles = []*os.File{vfsConn}
+	}
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = time.Second
+	return cmd.Run()
+}
+
+func (shExecutor) SetVFS(vfs VirtualFileSystem) {}
+
+// newTestEngine creates an engine over the given input file contents
+func newTestEngine(t *testing.T, inputs ...string) *Engine {
+	t.Helper()
+
+	dir := t.TempDir()
+	var inputFiles []string
+	for i, content := range inputs {
+		path := filepath.Join(dir, "input"+string(rune('1'+i))+".txt")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write input file: %v", err)
+		}
+		inputFiles = append(inputFiles, path)
+	}
+
+	engine, err := NewEngine(EngineConfig{
+		InputFiles:    inputFiles,
+		BufferSize:    4096,
+		NoStdin:       true,
+		ShellExecutor: shExecutor{},
+		VirtualFS:     newMemVFS(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	t.Cleanup(func() { engine.Close() })
+	return engine
+}
+
+// call executes a tool call with raw JSON arguments
+func call(e *Engine, name, arguments string) (string, error) {
+	return e.ExecuteToolCall(map[string]interface{}{
+		"name":      name,
+		"arguments": arguments,
+	})
+}
+
+func TestReadByFileReference(t *testing.T) {
+	engine := newTestEngine(t, "first\n", "second\n")
+
+	tests := []struct {
+		name     string
+		args     string
+		expected string
+	}{
+		{name: "dollar reference", args: `{"fd": "$2"}`, expected: "second"},
+		{name: "fd spelling", args: `{"fd": "fd=3"}`, expected: "first"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := call(engine, "read", test.args)
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if !strings.Contains(result, test.expected) {
+				t.Errorf("read result = %q, want it to contain %q", result, test.expected)
+			}
+		})
+	}
+
+	if _, err := call(engine, "read", `{"fd": "$9"}`); err == nil {
+		t.Errorf("Expected error for unknown file reference")
+	}
+}
+
+func TestOpenByFileReference(t *testing.T) {
+	engine := newTestEngine(t, "content\n")
+
+	result, err := call(engine, "open", `{"path": "$1"}`)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	if !strings.Contains(result, "assigned fd=") {
+		t.Errorf("open result = %q, want an assigned fd", result)
+	}
+
+	if _, err := call(engine, "open", `{"path": "input1.txt", "mode": "w"}`); err == nil {
+		t.Errorf("Expected error when opening input file for writing")
+	}
+}
+
+// fifoVFS adds named pipes to memVFS
+type fifoVFS struct {
+	*memVFS
+	fifos map[string]*vfsproxy.Fifo
+}
+
+func (v fifoVFS) MakeFifo(name string, perm os.FileMode) error {
+	if _, exists := v.fifos[name]; exists {
+		return os.ErrExist
+	}
+	v.fifos[name] = vfsproxy.NewFifo()
+	return nil
+}
+
+func (v fifoVFS) OpenFile(name string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
+	if fifo, exists := v.fifos[name]; exists {
+		if flag&os.O_WRONLY != 0 {
+			return fifo.OpenWriter(), nil
+		}
+		return fifo.OpenReader(), nil
+	}
+	return v.memVFS.OpenFile(name, flag, perm)
+}
+
+func TestOpenFifo(t *testing.T) {
+	engine := newTestEngine(t)
+	engine.virtualFS = fifoVFS{newMemVFS(), make(map[string]*vfsproxy.Fifo)}
+
+	result, err := call(engine, "open", `{"path": "feed", "mode": "r", "fifo": true}`)
+	if err != nil || !strings.Contains(result, "Created named pipe 'feed'") {
+		t.Fatalf("open(fifo, r) = %q, %v, want the pipe created", result, err)
+	}
+	result, err = call(engine, "open", `{"path": "feed", "mode": "w", "fifo": true}`)
+	if err != nil || strings.Contains(result, "Created") {
+		t.Fatalf("open(fifo, w) = %q, %v, want the existing pipe opened", result, err)
+	}
+	if result, _ := call(engine, "poll", `{"fds": [3], "timeout": 0.01}`); !strings.Contains(result, `"pending"`) {
+		t.Errorf("poll before writing = %s, want pending", result)
+	}
+	call(engine, "write", `{"fd": 4, "data": "hello"}`)
+	call(engine, "close", `{"fd": 4}`)
+	if result, _ := call(engine, "poll", `{"fds": [3], "timeout": 1}`); !strings.Contains(result, `"data"`) {
+		t.Errorf("poll after writing = %s, want data", result)
+	}
+	if result, err := call(engine, "read", `{"fd": 3}`); err != nil || !strings.Contains(result, "hello") {
+		t.Errorf("read = %q, %v, want hello", result, err)
+	}
+
+	if _, err := call(engine, "open", `{"path": "feed", "mode": "r+", "fifo": true}`); err == nil {
+		t.Error("open(fifo, r+) succeeded, want an error")
+	}
+}
+
+func TestWriteToPath(t *testing.T) {
+	engine := newTestEngine(t)
+
+	for _, data := range []string{"one", "two"} {
+		if _, err := call(engine, "write", `{"path": "notes.txt", "data": "`+data+`", "newline": true}`); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+
+	vfs := engine.virtualFS.(*memVFS)
+	if got := vfs.files["notes.txt"].String(); got != "one\ntwo\n" {
+		t.Errorf("notes.txt = %q, want %q", got, "one\ntwo\n")
+	}
+
+	if _, err := call(engine, "write", `{"path": "notes.txt", "fd": 1, "data": "x"}`); err == nil {
+		t.Errorf("Expected error when both fd and path are given")
+	}
+}
+
+func TestFdNumberingFollowsInputFiles(t *testing.T) {
+	engine := newTestEngine(t, "first\n", "second\n")
+
+	result, err := call(engine, "open", `{"path": "scratch.txt", "mode": "w"}`)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	if !strings.Contains(result, "assigned fd=5") {
+		t.Errorf("open result = %q, want fd=5 after two input files", result)
+	}
+
+	_, err = call(engine, "read", `{"fd": 42}`)
+	if err == nil {
+		t.Fatalf("Expected error for invalid fd")
+	}
+	if !strings.Contains(err.Error(), "open fds: 1=stdout") || !strings.Contains(err.Error(), "5=") {
+		t.Errorf("error = %q, want it to list the open fd table", err.Error())
+	}
+}
+
+func TestWaitReportsExitStatus(t *testing.T) {
+	engine := newTestEngine(t)
+
+	result, err := call(engine, "spawn", `{"script": "echo failing >&2; exit 3"}`)
+	if err != nil {
+		t.Fatalf("spawn failed: %v", err)
+	}
+	var spawned struct {
+		OutFd int `json:"out_fd"`
+	}
+	if err := json.Unmarshal([]byte(result), &spawned); err != nil {
+		t.Fatalf("Failed to parse spawn result %q: %v", result, err)
+	}
+
+	result, err = call(engine, "wait", fmt.Sprintf(`{"fd": %d, "timeout": 10}`, spawned.OutFd))
+	if err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	var waited struct {
+		Finished   bool   `json:"finished"`
+		ExitCode   int    `json:"exit_code"`
+		StderrTail string `json:"stderr_tail"`
+	}
+	if err := json.Unmarshal([]byte(result), &waited); err != nil {
+		t.Fatalf("Failed to parse wait result %q: %v", result, err)
+	}
+	if !waited.Finished || waited.ExitCode != 3 {
+		t.Errorf("wait result = %q, want finished with exit code 3", result)
+	}
+	if !strings.Contains(waited.StderrTail, "failing") {
+		t.Errorf("stderr_tail = %q, want it to contain %q", waited.StderrTail, "failing")
+	}
+
+	if _, err := call(engine, "wait", `{"fd": 1}`); err == nil {
+		t.Errorf("Expected error when waiting on a non-spawn fd")
+	}
+}
+
+func TestWaitReportsScriptFailure(t *testing.T) {
+	engine := newTestEngine(t)
+
+	trailer := ScriptFailure{Command: "grep x missing", Line: 2, ExitCode: 1, Message: "grep: missing: no such file"}.Trailer()
+	args, _ := json.Marshal(map[string]string{"script": "echo failing >&2; printf '%s' '" + trailer + "' >&2; exit 1"})
+	result, err := call(engine, "spawn", string(args))
+	if err != nil {
+		t.Fatalf("spawn failed: %v", err)
+	}
+	var spawned struct {
+		OutFd int `json:"out_fd"`
+	}
+	if err := json.Unmarshal([]byte(result), &spawned); err != nil {
+		t.Fatalf("Failed to parse spawn result %q: %v", result, err)
+	}
+
+	result, err = call(engine, "wait", fmt.Sprintf(`{"fd": %d, "timeout": 10}`, spawned.OutFd))
+	if err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	var waited struct {
+		StderrTail string         `json:"stderr_tail"`
+		Failure    *ScriptFailure `json:"failure"`
+	}
+	if err := json.Unmarshal([]byte(result), &waited); err != nil {
+		t.Fatalf("Failed to parse wait result %q: %v", result, err)
+	}
+	if waited.Failure == nil || waited.Failure.Command != "grep x missing" || waited.Failure.Line != 2 {
+		t.Errorf("wait result = %q, want the script failure", result)
+	}
+	if waited.StderrTail != "failing\n" {
+		t.Errorf("stderr_tail = %q, want it without the trailer", waited.StderrTail)
+	}
+}
+
+func TestSplitScriptFailure(t *testing.T) {
+	if rest, failure := splitScriptFailure("plain error\n"); rest != "plain error\n" || failure != nil {
+		t.Errorf("splitScriptFailure without a trailer = %q, %v", rest, failure)
+	}
+	if rest, failure := splitScriptFailure(ScriptFailurePrefix + "{broken\n"); failure != nil || rest != ScriptFailurePrefix+"{broken\n" {
+		t.Errorf("splitScriptFailure with a broken trailer = %q, %v", rest, failure)
+	}
+	rest, failure := splitScriptFailure("a\n" + ScriptFailure{ExitCode: 2, Line: 3}.Trailer())
+	if rest != "a\n" || failure == nil || failure.ExitCode != 2 || failure.Line != 3 {
+		t.Errorf("splitScriptFailure = %q, %+v", rest, failure)
+	}
+}
+
+func TestSpawnTimeoutKillsScript(t *testing.T) {
+	engine := newTestEngine(t)
+	engine.spawnTimeout = 200 * time.Millisecond
+
+	result, err := call(engine, "spawn", `{"script": "while :; do :; done"}`)
+	if err != nil {
+		t.Fatalf("spawn failed: %v", err)
+	}
+	var spawned struct {
+		OutFd int `json:"out_fd"`
+	}
+	if err := json.Unmarshal([]byte(result), &spawned); err != nil {
+		t.Fatalf("Failed to parse spawn result %q: %v", result, err)
+	}
+
+	result, err = call(engine, "wait", fmt.Sprintf(`{"fd": %d, "timeout": 10}`, spawned.OutFd))
+	if err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	var waited struct {
+		Finished bool `json:"finished"`
+		ExitCode int  `json:"exit_code"`
+		TimedOut bool `json:"timed_out"`
+	}
+	if err := json.Unmarshal([]byte(result), &waited); err != nil {
+		t.Fatalf("Failed to parse wait result %q: %v", result, err)
+	}
+	if !waited.Finished || !waited.TimedOut || waited.ExitCode != timeoutExitCode {
+		t.Errorf("wait result = %q, want finished and timed out with exit code %d", result, timeoutExitCode)
+	}
+}
+
+func TestFdsListsOpenDescriptors(t *testing.T) {
+	engine := newTestEngine(t, "hello\n")
+
+	if _, err := call(engine, "read", `{"fd": 3}`); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if _, err := call(engine, "open", `{"path": "out.txt", "mode": "w"}`); err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+
+	result, err := call(engine, "fds", `{}`)
+	if err != nil {
+		t.Fatalf("fds failed: %v", err)
+	}
+	var listed struct {
+		Fds []fdInfo `json:"fds"`
+	}
+	if err := json.Unmarshal([]byte(result), &listed); err != nil {
+		t.Fatalf("Failed to parse fds result %q: %v", result, err)
+	}
+
+	byFd := make(map[int]fdInfo)
+	for _, info := range listed.Fds {
+		byFd[info.Fd] = info
+	}
+	if info := byFd[3]; info.Direction != "r" || info.Bytes != 6 || !strings.HasPrefix(info.Origin, "input file #1") {
+		t.Errorf("fd 3 = %+v, want readable input file #1 with 6 bytes read", info)
+	}
+	if info := byFd[4]; info.Direction != "w" || !strings.Contains(info.Origin, "out.txt") {
+		t.Errorf("fd 4 = %+v, want writable virtual file out.txt", info)
+	}
+	if _, listedStdin := byFd[0]; listedStdin {
+		t.Errorf("stdin should not be listed when NoStdin is set")
+	}
+}
+
+func TestPollSpawnedPipes(t *testing.T) {
+	engine := newTestEngine(t)
+
+	result, err := call(engine, "spawn", `{"script": "echo oops >&2; sleep 0.2; echo done", "capture_stderr": true}`)
+	if err != nil {
+		t.Fatalf("spawn failed: %v", err)
+	}
+	var spawned struct {
+		OutFd int `json:"out_fd"`
+		ErrFd int `json:"err_fd"`
+	}
+	if err := json.Unmarshal([]byte(result), &spawned); err != nil {
+		t.Fatalf("Failed to parse spawn result %q: %v", result, err)
+	}
+
+	var polled struct {
+		Fds []pollEntry `json:"fds"`
+	}
+	result, err = call(engine, "poll", fmt.Sprintf(`{"fds": [%d, %d], "timeout": 5}`, spawned.OutFd, spawned.ErrFd))
+	if err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if err := json.Unmarshal([]byte(result), &polled); err != nil {
+		t.Fatalf("Failed to parse poll result %q: %v", result, err)
+	}
+	if polled.Fds[1].Status != "data" {
+		t.Errorf("poll result = %q, want stderr fd to have data first", result)
+	}
+
+	errText, err := call(engine, "read", fmt.Sprintf(`{"fd": %d}`, spawned.ErrFd))
+	if err != nil || !strings.Contains(errText, "oops") {
+		t.Errorf("read stderr = %q, %v, want it to contain %q", errText, err, "oops")
+	}
+
+	if _, err := call(engine, "poll", `{"fds": [42]}`); err == nil {
+		t.Errorf("Expected error when polling an invalid fd")
+	}
+}
+
+func TestWriteToClosedStdout(t *testing.T) {
+	engine := newTestEngine(t)
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	reader.Close()
+	defer writer.Close()
+	engine.fileDescriptors[1] = writer
+
+	_, err = call(engine, "write", `{"fd": 1, "data": "lost"}`)
+	if !errors.Is(err, ErrOutputClosed) {
+		t.Errorf("write error = %v, want ErrOutputClosed", err)
+	}
+}
+
+func TestSpawnEnv(t *testing.T) {
+	t.Setenv("LLMCMD_TEST_INHERITED", "yes")
+	engine := newTestEngine(t)
+
+	tests := []struct {
+		name     string
+		args     string
+		expected string
+	}{
+		{name: "added variable", args: `{"script": "echo $GREETING-$LLMCMD_TEST_INHERITED", "env": {"GREETING": "hi"}}`, expected: "hi-yes"},
+		{name: "cleared environment", args: `{"script": "echo [$LLMCMD_TEST_INHERITED] $N", "env": {"N": 5}, "clear_env": true}`, expected: "[] 5"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := call(engine, "spawn", test.args)
+			if err != nil {
+				t.Fatalf("spawn failed: %v", err)
+			}
+			var spawned struct {
+				OutFd int `json:"out_fd"`
+			}
+			if err := json.Unmarshal([]byte(result), &spawned); err != nil {
+				t.Fatalf("Failed to parse spawn result %q: %v", result, err)
+			}
+			output, err := call(engine, "read", fmt.Sprintf(`{"fd": %d}`, spawned.OutFd))
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if !strings.Contains(output, test.expected) {
+				t.Errorf("output = %q, want it to contain %q", output, test.expected)
+			}
+		})
+	}
+
+	if _, err := call(engine, "spawn", `{"script": "true", "env": {"A=B": "x"}}`); err == nil {
+		t.Errorf("Expected error for invalid variable name")
+	}
+
+	// Without PATH to keep, a cleared environment is empty, not inherited
+	t.Setenv("PATH", "")
+	os.Unsetenv("PATH")
+	if env, err := spawnEnv(map[string]interface{}{"clear_env": true}); err != nil || env == nil || len(env) != 0 {
+		t.Errorf("spawnEnv(clear_env) without PATH = %q (nil: %v), %v; want an empty environment", env, env == nil, err)
+	}
+}
+
+func TestSpawnLimits(t *testing.T) {
+	engine := newTestEngine(t)
+	engine.spawnLimits = SpawnLimits{CPUSeconds: 5, MemoryBytes: 512 << 20, OpenFiles: 64}
+
+	result, err := call(engine, "spawn", `{"script": "echo $(ulimit -t) $(ulimit -v) $(ulimit -n)"}`)
+	if err != nil {
+		t.Fatalf("spawn failed: %v", err)
+	}
+	var spawned struct {
+		OutFd int `json:"out_fd"`
+	}
+	if err := json.Unmarshal([]byte(result), &spawned); err != nil {
+		t.Fatalf("Failed to parse spawn result %q: %v", result, err)
+	}
+	output, err := call(engine, "read", fmt.Sprintf(`{"fd": %d}`, spawned.OutFd))
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if want := "5 524288 64"; !strings.Contains(output, want) {
+		t.Errorf("output = %q, want it to contain %q", output, want)
+	}
+}
+
+// checkingExecutor is a shExecutor that rejects scripts containing "fi fi"
+type checkingExecutor struct {
+	shExecutor
+}
+
+func (checkingExecutor) CheckSyntax(script string) error {
+	if strings.Contains(script, "fi fi") {
+		return errors.New("syntax error at line 1, column 4: unexpected 'fi'")
+	}
+	return nil
+}
+
+func TestSpawnRejectsInvalidScripts(t *testing.T) {
+	engine := newTestEngine(t)
+	engine.shellExecutor = checkingExecutor{}
+	open := len(engine.fileDescriptors)
+
+	_, err := call(engine, "spawn", `{"script": "fi fi"}`)
+	if ErrorCode(err) != CodeInvalid || !strings.Contains(err.Error(), "line 1, column 4") {
+		t.Errorf("spawn error = %v, want %s with the syntax error", err, CodeInvalid)
+	}
+	if len(engine.fileDescriptors) != open {
+		t.Errorf("rejected spawn opened fds: %d open, want %d", len(engine.fileDescriptors), open)
+	}
+	if _, err := call(engine, "spawn", `{"script": "echo ok"}`); err != nil {
+		t.Errorf("spawn of a valid script failed: %v", err)
+	}
+}
+
+func TestSpawnConnectsExistingFds(t *testing.T) {
+	engine := newTestEngine(t, "hello\n")
+
+	if _, err := call(engine, "open", `{"path": "upper.txt", "mode": "w"}`); err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+
+	result, err := call(engine, "spawn", `{"script": "tr a-z A-Z", "in_fd": "$1", "out_fd": 4}`)
+	if err != nil {
+		t.Fatalf("spawn failed: %v", err)
+	}
+	if !strings.Contains(result, `"exit_code":0`) {
+		t.Errorf("spawn result = %q, want synchronous exit_code 0", result)
+	}
+
+	vfs := engine.virtualFS.(*memVFS)
+	if got := vfs.files["upper.txt"].String(); got != "HELLO\n" {
+		t.Errorf("upper.txt = %q, want %q", got, "HELLO\n")
+	}
+
+	if _, err := call(engine, "spawn", `{"script": "cat", "in_fd": 1}`); err == nil {
+		t.Errorf("Expected error when in_fd is not readable")
+	}
+}
+
+func TestLargeResultsUseHandles(t *testing.T) {
+	content := strings.Repeat("0123456789", 30)
+	engine := newTestEngine(t, content)
+	engine.resultThreshold = 100
+
+	result, err := call(engine, "read", `{"fd": 3}`)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	var stored struct {
+		Handle  string `json:"handle"`
+		Bytes   int    `json:"bytes"`
+		Preview string `json:"preview"`
+	}
+	if err := json.Unmarshal([]byte(result), &stored); err != nil {
+		t.Fatalf("Failed to parse handle result %q: %v", result, err)
+	}
+	if stored.Handle == "" || stored.Bytes != len(content) || !strings.HasPrefix(content, stored.Preview) {
+		t.Fatalf("read result = %q, want a handle for %d bytes", result, len(content))
+	}
+
+	var fetched strings.Builder
+	for offset := 0; ; {
+		result, err := call(engine, "fetch", fmt.Sprintf(`{"handle": %q, "offset": %d}`, stored.Handle, offset))
+		if err != nil {
+			t.Fatalf("fetch failed: %v", err)
+		}
+		var page struct {
+			Data      string `json:"data"`
+			Remaining int    `json:"remaining"`
+		}
+		if err := json.Unmarshal([]byte(result), &page); err != nil {
+			t.Fatalf("Failed to parse fetch result %q: %v", result, err)
+		}
+		if len(page.Data) > 100 {
+			t.Errorf("fetch returned %d bytes, want at most the threshold", len(page.Data))
+		}
+		fetched.WriteString(page.Data)
+		offset += len(page.Data)
+		if page.Remaining == 0 {
+			break
+		}
+	}
+	if fetched.String() != content {
+		t.Errorf("fetched content = %q, want %q", fetched.String(), content)
+	}
+
+	if _, err := call(engine, "fetch", `{"handle": "r99"}`); err == nil {
+		t.Errorf("Expected error for unknown handle")
+	}
+
+	// A length shorter than the next character still returns all of it
+	handle := engine.storeLargeResult("read", strings.Repeat("é", 100))
+	if err := json.Unmarshal([]byte(handle), &stored); err != nil {
+		t.Fatalf("Failed to parse handle result %q: %v", handle, err)
+	}
+	result, err = call(engine, "fetch", fmt.Sprintf(`{"handle": %q, "offset": 0, "length": 1}`, stored.Handle))
+	if err != nil || !strings.Contains(result, `"data":"é"`) || !strings.Contains(result, `"remaining":198`) {
+		t.Errorf("fetch of 1 byte = %q, %v; want one whole character", result, err)
+	}
+
+	// Only the most recent results are kept
+	for i := 0; i < maxStoredResults; i++ {
+		engine.storeLargeResult("read", content)
+	}
+	if _, err := call(engine, "fetch", fmt.Sprintf(`{"handle": %q}`, stored.Handle)); err == nil {
+		t.Errorf("fetch of an evicted handle succeeded")
+	}
+	if len(engine.results) != maxStoredResults {
+		t.Errorf("%d results stored, want %d", len(engine.results), maxStoredResults)
+	}
+}
+
+// recordingHook collects tool events for tests
+type recordingHook struct {
+	starts, ends []ToolEvent
+}
+
+func (h *recordingHook) OnToolStart(event ToolEvent) { h.starts = append(h.starts, event) }
+func (h *recordingHook) OnToolEnd(event ToolEvent)   { h.ends = append(h.ends, event) }
+
+func TestToolHooksReceiveEvents(t *testing.T) {
+	engine := newTestEngine(t, "hello\n")
+	hook := &recordingHook{}
+	engine.hooks = []ToolHook{hook}
+
+	if _, err := call(engine, "read", `{"fd": 3}`); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if _, err := call(engine, "read", `{"fd": 99}`); err == nil {
+		t.Fatalf("Expected error for invalid fd")
+	}
+
+	if len(hook.starts) != 2 || len(hook.ends) != 2 {
+		t.Fatalf("got %d start and %d end events, want 2 each", len(hook.starts), len(hook.ends))
+	}
+	first := hook.ends[0]
+	if first.Tool != "read" || first.Bytes != int64(len("hello\n")) || first.Err != nil {
+		t.Errorf("first end event = %+v, want a successful read of 6 bytes", first)
+	}
+	if first.ArgsDigest == "" || first.ArgsDigest == hook.ends[1].ArgsDigest {
+		t.Errorf("args digests %q and %q should be set and differ", first.ArgsDigest, hook.ends[1].ArgsDigest)
+	}
+	if hook.ends[1].Err == nil {
+		t.Errorf("second end event has no error, want the invalid fd error")
+	}
+}
+
+func TestDryRunHasNoSideEffects(t *testing.T) {
+	dir := t.TempDir()
+	outputPath := filepath.Join(dir, "out.txt")
+	markerPath := filepath.Join(dir, "marker")
+	vfs := newMemVFS()
+
+	engine, err := NewEngine(EngineConfig{
+		OutputFile:    outputPath,
+		BufferSize:    4096,
+		NoStdin:       true,
+		ShellExecutor: shExecutor{},
+		VirtualFS:     vfs,
+		DryRun:        true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	result, err := call(engine, "spawn", fmt.Sprintf(`{"script": "touch %s"}`, markerPath))
+	if err != nil {
+		t.Fatalf("spawn failed: %v", err)
+	}
+	var spawned struct {
+		DryRun bool `json:"dry_run"`
+		InFd   int  `json:"in_fd"`
+		OutFd  int  `json:"out_fd"`
+	}
+	if err := json.Unmarshal([]byte(result), &spawned); err != nil {
+		t.Fatalf("Failed to parse spawn result %q: %v", result, err)
+	}
+	if !spawned.DryRun || spawned.InFd == 0 || spawned.OutFd == 0 {
+		t.Errorf("spawn result = %q, want a dry run with placeholder fds", result)
+	}
+
+	steps := []struct{ tool, args string }{
+		{"write", fmt.Sprintf(`{"fd": %d, "data": "input"}`, spawned.InFd)},
+		{"read", fmt.Sprintf(`{"fd": %d}`, spawned.OutFd)},
+		{"write", `{"fd": 1, "data": "result"}`},
+		{"open", `{"path": "notes.txt", "mode": "w"}`},
+		{"write", `{"path": "log.txt", "data": "entry"}`},
+	}
+	for _, step := range steps {
+		if _, err := call(engine, step.tool, step.args); err != nil {
+			t.Fatalf("%s %s failed: %v", step.tool, step.args, err)
+		}
+	}
+
+	for _, path := range []string{outputPath, markerPath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s exists after a dry run", path)
+		}
+	}
+	if files := vfs.ListFiles(); len(files) != 0 {
+		t.Errorf("virtual files after a dry run = %v, want none", files)
+	}
+	if actions := engine.DryRunActions(); len(actions) != 5 {
+		t.Errorf("DryRunActions() = %q, want 5 actions", actions)
+	}
+}
+
+func TestScratchDirSharedWithSpawn(t *testing.T) {
+	engine := newTestEngine(t)
+	dir := engine.scratchDir
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Fatalf("scratch directory %q not created: %v", dir, err)
+	}
+
+	result, err := call(engine, "spawn", `{"script": "echo scratch > \"$LLMCMD_TMPDIR/note.txt\""}`)
+	if err != nil {
+		t.Fatalf("spawn failed: %v", err)
+	}
+	var spawned struct {
+		OutFd int `json:"out_fd"`
+	}
+	if err := json.Unmarshal([]byte(result), &spawned); err != nil {
+		t.Fatalf("Failed to parse spawn result %q: %v", result, err)
+	}
+	if _, err := call(engine, "wait", fmt.Sprintf(`{"fd": %d, "timeout": 10}`, spawned.OutFd)); err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+
+	result, err = call(engine, "open", `{"path": "$LLMCMD_TMPDIR/note.txt"}`)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	fd := engine.nextFd - 1
+	if output, err := call(engine, "read", fmt.Sprintf(`{"fd": %d}`, fd)); err != nil || !strings.Contains(output, "scratch") {
+		t.Errorf("read(%d) = %q, %v; want the file written by the script (open: %s)", fd, output, err, result)
+	}
+
+	if _, err := call(engine, "open", `{"path": "$LLMCMD_TMPDIR/../escape.txt", "mode": "w"}`); err == nil {
+		if _, statErr := os.Stat(filepath.Join(filepath.Dir(dir), "escape.txt")); statErr == nil {
+			t.Errorf("open escaped the scratch directory")
+		}
+	}
+
+	engine.Close()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("scratch directory %q still exists after Close", dir)
+	}
+}
+
+func TestCopyBetweenFds(t *testing.T) {
+	engine := newTestEngine(t, "0123456789")
+
+	if _, err := call(engine, "open", `{"path": "copy.txt", "mode": "w"}`); err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	dst := engine.nextFd - 1
+
+	result, err := call(engine, "copy", fmt.Sprintf(`{"src_fd": "$1", "dst_fd": %d, "count": 4}`, dst))
+	if err != nil {
+		t.Fatalf("copy with count failed: %v", err)
+	}
+	if !strings.Contains(result, `"bytes":4`) || !strings.Contains(result, `"eof":false`) {
+		t.Errorf("copy with count = %q, want 4 bytes and no EOF", result)
+	}
+	result, err = call(engine, "copy", fmt.Sprintf(`{"src_fd": 3, "dst_fd": %d}`, dst))
+	if err != nil {
+		t.Fatalf("copy failed: %v", err)
+	}
+	if !strings.Contains(result, `"bytes":6`) || !strings.Contains(result, `"eof":true`) {
+		t.Errorf("copy = %q, want the remaining 6 bytes and EOF", result)
+	}
+
+	vfs := engine.virtualFS.(*memVFS)
+	if got := vfs.files["copy.txt"].String(); got != "0123456789" {
+		t.Errorf("copied content = %q, want %q", got, "0123456789")
+	}
+	if stats := engine.GetStats(); stats.BytesRead != 10 || stats.BytesWritten != 10 {
+		t.Errorf("stats = %+v, want 10 bytes read and written", stats)
+	}
+
+	if _, err := call(engine, "copy", `{"src_fd": 1, "dst_fd": 3}`); err == nil {
+		t.Errorf("Expected error when copying from a write-only fd")
+	}
+}
+
+func TestSpawnWritesThroughVFSConnection(t *testing.T) {
+	engine := newTestEngine(t)
+	defer engine.Close()
+
+	// Speak the proxy protocol directly, as llmsh does for "> errors.txt"
+	script := `printf 'HELLO 2\nPUT "errors.txt" trunc 6\nERROR\n' >&3 && head -n 2 <&3`
+	result, err := call(engine, "spawn", fmt.Sprintf(`{"script": %q}`, script))
+	if err != nil {
+		t.Fatalf("spawn failed: %v", err)
+	}
+	var spawned struct {
+		OutFd int `json:"out_fd"`
+	}
+	if err := json.Unmarshal([]byte(result), &spawned); err != nil {
+		t.Fatalf("Failed to parse spawn result %q: %v", result, err)
+	}
+	if output, err := call(engine, "read", fmt.Sprintf(`{"fd": %d}`, spawned.OutFd)); err != nil || !strings.Contains(output, "OK 0") {
+		t.Fatalf("read(%d) = %q, %v; want the proxy response", spawned.OutFd, output, err)
+	}
+	if _, err := call(engine, "wait", fmt.Sprintf(`{"fd": %d, "timeout": 10}`, spawned.OutFd)); err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+
+	if _, err := call(engine, "open", `{"path": "errors.txt"}`); err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	fd := engine.nextFd - 1
+	if output, err := call(engine, "read", fmt.Sprintf(`{"fd": %d}`, fd)); err != nil || !strings.Contains(output, "ERROR") {
+		t.Errorf("read(%d) = %q, %v; want the file created by the script", fd, output, err)
+	}
+}
+
+func TestSpawnAnnouncesVFSSocket(t *testing.T) {
+	engine := newTestEngine(t)
+
+	result, err := call(engine, "spawn", `{"script": "printf %s \"$LLMCMD_VFS_SOCKET\""}`)
+	if err != nil {
+		t.Fatalf("spawn failed: %v", err)
+	}
+	var spawned struct {
+		OutFd int `json:"out_fd"`
+	}
+	if err := json.Unmarshal([]byte(result), &spawned); err != nil {
+		t.Fatalf("Failed to parse spawn result %q: %v", result, err)
+	}
+	if _, err := call(engine, "wait", fmt.Sprintf(`{"fd": %d, "timeout": 10}`, spawned.OutFd)); err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	path := engine.vfsSocketPath()
+	if output, err := call(engine, "read", fmt.Sprintf(`{"fd": %d}`, spawned.OutFd)); err != nil || path == "" || !strings.Contains(output, path) {
+		t.Fatalf("read(%d) = %q, %v; want the socket path %q", spawned.OutFd, output, err, path)
+	}
+
+	// Each connection to the socket is served on its own
+	first, err := vfsproxy.DialSocket(path)
+	if err != nil {
+		t.Fatalf("DialSocket() error = %v", err)
+	}
+	second, err := vfsproxy.DialSocket(path)
+	if err != nil {
+		t.Fatalf("DialSocket() error = %v", err)
+	}
+	if err := first.WriteFile("shared.txt", []byte("hello"), false); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if data, err := second.ReadFile("shared.txt"); err != nil || string(data) != "hello" {
+		t.Errorf("ReadFile() over the second connection = %q, %v", data, err)
+	}
+
+	engine.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket after Close(): stat error = %v, want not exist", err)
+	}
+	if _, err := first.ReadFile("shared.txt"); err == nil {
+		t.Error("ReadFile() after Close() succeeded")
+	}
+}
+
+func TestVFSProcessControl(t *testing.T) {
+	engine := newTestEngine(t)
+
+	result, err := call(engine, "spawn", `{"script": "sleep 30"}`)
+	if err != nil {
+		t.Fatalf("spawn failed: %v", err)
+	}
+	var spawned struct {
+		InFd int `json:"in_fd"`
+	}
+	if err := json.Unmarshal([]byte(result), &spawned); err != nil {
+		t.Fatalf("Failed to parse spawn result %q: %v", result, err)
+	}
+	client, err := vfsproxy.DialSocket(engine.vfsSocketPath())
+	if err != nil {
+		t.Fatalf("DialSocket() error = %v", err)
+	}
+
+	procs, err := client.Processes()
+	if err != nil || len(procs) != 1 || !procs[0].Running || !strings.Contains(procs[0].Command, "sleep 30") {
+		t.Fatalf("Processes() = %+v, %v; want the running sleep", procs, err)
+	}
+	pid := procs[0].PID
+	if pid != spawned.InFd {
+		t.Errorf("PID = %d, want the spawned in_fd %d", pid, spawned.InFd)
+	}
+	if p, err := client.Wait(pid, 10*time.Millisecond); err != nil || !p.Running {
+		t.Errorf("Wait() before KILL = %+v, %v; want still running", p, err)
+	}
+
+	if err := client.Kill(pid, syscall.SIGTERM); err != nil {
+		t.Fatalf("Kill() error = %v", err)
+	}
+	if p, err := client.Wait(pid, 10*time.Second); err != nil || p.Running {
+		t.Errorf("Wait() after KILL = %+v, %v; want exited", p, err)
+	}
+	if err := client.Kill(pid+100, syscall.SIGTERM); err == nil || !strings.Contains(err.Error(), "no such process") {
+		t.Errorf("Kill() of an unknown PID error = %v, want no such process", err)
+	}
+}
+
+func TestHashFdAndPath(t *testing.T) {
+	engine := newTestEngine(t, "hello\n")
+
+	// Hashing by name opens the input file afresh, leaving fd 3 unread
+	result, err := call(engine, "hash", `{"path": "$1"}`)
+	if err != nil {
+		t.Fatalf("hash by path failed: %v", err)
+	}
+	if want := "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"; !strings.Contains(result, want) {
+		t.Errorf("hash(path) = %q, want sha256 %s", result, want)
+	}
+
+	result, err = call(engine, "hash", `{"fd": 3, "algorithm": "md5"}`)
+	if err != nil {
+		t.Fatalf("hash by fd failed: %v", err)
+	}
+	if want := "b1946ac92492d2347c6235b4d2611184"; !strings.Contains(result, want) || !strings.Contains(result, `"bytes":6`) {
+		t.Errorf("hash(fd) = %q, want md5 %s over 6 bytes", result, want)
+	}
+
+	if _, err := call(engine, "hash", `{"fd": 3, "path": "$1"}`); err == nil {
+		t.Error("hash with both fd and path succeeded")
+	}
+	if _, err := call(engine, "hash", `{"fd": 3, "algorithm": "sha1"}`); err == nil {
+		t.Error("hash with an unknown algorithm succeeded")
+	}
+}
+
+func TestToolLatencyStats(t *testing.T) {
+	engine := newTestEngine(t, "a\nb\n")
+
+	for i := 0; i < 2; i++ {
+		call(engine, "read", `{"fd": 3, "lines": 1}`)
+	}
+	call(engine, "fds", `{}`)
+
+	stats := engine.GetStats()
+	if got := stats.Tools["read"].Calls; got != 2 {
+		t.Errorf("read calls = %d, want 2", got)
+	}
+	if got := stats.Tools["fds"].Calls; got != 1 {
+		t.Errorf("fds calls = %d, want 1", got)
+	}
+	read := stats.Tools["read"]
+	if read.MaxLatency > read.TotalLatency || read.AverageLatency() > read.MaxLatency {
+		t.Errorf("read latency = %+v, want max <= total and avg <= max", read)
+	}
+
+	// GetStats returns a copy
+	stats.Tools["read"] = ToolLatency{}
+	if engine.GetStats().Tools["read"].Calls != 2 {
+		t.Errorf("modifying GetStats() result changed the engine statistics")
+	}
+}
+
+func TestBatchRunsCallsInOrder(t *testing.T) {
+	engine := newTestEngine(t)
+
+	result, err := call(engine, "batch", `{"calls": [
+		{"name": "write", "arguments": {"path": "notes.txt", "data": "one"}},
+		{"name": "write", "arguments": {"path": "notes.txt", "data": "two"}},
+		{"name": "read", "arguments": {"fd": 99}},
+		{"name": "write", "arguments": {"path": "notes.txt", "data": "three"}}
+	]}`)
+	if err != nil {
+		t.Fatalf("batch failed: %v", err)
+	}
+	var report struct {
+		Completed  int    `json:"completed"`
+		FailedCall int    `json:"failed_call"`
+		Error      string `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(result), &report); err != nil {
+		t.Fatalf("Failed to parse batch result %q: %v", result, err)
+	}
+	if report.Completed != 2 || report.FailedCall != 2 || report.Error == "" {
+		t.Errorf("batch result = %q, want 2 completed calls and call 2 failed", result)
+	}
+	if got := engine.virtualFS.(*memVFS).files["notes.txt"].String(); got != "onetwo" {
+		t.Errorf("notes.txt = %q, want the calls before the failure only", got)
+	}
+
+	if _, err := call(engine, "batch", `{"calls": [{"name": "batch", "arguments": {"calls": []}}]}`); err == nil {
+		t.Error("nested batch succeeded")
+	}
+	if _, err := call(engine, "batch", `{"calls": [{"name": "exit", "arguments": {"code": 0}}]}`); err == nil || !strings.HasPrefix(err.Error(), "EXIT_REQUESTED:") {
+		t.Errorf("batch with exit returned %v, want the exit request", err)
+	}
+}
+
+func TestNotesPersistBetweenCalls(t *testing.T) {
+	engine := newTestEngine(t, "")
+
+	if _, err := call(engine, "note_write", `{"key": "plan", "value": "count errors"}`); err != nil {
+		t.Fatalf("note_write failed: %v", err)
+	}
+	if _, err := call(engine, "note_write", `{"key": "plan", "value": ", then sort", "append": true}`); err != nil {
+		t.Fatalf("note_write append failed: %v", err)
+	}
+	result, err := call(engine, "note_read", `{"key": "plan"}`)
+	if err != nil || !strings.Contains(result, `"value":"count errors, then sort"`) {
+		t.Errorf("note_read = %q, %v; want the appended note", result, err)
+	}
+	result, err = call(engine, "note_read", `{}`)
+	if err != nil || result != `{"notes":{"plan":23}}` {
+		t.Errorf("note_read listing = %q, %v", result, err)
+	}
+
+	// An empty value removes the note
+	if _, err := call(engine, "note_write", `{"key": "plan", "value": ""}`); err != nil {
+		t.Fatalf("note_write removal failed: %v", err)
+	}
+	if _, err := call(engine, "note_read", `{"key": "plan"}`); err == nil {
+		t.Error("note_read of a removed note succeeded")
+	}
+
+	big := strings.Repeat("x", maxNotesSize)
+	if _, err := call(engine, "note_write", `{"key": "big", "value": "`+big+`"}`); err == nil {
+		t.Error("note_write over the size limit succeeded")
+	}
+}
+
+// TestConcurrentToolCalls runs tool calls from several goroutines; run with
+// -race to check the engine's locking
+func TestConcurrentToolCalls(t *testing.T) {
+	engine := newTestEngine(t, "hello\n")
+
+	const workers = 8
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			calls := [][2]string{
+				{"spawn", `{"script": "echo hi"}`},
+				{"fds", `{}`},
+				{"note_write", fmt.Sprintf(`{"key": "k%d", "value": "v"}`, i)},
+				{"hash", `{"path": "$1"}`},
+				{"note_read", `{}`},
+			}
+			for _, c := range calls {
+				if _, err := call(engine, c[0], c[1]); err != nil {
+					t.Errorf("%s failed: %v", c[0], err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	stats := engine.GetStats()
+	if stats.SpawnCalls != workers || stats.Tools["note_write"].Calls != workers {
+		t.Errorf("stats = %+v, want %d spawn and note_write calls", stats, workers)
+	}
+	if want := int64(workers * len("hello\n")); stats.BytesRead != want {
+		t.Errorf("BytesRead = %d, want %d", stats.BytesRead, want)
+	}
+}
+
+func TestToolErrorCodes(t *testing.T) {
+	engine := newTestEngine(t, "input\n")
+
+	tests := []struct {
+		name string
+		tool string
+		args string
+		code string
+	}{
+		{name: "unknown fd", tool: "read", args: `{"fd": 42}`, code: CodeBadFd},
+		{name: "unknown reference", tool: "read", args: `{"fd": "$9"}`, code: CodeBadFd},
+		{name: "write to input", tool: "write", args: `{"fd": 3, "data": "x"}`, code: CodeAccess},
+		{name: "open input for writing", tool: "open", args: `{"path": "$1", "mode": "w"}`, code: CodeAccess},
+		{name: "missing note", tool: "note_read", args: `{"key": "nope"}`, code: CodeNoEnt},
+		{name: "bad argument", tool: "read", args: `{"fd": 3, "count": -1}`, code: CodeInvalid},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := call(engine, test.tool, test.args)
+			if err == nil {
+				t.Fatalf("expected %s to fail", test.tool)
+			}
+			if code := ErrorCode(err); code != test.code {
+				t.Errorf("ErrorCode(%v) = %s, want %s", err, code, test.code)
+			}
+
+			var result struct {
+				Error struct {
+					Code    string `json:"code"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if jsonErr := json.Unmarshal([]byte(ErrorResult(err)), &result); jsonErr != nil {
+				t.Fatalf("ErrorResult is not JSON: %v", jsonErr)
+			}
+			if result.Error.Code != test.code || result.Error.Message != err.Error() {
+				t.Errorf("ErrorResult = %+v, want code %s and message %q", result.Error, test.code, err.Error())
+			}
+		})
+	}
+}
+
+func TestReadContinuationOffset(t *testing.T) {
+	engine := newTestEngine(t, "0123456789")
+
+	tests := []struct {
+		args     string
+		expected string
+	}{
+		{args: `{"fd": 3, "count": 4}`, expected: "0123\n--- more data: next_offset=4 ---"},
+		{args: `{"fd": 3, "count": 4, "offset": 8}`, expected: "89\n--- EOF reached after 2 bytes ---"},
+		{args: `{"fd": 3, "count": 4, "offset": 2}`, expected: "2345\n--- more data: next_offset=6 ---"},
+		{args: `{"fd": 3, "offset": 10}`, expected: "--- EOF: No more data available ---"},
+		// Offset reads leave the position of sequential reads alone
+		{args: `{"fd": 3, "count": 4}`, expected: "4567\n--- more data: next_offset=8 ---"},
+	}
+
+	for _, test := range tests {
+		result, err := call(engine, "read", test.args)
+		if err != nil {
+			t.Fatalf("read(%s) failed: %v", test.args, err)
+		}
+		if result != test.expected {
+			t.Errorf("read(%s) = %q, want %q", test.args, result, test.expected)
+		}
+	}
+
+	if _, err := call(engine, "read", `{"fd": 3, "offset": -1}`); ErrorCode(err) != CodeInvalid {
+		t.Errorf("negative offset error = %v, want %s", err, CodeInvalid)
+	}
+}
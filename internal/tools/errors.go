@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// Error codes reported in failed tool results, so the LLM can branch on the
+// kind of failure instead of parsing the message
+const (
+	CodeBadFd   = "EBADFD"   // Unknown, closed or mismatched file descriptor
+	CodeEOF     = "EEOF"     // The other end of a stream is already closed
+	CodeTimeout = "ETIMEOUT" // A deadline passed before the operation completed
+	CodeAccess  = "EACCESS"  // The fd or file does not permit the operation
+	CodeSpawn   = "ESPAWN"   // A command could not be started
+	CodeNoEnt   = "ENOENT"   // A file or note does not exist
+	CodeInvalid = "EINVAL"   // Missing or malformed tool arguments
+	CodeIO      = "EIO"      // Any other failure of the underlying operation
+)
+
+// ToolError is a tool failure carrying a machine-readable code
+type ToolError struct {
+	Code    string
+	Tool    string // Tool name, empty when the caller adds it
+	Message string
+	Err     error // Underlying error, if any
+}
+
+// newToolError creates a ToolError with a formatted message
+func newToolError(code, tool, format string, args ...interface{}) *ToolError {
+	return &ToolError{Code: code, Tool: tool, Message: fmt.Sprintf(format, args...)}
+}
+
+// Error returns the message in the usual "tool: message" form
+func (e *ToolError) Error() string {
+	msg := e.Message
+	if e.Tool != "" {
+		msg = e.Tool + ": " + msg
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error
+func (e *ToolError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCode classifies err: the code of a wrapped ToolError, or one derived
+// from well-known errors. Errors created by argument checks have no
+// underlying error and are reported as EINVAL.
+func ErrorCode(err error) string {
+	var toolErr *ToolError
+	switch {
+	case errors.As(err, &toolErr):
+		return toolErr.Code
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return CodeTimeout
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.ErrClosedPipe),
+		errors.Is(err, os.ErrClosed), errors.Is(err, syscall.EPIPE):
+		return CodeEOF
+	case errors.Is(err, fs.ErrPermission):
+		return CodeAccess
+	case errors.Is(err, fs.ErrNotExist):
+		return CodeNoEnt
+	case errors.Unwrap(err) == nil:
+		return CodeInvalid
+	default:
+		return CodeIO
+	}
+}
+
+// ErrorResult renders a failed tool call as the JSON tool result
+func ErrorResult(err error) string {
+	resultBytes, _ := json.Marshal(map[string]interface{}{
+		"error": map[string]string{
+			"code":    ErrorCode(err),
+			"message": err.Error(),
+		},
+	})
+	return string(resultBytes)
+}
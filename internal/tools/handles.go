@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// resultPreviewSize is how much of a stored result is shown next to its handle
+const resultPreviewSize = 512
+
+// maxStoredResults is how many stored results are kept; older handles are
+// forgotten as new results are stored
+const maxStoredResults = 64
+
+// storeLargeResult replaces a tool result larger than the configured threshold
+// with a short handle and a preview. The full text is kept by the engine (VFS
+// files are consumed on read, so they cannot be paged) and fetched on demand.
+func (e *Engine) storeLargeResult(tool, result string) string {
+	if e.resultThreshold <= 0 || len(result) <= e.resultThreshold || tool == "fetch" || tool == "help" {
+		return result
+	}
+
+	e.resultsMutex.Lock()
+	e.resultSeq++
+	handle := fmt.Sprintf("r%d", e.resultSeq)
+	e.results[handle] = result
+	delete(e.results, fmt.Sprintf("r%d", e.resultSeq-maxStoredResults))
+	e.resultsMutex.Unlock()
+
+	resultBytes, _ := json.Marshal(map[string]interface{}{
+		"handle":  handle,
+		"tool":    tool,
+		"bytes":   len(result),
+		"preview": truncateUTF8(result, resultPreviewSize),
+		"message": fmt.Sprintf("result stored as %s; use fetch(handle, offset, length) to page through it", handle),
+	})
+	return string(resultBytes)
+}
+
+// executeFetch implements the fetch tool - pages through a stored tool result
+func (e *Engine) executeFetch(args map[string]interface{}) (string, error) {
+	handle, ok := args["handle"].(string)
+	if !ok || handle == "" {
+		e.countError()
+		return "", fmt.Errorf("fetch: handle parameter is required")
+	}
+	e.resultsMutex.Lock()
+	result, exists := e.results[handle]
+	e.resultsMutex.Unlock()
+	if !exists {
+		e.countError()
+		return "", fmt.Errorf("fetch: unknown handle %q (only the last %d stored results are kept)", handle, maxStoredResults)
+	}
+
+	offset := 0
+	if value, ok := args["offset"].(float64); ok {
+		offset = int(value)
+	}
+	if offset < 0 || offset > len(result) {
+		e.countError()
+		return "", fmt.Errorf("fetch: offset must be between 0 and %d", len(result))
+	}
+
+	// Pages do not exceed the threshold, except for a single character longer
+	// than length, and fetch results are not stored again
+	length := e.resultThreshold
+	if value, ok := args["length"].(float64); ok {
+		if value <= 0 {
+			e.countError()
+			return "", fmt.Errorf("fetch: length must be positive")
+		}
+		length = min(int(value), e.resultThreshold)
+	}
+
+	data := truncateUTF8(result[offset:], length)
+	if data == "" && offset < len(result) {
+		// Return a whole character, so that fetching always advances
+		_, size := utf8.DecodeRuneInString(result[offset:])
+		data = result[offset : offset+size]
+	}
+	resultBytes, _ := json.Marshal(map[string]interface{}{
+		"handle":    handle,
+		"offset":    offset,
+		"data":      data,
+		"remaining": len(result) - offset - len(data),
+	})
+	return string(resultBytes), nil
+}
+
+// truncateUTF8 returns at most n bytes of s without splitting a UTF-8 sequence
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+)
+
+// newHash returns the hash for a hash tool algorithm name
+func newHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "sha256":
+		return sha256.New(), nil
+	case "md5":
+		return md5.New(), nil
+	case "crc32":
+		return crc32.NewIEEE(), nil
+	default:
+		return nil, fmt.Errorf("unknown algorithm %q (valid: sha256, md5, crc32)", algorithm)
+	}
+}
+
+// executeHash implements the hash tool - checksums an fd or a file without
+// passing its contents through the conversation
+func (e *Engine) executeHash(args map[string]interface{}) (string, error) {
+	algorithm := "sha256"
+	if value, ok := args["algorithm"].(string); ok && value != "" {
+		algorithm = value
+	}
+	h, err := newHash(algorithm)
+	if err != nil {
+		e.countError()
+		return "", fmt.Errorf("hash: %w", err)
+	}
+
+	path, hasPath := args["path"].(string)
+	_, hasFd := args["fd"]
+	if hasPath == hasFd {
+		e.countError()
+		return "", fmt.Errorf("hash: exactly one of fd and path is required")
+	}
+
+	var n int64
+	result := map[string]interface{}{"algorithm": algorithm}
+	if hasFd {
+		fd, err := e.fdArg(args, "fd")
+		if err != nil {
+			e.countError()
+			return "", fmt.Errorf("hash: %w", err)
+		}
+		if !e.isOpenFd(fd) {
+			return "", e.fdError("hash", "invalid file descriptor %d", fd)
+		}
+		reader, ok := e.fdObject(fd).(io.Reader)
+		if !ok || !strings.Contains(e.fdMode(fd), "r") {
+			return "", e.fdAccessError("hash", "file descriptor %d is not readable", fd)
+		}
+		n, err = io.Copy(h, reader)
+		e.countRead(fd, int(n))
+		if err != nil {
+			e.countError()
+			return "", fmt.Errorf("hash: read %d bytes before error: %w", n, err)
+		}
+		result["fd"] = fd
+	} else {
+		file, err := e.openForHash(path)
+		if err != nil {
+			e.countError()
+			return "", fmt.Errorf("hash: %w", err)
+		}
+		n, err = io.Copy(h, file)
+		file.Close()
+		e.countBytes(n, 0)
+		if err != nil {
+			e.countError()
+			return "", fmt.Errorf("hash: read %d bytes before error: %w", n, err)
+		}
+		result["path"] = path
+	}
+
+	result["bytes"] = n
+	result["digest"] = hex.EncodeToString(h.Sum(nil))
+	resultBytes, _ := json.Marshal(result)
+	return string(resultBytes), nil
+}
+
+// openForHash opens path read-only: input files by name and scratch files
+// directly, anything else through the VFS
+func (e *Engine) openForHash(path string) (io.ReadCloser, error) {
+	if fd, ok := e.fdNames[strings.TrimSpace(path)]; ok {
+		if realPath, isInput := e.fdPaths[fd]; isInput {
+			return os.Open(realPath)
+		}
+		return nil, fmt.Errorf("'%s' is open as fd=%d; hash it by fd", path, fd)
+	}
+	if realPath, isScratch, err := e.scratchPath(path); isScratch {
+		if err != nil {
+			return nil, err
+		}
+		return os.Open(realPath)
+	}
+	if e.virtualFS == nil {
+		return nil, fmt.Errorf("virtual file system not available")
+	}
+	return e.virtualFS.OpenFile(path, os.O_RDONLY, 0)
+}
@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// maxNotesSize limits the total size of scratchpad notes kept by the engine
+const maxNotesSize = 64 * 1024
+
+// notesSize returns the total size of the scratchpad notes
+func (e *Engine) notesSize() int {
+	size := 0
+	for key, value := range e.notes {
+		size += len(key) + len(value)
+	}
+	return size
+}
+
+// executeNoteWrite implements the note_write tool - stores, appends to or
+// removes a scratchpad note kept in memory for the rest of the run
+func (e *Engine) executeNoteWrite(args map[string]interface{}) (string, error) {
+	key, ok := args["key"].(string)
+	if !ok || key == "" {
+		e.countError()
+		return "", fmt.Errorf("note_write: key parameter is required")
+	}
+	value, ok := args["value"].(string)
+	if !ok {
+		e.countError()
+		return "", fmt.Errorf("note_write: value parameter is required")
+	}
+	appendValue, _ := args["append"].(bool)
+
+	e.resultsMutex.Lock()
+	defer e.resultsMutex.Unlock()
+	if appendValue {
+		value = e.notes[key] + value
+	}
+	if value == "" {
+		delete(e.notes, key)
+	} else {
+		old, exists := e.notes[key]
+		size := e.notesSize() + len(value)
+		if exists {
+			size -= len(old)
+		} else {
+			size += len(key)
+		}
+		if size > maxNotesSize {
+			e.countError()
+			return "", fmt.Errorf("note_write: notes would exceed %d bytes; remove notes or keep larger data in a virtual file", maxNotesSize)
+		}
+		e.notes[key] = value
+	}
+
+	resultBytes, _ := json.Marshal(map[string]interface{}{
+		"key":   key,
+		"bytes": len(value),
+		"notes": len(e.notes),
+	})
+	return string(resultBytes), nil
+}
+
+// executeNoteRead implements the note_read tool - returns one scratchpad note,
+// or the keys and sizes of all notes when no key is given
+func (e *Engine) executeNoteRead(args map[string]interface{}) (string, error) {
+	key, _ := args["key"].(string)
+
+	e.resultsMutex.Lock()
+	defer e.resultsMutex.Unlock()
+	if key == "" {
+		sizes := make(map[string]int, len(e.notes))
+		for key, value := range e.notes {
+			sizes[key] = len(value)
+		}
+		resultBytes, _ := json.Marshal(map[string]interface{}{"notes": sizes})
+		return string(resultBytes), nil
+	}
+
+	value, exists := e.notes[key]
+	if !exists {
+		e.countError()
+		return "", newToolError(CodeNoEnt, "note_read", "no note %q", key)
+	}
+	resultBytes, _ := json.Marshal(map[string]interface{}{
+		"key":   key,
+		"value": value,
+	})
+	return string(resultBytes), nil
+}
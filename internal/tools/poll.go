@@ -0,0 +1,211 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// pipeBufferLimit caps how much unread pipe output is buffered before the child blocks
+const pipeBufferLimit = 1 << 20
+
+// pipeReader drains a pipe in the background so the poll tool can tell
+// whether a read would return data, EOF, or block
+type pipeReader struct {
+	src    io.ReadCloser
+	notify func() // Called whenever data or EOF becomes available
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	buf  []byte
+	err  error // io.EOF or the read error that ended the pipe
+}
+
+// newPipeReader starts draining src, calling notify on every state change
+func newPipeReader(src io.ReadCloser, notify func()) *pipeReader {
+	p := &pipeReader{src: src, notify: notify}
+	p.cond = sync.NewCond(&p.mu)
+	go p.pump()
+	return p
+}
+
+// pump copies data from the pipe into the buffer until EOF or error
+func (p *pipeReader) pump() {
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := p.src.Read(chunk)
+
+		p.mu.Lock()
+		for n > 0 && len(p.buf) >= pipeBufferLimit && p.err == nil {
+			p.cond.Wait()
+		}
+		p.buf = append(p.buf, chunk[:n]...)
+		if err != nil && p.err == nil {
+			p.err = err
+		}
+		p.cond.Broadcast()
+		p.mu.Unlock()
+
+		if p.notify != nil {
+			p.notify()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Read returns buffered data, blocking until some is available or the pipe ends
+func (p *pipeReader) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.buf) == 0 && p.err == nil {
+		p.cond.Wait()
+	}
+	if len(p.buf) == 0 {
+		return 0, p.err
+	}
+	n := copy(b, p.buf)
+	p.buf = p.buf[n:]
+	p.cond.Broadcast()
+	return n, nil
+}
+
+// Close closes the pipe and wakes up any blocked readers
+func (p *pipeReader) Close() error {
+	err := p.src.Close()
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = io.EOF
+	}
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	return err
+}
+
+// status reports "data", "eof" or "pending" and the number of buffered bytes
+func (p *pipeReader) status() (string, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case len(p.buf) > 0:
+		return "data", len(p.buf)
+	case p.err != nil:
+		return "eof", 0
+	default:
+		return "pending", 0
+	}
+}
+
+// notifyReady wakes up poll calls waiting for pipe activity
+func (e *Engine) notifyReady() {
+	e.readyMutex.Lock()
+	close(e.readyCh)
+	e.readyCh = make(chan struct{})
+	e.readyMutex.Unlock()
+}
+
+// readyChannel returns the channel closed on the next pipe activity
+func (e *Engine) readyChannel() chan struct{} {
+	e.readyMutex.Lock()
+	defer e.readyMutex.Unlock()
+	return e.readyCh
+}
+
+// pollEntry is the readiness of one fd in the poll tool result
+type pollEntry struct {
+	Fd       int    `json:"fd"`
+	Status   string `json:"status"` // data, eof, pending, or ready (reads do not wait for a pipe)
+	Buffered int    `json:"buffered,omitempty"`
+}
+
+// defaultPollTimeout and maxPollTimeout bound how long the poll tool blocks
+const (
+	defaultPollTimeout = 10 * time.Second
+	maxPollTimeout     = 300 * time.Second
+)
+
+// executePoll implements the poll tool - waits until one of several fds is readable
+func (e *Engine) executePoll(args map[string]interface{}) (string, error) {
+	fdList, ok := args["fds"].([]interface{})
+	if !ok || len(fdList) == 0 {
+		e.countError()
+		return "", fmt.Errorf("poll: fds must be a non-empty list")
+	}
+
+	var fds []int
+	for i := range fdList {
+		fd, err := e.fdArg(map[string]interface{}{"fd": fdList[i]}, "fd")
+		if err != nil {
+			e.countError()
+			return "", fmt.Errorf("poll: fds[%d]: %w", i, err)
+		}
+		if e.fdObject(fd) == nil || e.isFdClosed(fd) {
+			return "", e.fdError("poll", "invalid file descriptor %d", fd)
+		}
+		if _, readable := e.fdObject(fd).(io.Reader); !readable {
+			return "", e.fdAccessError("poll", "file descriptor %d is not readable", fd)
+		}
+		fds = append(fds, fd)
+	}
+
+	timeout := defaultPollTimeout
+	if seconds, ok := args["timeout"].(float64); ok {
+		if seconds < 0 {
+			e.countError()
+			return "", fmt.Errorf("poll: timeout must not be negative")
+		}
+		timeout = time.Duration(seconds * float64(time.Second))
+		if timeout > maxPollTimeout {
+			timeout = maxPollTimeout
+		}
+	}
+
+	expired := e.clock.After(timeout)
+	for {
+		// Take the channel before checking so no activity is missed in between
+		ready := e.readyChannel()
+		entries, anyReady := e.pollStatus(fds)
+		if anyReady {
+			return pollResult(entries, false)
+		}
+		select {
+		case <-ready:
+		case <-expired:
+			entries, _ = e.pollStatus(fds)
+			return pollResult(entries, true)
+		}
+	}
+}
+
+// pollStatus checks each fd once and reports whether any is ready to read
+func (e *Engine) pollStatus(fds []int) ([]pollEntry, bool) {
+	var entries []pollEntry
+	anyReady := false
+	for _, fd := range fds {
+		entry := pollEntry{Fd: fd, Status: "ready"}
+		if pipe, ok := e.fdObject(fd).(*pipeReader); ok {
+			entry.Status, entry.Buffered = pipe.status()
+		}
+		if entry.Status != "pending" {
+			anyReady = true
+		}
+		entries = append(entries, entry)
+	}
+	return entries, anyReady
+}
+
+// pollResult formats the poll tool result
+func pollResult(entries []pollEntry, timedOut bool) (string, error) {
+	result := map[string]interface{}{
+		"fds":       entries,
+		"timed_out": timedOut,
+	}
+	if timedOut {
+		result["code"] = CodeTimeout
+	}
+	resultBytes, _ := json.Marshal(result)
+	return string(resultBytes), nil
+}
@@ -0,0 +1,157 @@
+package tools
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// exitGracePeriod is how long exit waits for spawned commands to finish after
+// their input is closed, and again after they are killed
+const exitGracePeriod = 2 * time.Second
+
+// maxReapOutput limits the unread output of each reaped command copied to stderr
+const maxReapOutput = 4096
+
+// reapedCommand describes a command still running when exit was called
+type reapedCommand struct {
+	script     string
+	exitCode   int
+	killed     bool // Killed after the grace period
+	abandoned  bool // Could not be stopped; its executor does not support cancellation
+	unread     int  // Bytes of output the LLM never read
+	truncated  bool // unread was capped at maxReapOutput
+	runningCmd *RunningCommand
+}
+
+// reapCommands stops the commands still running when exit is called: their
+// input is closed so they can finish, and those still running after the grace
+// period are killed. Output the LLM never read is copied to stderr, truncated.
+func (e *Engine) reapCommands() []reapedCommand {
+	e.commandsMutex.RLock()
+	seen := make(map[*RunningCommand]bool)
+	var pending []*RunningCommand
+	for _, runningCmd := range e.runningCommands {
+		runningCmd.mu.RLock()
+		finished := runningCmd.finished
+		runningCmd.mu.RUnlock()
+		if !finished && !seen[runningCmd] {
+			seen[runningCmd] = true
+			pending = append(pending, runningCmd)
+		}
+	}
+	e.commandsMutex.RUnlock()
+	if len(pending) == 0 {
+		return nil
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].pid < pending[j].pid })
+
+	reaped := make([]reapedCommand, len(pending))
+	for i, runningCmd := range pending {
+		reaped[i] = reapedCommand{script: runningCmd.commandName, runningCmd: runningCmd}
+		if runningCmd.stdin != nil {
+			runningCmd.stdin.Close()
+		}
+	}
+
+	// Give the commands a chance to finish on EOF, then kill the rest
+	if !e.waitExited(pending) {
+		for i, runningCmd := range pending {
+			if !isExited(runningCmd) {
+				reaped[i].killed = true
+				runningCmd.cancel()
+			}
+		}
+		e.waitExited(pending)
+	}
+
+	for i, runningCmd := range pending {
+		if !isExited(runningCmd) {
+			reaped[i].abandoned = true
+			continue
+		}
+		runningCmd.mu.RLock()
+		reaped[i].exitCode = runningCmd.exitCode
+		runningCmd.mu.RUnlock()
+		e.flushUnread(&reaped[i])
+	}
+	return reaped
+}
+
+// waitExited waits up to the grace period for all commands to finish and
+// reports whether they did
+func (e *Engine) waitExited(commands []*RunningCommand) bool {
+	deadline := e.clock.After(exitGracePeriod)
+	for _, runningCmd := range commands {
+		select {
+		case <-runningCmd.exited:
+		case <-deadline:
+			return false
+		}
+	}
+	return true
+}
+
+// isExited reports whether the command has finished
+func isExited(runningCmd *RunningCommand) bool {
+	select {
+	case <-runningCmd.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// flushUnread copies the output left in an exited command's pipes to stderr
+func (e *Engine) flushUnread(reaped *reapedCommand) {
+	var unread []byte
+	for _, pipe := range []io.Reader{reaped.runningCmd.stdout, reaped.runningCmd.stderr} {
+		if pipe == nil || len(unread) > maxReapOutput {
+			continue
+		}
+		// The writing end is closed once the command exits, so this cannot block
+		data, _ := io.ReadAll(io.LimitReader(pipe, int64(maxReapOutput+1-len(unread))))
+		unread = append(unread, data...)
+	}
+	if len(unread) == 0 {
+		return
+	}
+	if len(unread) > maxReapOutput {
+		unread = unread[:maxReapOutput]
+		reaped.truncated = true
+	}
+	reaped.unread = len(unread)
+	fmt.Fprintf(os.Stderr, "llmcmd: unread output of '%s':\n%s", reaped.script, unread)
+	if reaped.truncated {
+		fmt.Fprintf(os.Stderr, "\n... (truncated to %d bytes)", maxReapOutput)
+	}
+	fmt.Fprintln(os.Stderr)
+}
+
+// reapSummary describes the reaped commands for the exit tool result
+func reapSummary(reaped []reapedCommand) string {
+	parts := make([]string, len(reaped))
+	for i, r := range reaped {
+		var status string
+		switch {
+		case r.abandoned:
+			status = "could not be stopped"
+		case r.killed:
+			status = "killed"
+		default:
+			status = fmt.Sprintf("exit code %d", r.exitCode)
+		}
+		if r.unread > 0 {
+			more := ""
+			if r.truncated {
+				more = "+"
+			}
+			status += fmt.Sprintf(", %d%s unread bytes copied to stderr", r.unread, more)
+		}
+		parts[i] = fmt.Sprintf("'%s' (%s)", r.script, status)
+	}
+	return fmt.Sprintf("stopped %d running command(s): %s", len(reaped), strings.Join(parts, ", "))
+}
@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ScratchDirEnv names the per-run scratch directory in the environment of
+// spawned scripts and in open() paths
+const ScratchDirEnv = "LLMCMD_TMPDIR"
+
+// createScratchDir creates the real per-run directory for tools that need real paths
+func (e *Engine) createScratchDir() error {
+	dir, err := os.MkdirTemp("", "llmcmd-run-")
+	if err != nil {
+		return fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	e.scratchDir = dir
+	return nil
+}
+
+// scratchPath maps "$LLMCMD_TMPDIR/name" or a path inside the scratch directory
+// to a real path. ok is false for paths that belong to the VFS.
+func (e *Engine) scratchPath(path string) (string, bool, error) {
+	if e.scratchDir == "" {
+		return "", false, nil
+	}
+
+	for _, prefix := range []string{"$" + ScratchDirEnv, "${" + ScratchDirEnv + "}"} {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			path = e.scratchDir + path[len(prefix):]
+			break
+		}
+	}
+	if !filepath.IsAbs(path) {
+		return "", false, nil
+	}
+
+	rel, err := filepath.Rel(e.scratchDir, filepath.Clean(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false, nil
+	}
+	if rel == "." {
+		return "", true, fmt.Errorf("%s is a directory", ScratchDirEnv)
+	}
+	return filepath.Join(e.scratchDir, rel), true, nil
+}
+
+// withScratchEnv adds the scratch directory to a spawn environment (nil inherits ours)
+func (e *Engine) withScratchEnv(env []string) []string {
+	if e.scratchDir == "" {
+		return env
+	}
+	if env == nil {
+		env = os.Environ()
+	}
+	return append(env, ScratchDirEnv+"="+e.scratchDir)
+}
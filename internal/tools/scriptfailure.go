@@ -0,0 +1,41 @@
+package tools
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ScriptFailurePrefix starts the trailer line with which llmsh reports why a
+// script failed, as the last line of its stderr
+const ScriptFailurePrefix = "llmsh-failure: "
+
+// ScriptFailure describes why an llmsh script failed: the last command that
+// failed, its line in the script, and the script's exit status. spawn and wait
+// report it in place of a bare exit code.
+type ScriptFailure struct {
+	Command  string `json:"command,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	ExitCode int    `json:"exit_code"`
+	Message  string `json:"message,omitempty"`
+}
+
+// Trailer formats the failure as the trailer line llmsh writes on stderr
+func (f ScriptFailure) Trailer() string {
+	data, _ := json.Marshal(f)
+	return ScriptFailurePrefix + string(data) + "\n"
+}
+
+// splitScriptFailure removes a failure trailer from the end of stderr output,
+// returning the rest and the failure, or stderr unchanged and nil
+func splitScriptFailure(stderr string) (string, *ScriptFailure) {
+	trimmed := strings.TrimSuffix(stderr, "\n")
+	start := strings.LastIndexByte(trimmed, '\n') + 1
+	if !strings.HasPrefix(trimmed[start:], ScriptFailurePrefix) {
+		return stderr, nil
+	}
+	var failure ScriptFailure
+	if err := json.Unmarshal([]byte(trimmed[start+len(ScriptFailurePrefix):]), &failure); err != nil {
+		return stderr, nil
+	}
+	return stderr[:start], &failure
+}
@@ -0,0 +1,85 @@
+package toolstest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a deterministic tools.Clock: time only moves when Advance is called
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []clockWaiter
+	changed chan struct{} // Closed and replaced when waiters are added
+}
+
+// clockWaiter is a pending After call
+type clockWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// NewClock returns a fake clock set to start
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start, changed: make(chan struct{})}
+}
+
+// Now returns the fake current time
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the fake time once the clock has
+// been advanced by d
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, clockWaiter{deadline: c.now.Add(d), ch: ch})
+	close(c.changed)
+	c.changed = make(chan struct{})
+	return ch
+}
+
+// Advance moves the clock forward by d and fires every After call that is due
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.deadline.After(c.now) {
+			pending = append(pending, w)
+		} else {
+			w.ch <- c.now
+		}
+	}
+	c.waiters = pending
+}
+
+// Waiters returns the number of After calls that have not fired yet
+func (c *Clock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntil waits until at least n After calls are pending, so a test can
+// advance the clock only once the engine is actually waiting on it
+func (c *Clock) BlockUntil(n int) {
+	for {
+		c.mu.Lock()
+		count, changed := len(c.waiters), c.changed
+		c.mu.Unlock()
+		if count >= n {
+			return
+		}
+		<-changed
+	}
+}
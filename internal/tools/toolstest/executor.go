@@ -0,0 +1,159 @@
+package toolstest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/mako10k/llmcmd/internal/tools"
+)
+
+// Behavior is a scripted in-memory process: it reads stdin, writes stdout and
+// stderr, and returns nil or an error carrying its exit code. ctx is canceled
+// when the engine kills the process (spawn timeout).
+type Behavior func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer) error
+
+// ExitError is the error of a process that exited with a non-zero code
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string { return fmt.Sprintf("exit status %d", e.Code) }
+
+// ExitCode returns the exit code, as *exec.ExitError does
+func (e *ExitError) ExitCode() int { return e.Code }
+
+// Executor is a tools.ShellExecutor that runs scripted behaviors instead of
+// starting llmsh, matching spawn scripts exactly
+type Executor struct {
+	mu        sync.Mutex
+	behaviors map[string]Behavior
+	started   []string
+	vfs       tools.VirtualFileSystem
+}
+
+// NewExecutor returns an executor without behaviors; unknown scripts exit 127
+func NewExecutor() *Executor {
+	return &Executor{behaviors: make(map[string]Behavior)}
+}
+
+// Handle registers the behavior run for script
+func (x *Executor) Handle(script string, behavior Behavior) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.behaviors[script] = behavior
+}
+
+// Started returns the scripts run so far, in order
+func (x *Executor) Started() []string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return append([]string(nil), x.started...)
+}
+
+// VFS returns the virtual file system passed to SetVFS
+func (x *Executor) VFS() tools.VirtualFileSystem {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.vfs
+}
+
+// run looks up and runs the behavior for command
+func (x *Executor) run(ctx context.Context, command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	x.mu.Lock()
+	behavior, exists := x.behaviors[command]
+	x.started = append(x.started, command)
+	x.mu.Unlock()
+
+	if stdin == nil {
+		stdin = eofReader{}
+	}
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	if !exists {
+		fmt.Fprintf(stderr, "toolstest: no behavior for script %q\n", command)
+		return &ExitError{Code: 127}
+	}
+	return behavior(ctx, stdin, stdout, stderr)
+}
+
+// Execute runs command without input or output
+func (x *Executor) Execute(command string) error {
+	return x.run(context.Background(), command, nil, nil, nil)
+}
+
+// ExecuteWithIO runs command with the given streams
+func (x *Executor) ExecuteWithIO(command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return x.run(context.Background(), command, stdin, stdout, stderr)
+}
+
+// ExecuteWithEnv runs command with the given streams; behaviors do not see env
+func (x *Executor) ExecuteWithEnv(command string, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return x.run(context.Background(), command, stdin, stdout, stderr)
+}
+
+// ExecuteContext runs command until it finishes or ctx is canceled
+func (x *Executor) ExecuteContext(ctx context.Context, command string, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return x.run(ctx, command, stdin, stdout, stderr)
+}
+
+// SetVFS records the virtual file system
+func (x *Executor) SetVFS(vfs tools.VirtualFileSystem) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.vfs = vfs
+}
+
+// eofReader is an empty stdin
+type eofReader struct{}
+
+func (eofReader) Read([]byte) (int, error) { return 0, io.EOF }
+
+// Output writes text to stdout and exits 0
+func Output(text string) Behavior {
+	return func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer) error {
+		_, err := io.WriteString(stdout, text)
+		return err
+	}
+}
+
+// Cat copies stdin to stdout until EOF
+func Cat() Behavior {
+	return func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer) error {
+		_, err := io.Copy(stdout, stdin)
+		return err
+	}
+}
+
+// Fail writes message to stderr and exits with code
+func Fail(message string, code int) Behavior {
+	return func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer) error {
+		io.WriteString(stderr, message)
+		return &ExitError{Code: code}
+	}
+}
+
+// Block runs until the process is killed, like a script stuck in a loop
+func Block() Behavior {
+	return func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer) error {
+		<-ctx.Done()
+		return &ExitError{Code: 137}
+	}
+}
+
+// Sequence runs behaviors in order on the same streams, stopping at the first error
+func Sequence(behaviors ...Behavior) Behavior {
+	return func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer) error {
+		for _, behavior := range behaviors {
+			if err := behavior(ctx, stdin, stdout, stderr); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,122 @@
+// Package toolstest provides an in-memory harness for testing the tool
+// engine: a ShellExecutor running scripted process behaviors, a fake clock,
+// and helpers to drive tool calls, so fd chains, EOF and close semantics can
+// be tested without starting llmsh or waiting on real timers.
+package toolstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mako10k/llmcmd/internal/tools"
+)
+
+// Epoch is the time fake clocks created by New start at
+var Epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Harness is an engine wired to a fake executor and clock
+type Harness struct {
+	t        testing.TB
+	Engine   *tools.Engine
+	Executor *Executor
+	Clock    *Clock
+}
+
+// New creates an engine whose input files hold inputs ($1, $2, ...) and whose
+// ShellExecutor and Clock are fakes; fields left zero in config get test
+// defaults. The engine is closed when the test ends.
+func New(t testing.TB, config tools.EngineConfig, inputs ...string) *Harness {
+	t.Helper()
+
+	dir := t.TempDir()
+	for i, content := range inputs {
+		path := filepath.Join(dir, "input"+strconv.Itoa(i+1)+".txt")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("toolstest: write input file: %v", err)
+		}
+		config.InputFiles = append(config.InputFiles, path)
+	}
+	if config.BufferSize == 0 {
+		config.BufferSize = 4096
+	}
+	config.NoStdin = true
+
+	h := &Harness{t: t, Executor: NewExecutor(), Clock: NewClock(Epoch)}
+	if config.ShellExecutor == nil {
+		config.ShellExecutor = h.Executor
+	}
+	if config.Clock == nil {
+		config.Clock = h.Clock
+	}
+
+	engine, err := tools.NewEngine(config)
+	if err != nil {
+		t.Fatalf("toolstest: create engine: %v", err)
+	}
+	t.Cleanup(func() { engine.Close() })
+	h.Engine = engine
+	return h
+}
+
+// Call executes a tool call with raw JSON arguments
+func (h *Harness) Call(name, arguments string) (string, error) {
+	return h.Engine.ExecuteToolCall(map[string]interface{}{
+		"name":      name,
+		"arguments": arguments,
+	})
+}
+
+// MustCall executes a tool call, failing the test on error, and decodes its
+// JSON result into a map (nil for results that are not JSON objects)
+func (h *Harness) MustCall(name, arguments string) map[string]interface{} {
+	h.t.Helper()
+	result, err := h.Call(name, arguments)
+	if err != nil {
+		h.t.Fatalf("%s(%s) failed: %v", name, arguments, err)
+	}
+	var decoded map[string]interface{}
+	if json.Unmarshal([]byte(result), &decoded) != nil {
+		return nil
+	}
+	return decoded
+}
+
+// ReadAll reads fd with the read tool until EOF and returns the data without
+// the EOF and continuation markers the read tool appends
+func (h *Harness) ReadAll(fd int) string {
+	h.t.Helper()
+	var data strings.Builder
+	for {
+		result, err := h.Call("read", fmt.Sprintf(`{"fd": %d}`, fd))
+		if err != nil {
+			h.t.Fatalf("read(%d) failed: %v", fd, err)
+		}
+		if strings.HasPrefix(result, "--- EOF:") {
+			return data.String()
+		}
+		if i := strings.LastIndex(result, "\n--- EOF reached after "); i >= 0 {
+			data.WriteString(result[:i])
+			return data.String()
+		}
+		if i := strings.LastIndex(result, "\n--- more data: next_offset="); i >= 0 {
+			result = result[:i]
+		}
+		data.WriteString(result)
+	}
+}
+
+// Fd returns an integer field of a decoded tool result, such as in_fd
+func (h *Harness) Fd(result map[string]interface{}, key string) int {
+	h.t.Helper()
+	value, ok := result[key].(float64)
+	if !ok {
+		h.t.Fatalf("result has no %s: %v", key, result)
+	}
+	return int(value)
+}
@@ -0,0 +1,151 @@
+package toolstest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mako10k/llmcmd/internal/tools"
+)
+
+func TestSpawnCatEOFOnWrite(t *testing.T) {
+	h := New(t, tools.EngineConfig{})
+	h.Executor.Handle("cat", Cat())
+
+	spawned := h.MustCall("spawn", `{"script": "cat"}`)
+	inFd, outFd := h.Fd(spawned, "in_fd"), h.Fd(spawned, "out_fd")
+
+	h.MustCall("write", fmt.Sprintf(`{"fd": %d, "data": "hello", "eof": true}`, inFd))
+	if data := h.ReadAll(outFd); data != "hello" {
+		t.Errorf("read %q, want hello", data)
+	}
+	if waited := h.MustCall("wait", fmt.Sprintf(`{"fd": %d}`, outFd)); waited["finished"] != true || waited["exit_code"] != 0.0 {
+		t.Errorf("wait = %v, want exit 0", waited)
+	}
+}
+
+func TestCloseInputSignalsEOF(t *testing.T) {
+	h := New(t, tools.EngineConfig{})
+	h.Executor.Handle("cat", Cat())
+
+	spawned := h.MustCall("spawn", `{"script": "cat"}`)
+	inFd, outFd := h.Fd(spawned, "in_fd"), h.Fd(spawned, "out_fd")
+
+	h.MustCall("write", fmt.Sprintf(`{"fd": %d, "data": "partial"}`, inFd))
+	h.MustCall("close", fmt.Sprintf(`{"fd": %d}`, inFd))
+	if data := h.ReadAll(outFd); data != "partial" {
+		t.Errorf("read %q, want the data written before close", data)
+	}
+	if _, err := h.Call("write", fmt.Sprintf(`{"fd": %d, "data": "late"}`, inFd)); err == nil {
+		t.Error("write to a closed fd succeeded")
+	}
+}
+
+func TestSpawnChainFromInputFile(t *testing.T) {
+	h := New(t, tools.EngineConfig{}, "abc\n")
+	h.Executor.Handle("cat", Cat())
+	h.Executor.Handle("tail", Sequence(Cat(), Output("end\n")))
+
+	first := h.MustCall("spawn", `{"script": "cat", "in_fd": "$1"}`)
+	second := h.MustCall("spawn", fmt.Sprintf(`{"script": "tail", "in_fd": %d}`, h.Fd(first, "out_fd")))
+	if data := h.ReadAll(h.Fd(second, "out_fd")); data != "abc\nend\n" {
+		t.Errorf("read %q, want the input followed by the second stage", data)
+	}
+	// Both stages run concurrently, so only the set of scripts is fixed
+	started := h.Executor.Started()
+	sort.Strings(started)
+	if strings.Join(started, ",") != "cat,tail" {
+		t.Errorf("started = %v", started)
+	}
+}
+
+func TestUnknownScriptExits127(t *testing.T) {
+	h := New(t, tools.EngineConfig{})
+
+	spawned := h.MustCall("spawn", `{"script": "missing"}`)
+	waited := h.MustCall("wait", fmt.Sprintf(`{"fd": %d}`, h.Fd(spawned, "out_fd")))
+	if waited["exit_code"] != 127.0 || !strings.Contains(waited["stderr_tail"].(string), "no behavior") {
+		t.Errorf("wait = %v, want exit 127 with a stderr hint", waited)
+	}
+}
+
+func TestSpawnTimeoutWithFakeClock(t *testing.T) {
+	h := New(t, tools.EngineConfig{SpawnTimeout: time.Minute})
+	h.Executor.Handle("loop", Block())
+
+	spawned := h.MustCall("spawn", `{"script": "loop"}`)
+	h.Clock.BlockUntil(1)
+	h.Clock.Advance(time.Minute)
+
+	waited := h.MustCall("wait", fmt.Sprintf(`{"fd": %d}`, h.Fd(spawned, "out_fd")))
+	if waited["timed_out"] != true || waited["duration_ms"] != float64(time.Minute.Milliseconds()) {
+		t.Errorf("wait = %v, want a timeout after one fake minute", waited)
+	}
+}
+
+func TestWaitTimeoutWithFakeClock(t *testing.T) {
+	h := New(t, tools.EngineConfig{})
+	h.Executor.Handle("loop", Block())
+
+	spawned := h.MustCall("spawn", `{"script": "loop"}`)
+	results := make(chan map[string]interface{})
+	go func() {
+		results <- h.MustCall("wait", fmt.Sprintf(`{"fd": %d, "timeout": 5}`, h.Fd(spawned, "out_fd")))
+	}()
+	h.Clock.BlockUntil(1)
+	h.Clock.Advance(5 * time.Second)
+
+	if waited := <-results; waited["finished"] != false || waited["duration_ms"] != 5000.0 {
+		t.Errorf("wait = %v, want still running after 5 fake seconds", waited)
+	}
+}
+
+func TestToolLatencyUsesClock(t *testing.T) {
+	h := New(t, tools.EngineConfig{})
+	h.MustCall("fds", `{}`)
+
+	if latency := h.Engine.GetStats().Tools["fds"]; latency.Calls != 1 || latency.TotalLatency != 0 {
+		t.Errorf("latency = %+v, want one call taking no fake time", latency)
+	}
+}
+
+func TestExitReapsRunningCommands(t *testing.T) {
+	h := New(t, tools.EngineConfig{})
+	h.Executor.Handle("cat", Cat())
+	h.Executor.Handle("loop", Block())
+
+	cat := h.MustCall("spawn", `{"script": "cat"}`)
+	h.MustCall("spawn", `{"script": "loop"}`)
+	h.MustCall("write", fmt.Sprintf(`{"fd": %d, "data": "unread"}`, h.Fd(cat, "in_fd")))
+
+	type exitResult struct {
+		result string
+		err    error
+	}
+	done := make(chan exitResult)
+	go func() {
+		result, err := h.Call("exit", `{"code": 0}`)
+		done <- exitResult{result, err}
+	}()
+
+	// cat finishes on EOF; loop only stops once the grace period has passed
+	h.Clock.BlockUntil(1)
+	for {
+		waited := h.MustCall("wait", fmt.Sprintf(`{"fd": %d, "timeout": 0}`, h.Fd(cat, "out_fd")))
+		if waited["finished"] == true {
+			break
+		}
+	}
+	h.Clock.Advance(time.Minute)
+	exited := <-done
+	if exited.err == nil || exited.err.Error() != "EXIT_REQUESTED:0" {
+		t.Fatalf("exit error = %v, want an exit request", exited.err)
+	}
+	for _, want := range []string{"stopped 2 running command(s)", "'cat' (exit code 0, 6 unread bytes", "'loop' (killed)"} {
+		if !strings.Contains(exited.result, want) {
+			t.Errorf("exit result %q does not mention %q", exited.result, want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// ToolEvent describes one tool call as seen by a ToolHook
+type ToolEvent struct {
+	Tool       string        // Tool name, e.g. "read"
+	Args       string        // Raw JSON arguments
+	ArgsDigest string        // Short SHA-256 of Args, safe to log
+	Start      time.Time     // When the call started
+	Duration   time.Duration // Run time (OnToolEnd only)
+	Bytes      int64         // Bytes read and written by the call (OnToolEnd only)
+	Result     string        // Tool result (OnToolEnd only)
+	Err        error         // Tool error (OnToolEnd only)
+}
+
+// ToolHook receives structured events around every tool call
+type ToolHook interface {
+	OnToolStart(event ToolEvent)
+	OnToolEnd(event ToolEvent)
+}
+
+// argsDigest returns the first 12 hex digits of the SHA-256 of the arguments
+func argsDigest(args string) string {
+	sum := sha256.Sum256([]byte(args))
+	return hex.EncodeToString(sum[:6])
+}
+
+// traceToolCall runs call and reports it to the configured hooks
+func (e *Engine) traceToolCall(toolCall map[string]interface{}, call func() (string, error)) (string, error) {
+	if len(e.hooks) == 0 {
+		return call()
+	}
+
+	name, _ := toolCall["name"].(string)
+	args, _ := toolCall["arguments"].(string)
+	event := ToolEvent{Tool: name, Args: args, ArgsDigest: argsDigest(args), Start: e.clock.Now()}
+	for _, hook := range e.hooks {
+		hook.OnToolStart(event)
+	}
+
+	bytesBefore := e.bytesTransferred()
+	result, err := call()
+
+	event.Duration = e.clock.Now().Sub(event.Start)
+	event.Bytes = e.bytesTransferred() - bytesBefore
+	event.Result = result
+	event.Err = err
+	for _, hook := range e.hooks {
+		hook.OnToolEnd(event)
+	}
+	return result, err
+}
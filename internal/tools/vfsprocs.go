@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/mako10k/llmcmd/internal/vfsproxy"
+)
+
+// spawnedProcesses lets spawned scripts control the engine's other spawned
+// scripts over the VFS connection with PS, WAIT and KILL. A PID is the fd
+// the wait tool takes for the script.
+type spawnedProcesses struct {
+	e *Engine
+}
+
+// scripts returns the spawned scripts by PID
+func (p spawnedProcesses) scripts() map[int]*RunningCommand {
+	p.e.commandsMutex.RLock()
+	defer p.e.commandsMutex.RUnlock()
+	scripts := make(map[int]*RunningCommand)
+	for _, runningCmd := range p.e.runningCommands {
+		// Built-in commands have no exited channel and cannot be waited for
+		if runningCmd.exited != nil {
+			scripts[runningCmd.pid] = runningCmd
+		}
+	}
+	return scripts
+}
+
+// script returns the spawned script with the given PID
+func (p spawnedProcesses) script(pid int) (*RunningCommand, error) {
+	runningCmd, ok := p.scripts()[pid]
+	if !ok {
+		return nil, fmt.Errorf("process %d: %w", pid, syscall.ESRCH)
+	}
+	return runningCmd, nil
+}
+
+// process describes a spawned script for PS and WAIT
+func process(runningCmd *RunningCommand) vfsproxy.Process {
+	runningCmd.mu.RLock()
+	defer runningCmd.mu.RUnlock()
+	return vfsproxy.Process{
+		PID:      runningCmd.pid,
+		Command:  runningCmd.commandName,
+		Running:  !runningCmd.finished,
+		ExitCode: runningCmd.exitCode,
+	}
+}
+
+// Processes implements vfsproxy.ProcessController
+func (p spawnedProcesses) Processes() []vfsproxy.Process {
+	var procs []vfsproxy.Process
+	for _, runningCmd := range p.scripts() {
+		procs = append(procs, process(runningCmd))
+	}
+	sort.Slice(procs, func(i, j int) bool { return procs[i].PID < procs[j].PID })
+	return procs
+}
+
+// Wait implements vfsproxy.ProcessController
+func (p spawnedProcesses) Wait(pid int, timeout time.Duration) (vfsproxy.Process, error) {
+	runningCmd, err := p.script(pid)
+	if err != nil {
+		return vfsproxy.Process{}, err
+	}
+	select {
+	case <-runningCmd.exited:
+	case <-p.e.clock.After(timeout):
+	}
+	return process(runningCmd), nil
+}
+
+// Signal implements vfsproxy.ProcessController. The protocol only sends
+// SIGTERM and SIGKILL, and both stop the script the way the spawn timeout
+// does; one that has already exited is left alone.
+func (p spawnedProcesses) Signal(pid int, sig syscall.Signal) error {
+	runningCmd, err := p.script(pid)
+	if err != nil {
+		return err
+	}
+	if !isExited(runningCmd) && runningCmd.cancel != nil {
+		runningCmd.cancel()
+	}
+	return nil
+}
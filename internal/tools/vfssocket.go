@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/mako10k/llmcmd/internal/vfsproxy"
+)
+
+// vfsSocketPath returns the Unix socket on which the engine serves its VFS,
+// starting it on first use in a directory only we can enter. Every llmsh in a
+// spawned script connects to it on its own, where sharing fd 3 would make
+// background llmsh processes take turns on one connection. It returns "" when
+// the socket cannot be made; scripts then have fd 3 alone.
+func (e *Engine) vfsSocketPath() string {
+	e.vfsSocketOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "llmcmd-vfs-")
+		if err != nil {
+			return
+		}
+		listener, err := net.Listen("unix", filepath.Join(dir, "vfs.sock"))
+		if err != nil {
+			os.RemoveAll(dir)
+			return
+		}
+		e.vfsListener, e.vfsSocketDir = listener, dir
+		e.vfsServed = make(chan struct{})
+		go func() {
+			defer close(e.vfsServed)
+			vfsproxy.ServeListener(listener, e.virtualFS, spawnedProcesses{e})
+		}()
+	})
+	if e.vfsListener == nil {
+		return ""
+	}
+	return e.vfsListener.Addr().String()
+}
+
+// closeVFSSocket stops serving the VFS socket, closing its connections, and
+// removes it; a socket that was never started will not be
+func (e *Engine) closeVFSSocket() error {
+	e.vfsSocketOnce.Do(func() {})
+	if e.vfsListener == nil {
+		return nil
+	}
+	e.vfsListener.Close()
+	<-e.vfsServed
+	return os.RemoveAll(e.vfsSocketDir)
+}
@@ -0,0 +1,164 @@
+package vfsproxy
+
+import (
+	"io"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// FifoCapacity is the number of bytes a Fifo holds before writers block
+const FifoCapacity = 64 * 1024
+
+// FifoMaker is implemented by file systems that can make named pipes for
+// MKFIFO
+type FifoMaker interface {
+	// MakeFifo makes a named pipe called name, which must not exist yet
+	MakeFifo(name string, perm os.FileMode) error
+}
+
+// Fifo is a named pipe: what is written to it through any writer can be read
+// once through any reader. Unlike a FIFO of the OS, opening either end never
+// waits, so a writer may come first: its data waits in the pipe, and writes
+// block once FifoCapacity bytes are waiting. Reads block until data comes,
+// and see EOF once every writer that opened the pipe has closed it; writes
+// fail with EPIPE once every reader has closed it. When both ends are closed
+// and nothing is left to read, the pipe is as good as new.
+type Fifo struct {
+	mu        sync.Mutex
+	changed   *sync.Cond // Signalled when data, space or a closed end appears
+	buf       []byte
+	readers   int
+	writers   int
+	hadReader bool // A reader has opened the pipe since it was last unused
+	hadWriter bool // A writer has opened the pipe since it was last unused
+}
+
+// NewFifo returns an empty named pipe
+func NewFifo() *Fifo {
+	f := &Fifo{}
+	f.changed = sync.NewCond(&f.mu)
+	return f
+}
+
+// OpenReader opens the read end of the pipe; writing to it fails with EBADF
+func (f *Fifo) OpenReader() io.ReadWriteCloser {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.readers++
+	f.hadReader = true
+	return &fifoReader{fifo: f}
+}
+
+// OpenWriter opens the write end of the pipe; reading from it fails with EBADF
+func (f *Fifo) OpenWriter() io.ReadWriteCloser {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.writers++
+	f.hadWriter = true
+	f.changed.Broadcast()
+	return &fifoWriter{fifo: f}
+}
+
+// Buffered returns the number of bytes waiting to be read
+func (f *Fifo) Buffered() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.buf)
+}
+
+// read copies waiting data to p, waiting for some to arrive
+func (f *Fifo) read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for len(f.buf) == 0 {
+		if f.hadWriter && f.writers == 0 {
+			return 0, io.EOF
+		}
+		if len(p) == 0 {
+			return 0, nil
+		}
+		f.changed.Wait()
+	}
+	n := copy(p, f.buf)
+	f.buf = append(f.buf[:0], f.buf[n:]...)
+	f.changed.Broadcast()
+	return n, nil
+}
+
+// write adds p to the pipe, waiting for readers to make room
+func (f *Fifo) write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for n < len(p) {
+		if f.hadReader && f.readers == 0 {
+			return n, syscall.EPIPE
+		}
+		room := FifoCapacity - len(f.buf)
+		if room == 0 {
+			f.changed.Wait()
+			continue
+		}
+		chunk := min(room, len(p)-n)
+		f.buf = append(f.buf, p[n:n+chunk]...)
+		n += chunk
+		f.changed.Broadcast()
+	}
+	return n, nil
+}
+
+// closeEnd forgets a reader or a writer, resetting the pipe once it is unused
+func (f *Fifo) closeEnd(reader bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if reader {
+		f.readers--
+	} else {
+		f.writers--
+	}
+	if f.readers == 0 && f.writers == 0 && len(f.buf) == 0 {
+		f.hadReader, f.hadWriter = false, false
+	}
+	f.changed.Broadcast()
+}
+
+// fifoReader is an open read end of a Fifo
+type fifoReader struct {
+	fifo   *Fifo
+	closed sync.Once
+}
+
+func (r *fifoReader) Read(p []byte) (int, error) {
+	return r.fifo.read(p)
+}
+
+func (r *fifoReader) Write(p []byte) (int, error) {
+	return 0, syscall.EBADF
+}
+
+func (r *fifoReader) Close() error {
+	r.closed.Do(func() { r.fifo.closeEnd(true) })
+	return nil
+}
+
+// fifoWriter is an open write end of a Fifo
+type fifoWriter struct {
+	fifo   *Fifo
+	closed sync.Once
+}
+
+func (w *fifoWriter) Write(p []byte) (int, error) {
+	return w.fifo.write(p)
+}
+
+func (w *fifoWriter) Read(p []byte) (int, error) {
+	return 0, syscall.EBADF
+}
+
+func (w *fifoWriter) Close() error {
+	w.closed.Do(func() { w.fifo.closeEnd(false) })
+	return nil
+}
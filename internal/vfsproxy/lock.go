@@ -0,0 +1,56 @@
+package vfsproxy
+
+import "sync"
+
+// Locker is implemented by file systems that keep advisory locks for LOCK and
+// UNLOCK. Locks are taken on names, which need not be files, and only hold
+// back other lockers: reading and writing ignore them.
+type Locker interface {
+	// TryLock takes or converts owner's lock on name without waiting, and
+	// reports whether it was granted
+	TryLock(name, owner string, exclusive bool) bool
+	// Unlock releases owner's lock on name, if it holds one
+	Unlock(name, owner string)
+}
+
+// LockTable keeps advisory locks like flock(2): a name is locked either
+// exclusively by one owner or shared by any number of them. The zero value is
+// ready to use.
+type LockTable struct {
+	mu    sync.Mutex
+	locks map[string]map[string]bool // Name -> owner -> exclusive
+}
+
+// TryLock takes or converts owner's lock on name without waiting, and reports
+// whether it was granted
+func (t *LockTable) TryLock(name, owner string, exclusive bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	holders := t.locks[name]
+	for other, otherExclusive := range holders {
+		if other != owner && (exclusive || otherExclusive) {
+			return false
+		}
+	}
+	if holders == nil {
+		if t.locks == nil {
+			t.locks = make(map[string]map[string]bool)
+		}
+		holders = make(map[string]bool)
+		t.locks[name] = holders
+	}
+	holders[owner] = exclusive
+	return true
+}
+
+// Unlock releases owner's lock on name, if it holds one
+func (t *LockTable) Unlock(name, owner string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.locks[name], owner)
+	if len(t.locks[name]) == 0 {
+		delete(t.locks, name)
+	}
+}
@@ -0,0 +1,232 @@
+package vfsproxy
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// Truncater is implemented by file systems that can change a file's size for
+// TRUNCATE
+type Truncater interface {
+	// Truncate cuts the file to size bytes, or extends it with zeros
+	Truncate(name string, size int64) error
+}
+
+// openModes are the modes OPEN takes, as the open tool's
+var openModes = map[string]int{
+	"r":  os.O_RDONLY,
+	"w":  os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
+	"a":  os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+	"r+": os.O_RDWR,
+	"w+": os.O_RDWR | os.O_CREATE | os.O_TRUNC,
+	"a+": os.O_RDWR | os.O_CREATE | os.O_APPEND,
+}
+
+// openFile is a file opened with OPEN
+type openFile struct {
+	file  io.ReadWriteCloser
+	name  string
+	read  bool
+	write bool
+}
+
+// open opens name for the connection and answers the handle in decimal
+func (s *session) open(name, mode string) ([]byte, error) {
+	flag, ok := openModes[mode]
+	if !ok {
+		return nil, fmt.Errorf("invalid mode %q (valid modes: r, w, a, r+, w+, a+)", mode)
+	}
+	file, err := s.fs.OpenFile(name, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	access := flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR)
+	s.next++
+	s.files[s.next] = &openFile{
+		file:  file,
+		name:  name,
+		read:  access != os.O_WRONLY,
+		write: access != os.O_RDONLY,
+	}
+	return []byte(strconv.Itoa(s.next)), nil
+}
+
+// handleOpen executes READ, WRITE, SEEK or CLOSE on an open file
+func (s *session) handleOpen(op string, args []string, line string) ([]byte, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("malformed request %q", line)
+	}
+	handle, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed request %q", line)
+	}
+	f := s.files[handle]
+	if f == nil {
+		if op == "WRITE" && len(args) == 2 {
+			// Skip the payload so the next request is read right
+			if size, err := strconv.Atoi(args[1]); err == nil && size >= 0 && size <= MaxFileSize {
+				io.CopyN(io.Discard, s.reader, int64(size))
+			}
+		}
+		return nil, fmt.Errorf("handle %d: %w", handle, syscall.EBADF)
+	}
+	args = args[1:]
+
+	switch op {
+	case "READ":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("malformed request %q", line)
+		}
+		count, err := strconv.Atoi(args[0])
+		if err != nil || count < 0 || count > MaxFileSize {
+			return nil, fmt.Errorf("invalid count %q", args[0])
+		}
+		if !f.read {
+			return nil, fmt.Errorf("%s: %w (not open for reading)", f.name, syscall.EBADF)
+		}
+		// One read, as read(2): a pipe answers what it has
+		data := make([]byte, count)
+		n, err := f.file.Read(data)
+		if err == io.EOF {
+			err = nil
+		}
+		return data[:n], err
+
+	case "WRITE":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("malformed request %q", line)
+		}
+		size, err := strconv.Atoi(args[0])
+		if err != nil || size < 0 || size > MaxFileSize {
+			return nil, fmt.Errorf("invalid size %q", args[0])
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(s.reader, data); err != nil {
+			return nil, err
+		}
+		if !f.write {
+			return nil, fmt.Errorf("%s: %w (not open for writing)", f.name, syscall.EBADF)
+		}
+		_, err = f.file.Write(data)
+		return nil, err
+
+	case "SEEK":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("malformed request %q", line)
+		}
+		offset, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset %q", args[0])
+		}
+		whence, err := strconv.Atoi(args[1])
+		if err != nil || whence < io.SeekStart || whence > io.SeekEnd {
+			return nil, fmt.Errorf("invalid whence %q", args[1])
+		}
+		seeker, ok := f.file.(io.Seeker)
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", f.name, syscall.ESPIPE)
+		}
+		pos, err := seeker.Seek(offset, whence)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strconv.FormatInt(pos, 10)), nil
+
+	default: // CLOSE
+		if len(args) != 0 {
+			return nil, fmt.Errorf("malformed request %q", line)
+		}
+		delete(s.files, handle)
+		return nil, f.file.Close()
+	}
+}
+
+// File is a parent virtual file opened with Client.Open, read and written in
+// place through the connection. Each call is a request, so read and write in
+// large chunks.
+type File struct {
+	client *Client
+	handle int
+	name   string
+}
+
+// Open opens a parent virtual file in one of the open tool's modes: r, w, a,
+// r+, w+ or a+. Files opened with r+, w+ or a+ are regular files in the
+// parent and can be seeked; the others read and write like pipes.
+func (c *Client) Open(name, mode string) (*File, error) {
+	if _, ok := openModes[mode]; !ok {
+		return nil, fmt.Errorf("vfs: invalid mode %q (valid modes: r, w, a, r+, w+, a+)", mode)
+	}
+	data, err := c.request(fmt.Sprintf("OPEN %s %s\n", strconv.Quote(name), mode), nil)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := strconv.Atoi(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("vfs: malformed handle %q", data)
+	}
+	return &File{client: c, handle: handle, name: name}, nil
+}
+
+// Name returns the name the file was opened with
+func (f *File) Name() string {
+	return f.name
+}
+
+// Read implements io.Reader, returning io.EOF at the end of the file
+func (f *File) Read(p []byte) (int, error) {
+	if len(p) > MaxFileSize {
+		p = p[:MaxFileSize]
+	}
+	data, err := f.client.request(fmt.Sprintf("READ %d %d\n", f.handle, len(p)), nil)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) == 0 && len(p) > 0 {
+		return 0, io.EOF
+	}
+	return copy(p, data), nil
+}
+
+// Write implements io.Writer
+func (f *File) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), MaxFileSize)]
+		if _, err := f.client.request(fmt.Sprintf("WRITE %d %d\n", f.handle, len(chunk)), chunk); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// Seek implements io.Seeker; it fails with ESPIPE for files the parent reads
+// like pipes
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	data, err := f.client.request(fmt.Sprintf("SEEK %d %d %d\n", f.handle, offset, whence), nil)
+	if err != nil {
+		return 0, err
+	}
+	pos, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("vfs: malformed offset %q", data)
+	}
+	return pos, nil
+}
+
+// Close implements io.Closer
+func (f *File) Close() error {
+	_, err := f.client.request(fmt.Sprintf("CLOSE %d\n", f.handle), nil)
+	return err
+}
+
+// Truncate cuts a parent virtual file to size bytes, or extends it with zeros
+func (c *Client) Truncate(name string, size int64) error {
+	_, err := c.request(fmt.Sprintf("TRUNCATE %s %d\n", strconv.Quote(name), size), nil)
+	return err
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package vfsproxy
+
+import (
+	"errors"
+	"os"
+)
+
+// Pair is not supported on this platform; spawned scripts use their own files
+func Pair() (*os.File, *os.File, error) {
+	return nil, nil, errors.New("vfs proxy is not supported on this platform")
+}
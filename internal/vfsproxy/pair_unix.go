@@ -0,0 +1,26 @@
+//go:build unix
+
+package vfsproxy
+
+import (
+	"os"
+	"syscall"
+)
+
+// Pair returns the two ends of a connection: the parent serves on the first,
+// the second is passed to the child
+func Pair() (*os.File, *os.File, error) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return nil, nil, os.NewSyscallError("socketpair", err)
+	}
+	syscall.CloseOnExec(fds[0])
+	syscall.CloseOnExec(fds[1])
+	// A non-blocking parent end lets the server use deadlines
+	if err := syscall.SetNonblock(fds[0], true); err != nil {
+		syscall.Close(fds[0])
+		syscall.Close(fds[1])
+		return nil, nil, os.NewSyscallError("setnonblock", err)
+	}
+	return os.NewFile(uintptr(fds[0]), "vfs-parent"), os.NewFile(uintptr(fds[1]), "vfs-child"), nil
+}
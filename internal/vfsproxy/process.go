@@ -0,0 +1,167 @@
+package vfsproxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Process describes a command the parent runs in the background, for PS and
+// WAIT
+type Process struct {
+	PID      int    // Number the parent knows the command by
+	Command  string // What the command runs
+	Running  bool
+	ExitCode int // Set once the command has exited
+}
+
+// ProcessController is implemented by parents whose background commands can
+// be listed with PS, waited for with WAIT and stopped with KILL
+type ProcessController interface {
+	// Processes returns the background commands, running or exited, by PID
+	Processes() []Process
+	// Wait blocks until the command exits or timeout passes, and returns
+	// it as it is then
+	Wait(pid int, timeout time.Duration) (Process, error)
+	// Signal stops the command with sig, SIGTERM or SIGKILL; it fails with
+	// ESRCH for unknown PIDs
+	Signal(pid int, sig syscall.Signal) error
+}
+
+// signals are the signals KILL takes, by name. Both stop the command: a
+// parent may run it in-process, or on Windows, where no other signal can be
+// delivered.
+var signals = map[string]syscall.Signal{
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+}
+
+// parseSignal reads a signal given by name, with or without SIG, or number
+func parseSignal(s string) (syscall.Signal, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		for _, sig := range signals {
+			if int(sig) == n {
+				return sig, true
+			}
+		}
+		return 0, false
+	}
+	sig, ok := signals[strings.TrimPrefix(strings.ToUpper(s), "SIG")]
+	return sig, ok
+}
+
+// formatProcess is the line PS and WAIT answer for p
+func formatProcess(p Process) string {
+	state := "exited"
+	if p.Running {
+		state = "running"
+	}
+	return fmt.Sprintf("%d %s %d %s\n", p.PID, state, p.ExitCode, strconv.Quote(p.Command))
+}
+
+// parseProcess reads a line formatProcess made
+func parseProcess(line string) (Process, error) {
+	malformed := fmt.Errorf("vfs: malformed process %q", line)
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) != 4 || (fields[1] != "running" && fields[1] != "exited") {
+		return Process{}, malformed
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return Process{}, malformed
+	}
+	code, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return Process{}, malformed
+	}
+	command, err := strconv.Unquote(fields[3])
+	if err != nil {
+		return Process{}, malformed
+	}
+	return Process{PID: pid, Command: command, Running: fields[1] == "running", ExitCode: code}, nil
+}
+
+// handleProcess executes PS, WAIT or KILL
+func (s *session) handleProcess(op string, args []string, line string) ([]byte, error) {
+	if s.procs == nil {
+		return nil, fmt.Errorf("process control is not supported")
+	}
+	if op == "PS" {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("malformed request %q", line)
+		}
+		var b strings.Builder
+		for _, p := range s.procs.Processes() {
+			b.WriteString(formatProcess(p))
+		}
+		return []byte(b.String()), nil
+	}
+
+	if len(args) != 2 {
+		return nil, fmt.Errorf("malformed request %q", line)
+	}
+	pid, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed request %q", line)
+	}
+	if op == "WAIT" {
+		ms, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || ms < 0 {
+			return nil, fmt.Errorf("invalid timeout %q", args[1])
+		}
+		p, err := s.procs.Wait(pid, time.Duration(ms)*time.Millisecond)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(formatProcess(p)), nil
+	}
+	// KILL
+	sig, ok := parseSignal(args[1])
+	if !ok {
+		return nil, fmt.Errorf("invalid signal %q (valid signals: KILL, TERM)", args[1])
+	}
+	return nil, s.procs.Signal(pid, sig)
+}
+
+// Processes lists the parent's background commands
+func (c *Client) Processes() ([]Process, error) {
+	data, err := c.request("PS\n", nil)
+	if err != nil {
+		return nil, err
+	}
+	var procs []Process
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		p, err := parseProcess(line)
+		if err != nil {
+			return nil, err
+		}
+		procs = append(procs, p)
+	}
+	return procs, nil
+}
+
+// Wait waits up to timeout for a background command of the parent to exit;
+// the returned Process says whether it has
+func (c *Client) Wait(pid int, timeout time.Duration) (Process, error) {
+	data, err := c.request(fmt.Sprintf("WAIT %d %d\n", pid, timeout.Milliseconds()), nil)
+	if err != nil {
+		return Process{}, err
+	}
+	return parseProcess(strings.TrimSuffix(string(data), "\n"))
+}
+
+// Kill stops a background command of the parent with SIGTERM or SIGKILL
+func (c *Client) Kill(pid int, sig syscall.Signal) error {
+	for name, s := range signals {
+		if s == sig {
+			_, err := c.request(fmt.Sprintf("KILL %d %s\n", pid, name), nil)
+			return err
+		}
+	}
+	return fmt.Errorf("vfs: signal %v cannot be sent (valid signals: KILL, TERM)", sig)
+}
@@ -0,0 +1,60 @@
+package vfsproxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// SocketEnvVar announces the path of a Unix socket on which the parent serves
+// its VFS. Unlike the inherited fd, which every process of a script shares,
+// each llmsh connecting to the socket gets a connection of its own.
+const SocketEnvVar = "LLMCMD_VFS_SOCKET"
+
+// clientIDs numbers the connections Serve answers, for HELLO
+var clientIDs atomic.Int64
+
+// ServeListener accepts connections on l and serves each against fs and
+// procs, which may be nil, as ServeProcesses does, until l is closed; the
+// connections still open are closed then too
+func ServeListener(l net.Listener, fs FileSystem, procs ProcessController) error {
+	var mu sync.Mutex
+	conns := make(map[net.Conn]bool)
+	defer func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for conn := range conns {
+			conn.Close()
+		}
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		mu.Lock()
+		conns[conn] = true
+		mu.Unlock()
+		go func() {
+			ServeProcesses(conn, fs, procs)
+			conn.Close()
+			mu.Lock()
+			delete(conns, conn)
+			mu.Unlock()
+		}()
+	}
+}
+
+// DialSocket connects to a parent serving its VFS on the Unix socket at path
+func DialSocket(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("vfs: %w", err)
+	}
+	return NewClient(conn), nil
+}
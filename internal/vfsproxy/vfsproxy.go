@@ -0,0 +1,625 @@
+// Package vfsproxy lets a spawned llmsh read and write the virtual files of the
+// llmcmd that started it, over a connection inherited as a file descriptor.
+//
+// Requests are a line, followed by a payload for PUT; names are Go-quoted
+// strings. Every response is a line followed by a payload whose length the
+// line gives, so file contents pass through unchanged whatever bytes they
+// hold, newlines and NULs included:
+//
+//	HELLO <version>\n                  -> OK <n>\n<version> <id> | ERR "message"\n
+//	GET "name"\n                       -> OK <n>\n<n bytes> | ERR "message"\n
+//	PUT "name" trunc|append <n>\n<n bytes> -> OK 0\n      | ERR "message"\n
+//	LIST "pattern"\n                   -> OK <n>\n<n bytes> | ERR "message"\n
+//	STAT "name"\n                      -> OK <n>\n<n bytes> | ERR "message"\n
+//	RM "name"\n                        -> OK 0\n            | ERR "message"\n
+//	CP "src" "dst"\n                   -> OK 0\n            | ERR "message"\n
+//	LOCK "name" ex|sh <owner>\n        -> OK 1\n1|0          | ERR "message"\n
+//	UNLOCK "name" <owner>\n            -> OK 0\n            | ERR "message"\n
+//	MKFIFO "name" <perm>\n             -> OK 0\n            | ERR "message"\n
+//	TRUNCATE "name" <size>\n           -> OK 0\n            | ERR "message"\n
+//	OPEN "name" <mode>\n               -> OK <n>\n<handle>   | ERR "message"\n
+//	READ <handle> <count>\n            -> OK <n>\n<n bytes> | ERR "message"\n
+//	WRITE <handle> <n>\n<n bytes>      -> OK 0\n            | ERR "message"\n
+//	SEEK <handle> <offset> <whence>\n  -> OK <n>\n<offset>   | ERR "message"\n
+//	CLOSE <handle>\n                   -> OK 0\n            | ERR "message"\n
+//	PS\n                               -> OK <n>\n<n bytes> | ERR "message"\n
+//	WAIT <pid> <timeout ms>\n          -> OK <n>\n<n bytes> | ERR "message"\n
+//	KILL <pid> <signal>\n              -> OK 0\n            | ERR "message"\n
+//
+// A connection starts with HELLO, where the client names the ProtocolVersion
+// it speaks; the server answers with its own and a number identifying the
+// connection among all it serves, or refuses a version it does not speak. Any other request before a successful HELLO is refused and ends
+// the connection, so an llmsh and an llmcmd from different releases fail
+// with a clear error instead of misreading each other.
+//
+// LIST answers the Go-quoted names matching a path.Match pattern, one per
+// line, or all names for an empty pattern. STAT
+// answers the size of a file in decimal, without reading it, followed by its
+// permissions in octal and its modification and creation times in Unix
+// nanoseconds (0 when not known) if the file system tracks them. CP makes dst a
+// copy of src without reading src, so it is not consumed.
+//
+// LOCK takes an exclusive or shared advisory lock on a name for owner, a word
+// chosen by the client, and answers 1, or 0 when another owner holds a
+// conflicting lock; it never waits. Locks still held when the connection
+// closes are released, so a script that dies cannot leave a name locked.
+//
+// GET and PUT move whole files. For random access a file is opened with
+// OPEN in one of the open tool's modes (r, w, a, r+, w+, a+), which answers
+// a handle for READ, WRITE, SEEK and CLOSE. READ answers at most count bytes
+// from one read, none at the end of the file; SEEK takes an io.Seek whence
+// and answers the new offset, and fails with ESPIPE on files read like
+// pipes. Handles belong to the connection and are closed with it. TRUNCATE
+// cuts a file to size bytes or extends it with zeros, without opening it.
+//
+// PS lists the commands the parent runs in the background, one per line as
+// <pid> running|exited <exit code> "command", where the exit code is 0 while
+// the command runs. WAIT answers such a line for one command once it exits,
+// or when the timeout passes while it still runs. KILL stops the command with
+// TERM or KILL (names with or without SIG, or numbers), the only signals it
+// takes, and does not wait for the command to exit. All three are refused by a parent without a
+// ProcessController.
+//
+// MKFIFO makes a named pipe (see Fifo) with permissions in octal. GET of a
+// named pipe waits until its writers are done, and PUT waits while it is full;
+// STAT reports it with os.ModeNamedPipe in the permissions.
+package vfsproxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProtocolVersion is the version of the protocol this package speaks. Version
+// 1 had no HELLO and listed names unquoted.
+const ProtocolVersion = 2
+
+// EnvVar announces the inherited connection's fd number to spawned processes
+const EnvVar = "LLMCMD_VFS_FD"
+
+// MaxFileSize bounds a single transfer
+const MaxFileSize = 64 << 20
+
+// FileSystem is the part of the parent's VFS the proxy needs
+type FileSystem interface {
+	OpenFile(name string, flag int, perm os.FileMode) (io.ReadWriteCloser, error)
+}
+
+// Lister is implemented by file systems whose files can be listed for LIST
+type Lister interface {
+	// FileNames returns the names of the files that can currently be read
+	FileNames() []string
+}
+
+// Stater is implemented by file systems that can report a file's size for STAT
+type Stater interface {
+	// FileSize returns the size of a file that can be read
+	FileSize(name string) (int, error)
+}
+
+// FileStat describes a file for STAT
+type FileStat struct {
+	Size    int64
+	Mode    os.FileMode // Permission bits, and os.ModeNamedPipe for named pipes
+	ModTime time.Time   // Zero when not known
+	Created time.Time   // Zero when not known
+}
+
+// FileStater is implemented by file systems that track permissions and times;
+// STAT then reports them along with the size
+type FileStater interface {
+	FileStat(name string) (FileStat, error)
+}
+
+// Remover is implemented by file systems whose files can be removed with RM
+type Remover interface {
+	RemoveFile(name string) error
+}
+
+// Cloner is implemented by file systems that can copy a file for CP
+type Cloner interface {
+	// Clone makes dst a copy of src, replacing any file named dst
+	Clone(src, dst string) error
+}
+
+// Serve answers requests on conn against fs until conn is closed
+func Serve(conn io.ReadWriter, fs FileSystem) error {
+	return ServeProcesses(conn, fs, nil)
+}
+
+// ServeProcesses is Serve for a parent whose background commands procs
+// controls through PS, WAIT and KILL; with a nil procs they are refused
+func ServeProcesses(conn io.ReadWriter, fs FileSystem, procs ProcessController) error {
+	s := &session{
+		fs:     fs,
+		procs:  procs,
+		reader: bufio.NewReader(conn),
+		held:   make(map[heldLock]bool),
+		files:  make(map[int]*openFile),
+	}
+	defer s.close()
+	greeted := false
+	id := clientIDs.Add(1)
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		var data []byte
+		op, version, _ := strings.Cut(line, " ")
+		switch {
+		case op == "HELLO":
+			data, err = hello(version, id)
+			greeted = err == nil
+		case !greeted:
+			// What follows may be a payload rather than a request
+			err = fmt.Errorf("protocol version handshake missing: llmsh and llmcmd are from different releases")
+			fmt.Fprintf(conn, "ERR %s\n", strconv.Quote(err.Error()))
+			return err
+		default:
+			data, err = s.handle(line)
+		}
+		if err != nil {
+			_, err = fmt.Fprintf(conn, "ERR %s\n", strconv.Quote(err.Error()))
+		} else {
+			_, err = fmt.Fprintf(conn, "OK %d\n%s", len(data), data)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// hello answers HELLO with the server's version and the connection's id if
+// the server speaks the client's version
+func hello(version string, id int64) ([]byte, error) {
+	if v, err := strconv.Atoi(version); err != nil || v != ProtocolVersion {
+		return nil, fmt.Errorf("unsupported protocol version %q: llmcmd speaks version %d", version, ProtocolVersion)
+	}
+	return []byte(fmt.Sprintf("%d %d", ProtocolVersion, id)), nil
+}
+
+// session is what Serve keeps about one connection
+type session struct {
+	fs     FileSystem
+	procs  ProcessController // Nil when the parent offers no process control
+	reader *bufio.Reader
+	held   map[heldLock]bool // Locks taken through the connection
+	files  map[int]*openFile // Files opened with OPEN, by handle
+	next   int               // Handle of the next OPEN
+}
+
+// heldLock is a lock taken through a connection, released when it closes
+type heldLock struct {
+	name, owner string
+}
+
+// close releases the locks and closes the files the connection left behind
+func (s *session) close() {
+	for lock := range s.held {
+		s.fs.(Locker).Unlock(lock.name, lock.owner)
+	}
+	for _, f := range s.files {
+		f.file.Close()
+	}
+}
+
+// handle executes one request, reading any payload from the connection
+func (s *session) handle(line string) ([]byte, error) {
+	fs, reader, held := s.fs, s.reader, s.held
+	op, rest, _ := strings.Cut(line, " ")
+	switch op {
+	case "READ", "WRITE", "SEEK", "CLOSE":
+		return s.handleOpen(op, strings.Fields(rest), line)
+	case "PS", "WAIT", "KILL":
+		return s.handleProcess(op, strings.Fields(rest), line)
+	}
+	quoted, err := strconv.QuotedPrefix(rest)
+	if err != nil {
+		return nil, fmt.Errorf("malformed request %q", line)
+	}
+	name, _ := strconv.Unquote(quoted)
+	args := strings.Fields(rest[len(quoted):])
+
+	switch op {
+	case "GET":
+		file, err := fs.OpenFile(name, os.O_RDONLY, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		data, err := io.ReadAll(io.LimitReader(file, MaxFileSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > MaxFileSize {
+			return nil, fmt.Errorf("%s: larger than %d bytes", name, MaxFileSize)
+		}
+		return data, nil
+
+	case "PUT":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("malformed request %q", line)
+		}
+		size, err := strconv.Atoi(args[1])
+		if err != nil || size < 0 || size > MaxFileSize {
+			return nil, fmt.Errorf("invalid size %q", args[1])
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+
+		flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if args[0] == "append" {
+			flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		file, err := fs.OpenFile(name, flag, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.Write(data); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return nil, file.Close()
+
+	case "LIST":
+		lister, ok := fs.(Lister)
+		if !ok {
+			return nil, fmt.Errorf("listing files is not supported")
+		}
+		if _, err := path.Match(name, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q", name)
+		}
+		var matches []string
+		for _, file := range lister.FileNames() {
+			if ok, _ := path.Match(name, file); ok || name == "" {
+				matches = append(matches, strconv.Quote(file)+"\n")
+			}
+		}
+		sort.Strings(matches)
+		return []byte(strings.Join(matches, "")), nil
+
+	case "STAT":
+		if stater, ok := fs.(FileStater); ok {
+			stat, err := stater.FileStat(name)
+			if err != nil {
+				return nil, err
+			}
+			return []byte(fmt.Sprintf("%d %o %d %d", stat.Size, uint32(stat.Mode&statModeBits), unixNano(stat.ModTime), unixNano(stat.Created))), nil
+		}
+		stater, ok := fs.(Stater)
+		if !ok {
+			return nil, fmt.Errorf("file sizes are not supported")
+		}
+		size, err := stater.FileSize(name)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strconv.Itoa(size)), nil
+
+	case "RM":
+		remover, ok := fs.(Remover)
+		if !ok {
+			return nil, fmt.Errorf("removing files is not supported")
+		}
+		return nil, remover.RemoveFile(name)
+
+	case "CP":
+		cloner, ok := fs.(Cloner)
+		if !ok {
+			return nil, fmt.Errorf("copying files is not supported")
+		}
+		rest = strings.TrimSpace(rest[len(quoted):])
+		quotedDst, err := strconv.QuotedPrefix(rest)
+		if err != nil || quotedDst != rest {
+			return nil, fmt.Errorf("malformed request %q", line)
+		}
+		dst, _ := strconv.Unquote(quotedDst)
+		return nil, cloner.Clone(name, dst)
+
+	case "LOCK":
+		locker, ok := fs.(Locker)
+		if !ok {
+			return nil, fmt.Errorf("locking files is not supported")
+		}
+		if len(args) != 2 || (args[0] != "ex" && args[0] != "sh") {
+			return nil, fmt.Errorf("malformed request %q", line)
+		}
+		if !locker.TryLock(name, args[1], args[0] == "ex") {
+			return []byte("0"), nil
+		}
+		held[heldLock{name, args[1]}] = true
+		return []byte("1"), nil
+
+	case "UNLOCK":
+		locker, ok := fs.(Locker)
+		if !ok {
+			return nil, fmt.Errorf("locking files is not supported")
+		}
+		if len(args) != 1 {
+			return nil, fmt.Errorf("malformed request %q", line)
+		}
+		locker.Unlock(name, args[0])
+		delete(held, heldLock{name, args[0]})
+		return nil, nil
+
+	case "OPEN":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("malformed request %q", line)
+		}
+		return s.open(name, args[0])
+
+	case "TRUNCATE":
+		truncater, ok := fs.(Truncater)
+		if !ok {
+			return nil, fmt.Errorf("truncating files is not supported")
+		}
+		if len(args) != 1 {
+			return nil, fmt.Errorf("malformed request %q", line)
+		}
+		size, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid size %q", args[0])
+		}
+		return nil, truncater.Truncate(name, size)
+
+	case "MKFIFO":
+		maker, ok := fs.(FifoMaker)
+		if !ok {
+			return nil, fmt.Errorf("named pipes are not supported")
+		}
+		if len(args) != 1 {
+			return nil, fmt.Errorf("malformed request %q", line)
+		}
+		perm, err := strconv.ParseUint(args[0], 8, 32)
+		if err != nil || perm > uint64(os.ModePerm) {
+			return nil, fmt.Errorf("invalid permissions %q", args[0])
+		}
+		return nil, maker.MakeFifo(name, os.FileMode(perm))
+
+	default:
+		return nil, fmt.Errorf("unknown request %q", op)
+	}
+}
+
+// Client talks to Serve on the other end of a connection. The first request
+// starts with the HELLO handshake.
+type Client struct {
+	mu      sync.Mutex
+	conn    io.ReadWriter
+	reader  *bufio.Reader
+	greeted bool
+	id      int64 // The connection's number, given by the server in HELLO
+	err     error // Why the handshake failed
+}
+
+// NewClient creates a client over conn
+func NewClient(conn io.ReadWriter) *Client {
+	return &Client{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// Dial connects to the parent through an inherited file descriptor
+func Dial(fd int) (*Client, error) {
+	file := os.NewFile(uintptr(fd), "vfs")
+	if file == nil {
+		return nil, fmt.Errorf("invalid vfs fd %d", fd)
+	}
+	return NewClient(file), nil
+}
+
+// ID returns the number the parent gave this connection, shaking hands first
+// if no request has been made yet
+func (c *Client) ID() (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.handshakeLocked(); err != nil {
+		return 0, err
+	}
+	return c.id, nil
+}
+
+// ReadFile returns the contents of a parent virtual file
+func (c *Client) ReadFile(name string) ([]byte, error) {
+	return c.request(fmt.Sprintf("GET %s\n", strconv.Quote(name)), nil)
+}
+
+// WriteFile replaces (or appends to) a parent virtual file
+func (c *Client) WriteFile(name string, data []byte, append bool) error {
+	mode := "trunc"
+	if append {
+		mode = "append"
+	}
+	_, err := c.request(fmt.Sprintf("PUT %s %s %d\n", strconv.Quote(name), mode, len(data)), data)
+	return err
+}
+
+// ListFiles returns the names of the parent virtual files matching pattern,
+// a path.Match pattern, in sorted order; an empty pattern matches all files
+func (c *Client) ListFiles(pattern string) ([]string, error) {
+	data, err := c.request(fmt.Sprintf("LIST %s\n", strconv.Quote(pattern)), nil)
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	names := make([]string, len(lines))
+	for i, line := range lines {
+		if names[i], err = strconv.Unquote(line); err != nil {
+			return nil, fmt.Errorf("vfs: malformed name %q", line)
+		}
+	}
+	return names, nil
+}
+
+// FileSize returns the size of a parent virtual file, without reading it
+func (c *Client) FileSize(name string) (int, error) {
+	stat, err := c.Stat(name)
+	return int(stat.Size), err
+}
+
+// Stat describes a parent virtual file without reading it. Permissions and
+// times are zero when the parent does not track them.
+func (c *Client) Stat(name string) (FileStat, error) {
+	data, err := c.request(fmt.Sprintf("STAT %s\n", strconv.Quote(name)), nil)
+	if err != nil {
+		return FileStat{}, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 1 && len(fields) != 4 {
+		return FileStat{}, fmt.Errorf("vfs: malformed stat %q", data)
+	}
+	var stat FileStat
+	if stat.Size, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+		return FileStat{}, fmt.Errorf("vfs: malformed size %q", data)
+	}
+	if len(fields) == 1 {
+		return stat, nil
+	}
+	mode, err := strconv.ParseUint(fields[1], 8, 32)
+	if err != nil {
+		return FileStat{}, fmt.Errorf("vfs: malformed stat %q", data)
+	}
+	stat.Mode = os.FileMode(mode) & statModeBits
+	for i, t := range []*time.Time{&stat.ModTime, &stat.Created} {
+		nanos, err := strconv.ParseInt(fields[2+i], 10, 64)
+		if err != nil {
+			return FileStat{}, fmt.Errorf("vfs: malformed stat %q", data)
+		}
+		if nanos != 0 {
+			*t = time.Unix(0, nanos)
+		}
+	}
+	return stat, nil
+}
+
+// statModeBits are the bits of a file mode STAT reports
+const statModeBits = os.ModePerm | os.ModeNamedPipe
+
+// unixNano returns t in Unix nanoseconds, or 0 for the zero time
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// RemoveFile removes a parent virtual file
+func (c *Client) RemoveFile(name string) error {
+	_, err := c.request(fmt.Sprintf("RM %s\n", strconv.Quote(name)), nil)
+	return err
+}
+
+// CloneFile makes the parent virtual file dst a copy of src, without
+// consuming src
+func (c *Client) CloneFile(src, dst string) error {
+	_, err := c.request(fmt.Sprintf("CP %s %s\n", strconv.Quote(src), strconv.Quote(dst)), nil)
+	return err
+}
+
+// TryLock asks for an advisory lock on a parent name for owner, a word unique
+// to the caller, without waiting; it reports whether the lock was granted
+func (c *Client) TryLock(name, owner string, exclusive bool) (bool, error) {
+	mode := "sh"
+	if exclusive {
+		mode = "ex"
+	}
+	data, err := c.request(fmt.Sprintf("LOCK %s %s %s\n", strconv.Quote(name), mode, owner), nil)
+	return string(data) == "1", err
+}
+
+// Unlock releases owner's advisory lock on a parent name
+func (c *Client) Unlock(name, owner string) error {
+	_, err := c.request(fmt.Sprintf("UNLOCK %s %s\n", strconv.Quote(name), owner), nil)
+	return err
+}
+
+// MakeFifo makes a named pipe in the parent, through which scripts spawned
+// separately can stream data to each other
+func (c *Client) MakeFifo(name string, perm os.FileMode) error {
+	_, err := c.request(fmt.Sprintf("MKFIFO %s %o\n", strconv.Quote(name), uint32(perm.Perm())), nil)
+	return err
+}
+
+// request sends one request and reads its response, shaking hands first on
+// a new connection
+func (c *Client) request(header string, payload []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.handshakeLocked(); err != nil {
+		return nil, err
+	}
+	return c.roundTrip(header, payload)
+}
+
+// handshakeLocked sends HELLO unless it has been sent; a failed handshake
+// fails every later request too
+func (c *Client) handshakeLocked() error {
+	if c.err != nil {
+		return c.err
+	}
+	if !c.greeted {
+		data, err := c.roundTrip(fmt.Sprintf("HELLO %d\n", ProtocolVersion), nil)
+		if err == nil {
+			version, id, _ := strings.Cut(string(data), " ")
+			if version != strconv.Itoa(ProtocolVersion) {
+				err = fmt.Errorf("vfs: llmcmd answered protocol version %q", version)
+			} else if c.id, err = strconv.ParseInt(id, 10, 64); err != nil {
+				err = fmt.Errorf("vfs: malformed connection id %q", id)
+			}
+		}
+		if err != nil {
+			c.err = fmt.Errorf("%w (this llmsh speaks protocol version %d)", err, ProtocolVersion)
+			return c.err
+		}
+		c.greeted = true
+	}
+	return nil
+}
+
+// roundTrip writes one request and reads one response
+func (c *Client) roundTrip(header string, payload []byte) ([]byte, error) {
+	if _, err := c.conn.Write(append([]byte(header), payload...)); err != nil {
+		return nil, fmt.Errorf("vfs: %w", err)
+	}
+
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("vfs: %w", err)
+	}
+	status, rest, _ := strings.Cut(strings.TrimSuffix(line, "\n"), " ")
+	switch status {
+	case "OK":
+		size, err := strconv.Atoi(rest)
+		if err != nil || size < 0 || size > MaxFileSize {
+			return nil, fmt.Errorf("vfs: malformed response %q", line)
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, fmt.Errorf("vfs: %w", err)
+		}
+		return data, nil
+	case "ERR":
+		message, err := strconv.Unquote(rest)
+		if err != nil {
+			message = rest
+		}
+		return nil, fmt.Errorf("vfs: %s", message)
+	default:
+		return nil, fmt.Errorf("vfs: malformed response %q", line)
+	}
+}
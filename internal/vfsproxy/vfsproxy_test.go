@@ -0,0 +1,611 @@
+package vfsproxy
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// mapFS is an in-memory FileSystem for proxy tests
+type mapFS struct {
+	mu    sync.Mutex
+	files map[string]*bytes.Buffer
+}
+
+type mapFile struct {
+	*bytes.Buffer
+}
+
+func (mapFile) Close() error { return nil }
+
+func (m *mapFS) OpenFile(name string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	buf, exists := m.files[name]
+	if !exists {
+		if flag&os.O_CREATE == 0 {
+			return nil, os.ErrNotExist
+		}
+		buf = &bytes.Buffer{}
+		m.files[name] = buf
+	}
+	if flag&os.O_TRUNC != 0 {
+		buf.Reset()
+	}
+	return mapFile{buf}, nil
+}
+
+func (m *mapFS) FileNames() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for name := range m.files {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (m *mapFS) FileSize(name string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	buf, exists := m.files[name]
+	if !exists {
+		return 0, os.ErrNotExist
+	}
+	return buf.Len(), nil
+}
+
+func (m *mapFS) RemoveFile(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.files[name]; !exists {
+		return os.ErrNotExist
+	}
+	delete(m.files, name)
+	return nil
+}
+
+func (m *mapFS) Clone(src, dst string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	buf, exists := m.files[src]
+	if !exists {
+		return os.ErrNotExist
+	}
+	m.files[dst] = bytes.NewBuffer(bytes.Clone(buf.Bytes()))
+	return nil
+}
+
+func TestClientReadsAndWritesServedFiles(t *testing.T) {
+	fs := &mapFS{files: map[string]*bytes.Buffer{"log": bytes.NewBufferString("ERROR one\n")}}
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		Serve(serverConn, fs)
+		serverConn.Close()
+	}()
+	client := NewClient(clientConn)
+
+	data, err := client.ReadFile("log")
+	if err != nil || string(data) != "ERROR one\n" {
+		t.Fatalf("ReadFile(log) = %q, %v", data, err)
+	}
+
+	if err := client.WriteFile("out file.txt", []byte("a\n"), false); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := client.WriteFile("out file.txt", []byte("b\n"), true); err != nil {
+		t.Fatalf("WriteFile(append) failed: %v", err)
+	}
+	if got := fs.files["out file.txt"].String(); got != "a\nb\n" {
+		t.Errorf("out file.txt = %q, want %q", got, "a\nb\n")
+	}
+
+	if _, err := client.ReadFile("missing"); err == nil || !strings.Contains(err.Error(), "not exist") {
+		t.Errorf("ReadFile(missing) error = %v, want a not-exist error", err)
+	}
+	// The connection stays usable after an error
+	if _, err := client.ReadFile("log"); err != nil {
+		t.Errorf("ReadFile after error failed: %v", err)
+	}
+}
+
+func TestClientTransfersAnyBytes(t *testing.T) {
+	fs := &mapFS{files: map[string]*bytes.Buffer{}}
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		Serve(serverConn, fs)
+		serverConn.Close()
+	}()
+	client := NewClient(clientConn)
+
+	// Data that looks like protocol lines, and a name with a newline
+	data := []byte("a\nOK 3\n\x00\xff\r\nERR \"x\"\n")
+	name := "odd\nname"
+	if err := client.WriteFile(name, data, false); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if got, err := client.ReadFile(name); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("ReadFile = %q, %v, want %q", got, err, data)
+	}
+	if names, err := client.ListFiles(""); err != nil || len(names) != 1 || names[0] != name {
+		t.Errorf("ListFiles = %q, %v, want [%q]", names, err, name)
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	// A request without HELLO is refused and ends the connection
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	served := make(chan error, 1)
+	go func(conn net.Conn) {
+		served <- Serve(conn, &mapFS{files: map[string]*bytes.Buffer{}})
+		conn.Close()
+	}(serverConn)
+	go clientConn.Write([]byte("PUT \"a\" trunc 4\n"))
+	line, _ := bufio.NewReader(clientConn).ReadString('\n')
+	if !strings.HasPrefix(line, "ERR ") || !strings.Contains(line, "handshake") {
+		t.Errorf("response without HELLO = %q, want a handshake error", line)
+	}
+	if err := <-served; err == nil {
+		t.Error("Serve() kept the connection without HELLO")
+	}
+
+	// A server of another version refuses the client's
+	serverConn, clientConn = net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		reader := bufio.NewReader(serverConn)
+		reader.ReadString('\n')
+		serverConn.Write([]byte(`ERR "unknown request \"HELLO\""` + "\n"))
+		serverConn.Close()
+	}()
+	client := NewClient(clientConn)
+	if _, err := client.ReadFile("a"); err == nil || !strings.Contains(err.Error(), "protocol version 2") {
+		t.Errorf("ReadFile() from an old server error = %v, want a protocol version error", err)
+	}
+	if _, err := client.ReadFile("a"); err == nil {
+		t.Error("second ReadFile() after a failed handshake succeeded")
+	}
+
+	if _, err := hello("1", 1); err == nil {
+		t.Error("hello(1) succeeded, want an unsupported version error")
+	}
+}
+
+func TestClientListsMatchingFiles(t *testing.T) {
+	fs := &mapFS{files: map[string]*bytes.Buffer{
+		"b.log": {}, "a.log": {}, "notes.txt": {}, "dir/c.log": {},
+	}}
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		Serve(serverConn, fs)
+		serverConn.Close()
+	}()
+	client := NewClient(clientConn)
+
+	names, err := client.ListFiles("*.log")
+	if err != nil || strings.Join(names, ",") != "a.log,b.log" {
+		t.Errorf("ListFiles(*.log) = %q, %v, want [a.log b.log]", names, err)
+	}
+	if names, err := client.ListFiles("*.csv"); err != nil || len(names) != 0 {
+		t.Errorf("ListFiles(*.csv) = %q, %v, want no names", names, err)
+	}
+	if names, err := client.ListFiles(""); err != nil || strings.Join(names, ",") != "a.log,b.log,dir/c.log,notes.txt" {
+		t.Errorf("ListFiles(\"\") = %q, %v, want all names", names, err)
+	}
+	if _, err := client.ListFiles("[a"); err == nil {
+		t.Error("ListFiles([a) succeeded, want an invalid pattern error")
+	}
+}
+
+func TestClientStatsAndRemovesFiles(t *testing.T) {
+	fs := &mapFS{files: map[string]*bytes.Buffer{"log": bytes.NewBufferString("ERROR one\n")}}
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		Serve(serverConn, fs)
+		serverConn.Close()
+	}()
+	client := NewClient(clientConn)
+
+	if size, err := client.FileSize("log"); err != nil || size != 10 {
+		t.Errorf("FileSize(log) = %d, %v, want 10", size, err)
+	}
+	if _, err := client.FileSize("missing"); err == nil {
+		t.Error("FileSize(missing) succeeded, want an error")
+	}
+
+	if err := client.RemoveFile("log"); err != nil {
+		t.Fatalf("RemoveFile(log) failed: %v", err)
+	}
+	if _, exists := fs.files["log"]; exists {
+		t.Error("log still exists after RemoveFile")
+	}
+	if err := client.RemoveFile("log"); err == nil {
+		t.Error("second RemoveFile(log) succeeded, want an error")
+	}
+}
+
+func TestClientClonesFiles(t *testing.T) {
+	fs := &mapFS{files: map[string]*bytes.Buffer{"my file": bytes.NewBufferString("data\n")}}
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		Serve(serverConn, fs)
+		serverConn.Close()
+	}()
+	client := NewClient(clientConn)
+
+	if err := client.CloneFile("my file", "backup \"1\""); err != nil {
+		t.Fatalf("CloneFile() failed: %v", err)
+	}
+	if got := fs.files["backup \"1\""]; got == nil || got.String() != "data\n" {
+		t.Errorf("clone = %v, want %q", got, "data\n")
+	}
+	if got := fs.files["my file"].String(); got != "data\n" {
+		t.Errorf("source after CloneFile = %q, want it unread", got)
+	}
+	if err := client.CloneFile("missing", "x"); err == nil {
+		t.Error("CloneFile(missing) succeeded, want an error")
+	}
+}
+
+// statFS adds permissions and times to mapFS
+type statFS struct {
+	*mapFS
+	modTime time.Time
+}
+
+func (s statFS) FileStat(name string) (FileStat, error) {
+	size, err := s.FileSize(name)
+	return FileStat{Size: int64(size), Mode: 0600, ModTime: s.modTime}, err
+}
+
+func TestClientStatsPermissionsAndTimes(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 5, time.UTC)
+	fs := statFS{&mapFS{files: map[string]*bytes.Buffer{"log": bytes.NewBufferString("ERROR one\n")}}, modTime}
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		Serve(serverConn, fs)
+		serverConn.Close()
+	}()
+	client := NewClient(clientConn)
+
+	stat, err := client.Stat("log")
+	if err != nil {
+		t.Fatalf("Stat(log) failed: %v", err)
+	}
+	if stat.Size != 10 || stat.Mode != 0600 || !stat.ModTime.Equal(modTime) || !stat.Created.IsZero() {
+		t.Errorf("Stat(log) = %+v, want 10 bytes, mode 0600, modified %v and no creation time", stat, modTime)
+	}
+	if size, err := client.FileSize("log"); err != nil || size != 10 {
+		t.Errorf("FileSize(log) = %d, %v, want 10", size, err)
+	}
+}
+
+// lockFS adds advisory locks to mapFS
+type lockFS struct {
+	*mapFS
+	*LockTable
+}
+
+func TestClientLocksUntilUnlockOrClose(t *testing.T) {
+	fs := lockFS{&mapFS{files: map[string]*bytes.Buffer{}}, &LockTable{}}
+	connect := func() (*Client, net.Conn) {
+		serverConn, clientConn := net.Pipe()
+		done := make(chan struct{})
+		go func() {
+			Serve(serverConn, fs)
+			serverConn.Close()
+			close(done)
+		}()
+		t.Cleanup(func() { clientConn.Close() })
+		return NewClient(clientConn), &waitConn{clientConn, done}
+	}
+	first, firstConn := connect()
+	second, _ := connect()
+
+	lock := func(c *Client, owner string, exclusive bool, want bool) {
+		t.Helper()
+		got, err := c.TryLock("report", owner, exclusive)
+		if err != nil || got != want {
+			t.Errorf("TryLock(report, %s, exclusive=%v) = %v, %v, want %v", owner, exclusive, got, err, want)
+		}
+	}
+	lock(first, "a", true, true)
+	lock(second, "b", false, false)
+	lock(first, "a", true, true) // Taking it again is a no-op
+	if err := first.Unlock("report", "a"); err != nil {
+		t.Fatalf("Unlock() failed: %v", err)
+	}
+	lock(second, "b", false, true)
+	lock(first, "a", false, true)
+	lock(second, "c", true, false)
+
+	// Closing a connection releases what its owners still hold
+	lock(second, "b", true, false)
+	firstConn.Close()
+	lock(second, "b", true, true)
+}
+
+// waitConn waits for the server to see the connection closed
+type waitConn struct {
+	net.Conn
+	done chan struct{}
+}
+
+func (c *waitConn) Close() error {
+	err := c.Conn.Close()
+	<-c.done
+	return err
+}
+
+func TestFifo(t *testing.T) {
+	fifo := NewFifo()
+
+	// A writer may come first; its data waits for a reader
+	w := fifo.OpenWriter()
+	io.WriteString(w, "early\n")
+	w.Close()
+	r := fifo.OpenReader()
+	if got, err := io.ReadAll(r); err != nil || string(got) != "early\n" {
+		t.Errorf("ReadAll() = %q, %v, want the data written before the reader came", got, err)
+	}
+	r.Close()
+
+	// Once unused the pipe starts afresh: a reader waits for the next writer
+	r = fifo.OpenReader()
+	done := make(chan string)
+	go func() {
+		got, _ := io.ReadAll(r)
+		done <- string(got)
+	}()
+	w = fifo.OpenWriter()
+	big := strings.Repeat("x", FifoCapacity+1)
+	if n, err := io.WriteString(w, big); err != nil || n != len(big) {
+		t.Errorf("Write() of more than FifoCapacity = %d, %v", n, err)
+	}
+	w.Close()
+	if got := <-done; got != big {
+		t.Errorf("read %d bytes, want %d", len(got), len(big))
+	}
+
+	// Writing after every reader has gone fails
+	w = fifo.OpenWriter()
+	r.Close()
+	if _, err := w.Write([]byte("x")); !errors.Is(err, syscall.EPIPE) {
+		t.Errorf("Write() without readers: err = %v, want EPIPE", err)
+	}
+}
+
+func TestServeListener(t *testing.T) {
+	fs := &mapFS{files: map[string]*bytes.Buffer{"log": bytes.NewBufferString("ERROR one\n")}}
+	path := filepath.Join(t.TempDir(), "vfs.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("Unix sockets are not available: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- ServeListener(listener, fs, nil) }()
+
+	first, err := DialSocket(path)
+	if err != nil {
+		t.Fatalf("DialSocket() error = %v", err)
+	}
+	second, err := DialSocket(path)
+	if err != nil {
+		t.Fatalf("DialSocket() error = %v", err)
+	}
+	firstID, err := first.ID()
+	if err != nil {
+		t.Fatalf("ID() error = %v", err)
+	}
+	if secondID, err := second.ID(); err != nil || secondID == firstID {
+		t.Errorf("second ID() = %d, %v; want an id other than %d", secondID, err, firstID)
+	}
+
+	if err := first.WriteFile("out.txt", []byte("a\n"), false); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if data, err := second.ReadFile("out.txt"); err != nil || string(data) != "a\n" {
+		t.Errorf("ReadFile() over the other connection = %q, %v", data, err)
+	}
+
+	listener.Close()
+	if err := <-served; err != nil {
+		t.Errorf("ServeListener() = %v after Close, want nil", err)
+	}
+	if _, err := first.ReadFile("log"); err == nil {
+		t.Error("ReadFile() after the listener closed succeeded")
+	}
+}
+
+// dirFS serves the real files of a directory, which can be seeked
+type dirFS string
+
+func (d dirFS) OpenFile(name string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
+	return os.OpenFile(filepath.Join(string(d), name), flag, perm)
+}
+
+func (d dirFS) Truncate(name string, size int64) error {
+	return os.Truncate(filepath.Join(string(d), name), size)
+}
+
+func TestClientOpensFilesForRandomAccess(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "data"), []byte("0123456789"), 0644)
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		Serve(serverConn, dirFS(dir))
+		serverConn.Close()
+	}()
+	client := NewClient(clientConn)
+
+	f, err := client.Open("data", "r+")
+	if err != nil {
+		t.Fatalf("Open(r+) error = %v", err)
+	}
+	if pos, err := f.Seek(-4, io.SeekEnd); err != nil || pos != 6 {
+		t.Errorf("Seek(-4, end) = %d, %v; want 6", pos, err)
+	}
+	buf := make([]byte, 3)
+	if n, err := f.Read(buf); err != nil || string(buf[:n]) != "678" {
+		t.Errorf("Read() = %q, %v; want %q", buf[:n], err, "678")
+	}
+	if _, err := f.Seek(2, io.SeekStart); err != nil {
+		t.Fatalf("Seek(2) error = %v", err)
+	}
+	if _, err := f.Write([]byte("ab\ncd")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := f.Close(); err == nil {
+		t.Error("second Close() succeeded, want a bad handle error")
+	}
+
+	if err := client.Truncate("data", 4); err != nil {
+		t.Fatalf("Truncate() error = %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "data")); string(got) != "01ab" {
+		t.Errorf("data = %q, want %q", got, "01ab")
+	}
+
+	// A write-only file cannot be read, and the connection stays usable
+	// after a write to a closed handle
+	w, err := client.Open("new", "w")
+	if err != nil {
+		t.Fatalf("Open(w) error = %v", err)
+	}
+	if _, err := w.Read(buf); err == nil {
+		t.Error("Read() of a write-only file succeeded")
+	}
+	w.Close()
+	if _, err := w.Write([]byte("lost")); err == nil {
+		t.Error("Write() to a closed handle succeeded")
+	}
+	if _, err := client.Open("data", "x"); err == nil {
+		t.Error("Open() with an invalid mode succeeded")
+	}
+	if data, err := client.ReadFile("data"); err != nil || string(data) != "01ab" {
+		t.Errorf("ReadFile() = %q, %v", data, err)
+	}
+}
+
+func TestSeekOnPipeLikeFile(t *testing.T) {
+	fs := &mapFS{files: map[string]*bytes.Buffer{"log": bytes.NewBufferString("line\n")}}
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		Serve(serverConn, fs)
+		serverConn.Close()
+	}()
+	client := NewClient(clientConn)
+
+	f, err := client.Open("log", "r")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if _, err := f.Seek(1, io.SeekStart); err == nil || !strings.Contains(err.Error(), "illegal seek") {
+		t.Errorf("Seek() error = %v, want illegal seek", err)
+	}
+	if data, err := io.ReadAll(f); err != nil || string(data) != "line\n" {
+		t.Errorf("ReadAll() = %q, %v", data, err)
+	}
+	if err := client.Truncate("log", 0); err == nil {
+		t.Error("Truncate() on a file system without it succeeded")
+	}
+}
+
+// fakeProcs is a ProcessController for proxy tests
+type fakeProcs struct {
+	procs  []Process
+	killed map[int]syscall.Signal
+}
+
+func (f *fakeProcs) Processes() []Process { return f.procs }
+
+func (f *fakeProcs) Wait(pid int, timeout time.Duration) (Process, error) {
+	for _, p := range f.procs {
+		if p.PID == pid {
+			return p, nil
+		}
+	}
+	return Process{}, syscall.ESRCH
+}
+
+func (f *fakeProcs) Signal(pid int, sig syscall.Signal) error {
+	f.killed[pid] = sig
+	return nil
+}
+
+func TestClientControlsProcesses(t *testing.T) {
+	procs := &fakeProcs{
+		procs: []Process{
+			{PID: 4, Command: "sort \"a b\"", Running: true},
+			{PID: 6, Command: "false", ExitCode: 1},
+		},
+		killed: make(map[int]syscall.Signal),
+	}
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go func(conn net.Conn) {
+		ServeProcesses(conn, &mapFS{files: map[string]*bytes.Buffer{}}, procs)
+		conn.Close()
+	}(serverConn)
+	client := NewClient(clientConn)
+
+	got, err := client.Processes()
+	if err != nil || len(got) != 2 || got[0] != procs.procs[0] || got[1] != procs.procs[1] {
+		t.Errorf("Processes() = %+v, %v; want %+v", got, err, procs.procs)
+	}
+	if p, err := client.Wait(6, time.Second); err != nil || p != procs.procs[1] {
+		t.Errorf("Wait(6) = %+v, %v", p, err)
+	}
+	if _, err := client.Wait(5, time.Second); err == nil || !strings.Contains(err.Error(), "no such process") {
+		t.Errorf("Wait(5) error = %v, want no such process", err)
+	}
+	if err := client.Kill(4, syscall.SIGTERM); err != nil || procs.killed[4] != syscall.SIGTERM {
+		t.Errorf("Kill(4, SIGTERM) = %v, sent %v", err, procs.killed[4])
+	}
+	if err := client.Kill(4, syscall.SIGINT); err == nil {
+		t.Error("Kill(SIGINT) succeeded")
+	}
+	for _, sig := range []string{"sigterm", "9"} {
+		if _, err := client.request("KILL 6 "+sig+"\n", nil); err != nil {
+			t.Errorf("KILL 6 %s error = %v", sig, err)
+		}
+	}
+	if procs.killed[6] != syscall.SIGKILL {
+		t.Errorf("KILL 6 9 sent %v, want SIGKILL", procs.killed[6])
+	}
+	if _, err := client.request("KILL 6 HUP\n", nil); err == nil || !strings.Contains(err.Error(), "valid signals: KILL, TERM") {
+		t.Errorf("KILL 6 HUP error = %v, want the valid signals", err)
+	}
+
+	// Without a ProcessController the requests are refused
+	serverConn, clientConn = net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		Serve(serverConn, &mapFS{files: map[string]*bytes.Buffer{}})
+		serverConn.Close()
+	}()
+	if _, err := NewClient(clientConn).Processes(); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("Processes() without a controller error = %v, want not supported", err)
+	}
+}